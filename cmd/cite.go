@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/spf13/cobra"
+)
+
+var citeStyle string
+
+var citeCmd = &cobra.Command{
+	Use:   "cite [epub files...]",
+	Short: "Write citation entries from EPUB metadata",
+	Long: `Write citation entries for EPUB files from their metadata, for a reference
+manager or a bibliography.
+
+Styles: bibtex, ris (EndNote, Zotero, Mendeley) and csl-json (Pandoc, Zotero).
+Authors, editors and translators, the series, publisher, year, language and
+subjects are included, and the ISBN and DOI when an identifier is one. The
+entries are written to standard output.
+
+Examples:
+  publify cite paper.epub
+  publify cite *.epub --style ris > library.ris
+  publify cite report.epub --style csl-json > references.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCite,
+}
+
+func init() {
+	rootCmd.AddCommand(citeCmd)
+
+	citeCmd.Flags().StringVarP(&citeStyle, "style", "s", metadata.CiteBibTeX, "Citation style (bibtex, ris, csl-json)")
+}
+
+func runCite(cmd *cobra.Command, args []string) error {
+	books := make([]metadata.EPUBMetadata, 0, len(args))
+	for _, epubPath := range args {
+		meta, err := readCitedMetadata(epubPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", epubPath, err)
+		}
+		books = append(books, meta)
+	}
+
+	citations, err := metadata.Cite(books, citeStyle)
+	if err != nil {
+		return err
+	}
+	fmt.Print(citations)
+	return nil
+}
+
+// readCitedMetadata reads the metadata of a book to cite
+func readCitedMetadata(epubPath string) (metadata.EPUBMetadata, error) {
+	if err := validateEPUBFile(epubPath); err != nil {
+		return metadata.EPUBMetadata{}, err
+	}
+
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return metadata.EPUBMetadata{}, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	meta, err := reader.GetMetadata()
+	if err != nil {
+		return metadata.EPUBMetadata{}, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return meta, nil
+}
@@ -5,24 +5,35 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/epubzip"
+	"github.com/alde/publify/internal/logging"
+	"github.com/alde/publify/internal/safefile"
+	"github.com/alde/publify/internal/xhtml"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/reader"
 	"github.com/spf13/cobra"
 )
 
-// noCompressionWriter implements io.WriteCloser for fast compression mode
-type noCompressionWriter struct {
-	io.Writer
-}
-
-func (w *noCompressionWriter) Close() error {
-	return nil
-}
-
 var (
 	compressOutputPath string
 	compressionLevel   string
+	compressReader     string
+	compressForce      bool
+	compressBackup     bool
+	compressTidy       bool
+	compressUnits      bool
+	compressReproduce  bool
+	compressExclude    []string
+	compressKeepJunk   bool
+	compressContainer  bool
+	compressValidate   bool
 )
 
 var compressCmd = &cobra.Command{
@@ -32,11 +43,36 @@ var compressCmd = &cobra.Command{
 
 The folder should contain the EPUB structure with META-INF/, OEBPS/, and other
 standard EPUB files. This is typically used after extracting and editing an EPUB.
+A missing or wrong mimetype file is written correctly in the EPUB, and with
+--add-container a missing META-INF/container.xml is created pointing at the folder's
+package document (.opf). The folder itself is left as it is.
+
+--validate checks the folder before anything is written: manifest items whose files
+are missing, files the manifest doesn't list, spine entries that aren't in the
+manifest and a missing navigation document. If it finds any, no EPUB is made.
 
 Examples:
   publify compress extracted_book/ -o fixed_book.epub
   publify compress book_folder/ --output book.epub
-  publify compress folder/ -o book.epub --compression fast`,
+  publify compress folder/ -o book.epub --compression fast
+  publify compress folder/ -o book.epub --reader kindle
+  publify compress folder/ -o book.epub --force --backup
+  publify compress edited/ -o book.epub --tidy
+  publify compress edited/ -o book.epub --reader kobo --relative-units
+  publify compress edited/ -o book.epub --reproducible
+  publify compress edited/ -o book.epub --exclude "drafts" --exclude "*.orig"
+  publify compress handmade/ -o book.epub --add-container
+  publify compress edited/ -o book.epub --validate
+
+Files the operating system or an editor leave behind (.DS_Store, Thumbs.db, ._ files,
+swap and ~ backup files, __MACOSX/ and .git/) are left out, since EPUB checkers refuse
+files the manifest doesn't list; --keep-junk packs them anyway. --exclude leaves out
+anything else matching a glob: a pattern with a slash matches the path in the folder
+("OEBPS/notes/*"), one without matches file and folder names anywhere ("*.orig").
+
+With --reproducible every file is stamped with SOURCE_DATE_EPOCH (or 1980-01-01 if it
+isn't set) and the same permissions, so compressing the same folder twice gives
+byte-identical EPUBs.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runCompress,
 }
@@ -45,7 +81,20 @@ func init() {
 	rootCmd.AddCommand(compressCmd)
 
 	compressCmd.Flags().StringVarP(&compressOutputPath, "output", "o", "", "Output EPUB file path (required)")
-	compressCmd.Flags().StringVar(&compressionLevel, "compression", "default", "Compression level (fast, default, best)")
+	compressCmd.Flags().StringVar(&compressionLevel, "compression", "default", "Compression level (store, fast, default, best)")
+	compressCmd.Flags().StringVar(&compressReader, "reader", "", "Warn about content that exceeds this reader's limits (kobo, kindle, generic)")
+
+	compressCmd.Flags().BoolVar(&compressForce, "force", false, "Overwrite the output file if it already exists")
+	compressCmd.Flags().BoolVar(&compressBackup, "backup", false, "Keep an overwritten output file as <output>.bak (implies --force)")
+
+	compressCmd.Flags().BoolVar(&compressTidy, "tidy", false, "Fix unclosed tags, bare ampersands and similar slips in XHTML files")
+	compressCmd.Flags().BoolVar(&compressReproduce, "reproducible", false, "Fix timestamps and permissions so the same folder always gives the same bytes")
+	compressCmd.Flags().BoolVar(&compressUnits, "relative-units", false, "Rewrite pixel sizes in stylesheets as em and % for the --reader's screen")
+
+	compressCmd.Flags().StringArrayVar(&compressExclude, "exclude", nil, "Leave out files and folders matching this glob (repeatable)")
+	compressCmd.Flags().BoolVar(&compressKeepJunk, "keep-junk", false, "Pack .DS_Store, Thumbs.db, editor swap files and the like too")
+	compressCmd.Flags().BoolVar(&compressContainer, "add-container", false, "Create META-INF/container.xml if it's missing, pointing at the folder's .opf")
+	compressCmd.Flags().BoolVar(&compressValidate, "validate", false, "Check the manifest and spine against the folder first, and stop if they don't match")
 
 	compressCmd.MarkFlagRequired("output")
 }
@@ -54,7 +103,8 @@ func runCompress(cmd *cobra.Command, args []string) error {
 	folderPath := args[0]
 
 	// Validate input folder
-	if err := validateCompressInputFolder(folderPath); err != nil {
+	opfPath, err := validateCompressInputFolder(folderPath, compressContainer)
+	if err != nil {
 		return fmt.Errorf("input folder validation failed: %w", err)
 	}
 
@@ -62,81 +112,179 @@ func runCompress(cmd *cobra.Command, args []string) error {
 	if err := validateOutputPath(compressOutputPath); err != nil {
 		return fmt.Errorf("output validation failed: %w", err)
 	}
+	if err := checkOverwrite(compressOutputPath, compressForce || compressBackup); err != nil {
+		return err
+	}
 
 	// Validate compression level
-	if err := validateCompressionLevel(compressionLevel); err != nil {
+	if err := epubzip.ValidateLevel(compressionLevel); err != nil {
 		return fmt.Errorf("compression validation failed: %w", err)
 	}
+	filter, err := epubzip.NewFilter(nil, compressExclude)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the target reader up front so a typo fails before we write anything
+	var profile *reader.Profile
+	if compressReader != "" {
+		p, err := reader.GetProfile(compressReader)
+		if err != nil {
+			return fmt.Errorf("reader profile error: %w", err)
+		}
+		profile = &p
+	}
+	if compressUnits && profile == nil {
+		return fmt.Errorf("--relative-units needs a --reader to size things for")
+	}
+
+	if compressValidate {
+		if err := validateFolderStructure(folderPath, opfPath, filter); err != nil {
+			return err
+		}
+	}
 
 	// Compress folder to EPUB
-	return compressToEPUB(folderPath, compressOutputPath)
+	if err := compressToEPUB(folderPath, compressOutputPath, opfPath, filter, contentRewriter(profile)); err != nil {
+		return err
+	}
+
+	if profile != nil {
+		return checkReaderLimits(compressOutputPath, *profile)
+	}
+
+	return nil
+}
+
+// checkReaderLimits warns about content documents the target reader may fail to open
+func checkReaderLimits(epubPath string, profile reader.Profile) error {
+	warnings, err := converter.NewEPUBOptimizer(profile).CheckContentDocuments(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to check content documents: %w", err)
+	}
+
+	for _, warning := range warnings {
+		console.Printf("⚠️  %s may not open on %s: %s\n", warning.Path, profile.Name, warning)
+	}
+
+	return nil
 }
 
-func validateCompressInputFolder(folderPath string) error {
+// validateCompressInputFolder checks the folder holds a book. If it has no container.xml
+// and addContainer is set, it returns the package document one should point to.
+func validateCompressInputFolder(folderPath string, addContainer bool) (string, error) {
 	// Check if folder exists
 	stat, err := os.Stat(folderPath)
 	if os.IsNotExist(err) {
-		return fmt.Errorf("input folder does not exist: %s", folderPath)
+		return "", fmt.Errorf("input folder does not exist: %s", folderPath)
 	}
 	if !stat.IsDir() {
-		return fmt.Errorf("input path is not a directory: %s", folderPath)
+		return "", fmt.Errorf("input path is not a directory: %s", folderPath)
 	}
 
-	// Check for required EPUB structure
-	requiredFiles := []string{
-		"mimetype",
-		"META-INF/container.xml",
+	// The mimetype file is easy to lose and has only one right content, so it's
+	// written whether it's there or not. container.xml has to point somewhere.
+	if _, err := os.Stat(filepath.Join(folderPath, "META-INF", "container.xml")); err == nil {
+		return "", nil
+	}
+	if !addContainer {
+		return "", fmt.Errorf("missing required EPUB file: META-INF/container.xml (this doesn't look like an extracted EPUB folder; --add-container creates one pointing at the .opf)")
 	}
 
-	for _, requiredFile := range requiredFiles {
-		fullPath := filepath.Join(folderPath, requiredFile)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			return fmt.Errorf("missing required EPUB file: %s (this doesn't look like an extracted EPUB folder)", requiredFile)
+	return findPackageDocument(folderPath)
+}
+
+// validateFolderStructure checks the folder's manifest and spine against the files that
+// will be packed, and refuses if they don't match. opfPath is the package document a
+// created container.xml points to ("" = the folder has its own).
+func validateFolderStructure(folderPath, opfPath string, filter epubzip.Filter) error {
+	// Excluded folders leave out everything in them
+	ignore := func(name string) bool {
+		for dir := name; dir != "."; dir = path.Dir(dir) {
+			if !filter.Match(dir) {
+				return true
+			}
 		}
+		return false
 	}
 
-	return nil
+	fsys := os.DirFS(folderPath)
+	var problems []metadata.Problem
+	var err error
+	if opfPath != "" {
+		problems, err = metadata.ValidatePackage(fsys, opfPath, ignore)
+	} else {
+		problems, err = metadata.ValidateStructure(fsys, ignore)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to validate %s: %w", folderPath, err)
+	}
+
+	if len(problems) == 0 {
+		console.Printf("✅ Manifest and spine match the folder\n")
+		return nil
+	}
+	for _, problem := range problems {
+		console.Printf("❌ %s\n", problem)
+	}
+	return fmt.Errorf("found %d structural problems in %s, so no EPUB was written", len(problems), folderPath)
 }
 
-func validateCompressionLevel(level string) error {
-	validLevels := []string{"fast", "default", "best"}
-	for _, valid := range validLevels {
-		if level == valid {
+// findPackageDocument returns the path of the folder's one .opf file, as it goes in the EPUB
+func findPackageDocument(folderPath string) (string, error) {
+	var found []string
+	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != folderPath && epubzip.IsJunkFolder(path) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".opf") || epubzip.IsJunk(path) {
 			return nil
 		}
+		relPath, err := filepath.Rel(folderPath, path)
+		if err != nil {
+			return err
+		}
+		found = append(found, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look for the package document: %w", err)
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no package document (.opf) in %s to point container.xml at", folderPath)
+	case 1:
+		return found[0], nil
 	}
-	return fmt.Errorf("invalid compression level: %s (valid options: %s)", level, strings.Join(validLevels, ", "))
+	return "", fmt.Errorf("more than one package document (%s), so write META-INF/container.xml yourself", strings.Join(found, ", "))
 }
 
-func compressToEPUB(folderPath, outputPath string) error {
-	// Create output file
-	outputFile, err := os.Create(outputPath)
+// compressToEPUB packs the folder into an EPUB, adding a META-INF/container.xml pointing
+// to opfPath ("" = the folder has one)
+func compressToEPUB(folderPath, outputPath, opfPath string, filter epubzip.Filter, rewrite rewriteFunc) error {
+	// Create output file next to the destination, renamed into place once complete
+	outputFile, err := safefile.Create(outputPath, compressBackup)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outputFile.Close()
-
-	// Create ZIP writer
-	zipWriter := zip.NewWriter(outputFile)
-	defer zipWriter.Close()
-
-	// Set compression level
-	switch compressionLevel {
-	case "fast":
-		// Use no compression for speed
-		zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
-			return &noCompressionWriter{Writer: out}, nil
-		})
-	case "best":
-		// Use maximum compression - this will be slower but create smaller files
-		// The default deflate compressor already uses good compression
-	default:
-		// Use default compression
+	defer outputFile.Abort()
+
+	// Create ZIP writer, deflating at the level asked for. Images and fonts that are
+	// already compressed are stored, since deflating them again only costs time.
+	zipWriter, err := epubzip.NewWriter(outputFile, epubzip.Options{
+		Level:                compressionLevel,
+		StoreCompressedMedia: true,
+		Reproducible:         compressReproduce,
+	})
+	if err != nil {
+		return err
 	}
 
-	if verbose {
-		fmt.Printf("Compressing folder %s to EPUB: %s\n", folderPath, outputPath)
-	}
+	logging.Module("epub").Debug("Compressing folder", "folder", folderPath, "output", outputPath)
 
 	// Special handling for mimetype file (must be uncompressed and first in ZIP per EPUB spec)
 	mimetypePath := filepath.Join(folderPath, "mimetype")
@@ -145,6 +293,18 @@ func compressToEPUB(folderPath, outputPath string) error {
 	}
 
 	fileCount := 1 // Already added mimetype
+	if opfPath != "" {
+		writer, err := zipWriter.Create(&zip.FileHeader{Name: "META-INF/container.xml", Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("failed to create container.xml: %w", err)
+		}
+		if _, err := writer.Write(metadata.ContainerXML(opfPath)); err != nil {
+			return fmt.Errorf("failed to write container.xml: %w", err)
+		}
+		console.Printf("🧩 Added META-INF/container.xml pointing to %s\n", opfPath)
+		fileCount++
+	}
+	skipped := 0
 
 	// Walk through directory and add files (excluding mimetype which we already added)
 	err = filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
@@ -157,11 +317,6 @@ func compressToEPUB(folderPath, outputPath string) error {
 			return nil
 		}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
-
 		// Get relative path for ZIP entry
 		relPath, err := filepath.Rel(folderPath, path)
 		if err != nil {
@@ -171,14 +326,35 @@ func compressToEPUB(folderPath, outputPath string) error {
 		// Normalize path separators for ZIP (always use forward slashes)
 		relPath = filepath.ToSlash(relPath)
 
+		// Pass over junk and excluded folders whole
+		if info.IsDir() {
+			if relPath != "." && ((!compressKeepJunk && epubzip.IsJunkFolder(relPath)) || !filter.Match(relPath)) {
+				logging.Module("epub").Debug("Skipped folder", "path", relPath)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if (!compressKeepJunk && epubzip.IsJunk(relPath)) || !filter.Match(relPath) {
+			logging.Module("epub").Debug("Skipped file", "path", relPath)
+			skipped++
+			return nil
+		}
+
+		if rewrite != nil && (xhtml.IsDocument(relPath) || isStylesheet(relPath)) {
+			if err := addRewrittenFileToZip(zipWriter, path, relPath, rewrite); err != nil {
+				return fmt.Errorf("failed to add file %s: %w", relPath, err)
+			}
+			fileCount++
+			return nil
+		}
+
 		if err := addFileToZip(zipWriter, path, relPath); err != nil {
 			return fmt.Errorf("failed to add file %s: %w", relPath, err)
 		}
 
 		fileCount++
-		if verbose {
-			fmt.Printf("  ✓ %s\n", relPath)
-		}
+		logging.Module("epub").Debug("Added file", "path", relPath)
 
 		return nil
 	})
@@ -187,45 +363,116 @@ func compressToEPUB(folderPath, outputPath string) error {
 		return fmt.Errorf("failed to compress folder: %w", err)
 	}
 
-	fmt.Printf("✅ Successfully compressed %d files to %s\n", fileCount, filepath.Base(outputPath))
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish EPUB: %w", err)
+	}
+	if err := outputFile.Commit(); err != nil {
+		return err
+	}
+
+	console.Printf("✅ Successfully compressed %d files to %s\n", fileCount, filepath.Base(outputPath))
+	if skipped > 0 {
+		console.Printf("🧹 Left out %d junk or excluded files (-vv lists them)\n", skipped)
+	}
 
 	// Provide helpful next steps
-	fmt.Printf("\nNext steps:\n")
-	fmt.Printf("  Test the EPUB file in your e-reader to ensure it works correctly\n")
+	console.Printf("\nNext steps:\n")
+	console.Printf("  Test the EPUB file in your e-reader to ensure it works correctly\n")
 
 	return nil
 }
 
-func addMimetypeFile(zipWriter *zip.Writer, mimetypePath string) error {
-	// Read mimetype content
+// addMimetypeFile writes the mimetype entry, with the right content whatever the folder's
+// file says (or if it's missing)
+func addMimetypeFile(zipWriter *epubzip.Writer, mimetypePath string) error {
 	content, err := os.ReadFile(mimetypePath)
-	if err != nil {
+	switch {
+	case os.IsNotExist(err):
+		console.Printf("🧩 Added the missing mimetype file\n")
+	case err != nil:
 		return fmt.Errorf("failed to read mimetype file: %w", err)
+	case strings.TrimSpace(string(content)) != epubzip.Mimetype:
+		console.Printf("⚠️  Replaced mimetype %q with %q\n", strings.TrimSpace(string(content)), epubzip.Mimetype)
 	}
 
-	// Create mimetype entry with no compression (required by EPUB spec)
-	header := &zip.FileHeader{
-		Name:   "mimetype",
-		Method: zip.Store, // No compression
+	// Stored with no compression (required by EPUB spec)
+	if err := zipWriter.WriteMimetype([]byte(epubzip.Mimetype)); err != nil {
+		return err
 	}
 
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return fmt.Errorf("failed to create mimetype entry: %w", err)
+	logging.Module("epub").Debug("Added file", "path", "mimetype", "compressed", false)
+
+	return nil
+}
+
+// rewriteFunc changes the content of an XHTML document or stylesheet on its way into the EPUB
+type rewriteFunc func(name string, content []byte) []byte
+
+// contentRewriter combines the rewrites the flags ask for, or returns nil if there are none
+func contentRewriter(profile *reader.Profile) rewriteFunc {
+	if !compressTidy && !compressUnits {
+		return nil
 	}
 
-	if _, err := writer.Write(content); err != nil {
-		return fmt.Errorf("failed to write mimetype content: %w", err)
+	return func(name string, content []byte) []byte {
+		isDocument := xhtml.IsDocument(name)
+
+		// Tidy first, so the unit pass sees well-formed style attributes
+		if compressTidy && isDocument {
+			content = tidyDocument(name, content)
+		}
+
+		if compressUnits {
+			optimizer := converter.NewEPUBOptimizer(*profile)
+			if isDocument {
+				content = []byte(optimizer.RelativeUnitsHTML(string(content)))
+			} else {
+				content = []byte(optimizer.RelativeUnits(string(content)))
+			}
+		}
+
+		return content
+	}
+}
+
+func isStylesheet(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".css")
+}
+
+// addRewrittenFileToZip adds an XHTML file or stylesheet after running it through rewrite
+func addRewrittenFileToZip(zipWriter *epubzip.Writer, filePath, zipPath string, rewrite rewriteFunc) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
 	}
 
-	if verbose {
-		fmt.Printf("  ✓ mimetype (uncompressed)\n")
+	content = rewrite(zipPath, content)
+
+	writer, err := zipWriter.Create(&zip.FileHeader{Name: zipPath, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return fmt.Errorf("failed to write file content: %w", err)
 	}
 
 	return nil
 }
 
-func addFileToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
+// tidyDocument runs the XHTML tidy pass on a document and reports what it did
+func tidyDocument(name string, content []byte) []byte {
+	tidied, fixes, err := xhtml.Tidy(content)
+	if err != nil {
+		console.Printf("⚠️  Couldn't tidy %s: %v (strict readers may refuse it)\n", name, err)
+		return content
+	}
+	if len(fixes) > 0 {
+		console.Printf("🧹 Tidied %s (%s)\n", name, strings.Join(fixes, ", "))
+	}
+	return tidied
+}
+
+func addFileToZip(zipWriter *epubzip.Writer, filePath, zipPath string) error {
 	// Open source file
 	sourceFile, err := os.Open(filePath)
 	if err != nil {
@@ -248,11 +495,8 @@ func addFileToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
 	// Set the zip path
 	header.Name = zipPath
 
-	// Use deflate compression for most files
-	header.Method = zip.Deflate
-
-	// Create writer for this file
-	writer, err := zipWriter.CreateHeader(header)
+	// Create writer for this file, deflated at the level asked for unless it's compressed already
+	writer, err := zipWriter.Create(header)
 	if err != nil {
 		return fmt.Errorf("failed to create zip entry: %w", err)
 	}
@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/alde/publify/internal/epubzip"
 	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/progress"
 	"github.com/alde/publify/pkg/reader"
+	"github.com/alde/publify/pkg/storage"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -19,9 +26,73 @@ var (
 	enableOCR   bool
 	ocrLanguage string
 	imagePages  string
+	pageRange   string
 	skipPages   string
+
+	noAutoOCR        bool
+	ocrPreprocess    string
+	minOCRConfidence int
+	ocrEngine        string
+	ocrCredentials   string
+	bleedThreshold   float64
+	noBleedDetection bool
+	noFigures        bool
+	noDescreen       bool
+	noStraighten     bool
+	keepBlankPages   bool
+	noSizeBudget     bool
+	textRender       bool
+	textLayer        bool
+	fixedLayout      bool
+	cropMargins      bool
+	splitSpreads     bool
+	sharpen          float64
+	dither           bool
+	gamma            float64
+	contrast         float64
+	whitePoint       int
+	imageFormat      string
+	fallbackFont     string
+	transliterate    bool
+	writingMode      string
+	rightToLeft      bool
+	direction        string
+
+	outputCompression string
+	forceOverwrite    bool
+	backupOutput      bool
+
+	coverImage string
+	coverPage  int
+
+	titlePage     bool
+	colophon      bool
+	bookPublisher string
+	bookOwner     string
+	ownerMark     bool
+	fetchMeta     bool
+	fromFilename  string
+	templateDir   string
+	customCSS     string
+	embedFonts    []string
+	obfuscateFont bool
+	dryRun        bool
+	maxSize       string
+	reviewPlan    bool
+	stableNames   bool
+	reproducible  bool
+	fastMode      bool
+	resumeRun     bool
+	memoryLimit   string
+	storageDir    string
+	altTextFile   string
+	convertPreset string
+	readingWPM    int
 )
 
+// storageEnv points checkpoints at a shared directory, as --storage does
+const storageEnv = "PUBLIFY_STORAGE"
+
 var convertCmd = &cobra.Command{
 	Use:   "convert [input file]",
 	Short: "Convert documents between formats",
@@ -30,10 +101,149 @@ var convertCmd = &cobra.Command{
 Currently supports:
 - PDF to EPUB conversion
 
+--fast is for converting a whole library in one go. It skips:
+- bleed-through detection: text showing through from the other side of the page is kept
+- layout analysis: centered dedications and epigraphs come out as ordinary paragraphs
+- the progress display and per-worker statistics (--progress json still gets its events)
+- the check that content documents fit the reader's size limits (run publify compress
+  --reader on the result if you need it)
+Text, images, figures, OCR and chapters are handled as usual.
+
+Pages are checkpointed next to the output as they finish. If a conversion is interrupted,
+run it again with --resume and the same settings to carry on where it stopped; the
+checkpoint is removed once the EPUB is written. --storage (or PUBLIFY_STORAGE) keeps
+checkpoints in one directory instead, such as a volume shared by the machines of a
+conversion service, so another one can resume the job.
+
+A PDF whose pages mostly have no text layer is taken to be scanned, and OCR is turned on
+for it without --ocr. If Tesseract isn't installed its pages are kept as pictures instead,
+with a warning, and --ocr without Tesseract leaves pages to their text layer;
+publify doctor says what's missing. Pages kept as images with --image-pages aren't
+counted, and --no-auto-ocr leaves OCR off regardless.
+
+With --ocr, page images are cleaned up before recognition: contrast stretched, turned the
+right way up, tilt straightened, reduced to black and white and despeckled.
+--ocr-preprocess picks the steps (contrast, rotate, deskew, binarize, despeckle) or turns
+them off with "none". Image pages scanned sideways, upside down or crooked are turned and
+levelled too, unless --no-straighten; the summary lists the pages that were.
+
+Image pages with nothing printed on them, and pages that are the same as an earlier one
+(a page scanned twice), are left out of the book and listed in the summary;
+--keep-blank-pages keeps them.
+
+Each reader profile aims for the EPUB to come to a share of the PDF's size. When it
+comes out bigger, its largest images are encoded again at lower quality, then smaller,
+until it fits or they're as low as they go (quality 40, half size); the summary says
+what they gave up. --no-size-budget keeps them as they were.
+
+--ocr-lang takes several languages joined with +, such as eng+fra for English with French
+quotations. When they're written in different scripts (eng+rus), pages that turn out to be
+in one of them are read again with only its languages.
+
+The summary gives OCR's mean confidence over the pages it read (--verbose lists every
+page). With --min-ocr-confidence, pages read with less confidence than that get a note in
+the book saying their text may be garbled, and are listed so they can be checked.
+
+Scans too poor for Tesseract can be read by a cloud service instead with --ocr-engine
+google (Cloud Vision), azure (AI Vision Read) or textract (AWS). Page images are sent to
+the service, which bills per page. The account goes in a JSON credentials file,
+~/.config/publify/ocr.json unless --ocr-credentials says otherwise; only the engine in use
+needs an entry:
+
+  {
+    "google":   {"api_key": "..."},
+    "azure":    {"endpoint": "https://<resource>.cognitiveservices.azure.com", "key": "..."},
+    "textract": {"region": "eu-west-1", "access_key_id": "...", "secret_access_key": "..."}
+  }
+
+Older readers draw characters their fonts lack as empty boxes. --fallback-font embeds a
+font (.ttf or .otf) for them, cut down to the characters the book needs; --transliterate
+spells them with ones the reader has instead (ł as l, Greek and Cyrillic in Latin letters,
+curly quotes as straight ones). Given both, the font is used where it has the character.
+The summary lists what was found and what became of it.
+
+Japanese set in vertical columns is kept in columns, read right to left, on readers that
+support it (kobo, kindle); --writing-mode horizontal or vertical decides it instead. Ruby
+in Aozora Bunko notation (漢字《かんじ》) becomes furigana, or the reading in brackets
+where the reader can't show it.
+
+Arabic and Hebrew books are set right to left, with pages turning the same way and
+passages in other languages marked to run their own way. --direction rtl (or --rtl) does
+the same for a book whose language isn't recognized, such as manga or a scan without a
+text layer; --direction ltr keeps a book left to right whatever its language. --preset
+manga sets a comic right to left with every page laid out as drawn.
+
+--css embeds a stylesheet of your own, linked from every chapter after publify's, to set
+margins, line height, justification and the like. It's optimized for the reader like the
+rest of the book: properties the reader can't handle are dropped and pixel sizes made
+relative. Fonts and images it refers to aren't embedded.
+
+--embed-font embeds a font (.ttf or .otf) for a typeface the book needs, such as one for
+Cyrillic or a dyslexia-friendly one, cut down to the characters the book uses. Give it
+once per file; the text is set in the first, and --css can use the others by family name.
+Some readers only show it with their font set to the publisher's (Kobo's "Publisher
+Default"). --obfuscate-fonts scrambles them as the IDPF specifies, which reading systems
+undo but keeps a licensed font from being copied straight out of the book.
+
+Books get the accessibility metadata EPUB checkers such as Ace look for, landmarks and
+language tags. Figures and image pages take their alt text from captions found in the
+PDF; --alt-text gives descriptions of your own, in a JSON file from their ids (see Stable
+IDs in the README) to the text: {"page0015-figure1": "Map of Ankh-Morpork", "page0012":
+"Portrait of the author"}. The summary counts the pictures left without one.
+
+Rights metadata in the PDF is carried into the EPUB. Books sold without DRM are often
+stamped "Purchased by ..." on every page instead; when the PDF is stamped or its rights say
+it's licensed, the summary says so, and cloud OCR warns before sending its pages off.
+--owner "Name" marks the EPUB as that person's copy, in its rights statement and metadata,
+and in the colophon with --colophon. Give an email address too ("Jane Doe <jane@example.com>")
+and a copy shared around the family can be traced back. --owner-mark also writes it faintly
+at the foot of every image page, where a scanned book has nowhere else to show it.
+
 Examples:
   publify convert input.pdf -o output.epub --reader kobo --color
   publify convert book.pdf -o book.epub --reader kobo --image-pages "1-2,419-420"
-  publify convert book.pdf -o book.epub --skip "8,10,12" --ocr`,
+  publify convert book.pdf -o book.epub --pages "10-250"
+  publify convert book.pdf --dry-run --ocr --skip "8,10"
+  publify convert book.pdf -o book.epub --review
+  publify convert book.pdf -o book.epub --skip "8,10,12" --ocr
+  publify convert book.pdf -o book.epub --ocr --bleed-threshold -4.5
+  publify convert scan.pdf -o scan.epub --ocr --min-ocr-confidence 60
+  publify convert book.pdf -o book.epub --ocr --no-bleed-detection
+  publify convert book.pdf -o book.epub --compression best
+  publify convert book.pdf -o book.epub --reader kindle --sharpen 1.2
+  publify convert comic.pdf -o comic.epub --reader kobo-bw --dither --gamma 1.3 --white-point 235
+  publify convert artbook.pdf -o artbook.epub --color --image-format jxl
+  publify convert scan.pdf -o scan.epub --image-pages "1-420" --text-render
+  publify convert scan.pdf -o scan.epub --image-pages "1-420" --ocr --text-layer
+  publify convert artbook.pdf -o artbook.epub --reader kobo --color --fixed-layout
+  publify convert method.pdf -o method.epub --image-pages "12-80" --fixed-layout
+  publify convert scan.pdf -o scan.epub --image-pages "1-300" --crop-margins --split-spreads
+  publify convert scan.pdf -o scan.epub --ocr --resume
+  publify convert scan.pdf -o scan.epub --ocr --ocr-preprocess "contrast,deskew"
+  publify convert scan.pdf -o scan.epub --ocr --ocr-lang eng+fra
+  publify convert scan.pdf -o scan.epub --ocr --ocr-engine google
+  publify convert scan.pdf -o scan.epub --preset scanned-novel
+  publify convert book.pdf -o book.epub --transliterate
+  publify convert book.pdf -o book.epub --fallback-font NotoSans-Regular.ttf
+  publify convert novel.pdf -o novel.epub --reader kobo --writing-mode vertical
+  publify convert scan.pdf -o scan.epub --image-pages "1-300" --rtl
+  publify convert manga.pdf -o manga.epub --reader kobo-bw --preset manga
+  publify convert arabic-grammar.pdf -o grammar.epub --direction ltr
+  publify convert book.pdf -o book.epub --cover auto --cover-page 2
+  publify convert book.pdf -o book.epub --title-page --colophon --publisher "Self-published"
+  publify convert book.pdf -o book.epub --fetch
+  publify convert book.pdf -o book.epub --owner "Jane Doe"
+  publify convert scan.pdf -o scan.epub --image-pages "1-300" --owner "Jane Doe <jane@example.com>" --colophon --owner-mark
+  publify convert "Terry Pratchett - Mort.pdf" -o mort.epub --from-filename "{author} - {title}"
+  publify convert book.pdf -o book.epub --force --backup
+  publify convert book.pdf -o book.epub --stable-names
+  SOURCE_DATE_EPOCH=1700000000 publify convert book.pdf -o book.epub --reproducible
+  publify convert book.pdf -o book.epub --fast
+  publify convert book.pdf -o book.epub --templates my-templates/
+  publify convert book.pdf -o book.epub --css my-style.css
+  publify convert book.pdf -o book.epub --embed-font OpenDyslexic-Regular.otf --embed-font OpenDyslexic-Bold.otf
+  publify convert book.pdf -o book.epub --embed-font Literata.ttf --obfuscate-fonts
+  publify convert atlas.pdf -o atlas.epub --alt-text descriptions.json`,
 	Args: cobra.ExactArgs(1),
 	RunE: runConvert,
 }
@@ -45,25 +255,127 @@ func init() {
 	convertCmd.Flags().StringVar(&readerType, "reader", "generic", "Target reader type (kobo, kindle, generic)")
 	convertCmd.Flags().BoolVar(&enableColor, "color", false, "Enable color processing for color e-readers")
 	convertCmd.Flags().IntVar(&workerCount, "workers", 0, "Number of worker goroutines (0 = auto)")
-	convertCmd.Flags().BoolVar(&enableOCR, "ocr", false, "Enable OCR for scanned PDFs (requires Tesseract)")
-	convertCmd.Flags().StringVar(&ocrLanguage, "ocr-lang", "eng", "OCR language (eng, sve, deu, etc.)")
+	convertCmd.Flags().BoolVar(&enableOCR, "ocr", false, "Enable OCR (requires Tesseract; turned on by itself for PDFs that look scanned)")
+	convertCmd.Flags().BoolVar(&noAutoOCR, "no-auto-ocr", false, "Don't turn on OCR for PDFs that look scanned (no text layer)")
+	convertCmd.Flags().StringVar(&ocrLanguage, "ocr-lang", "eng", "OCR languages, joined with + for books in several (eng, swe, eng+fra, etc.)")
+	convertCmd.Flags().StringVar(&ocrEngine, "ocr-engine", converter.OCREngineTesseract, "OCR engine: tesseract, or the cloud services google, azure or textract (see above)")
+	convertCmd.Flags().StringVar(&ocrCredentials, "ocr-credentials", "", "Credentials file for the cloud OCR engines (default ~/.config/publify/ocr.json)")
+	convertCmd.Flags().StringVar(&ocrPreprocess, "ocr-preprocess", "all", "Clean-up before OCR: contrast, rotate, deskew, binarize, despeckle, all or none")
+	convertCmd.Flags().IntVar(&minOCRConfidence, "min-ocr-confidence", 0, "Mark OCR'd pages read with less confidence than this, 0-100 (0 = none)")
 	convertCmd.Flags().StringVar(&imagePages, "image-pages", "", "Page ranges to treat as images (e.g., \"1-2,419-420\")")
+	convertCmd.Flags().StringVar(&pageRange, "pages", "", "Page ranges to convert, leaving out the rest (e.g., \"10-250\")")
 	convertCmd.Flags().StringVar(&skipPages, "skip", "", "Page numbers to skip entirely (e.g., \"8,10,12,418\")")
-
-	convertCmd.MarkFlagRequired("output")
+	convertCmd.Flags().Float64Var(&bleedThreshold, "bleed-threshold", converter.DefaultBleedThreshold, "Markov score below which page text is treated as bleed-through (lower = more permissive)")
+	convertCmd.Flags().BoolVar(&noBleedDetection, "no-bleed-detection", false, "Disable bleed-through detection and keep all extracted text")
+	convertCmd.Flags().BoolVar(&noFigures, "no-figures", false, "Don't extract images embedded in text pages")
+	convertCmd.Flags().BoolVar(&noDescreen, "no-descreen", false, "Don't remove halftone dot patterns from image pages")
+	convertCmd.Flags().BoolVar(&noStraighten, "no-straighten", false, "Keep image pages turned and tilted as they were scanned")
+	convertCmd.Flags().BoolVar(&keepBlankPages, "keep-blank-pages", false, "Keep blank image pages and pages that are the same as an earlier one")
+	convertCmd.Flags().BoolVar(&noSizeBudget, "no-size-budget", false, "Keep image quality even if the EPUB comes out over the reader's target size")
+	convertCmd.Flags().BoolVar(&textRender, "text-render", false, "Store image pages that are plain text as 1-bit black and white PNGs (smaller, sharper on e-ink)")
+	convertCmd.Flags().BoolVar(&fixedLayout, "fixed-layout", false, "Lay image pages out as printed, each a fixed page sized to the reader's screen (every page, without --image-pages)")
+	convertCmd.Flags().BoolVar(&cropMargins, "crop-margins", false, "Crop the blank margins around image pages, so what's printed fills more of the screen")
+	convertCmd.Flags().BoolVar(&splitSpreads, "split-spreads", false, "Split image pages of two pages side by side (scans of an open book, double-page art) into two pages")
+	convertCmd.Flags().BoolVar(&textLayer, "text-layer", false, "Put the text of image pages over them as an invisible layer, for search and dictionary lookup (best with --ocr)")
+	convertCmd.Flags().StringVar(&fallbackFont, "fallback-font", "", "Font to embed for characters the reader's fonts lack (only the glyphs used are kept)")
+	convertCmd.Flags().BoolVar(&transliterate, "transliterate", false, "Spell characters the reader's fonts lack with ones it has, e.g. ł as l")
+	convertCmd.Flags().StringVar(&writingMode, "writing-mode", "", "Set the text in horizontal lines or vertical columns (default: vertical for Japanese set in columns)")
+	convertCmd.Flags().StringVar(&direction, "direction", "", "Set the book rtl (right to left) or ltr whatever its language (default: from the language)")
+	convertCmd.Flags().BoolVar(&rightToLeft, "rtl", false, "Same as --direction rtl")
+	convertCmd.Flags().Float64Var(&sharpen, "sharpen", 0, "Sharpening strength for downscaled images (0 = off, default from reader profile)")
+	convertCmd.Flags().BoolVar(&dither, "dither", false, "Dither grayscale images down to the screen's shades of gray, instead of banding")
+	convertCmd.Flags().Float64Var(&gamma, "gamma", 0, "Gamma for grayscale images: above 1 lightens muddy midtones (0 = unchanged)")
+	convertCmd.Flags().Float64Var(&contrast, "contrast", 0, "Contrast for grayscale images, in percent from -100 to 100 (0 = unchanged)")
+	convertCmd.Flags().IntVar(&whitePoint, "white-point", 0, "Make grays this light (0-255) or lighter white, clearing paper tone in grayscale images (0 = off)")
+	convertCmd.Flags().StringVar(&imageFormat, "image-format", "", "Store images as jpeg, png, webp, avif or jxl (default: the best the reader takes)")
+	convertCmd.Flags().StringVar(&outputCompression, "compression", "", "Output compression level (store, fast, default, best); images are stored, text deflated")
+	convertCmd.Flags().BoolVar(&forceOverwrite, "force", false, "Overwrite the output file if it already exists")
+	convertCmd.Flags().BoolVar(&backupOutput, "backup", false, "Keep an overwritten output file as <output>.bak (implies --force)")
+	convertCmd.Flags().StringVar(&coverImage, "cover", "", "Cover image file, or \"auto\" to render one from the PDF")
+	convertCmd.Flags().IntVar(&coverPage, "cover-page", 0, "Page to render as the cover (implies --cover auto, default 1)")
+	convertCmd.Flags().BoolVar(&titlePage, "title-page", false, "Add a generated title page with title, author and publisher")
+	convertCmd.Flags().BoolVar(&colophon, "colophon", false, "Add a closing page noting the source file and conversion settings")
+	convertCmd.Flags().StringVar(&bookPublisher, "publisher", "", "Publisher name for the metadata and title page")
+	convertCmd.Flags().StringVar(&bookOwner, "owner", "", "Mark the book as this person's copy in its rights statement, metadata and colophon")
+	convertCmd.Flags().BoolVar(&ownerMark, "owner-mark", false, "Also write the --owner faintly at the foot of every image page")
+
+	convertCmd.Flags().StringVar(&fromFilename, "from-filename", "", "Take title, author and publisher from the input file name, e.g. \"{author} - {title}\"")
+	convertCmd.Flags().StringVar(&customCSS, "css", "", "Stylesheet to embed and link from every chapter, for margins, line height and justification")
+	convertCmd.Flags().StringArrayVar(&embedFonts, "embed-font", nil, "Font to embed and set the text in, cut down to the characters used (repeatable)")
+	convertCmd.Flags().BoolVar(&obfuscateFont, "obfuscate-fonts", false, "Scramble embedded fonts with IDPF font obfuscation")
+	convertCmd.Flags().StringVar(&altTextFile, "alt-text", "", "JSON file of text descriptions for figures and image pages, by id")
+	convertCmd.Flags().StringVar(&templateDir, "templates", "", "Directory with custom chapter/cover/title page/colophon templates (see publify templates)")
+	convertCmd.Flags().BoolVar(&fetchMeta, "fetch", false, "Look up metadata and cover online by ISBN or title (asks before applying)")
+
+	convertCmd.Flags().BoolVar(&reviewPlan, "review", false, "Review the proposed chapters before generating: merge, split, rename, drop pages")
+	convertCmd.Flags().BoolVar(&stableNames, "stable-names", false, "Derive the book identifier from the input file, so re-converting it gives the same one")
+	convertCmd.Flags().BoolVar(&reproducible, "reproducible", false, "Give byte-identical EPUBs for the same input and flags: stable names, timestamps at SOURCE_DATE_EPOCH (or 1980)")
+	convertCmd.Flags().BoolVar(&fastMode, "fast", false, "Favour speed over polish for bulk conversions (see above for what's skipped)")
+	convertCmd.Flags().BoolVar(&resumeRun, "resume", false, "Pick up an interrupted conversion to the same output, reusing the pages it finished")
+	convertCmd.Flags().StringVar(&memoryLimit, "memory-limit", "", "Keep page images in memory until memory use passes this, e.g. 2GB, then on disk (default: on disk from the start)")
+	convertCmd.Flags().StringVar(&storageDir, "storage", "", "Directory to keep checkpoints in instead of next to the output (default $"+storageEnv+")")
+	convertCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Process the PDF and show the chapter plan without writing an EPUB")
+	convertCmd.Flags().StringVar(&maxSize, "max-size", "", "Warn before converting if the EPUB looks set to be larger than this (e.g., \"20MB\"; default: the reader's limit)")
+	convertCmd.Flags().IntVar(&readingWPM, "wpm", metadata.DefaultWPM, "Reading speed the summary's reading time is estimated at, in words a minute")
+	convertCmd.Flags().StringVar(&convertPreset, "preset", "", "Installed preset or preset file to take settings from (see publify preset)")
 }
 
 func runConvert(cmd *cobra.Command, args []string) error {
 	inputPath := args[0]
 
+	// Flags given on the command line win over the preset's
+	if convertPreset != "" {
+		if err := applyPreset(cmd, convertPreset); err != nil {
+			return err
+		}
+	}
+
 	// Validate input file (because trusting user input is like trusting weather forecasts)
 	if err := validateInputFile(inputPath); err != nil {
 		return fmt.Errorf("input validation failed: %w", err)
 	}
 
-	// Validate output path (making sure we don't write to /dev/null by mistake)
-	if err := validateOutputPath(outputPath); err != nil {
-		return fmt.Errorf("output validation failed: %w", err)
+	if reviewPlan && dryRun {
+		return fmt.Errorf("--review can't be combined with --dry-run, the dry run already shows the chapters")
+	}
+	if reviewPlan && progress.Headless() {
+		return fmt.Errorf("--review needs a terminal to ask at, and publify is running headless (see publify --help)")
+	}
+	if resumeRun && dryRun {
+		return fmt.Errorf("--resume can't be combined with --dry-run, a dry run keeps no checkpoint")
+	}
+
+	// Validate output path (making sure we don't write to /dev/null by mistake).
+	// A dry run writes nothing, so it doesn't need one.
+	if !dryRun {
+		if outputPath == "" {
+			return fmt.Errorf("required flag \"output\" not set")
+		}
+		if err := validateOutputPath(outputPath); err != nil {
+			return fmt.Errorf("output validation failed: %w", err)
+		}
+		if err := checkOverwrite(outputPath, forceOverwrite || backupOutput); err != nil {
+			return err
+		}
+	}
+
+	var maxSizeBytes uint64
+	if maxSize != "" {
+		parsed, err := humanize.ParseBytes(maxSize)
+		if err != nil {
+			return fmt.Errorf("invalid --max-size %q: %w", maxSize, err)
+		}
+		maxSizeBytes = parsed
+	}
+
+	// Memory limit (humans say "2GB", not 2147483648)
+	var memoryLimitBytes uint64
+	if memoryLimit != "" {
+		parsed, err := humanize.ParseBytes(memoryLimit)
+		if err != nil || parsed == 0 {
+			return fmt.Errorf("invalid --memory-limit %q (e.g. 512MB or 2GB)", memoryLimit)
+		}
+		memoryLimitBytes = parsed
 	}
 
 	// Get reader profile (each device has its own quirks, like people from different regions)
@@ -77,9 +389,68 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		profile.Capabilities.SupportsColor = false
 	}
 
-	// Check OCR availability if requested (Tesseract needs to be installed properly, ja?)
-	if enableOCR && !converter.IsOCRAvailable() {
-		return fmt.Errorf("OCR requested but Tesseract not available. Please install Tesseract OCR")
+	// Sharpening override (only when given, zero is a valid way of turning it off)
+	if cmd.Flags().Changed("sharpen") {
+		if sharpen < 0 || sharpen > 3 {
+			return fmt.Errorf("invalid sharpen strength: %.2f (must be between 0 and 3)", sharpen)
+		}
+		profile.Capabilities.SharpenStrength = sharpen
+	}
+
+	// E-ink tone tuning overrides, likewise only when given
+	if cmd.Flags().Changed("dither") {
+		profile.Capabilities.Dither = dither
+	}
+	if cmd.Flags().Changed("gamma") {
+		if gamma < 0 || gamma > 5 {
+			return fmt.Errorf("invalid gamma: %.2f (must be between 0 and 5)", gamma)
+		}
+		profile.Capabilities.Gamma = gamma
+	}
+	if cmd.Flags().Changed("contrast") {
+		if contrast < -100 || contrast > 100 {
+			return fmt.Errorf("invalid contrast: %.0f (must be between -100 and 100)", contrast)
+		}
+		profile.Capabilities.Contrast = contrast
+	}
+	if cmd.Flags().Changed("white-point") {
+		if whitePoint < 0 || whitePoint > 255 {
+			return fmt.Errorf("invalid white point: %d (must be between 0 and 255)", whitePoint)
+		}
+		profile.Capabilities.WhitePoint = whitePoint
+	}
+
+	// AVIF and JPEG XL are half the size of JPEG, but few readers show them yet
+	if imageFormat != "" {
+		format := strings.ToLower(imageFormat)
+		if !slices.Contains(converter.ImageFormats, format) {
+			return fmt.Errorf("invalid image format %q (use %s)", imageFormat, strings.Join(converter.ImageFormats, ", "))
+		}
+		profile.Capabilities.PreferredImageFormat = format
+		profile.Capabilities.SupportedImageFormats = []string{format}
+	}
+
+	// Check the OCR engine asked for (whether Tesseract is installed is found out when it's needed, ja?)
+	// A cloud engine is also used without --ocr if the PDF turns out to be scanned
+	credentials, err := loadOCRCredentials(enableOCR || ocrEngine != converter.OCREngineTesseract, ocrEngine, ocrCredentials)
+	if err != nil {
+		return err
+	}
+
+	if _, err := converter.ParseOCRPreprocessing(ocrPreprocess); err != nil {
+		return err
+	}
+	if _, err := converter.ParseOCRLanguages(ocrLanguage); err != nil {
+		return err
+	}
+	if minOCRConfidence < 0 || minOCRConfidence > 100 {
+		return fmt.Errorf("--min-ocr-confidence must be between 0 and 100")
+	}
+	if readingWPM <= 0 {
+		return fmt.Errorf("--wpm must be a positive number of words a minute")
+	}
+	if ownerMark && bookOwner == "" {
+		return fmt.Errorf("--owner-mark needs --owner to say whose copy it is")
 	}
 
 	// Validate image pages format if provided
@@ -90,6 +461,14 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Validate page selection format if provided (checked against the page count once the PDF is open)
+	if pageRange != "" {
+		_, err := converter.ParsePageRanges(pageRange)
+		if err != nil {
+			return fmt.Errorf("invalid page selection format: %w", err)
+		}
+	}
+
 	// Validate skip pages format if provided
 	if skipPages != "" {
 		err := validateSkipPages(skipPages)
@@ -98,17 +477,172 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// A threshold of zero or above would reject every page, since Markov scores are log probabilities
+	if bleedThreshold >= 0 {
+		return fmt.Errorf("invalid bleed threshold: %.2f (must be negative, default %.1f)", bleedThreshold, converter.DefaultBleedThreshold)
+	}
+
+	if outputCompression != "" {
+		if err := epubzip.ValidateLevel(outputCompression); err != nil {
+			return err
+		}
+	}
+
+	// A cover page only makes sense when rendering the cover from the PDF
+	if coverPage != 0 {
+		if coverPage < 1 {
+			return fmt.Errorf("invalid cover page: %d (must be positive)", coverPage)
+		}
+		if coverImage == "" {
+			coverImage = converter.CoverAuto
+		}
+		if coverImage != converter.CoverAuto {
+			return fmt.Errorf("--cover-page can only be used with --cover auto")
+		}
+	}
+
+	if coverImage != "" && coverImage != converter.CoverAuto {
+		if _, err := os.Stat(coverImage); err != nil {
+			return fmt.Errorf("cover image not found: %s", coverImage)
+		}
+	}
+	if customCSS != "" {
+		if _, err := os.Stat(customCSS); err != nil {
+			return fmt.Errorf("stylesheet not found: %s", customCSS)
+		}
+	}
+
+	if fallbackFont != "" {
+		ext := strings.ToLower(filepath.Ext(fallbackFont))
+		if ext != ".ttf" && ext != ".otf" {
+			return fmt.Errorf("unsupported fallback font format: %s (only .ttf and .otf are supported)", ext)
+		}
+		if _, err := os.Stat(fallbackFont); err != nil {
+			return fmt.Errorf("fallback font not found: %s", fallbackFont)
+		}
+	}
+
+	for _, font := range embedFonts {
+		ext := strings.ToLower(filepath.Ext(font))
+		if ext != ".ttf" && ext != ".otf" {
+			return fmt.Errorf("unsupported font format: %s (only .ttf and .otf are supported)", ext)
+		}
+		if _, err := os.Stat(font); err != nil {
+			return fmt.Errorf("font not found: %s", font)
+		}
+	}
+	if obfuscateFont && len(embedFonts) == 0 {
+		return fmt.Errorf("--obfuscate-fonts needs fonts to embed (--embed-font)")
+	}
+
+	if err := converter.ValidateWritingMode(writingMode); err != nil {
+		return err
+	}
+	if rightToLeft {
+		if direction == converter.DirectionLTR {
+			return fmt.Errorf("--rtl can't be combined with --direction ltr")
+		}
+		direction = converter.DirectionRTL
+	}
+	if err := converter.ValidateDirection(direction); err != nil {
+		return err
+	}
+
+	templates, err := converter.LoadTemplates(templateDir)
+	if err != nil {
+		return err
+	}
+
 	// Set up converter options
 	opts := converter.Options{
-		InputPath:      inputPath,
-		OutputPath:     outputPath,
-		Profile:        profile,
-		WorkerCount:    workerCount,
-		Verbose:        verbose,
-		EnableOCR:      enableOCR,
-		OCRLanguage:    ocrLanguage,
-		ImagePageRange: imagePages,
-		SkipPages:      skipPages,
+		InputPath:             inputPath,
+		OutputPath:            outputPath,
+		Profile:               profile,
+		WorkerCount:           workerCount,
+		Verbose:               verbosity > 0,
+		EnableOCR:             enableOCR,
+		NoAutoOCR:             noAutoOCR,
+		OCRLanguage:           ocrLanguage,
+		OCREngine:             ocrEngine,
+		OCRCredentials:        credentials,
+		OCRPreprocess:         ocrPreprocess,
+		MinOCRConfidence:      minOCRConfidence,
+		ImagePageRange:        imagePages,
+		PageRange:             pageRange,
+		SkipPages:             skipPages,
+		BleedThreshold:        bleedThreshold,
+		DisableBleedDetection: noBleedDetection,
+		SkipFigures:           noFigures,
+		SkipDescreen:          noDescreen,
+		SkipStraighten:        noStraighten,
+		KeepBlankPages:        keepBlankPages,
+		SkipSizeBudget:        noSizeBudget,
+		TextRender:            textRender,
+		TextLayer:             textLayer,
+		FixedLayout:           fixedLayout,
+		CropMargins:           cropMargins,
+		SplitSpreads:          splitSpreads,
+		FallbackFont:          fallbackFont,
+		Transliterate:         transliterate,
+		WritingMode:           writingMode,
+		Direction:             direction,
+		Compression:           outputCompression,
+		Backup:                backupOutput,
+		DryRun:                dryRun,
+		MaxSize:               int64(maxSizeBytes),
+		StableNames:           stableNames,
+		Reproducible:          reproducible,
+		Fast:                  fastMode,
+		Resume:                resumeRun,
+		MemoryLimit:           memoryLimitBytes,
+		ReadingWPM:            readingWPM,
+		Cover:                 coverImage,
+		CoverPage:             coverPage,
+		TitlePage:             titlePage,
+		Colophon:              colophon,
+		Publisher:             bookPublisher,
+		Owner:                 bookOwner,
+		OwnerMark:             ownerMark,
+		ToolVersion:           rootCmd.Version,
+		Templates:             templates,
+		CSS:                   customCSS,
+		EmbedFonts:            embedFonts,
+		ObfuscateFonts:        obfuscateFont,
+	}
+
+	// A shared directory keeps checkpoints where any machine converting to the same
+	// output finds them
+	if storageDir == "" {
+		storageDir = os.Getenv(storageEnv)
+	}
+	if storageDir != "" {
+		opts.Storage = storage.NewDir(storageDir)
+	}
+
+	if altTextFile != "" {
+		if opts.DescribeImage, err = loadAltText(altTextFile); err != nil {
+			return err
+		}
+	}
+
+	if fromFilename != "" {
+		if err := applyFilenameMetadata(&opts, inputPath, fromFilename); err != nil {
+			return err
+		}
+	}
+
+	// Fetched covers need somewhere to live until the EPUB is written
+	if fetchMeta && !dryRun {
+		fetchDir, err := os.MkdirTemp("", "publify-fetch-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(fetchDir)
+		opts.FetchMetadata = convertMetadataLookup(fetchDir)
+	}
+
+	if reviewPlan {
+		opts.ReviewChapters = reviewChapters
 	}
 
 	// Run conversion
@@ -116,6 +650,63 @@ func runConvert(cmd *cobra.Command, args []string) error {
 	return conv.Convert()
 }
 
+// loadOCRCredentials checks that the OCR engine asked for can be used, and reads the
+// credentials file if it's a cloud service
+func loadOCRCredentials(enabled bool, engine, path string) (*converter.OCRCredentials, error) {
+	if err := converter.ValidateOCREngine(engine); err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+	// Without Tesseract the conversion goes on without OCR, with a warning
+	if engine == converter.OCREngineTesseract {
+		return nil, nil
+	}
+	return converter.LoadOCRCredentials(path)
+}
+
+// applyFilenameMetadata sets the title, author and publisher parsed from the input file name.
+// An explicit --publisher wins.
+func applyFilenameMetadata(opts *converter.Options, inputPath, template string) error {
+	parsed, err := metadata.ParseFilename(inputPath, template)
+	if err != nil {
+		return err
+	}
+	if parsed.Series != "" || parsed.SeriesIndex > 0 || parsed.Language != "" {
+		return fmt.Errorf("convert only takes {title}, {author} and {publisher} from the file name; set the rest with publify metadata")
+	}
+
+	opts.Title = parsed.Title
+	var authors []string
+	for _, author := range parsed.Authors {
+		authors = append(authors, author.Name)
+	}
+	opts.Author = strings.Join(authors, ", ")
+	if opts.Publisher == "" {
+		opts.Publisher = parsed.Publisher
+	}
+
+	return nil
+}
+
+// loadAltText reads descriptions of the book's pictures, a JSON object from their ids
+// (page0015-figure1, page0012) to the text
+func loadAltText(path string) (func(converter.ImageToDescribe) (string, error), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alt text: %w", err)
+	}
+	var descriptions map[string]string
+	if err := json.Unmarshal(data, &descriptions); err != nil {
+		return nil, fmt.Errorf("failed to parse alt text %s (expected {\"page0015-figure1\": \"...\"}): %w", path, err)
+	}
+
+	return func(img converter.ImageToDescribe) (string, error) {
+		return descriptions[img.ID], nil
+	}, nil
+}
+
 func validateInputFile(path string) error {
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -181,8 +772,22 @@ func validateOutputPath(path string) error {
 	return nil
 }
 
-var verbose bool
+// checkOverwrite refuses to replace an existing file unless asked to, since a book that
+// took twenty minutes to convert deserves better than a stray up-arrow
+func checkOverwrite(path string, force bool) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check output file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("output path is a directory: %s", path)
+	}
+	if !force {
+		return fmt.Errorf("output file already exists: %s (use --force to overwrite, --backup to keep a copy)", path)
+	}
 
-func init() {
-	convertCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	return nil
 }
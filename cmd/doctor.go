@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/selfupdate"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/spf13/cobra"
+)
+
+// doctorTimeout bounds each network check, so an offline machine isn't kept waiting
+const doctorTimeout = 5 * time.Second
+
+var (
+	doctorJSON           bool
+	doctorOCRCredentials string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check which optional dependencies publify can use",
+	Long: `Check what publify can use on this machine beyond itself, and say what it does
+without each: Tesseract for OCR, cloud OCR credentials, the network, and somewhere to
+write temporary files and checkpoints.
+
+None of them are needed to convert a PDF with a text layer. Without one, the features that
+need it are left out with a warning rather than failing the command: a scanned PDF without
+Tesseract comes out as pages kept as pictures, and a metadata lookup that can't reach the
+catalogues leaves the PDF's own metadata.
+
+--json writes the report as JSON, for installers and services to check before they rely
+on a feature. Each capability has a name, whether it's available, a detail (a version, a
+path or what's wrong), the features that need it, and what publify does without it.
+
+Examples:
+  publify doctor
+  publify doctor --json | jq '.capabilities[] | select(.available | not)'`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Write the report as JSON")
+	doctorCmd.Flags().StringVar(&doctorOCRCredentials, "ocr-credentials", "", "Cloud OCR credentials file to check (default ~/.config/publify/ocr.json)")
+}
+
+// capability is something optional publify can use, and what goes without it
+type capability struct {
+	Name      string   `json:"name"`
+	Available bool     `json:"available"`
+	Detail    string   `json:"detail"`
+	Features  []string `json:"features"`          // What needs it
+	Without   string   `json:"without,omitempty"` // What publify does when it's missing
+}
+
+type doctorReport struct {
+	Version      string       `json:"version"`
+	Capabilities []capability `json:"capabilities"`
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	report := doctorReport{
+		Version: rootCmd.Version,
+		Capabilities: []capability{
+			checkTesseract(),
+			checkCloudOCR(doctorOCRCredentials),
+			checkNetwork(),
+			checkTempDir(),
+			checkStorage(),
+		},
+	}
+
+	if doctorJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	for _, c := range report.Capabilities {
+		mark := "✅"
+		if !c.Available {
+			mark = "⚠️ "
+		}
+		console.Displayf("%s %-10s %s\n", mark, c.Name, c.Detail)
+		console.Displayf("   %-10s for %s\n", "", strings.Join(c.Features, ", "))
+		if !c.Available && c.Without != "" {
+			console.Displayf("   %-10s without it: %s\n", "", c.Without)
+		}
+	}
+	return nil
+}
+
+func checkTesseract() capability {
+	c := capability{
+		Name:     "tesseract",
+		Features: []string{"convert --ocr", "OCR of scanned PDFs", "fingerprint of scanned PDFs", "eval"},
+		Without: "scanned PDFs are converted with their pages kept as pictures, and --ocr is skipped, with a warning; " +
+			"--ocr-engine reads scans with a cloud service instead",
+	}
+
+	status, err := converter.Tesseract()
+	if err != nil {
+		c.Detail = "not installed (apt install tesseract-ocr, brew install tesseract)"
+		return c
+	}
+
+	where := status.Path
+	if status.Linked {
+		where = "linked in"
+	}
+	c.Detail = strings.TrimSpace(status.Version + " " + where)
+	switch {
+	case status.Languages == nil:
+		c.Available = true
+		c.Detail += ", languages couldn't be listed"
+	case len(status.Languages) == 0:
+		c.Detail += ", but no language data is installed (apt install tesseract-ocr-eng)"
+	default:
+		c.Available = true
+		c.Detail += ", languages " + strings.Join(status.Languages, " ")
+	}
+	return c
+}
+
+func checkCloudOCR(path string) capability {
+	c := capability{
+		Name:     "cloud-ocr",
+		Features: []string{"convert --ocr-engine google, azure or textract"},
+		Without:  "scans are read with Tesseract only",
+	}
+
+	credentials, err := converter.LoadOCRCredentials(path)
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	var engines []string
+	for _, engine := range converter.OCREngines {
+		if engine == converter.OCREngineTesseract {
+			continue
+		}
+		if _, err := converter.NewCloudOCREngine(engine, credentials); err == nil {
+			engines = append(engines, engine)
+		}
+	}
+	if len(engines) == 0 {
+		c.Detail = "the credentials file has no complete account for any engine"
+		return c
+	}
+	c.Available = true
+	c.Detail = "accounts for " + strings.Join(engines, ", ")
+	return c
+}
+
+// checkNetwork tries the services publify talks to: the catalogues metadata is looked up
+// in, and the release feed
+func checkNetwork() capability {
+	c := capability{
+		Name:     "network",
+		Features: []string{"convert --fetch", "metadata --fetch", "self-update", "cloud OCR"},
+		Without:  "convert --fetch keeps the PDF's own metadata, with a warning; metadata --fetch and self-update fail",
+	}
+
+	services := []struct{ name, url string }{
+		{"Open Library", "https://openlibrary.org"},
+		{"Google Books", "https://www.googleapis.com/books/v1/volumes?q=isbn:0"},
+		{"releases", selfupdate.FeedURL},
+	}
+	failures := make([]error, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			failures[i] = reachable(service.url)
+		}()
+	}
+	wg.Wait()
+
+	var reached, failed []string
+	for i, service := range services {
+		if failures[i] != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", service.name, failures[i]))
+		} else {
+			reached = append(reached, service.name)
+		}
+	}
+	c.Available = len(reached) > 0
+	switch {
+	case len(failed) == 0:
+		c.Detail = "reached " + strings.Join(reached, ", ")
+	case len(reached) == 0:
+		c.Detail = "can't reach " + strings.Join(failed, ", ")
+	default:
+		c.Detail = "reached " + strings.Join(reached, ", ") + "; can't reach " + strings.Join(failed, ", ")
+	}
+	return c
+}
+
+// reachable makes a request to a service; any answer at all means it can be reached
+func reachable(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out")
+		}
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func checkTempDir() capability {
+	dir := os.TempDir()
+	c := capability{
+		Name:     "temp-dir",
+		Detail:   dir,
+		Features: []string{"convert", "fingerprint", "metadata --fetch"},
+		Without:  "those commands fail; set " + tempDirEnv + " to a writable directory",
+	}
+	if c.Available = writableDir(dir); !c.Available {
+		c.Detail += " isn't writable"
+	}
+	return c
+}
+
+func checkStorage() capability {
+	c := capability{
+		Name:     "storage",
+		Features: []string{"convert --resume"},
+		Without:  "conversions go on without checkpoints, with a warning, and --resume fails",
+	}
+
+	dir := os.Getenv(storageEnv)
+	if dir == "" {
+		c.Available = true
+		c.Detail = "checkpoints are kept next to each output (" + storageEnv + " isn't set)"
+		return c
+	}
+	c.Detail = dir
+	if err := os.MkdirAll(dir, 0755); err != nil || !writableDir(dir) {
+		c.Detail += " isn't writable"
+		return c
+	}
+	c.Available = true
+	return c
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/eval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalOCR              bool
+	evalOCRLanguage      string
+	evalOCRPreprocess    string
+	evalOCREngine        string
+	evalOCRCredentials   string
+	evalBleedThreshold   float64
+	evalNoBleedDetection bool
+	evalFast             bool
+	evalWorkers          int
+	evalSave             string
+	evalBaseline         string
+	evalVerbose          bool
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [corpus directory]",
+	Short: "Score conversions against golden text",
+	Long: `Convert a corpus of PDFs and score the text against golden text, to see whether a
+new version or a different setting does better or worse on your kind of books.
+
+A corpus is a directory of PDFs with their golden text next to them under the same
+name (book.pdf and book.txt), pages separated by form feeds. pdftotext writes exactly
+that, so a good start is its output, corrected by hand. PDFs without golden text are
+left out. The default corpus is testdata/, which has a public domain fixture.
+
+Scores are word and character error rates (WER and CER): edits needed to get from the
+converted text to the golden text, per golden word or character. 0% is a perfect match.
+White space doesn't count.
+
+Save a run with --save and compare later runs against it with --baseline.
+
+Examples:
+  publify eval
+  publify eval ~/corpus --ocr --save before.json
+  publify eval ~/corpus --ocr --ocr-lang swe --baseline before.json
+  publify eval ~/corpus --ocr --ocr-preprocess none --baseline before.json
+  publify eval ~/corpus --ocr --ocr-engine azure --baseline before.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEval,
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+
+	evalCmd.Flags().BoolVar(&evalOCR, "ocr", false, "Enable OCR for scanned pages (requires Tesseract)")
+	evalCmd.Flags().StringVar(&evalOCRLanguage, "ocr-lang", "eng", "OCR languages, joined with + for books in several (eng, swe, eng+fra, etc.)")
+	evalCmd.Flags().StringVar(&evalOCREngine, "ocr-engine", converter.OCREngineTesseract, "OCR engine: tesseract, google, azure or textract (see publify convert --help)")
+	evalCmd.Flags().StringVar(&evalOCRCredentials, "ocr-credentials", "", "Credentials file for the cloud OCR engines (default ~/.config/publify/ocr.json)")
+	evalCmd.Flags().StringVar(&evalOCRPreprocess, "ocr-preprocess", "all", "Clean-up before OCR: contrast, rotate, deskew, binarize, despeckle, all or none")
+	evalCmd.Flags().Float64Var(&evalBleedThreshold, "bleed-threshold", converter.DefaultBleedThreshold, "Markov score below which page text is treated as bleed-through")
+	evalCmd.Flags().BoolVar(&evalNoBleedDetection, "no-bleed-detection", false, "Disable bleed-through detection and keep all extracted text")
+	evalCmd.Flags().BoolVar(&evalFast, "fast", false, "Score the text --fast conversions get")
+	evalCmd.Flags().IntVar(&evalWorkers, "workers", 0, "Number of worker goroutines (0 = auto)")
+	evalCmd.Flags().StringVar(&evalSave, "save", "", "Save the scores as JSON, to compare later runs against")
+	evalCmd.Flags().StringVar(&evalBaseline, "baseline", "", "Compare with scores saved earlier with --save")
+	evalCmd.Flags().BoolVarP(&evalVerbose, "verbose", "v", false, "Show each book's worst pages")
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	corpus := "testdata"
+	if len(args) > 0 {
+		corpus = args[0]
+	}
+
+	fixtures, err := eval.FindFixtures(corpus)
+	if err != nil {
+		return err
+	}
+	if len(fixtures) == 0 {
+		return fmt.Errorf("no PDFs with golden text in %s (book.pdf needs a book.txt next to it)", corpus)
+	}
+
+	var baseline *eval.Report
+	if evalBaseline != "" {
+		report, err := eval.LoadReport(evalBaseline)
+		if err != nil {
+			return err
+		}
+		baseline = &report
+	}
+
+	credentials, err := loadOCRCredentials(evalOCR, evalOCREngine, evalOCRCredentials)
+	if err != nil {
+		return err
+	}
+
+	opts := converter.PDFProcessorOptions{
+		EnableOCR:             evalOCR,
+		OCRLanguage:           evalOCRLanguage,
+		OCREngine:             evalOCREngine,
+		OCRCredentials:        credentials,
+		OCRPreprocess:         evalOCRPreprocess,
+		BleedThreshold:        evalBleedThreshold,
+		DisableBleedDetection: evalNoBleedDetection || evalFast,
+		SkipFigures:           true, // Figures don't change the text
+		SkipLayout:            evalFast,
+		Workers:               evalWorkers,
+	}
+
+	report := eval.Report{
+		Version:  rootCmd.Version,
+		Settings: evalSettings(cmd),
+		Created:  time.Now(),
+	}
+
+	console.Displayf("📏 Scoring %s from %s\n", countOf(len(fixtures), "book"), corpus)
+	console.Displayf("   %-36s %7s %7s %8s\n", "Book", "WER", "CER", "Time")
+	for _, fixture := range fixtures {
+		result, err := eval.Run(fixture, opts)
+		if err != nil {
+			return err
+		}
+		report.Results = append(report.Results, result)
+
+		console.Displayf("   %-36s %7s %7s %8s", truncateText(result.Name, 36),
+			percentage(result.Score.WER()), percentage(result.Score.CER()), result.Duration.Round(100*time.Millisecond))
+		if baseline != nil {
+			if before, ok := baseline.Find(result.Name); ok {
+				console.Displayf("  %s", change(before.Score, result.Score))
+			}
+		}
+		console.Displayf("\n")
+
+		if evalVerbose {
+			for _, page := range result.WorstPages(3) {
+				console.Displayf("      page %-4d %7s %7s  (%d of %d words wrong)\n", page.Page,
+					percentage(page.Score.WER()), percentage(page.Score.CER()), page.Score.WordErrors, page.Score.Words)
+			}
+		}
+	}
+
+	total := report.Total()
+	console.Displayf("   %-36s %7s %7s", "Total", percentage(total.WER()), percentage(total.CER()))
+	if baseline != nil {
+		console.Displayf("  %s", change(baseline.Total(), total))
+	}
+	console.Displayf("\n")
+
+	if evalSave != "" {
+		if err := report.Save(evalSave); err != nil {
+			return err
+		}
+		console.Printf("💾 Saved scores to %s\n", evalSave)
+	}
+
+	return nil
+}
+
+// evalSettings lists the flags a run was made with, so saved reports say what they measured
+func evalSettings(cmd *cobra.Command) []string {
+	var settings []string
+	for _, name := range []string{"ocr", "ocr-lang", "ocr-engine", "ocr-preprocess", "bleed-threshold", "no-bleed-detection", "fast"} {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			settings = append(settings, fmt.Sprintf("--%s=%s", name, flag.Value))
+		}
+	}
+	return settings
+}
+
+func percentage(rate float64) string {
+	return fmt.Sprintf("%.2f%%", rate*100)
+}
+
+// change describes how the word error rate moved since the baseline
+func change(before, after eval.Score) string {
+	delta := (after.WER() - before.WER()) * 100
+	switch {
+	case delta < -0.005:
+		return fmt.Sprintf("✅ %.2f points better", -delta)
+	case delta > 0.005:
+		return fmt.Sprintf("❌ %.2f points worse", delta)
+	}
+	return "unchanged"
+}
@@ -7,12 +7,19 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/epubzip"
+	"github.com/alde/publify/internal/logging"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
 var (
 	extractOutputDir  string
 	preserveStructure bool
+	extractInclude    []string
+	extractExclude    []string
+	extractList       bool
 )
 
 var extractCmd = &cobra.Command{
@@ -23,10 +30,19 @@ var extractCmd = &cobra.Command{
 The extracted folder will contain all EPUB files in their original structure,
 including META-INF/, OEBPS/, and all content files.
 
+--include and --exclude take glob patterns, so one chapter can be pulled out for
+editing without the rest of the book. A pattern with a slash matches the path in the
+archive ("OEBPS/*.xhtml"), one without matches the file name in any folder ("*.css").
+Both can be given more than once. --list prints what would be extracted and writes
+nothing, so it doesn't need --output.
+
 Examples:
   publify extract book.epub -o extracted/
   publify extract book.epub --output book_extracted/
-  publify extract book.epub --preserve-structure`,
+  publify extract book.epub --preserve-structure
+  publify extract book.epub -o chapter/ --include "OEBPS/chapter3.xhtml"
+  publify extract book.epub -o text/ --include "*.xhtml" --exclude "nav.xhtml"
+  publify extract book.epub --list --include "*.css"`,
 	Args: cobra.ExactArgs(1),
 	RunE: runExtract,
 }
@@ -34,10 +50,11 @@ Examples:
 func init() {
 	rootCmd.AddCommand(extractCmd)
 
-	extractCmd.Flags().StringVarP(&extractOutputDir, "output", "o", "", "Output directory for extracted files (required)")
+	extractCmd.Flags().StringVarP(&extractOutputDir, "output", "o", "", "Output directory for extracted files (required unless --list)")
 	extractCmd.Flags().BoolVar(&preserveStructure, "preserve-structure", true, "Preserve original EPUB directory structure")
-
-	extractCmd.MarkFlagRequired("output")
+	extractCmd.Flags().StringArrayVar(&extractInclude, "include", nil, "Only extract entries matching this glob (repeatable)")
+	extractCmd.Flags().StringArrayVar(&extractExclude, "exclude", nil, "Leave out entries matching this glob (repeatable)")
+	extractCmd.Flags().BoolVar(&extractList, "list", false, "List the entries that would be extracted, without extracting them")
 }
 
 func runExtract(cmd *cobra.Command, args []string) error {
@@ -48,13 +65,25 @@ func runExtract(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("EPUB validation failed: %w", err)
 	}
 
+	filter, err := epubzip.NewFilter(extractInclude, extractExclude)
+	if err != nil {
+		return err
+	}
+
+	if extractList {
+		return listEPUB(epubPath, filter)
+	}
+
 	// Validate output directory
+	if extractOutputDir == "" {
+		return fmt.Errorf("an output directory is required (--output), unless listing with --list")
+	}
 	if err := validateExtractOutputDir(extractOutputDir); err != nil {
 		return fmt.Errorf("output directory validation failed: %w", err)
 	}
 
 	// Extract EPUB
-	return extractEPUB(epubPath, extractOutputDir)
+	return extractEPUB(epubPath, extractOutputDir, filter)
 }
 
 func validateExtractOutputDir(outputDir string) error {
@@ -72,7 +101,34 @@ func validateExtractOutputDir(outputDir string) error {
 	return nil
 }
 
-func extractEPUB(epubPath, outputDir string) error {
+// listEPUB prints the entries the filter keeps, the way publify ls does
+func listEPUB(epubPath string, filter epubzip.Filter) error {
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %w", err)
+	}
+	defer zipReader.Close()
+
+	var total, compressed uint64
+	count := 0
+	console.Displayf("%10s  %10s  %-7s  %s\n", "Size", "Packed", "Method", "Name")
+	for _, file := range zipReader.File {
+		if !filter.Match(file.Name) {
+			continue
+		}
+		console.Displayf("%10s  %10s  %-7s  %s\n",
+			humanize.Bytes(file.UncompressedSize64), humanize.Bytes(file.CompressedSize64),
+			methodName(file.Method), file.Name)
+		total += file.UncompressedSize64
+		compressed += file.CompressedSize64
+		count++
+	}
+	console.Displayf("%10s  %10s  %-7s  %d files\n", humanize.Bytes(total), humanize.Bytes(compressed), "", count)
+
+	return nil
+}
+
+func extractEPUB(epubPath, outputDir string, filter epubzip.Filter) error {
 	// Open EPUB file (which is a ZIP archive)
 	zipReader, err := zip.OpenReader(epubPath)
 	if err != nil {
@@ -85,41 +141,54 @@ func extractEPUB(epubPath, outputDir string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	if verbose {
-		fmt.Printf("Extracting EPUB to: %s\n", outputDir)
-	}
+	logging.Module("epub").Debug("Extracting EPUB", "output", outputDir)
 
-	// Extract all files
+	// Extract all files the filter keeps
 	fileCount := 0
 	for _, file := range zipReader.File {
+		if !filter.Match(file.Name) {
+			continue
+		}
+		if epubzip.IsSymlink(file) {
+			logging.Module("epub").Warn("Skipped symbolic link", "path", file.Name)
+			continue
+		}
 		if err := extractFile(file, outputDir); err != nil {
 			return fmt.Errorf("failed to extract file %s: %w", file.Name, err)
 		}
 		fileCount++
-
-		if verbose {
-			fmt.Printf("  ✓ %s\n", file.Name)
-		}
+		logging.Module("epub").Debug("Extracted file", "path", file.Name)
 	}
 
-	fmt.Printf("✅ Successfully extracted %d files from %s to %s\n",
+	console.Printf("✅ Successfully extracted %d files from %s to %s\n",
 		fileCount, filepath.Base(epubPath), outputDir)
+	if fileCount == 0 && !filter.Empty() {
+		console.Printf("⚠️  Nothing matched --include/--exclude; see what's there with --list\n")
+		return nil
+	}
+	if !filter.Empty() {
+		// Part of a book doesn't compress back into one
+		return nil
+	}
 
 	// Provide helpful next steps
-	fmt.Printf("\nNext steps:\n")
-	fmt.Printf("  1. Edit files in %s as needed\n", outputDir)
-	fmt.Printf("  2. Use 'publify compress %s -o new_book.epub' to create a new EPUB\n", outputDir)
+	console.Printf("\nNext steps:\n")
+	console.Printf("  1. Edit files in %s as needed\n", outputDir)
+	console.Printf("  2. Use 'publify compress %s -o new_book.epub' to create a new EPUB\n", outputDir)
 
 	return nil
 }
 
 func extractFile(file *zip.File, destDir string) error {
-	// Create the full destination path
-	destPath := filepath.Join(destDir, file.Name)
+	// Create the full destination path, refusing names that climb out of destDir
+	destPath, err := epubzip.EntryPath(destDir, file.Name)
+	if err != nil {
+		return err
+	}
 
 	// Create directory if this is a directory entry
 	if file.FileInfo().IsDir() {
-		return os.MkdirAll(destPath, file.FileInfo().Mode())
+		return os.MkdirAll(destPath, 0755)
 	}
 
 	// Create parent directories if they don't exist
@@ -146,12 +215,11 @@ func extractFile(file *zip.File, destDir string) error {
 		return fmt.Errorf("failed to copy file content: %w", err)
 	}
 
-	// Set file permissions to match original (because permissions matter, even in Sweden)
-	if err := destFile.Chmod(file.FileInfo().Mode()); err != nil {
+	// Set file permissions to match original (because permissions matter, even in Sweden),
+	// leaving out setuid and the like
+	if err := destFile.Chmod(epubzip.EntryPerm(file)); err != nil {
 		// Non-fatal error - just warn
-		if verbose {
-			fmt.Printf("Warning: failed to set permissions for %s: %v\n", destPath, err)
-		}
+		logging.Module("epub").Warn("Failed to set permissions", "path", destPath, "err", err)
 	}
 
 	return nil
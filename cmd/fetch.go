@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/progress"
+)
+
+// fetchTimeout bounds the whole lookup, both catalogues included
+const fetchTimeout = 30 * time.Second
+
+// maxFetchCandidates is how many title search results the user gets to choose from
+const maxFetchCandidates = 5
+
+// fetchBookInfo looks a book up online, by ISBN when there is one and by title otherwise,
+// and asks the user to confirm the match. Returns nil if nothing was chosen. Headless,
+// there's nobody to ask: an ISBN match is used as it is, and title matches are skipped.
+func fetchBookInfo(isbn, title, author string) (*metadata.BookInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	lookup := metadata.NewLookup()
+	input := bufio.NewReader(os.Stdin)
+	headless := progress.Headless()
+
+	if isbn != "" {
+		console.Printf("🔎 Looking up ISBN %s...\n", isbn)
+		info, err := lookup.ByISBN(ctx, isbn)
+		if err == nil {
+			printBookInfo(*info)
+			if headless {
+				console.Println("Using it (headless, not asking)")
+				return info, nil
+			}
+			if confirm(input, "Use this metadata? [y/N] ") {
+				return info, nil
+			}
+			return nil, nil
+		}
+		if title == "" {
+			return nil, err
+		}
+		console.Printf("⚠️  ISBN lookup failed (%v), searching by title instead\n", err)
+	}
+
+	if title == "" {
+		return nil, fmt.Errorf("no ISBN or title to search for")
+	}
+
+	console.Printf("🔎 Searching for \"%s\"...\n", title)
+	candidates, err := lookup.Search(ctx, title, author, maxFetchCandidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		console.Println("No matching books found")
+		return nil, nil
+	}
+
+	if headless {
+		console.Printf("Found %d possible match(es), not picking one without a terminal to ask\n", len(candidates))
+		return nil, nil
+	}
+
+	for i, candidate := range candidates {
+		console.Displayf("\n[%d]\n", i+1)
+		printBookInfo(candidate)
+	}
+
+	choice := prompt(input, fmt.Sprintf("\nUse which match? [1-%d, Enter to skip] ", len(candidates)))
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(candidates) {
+		return nil, nil
+	}
+
+	return &candidates[index-1], nil
+}
+
+// fetchCover downloads the cover of a fetched book into dir. Failures only warn,
+// a missing cover shouldn't throw away the rest of the metadata.
+func fetchCover(info *metadata.BookInfo, dir string) string {
+	if info.CoverURL == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	coverPath, err := metadata.NewLookup().DownloadCover(ctx, info.CoverURL, dir)
+	if err != nil {
+		console.Printf("⚠️  %v\n", err)
+		return ""
+	}
+	return coverPath
+}
+
+// convertMetadataLookup adapts fetchBookInfo to the converter's lookup hook,
+// downloading covers into dir
+func convertMetadataLookup(dir string) func(converter.MetadataQuery) (*converter.FetchedMetadata, error) {
+	return func(query converter.MetadataQuery) (*converter.FetchedMetadata, error) {
+		info, err := fetchBookInfo(query.ISBN, query.Title, query.Author)
+		if err != nil || info == nil {
+			return nil, err
+		}
+
+		return &converter.FetchedMetadata{
+			Title:       info.Title,
+			Author:      info.Author,
+			Description: info.Description,
+			Publisher:   info.Publisher,
+			CoverPath:   fetchCover(info, dir),
+		}, nil
+	}
+}
+
+func printBookInfo(info metadata.BookInfo) {
+	console.Displayf("📝 Title:       %s\n", info.Title)
+	if info.Author != "" {
+		console.Displayf("✍️  Author:      %s\n", info.Author)
+	}
+	if info.Publisher != "" {
+		console.Displayf("🏢 Publisher:   %s\n", info.Publisher)
+	}
+	if info.ISBN != "" {
+		console.Displayf("🔗 ISBN:        %s\n", info.ISBN)
+	}
+	if info.Description != "" {
+		console.Displayf("📄 Description: %s\n", truncateText(info.Description, 80))
+	}
+	if info.CoverURL != "" {
+		console.Displayf("📸 Cover:       %s\n", info.CoverURL)
+	}
+	console.Displayf("🌐 Source:      %s\n", info.Source)
+}
+
+func prompt(input *bufio.Reader, question string) string {
+	console.Displayf("%s", question)
+	answer, _ := input.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+func confirm(input *bufio.Reader, question string) bool {
+	answer := strings.ToLower(prompt(input, question))
+	return answer == "y" || answer == "yes"
+}
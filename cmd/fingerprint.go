@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/worker"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/fingerprint"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fingerprintOCR         bool
+	fingerprintOCRLanguage string
+	fingerprintRecompute   bool
+)
+
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint [files...]",
+	Short: "Tell whether PDFs, EPUBs and text files hold the same book",
+	Long: `Fingerprint the text of books to tell whether they're the same book, whatever their
+format: a PDF and the EPUB made from it, two EPUBs from different shops, or a text file.
+
+A fingerprint is a sample of the runs of five words in the text, so layout, line breaks,
+hyphenation and page numbers don't change it. Given several files, each pair is compared:
+
+  80-100%   the same book
+  30-80%    the same book with differences: another edition, or a scan with OCR errors
+  0-30%     different books
+
+publify convert stores the fingerprint in the EPUB's metadata, which is used when it's
+there; --recompute works it out from the EPUB's text instead. PDFs that look scanned are
+read with OCR, as publify convert would.
+
+Examples:
+  publify fingerprint book.epub
+  publify fingerprint book.pdf book.epub
+  publify fingerprint "Mort (1987).epub" mort-scan.pdf --ocr-lang eng`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runFingerprint,
+}
+
+func init() {
+	rootCmd.AddCommand(fingerprintCmd)
+
+	fingerprintCmd.Flags().BoolVar(&fingerprintOCR, "ocr", false, "Read PDFs with OCR even where they have a text layer (requires Tesseract)")
+	fingerprintCmd.Flags().StringVar(&fingerprintOCRLanguage, "ocr-lang", "eng", "OCR languages, joined with + for books in several (eng, swe, eng+fra, etc.)")
+	fingerprintCmd.Flags().BoolVar(&fingerprintRecompute, "recompute", false, "Fingerprint EPUBs from their text even when they have one stored")
+}
+
+func runFingerprint(cmd *cobra.Command, args []string) error {
+	prints := make([]fingerprint.Fingerprint, len(args))
+	for i, path := range args {
+		fp, source, err := fileFingerprint(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		prints[i] = fp
+		console.Displayf("🔖 %s\n   %s (%s)\n", path, fp, source)
+	}
+
+	if len(args) > 1 {
+		console.Displayf("\n")
+	}
+	for i := range args {
+		for j := i + 1; j < len(args); j++ {
+			similarity := prints[i].Similarity(prints[j])
+			console.Displayf("%s and %s: %.0f%% alike, %s\n", filepath.Base(args[i]), filepath.Base(args[j]),
+				similarity*100, fingerprint.Verdict(similarity))
+		}
+	}
+	return nil
+}
+
+// fileFingerprint fingerprints a PDF, EPUB or text file, saying where the fingerprint came from
+func fileFingerprint(path string) (fingerprint.Fingerprint, string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("file does not exist")
+	}
+
+	var text string
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".epub":
+		var stored fingerprint.Fingerprint
+		stored, text, err = epubFingerprintText(path)
+		if stored != nil {
+			return stored, "stored at conversion", nil
+		}
+	case ".pdf":
+		text, err = pdfText(path)
+	case ".txt":
+		var data []byte
+		data, err = os.ReadFile(path)
+		text = string(data)
+	default:
+		return nil, "", fmt.Errorf("unsupported format: %s (PDF, EPUB and text files are)", ext)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	fp := fingerprint.Compute(text)
+	if len(fp) == 0 {
+		return nil, "", fmt.Errorf("too little text to fingerprint (a scanned PDF needs --ocr)")
+	}
+	return fp, "from the text", nil
+}
+
+// epubFingerprintText returns the fingerprint stored in an EPUB, or its text to work one
+// out from if there's none (or --recompute asks for it)
+func epubFingerprintText(path string) (fingerprint.Fingerprint, string, error) {
+	reader, err := metadata.NewEPUBReader(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	if !fingerprintRecompute {
+		meta, err := reader.GetMetadata()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read metadata: %w", err)
+		}
+		if meta.Fingerprint != "" {
+			if stored, err := fingerprint.Parse(meta.Fingerprint); err == nil {
+				return stored, "", nil
+			}
+		}
+	}
+
+	text, err := reader.ReadText()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read text: %w", err)
+	}
+	return nil, text, nil
+}
+
+// pdfText extracts a PDF's text the way publify convert does
+func pdfText(path string) (string, error) {
+	processor, err := converter.NewPDFProcessor(path, converter.PDFProcessorOptions{
+		EnableOCR:   fingerprintOCR,
+		AutoOCR:     true,
+		OCRLanguage: fingerprintOCRLanguage,
+		SkipFigures: true, // Figures don't change the text
+		SkipLayout:  true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer processor.Close()
+
+	pool := worker.NewPool(runtime.NumCPU())
+	pool.Start()
+	defer pool.Stop()
+
+	pages, err := processor.ProcessPages(context.Background(), pool, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to process PDF: %w", err)
+	}
+
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\n")
+	}
+	return text.String(), nil
+}
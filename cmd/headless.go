@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alde/publify/pkg/progress"
+)
+
+// tempDirEnv points publify's temporary files somewhere other than the system default
+const tempDirEnv = "PUBLIFY_TMPDIR"
+
+// configureTempDir decides where temporary files go: PUBLIFY_TMPDIR if it's set. Headless,
+// the system temp directory is tried first, since containers often run with a read-only
+// root filesystem, and the working directory is used if nothing can be written there.
+func configureTempDir() error {
+	if dir := os.Getenv(tempDirEnv); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s %s: %w", tempDirEnv, dir, err)
+		}
+		return os.Setenv("TMPDIR", dir)
+	}
+	if !progress.Headless() || writableDir(os.TempDir()) {
+		return nil
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to find a writable temp directory: %w", err)
+	}
+	dir := filepath.Join(workDir, ".publify-tmp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s isn't writable and neither is the working directory; set %s: %w", os.TempDir(), tempDirEnv, err)
+	}
+	return os.Setenv("TMPDIR", dir)
+}
+
+// writableDir checks that files can be created in dir
+func writableDir(dir string) bool {
+	probe, err := os.MkdirTemp(dir, "publify-probe-*")
+	if err != nil {
+		return false
+	}
+	os.Remove(probe)
+	return true
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info [file]",
+	Short: "Show what's in a PDF or EPUB, to help pick conversion flags",
+	Long: `Look a PDF or an EPUB over and report what matters for converting or reading it.
+
+For a PDF: the page count, whether the pages have a text layer, encryption and what its
+permissions allow, the fonts the text is set in (and whether they're embedded) and how
+many bookmarks it has. The text layer and fonts come from a dozen pages spread through
+the book rather than every page, so a long book doesn't take long. Hints suggest the
+convert flags the PDF looks to need.
+
+For an EPUB: its version, chapter count, image count and the largest files in it, and
+the words in each chapter with how long they take to read at --wpm words a minute.
+
+Examples:
+  publify info book.pdf
+  publify info book.epub
+  publify info book.epub --wpm 300`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+var infoWPM int
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().IntVar(&infoWPM, "wpm", metadata.DefaultWPM, "Reading speed reading times are estimated at, in words a minute")
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	if infoWPM <= 0 {
+		return fmt.Errorf("--wpm must be a positive number of words a minute")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".pdf":
+		return showPDFInfo(path)
+	case ".epub":
+		return showEPUBInfo(path)
+	default:
+		return fmt.Errorf("unsupported file type: %s (expected .pdf or .epub)", ext)
+	}
+}
+
+func showPDFInfo(pdfPath string) error {
+	processor, err := converter.NewPDFProcessor(pdfPath, converter.PDFProcessorOptions{Workers: 1})
+	if err != nil {
+		return fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer processor.Close()
+
+	info, err := processor.Info()
+	if err != nil {
+		return fmt.Errorf("failed to read PDF: %w", err)
+	}
+	size, _ := processor.GetFileSize()
+
+	console.Displayf("📄 PDF: %s\n", filepath.Base(pdfPath))
+	console.Displayf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	console.Displayf("📏 Size:        %s\n", humanize.Bytes(uint64(size)))
+	console.Displayf("📃 Pages:       %d\n", info.PageCount)
+
+	switch {
+	case info.SampledPages == 0:
+	case info.TextPages == info.SampledPages:
+		console.Displayf("🔤 Text layer:  yes (%d of %d sampled pages)\n", info.TextPages, info.SampledPages)
+	case info.TextPages == 0:
+		console.Displayf("🔤 Text layer:  none (%d sampled pages)\n", info.SampledPages)
+	default:
+		console.Displayf("🔤 Text layer:  on %d of %d sampled pages\n", info.TextPages, info.SampledPages)
+	}
+
+	if info.Encrypted {
+		var denied []string
+		if !info.CanCopy {
+			denied = append(denied, "copying text")
+		}
+		if !info.CanPrint {
+			denied = append(denied, "printing")
+		}
+		if len(denied) > 0 {
+			console.Displayf("🔒 Encrypted:   yes, not allowing %s\n", strings.Join(denied, " or "))
+		} else {
+			console.Displayf("🔒 Encrypted:   yes\n")
+		}
+	} else {
+		console.Displayf("🔓 Encrypted:   no\n")
+	}
+
+	if len(info.Fonts) > 0 {
+		notEmbedded := 0
+		names := make([]string, len(info.Fonts))
+		for i, font := range info.Fonts {
+			names[i] = font.Name
+			if !font.Embedded {
+				names[i] += " (not embedded)"
+				notEmbedded++
+			}
+		}
+		console.Displayf("🔠 Fonts:       %s", countOf(len(info.Fonts), "font"))
+		if notEmbedded > 0 {
+			console.Displayf(", %d not embedded", notEmbedded)
+		}
+		console.Displayf("\n")
+		for _, name := range names {
+			console.Displayf("               %s\n", name)
+		}
+	}
+
+	console.Displayf("🔖 Bookmarks:   %d\n", info.Bookmarks)
+
+	if hints := pdfHints(info); len(hints) > 0 {
+		console.Displayf("\n💡 Hints:\n")
+		for _, hint := range hints {
+			console.Displayf("   • %s\n", hint)
+		}
+	}
+	return nil
+}
+
+// pdfHints suggests convert flags for what Info found
+func pdfHints(info converter.PDFInfo) []string {
+	var hints []string
+
+	switch {
+	case info.SampledPages == 0:
+	case 2*(info.SampledPages-info.TextPages) > info.SampledPages:
+		hints = append(hints, "It looks scanned: convert turns OCR on for it by itself (Tesseract needed, see publify doctor). "+
+			"Give --ocr-lang for a book not in English.")
+	case info.TextPages < info.SampledPages:
+		hints = append(hints, "Some pages have no text layer, likely plates or scanned inserts: keep them as pictures with "+
+			"--image-pages, or read them with --ocr.")
+	}
+
+	if info.Encrypted && !info.CanCopy {
+		hints = append(hints, "Its permissions don't allow copying text, so check you're free to convert it for your own reading.")
+	}
+
+	if info.Bookmarks > 0 {
+		hints = append(hints, fmt.Sprintf("Chapters are worked out from the pages, not the %s; --review lets you check them against those.",
+			countOf(info.Bookmarks, "bookmark")))
+	}
+
+	return hints
+}
+
+func showEPUBInfo(epubPath string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	info, err := reader.Info()
+	if err != nil {
+		return fmt.Errorf("failed to read EPUB: %w", err)
+	}
+
+	console.Displayf("📖 EPUB: %s\n", filepath.Base(epubPath))
+	console.Displayf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if info.Version != "" {
+		console.Displayf("🏷️  Version:     EPUB %s\n", info.Version)
+	}
+	console.Displayf("📏 Size:        %s uncompressed\n", humanize.Bytes(info.TotalSize))
+	console.Displayf("📑 Chapters:    %d\n", info.Chapters)
+	console.Displayf("🖼️  Images:      %d\n", info.Images)
+	console.Displayf("📝 Words:       %s, about %s to read\n", humanize.Comma(int64(info.Words)),
+		metadata.FormatReadingTime(metadata.ReadingTime(info.Words, infoWPM)))
+	printChapterWords(info.ChapterWords, infoWPM)
+
+	if len(info.Largest) > 0 {
+		console.Displayf("📦 Largest files:\n")
+		for _, resource := range info.Largest {
+			console.Displayf("   %10s  %-40s %s\n", humanize.Bytes(resource.Size), truncateText(resource.Path, 40), resource.MediaType)
+		}
+	}
+	return nil
+}
+
+// printChapterWords lists the chapters with their length and reading time at wpm
+func printChapterWords(chapters []metadata.ChapterWords, wpm int) {
+	for _, chapter := range chapters {
+		console.Displayf("   %8s  %-14s %s\n", humanize.Comma(int64(chapter.Words)),
+			metadata.FormatReadingTime(metadata.ReadingTime(chapter.Words, wpm)), truncateText(chapter.Title, 50))
+	}
+}
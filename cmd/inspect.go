@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [epub file]",
+	Short: "List the files inside an EPUB",
+	Long: `List the files inside an EPUB without extracting it, with their sizes and
+how they are stored.
+
+Examples:
+  publify ls book.epub`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLs,
+}
+
+var catCmd = &cobra.Command{
+	Use:   "cat [epub file] [entry...]",
+	Short: "Print files from inside an EPUB",
+	Long: `Write files from inside an EPUB to stdout without extracting it.
+
+Entries are given by their path in the archive, as shown by publify ls. A bare
+file name works too, as long as only one entry has it.
+
+Examples:
+  publify cat book.epub OEBPS/chapter1.xhtml
+  publify cat book.epub content.opf
+  publify cat book.epub META-INF/container.xml | xmllint --format -`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runCat,
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(catCmd)
+}
+
+func runLs(cmd *cobra.Command, args []string) error {
+	epubPath := args[0]
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %w", err)
+	}
+	defer zipReader.Close()
+
+	var total, compressed uint64
+	console.Displayf("%10s  %10s  %-7s  %s\n", "Size", "Packed", "Method", "Name")
+	for _, file := range zipReader.File {
+		console.Displayf("%10s  %10s  %-7s  %s\n",
+			humanize.Bytes(file.UncompressedSize64), humanize.Bytes(file.CompressedSize64),
+			methodName(file.Method), file.Name)
+		total += file.UncompressedSize64
+		compressed += file.CompressedSize64
+	}
+	console.Displayf("%10s  %10s  %-7s  %d files\n", humanize.Bytes(total), humanize.Bytes(compressed), "", len(zipReader.File))
+
+	return nil
+}
+
+func methodName(method uint16) string {
+	switch method {
+	case zip.Store:
+		return "store"
+	case zip.Deflate:
+		return "deflate"
+	}
+	return fmt.Sprintf("#%d", method)
+}
+
+func runCat(cmd *cobra.Command, args []string) error {
+	epubPath := args[0]
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %w", err)
+	}
+	defer zipReader.Close()
+
+	// Find everything first, so a typo in the last name doesn't leave half the output behind
+	var files []*zip.File
+	for _, name := range args[1:] {
+		file, err := findEntry(zipReader.File, name)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	for _, file := range files {
+		if err := catEntry(file, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findEntry looks an entry up by its full path, or by its file name if that's unambiguous
+func findEntry(files []*zip.File, name string) (*zip.File, error) {
+	name = strings.TrimPrefix(name, "/")
+
+	var matches []*zip.File
+	for _, file := range files {
+		if file.Name == name {
+			return file, nil
+		}
+		if path.Base(file.Name) == name {
+			matches = append(matches, file)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no entry named %s in the EPUB (see publify ls)", name)
+	case 1:
+		return matches[0], nil
+	}
+
+	paths := make([]string, len(matches))
+	for i, match := range matches {
+		paths[i] = match.Name
+	}
+	return nil, fmt.Errorf("%s is ambiguous, it could be any of: %s", name, strings.Join(paths, ", "))
+}
+
+func catEntry(file *zip.File, w io.Writer) error {
+	if file.FileInfo().IsDir() {
+		return fmt.Errorf("%s is a directory", file.Name)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+
+	return nil
+}
@@ -1,23 +1,47 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/safefile"
 	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/progress"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
 var (
 	metaTitle       string
-	metaAuthor      string
+	metaAuthors     []string
+	metaContributor []string
 	metaDescription string
 	metaLanguage    string
 	metaPublisher   string
 	metaCover       string
+	metaNoCover     bool
 	showMeta        bool
+	metaFetch       bool
+	metaJSON        bool
+	metaExport      string
+	metaExportCover string
+	metaImport      string
+	metaFromName    string
+	metaSeries      string
+	metaSeriesIndex string
+	metaSubjects    []string
+	metaRights      string
+	metaReproduce   bool
+	metaTOCFrom     string
+	metaEditTOC     bool
+	metaExportTOC   string
+	metaWPM         int
 )
 
 var metadataCmd = &cobra.Command{
@@ -27,19 +51,52 @@ var metadataCmd = &cobra.Command{
 
 View metadata:
   publify metadata book.epub
+  publify metadata book.epub --json
+  publify metadata book.epub -v --wpm 300   (words and reading time per chapter)
 
 Edit metadata:
   publify metadata book.epub --title "New Title" --author "New Author"
+  publify metadata book.epub --author "Neil Gaiman" --author "Terry Pratchett"
+  publify metadata book.epub --contributor "Tiina Nunnally:trl" --contributor "Jane Doe:ill"
   publify metadata book.epub --description "Book description"
   publify metadata book.epub --cover cover.jpg
+  publify metadata book.epub --remove-cover
+  publify metadata book.epub --export-cover cover.jpg
+  publify metadata book.epub --series "Discworld" --series-index 3
+  publify metadata book.epub --subject Fantasy --subject Humour --rights "© 1987 Terry Pratchett"
+
+Copy metadata between books with a sidecar file:
+  publify metadata book.epub --export meta.yaml
+  publify metadata other.epub --import meta.yaml
+
+Fix the table of contents (nav.xhtml and toc.ncx are both rewritten):
+  publify metadata book.epub --export-toc toc.yaml
+  publify metadata book.epub --toc-from toc.yaml
+  publify metadata book.epub --edit-toc
+
+Take metadata from the file name (placeholders: {title}, {author}, {series},
+{series_index}, {publisher}, {language}):
+  publify metadata "Terry Pratchett - Mort.epub" --from-filename "{author} - {title}"
+
+Fetch metadata online (Open Library, Google Books):
+  publify metadata book.epub --fetch
 
 All metadata fields:
-  --title       Book title
-  --author      Author name
-  --description Book description
-  --language    Language code (e.g., en, sv, de)
-  --publisher   Publisher name
-  --cover       Path to cover image file`,
+  --title        Book title
+  --author       Author name (repeatable)
+  --contributor  Contributor as "Name:role" (roles: aut, edt, ill, trl, nrt, ...)
+  --description  Book description
+  --language     Language code (e.g., en, sv, de)
+  --publisher    Publisher name
+  --cover        Path to cover image file (replaces the old cover where it was)
+  --remove-cover Take the cover image and cover page out of the book
+  --series       Series name (calibre:series)
+  --series-index Position in the series (e.g. 3 or 3.5)
+  --subject      Subject/genre, repeatable (replaces existing subjects)
+  --rights       Rights statement
+  --fetch        Look up missing details by ISBN or title and confirm before applying
+  --toc-from     Table of contents file (.yaml or .json, as --export-toc writes)
+  --edit-toc     Rename, reorder and nest table of contents entries interactively`,
 	Args: cobra.ExactArgs(1),
 	RunE: runMetadata,
 }
@@ -48,12 +105,31 @@ func init() {
 	rootCmd.AddCommand(metadataCmd)
 
 	metadataCmd.Flags().StringVar(&metaTitle, "title", "", "Set book title")
-	metadataCmd.Flags().StringVar(&metaAuthor, "author", "", "Set author name")
+	metadataCmd.Flags().StringArrayVar(&metaAuthors, "author", nil, "Set author (repeatable, replaces existing authors; \"Name:role\" for another role)")
+	metadataCmd.Flags().StringArrayVar(&metaContributor, "contributor", nil, "Set contributor as \"Name:role\", e.g. \"Jane Doe:trl\" (repeatable, replaces existing)")
 	metadataCmd.Flags().StringVar(&metaDescription, "description", "", "Set book description")
 	metadataCmd.Flags().StringVar(&metaLanguage, "language", "", "Set language code (e.g., en, sv)")
 	metadataCmd.Flags().StringVar(&metaPublisher, "publisher", "", "Set publisher name")
 	metadataCmd.Flags().StringVar(&metaCover, "cover", "", "Set cover image (path to image file)")
+	metadataCmd.Flags().BoolVar(&metaNoCover, "remove-cover", false, "Remove the cover image, and the cover page if it shows nothing else")
+	metadataCmd.Flags().StringVar(&metaSeries, "series", "", "Set series name")
+	metadataCmd.Flags().StringVar(&metaSeriesIndex, "series-index", "", "Set position in the series (e.g. 3 or 3.5)")
+	metadataCmd.Flags().StringSliceVar(&metaSubjects, "subject", nil, "Set subjects (repeatable or comma-separated, replaces existing)")
+	metadataCmd.Flags().StringVar(&metaRights, "rights", "", "Set rights statement")
+	metadataCmd.Flags().BoolVar(&metaFetch, "fetch", false, "Look up metadata online by ISBN or title (asks before applying)")
+	metadataCmd.Flags().BoolVar(&metaJSON, "json", false, "Show metadata as JSON (for scripts and library managers)")
+	metadataCmd.Flags().StringVar(&metaExport, "export", "", "Export metadata to a sidecar file (.yaml or .json)")
+	metadataCmd.Flags().StringVar(&metaExportCover, "export-cover", "", "Write the cover image to this file (after any edits; the extension is added if left out)")
+	metadataCmd.Flags().StringVar(&metaImport, "import", "", "Apply metadata from a sidecar file (.yaml or .json); other flags take precedence")
+	metadataCmd.Flags().StringVar(&metaFromName, "from-filename", "", "Set metadata parsed from the file name with a template, e.g. \"{author} - {title}\"; other flags take precedence")
 	metadataCmd.Flags().BoolVar(&showMeta, "show", false, "Show current metadata (default if no flags)")
+	metadataCmd.Flags().StringVar(&metaTOCFrom, "toc-from", "", "Replace the table of contents with the one in this file (.yaml or .json)")
+	metadataCmd.Flags().BoolVar(&metaEditTOC, "edit-toc", false, "Edit the table of contents interactively (after --toc-from, if given)")
+	metadataCmd.Flags().StringVar(&metaExportTOC, "export-toc", "", "Export the table of contents to a file (.yaml or .json) for editing (after any edits)")
+	metadataCmd.Flags().IntVar(&metaWPM, "wpm", metadata.DefaultWPM, "Reading speed the reading time is estimated at, in words a minute")
+	metadataCmd.Flags().BoolVar(&metaReproduce, "reproducible", false, "Stamp the saved EPUB with SOURCE_DATE_EPOCH (or 1980) instead of now, so the same edit gives the same bytes")
+
+	metadataCmd.MarkFlagsMutuallyExclusive("cover", "remove-cover")
 }
 
 func runMetadata(cmd *cobra.Command, args []string) error {
@@ -64,13 +140,80 @@ func runMetadata(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("EPUB validation failed: %w", err)
 	}
 
+	// The file name is specific to this book, a sidecar is often shared between several
+	if metaFromName != "" {
+		sidecar, err := metadata.ParseFilename(epubPath, metaFromName)
+		if err != nil {
+			return err
+		}
+		applySidecar(sidecar)
+	}
+	if metaImport != "" {
+		if err := importSidecar(metaImport); err != nil {
+			return err
+		}
+	}
+
+	if metaWPM <= 0 {
+		return fmt.Errorf("--wpm must be a positive number of words a minute")
+	}
+
 	// Check if we're only viewing metadata
 	if isViewOnlyMode() {
+		if metaExport != "" || metaExportCover != "" || metaExportTOC != "" {
+			return exportMetadata(epubPath)
+		}
+		if metaJSON {
+			return showMetadataJSON(epubPath)
+		}
 		return showMetadata(epubPath)
 	}
+	if metaJSON {
+		return fmt.Errorf("--json only works when viewing metadata, not when editing")
+	}
+	if metaEditTOC && progress.Headless() {
+		return fmt.Errorf("--edit-toc needs a terminal to ask at, and publify is running headless (see publify --help); use --toc-from instead")
+	}
 
 	// Edit metadata
-	return editMetadata(epubPath)
+	if err := editMetadata(epubPath); err != nil {
+		return err
+	}
+	return exportMetadata(epubPath)
+}
+
+// exportMetadata writes the sidecar, cover image and table of contents the flags ask for
+func exportMetadata(epubPath string) error {
+	if metaExport != "" {
+		if err := exportSidecar(epubPath, metaExport); err != nil {
+			return err
+		}
+	}
+	if metaExportTOC != "" {
+		if err := exportTOC(epubPath, metaExportTOC); err != nil {
+			return err
+		}
+	}
+	if metaExportCover != "" {
+		return exportCover(epubPath, metaExportCover)
+	}
+	return nil
+}
+
+// exportCover writes the book's cover image to coverPath
+func exportCover(epubPath, coverPath string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	written, err := reader.ExportCover(coverPath)
+	if err != nil {
+		return fmt.Errorf("failed to export cover: %w", err)
+	}
+	console.Printf("📸 Cover saved as %s\n", written)
+	return nil
 }
 
 func validateEPUBFile(path string) error {
@@ -91,11 +234,20 @@ func validateEPUBFile(path string) error {
 func isViewOnlyMode() bool {
 	// If no editing flags are set, we're in view mode
 	return metaTitle == "" &&
-		metaAuthor == "" &&
+		len(metaAuthors) == 0 &&
+		len(metaContributor) == 0 &&
 		metaDescription == "" &&
 		metaLanguage == "" &&
 		metaPublisher == "" &&
-		metaCover == ""
+		metaCover == "" &&
+		!metaNoCover &&
+		metaSeries == "" &&
+		metaSeriesIndex == "" &&
+		len(metaSubjects) == 0 &&
+		metaRights == "" &&
+		!metaFetch &&
+		metaTOCFrom == "" &&
+		!metaEditTOC
 }
 
 func showMetadata(epubPath string) error {
@@ -111,58 +263,164 @@ func showMetadata(epubPath string) error {
 	}
 
 	// Display metadata in a nice format
-	fmt.Printf("📖 EPUB Metadata: %s\n", filepath.Base(epubPath))
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	console.Displayf("📖 EPUB Metadata: %s\n", filepath.Base(epubPath))
+	console.Displayf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 
 	if meta.Title != "" {
-		fmt.Printf("📝 Title:       %s\n", meta.Title)
+		console.Displayf("📝 Title:       %s\n", meta.Title)
+	}
+	if len(meta.Creators) > 0 {
+		console.Displayf("✍️  Author:      %s\n", formatContributors(meta.Creators))
 	}
-	if meta.Author != "" {
-		fmt.Printf("✍️  Author:      %s\n", meta.Author)
+	if len(meta.Contributors) > 0 {
+		console.Displayf("🤝 Contributor: %s\n", formatContributors(meta.Contributors))
 	}
 	if meta.Description != "" {
-		fmt.Printf("📄 Description: %s\n", truncateText(meta.Description, 80))
+		console.Displayf("📄 Description: %s\n", truncateText(meta.Description, 80))
 	}
 	if meta.Language != "" {
-		fmt.Printf("🌍 Language:    %s\n", meta.Language)
+		console.Displayf("🌍 Language:    %s\n", meta.Language)
 	}
 	if meta.Publisher != "" {
-		fmt.Printf("🏢 Publisher:   %s\n", meta.Publisher)
+		console.Displayf("🏢 Publisher:   %s\n", meta.Publisher)
 	}
 	if meta.Identifier != "" {
-		fmt.Printf("🔗 Identifier:  %s\n", meta.Identifier)
+		console.Displayf("🔗 Identifier:  %s\n", meta.Identifier)
+	}
+	if meta.Series != "" {
+		if meta.SeriesIndex > 0 {
+			console.Displayf("📚 Series:      %s #%g\n", meta.Series, meta.SeriesIndex)
+		} else {
+			console.Displayf("📚 Series:      %s\n", meta.Series)
+		}
+	}
+	if len(meta.Subjects) > 0 {
+		console.Displayf("🏷️  Subjects:    %s\n", truncateText(strings.Join(meta.Subjects, ", "), 80))
+	}
+	if meta.Rights != "" {
+		console.Displayf("©️  Rights:      %s\n", meta.Rights)
 	}
 	if meta.CoverPath != "" {
-		fmt.Printf("📸 Cover:       %s\n", meta.CoverPath)
+		console.Displayf("📸 Cover:       %s\n", meta.CoverPath)
 	}
 	if !meta.Created.IsZero() {
-		fmt.Printf("📅 Created:     %s\n", meta.Created.Format("2006-01-02 15:04:05"))
+		console.Displayf("📅 Created:     %s\n", meta.Created.Format("2006-01-02 15:04:05"))
 	}
 	if !meta.Modified.IsZero() {
-		fmt.Printf("📝 Modified:    %s\n", meta.Modified.Format("2006-01-02 15:04:05"))
+		console.Displayf("📝 Modified:    %s\n", meta.Modified.Format("2006-01-02 15:04:05"))
 	}
 
 	// Show file info
 	stat, err := os.Stat(epubPath)
 	if err == nil {
-		fmt.Printf("📊 File Size:   %s\n", formatFileSize(stat.Size()))
+		console.Displayf("📊 File Size:   %s\n", formatFileSize(stat.Size()))
 	}
 
-	// Show chapter count if available
-	chapters, err := reader.GetChapterList()
+	// Show chapter count and length if available
+	chapters, err := reader.WordCounts()
 	if err == nil && len(chapters) > 0 {
-		fmt.Printf("📚 Chapters:    %d\n", len(chapters))
+		console.Displayf("📚 Chapters:    %d\n", len(chapters))
+		words := 0
+		for _, chapter := range chapters {
+			words += chapter.Words
+		}
+		console.Displayf("⏱️  Words:       %s, about %s to read\n", humanize.Comma(int64(words)),
+			metadata.FormatReadingTime(metadata.ReadingTime(words, metaWPM)))
+		if verbosity > 0 {
+			printChapterWords(chapters, metaWPM)
+		}
 	}
 
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	console.Displayf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 
 	return nil
 }
 
+// metadataDocument is the --json output: the metadata plus file details
+type metadataDocument struct {
+	File     string                `json:"file"`
+	FileSize int64                 `json:"fileSize"`
+	Metadata metadata.EPUBMetadata `json:"metadata"`
+	Chapters []metadata.Chapter    `json:"chapters"`
+	TOC      []metadata.Chapter    `json:"toc,omitempty"` // The table of contents, nested
+	Reading  *readingDocument      `json:"reading,omitempty"`
+}
+
+// readingDocument is how long the book is, and takes to read
+type readingDocument struct {
+	WPM      int                     `json:"wpm"`
+	Words    int                     `json:"words"`
+	Minutes  int                     `json:"minutes"` // At WPM words a minute
+	Chapters []metadata.ChapterWords `json:"chapters"`
+}
+
+func showMetadataJSON(epubPath string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	meta, err := reader.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	chapters, err := reader.GetChapterList()
+	if err != nil {
+		return fmt.Errorf("failed to read chapters: %w", err)
+	}
+	if chapters == nil {
+		chapters = []metadata.Chapter{}
+	}
+
+	doc := metadataDocument{
+		File:     filepath.Base(epubPath),
+		Metadata: meta,
+		Chapters: chapters,
+	}
+	if toc, err := reader.GetTableOfContents(); err == nil {
+		doc.TOC = toc
+	}
+	if counts, err := reader.WordCounts(); err == nil && len(counts) > 0 {
+		reading := &readingDocument{WPM: metaWPM, Chapters: counts}
+		for _, chapter := range counts {
+			reading.Words += chapter.Words
+		}
+		reading.Minutes = int(metadata.ReadingTime(reading.Words, metaWPM).Round(time.Minute).Minutes())
+		doc.Reading = reading
+	}
+	if stat, err := os.Stat(epubPath); err == nil {
+		doc.FileSize = stat.Size()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
 func editMetadata(epubPath string) error {
+	if metaFetch {
+		tempDir, err := os.MkdirTemp("", "publify-fetch-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := fetchMetadata(epubPath, tempDir); err != nil {
+			return fmt.Errorf("metadata lookup failed: %w", err)
+		}
+	}
+
+	// Interactive editing happens before anything is written
+	toc, err := newTOC(epubPath)
+	if err != nil {
+		return fmt.Errorf("table of contents: %w", err)
+	}
+
 	// Create backup
 	backupPath := epubPath + ".backup"
-	if err := copyFile(epubPath, backupPath); err != nil {
+	if err := safefile.Copy(epubPath, backupPath); err != nil {
 		return fmt.Errorf("failed to create backup: %w", err)
 	}
 
@@ -171,6 +429,7 @@ func editMetadata(epubPath string) error {
 		return fmt.Errorf("failed to open EPUB for editing: %w", err)
 	}
 	defer editor.Close()
+	editor.SetReproducible(metaReproduce)
 
 	// Apply metadata changes
 	changes := 0
@@ -180,18 +439,36 @@ func editMetadata(epubPath string) error {
 			return fmt.Errorf("failed to set title: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set title: %s\n", metaTitle)
+		if verbosity > 0 {
+			console.Printf("✅ Set title: %s\n", metaTitle)
 		}
 	}
 
-	if metaAuthor != "" {
-		if err := editor.SetAuthor(metaAuthor); err != nil {
+	if len(metaAuthors) > 0 {
+		creators, err := parseContributors(metaAuthors, "aut")
+		if err != nil {
+			return fmt.Errorf("invalid author: %w", err)
+		}
+		if err := editor.SetCreators(creators); err != nil {
 			return fmt.Errorf("failed to set author: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set author: %s\n", metaAuthor)
+		if verbosity > 0 {
+			console.Printf("✅ Set author: %s\n", formatContributors(creators))
+		}
+	}
+
+	if len(metaContributor) > 0 {
+		contributors, err := parseContributors(metaContributor, "ctb")
+		if err != nil {
+			return fmt.Errorf("invalid contributor: %w", err)
+		}
+		if err := editor.SetContributors(contributors); err != nil {
+			return fmt.Errorf("failed to set contributors: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set contributors: %s\n", formatContributors(contributors))
 		}
 	}
 
@@ -200,8 +477,8 @@ func editMetadata(epubPath string) error {
 			return fmt.Errorf("failed to set description: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set description: %s\n", truncateText(metaDescription, 50))
+		if verbosity > 0 {
+			console.Printf("✅ Set description: %s\n", truncateText(metaDescription, 50))
 		}
 	}
 
@@ -210,8 +487,8 @@ func editMetadata(epubPath string) error {
 			return fmt.Errorf("failed to set language: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set language: %s\n", metaLanguage)
+		if verbosity > 0 {
+			console.Printf("✅ Set language: %s\n", metaLanguage)
 		}
 	}
 
@@ -220,8 +497,52 @@ func editMetadata(epubPath string) error {
 			return fmt.Errorf("failed to set publisher: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set publisher: %s\n", metaPublisher)
+		if verbosity > 0 {
+			console.Printf("✅ Set publisher: %s\n", metaPublisher)
+		}
+	}
+
+	if metaSeries != "" {
+		if err := editor.SetSeries(metaSeries); err != nil {
+			return fmt.Errorf("failed to set series: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set series: %s\n", metaSeries)
+		}
+	}
+
+	if metaSeriesIndex != "" {
+		index, err := strconv.ParseFloat(metaSeriesIndex, 64)
+		if err != nil {
+			return fmt.Errorf("invalid series index: %s", metaSeriesIndex)
+		}
+		if err := editor.SetSeriesIndex(index); err != nil {
+			return fmt.Errorf("failed to set series index: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set series index: %g\n", index)
+		}
+	}
+
+	if len(metaSubjects) > 0 {
+		if err := editor.SetSubjects(metaSubjects); err != nil {
+			return fmt.Errorf("failed to set subjects: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set subjects: %s\n", strings.Join(metaSubjects, ", "))
+		}
+	}
+
+	if metaRights != "" {
+		if err := editor.SetRights(metaRights); err != nil {
+			return fmt.Errorf("failed to set rights: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set rights: %s\n", metaRights)
 		}
 	}
 
@@ -234,13 +555,31 @@ func editMetadata(epubPath string) error {
 			return fmt.Errorf("failed to set cover: %w", err)
 		}
 		changes++
-		if verbose {
-			fmt.Printf("✅ Set cover: %s\n", filepath.Base(metaCover))
+		if verbosity > 0 {
+			console.Printf("✅ Set cover: %s\n", filepath.Base(metaCover))
+		}
+	}
+
+	if metaNoCover {
+		editor.RemoveCover()
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Removed cover\n")
+		}
+	}
+
+	if toc != nil {
+		if err := editor.SetTOC(toc); err != nil {
+			return fmt.Errorf("failed to set table of contents: %w", err)
+		}
+		changes++
+		if verbosity > 0 {
+			console.Printf("✅ Set table of contents: %d top-level entries\n", len(toc))
 		}
 	}
 
 	if changes == 0 {
-		fmt.Println("No metadata changes specified. Use --help to see available options.")
+		console.Println("No metadata changes specified. Use --help to see available options.")
 		return nil
 	}
 
@@ -251,14 +590,91 @@ func editMetadata(epubPath string) error {
 
 	// Remove backup if successful
 	if err := os.Remove(backupPath); err != nil {
-		fmt.Printf("Warning: failed to remove backup file: %s\n", backupPath)
+		console.Printf("Warning: failed to remove backup file: %s\n", backupPath)
+	}
+
+	console.Printf("✅ Successfully updated %d metadata field(s) in %s\n", changes, filepath.Base(epubPath))
+
+	return nil
+}
+
+// fetchMetadata looks the book up online and fills in any fields not given as flags
+func fetchMetadata(epubPath, tempDir string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	meta, err := reader.GetMetadata()
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
 	}
 
-	fmt.Printf("✅ Successfully updated %d metadata field(s) in %s\n", changes, filepath.Base(epubPath))
+	isbn, _ := metadata.NormalizeISBN(meta.Identifier)
+	title, author := meta.Title, meta.Author
+	if metaTitle != "" {
+		title = metaTitle
+	}
+	if len(metaAuthors) > 0 {
+		author = strings.Split(metaAuthors[0], ":")[0]
+	}
+
+	info, err := fetchBookInfo(isbn, title, author)
+	if err != nil || info == nil {
+		return err
+	}
+
+	// Explicit flags always win
+	fields := []struct {
+		target *string
+		value  string
+	}{
+		{&metaTitle, info.Title},
+		{&metaDescription, info.Description},
+		{&metaPublisher, info.Publisher},
+	}
+	for _, field := range fields {
+		if *field.target == "" {
+			*field.target = field.value
+		}
+	}
+	if len(metaAuthors) == 0 && info.Author != "" {
+		// Catalogues give the authors as one comma-separated string
+		metaAuthors = strings.Split(info.Author, ", ")
+	}
+	if metaCover == "" && !metaNoCover {
+		metaCover = fetchCover(info, tempDir)
+	}
 
 	return nil
 }
 
+// parseContributors parses "Name" or "Name:role" flag values
+func parseContributors(values []string, defaultRole string) ([]metadata.Contributor, error) {
+	var people []metadata.Contributor
+	for _, value := range values {
+		person, err := metadata.ParseContributor(value, defaultRole)
+		if err != nil {
+			return nil, err
+		}
+		people = append(people, person)
+	}
+	return people, nil
+}
+
+// formatContributors lists people with their roles, leaving out the obvious "Author"
+func formatContributors(people []metadata.Contributor) string {
+	var parts []string
+	for _, person := range people {
+		if person.Role == "aut" || person.Role == "" {
+			parts = append(parts, person.Name)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", person.Name, metadata.RoleName(person.Role)))
+	}
+	return strings.Join(parts, ", ")
+}
+
 func validateCoverImage(imagePath string) error {
 	// Check if file exists
 	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
@@ -278,23 +694,6 @@ func validateCoverImage(imagePath string) error {
 	return fmt.Errorf("unsupported image format: %s (supported: %v)", ext, validExtensions)
 }
 
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = destFile.ReadFrom(sourceFile)
-	return err
-}
-
 func truncateText(text string, maxLength int) string {
 	if len(text) <= maxLength {
 		return text
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/preset"
+	"github.com/alde/publify/pkg/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// presetTimeout bounds downloading a preset and its checksum
+const presetTimeout = 30 * time.Second
+
+// presetFlags are the convert flags a preset may set: how pages are read and how the book
+// is made. Files, the output, the owner, and anything that sends pages or asks anywhere
+// are left to the command line, since presets come from strangers.
+var presetFlags = map[string]bool{
+	"reader": true, "color": true, "sharpen": true, "compression": true,
+	"dither": true, "gamma": true, "contrast": true, "white-point": true, "image-format": true,
+	"ocr": true, "no-auto-ocr": true, "ocr-lang": true, "ocr-preprocess": true, "min-ocr-confidence": true,
+	"image-pages": true, "fixed-layout": true, "crop-margins": true, "split-spreads": true, "pages": true, "skip": true, "cover-page": true,
+	"bleed-threshold": true, "no-bleed-detection": true, "no-figures": true, "no-descreen": true, "no-straighten": true, "keep-blank-pages": true, "no-size-budget": true,
+	"text-render": true, "text-layer": true, "transliterate": true, "writing-mode": true, "direction": true, "rtl": true,
+	"title-page": true, "colophon": true, "publisher": true, "from-filename": true,
+	"stable-names": true, "reproducible": true, "fast": true, "max-size": true,
+}
+
+var (
+	presetChecksum    string
+	presetInstallName string
+	presetForce       bool
+)
+
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Install and manage shared conversion presets",
+	Long: `Install, list, show and remove conversion presets: named sets of publify convert
+settings tuned for a scanner or a publisher's PDFs, as people share them. Use one with
+publify convert --preset NAME; flags given on the command line win over the preset's.
+
+A preset is a YAML file of convert flags and their values, with a name and description:
+
+  name: scanned-novel
+  description: Mass-market paperbacks scanned at 300 dpi on a flatbed
+  ocr-lang: eng
+  ocr-preprocess: deskew,binarize
+  bleed-threshold: -3.5
+  no-figures: true
+
+Presets only set how pages are read and how the book is made. They can't name files
+(--css, --cover, --embed-font and the like), set the output or owner, or send pages
+anywhere (--ocr-engine, --fetch).
+
+publify comes with a manga preset: right to left, every page laid out as drawn. An
+installed preset of the same name is used instead.
+
+Presets are downloaded over https and checked against their SHA-256 checksum, given with
+--sha256 or published next to the preset as <url>.sha256 (sha256sum's format). Installed
+presets are kept in ~/.config/publify/presets, and show says whether one was edited
+since.
+
+Examples:
+  publify preset install https://example.org/presets/scanned-novel.yaml
+  publify preset install https://example.org/presets/manga.yaml --sha256 9f86d08...
+  publify preset install my-scanner.yaml
+  publify preset list
+  publify preset show scanned-novel
+  publify preset remove scanned-novel`,
+}
+
+var presetInstallCmd = &cobra.Command{
+	Use:   "install [url or file]",
+	Short: "Install a preset from a URL or a file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetInstall,
+}
+
+var presetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed presets",
+	Args:  cobra.NoArgs,
+	RunE:  runPresetList,
+}
+
+var presetShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show an installed preset and where it came from",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetShow,
+}
+
+var presetRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove an installed preset",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(presetCmd)
+	presetCmd.AddCommand(presetInstallCmd, presetListCmd, presetShowCmd, presetRemoveCmd)
+
+	presetInstallCmd.Flags().StringVar(&presetChecksum, "sha256", "", "SHA-256 checksum the preset must have (default: read from <url>.sha256)")
+	presetInstallCmd.Flags().StringVar(&presetInstallName, "name", "", "Install under this name instead of the preset's own")
+	presetInstallCmd.Flags().BoolVar(&presetForce, "force", false, "Replace an installed preset of the same name from somewhere else")
+}
+
+// presetRegistry opens the registry of installed presets
+func presetRegistry() (*preset.Registry, error) {
+	dir, err := preset.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return preset.NewRegistry(storage.NewDir(dir)), nil
+}
+
+func runPresetInstall(cmd *cobra.Command, args []string) error {
+	source := args[0]
+	var data []byte
+	if strings.Contains(source, "://") {
+		ctx, cancel := context.WithTimeout(context.Background(), presetTimeout)
+		defer cancel()
+		console.Printf("📥 Downloading %s...\n", source)
+		var err error
+		if data, err = preset.Fetch(ctx, &http.Client{}, source, presetChecksum); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return fmt.Errorf("failed to read preset: %w", err)
+		}
+		if presetChecksum != "" && !strings.EqualFold(preset.Checksum(data), presetChecksum) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", source, presetChecksum, preset.Checksum(data))
+		}
+		if source, err = filepath.Abs(source); err != nil {
+			return fmt.Errorf("failed to resolve preset path: %w", err)
+		}
+	}
+
+	fallback := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	p, err := preset.Parse(data, strings.ToLower(fallback))
+	if err != nil {
+		return fmt.Errorf("invalid preset %s: %w", source, err)
+	}
+	if presetInstallName != "" {
+		if !preset.ValidName(presetInstallName) {
+			return fmt.Errorf("invalid preset name %q (lowercase letters, digits, dots, dashes and underscores)", presetInstallName)
+		}
+		p.Name = presetInstallName
+	}
+	if err := checkPresetSettings(p, convertCmd.Flags()); err != nil {
+		return err
+	}
+
+	registry, err := presetRegistry()
+	if err != nil {
+		return err
+	}
+	existing, _, err := registry.Get(p.Name)
+	switch {
+	case err == nil && existing.Source != source && !presetForce:
+		return fmt.Errorf("a preset called %s is already installed from %s; use --name to install this one under another name, or --force to replace it",
+			p.Name, presetSource(existing))
+	case err != nil && !errors.Is(err, preset.ErrNotInstalled) && !presetForce:
+		return err
+	}
+
+	installed, err := registry.Install(p, data, source)
+	if err != nil {
+		return err
+	}
+	verb := "Installed"
+	if existing != nil {
+		verb = "Updated"
+	}
+	console.Printf("✅ %s preset %s (sha256 %s)\n", verb, installed.Name, installed.SHA256[:12])
+	console.Printf("   Use it with: publify convert book.pdf -o book.epub --preset %s\n", installed.Name)
+	return nil
+}
+
+func runPresetList(cmd *cobra.Command, args []string) error {
+	registry, err := presetRegistry()
+	if err != nil {
+		return err
+	}
+	presets, err := registry.List()
+	if err != nil {
+		return err
+	}
+	if len(presets) == 0 {
+		console.Println("No presets installed (publify preset install <url>)")
+	}
+	for _, p := range presets {
+		console.Displayf("%-24s %s\n", p.Name, p.Description)
+	}
+
+	// Those publify comes with, unless one of the same name is installed
+	for _, name := range preset.BuiltinNames() {
+		if _, _, err := registry.Get(name); !errors.Is(err, preset.ErrNotInstalled) {
+			continue
+		}
+		p, _, err := preset.Builtin(name)
+		if err != nil {
+			return err
+		}
+		console.Displayf("%-24s %s (built in)\n", p.Name, p.Description)
+	}
+	return nil
+}
+
+func runPresetShow(cmd *cobra.Command, args []string) error {
+	registry, err := presetRegistry()
+	if err != nil {
+		return err
+	}
+	p, data, err := lookupPreset(registry, args[0])
+	if err != nil {
+		return err
+	}
+
+	console.Displayf("🎛️  %s\n", p.Name)
+	if p.Description != "" {
+		console.Displayf("   %s\n", p.Description)
+	}
+	console.Displayf("   Source:    %s\n", presetSource(p))
+	if p.SHA256 != "" {
+		console.Displayf("   SHA-256:   %s\n", p.SHA256)
+		console.Displayf("   Installed: %s\n", p.InstalledAt.Local().Format("2006-01-02 15:04"))
+	}
+	if p.Edited {
+		console.Displayf("   ⚠️  Edited since it was installed\n")
+	}
+	console.Displayf("\n%s", data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		console.Displayf("\n")
+	}
+	return nil
+}
+
+func runPresetRemove(cmd *cobra.Command, args []string) error {
+	registry, err := presetRegistry()
+	if err != nil {
+		return err
+	}
+	if err := registry.Remove(args[0]); err != nil {
+		return err
+	}
+	console.Printf("🗑️  Removed preset %s\n", args[0])
+	return nil
+}
+
+// lookupPreset finds a preset by name: an installed one, or else one publify comes with
+func lookupPreset(registry *preset.Registry, name string) (*preset.Installed, []byte, error) {
+	p, data, err := registry.Get(name)
+	if errors.Is(err, preset.ErrNotInstalled) {
+		if builtin, builtinData, err := preset.Builtin(name); err == nil {
+			return builtin, builtinData, nil
+		}
+	}
+	return p, data, err
+}
+
+// presetSource says where an installed preset came from
+func presetSource(p *preset.Installed) string {
+	if p.Builtin {
+		return "built into publify"
+	}
+	if p.Source == "" {
+		return "copied in by hand"
+	}
+	return p.Source
+}
+
+// checkPresetSettings makes sure a preset only sets the flags presets may, with values
+// they take. flags are publify convert's.
+func checkPresetSettings(p *preset.Preset, flags *pflag.FlagSet) error {
+	for _, name := range p.Flags() {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("preset %s sets --%s, which publify convert doesn't have", p.Name, name)
+		}
+		if !presetFlags[name] {
+			return fmt.Errorf("preset %s sets --%s, which presets can't; give it on the command line instead", p.Name, name)
+		}
+
+		values := p.Settings[name]
+		if len(values) > 1 && flag.Value.Type() != "stringArray" {
+			return fmt.Errorf("preset %s gives --%s more than once", p.Name, name)
+		}
+		for _, value := range values {
+			var err error
+			switch flag.Value.Type() {
+			case "bool":
+				_, err = strconv.ParseBool(value)
+			case "int":
+				_, err = strconv.Atoi(value)
+			case "float64":
+				_, err = strconv.ParseFloat(value, 64)
+			}
+			if err != nil {
+				return fmt.Errorf("preset %s: invalid %s %q for --%s", p.Name, flag.Value.Type(), value, name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyPreset sets the convert flags a preset gives, except those given on the command
+// line. The preset is an installed one, or a file.
+func applyPreset(cmd *cobra.Command, nameOrPath string) error {
+	var p *preset.Preset
+	if strings.ContainsAny(nameOrPath, `/\`) || filepath.Ext(nameOrPath) == ".yaml" || filepath.Ext(nameOrPath) == ".yml" {
+		data, err := os.ReadFile(nameOrPath)
+		if err != nil {
+			return fmt.Errorf("failed to read preset: %w", err)
+		}
+		fallback := strings.ToLower(strings.TrimSuffix(filepath.Base(nameOrPath), filepath.Ext(nameOrPath)))
+		if p, err = preset.Parse(data, fallback); err != nil {
+			return fmt.Errorf("invalid preset %s: %w", nameOrPath, err)
+		}
+	} else {
+		registry, err := presetRegistry()
+		if err != nil {
+			return err
+		}
+		installed, _, err := lookupPreset(registry, nameOrPath)
+		if errors.Is(err, preset.ErrNotInstalled) {
+			return fmt.Errorf("no preset called %s is installed (publify preset list shows those that are)", nameOrPath)
+		}
+		if err != nil {
+			return err
+		}
+		p = installed.Preset
+	}
+	if err := checkPresetSettings(p, cmd.Flags()); err != nil {
+		return err
+	}
+
+	for _, name := range p.Flags() {
+		if cmd.Flags().Changed(name) {
+			continue
+		}
+		for _, value := range p.Settings[name] {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("preset %s: invalid --%s: %w", p.Name, name, err)
+			}
+		}
+	}
+
+	if p.Description != "" {
+		console.Printf("🎛️  Using preset %s: %s\n", p.Name, p.Description)
+	} else {
+		console.Printf("🎛️  Using preset %s\n", p.Name)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/epubzip"
+	"github.com/alde/publify/internal/safefile"
+	"github.com/alde/publify/internal/xhtml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	putFrom   string
+	putBackup bool
+	putTidy   bool
+)
+
+var putCmd = &cobra.Command{
+	Use:   "put [epub file] [entry]",
+	Short: "Replace a single file inside an EPUB",
+	Long: `Replace one file inside an EPUB without extracting and recompressing the
+whole book. The other files are copied untouched and the mimetype entry stays
+first and uncompressed.
+
+The entry is given by its path in the archive, as shown by publify ls, or by its
+file name if only one entry has it. Use --from - to read the new content from stdin.
+
+Examples:
+  publify put book.epub OEBPS/styles.css --from fixed.css
+  publify put book.epub chapter1.xhtml --from chapter1.xhtml --backup --tidy
+  publify cat book.epub content.opf | sed 's/Old/New/' | publify put book.epub content.opf --from -`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPut,
+}
+
+func init() {
+	rootCmd.AddCommand(putCmd)
+
+	putCmd.Flags().StringVar(&putFrom, "from", "", "File with the new content, or - for stdin (required)")
+	putCmd.Flags().BoolVar(&putBackup, "backup", false, "Keep the previous EPUB as <file>.bak")
+	putCmd.Flags().BoolVar(&putTidy, "tidy", false, "Fix unclosed tags, bare ampersands and similar slips when the entry is XHTML")
+
+	putCmd.MarkFlagRequired("from")
+}
+
+func runPut(cmd *cobra.Command, args []string) error {
+	epubPath, entryName := args[0], args[1]
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	// Read everything before touching the book; stdin may well be a pipe from publify cat
+	content, err := readPutContent(putFrom)
+	if err != nil {
+		return err
+	}
+
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %w", err)
+	}
+	defer zipReader.Close()
+
+	entry, err := findEntry(zipReader.File, entryName)
+	if err != nil {
+		return err
+	}
+
+	if putTidy && xhtml.IsDocument(entry.Name) {
+		content = tidyDocument(entry.Name, content)
+	}
+
+	outputFile, err := safefile.Create(epubPath, putBackup)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outputFile.Abort()
+
+	err = epubzip.ReplaceEntries(&zipReader.Reader, outputFile, epubzip.Options{StoreCompressedMedia: true},
+		map[string][]byte{entry.Name: content})
+	if err != nil {
+		return fmt.Errorf("failed to replace %s: %w", entry.Name, err)
+	}
+
+	// The rename replaces the file we're reading from, so close it first (Windows insists)
+	zipReader.Close()
+	if err := outputFile.Commit(); err != nil {
+		return err
+	}
+
+	console.Printf("✅ Replaced %s in %s (%d bytes)\n", entry.Name, epubPath, len(content))
+	return nil
+}
+
+func readPutContent(from string) ([]byte, error) {
+	if from == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return content, nil
+	}
+
+	content, err := os.ReadFile(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replacement file: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/transfer"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	relayListen    string
+	relayURL       string
+	relayCert      string
+	relayKey       string
+	relayMaxSize   string
+	relayMaxStored string
+	relayMaxExpiry time.Duration
+)
+
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Run a relay that publify send can upload books to",
+	Long: `Run a relay for publify send: it keeps each uploaded book, encrypted, for a single
+download or until it expires, and serves the page that decrypts it in the reader's
+browser. Books are kept in memory and lost if the relay stops.
+
+publify send only uploads over https, and browsers only decrypt on https pages. Give
+--cert and --key to serve https directly, or serve plain http behind a proxy that
+terminates TLS and give the address readers reach it at with --url.
+
+Whoever runs the relay could read the books sent through it, since it serves the page
+that decrypts them.
+
+Examples:
+  publify relay --listen :8443 --cert relay.crt --key relay.key
+  publify relay --listen 127.0.0.1:8080 --url https://relay.example.org --max-size 50MB`,
+	Args: cobra.NoArgs,
+	RunE: runRelay,
+}
+
+func init() {
+	rootCmd.AddCommand(relayCmd)
+
+	relayCmd.Flags().StringVar(&relayListen, "listen", ":8443", "Address to listen on")
+	relayCmd.Flags().StringVar(&relayURL, "url", "", "Address readers reach the relay at, for its links (default: https:// and the host uploads come to)")
+	relayCmd.Flags().StringVar(&relayCert, "cert", "", "TLS certificate to serve https with")
+	relayCmd.Flags().StringVar(&relayKey, "key", "", "TLS private key for --cert")
+	relayCmd.Flags().StringVar(&relayMaxSize, "max-size", humanize.IBytes(transfer.DefaultRelayMaxSize), "Largest book taken")
+	relayCmd.Flags().StringVar(&relayMaxStored, "max-stored", humanize.IBytes(transfer.DefaultRelayMaxStored), "Most kept at once, all books together")
+	relayCmd.Flags().DurationVar(&relayMaxExpiry, "max-expiry", transfer.DefaultRelayMaxExpiry, "Longest a book is kept, whatever publify send asks for")
+}
+
+func runRelay(cmd *cobra.Command, args []string) error {
+	if (relayCert == "") != (relayKey == "") {
+		return fmt.Errorf("--cert and --key go together")
+	}
+	maxSize, err := humanize.ParseBytes(relayMaxSize)
+	if err != nil || maxSize == 0 {
+		return fmt.Errorf("invalid --max-size %q (e.g. 200MB)", relayMaxSize)
+	}
+	maxStored, err := humanize.ParseBytes(relayMaxStored)
+	if err != nil || maxStored == 0 {
+		return fmt.Errorf("invalid --max-stored %q (e.g. 2GB)", relayMaxStored)
+	}
+
+	server := &http.Server{
+		Addr: relayListen,
+		Handler: transfer.NewRelay(transfer.RelayOptions{
+			URL:       relayURL,
+			MaxSize:   int64(maxSize),
+			MaxStored: int64(maxStored),
+			MaxExpiry: relayMaxExpiry,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if relayCert != "" {
+			console.Printf("📮 Relay listening on https://%s\n", relayListen)
+			errs <- server.ListenAndServeTLS(relayCert, relayKey)
+		} else {
+			console.Printf("📮 Relay listening on http://%s, for a proxy serving it over https\n", relayListen)
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	select {
+	case err := <-errs:
+		return fmt.Errorf("relay stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	console.Println("Relay stopped")
+	return nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renameTemplate string
+	renameDryRun   bool
+	renameForce    bool
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename [epub files...]",
+	Short: "Rename EPUB files from their metadata",
+	Long: `Rename EPUB files using their metadata and a file name template.
+
+Placeholders: {title}, {author}, {series}, {series_index}, {publisher}, {language}.
+Several authors are joined with " & ". Files stay in their directory and keep
+their extension. Books missing a field used by the template are skipped.
+
+This is the inverse of --from-filename on the metadata and convert commands.
+
+Examples:
+  publify rename *.epub
+  publify rename book.epub --template "{series} {series_index} - {title}"
+  publify rename library/*.epub --dry-run`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runRename,
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+
+	renameCmd.Flags().StringVarP(&renameTemplate, "template", "t", metadata.DefaultFilenameTemplate, "File name template")
+	renameCmd.Flags().BoolVar(&renameDryRun, "dry-run", false, "Show the new names without renaming anything")
+	renameCmd.Flags().BoolVar(&renameForce, "force", false, "Overwrite files that already have the new name")
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	renamed, skipped := 0, 0
+	for _, epubPath := range args {
+		newPath, err := renamedPath(epubPath, renameTemplate)
+		if err != nil {
+			console.Printf("⚠️  Skipping %s: %v\n", epubPath, err)
+			skipped++
+			continue
+		}
+		if newPath == epubPath {
+			continue
+		}
+
+		if !renameForce {
+			if _, err := os.Stat(newPath); err == nil {
+				console.Printf("⚠️  Skipping %s: %s already exists (use --force to overwrite)\n", epubPath, filepath.Base(newPath))
+				skipped++
+				continue
+			}
+		}
+
+		console.Printf("📝 %s → %s\n", filepath.Base(epubPath), filepath.Base(newPath))
+		if renameDryRun {
+			continue
+		}
+		if err := os.Rename(epubPath, newPath); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", epubPath, err)
+		}
+		renamed++
+	}
+
+	if renameDryRun {
+		console.Println("🔍 Dry run, nothing was renamed")
+		return nil
+	}
+	console.Printf("✅ Renamed %d file(s)", renamed)
+	if skipped > 0 {
+		console.Printf(", skipped %d", skipped)
+	}
+	console.Println()
+
+	return nil
+}
+
+// renamedPath works out the new path of a book from its metadata
+func renamedPath(epubPath, template string) (string, error) {
+	if err := validateEPUBFile(epubPath); err != nil {
+		return "", err
+	}
+
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	meta, err := reader.GetMetadata()
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	name, err := metadata.FormatFilename(meta, template)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(epubPath), name+filepath.Ext(epubPath)), nil
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/reader"
+	"github.com/alde/publify/pkg/render"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	renderOutputDir string
+	renderReader    string
+	renderChapters  string
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render [epub file]",
+	Short: "Render chapters as page images, the way a reader would show them",
+	Long: `Render chapters of an EPUB as PNG page images at a reader's screen size and resolution,
+in greyscale for e-ink readers, to see what a conversion will look like without copying it
+to a device, or to keep snapshots to compare after changing settings.
+
+The renderer is built in and deliberately simple: it sets the text in its own fonts and
+understands the CSS publify itself writes (alignment, bold, italics, sizes, margins and
+hidden text), not everything a reader's engine would. Line breaks and page breaks will
+differ slightly from the device; layout, images and styling should not.
+
+Chapters are counted in reading order, so the cover and title page count too. Pages are
+written as chapter001-page001.png and so on.
+
+Examples:
+  publify render book.epub --reader kobo-bw -o pages/
+  publify render book.epub --reader kindle --chapters 2-5 -o pages/`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRender,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVarP(&renderOutputDir, "output", "o", "", "Directory to write the page images to (required)")
+	renderCmd.Flags().StringVar(&renderReader, "reader", "generic", "Reader to render for (kobo, kobo-bw, kindle, kindle-oasis, generic)")
+	renderCmd.Flags().StringVar(&renderChapters, "chapters", "1-3", "Chapters to render, e.g. \"1-3\" or \"2,5\"")
+
+	// Reader profiles are profiles, so --profile is understood too
+	renderCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "profile" {
+			name = "reader"
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	renderCmd.MarkFlagRequired("output")
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	epubPath := args[0]
+
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	profile, err := reader.GetProfile(renderReader)
+	if err != nil {
+		return fmt.Errorf("reader profile error: %w", err)
+	}
+
+	selection, err := converter.ParsePageRanges(renderChapters)
+	if err != nil {
+		return fmt.Errorf("invalid chapter selection: %w", err)
+	}
+
+	epub, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return err
+	}
+	defer epub.Close()
+
+	chapters, err := epub.GetChapterList()
+	if err != nil {
+		return err
+	}
+	selected := 0
+	for i := range chapters {
+		if selection.Contains(i + 1) {
+			selected++
+		}
+	}
+	if selected == 0 {
+		return fmt.Errorf("no chapters selected by %q, the book has %s", renderChapters, countOf(len(chapters), "chapter"))
+	}
+
+	renderer, err := render.New(render.OptionsFor(profile))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(renderOutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	console.Printf("🖼️  Rendering for %s (%dx%d, %d DPI)\n", profile.Name,
+		profile.Capabilities.ScreenWidth, profile.Capabilities.ScreenHeight, profile.Capabilities.DPI)
+
+	rendered, pageCount := 0, 0
+	for i, chapter := range chapters {
+		number := i + 1
+		if !selection.Contains(number) {
+			continue
+		}
+
+		document, err := epub.ReadContent(chapter.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read chapter %d: %w", number, err)
+		}
+		load := func(src string) ([]byte, error) {
+			return epub.ReadContent(render.Resolve(chapter.Path, src))
+		}
+
+		pages, err := renderer.RenderChapter(document, load)
+		if err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", number, err)
+		}
+
+		for j, page := range pages {
+			if err := writePNG(filepath.Join(renderOutputDir, render.PageFileName(number, j+1)), page); err != nil {
+				return err
+			}
+		}
+		console.Printf("   %3d. %-40s %s\n", number, truncateText(chapter.Path, 40), countOf(len(pages), "page"))
+		rendered++
+		pageCount += len(pages)
+	}
+
+	console.Printf("✅ Rendered %s from %s to %s\n", countOf(pageCount, "page"), countOf(rendered, "chapter"), renderOutputDir)
+	return nil
+}
+
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// countOf formats a count with its noun, "1 page" or "3 pages"
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/safefile"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	repairOutput string
+	repairBackup bool
+	repairDryRun bool
+)
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [epub file]",
+	Short: "Fix common structural problems in an EPUB",
+	Long: `Fix the structural problems that most often stop e-readers from opening a book:
+
+  - a missing or broken mimetype entry, or one that isn't first and uncompressed
+  - a missing META-INF/container.xml (when the package document can be found)
+  - manifest entries pointing to files that aren't in the archive
+  - a missing EPUB 3 navigation document, rebuilt from the reading order
+
+The book is repaired in place unless --output is given. Content files are copied
+as they are.
+
+Examples:
+  publify repair book.epub
+  publify repair book.epub --dry-run
+  publify repair broken.epub -o fixed.epub
+  publify repair book.epub --backup`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRepair,
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+
+	repairCmd.Flags().StringVarP(&repairOutput, "output", "o", "", "Write the repaired book here instead of replacing the original")
+	repairCmd.Flags().BoolVar(&repairBackup, "backup", false, "Keep the original as <file>.bak when repairing in place")
+	repairCmd.Flags().BoolVar(&repairDryRun, "dry-run", false, "Only list the problems, don't change anything")
+}
+
+func runRepair(cmd *cobra.Command, args []string) error {
+	epubPath := args[0]
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	outputPath := epubPath
+	if repairOutput != "" {
+		if err := validateOutputPath(repairOutput); err != nil {
+			return fmt.Errorf("output validation failed: %w", err)
+		}
+		outputPath = repairOutput
+	}
+
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %w", err)
+	}
+	defer zipReader.Close()
+
+	if repairDryRun {
+		fixes, err := metadata.RepairEPUB(&zipReader.Reader, io.Discard)
+		if err != nil {
+			return fmt.Errorf("failed to repair EPUB: %w", err)
+		}
+		printRepairs(fixes, "🔍 Would fix")
+		return nil
+	}
+
+	outputFile, err := safefile.Create(outputPath, repairBackup)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outputFile.Abort()
+
+	fixes, err := metadata.RepairEPUB(&zipReader.Reader, outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to repair EPUB: %w", err)
+	}
+	if len(fixes) == 0 {
+		printRepairs(nil, "")
+		return nil
+	}
+
+	zipReader.Close()
+	if err := outputFile.Commit(); err != nil {
+		return err
+	}
+
+	printRepairs(fixes, "🔧 Fixed")
+	console.Printf("✅ Repaired book written to %s\n", outputPath)
+	return nil
+}
+
+func printRepairs(fixes []string, heading string) {
+	if len(fixes) == 0 {
+		console.Println("✅ Nothing to repair")
+		return
+	}
+
+	console.Printf("%s %d problem(s):\n", heading, len(fixes))
+	for _, fix := range fixes {
+		console.Printf("  • %s\n", fix)
+	}
+}
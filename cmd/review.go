@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+)
+
+const reviewHelp = `Commands:
+  l                 list the chapters
+  m N               merge chapter N with the next one
+  s PAGE            start a new chapter at PAGE
+  r N [TITLE]       rename chapter N (no title goes back to "Chapter N")
+  d PAGES           drop pages, e.g. "d 3" or "d 1-4,9"
+  done              generate the EPUB with these chapters
+  q                 cancel the conversion
+`
+
+// reviewChapters is the converter's review hook: it shows the proposed chapters and
+// applies the user's edits until they're done
+func reviewChapters(review *converter.ChapterReview) error {
+	input := bufio.NewReader(os.Stdin)
+
+	console.Displayf("\n📚 Chapter review\n")
+	printReview(review)
+	console.Displayf(reviewHelp)
+
+	for {
+		console.Displayf("\nreview> ")
+		line, err := input.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return fmt.Errorf("chapter review cancelled")
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "done", "g":
+			return nil
+		case "q", "quit":
+			return fmt.Errorf("chapter review cancelled")
+		case "?", "h", "help":
+			console.Displayf(reviewHelp)
+			continue
+		case "l", "list":
+			printReview(review)
+			continue
+		}
+
+		if err := applyReviewCommand(review, fields); err != nil {
+			console.Displayf("⚠️  %v\n", err)
+			continue
+		}
+		printReview(review)
+	}
+}
+
+// applyReviewCommand applies one editing command
+func applyReviewCommand(review *converter.ChapterReview, fields []string) error {
+	switch fields[0] {
+	case "m", "merge":
+		n, err := numberArgument(fields, "chapter")
+		if err != nil {
+			return err
+		}
+		return review.Merge(n)
+
+	case "s", "split":
+		page, err := numberArgument(fields, "page")
+		if err != nil {
+			return err
+		}
+		return review.Split(page)
+
+	case "r", "rename":
+		n, err := numberArgument(fields, "chapter")
+		if err != nil {
+			return err
+		}
+		return review.Rename(n, strings.Join(fields[2:], " "))
+
+	case "d", "drop":
+		if len(fields) < 2 {
+			return fmt.Errorf("%s needs the pages to drop", fields[0])
+		}
+		pages, err := converter.ParsePageRanges(strings.Join(fields[1:], ""))
+		if err != nil {
+			return err
+		}
+		for _, r := range pages.GetRanges() {
+			for page := r.Start; page <= r.End; page++ {
+				if err := review.Drop(page); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q (? for help)", fields[0])
+}
+
+// numberArgument reads the chapter or page number a command starts with
+func numberArgument(fields []string, what string) (int, error) {
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("%s needs a %s number", fields[0], what)
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s number: %s", what, fields[1])
+	}
+	return n, nil
+}
+
+func printReview(review *converter.ChapterReview) {
+	console.Displayf("\n")
+	for i, chapter := range review.Chapters() {
+		pages := fmt.Sprintf("p. %d", chapter.FirstPage)
+		if chapter.LastPage != chapter.FirstPage {
+			pages = fmt.Sprintf("pp. %d-%d", chapter.FirstPage, chapter.LastPage)
+		}
+		console.Displayf("%3d. %-30s %-14s %s\n", i+1, truncateText(chapter.Title, 30), pages, chapter.Opening)
+	}
+	if dropped := review.Dropped(); len(dropped) > 0 {
+		console.Displayf("🗑️  Dropped pages: %v\n", dropped)
+	}
+}
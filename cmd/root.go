@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/logging"
+	"github.com/alde/publify/pkg/progress"
 	"github.com/spf13/cobra"
 )
 
+// version is set by release builds with -ldflags "-X github.com/alde/publify/cmd.version=..."
+var version = "0.1.0"
+
 var rootCmd = &cobra.Command{
 	Use:   "publify",
 	Short: "Convert documents between formats for e-readers",
@@ -16,17 +22,129 @@ optimized for e-readers like Kobo, Kindle, and others.
 Currently supports:
 - PDF to EPUB conversion with reader-specific optimizations
 - Metadata editing for EPUB files
-- EPUB extraction and compression for manual editing workflows`,
-	Version: "0.1.0",
+- EPUB extraction and compression for manual editing workflows
+
+Run without a terminal, as in a job container, or with PUBLIFY_HEADLESS=1, publify is
+headless: progress is written to stdout as JSON lines instead of being drawn, and nothing
+is asked (an ISBN match is taken as found, a title search isn't guessed at, and --review
+is refused). PUBLIFY_HEADLESS=0 turns it off. Temporary files go in PUBLIFY_TMPDIR if it's
+set; headless, the working directory is used when the system temp directory is read-only.
+
+Diagnostics are logged to stderr, warnings and errors only unless --log-level says
+otherwise: -v logs info, -vv debug and -vvv everything, with the file and line each
+record comes from. --debug converter,ocr,epub turns on debug logging for those parts of
+publify only (converter, pdf, ocr, image, epub or progress), to follow OCR without the
+image pipeline's logs. --log-json writes them as JSON lines.
+
+--progress json writes progress as JSON lines (NDJSON) to stderr, or to --progress-file,
+for a GUI or CI wrapper to follow: a "progress" event as each page finishes, with the
+percentage and time remaining, and a "done" event with each worker's statistics.
+--progress none shows no live progress at all.
+
+--quiet prints errors only: no progress, summaries or confirmations, though what a command
+was asked to show (metadata, listings, questions) still is. --plain, or --no-emoji, keeps
+to ASCII, for scripts and for terminals and locales that draw emoji and box-drawing
+characters as boxes; titles and other text from the books are left as they are.`,
+	Version: version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		console.Setup(quiet, plain)
+		if err := setupLogging(cmd); err != nil {
+			return err
+		}
+		if err := setupProgress(); err != nil {
+			return err
+		}
+		return configureTempDir()
+	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if progressOutput != nil {
+		progressOutput.Close()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+var (
+	logLevel     string
+	logJSON      bool
+	verbosity    int    // How many times -v was given
+	debugModules string // Parts of publify to log debugging for
+
+	quiet bool
+	plain bool
+
+	progressFormat string
+	progressFile   string
+	progressOutput *os.File // The --progress-file, closed on exit
+)
+
 func init() {
-	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Say more: -v logs info, -vv debug, -vvv everything")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print errors only, and what the command was asked to show")
+	rootCmd.PersistentFlags().BoolVar(&plain, "plain", false, "Print ASCII only: no emoji, box drawing or arrows")
+	rootCmd.PersistentFlags().BoolVar(&plain, "no-emoji", false, "Same as --plain")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", logging.DefaultLevel, "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().BoolVar(&logJSON, "log-json", false, "Log as JSON lines")
+	rootCmd.PersistentFlags().StringVar(&debugModules, "debug", "", "Log debugging for these parts of publify only (e.g., \"ocr,epub\")")
+	rootCmd.PersistentFlags().StringVar(&progressFormat, "progress", progress.FormatAuto, "Progress display: auto, json or none")
+	rootCmd.PersistentFlags().StringVar(&progressFile, "progress-file", "", "Write JSON progress events to this file instead of stderr (implies --progress json)")
+}
+
+// setupLogging sends diagnostics to stderr at the level asked for. -v, -vv and -vvv mean
+// info, debug and trace, and --quiet errors only, unless a level was given as well.
+// Modules asked for with --debug are logged down to debug, or trace with -vvv.
+func setupLogging(cmd *cobra.Command) error {
+	modules, err := logging.ParseModules(debugModules)
+	if err != nil {
+		return err
+	}
+
+	opts := logging.Options{
+		Level:  logLevel,
+		JSON:   logJSON,
+		Source: verbosity >= 3,
+		Debug:  modules,
+	}
+	if verbosity >= 3 {
+		opts.DebugLevel = "trace"
+	}
+	if !cmd.Flags().Changed("log-level") {
+		if verbosity > 0 {
+			opts.Level = logging.VerbosityLevel(verbosity)
+		} else if quiet {
+			opts.Level = "error"
+		}
+	}
+	return logging.Setup(os.Stderr, opts)
+}
+
+// setupProgress picks how progress is shown. JSON events go to stderr, so they don't mix
+// with the summary on stdout, or to the --progress-file.
+func setupProgress() error {
+	if progressFile == "" {
+		// --quiet draws no progress, but JSON asked for is for programs and still goes out
+		switch {
+		case progressFormat == progress.FormatJSON:
+			return progress.SetFormat(progressFormat, os.Stderr)
+		case quiet:
+			return progress.SetFormat(progress.FormatNone, nil)
+		}
+		return progress.SetFormat(progressFormat, nil)
+	}
+
+	if progressFormat != progress.FormatAuto && progressFormat != progress.FormatJSON {
+		return fmt.Errorf("--progress-file writes JSON progress, it can't be combined with --progress %s", progressFormat)
+	}
+	file, err := os.Create(progressFile)
+	if err != nil {
+		return fmt.Errorf("failed to create progress file: %w", err)
+	}
+	progressOutput = file
+	return progress.SetFormat(progress.FormatJSON, file)
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/selfupdate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateChannel string
+	updateCheck   bool
+	updateForce   bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update publify to the latest release",
+	Long: `Download the latest release of publify and replace this binary with it.
+
+The release's checksums are checked against a signature made with the key built
+into this binary, and the download against its checksum, before anything is
+replaced. Builds from source have no key and can't update themselves.
+
+--channel stable (the default) only takes releases; prerelease also takes release
+candidates and betas.
+
+Examples:
+  publify self-update
+  publify self-update --check
+  publify self-update --channel prerelease`,
+	Args: cobra.NoArgs,
+	RunE: runSelfUpdate,
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+
+	selfUpdateCmd.Flags().StringVar(&updateChannel, "channel", selfupdate.ChannelStable, "Release channel: stable or prerelease")
+	selfUpdateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only say whether there's a newer release")
+	selfUpdateCmd.Flags().BoolVar(&updateForce, "force", false, "Install the latest release even if it isn't newer")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	if err := selfupdate.ValidateChannel(updateChannel); err != nil {
+		return err
+	}
+	updater, err := selfupdate.New()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	release, err := updater.Latest(ctx, updateChannel)
+	if err != nil {
+		return err
+	}
+
+	current := rootCmd.Version
+	if selfupdate.CompareVersions(release.Version, current) <= 0 && !updateForce {
+		console.Displayf("✅ publify %s is up to date (latest %s release: %s)\n", current, updateChannel, release.Version)
+		return nil
+	}
+	if updateCheck {
+		console.Displayf("⬆️  publify %s is available (this is %s); run publify self-update to install it\n", release.Version, current)
+		return nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the publify binary: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to find the publify binary: %w", err)
+	}
+
+	console.Printf("📥 Downloading publify %s...\n", release.Version)
+	binary, err := updater.Download(ctx, release)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.Replace(executable, binary); err != nil {
+		return err
+	}
+
+	console.Printf("✅ Updated %s from %s to %s\n", executable, current, release.Version)
+	return nil
+}
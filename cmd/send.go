@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/progress"
+	"github.com/alde/publify/pkg/transfer"
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+)
+
+// relayEnv names the relay to send books through, as --relay does
+const relayEnv = "PUBLIFY_RELAY"
+
+// sendTimeout bounds uploading a book to the relay
+const sendTimeout = 5 * time.Minute
+
+var (
+	sendRelay   string
+	sendExpires time.Duration
+	sendQR      bool
+	sendPort    int
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send [epub file]",
+	Short: "Send a book to a reader or tablet through a download link",
+	Long: `Send a book to an e-reader, tablet or phone without cables or email, through a
+download link publify prints with a QR code to scan with the reader's browser.
+
+With --qr the book is served from this machine to devices on the same network, until
+it has been downloaded once (or --expires passes, or Ctrl-C). The link has a random
+token in it, so only whoever sees it finds the book.
+
+Otherwise the book is encrypted on this machine and uploaded to a relay, which keeps
+it for a single download. The key is only in the link, after the #, which browsers
+don't send, so the relay doesn't store the book in the clear. This isn't end-to-end
+encryption: the relay also serves the page that decrypts the book, so whoever runs it
+could read it. Only send through a relay whose operator you trust, such as one you run
+yourself with publify relay. Give the relay with --relay or PUBLIFY_RELAY.
+
+Examples:
+  publify send book.epub --qr
+  publify send book.epub --qr --port 8080
+  publify send book.epub --relay https://relay.example.org
+  PUBLIFY_RELAY=https://relay.example.org publify send book.epub --expires 2h`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSend,
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+
+	sendCmd.Flags().StringVar(&sendRelay, "relay", "", "Relay to send the book through (default: $"+relayEnv+")")
+	sendCmd.Flags().DurationVar(&sendExpires, "expires", transfer.DefaultExpiry, "How long a book nobody has downloaded is kept")
+	sendCmd.Flags().BoolVar(&sendQR, "qr", false, "Serve the book from this machine on the local network instead of a relay")
+	sendCmd.Flags().IntVar(&sendPort, "port", 0, "Port to serve the book on with --qr (0 = any free one)")
+}
+
+func runSend(cmd *cobra.Command, args []string) error {
+	epubPath := args[0]
+	if err := validateEPUBFile(epubPath); err != nil {
+		return fmt.Errorf("EPUB validation failed: %w", err)
+	}
+
+	if sendQR && sendRelay != "" {
+		return fmt.Errorf("--qr serves the book from this machine, it can't be combined with --relay")
+	}
+
+	data, err := os.ReadFile(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to read EPUB file: %w", err)
+	}
+	if sendQR {
+		return shareLocally(filepath.Base(epubPath), data)
+	}
+
+	relay := sendRelay
+	if relay == "" {
+		relay = os.Getenv(relayEnv)
+	}
+	if relay == "" {
+		return fmt.Errorf("no relay to send the book through: give one with --relay or set %s, or use --qr on the local network", relayEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	console.Printf("🔒 Encrypting and uploading %s...\n", filepath.Base(epubPath))
+	upload, err := transfer.SendToRelay(ctx, &http.Client{}, relay, filepath.Base(epubPath), data, sendExpires)
+	if err != nil {
+		return err
+	}
+
+	console.Printf("✅ Ready for one download until %s\n", upload.Expires.Local().Format("2006-01-02 15:04"))
+	console.Displayf("%s\n", upload.Link)
+	if !progress.Headless() {
+		if err := printQRCode(upload.Link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shareLocally serves a book on the local network until it's downloaded
+func shareLocally(name string, data []byte) error {
+	host, err := transfer.LANAddress()
+	if err != nil {
+		return err
+	}
+	share, err := transfer.ShareLocally(host, sendPort, name, data)
+	if err != nil {
+		return err
+	}
+
+	console.Printf("📡 Serving %s on the local network; open the link on the reader, or scan the code\n", name)
+	console.Displayf("%s\n", share.URL)
+	if !progress.Headless() {
+		if err := printQRCode(share.URL); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, sendExpires)
+	defer cancel()
+	err = share.Wait(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("nobody downloaded %s in %s", name, sendExpires)
+	case err != nil:
+		console.Println("Stopped without a download")
+		return nil
+	}
+	console.Printf("✅ %s downloaded\n", name)
+	return nil
+}
+
+// printQRCode draws a QR code for a link in the terminal, two rows to a line
+func printQRCode(link string) error {
+	code, err := qrcode.New(link, qrcode.Low)
+	if err != nil {
+		return fmt.Errorf("failed to make QR code: %w", err)
+	}
+	console.Displayf("\n%s", code.ToSmallString(false))
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/metadata"
+)
+
+// exportSidecar writes the book's metadata to a sidecar file. The cover image is saved
+// next to it, so the record can be applied to another file as a whole.
+func exportSidecar(epubPath, sidecarPath string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	meta, err := reader.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	sidecar := metadata.NewSidecar(meta)
+
+	if cover, ext, err := reader.ReadCover(); err == nil {
+		base := strings.TrimSuffix(sidecarPath, filepath.Ext(sidecarPath))
+		coverPath := base + ".cover" + ext
+		if err := os.WriteFile(coverPath, cover, 0644); err != nil {
+			return fmt.Errorf("failed to write cover image: %w", err)
+		}
+		sidecar.Cover = filepath.Base(coverPath)
+	}
+
+	if err := metadata.WriteSidecar(sidecarPath, sidecar); err != nil {
+		return err
+	}
+
+	console.Printf("✅ Exported metadata to %s\n", sidecarPath)
+	if sidecar.Cover != "" {
+		console.Printf("📸 Cover saved as %s\n", sidecar.Cover)
+	}
+
+	return nil
+}
+
+// importSidecar fills in the metadata flags from a sidecar file. Flags given on the
+// command line win, so a sidecar can serve as a template with per-book tweaks.
+func importSidecar(sidecarPath string) error {
+	sidecar, err := metadata.ReadSidecar(sidecarPath)
+	if err != nil {
+		return err
+	}
+
+	applySidecar(sidecar)
+	return nil
+}
+
+// applySidecar fills the metadata flags that are still empty
+func applySidecar(sidecar metadata.Sidecar) {
+	fields := []struct {
+		target *string
+		value  string
+	}{
+		{&metaTitle, sidecar.Title},
+		{&metaDescription, sidecar.Description},
+		{&metaLanguage, sidecar.Language},
+		{&metaPublisher, sidecar.Publisher},
+		{&metaSeries, sidecar.Series},
+		{&metaRights, sidecar.Rights},
+		{&metaCover, sidecar.Cover},
+	}
+	for _, field := range fields {
+		if *field.target == "" {
+			*field.target = field.value
+		}
+	}
+
+	if metaSeriesIndex == "" && sidecar.SeriesIndex > 0 {
+		metaSeriesIndex = strconv.FormatFloat(sidecar.SeriesIndex, 'f', -1, 64)
+	}
+	if len(metaSubjects) == 0 {
+		metaSubjects = sidecar.Subjects
+	}
+	if len(metaAuthors) == 0 {
+		metaAuthors = contributorFlags(sidecar.Authors)
+	}
+	if len(metaContributor) == 0 {
+		metaContributor = contributorFlags(sidecar.Contributors)
+	}
+}
+
+// contributorFlags turns people back into "Name:role" flag values
+func contributorFlags(people []metadata.Contributor) []string {
+	var values []string
+	for _, person := range people {
+		if person.Role == "" {
+			values = append(values, person.Name)
+			continue
+		}
+		values = append(values, person.Name+":"+person.Role)
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/converter"
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates [directory]",
+	Short: "Write the built-in markup templates to a directory for customizing",
+	Long: `Write the built-in templates for generated markup to a directory, as a starting
+point for your own. Edit them, delete the ones you don't want to change, and pass
+the directory to convert with --templates.
+
+Templates use Go's html/template syntax (https://pkg.go.dev/html/template):
+
+  chapter.html.tmpl    Body of each chapter part: .Title, .ShowTitle, .Part, .Content
+  titlepage.html.tmpl  Body of the title page: .Title, .Author, .Publisher
+  colophon.html.tmpl   Body of the colophon: .Summary, .Settings
+  cover.xhtml.tmpl     The whole cover document, without the <?xml?> declaration:
+                       .Language, .Title, .ViewportWidth, .ViewportHeight,
+                       .ImageWidth, .ImageHeight, .ImagePath
+  fixedpage.xhtml.tmpl Each fixed-layout image page (--fixed-layout), whole:
+                       the cover's fields and .Alt, .PageBreak, .TextLayer
+
+Examples:
+  publify templates my-templates/
+  publify convert book.pdf -o book.epub --templates my-templates/`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTemplates,
+}
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+}
+
+func runTemplates(cmd *cobra.Command, args []string) error {
+	dir := args[0]
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create template directory: %w", err)
+	}
+
+	// Check first so an existing set is never half overwritten
+	for _, name := range converter.TemplateNames() {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("%s already exists in %s, not overwriting your templates", name, dir)
+		}
+	}
+
+	for _, name := range converter.TemplateNames() {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(converter.DefaultTemplate(name)), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		console.Printf("  ✓ %s\n", name)
+	}
+
+	console.Printf("✅ Templates written to %s\n", dir)
+	return nil
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/pkg/metadata"
+)
+
+const tocHelp = `Commands:
+  l                 list the entries
+  r N TITLE         rename entry N
+  m N TO            move entry N, with the entries under it, before entry TO
+  i N               indent entry N under the entry above it
+  o N               outdent entry N one level
+  d N               delete entry N (the entries under it move up a level)
+  a N HREF TITLE    add an entry linking to HREF after entry N (0 = at the start)
+  c                 list the book's documents, for the hrefs a takes
+  spine             start over with one entry per document, in reading order
+  done              save the table of contents
+  q                 leave the table of contents as it was
+`
+
+// newTOC works out the table of contents the flags ask for: the one in --toc-from, edited
+// with --edit-toc if that's given too (starting from the book's own without --toc-from).
+// Returns nil when there's nothing to change.
+func newTOC(epubPath string) ([]metadata.Chapter, error) {
+	var toc []metadata.Chapter
+	if metaTOCFrom != "" {
+		var err error
+		if toc, err = metadata.ReadTOCFile(metaTOCFrom); err != nil {
+			return nil, err
+		}
+	}
+	if !metaEditTOC {
+		return toc, nil
+	}
+
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	chapters, err := reader.GetChapterList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chapters: %w", err)
+	}
+	if toc == nil {
+		// A book without a table of contents starts from its documents
+		if toc, err = reader.GetTableOfContents(); err != nil {
+			console.Printf("⚠️  %v, starting from the book's documents\n", err)
+			toc = spineTOC(chapters)
+		}
+	}
+
+	return editTOC(metadata.NewTOCEdit(toc), reader, chapters)
+}
+
+// editTOC shows the table of contents and applies the user's edits until they're done.
+// Returns nil if they leave it as it was.
+func editTOC(edit *metadata.TOCEdit, reader *metadata.EPUBReader, chapters []metadata.Chapter) ([]metadata.Chapter, error) {
+	input := bufio.NewReader(os.Stdin)
+
+	console.Displayf("\n📑 Table of contents\n")
+	printTOC(edit)
+	console.Displayf(tocHelp)
+
+	for {
+		console.Displayf("\ntoc> ")
+		line, err := input.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return nil, nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "done":
+			return edit.TOC(), nil
+		case "q", "quit":
+			console.Displayf("Leaving the table of contents as it was\n")
+			return nil, nil
+		case "?", "h", "help":
+			console.Displayf(tocHelp)
+			continue
+		case "l", "list":
+			printTOC(edit)
+			continue
+		case "c", "chapters":
+			for _, chapter := range chapters {
+				console.Displayf("  %-40s %s\n", chapter.Path, chapter.Title)
+			}
+			continue
+		case "spine":
+			edit = metadata.NewTOCEdit(spineTOC(chapters))
+			printTOC(edit)
+			continue
+		}
+
+		if err := applyTOCCommand(edit, reader, fields); err != nil {
+			console.Displayf("⚠️  %v\n", err)
+			continue
+		}
+		printTOC(edit)
+	}
+}
+
+// applyTOCCommand applies one editing command
+func applyTOCCommand(edit *metadata.TOCEdit, reader *metadata.EPUBReader, fields []string) error {
+	n, err := numberArgument(fields, "entry")
+	if err != nil {
+		return err
+	}
+
+	switch fields[0] {
+	case "r", "rename":
+		return edit.Rename(n, strings.Join(fields[2:], " "))
+
+	case "m", "move":
+		if len(fields) < 3 {
+			return fmt.Errorf("%s needs the entry to move before", fields[0])
+		}
+		to, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("invalid entry number: %s", fields[2])
+		}
+		return edit.Move(n, to)
+
+	case "i", "indent":
+		return edit.Indent(n)
+
+	case "o", "outdent":
+		return edit.Outdent(n)
+
+	case "d", "delete":
+		return edit.Delete(n)
+
+	case "a", "add":
+		if len(fields) < 4 {
+			return fmt.Errorf("%s needs an href and a title", fields[0])
+		}
+		href, _, _ := strings.Cut(fields[2], "#")
+		if _, err := reader.ReadContent(href); err != nil {
+			return fmt.Errorf("%s isn't in the book (c lists its documents)", href)
+		}
+		return edit.Add(n, strings.Join(fields[3:], " "), fields[2])
+	}
+
+	return fmt.Errorf("unknown command %q (? for help)", fields[0])
+}
+
+// spineTOC makes a table of contents with one entry per chapter
+func spineTOC(chapters []metadata.Chapter) []metadata.Chapter {
+	toc := make([]metadata.Chapter, len(chapters))
+	for i, chapter := range chapters {
+		toc[i] = metadata.Chapter{ID: chapter.ID, Title: chapter.Title, Path: chapter.Path}
+	}
+	return toc
+}
+
+func printTOC(edit *metadata.TOCEdit) {
+	console.Displayf("\n")
+	for i, entry := range edit.Entries() {
+		href := entry.Path
+		if entry.Fragment != "" {
+			href += "#" + entry.Fragment
+		}
+		title := strings.Repeat("  ", entry.Depth) + entry.Title
+		console.Displayf("%3d. %-50s %s\n", i+1, truncateText(title, 50), href)
+	}
+}
+
+// exportTOC writes the book's table of contents to a file for editing
+func exportTOC(epubPath, tocPath string) error {
+	reader, err := metadata.NewEPUBReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	toc, err := reader.GetTableOfContents()
+	if err != nil {
+		chapters, chaptersErr := reader.GetChapterList()
+		if chaptersErr != nil {
+			return fmt.Errorf("failed to read table of contents: %w", err)
+		}
+		console.Printf("⚠️  %v, exporting the book's documents instead\n", err)
+		toc = spineTOC(chapters)
+	}
+
+	if err := metadata.WriteTOCFile(tocPath, toc); err != nil {
+		return err
+	}
+	console.Printf("📑 Table of contents saved as %s (apply it with --toc-from)\n", tocPath)
+	return nil
+}
@@ -0,0 +1,122 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	quiet bool
+	plain bool
+
+	output = io.Writer(os.Stdout)
+)
+
+// Setup sets how messages are printed. Quiet drops everything but what a command was asked
+// to show; plain spells emoji, box drawing and arrows in ASCII, for scripts and for
+// terminals and locales that draw them as boxes.
+func Setup(quietOutput, plainOutput bool) {
+	quiet, plain = quietOutput, plainOutput
+}
+
+// Quiet reports whether only errors are to be shown
+func Quiet() bool {
+	return quiet
+}
+
+// Plain reports whether output is to be kept to ASCII decoration
+func Plain() bool {
+	return plain
+}
+
+// Printf prints a status message, such as "✅ Set title: Mort", unless quiet
+func Printf(format string, args ...any) {
+	if quiet {
+		return
+	}
+	Displayf(format, args...)
+}
+
+// Println prints a status message unless quiet, like fmt.Println
+func Println(args ...any) {
+	if quiet {
+		return
+	}
+	if plain {
+		for i, arg := range args {
+			if text, ok := arg.(string); ok {
+				args[i] = Plainify(text)
+			}
+		}
+	}
+	fmt.Fprintln(output, args...)
+}
+
+// Displayf prints what the command was asked for, or a question that needs an answer,
+// even when quiet. Only the format is made plain; the values printed are left as they
+// are, so a title in Japanese stays in Japanese.
+func Displayf(format string, args ...any) {
+	if plain {
+		format = Plainify(format)
+	}
+	fmt.Fprintf(output, format, args...)
+}
+
+// plainSymbols are the ASCII spellings of decoration that isn't an emoji
+var plainSymbols = map[rune]string{
+	'━': "-", '─': "-", '═': "=", '│': "|",
+	'█': "#", '░': "-",
+	'→': "->", '←': "<-",
+	'•': "-", '✓': "-", '…': "...",
+}
+
+// plainEmoji are the emoji that say something worth keeping in words; the rest are
+// decoration and are dropped
+var plainEmoji = map[rune]string{
+	'⚠': "Warning:",
+}
+
+// Plainify spells decoration in ASCII and drops emoji, along with the spaces that set
+// them apart from the text
+func Plainify(text string) string {
+	runes := []rune(text)
+	var result strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if symbol, ok := plainSymbols[r]; ok {
+			result.WriteString(symbol)
+			continue
+		}
+		if !isEmoji(r) && !(i+1 < len(runes) && runes[i+1] == '\uFE0F') {
+			result.WriteRune(r)
+			continue
+		}
+
+		// Skip the variation selector and joiners that make up the emoji, and the spaces after it
+		for i+1 < len(runes) && (runes[i+1] == '\uFE0F' || runes[i+1] == '\u200D' || isEmoji(runes[i+1])) {
+			i++
+		}
+		for i+1 < len(runes) && runes[i+1] == ' ' {
+			i++
+		}
+		if word, ok := plainEmoji[r]; ok {
+			result.WriteString(word + " ")
+		}
+	}
+	return result.String()
+}
+
+// isEmoji reports whether r is in one of the blocks emoji come from
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // Pictographs, emoticons, transport, supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // Arrows and shapes, such as ⬆️
+		return true
+	}
+	return false
+}
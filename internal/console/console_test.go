@@ -0,0 +1,54 @@
+package console
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestPlainify(t *testing.T) {
+	tests := map[string]string{
+		"✅ Set title: %s\n":                "Set title: %s\n",
+		"✍️  Author:      %s\n":            "Author:      %s\n",
+		"©️  Rights:      %s\n":            "Rights:      %s\n",
+		"⚠️  Couldn't tidy %s\n":           "Warning: Couldn't tidy %s\n",
+		"📝 %s → %s\n":                      "%s -> %s\n",
+		"  • %s\n":                         "  - %s\n",
+		"━━━━\n":                           "----\n",
+		"[████░░]":                         "[####--]",
+		"⬆️  publify %s is available":      "publify %s is available",
+		"Plain text, Ångström and 漢字 stay": "Plain text, Ångström and 漢字 stay",
+	}
+	for text, expected := range tests {
+		if result := Plainify(text); result != expected {
+			t.Errorf("Plainify(%q) = %q, expected %q", text, result, expected)
+		}
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	defer func(w io.Writer) { output = w }(output)
+	defer Setup(false, false)
+
+	tests := []struct {
+		name         string
+		quiet, plain bool
+		expected     string
+	}{
+		{"normal", false, false, "✅ Set title: 📖 Mort\nMort\n"},
+		{"plain", false, true, "Set title: 📖 Mort\nMort\n"},
+		{"quiet", true, false, "Mort\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		output = &buf
+		Setup(test.quiet, test.plain)
+
+		Printf("✅ Set title: %s\n", "📖 Mort")
+		Displayf("%s\n", "Mort")
+
+		if buf.String() != test.expected {
+			t.Errorf("%s: printed %q, expected %q", test.name, buf.String(), test.expected)
+		}
+	}
+}
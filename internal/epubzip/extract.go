@@ -0,0 +1,47 @@
+package epubzip
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// EntryPath returns where an archive entry goes when extracted into dir, refusing names
+// that would land outside it: absolute paths, drive letters and ../ components. Zip names
+// use forward slashes, but backslashes count as separators too, since Windows tools have
+// been known to write them.
+func EntryPath(dir, name string) (string, error) {
+	clean := strings.ReplaceAll(name, `\`, "/")
+	if clean == "" || path.IsAbs(clean) || hasDriveLetter(clean) {
+		return "", fmt.Errorf("refusing to extract %q: absolute path", name)
+	}
+	clean = path.Clean(clean)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("refusing to extract %q: outside the destination", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// hasDriveLetter reports whether a name starts with a Windows drive, as in "C:"
+func hasDriveLetter(name string) bool {
+	if len(name) < 2 || name[1] != ':' {
+		return false
+	}
+	letter := name[0] | 0x20
+	return letter >= 'a' && letter <= 'z'
+}
+
+// IsSymlink reports whether an entry is a symbolic link. Books have no use for them, and
+// one extracted could point later entries anywhere, so they're never extracted.
+func IsSymlink(file *zip.File) bool {
+	return file.Mode()&os.ModeSymlink != 0
+}
+
+// EntryPerm is the permissions to extract an entry with: its own read/write/execute bits,
+// never setuid or the like, and always readable and writable by the owner
+func EntryPerm(file *zip.File) os.FileMode {
+	return file.Mode().Perm() | 0600
+}
@@ -0,0 +1,64 @@
+package epubzip
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntryPath(t *testing.T) {
+	dir := filepath.Join("out", "book")
+	safe := map[string]string{
+		"mimetype":                "mimetype",
+		"OEBPS/chapter1.xhtml":    filepath.Join("OEBPS", "chapter1.xhtml"),
+		`OEBPS\images\cover.jpg`:  filepath.Join("OEBPS", "images", "cover.jpg"),
+		"OEBPS/../META-INF/x.xml": filepath.Join("META-INF", "x.xml"),
+		"./OEBPS/":                "OEBPS",
+	}
+	for name, want := range safe {
+		got, err := EntryPath(dir, name)
+		if err != nil {
+			t.Errorf("EntryPath(%q) failed: %v", name, err)
+			continue
+		}
+		if want := filepath.Join(dir, want); got != want {
+			t.Errorf("EntryPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	for _, name := range []string{
+		"../evil.sh",
+		"OEBPS/../../evil.sh",
+		`..\..\evil.sh`,
+		"/etc/passwd",
+		`\Windows\evil.dll`,
+		"C:/Windows/evil.dll",
+		`c:evil.dll`,
+		"..",
+		"",
+	} {
+		if got, err := EntryPath(dir, name); err == nil {
+			t.Errorf("Expected %q refused, got %q", name, got)
+		}
+	}
+}
+
+func TestIsSymlinkAndEntryPerm(t *testing.T) {
+	link := &zip.FileHeader{Name: "OEBPS/link"}
+	link.SetMode(os.ModeSymlink | 0777)
+	if !IsSymlink(&zip.File{FileHeader: *link}) {
+		t.Error("Expected a symlink entry recognised")
+	}
+
+	setuid := &zip.FileHeader{Name: "OEBPS/run"}
+	setuid.SetMode(os.ModeSetuid | 0755)
+	if perm := EntryPerm(&zip.File{FileHeader: *setuid}); perm != 0755 {
+		t.Errorf("Expected setuid dropped, got %v", perm)
+	}
+	readOnly := &zip.FileHeader{Name: "OEBPS/text.xhtml"}
+	readOnly.SetMode(0444)
+	if perm := EntryPerm(&zip.File{FileHeader: *readOnly}); perm != 0644 {
+		t.Errorf("Expected the owner able to write, got %v", perm)
+	}
+}
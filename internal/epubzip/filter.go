@@ -0,0 +1,53 @@
+package epubzip
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter picks entries by glob patterns, as in "OEBPS/*.xhtml". A pattern without a
+// slash matches the file name in any folder, so "*.css" finds every stylesheet.
+type Filter struct {
+	Include []string // Entries to keep (none = all of them)
+	Exclude []string // Entries to leave out, even if included
+}
+
+// NewFilter returns a filter for the patterns, refusing any that don't parse
+func NewFilter(include, exclude []string) (Filter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Filter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return Filter{Include: include, Exclude: exclude}, nil
+}
+
+// Empty reports whether the filter keeps every entry
+func (f Filter) Empty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether an entry is kept
+func (f Filter) Match(name string) bool {
+	if matchAny(f.Exclude, name) {
+		return false
+	}
+	return len(f.Include) == 0 || matchAny(f.Include, name)
+}
+
+// matchAny reports whether any of the patterns match the entry's path, or its file name
+// for patterns without a slash
+func matchAny(patterns []string, name string) bool {
+	name = strings.TrimPrefix(name, "/")
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), target); ok {
+			return true
+		}
+	}
+	return false
+}
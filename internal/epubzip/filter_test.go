@@ -0,0 +1,31 @@
+package epubzip
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	filter, err := NewFilter([]string{"OEBPS/*.xhtml", "*.css"}, []string{"*nav*"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+
+	tests := map[string]bool{
+		"OEBPS/chapter1.xhtml":     true,
+		"OEBPS/nav.xhtml":          false, // Excluded
+		"OEBPS/text/chapter.xhtml": false, // * doesn't cross folders
+		"OEBPS/styles/book.css":    true,  // No slash, so by file name
+		"OEBPS/content.opf":        false,
+		"mimetype":                 false,
+	}
+	for name, want := range tests {
+		if got := filter.Match(name); got != want {
+			t.Errorf("Match(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if !(Filter{}).Match("anything") || !(Filter{}).Empty() {
+		t.Error("Expected an empty filter to keep everything")
+	}
+	if _, err := NewFilter(nil, []string{"[chapter"}); err == nil {
+		t.Error("Expected a malformed pattern to be refused")
+	}
+}
@@ -0,0 +1,60 @@
+package epubzip
+
+import (
+	"path"
+	"strings"
+)
+
+// junkFolders are folders operating systems and version control leave in a book's folder
+var junkFolders = map[string]bool{
+	"__macosx": true,
+	".git":     true,
+	".svn":     true,
+	".hg":      true,
+}
+
+// junkFiles are files Finder and Explorer drop into folders they've shown
+var junkFiles = map[string]bool{
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"ehthumbs.db": true,
+	"desktop.ini": true,
+	".directory":  true,
+}
+
+// IsJunk reports whether a path in a book's folder is something left behind by the
+// operating system or an editor (.DS_Store, Thumbs.db, swap and backup files) rather than
+// part of the book. EPUB checkers refuse files the manifest doesn't list, so they're kept
+// out when the folder is packed.
+func IsJunk(name string) bool {
+	parts := strings.Split(strings.Trim(strings.ReplaceAll(name, `\`, "/"), "/"), "/")
+	for _, part := range parts[:len(parts)-1] {
+		if junkFolders[strings.ToLower(part)] {
+			return true
+		}
+	}
+	return IsJunkFolder(name) || isJunkFile(path.Base(parts[len(parts)-1]))
+}
+
+// IsJunkFolder reports whether a folder holds nothing but junk, so it can be passed over whole
+func IsJunkFolder(name string) bool {
+	return junkFolders[strings.ToLower(path.Base(strings.ReplaceAll(name, `\`, "/")))]
+}
+
+// isJunkFile reports whether a file name is junk: Finder's ._ resource forks, vim swap
+// files, emacs lock and autosave files, and the ~ backups many editors keep
+func isJunkFile(base string) bool {
+	lower := strings.ToLower(base)
+	switch {
+	case junkFiles[lower]:
+		return true
+	case strings.HasPrefix(base, "._"), strings.HasPrefix(base, ".#"):
+		return true
+	case strings.HasSuffix(base, "~"):
+		return true
+	case strings.HasPrefix(base, "#") && strings.HasSuffix(base, "#") && len(base) > 1:
+		return true
+	}
+	ext := path.Ext(lower)
+	return strings.HasPrefix(base, ".") && (ext == ".swp" || ext == ".swo" || ext == ".swx")
+}
@@ -0,0 +1,29 @@
+package epubzip
+
+import "testing"
+
+func TestIsJunk(t *testing.T) {
+	tests := map[string]bool{
+		".DS_Store":                     true,
+		"OEBPS/images/.DS_Store":        true,
+		"OEBPS/Thumbs.db":               true,
+		"OEBPS/._chapter1.xhtml":        true,
+		"OEBPS/.chapter1.xhtml.swp":     true,
+		"OEBPS/chapter1.xhtml~":         true,
+		"OEBPS/.#chapter1.xhtml":        true,
+		"OEBPS/#chapter1.xhtml#":        true,
+		"__MACOSX/OEBPS/chapter1.xhtml": true,
+		".git/HEAD":                     true,
+		"OEBPS/chapter1.xhtml":          false,
+		"OEBPS/images/cover.jpg":        false,
+		"OEBPS/styles/book.css":         false,
+		"META-INF/container.xml":        false,
+		"OEBPS/fonts/swap.otf":          false,
+		"OEBPS/text/chapter#2.xhtml":    false,
+	}
+	for name, want := range tests {
+		if got := IsJunk(name); got != want {
+			t.Errorf("IsJunk(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package epubzip
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mimetype is the content of an EPUB's mimetype entry
+const Mimetype = "application/epub+zip"
+
+// Compression levels shared by the compress command and the EPUB generator
+const (
+	LevelStore   = "store"   // No compression at all (zip.Store)
+	LevelFast    = "fast"    // Deflate without compression, fastest to write
+	LevelDefault = "default" // Standard deflate
+	LevelBest    = "best"    // Smallest output, slowest to write
+)
+
+// Levels lists the accepted compression levels in order of increasing effort
+var Levels = []string{LevelStore, LevelFast, LevelDefault, LevelBest}
+
+// alreadyCompressed lists extensions whose content doesn't shrink under deflate. TrueType
+// and OpenType fonts aren't compressed, and deflate still takes a third off them.
+var alreadyCompressed = map[string]bool{
+	".jpg":   true,
+	".jpeg":  true,
+	".png":   true,
+	".gif":   true,
+	".webp":  true,
+	".avif":  true,
+	".jxl":   true,
+	".woff":  true,
+	".woff2": true,
+	".mp3":   true,
+	".m4a":   true,
+	".mp4":   true,
+}
+
+// Options controls how entries are compressed
+type Options struct {
+	Level string // One of Levels ("" = LevelDefault)
+	// StoreCompressedMedia stores images, fonts and audio as-is instead of
+	// deflating them, which wastes CPU and sometimes grows the file
+	StoreCompressedMedia bool
+	// Reproducible stamps every entry with SourceDate and the same permissions, and
+	// repacks entries in name order, so the same content always makes the same bytes
+	Reproducible bool
+}
+
+// SourceDate is the time reproducible archives are stamped with: SOURCE_DATE_EPOCH if it's
+// set, as reproducible-builds.org has it, or else 1980-01-01, the earliest a zip entry
+// can carry
+func SourceDate() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil && epoch >= 0 {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ValidateLevel checks that a compression level is known
+func ValidateLevel(level string) error {
+	for _, valid := range Levels {
+		if level == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid compression level: %s (valid options: %s)", level, strings.Join(Levels, ", "))
+}
+
+// IsCompressedMedia reports whether a file is already compressed and should be stored
+func IsCompressedMedia(name string) bool {
+	return alreadyCompressed[strings.ToLower(path.Ext(name))]
+}
+
+// Writer writes EPUB archives, keeping the mimetype entry first and uncompressed
+type Writer struct {
+	zw   *zip.Writer
+	opts Options
+}
+
+// NewWriter creates an EPUB archive writer with the given compression settings
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	if opts.Level == "" {
+		opts.Level = LevelDefault
+	}
+	if err := ValidateLevel(opts.Level); err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+
+	var flateLevel int
+	switch opts.Level {
+	case LevelFast:
+		flateLevel = flate.NoCompression
+	case LevelBest:
+		flateLevel = flate.BestCompression
+	default:
+		flateLevel = flate.DefaultCompression
+	}
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flateLevel)
+	})
+
+	return &Writer{zw: zw, opts: opts}, nil
+}
+
+// WriteMimetype writes the mimetype entry; it must be the first entry in the archive
+func (w *Writer) WriteMimetype(content []byte) error {
+	header := &zip.FileHeader{
+		Name:   "mimetype",
+		Method: zip.Store, // Required by the EPUB spec
+	}
+	w.normalize(header)
+	writer, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create mimetype entry: %w", err)
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		return fmt.Errorf("failed to write mimetype content: %w", err)
+	}
+
+	return nil
+}
+
+// Create adds an entry using the method appropriate for its type. The header's
+// Name must be set; Method is overridden.
+func (w *Writer) Create(header *zip.FileHeader) (io.Writer, error) {
+	header.Method = w.methodFor(header.Name)
+	w.normalize(header)
+	return w.zw.CreateHeader(header)
+}
+
+// normalize drops what differs from one run to the next from a header, if the archive is
+// to be reproducible
+func (w *Writer) normalize(header *zip.FileHeader) {
+	if !w.opts.Reproducible {
+		return
+	}
+	header.Modified = SourceDate()
+	header.ModifiedTime, header.ModifiedDate = 0, 0
+	header.Extra = nil
+	header.Comment = ""
+	header.CreatorVersion = 0
+	header.SetMode(0644)
+}
+
+// methodFor picks store or deflate for an entry
+func (w *Writer) methodFor(name string) uint16 {
+	if w.opts.Level == LevelStore {
+		return zip.Store
+	}
+	if w.opts.StoreCompressedMedia && IsCompressedMedia(name) {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// Copy adds an entry from another archive as it is, without recompressing it
+func (w *Writer) Copy(file *zip.File) error {
+	if !w.opts.Reproducible {
+		return w.zw.Copy(file)
+	}
+
+	// The compressed bytes go across untouched, under a normalized header
+	header := file.FileHeader
+	w.normalize(&header)
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return err
+	}
+	writer, err := w.zw.CreateRaw(&header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, raw)
+	return err
+}
+
+// Close finishes the archive
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+// TransformFunc rewrites an entry's content while repacking. Returning the
+// content unchanged leaves the entry as it was.
+type TransformFunc func(name string, content []byte) ([]byte, error)
+
+// Repack copies every entry of an existing EPUB into a new archive with the
+// writer's compression settings, putting mimetype first
+func Repack(src *zip.Reader, dst io.Writer, opts Options) error {
+	return RepackWith(src, dst, opts, nil)
+}
+
+// RepackWith is Repack with a transform applied to every entry except mimetype
+func RepackWith(src *zip.Reader, dst io.Writer, opts Options, transform TransformFunc) error {
+	return RepackAdding(src, dst, opts, transform, nil)
+}
+
+// RepackAdding is RepackWith that also writes entries the source doesn't have, such as
+// META-INF/encryption.xml, after the others. Names already in the source are an error.
+func RepackAdding(src *zip.Reader, dst io.Writer, opts Options, transform TransformFunc, added map[string][]byte) error {
+	for _, file := range src.File {
+		if _, ok := added[file.Name]; ok {
+			return fmt.Errorf("the EPUB already has an entry named %s", file.Name)
+		}
+	}
+
+	w, err := NewWriter(dst, opts)
+	if err != nil {
+		return err
+	}
+
+	mimetype := []byte(Mimetype)
+	for _, file := range src.File {
+		if file.Name == "mimetype" {
+			content, err := readEntry(file)
+			if err != nil {
+				return fmt.Errorf("failed to read mimetype: %w", err)
+			}
+			mimetype = content
+			break
+		}
+	}
+
+	if err := w.WriteMimetype(mimetype); err != nil {
+		return err
+	}
+
+	files := src.File
+	if opts.Reproducible {
+		files = slices.Clone(files)
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	for _, file := range files {
+		if file.Name == "mimetype" || file.FileInfo().IsDir() {
+			continue
+		}
+
+		header := file.FileHeader
+		writer, err := w.Create(&header)
+		if err != nil {
+			return fmt.Errorf("failed to create entry %s: %w", file.Name, err)
+		}
+
+		if transform != nil {
+			content, err := readEntry(file)
+			if err != nil {
+				return fmt.Errorf("failed to read entry %s: %w", file.Name, err)
+			}
+			if content, err = transform(file.Name, content); err != nil {
+				return fmt.Errorf("failed to rewrite entry %s: %w", file.Name, err)
+			}
+			if _, err := writer.Write(content); err != nil {
+				return fmt.Errorf("failed to write entry %s: %w", file.Name, err)
+			}
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open entry %s: %w", file.Name, err)
+		}
+		_, err = io.Copy(writer, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy entry %s: %w", file.Name, err)
+		}
+	}
+
+	names := make([]string, 0, len(added))
+	for name := range added {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writer, err := w.Create(&zip.FileHeader{Name: name, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("failed to create entry %s: %w", name, err)
+		}
+		if _, err := writer.Write(added[name]); err != nil {
+			return fmt.Errorf("failed to write entry %s: %w", name, err)
+		}
+	}
+
+	return w.Close()
+}
+
+// ReplaceEntries copies an EPUB, swapping in new content for the named entries. Other
+// entries are copied without recompressing them. Names not in the source are an error.
+func ReplaceEntries(src *zip.Reader, dst io.Writer, opts Options, replacements map[string][]byte) error {
+	found := make(map[string]bool)
+	for _, file := range src.File {
+		if _, ok := replacements[file.Name]; ok {
+			found[file.Name] = true
+		}
+	}
+	for name := range replacements {
+		if !found[name] {
+			return fmt.Errorf("no entry named %s in the EPUB", name)
+		}
+	}
+
+	return Patch(src, dst, opts, replacements)
+}
+
+// Patch copies an EPUB, swapping in new content for the entries in changes and adding
+// the ones the source doesn't have after the others. Entries changed to nil are left out.
+// Nothing else is decompressed or compressed again, so changing one file in a large book
+// costs little more than copying it.
+func Patch(src *zip.Reader, dst io.Writer, opts Options, changes map[string][]byte) error {
+	if _, ok := changes["mimetype"]; ok {
+		return fmt.Errorf("the mimetype entry can't be replaced")
+	}
+
+	w, err := NewWriter(dst, opts)
+	if err != nil {
+		return err
+	}
+
+	mimetype := []byte(Mimetype)
+	for _, file := range src.File {
+		if file.Name == "mimetype" {
+			content, err := readEntry(file)
+			if err != nil {
+				return fmt.Errorf("failed to read mimetype: %w", err)
+			}
+			mimetype = content
+			break
+		}
+	}
+	if err := w.WriteMimetype(mimetype); err != nil {
+		return err
+	}
+
+	files := src.File
+	if opts.Reproducible {
+		files = slices.Clone(files)
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	written := make(map[string]bool)
+	for _, file := range files {
+		if file.Name == "mimetype" || file.FileInfo().IsDir() {
+			continue
+		}
+
+		content, ok := changes[file.Name]
+		if !ok {
+			if err := w.Copy(file); err != nil {
+				return fmt.Errorf("failed to copy entry %s: %w", file.Name, err)
+			}
+			continue
+		}
+
+		written[file.Name] = true
+		if content == nil {
+			continue
+		}
+		if err := w.write(file.Name, content); err != nil {
+			return err
+		}
+	}
+
+	var added []string
+	for name, content := range changes {
+		if !written[name] && content != nil {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := w.write(name, changes[name]); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// write adds an entry with the given content, stamped now
+func (w *Writer) write(name string, content []byte) error {
+	writer, err := w.Create(&zip.FileHeader{Name: name, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create entry %s: %w", name, err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return fmt.Errorf("failed to write entry %s: %w", name, err)
+	}
+	return nil
+}
+
+func readEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
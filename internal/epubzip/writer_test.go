@@ -0,0 +1,210 @@
+package epubzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBook writes a small EPUB at a compression level and returns the archive
+func writeBook(t *testing.T, level string, chapter string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Options{Level: level, StoreCompressedMedia: true})
+	if err != nil {
+		t.Fatalf("NewWriter(%q) failed: %v", level, err)
+	}
+	if err := w.WriteMimetype([]byte("application/epub+zip")); err != nil {
+		t.Fatalf("WriteMimetype failed: %v", err)
+	}
+	entries := []struct{ name, content string }{
+		{"META-INF/container.xml", `<?xml version="1.0"?><container/>`},
+		{"EPUB/chapter1.xhtml", chapter},
+		{"EPUB/images/plate.png", "\x89PNG not really"},
+	}
+	for _, entry := range entries {
+		writer, err := w.Create(&zip.FileHeader{Name: entry.name, Modified: time.Now()})
+		if err != nil {
+			t.Fatalf("Create(%s) failed: %v", entry.name, err)
+		}
+		if _, err := writer.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("Write(%s) failed: %v", entry.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterLevels(t *testing.T) {
+	chapter := "<html><body>" + strings.Repeat("<p>The quick brown fox jumps over the lazy dog.</p>", 400) + "</body></html>"
+
+	sizes := make(map[string]int)
+	for _, level := range Levels {
+		book := writeBook(t, level, chapter)
+		sizes[level] = len(book)
+
+		zr, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+		if err != nil {
+			t.Fatalf("%s: the EPUB doesn't open: %v", level, err)
+		}
+		if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+			t.Errorf("%s: expected mimetype first and stored", level)
+		}
+		for _, file := range zr.File {
+			rc, err := file.Open()
+			if err != nil {
+				t.Fatalf("%s: failed to open %s: %v", level, file.Name, err)
+			}
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("%s: failed to read %s: %v", level, file.Name, err)
+			}
+			if file.Name == "EPUB/chapter1.xhtml" && string(content) != chapter {
+				t.Errorf("%s: chapter didn't come back as written", level)
+			}
+		}
+
+		for _, file := range zr.File {
+			wantStored := level == LevelStore || file.Name == "mimetype" || file.Name == "EPUB/images/plate.png"
+			if (file.Method == zip.Store) != wantStored {
+				t.Errorf("%s: %s stored = %v, want %v", level, file.Name, file.Method == zip.Store, wantStored)
+			}
+		}
+	}
+
+	// Fast deflates without compressing, so it comes out about as big as storing
+	if sizes[LevelFast] < len(chapter) {
+		t.Errorf("Expected fast not to compress, got %d bytes for a %d byte chapter", sizes[LevelFast], len(chapter))
+	}
+	if sizes[LevelDefault] >= sizes[LevelFast]/4 {
+		t.Errorf("Expected default to compress the chapter, got %d bytes against %d", sizes[LevelDefault], sizes[LevelFast])
+	}
+	if sizes[LevelBest] > sizes[LevelDefault] {
+		t.Errorf("Expected best no bigger than default, got %d against %d", sizes[LevelBest], sizes[LevelDefault])
+	}
+}
+
+func TestNewWriterRejectsUnknownLevel(t *testing.T) {
+	if _, err := NewWriter(io.Discard, Options{Level: "maximum"}); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
+
+// shuffledBook writes an EPUB with its entries in the given order, stamped with when
+func shuffledBook(t *testing.T, names []string, when time.Time) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: when}
+		header.SetMode(0600)
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte("content of " + name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestRepackReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	first := shuffledBook(t, []string{"mimetype", "EPUB/b.xhtml", "META-INF/container.xml", "EPUB/a.xhtml"},
+		time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	second := shuffledBook(t, []string{"mimetype", "EPUB/a.xhtml", "EPUB/b.xhtml", "META-INF/container.xml"},
+		time.Date(2025, 7, 9, 8, 30, 0, 0, time.Local))
+
+	var a, b bytes.Buffer
+	if err := Repack(first, &a, Options{Reproducible: true}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if err := Repack(second, &b, Options{Reproducible: true}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Fatal("Expected the same entries to repack to the same bytes")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(a.Bytes()), int64(a.Len()))
+	if err != nil {
+		t.Fatalf("The repacked EPUB doesn't open: %v", err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+		if !file.Modified.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("%s stamped %v, want SOURCE_DATE_EPOCH", file.Name, file.Modified)
+		}
+		if file.Mode().Perm() != 0644 {
+			t.Errorf("%s has mode %v, want 0644", file.Name, file.Mode())
+		}
+	}
+	if got := strings.Join(names, " "); got != "mimetype EPUB/a.xhtml EPUB/b.xhtml META-INF/container.xml" {
+		t.Errorf("Expected mimetype first and the rest in name order, got %s", got)
+	}
+}
+
+func TestSourceDate(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if got := SourceDate(); !got.Equal(time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 1980-01-01 without SOURCE_DATE_EPOCH, got %v", got)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "not a number")
+	if got := SourceDate(); got.Year() != 1980 {
+		t.Errorf("Expected a bad SOURCE_DATE_EPOCH ignored, got %v", got)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	chapter := "<html><body>" + strings.Repeat("<p>The quick brown fox jumps over the lazy dog.</p>", 400) + "</body></html>"
+	book := writeBook(t, LevelBest, chapter)
+	src, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	changes := map[string][]byte{
+		"META-INF/container.xml": []byte(`<?xml version="1.0"?><container version="1.0"/>`),
+		"EPUB/images/cover.jpg":  []byte("\xff\xd8\xff not really a JPEG"),
+	}
+	if err := Patch(src, &buf, Options{Level: LevelStore, StoreCompressedMedia: true}, changes); err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("The patched EPUB doesn't open: %v", err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if got := strings.Join(names, " "); got != "mimetype META-INF/container.xml EPUB/chapter1.xhtml EPUB/images/plate.png EPUB/images/cover.jpg" {
+		t.Errorf("Expected changed entries in place and new ones last, got %s", got)
+	}
+	// The chapter keeps its deflated bytes, whatever level the patch writes at
+	if zr.File[2].Method != zip.Deflate || zr.File[2].CompressedSize64 != src.File[2].CompressedSize64 {
+		t.Error("Expected the untouched chapter copied without recompressing")
+	}
+
+	if err := Patch(src, io.Discard, Options{}, map[string][]byte{"mimetype": nil}); err == nil {
+		t.Error("Expected replacing mimetype to be refused")
+	}
+}
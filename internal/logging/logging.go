@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Levels lists the names --log-level takes, least to most severe
+var Levels = []string{"trace", "debug", "info", "warn", "error"}
+
+// DefaultLevel shows warnings and errors, and keeps the diagnostics out of the way
+const DefaultLevel = "warn"
+
+// LevelTrace is below debug, for what's logged for every image and every word: only
+// worth reading for one module at a time
+const LevelTrace = slog.LevelDebug - 4
+
+// Modules are the parts of publify that log through Module, which --debug can turn on
+// one at a time
+var Modules = []string{"converter", "pdf", "ocr", "image", "epub", "progress"}
+
+// moduleKey is the attribute a module's logger tags its records with
+const moduleKey = "module"
+
+// Options says what's logged, where and how
+type Options struct {
+	Level  string   // Least severe level logged ("" = DefaultLevel)
+	JSON   bool     // Log JSON lines rather than text
+	Source bool     // Say which file and line each record comes from
+	Debug  []string // Modules logged down to DebugLevel whatever Level says
+	// DebugLevel is how far down the Debug modules are logged ("" = debug)
+	DebugLevel string
+}
+
+// ParseLevel reads a level name
+func ParseLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "trace") {
+		return LevelTrace, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (use %s)", name, strings.Join(Levels, ", "))
+	}
+	return level, nil
+}
+
+// ParseModules reads a comma-separated list of modules, as --debug takes them
+func ParseModules(spec string) ([]string, error) {
+	var modules []string
+	for _, module := range strings.Split(spec, ",") {
+		module = strings.ToLower(strings.TrimSpace(module))
+		if module == "" {
+			continue
+		}
+		if !slices.Contains(Modules, module) {
+			return nil, fmt.Errorf("unknown module %q (use %s)", module, strings.Join(Modules, ", "))
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// VerbosityLevel is the level -v, -vv and -vvv ask for: info, debug and trace
+func VerbosityLevel(verbosity int) string {
+	switch {
+	case verbosity <= 0:
+		return DefaultLevel
+	case verbosity == 1:
+		return "info"
+	case verbosity == 2:
+		return "debug"
+	}
+	return "trace"
+}
+
+// Setup makes the default slog logger write to w as the options say. Diagnostics go
+// through it rather than being printed, so they can be turned down and never land in the
+// progress display or in output meant for other programs.
+func Setup(w io.Writer, opts Options) error {
+	if opts.Level == "" {
+		opts.Level = DefaultLevel
+	}
+	minimum, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	debugLevel := slog.LevelDebug
+	if opts.DebugLevel != "" {
+		if debugLevel, err = ParseLevel(opts.DebugLevel); err != nil {
+			return err
+		}
+	}
+
+	// The handler lets everything through; filtering by module happens above it
+	handlerOpts := &slog.HandlerOptions{
+		Level:       min(minimum, debugLevel),
+		AddSource:   opts.Source,
+		ReplaceAttr: nameTrace,
+	}
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	filter := &moduleFilter{next: handler, minimum: minimum, debugLevel: debugLevel}
+	if len(opts.Debug) > 0 {
+		filter.debug = make(map[string]bool)
+		for _, module := range opts.Debug {
+			filter.debug[module] = true
+		}
+	}
+	slog.SetDefault(slog.New(filter))
+	return nil
+}
+
+// nameTrace writes LevelTrace as TRACE rather than DEBUG-4
+func nameTrace(groups []string, attr slog.Attr) slog.Attr {
+	if level, ok := attr.Value.Any().(slog.Level); ok && attr.Key == slog.LevelKey && len(groups) == 0 && level == LevelTrace {
+		attr.Value = slog.StringValue("TRACE")
+	}
+	return attr
+}
+
+// Module returns the logger for one part of publify, whose records carry its name so
+// --debug can single them out. It follows the default logger, so call it when logging
+// rather than keeping what it returns.
+func Module(name string) *slog.Logger {
+	return slog.Default().With(moduleKey, name)
+}
+
+// moduleFilter logs records from minimum up, and from debugLevel up for the modules
+// being debugged
+type moduleFilter struct {
+	next       slog.Handler
+	minimum    slog.Level
+	debugLevel slog.Level
+	debug      map[string]bool // Modules being debugged (nil = none)
+	module     string          // Module of the logger this handler is for ("" = none)
+}
+
+func (f *moduleFilter) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= f.minimum {
+		return true
+	}
+	return f.debug[f.module] && level >= f.debugLevel
+}
+
+func (f *moduleFilter) Handle(ctx context.Context, record slog.Record) error {
+	return f.next.Handle(ctx, record)
+}
+
+func (f *moduleFilter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	filter := *f
+	filter.next = f.next.WithAttrs(attrs)
+	for _, attr := range attrs {
+		if attr.Key == moduleKey {
+			filter.module = attr.Value.String()
+		}
+	}
+	return &filter
+}
+
+func (f *moduleFilter) WithGroup(name string) slog.Handler {
+	filter := *f
+	filter.next = f.next.WithGroup(name)
+	return &filter
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var out bytes.Buffer
+	if err := Setup(&out, Options{Level: "info", JSON: true}); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	slog.Debug("Markov chain score", "page", 3)
+	slog.Info("Rendered cover", "page", 1)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected the debug line left out, got %q", out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON line, got %q", lines[0])
+	}
+	if record["msg"] != "Rendered cover" || record["page"] != float64(1) {
+		t.Errorf("Unexpected record: %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range Levels {
+		if _, err := ParseLevel(name); err != nil {
+			t.Errorf("ParseLevel(%q) failed: %v", name, err)
+		}
+	}
+	if level, _ := ParseLevel("DEBUG"); level != slog.LevelDebug {
+		t.Errorf("Expected level names to be case-insensitive, got %v", level)
+	}
+	if _, err := ParseLevel("chatty"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestSetupDebugModules(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var out bytes.Buffer
+	if err := Setup(&out, Options{Debug: []string{"ocr"}}); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	Module("ocr").Debug("Read page", "page", 12, "confidence", 81)
+	Module("ocr").Log(context.Background(), LevelTrace, "Word", "text", "the")
+	Module("image").Debug("Optimized image", "page", 12)
+	slog.Debug("Markov chain score", "page", 12)
+	Module("image").Warn("Image too large to optimize", "page", 13)
+
+	logged := out.String()
+	if !strings.Contains(logged, `msg="Read page" module=ocr page=12`) || !strings.Contains(logged, "Image too large") {
+		t.Errorf("Expected the OCR debugging and warnings from everywhere, got:\n%s", logged)
+	}
+	if strings.Contains(logged, "Optimized image") || strings.Contains(logged, "Markov") || strings.Contains(logged, "Word") {
+		t.Errorf("Expected other modules' debugging and OCR tracing left out, got:\n%s", logged)
+	}
+
+	out.Reset()
+	if err := Setup(&out, Options{Level: "trace"}); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	Module("ocr").Log(context.Background(), LevelTrace, "Word", "text", "the")
+	if !strings.Contains(out.String(), "level=TRACE") {
+		t.Errorf("Expected the trace level named, got %q", out.String())
+	}
+}
+
+func TestParseModules(t *testing.T) {
+	modules, err := ParseModules("OCR, epub,")
+	if err != nil || len(modules) != 2 || modules[0] != "ocr" || modules[1] != "epub" {
+		t.Errorf("Expected ocr and epub, got %v, %v", modules, err)
+	}
+	if _, err := ParseModules("ocr,pixels"); err == nil {
+		t.Error("Expected an error for an unknown module")
+	}
+}
+
+func TestVerbosityLevel(t *testing.T) {
+	for verbosity, expected := range []string{DefaultLevel, "info", "debug", "trace", "trace"} {
+		if level := VerbosityLevel(verbosity); level != expected {
+			t.Errorf("VerbosityLevel(%d) = %q, expected %q", verbosity, level, expected)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package miniyaml reads and writes the small part of YAML publify's own files need:
+// top-level scalars, lists of scalars and lists of small maps. That's what gets written,
+// and what's read back, along with comments, quoting styles and [flow, lists] that people
+// add when editing by hand.
+package miniyaml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// plainScalar matches strings that can be written without quotes
+var plainScalar = regexp.MustCompile(`^[\p{L}\p{N}(][^:#\n"']*$`)
+
+// Scalar writes a string plainly when that's unambiguous, double-quoted otherwise
+func Scalar(value string) string {
+	if plainScalar.MatchString(value) && strings.TrimSpace(value) == value && !special(value) {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// special reports whether a plain scalar would be read back as something other than a string
+func special(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// Entry is a top-level key: either a scalar, or a list of items. List items are
+// scalars, or small maps when written as "- name: ..." with more keys below.
+type Entry struct {
+	Scalar string
+	Items  []Item
+	Line   int // Where it is in the file, for errors
+}
+
+// Item is a list item: a scalar, or the fields of a small map
+type Item struct {
+	Scalar string
+	Fields map[string]string
+}
+
+// Parse reads the top-level keys of a document
+func Parse(data []byte) (map[string]*Entry, error) {
+	entries := make(map[string]*Entry)
+	var current *Entry
+	var currentItem *Item
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := stripComment(scanner.Text())
+		if strings.TrimSpace(line) == "" || strings.TrimSpace(line) == "---" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		content := strings.TrimSpace(line)
+
+		switch {
+		case indent == 0:
+			key, value, ok := strings.Cut(content, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNum)
+			}
+			current = &Entry{Line: lineNum}
+			currentItem = nil
+			entries[strings.TrimSpace(key)] = current
+
+			value = strings.TrimSpace(value)
+			if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+				for _, item := range strings.Split(value[1:len(value)-1], ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						parsed, err := parseScalar(item)
+						if err != nil {
+							return nil, fmt.Errorf("line %d: %w", lineNum, err)
+						}
+						current.Items = append(current.Items, Item{Scalar: parsed})
+					}
+				}
+				continue
+			}
+
+			parsed, err := parseScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			current.Scalar = parsed
+
+		case current == nil:
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNum)
+
+		case strings.HasPrefix(content, "- ") || content == "-":
+			item := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+			current.Items = append(current.Items, Item{})
+			currentItem = &current.Items[len(current.Items)-1]
+
+			if key, value, ok := cutField(item); ok {
+				currentItem.Fields = map[string]string{key: value}
+				continue
+			}
+			parsed, err := parseScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			currentItem.Scalar = parsed
+
+		default:
+			// Another key of the list item above
+			key, value, ok := cutField(content)
+			if !ok || currentItem == nil || currentItem.Fields == nil {
+				return nil, fmt.Errorf("line %d: unexpected %q", lineNum, content)
+			}
+			currentItem.Fields[key] = value
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// cutField splits "key: value" inside a list item. Quoted scalars containing colons
+// aren't fields.
+func cutField(content string) (string, string, bool) {
+	if strings.HasPrefix(content, `"`) || strings.HasPrefix(content, "'") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(content, ":")
+	if !ok || strings.ContainsAny(key, " \t") || (value != "" && value[0] != ' ') {
+		return "", "", false
+	}
+	parsed, err := parseScalar(strings.TrimSpace(value))
+	if err != nil {
+		return "", "", false
+	}
+	return key, parsed, true
+}
+
+func parseScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted string %s", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid single-quoted string %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	return value, nil
+}
+
+// stripComment removes a trailing # comment that isn't inside quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
@@ -0,0 +1,47 @@
+package miniyaml
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	entries, err := Parse([]byte(`---
+title: "Mort: A Discworld Novel" # quoted for the colon
+series: Discworld
+subjects: [Fantasy, 'Humour']
+authors:
+  - name: Terry Pratchett
+    role: aut
+  - Neil Gaiman
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if title := entries["title"]; title == nil || title.Scalar != "Mort: A Discworld Novel" || title.Line != 2 {
+		t.Errorf("Unexpected title: %+v", title)
+	}
+	if subjects := entries["subjects"].Items; len(subjects) != 2 || subjects[1].Scalar != "Humour" {
+		t.Errorf("Unexpected flow list: %+v", subjects)
+	}
+	authors := entries["authors"].Items
+	if len(authors) != 2 || authors[0].Fields["role"] != "aut" || authors[1].Scalar != "Neil Gaiman" {
+		t.Errorf("Unexpected list of maps: %+v", authors)
+	}
+
+	if _, err := Parse([]byte("  indented: first\n")); err == nil {
+		t.Error("Expected an error for indentation before any key")
+	}
+}
+
+func TestScalar(t *testing.T) {
+	for value, expected := range map[string]string{
+		"Discworld":   "Discworld",
+		"Mort: Death": `"Mort: Death"`,
+		"yes":         `"yes"`,
+		"3":           `"3"`,
+		" padded":     `" padded"`,
+	} {
+		if result := Scalar(value); result != expected {
+			t.Errorf("Scalar(%q) = %s, expected %s", value, result, expected)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package safefile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix is appended to the previous file's name when a backup is kept
+const BackupSuffix = ".bak"
+
+// File is an output file that is written to a temporary file next to its destination and
+// moved into place on Commit. Readers never see half a book, and a failed write leaves the
+// previous file untouched.
+type File struct {
+	*os.File
+	path      string
+	backup    bool
+	committed bool
+}
+
+// Create starts writing path. With backup set, a file already at path is kept as
+// path+BackupSuffix when the new one is committed.
+func Create(path string, backup bool) (*File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	return &File{File: tmp, path: path, backup: backup}, nil
+}
+
+// Commit flushes the temporary file and renames it over the destination
+func (f *File) Commit() error {
+	if f.committed {
+		return nil
+	}
+
+	if err := f.File.Sync(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to flush %s: %w", f.path, err)
+	}
+	if err := f.File.Close(); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+
+	// CreateTemp makes the file private; give it the usual permissions, or the old file's
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+		if f.backup {
+			if err := backupFile(f.path); err != nil {
+				f.Abort()
+				return err
+			}
+		}
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to set permissions on %s: %w", f.path, err)
+	}
+
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to replace %s: %w", f.path, err)
+	}
+
+	f.committed = true
+	return nil
+}
+
+// Abort throws the temporary file away. It does nothing after a successful Commit, so it
+// can be deferred right after Create.
+func (f *File) Abort() {
+	if f.committed {
+		return
+	}
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// backupFile keeps a copy of path as path+BackupSuffix, replacing an older backup. A hard
+// link is enough since the original is about to be replaced by rename, not rewritten.
+func backupFile(path string) error {
+	backupPath := path + BackupSuffix
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old backup %s: %w", backupPath, err)
+	}
+	if err := os.Link(path, backupPath); err == nil {
+		return nil
+	}
+
+	// Some filesystems don't do hard links
+	if err := Copy(path, backupPath); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	return nil
+}
+
+// Copy copies the file at src to dst, which is only replaced once the copy is complete
+func Copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := Create(dst, false)
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Commit()
+}
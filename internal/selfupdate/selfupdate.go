@@ -0,0 +1,337 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Release channels for --channel
+const (
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease" // Release candidates and betas as well as stable releases
+)
+
+// Channels lists the channels --channel takes
+var Channels = []string{ChannelStable, ChannelPrerelease}
+
+// FeedURL is the release feed: publify's GitHub releases, newest first
+const FeedURL = "https://api.github.com/repos/alde/publify/releases"
+
+// PublicKey verifies release signatures, base64-encoded. Release builds set it with
+// -ldflags "-X github.com/alde/publify/internal/selfupdate.PublicKey=..."; a build without
+// one can't update itself, since it would have nothing to check a download against.
+var PublicKey string
+
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig" // Ed25519 signature of checksums.txt
+
+	// maxBinarySize bounds a download, well above the size of a release binary
+	maxBinarySize = 200 << 20
+)
+
+// Release is a published version and its files
+type Release struct {
+	Version    string  `json:"tag_name"`
+	Prerelease bool    `json:"prerelease"`
+	Draft      bool    `json:"draft"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Updater finds, downloads and verifies releases
+type Updater struct {
+	client    *http.Client
+	feedURL   string
+	publicKey ed25519.PublicKey
+}
+
+// New sets up an updater with the release feed and the key built into this binary
+func New() (*Updater, error) {
+	if PublicKey == "" {
+		return nil, fmt.Errorf("this build has no release signing key, so it can't verify updates; install a release binary or build from source")
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("this build's release signing key is malformed")
+	}
+	return newUpdater(&http.Client{Timeout: 5 * time.Minute}, FeedURL, ed25519.PublicKey(key)), nil
+}
+
+func newUpdater(client *http.Client, feedURL string, publicKey ed25519.PublicKey) *Updater {
+	return &Updater{client: client, feedURL: feedURL, publicKey: publicKey}
+}
+
+// ValidateChannel checks a --channel value
+func ValidateChannel(channel string) error {
+	for _, known := range Channels {
+		if channel == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown release channel %q (use %s)", channel, strings.Join(Channels, ", "))
+}
+
+// Latest finds the newest release on a channel
+func (u *Updater) Latest(ctx context.Context, channel string) (*Release, error) {
+	if err := ValidateChannel(channel); err != nil {
+		return nil, err
+	}
+
+	body, err := u.get(ctx, u.feedURL, 10<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the release feed: %w", err)
+	}
+	var releases []Release
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to read the release feed: %w", err)
+	}
+
+	var latest *Release
+	for i, release := range releases {
+		if release.Draft || (release.Prerelease && channel == ChannelStable) {
+			continue
+		}
+		if latest == nil || CompareVersions(release.Version, latest.Version) > 0 {
+			latest = &releases[i]
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no %s releases found", channel)
+	}
+	return latest, nil
+}
+
+// AssetName is the name of the release binary for a platform, such as publify-linux-amd64
+func AssetName(goos, goarch string) string {
+	name := "publify-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Download fetches the binary for this platform from a release, checks the signature
+// on the release's checksums and the binary against its checksum, and returns it
+func (u *Updater) Download(ctx context.Context, release *Release) ([]byte, error) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binaryAsset, ok := release.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", release.Version, runtime.GOOS, runtime.GOARCH)
+	}
+	checksums, ok := release.asset(checksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s", release.Version, checksumsAsset)
+	}
+	signature, ok := release.asset(signatureAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s isn't signed", release.Version)
+	}
+
+	checksumData, err := u.get(ctx, checksums.URL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+	signatureData, err := u.get(ctx, signature.URL, 1<<10)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download signature: %w", err)
+	}
+	if err := u.verifySignature(checksumData, signatureData); err != nil {
+		return nil, err
+	}
+
+	expected, err := findChecksum(checksumData, name)
+	if err != nil {
+		return nil, err
+	}
+	binary, err := u.get(ctx, binaryAsset.URL, maxBinarySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if sum := sha256.Sum256(binary); hex.EncodeToString(sum[:]) != expected {
+		return nil, fmt.Errorf("%s doesn't match its checksum, not installing it", name)
+	}
+	return binary, nil
+}
+
+// verifySignature checks the checksums were signed with the release key. The signature
+// may be raw or base64-encoded.
+func (u *Updater) verifySignature(checksums, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return fmt.Errorf("the release signature is malformed")
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(u.publicKey, checksums, signature) {
+		return fmt.Errorf("the release signature doesn't match, not installing it")
+	}
+	return nil
+}
+
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// findChecksum looks a file up in a sha256sum listing ("<hex>  <name>" per line)
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s in the release", name)
+}
+
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "publify-self-update")
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%s is larger than expected", url)
+	}
+	return body, nil
+}
+
+// Replace swaps the binary at path for a new one. The new binary is written next to it
+// and renamed into place, so an interrupted update leaves the old one working. Windows
+// won't replace a running executable, but will rename it out of the way.
+func Replace(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the installed binary: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".publify-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write the update next to %s: %w", path, err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(binary); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write the update: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write the update: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), info.Mode().Perm()|0111); err != nil {
+		return fmt.Errorf("failed to make the update executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("failed to move the old binary aside: %w", err)
+		}
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to install the update: %w", err)
+	}
+	return nil
+}
+
+// CompareVersions orders two versions such as v1.2.0 and 1.3.0-rc.1, returning -1, 0 or
+// 1. A pre-release comes before the release it leads up to.
+func CompareVersions(a, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		if c := compareNumbers(part(aParts, i), part(bParts, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	aIDs, bIDs := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < max(len(aIDs), len(bIDs)); i++ {
+		if i >= len(aIDs) {
+			return -1
+		}
+		if i >= len(bIDs) {
+			return 1
+		}
+		if c := compareNumbers(aIDs[i], bIDs[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func part(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareNumbers compares numerically where both are numbers, and as text otherwise
+func compareNumbers(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(aNum, bNum)
+	case aErr == nil:
+		return -1 // Numeric identifiers sort before alphanumeric ones
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,163 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"v1.2.0", "1.2.0", 0},
+		{"v1.10.0", "v1.9.3", 1},
+		{"v1.2", "v1.2.1", -1},
+		{"v2.0.0-rc.1", "v2.0.0", -1},
+		{"v2.0.0-rc.2", "v2.0.0-rc.10", -1},
+		{"v2.0.0-beta", "v2.0.0-alpha", 1},
+		{"v2.0.0-rc.1", "v1.9.9", 1},
+	}
+	for _, tt := range tests {
+		if c := CompareVersions(tt.a, tt.b); c != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, c, tt.expected)
+		}
+	}
+}
+
+// releaseServer serves a release feed and a signed release of binary
+func releaseServer(t *testing.T, binary []byte, key ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	sum := sha256.Sum256(binary)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  " + name + "\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, checksums))
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases":
+			assets := func(version string) []Asset {
+				return []Asset{
+					{Name: name, URL: server.URL + "/" + version + "/binary"},
+					{Name: checksumsAsset, URL: server.URL + "/" + version + "/checksums"},
+					{Name: signatureAsset, URL: server.URL + "/" + version + "/signature"},
+				}
+			}
+			json.NewEncoder(w).Encode([]Release{
+				{Version: "v1.3.0-rc.1", Prerelease: true, Assets: assets("v1.3.0-rc.1")},
+				{Version: "v1.4.0", Draft: true},
+				{Version: "v1.2.0", Assets: assets("v1.2.0")},
+				{Version: "v1.1.0", Assets: assets("v1.1.0")},
+			})
+		case "/v1.2.0/binary", "/v1.3.0-rc.1/binary":
+			w.Write(binary)
+		case "/v1.2.0/checksums", "/v1.3.0-rc.1/checksums":
+			w.Write(checksums)
+		case "/v1.2.0/signature", "/v1.3.0-rc.1/signature":
+			w.Write([]byte(signature))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpdaterLatestAndDownload(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("#!/bin/sh\necho publify\n")
+	server := releaseServer(t, binary, privateKey)
+	updater := newUpdater(server.Client(), server.URL+"/releases", publicKey)
+	ctx := context.Background()
+
+	stable, err := updater.Latest(ctx, ChannelStable)
+	if err != nil {
+		t.Fatalf("Latest() failed: %v", err)
+	}
+	if stable.Version != "v1.2.0" {
+		t.Errorf("Expected v1.2.0 on the stable channel, got %s", stable.Version)
+	}
+	prerelease, err := updater.Latest(ctx, ChannelPrerelease)
+	if err != nil {
+		t.Fatalf("Latest() failed: %v", err)
+	}
+	if prerelease.Version != "v1.3.0-rc.1" {
+		t.Errorf("Expected the release candidate on the prerelease channel, got %s", prerelease.Version)
+	}
+
+	downloaded, err := updater.Download(ctx, stable)
+	if err != nil {
+		t.Fatalf("Download() failed: %v", err)
+	}
+	if string(downloaded) != string(binary) {
+		t.Errorf("Downloaded %q", downloaded)
+	}
+
+	// Signed with another key
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	untrusted := newUpdater(server.Client(), server.URL+"/releases", otherKey)
+	if _, err := untrusted.Download(ctx, stable); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("Expected a signature error, got %v", err)
+	}
+}
+
+func TestDownloadRejectsTamperedBinary(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := releaseServer(t, []byte("genuine"), privateKey)
+	updater := newUpdater(server.Client(), server.URL+"/releases", publicKey)
+
+	release := &Release{Version: "v1.2.0", Assets: []Asset{
+		{Name: AssetName(runtime.GOOS, runtime.GOARCH), URL: server.URL + "/v1.2.0/checksums"}, // Not the binary
+		{Name: checksumsAsset, URL: server.URL + "/v1.2.0/checksums"},
+		{Name: signatureAsset, URL: server.URL + "/v1.2.0/signature"},
+	}}
+	if _, err := updater.Download(context.Background(), release); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "publify")
+	if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatalf("Replace() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("Expected the new binary installed, got %q (%v)", data, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected the new binary to be executable, got %v", info.Mode())
+	}
+}
+
+func TestNewWithoutKey(t *testing.T) {
+	if PublicKey != "" {
+		t.Skip("built with a release key")
+	}
+	if _, err := New(); err == nil {
+		t.Error("Expected an error for a build without a release key")
+	}
+}
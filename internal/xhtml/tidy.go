@@ -0,0 +1,269 @@
+package xhtml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// voidElements never have content; in XHTML they must be self-closed
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// closesParagraph lists elements that end an open <p> in HTML, which people rely on when
+// they write <p> without </p>
+var closesParagraph = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "dl": true, "table": true, "blockquote": true, "pre": true, "hr": true,
+	"section": true, "aside": true, "figure": true,
+}
+
+var (
+	entityRef = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	tagName   = regexp.MustCompile(`^</?([A-Za-z][-A-Za-z0-9_:.]*)`)
+)
+
+// xmlEntities are the only named entities XML knows without a DTD
+var xmlEntities = map[string]bool{"amp": true, "lt": true, "gt": true, "quot": true, "apos": true}
+
+// IsDocument reports whether a file name looks like an XHTML content document
+func IsDocument(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xhtml", ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// WellFormed returns nil if content parses as XML
+func WellFormed(content []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = true
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Tidy fixes the small mistakes hand editing leaves in XHTML: unclosed tags, stray end
+// tags, bare ampersands, HTML-only entities, <br> without a slash and unquoted attributes.
+// Well-formed documents are returned untouched. The fixes made are described in the
+// returned list; an error means the document is still broken afterwards.
+func Tidy(content []byte) ([]byte, []string, error) {
+	if WellFormed(content) == nil {
+		return content, nil, nil
+	}
+
+	t := &tidier{src: string(content)}
+	t.run()
+
+	result := []byte(t.out.String())
+	if err := WellFormed(result); err != nil {
+		return content, nil, fmt.Errorf("still not well-formed after tidying: %w", err)
+	}
+
+	return result, t.fixes(), nil
+}
+
+type tidier struct {
+	src   string
+	out   strings.Builder
+	stack []string
+
+	ampersands, entities, closed, stray, selfClosed, quoted int
+}
+
+func (t *tidier) run() {
+	s := t.src
+	for len(s) > 0 {
+		switch {
+		case s[0] == '&':
+			s = t.ampersand(s)
+		case s[0] == '<':
+			s = t.markup(s)
+		default:
+			next := strings.IndexAny(s, "&<")
+			if next == -1 {
+				next = len(s)
+			}
+			t.out.WriteString(s[:next])
+			s = s[next:]
+		}
+	}
+
+	for len(t.stack) > 0 {
+		t.closeTop()
+	}
+}
+
+// ampersand copies an entity reference, or escapes a bare ampersand
+func (t *tidier) ampersand(s string) string {
+	ref := entityRef.FindString(s)
+	if ref == "" {
+		t.out.WriteString("&amp;")
+		t.ampersands++
+		return s[1:]
+	}
+
+	name := ref[1 : len(ref)-1]
+	if name[0] != '#' && !xmlEntities[name] {
+		// &nbsp; and friends need a DTD that readers don't load; spell them out as numbers
+		if decoded := html.UnescapeString(ref); decoded != ref {
+			for _, r := range decoded {
+				fmt.Fprintf(&t.out, "&#%d;", r)
+			}
+			t.entities++
+			return s[len(ref):]
+		}
+		t.out.WriteString("&amp;")
+		t.ampersands++
+		return s[1:]
+	}
+
+	t.out.WriteString(ref)
+	return s[len(ref):]
+}
+
+// markup handles everything starting with '<'
+func (t *tidier) markup(s string) string {
+	for _, special := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}, {"<!", ">"}} {
+		if strings.HasPrefix(s, special[0]) {
+			end := strings.Index(s, special[1])
+			if end == -1 {
+				t.out.WriteString(s)
+				return ""
+			}
+			end += len(special[1])
+			t.out.WriteString(s[:end])
+			return s[end:]
+		}
+	}
+
+	match := tagName.FindStringSubmatch(s)
+	end := strings.IndexByte(s, '>')
+	if match == nil || end == -1 {
+		// A lone '<' in text
+		t.out.WriteString("&lt;")
+		t.ampersands++
+		return s[1:]
+	}
+
+	tag, name := s[:end+1], match[1]
+	if strings.HasPrefix(tag, "</") {
+		t.endTag(name)
+		return s[end+1:]
+	}
+
+	t.startTag(name, tag)
+	return s[end+1:]
+}
+
+func (t *tidier) startTag(name, tag string) {
+	lower := strings.ToLower(name)
+	if len(t.stack) > 0 {
+		top := strings.ToLower(t.stack[len(t.stack)-1])
+		if (top == "p" && closesParagraph[lower]) || (top == "li" && lower == "li") {
+			t.closeTop()
+		}
+	}
+
+	tag = t.fixAttributes(tag)
+
+	selfClosing := strings.HasSuffix(tag, "/>")
+	if voidElements[lower] && !selfClosing {
+		tag = strings.TrimSuffix(strings.TrimRight(strings.TrimSuffix(tag, ">"), " "), "/") + "/>"
+		t.selfClosed++
+		selfClosing = true
+	}
+
+	t.out.WriteString(tag)
+	if !selfClosing {
+		t.stack = append(t.stack, name)
+	}
+}
+
+// fixAttributes quotes unquoted attribute values (class=note) and escapes bare ampersands
+// in values (href="?a=1&b=2")
+func (t *tidier) fixAttributes(tag string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case c == '&' && entityRef.FindString(tag[i:]) == "":
+			b.WriteString("&amp;")
+			t.ampersands++
+			continue
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=' && i+1 < len(tag) && !strings.ContainsRune("\"' \t\n>", rune(tag[i+1])):
+			end := i + 1
+			for end < len(tag) && !strings.ContainsRune(" \t\n>", rune(tag[end])) && !strings.HasPrefix(tag[end:], "/>") {
+				end++
+			}
+			b.WriteString(`="` + t.fixAttributes(tag[i+1:end]) + `"`)
+			t.quoted++
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (t *tidier) endTag(name string) {
+	for i := len(t.stack) - 1; i >= 0; i-- {
+		if strings.EqualFold(t.stack[i], name) {
+			// Close whatever was left open inside it
+			for len(t.stack) > i+1 {
+				t.closeTop()
+			}
+			t.out.WriteString("</" + t.stack[i] + ">")
+			t.stack = t.stack[:i]
+			return
+		}
+	}
+
+	// Nothing to close; closing a void element is harmless, anything else was a mistake
+	if !voidElements[strings.ToLower(name)] {
+		t.stray++
+	}
+}
+
+func (t *tidier) closeTop() {
+	name := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	t.out.WriteString("</" + name + ">")
+	t.closed++
+}
+
+func (t *tidier) fixes() []string {
+	var fixes []string
+	add := func(count int, what string) {
+		if count > 0 {
+			fixes = append(fixes, fmt.Sprintf("%s: %d", what, count))
+		}
+	}
+	add(t.closed, "closed unclosed tags")
+	add(t.stray, "removed stray end tags")
+	add(t.ampersands, "escaped bare & and <")
+	add(t.entities, "replaced HTML entities")
+	add(t.selfClosed, "self-closed empty elements")
+	add(t.quoted, "quoted attribute values")
+	return fixes
+}
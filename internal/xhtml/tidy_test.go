@@ -0,0 +1,76 @@
+package xhtml
+
+import (
+	"testing"
+)
+
+func TestTidyLeavesWellFormedAlone(t *testing.T) {
+	doc := []byte(`<?xml version="1.0"?><html><body><p>Fish &amp; chips<br/></p></body></html>`)
+
+	result, fixes, err := Tidy(doc)
+	if err != nil {
+		t.Fatalf("Tidy failed: %v", err)
+	}
+	if string(result) != string(doc) || len(fixes) != 0 {
+		t.Errorf("Well-formed document was changed: %s (%v)", result, fixes)
+	}
+}
+
+func TestTidyFixesEditingMistakes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "bare ampersand",
+			in:   `<p>Fish & chips</p>`,
+			want: `<p>Fish &amp; chips</p>`,
+		},
+		{
+			name: "html entity",
+			in:   `<p>a&nbsp;b&mdash;c</p>`,
+			want: `<p>a&#160;b&#8212;c</p>`,
+		},
+		{
+			name: "void element",
+			in:   `<p>one<br>two<img src=a.png alt="x"></p>`,
+			want: `<p>one<br/>two<img src="a.png" alt="x"/></p>`,
+		},
+		{
+			name: "unclosed paragraphs",
+			in:   `<body><p>one<p>two<div>three</div></body>`,
+			want: `<body><p>one</p><p>two</p><div>three</div></body>`,
+		},
+		{
+			name: "unclosed inline",
+			in:   `<p>some <em>emphasis</p>`,
+			want: `<p>some <em>emphasis</em></p>`,
+		},
+		{
+			name: "stray end tag",
+			in:   `<p>text</i></p>`,
+			want: `<p>text</p>`,
+		},
+		{
+			name: "ampersand in attribute",
+			in:   `<p><a href="x?a=1&b=2">link</p>`,
+			want: `<p><a href="x?a=1&amp;b=2">link</a></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, fixes, err := Tidy([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Tidy failed: %v", err)
+			}
+			if string(result) != tt.want {
+				t.Errorf("Tidy(%q) = %q, want %q", tt.in, result, tt.want)
+			}
+			if len(fixes) == 0 {
+				t.Error("Expected the fixes to be reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"strings"
+)
+
+// Books are described with the schema.org accessibility properties EPUB Accessibility
+// asks for (https://www.w3.org/TR/epub-a11y-11/), worked out from what went into them:
+// whether they have pictures, whether every picture has a text description, and which
+// ways around the book they offer. Checkers such as Ace read them, and so do shops and
+// libraries deciding whether a book suits a reader.
+
+// ImageToDescribe is a picture in the book that needs a text description, for
+// Options.DescribeImage
+type ImageToDescribe struct {
+	// ID names the picture as the stable IDs do: page0015-figure1 for the first figure on
+	// page 15, page0012 for page 12 kept as an image
+	ID      string
+	Page    int
+	Image   image.Image
+	Caption string // Found with it in the PDF, used as its description otherwise ("" = none)
+	Text    string // The text of its page
+}
+
+// describeImage asks the DescribeImage hook for a picture's description ("" = no hook,
+// or it has none)
+func (eg *EPUBGenerator) describeImage(img ImageToDescribe) (string, error) {
+	if eg.options.DescribeImage == nil {
+		return "", nil
+	}
+	description, err := eg.options.DescribeImage(img)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe %s: %w", img.ID, err)
+	}
+	return strings.TrimSpace(description), nil
+}
+
+// UndescribedImages returns how many pictures in the book have no text description
+func (eg *EPUBGenerator) UndescribedImages() int {
+	return eg.undescribed
+}
+
+// accessibilityMetadata is the package metadata describing how the book can be read
+func (eg *EPUBGenerator) accessibilityMetadata() []string {
+	var meta []string
+	add := func(property, value string) {
+		meta = append(meta, fmt.Sprintf(`<meta property="schema:%s">%s</meta>`, property, html.EscapeString(value)))
+	}
+
+	// Pictures are seen; if they're all described, the text alone is enough
+	pictures := eg.imageCount > 0
+	add("accessMode", "textual")
+	if pictures {
+		add("accessMode", "visual")
+	}
+	if !pictures || eg.undescribed == 0 {
+		add("accessModeSufficient", "textual")
+	}
+	if pictures {
+		add("accessModeSufficient", "textual,visual")
+	}
+
+	add("accessibilityFeature", "tableOfContents")
+	add("accessibilityFeature", "structuralNavigation")
+	add("accessibilityFeature", "readingOrder")
+	if pictures && eg.undescribed == 0 {
+		add("accessibilityFeature", "alternativeText")
+	}
+	if len(eg.pages) > 0 {
+		add("accessibilityFeature", "pageBreakMarkers")
+		add("accessibilityFeature", "pageNavigation")
+	}
+
+	// Nothing is known about flashing or motion in the PDF's pictures
+	add("accessibilityHazard", "unknown")
+	add("accessibilitySummary", eg.accessibilitySummary())
+	return meta
+}
+
+// accessibilitySummary says in a sentence or two what the metadata says in properties
+func (eg *EPUBGenerator) accessibilitySummary() string {
+	summary := "Converted from a PDF. Chapters are in reading order, with a table of contents"
+	if len(eg.pages) > 0 {
+		summary += " and the print edition's page numbers"
+	}
+	summary += "."
+
+	switch {
+	case eg.imageCount == 0:
+	case eg.undescribed == 0:
+		summary += " Every picture has a text description."
+	case eg.undescribed == eg.imageCount:
+		summary += " Pictures have no text description."
+	default:
+		summary += fmt.Sprintf(" %d of %d pictures have no text description.", eg.undescribed, eg.imageCount)
+	}
+	return summary
+}
+
+// addAccessibilityMetadata adds the accessibility properties to the package metadata
+func addAccessibilityMetadata(content []byte, meta []string) []byte {
+	if len(meta) == 0 {
+		return content
+	}
+	var elements strings.Builder
+	for _, element := range meta {
+		elements.WriteString("    " + element + "\n")
+	}
+	elements.WriteString("  </metadata>")
+	return []byte(strings.Replace(string(content), "  </metadata>", elements.String(), 1))
+}
+
+// landmark is a place in the book a reader's "go to" menu offers
+type landmark struct {
+	epubType string
+	href     string // Relative to the navigation document
+	title    string
+}
+
+// landmarks are the cover, title page, table of contents and start of the text, where
+// the book has them
+func (eg *EPUBGenerator) landmarks() []landmark {
+	var marks []landmark
+	if eg.cover != nil {
+		marks = append(marks, landmark{"cover", coverPageHref, "Cover"})
+	}
+	if eg.titlePage {
+		marks = append(marks, landmark{"titlepage", "xhtml/titlepage.xhtml", "Title Page"})
+	}
+	marks = append(marks, landmark{"toc", "nav.xhtml#toc", "Table of Contents"})
+	if eg.firstChapter != "" {
+		marks = append(marks, landmark{"bodymatter", "xhtml/" + eg.firstChapter, "Start of Content"})
+	}
+	return marks
+}
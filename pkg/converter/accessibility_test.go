@@ -0,0 +1,163 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestAccessibilityMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		generator   EPUBGenerator
+		expected    []string
+		notExpected []string
+	}{
+		{
+			name:      "text only",
+			generator: EPUBGenerator{pages: []pageTarget{{label: "1"}}},
+			expected: []string{
+				`<meta property="schema:accessMode">textual</meta>`,
+				`<meta property="schema:accessModeSufficient">textual</meta>`,
+				`<meta property="schema:accessibilityFeature">pageNavigation</meta>`,
+				`<meta property="schema:accessibilityHazard">unknown</meta>`,
+				"with a table of contents and the print edition&#39;s page numbers.</meta>",
+			},
+			notExpected: []string{"visual", "alternativeText"},
+		},
+		{
+			name:      "described pictures",
+			generator: EPUBGenerator{imageCount: 3},
+			expected: []string{
+				`<meta property="schema:accessMode">visual</meta>`,
+				`<meta property="schema:accessModeSufficient">textual</meta>`,
+				`<meta property="schema:accessibilityFeature">alternativeText</meta>`,
+				"Every picture has a text description.",
+			},
+			notExpected: []string{"pageNavigation"},
+		},
+		{
+			name:      "undescribed pictures",
+			generator: EPUBGenerator{imageCount: 3, undescribed: 2},
+			expected: []string{
+				`<meta property="schema:accessModeSufficient">textual,visual</meta>`,
+				"2 of 3 pictures have no text description.",
+			},
+			notExpected: []string{"alternativeText", `accessModeSufficient">textual</meta>`},
+		},
+	}
+	for _, test := range tests {
+		meta := strings.Join(test.generator.accessibilityMetadata(), "\n")
+		for _, expected := range test.expected {
+			if !strings.Contains(meta, expected) {
+				t.Errorf("%s: expected %q in\n%s", test.name, expected, meta)
+			}
+		}
+		for _, unexpected := range test.notExpected {
+			if strings.Contains(meta, unexpected) {
+				t.Errorf("%s: didn't expect %q in\n%s", test.name, unexpected, meta)
+			}
+		}
+	}
+}
+
+func TestAddLandmarks(t *testing.T) {
+	eg := &EPUBGenerator{cover: &coverImage{}, titlePage: true, firstChapter: "page0009.xhtml"}
+	nav := string(addLandmarks([]byte("<body>\n</body>"), eg.landmarks()))
+
+	for _, expected := range []string{
+		`<nav epub:type="landmarks" hidden="">`,
+		`<a epub:type="cover" href="xhtml/cover.xhtml">Cover</a>`,
+		`<a epub:type="titlepage" href="xhtml/titlepage.xhtml">Title Page</a>`,
+		`<a epub:type="toc" href="nav.xhtml#toc">Table of Contents</a>`,
+		`<a epub:type="bodymatter" href="xhtml/page0009.xhtml">Start of Content</a>`,
+	} {
+		if !strings.Contains(nav, expected) {
+			t.Errorf("Expected %q in %s", expected, nav)
+		}
+	}
+	if strings.Index(nav, `"cover"`) > strings.Index(nav, `"bodymatter"`) {
+		t.Error("Expected the landmarks in reading order")
+	}
+
+	if plain := (&EPUBGenerator{}).landmarks()[0].epubType; plain != "toc" {
+		t.Errorf("Expected a book with no cover or title page to start with the toc landmark, got %s", plain)
+	}
+}
+
+func TestDescribeImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 120, 160))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	var asked []ImageToDescribe
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{
+		Title: "Mort",
+		DescribeImage: func(img ImageToDescribe) (string, error) {
+			asked = append(asked, img)
+			if img.ID == "page0002" {
+				return "Death on his horse Binky", nil
+			}
+			return "", nil
+		},
+	})
+	defer generator.Cleanup()
+
+	err := generator.AddChapter("Chapter 1", []PDFPage{
+		{Number: 1, Text: "Plate 1. The Disc\nseen from above", HasText: true, PageType: PageTypeImage, HasImage: true, ImageData: buf.Bytes()},
+		{Number: 2, PageType: PageTypeImage, HasImage: true, ImageData: buf.Bytes()},
+		{Number: 3, PageType: PageTypeImage, HasImage: true, ImageData: buf.Bytes()},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	if len(asked) != 3 || asked[0].ID != "page0001" || asked[0].Caption != "Plate 1. The Disc seen from above" || asked[0].Image == nil {
+		t.Errorf("Expected the hook asked about every page with its caption, got %+v", asked)
+	}
+	if generator.UndescribedImages() != 1 {
+		t.Errorf("Expected page 3 left undescribed, got %d", generator.UndescribedImages())
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "described.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	chapter := files["EPUB/xhtml/page0001.xhtml"]
+	for _, expected := range []string{`alt="Plate 1. The Disc seen from above"`, `alt="Death on his horse Binky"`, `alt="Page 3"`} {
+		if !strings.Contains(chapter, expected) {
+			t.Errorf("Expected %s in the chapter", expected)
+		}
+	}
+	if !strings.Contains(files[packagePath], "1 of 3 pictures have no text description.") {
+		t.Errorf("Expected the summary to count the undescribed picture, got %s", files[packagePath])
+	}
+	if !strings.Contains(files[navPath], `epub:type="landmarks"`) || !strings.Contains(files[navPath], `lang="en"`) {
+		t.Errorf("Expected landmarks and a language in the navigation document, got %s", files[navPath])
+	}
+}
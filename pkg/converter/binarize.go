@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	// textMidtoneLimit is the largest share of mid-gray pixels a page may have and still be
+	// plain text; photos and illustrations are mostly midtones
+	textMidtoneLimit = 0.08
+	// textInkMin and textInkMax bound the share of dark pixels on a page of text
+	textInkMin = 0.005
+	textInkMax = 0.35
+	// textMinContrast is the smallest ink-to-paper difference worth binarizing
+	textMinContrast = 60
+)
+
+// looksLikeText reports whether a grayscale page is ink on paper with next to no gray in
+// between. Scans rarely have white paper or black ink, so both are measured from the page.
+func looksLikeText(gray *image.Gray) bool {
+	histogram := grayHistogram(gray)
+	threshold := int(otsuThreshold(histogram))
+
+	var total, ink, inkSum, paperSum float64
+	for value, count := range histogram {
+		total += float64(count)
+		if value <= threshold {
+			ink += float64(count)
+			inkSum += float64(value * count)
+		} else {
+			paperSum += float64(value * count)
+		}
+	}
+	if ink == 0 || ink == total {
+		return false
+	}
+
+	inkLevel := inkSum / ink
+	paperLevel := paperSum / (total - ink)
+	contrast := paperLevel - inkLevel
+	if contrast < textMinContrast {
+		return false
+	}
+
+	// Midtones are the grays well clear of both the ink and the paper
+	var midtones float64
+	low, high := inkLevel+contrast/4, paperLevel-contrast/4
+	for value, count := range histogram {
+		if float64(value) > low && float64(value) < high {
+			midtones += float64(count)
+		}
+	}
+
+	inkShare := ink / total
+	return midtones/total <= textMidtoneLimit && inkShare >= textInkMin && inkShare <= textInkMax
+}
+
+// binarize turns a grayscale image into pure black and white using Otsu's threshold.
+// The two-color palette makes the PNG encoder write it with one bit per pixel.
+func binarize(gray *image.Gray) *image.Paletted {
+	threshold := otsuThreshold(grayHistogram(gray))
+
+	bounds := gray.Bounds()
+	result := image.NewPaletted(bounds, color.Palette{color.Black, color.White})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if gray.GrayAt(x, y).Y > threshold {
+				result.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return result
+}
+
+// grayImage converts any image to 8-bit grayscale
+func grayImage(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+func grayHistogram(gray *image.Gray) [256]int {
+	var histogram [256]int
+	bounds := gray.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := gray.Pix[(y-bounds.Min.Y)*gray.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			histogram[row[x]]++
+		}
+	}
+	return histogram
+}
+
+// otsuThreshold picks the gray level that best separates ink from paper
+func otsuThreshold(histogram [256]int) uint8 {
+	var total, weightedSum float64
+	for value, count := range histogram {
+		total += float64(count)
+		weightedSum += float64(value * count)
+	}
+
+	var background, backgroundSum, bestVariance float64
+	var best uint8
+	for value, count := range histogram {
+		background += float64(count)
+		if background == 0 {
+			continue
+		}
+		foreground := total - background
+		if foreground == 0 {
+			break
+		}
+
+		backgroundSum += float64(value * count)
+		meanBackground := backgroundSum / background
+		meanForeground := (weightedSum - backgroundSum) / foreground
+
+		variance := background * foreground * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if variance > bestVariance {
+			bestVariance = variance
+			best = uint8(value)
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// blankSamples is the width a page is scaled to for looking for anything on it, which
+	// also evens out scanner noise
+	blankSamples = 850
+	// blankContrast is how far from the paper a pixel must be to count as something printed.
+	// Bleed-through and paper texture stay well within it.
+	blankContrast = 64
+	// blankInkShare is the largest share of printed pixels a blank page may have: dust, a
+	// speck or two, a stray folio. A single short line of text is several times that.
+	blankInkShare = 0.0002
+	// minDuplicateText is how much text a text page needs before two of them the same count
+	// as the one page twice. Short pages ("Part One", "Notes") can repeat legitimately.
+	minDuplicateText = 100
+)
+
+// PageDuplicate is a page left out for being the same as an earlier one
+type PageDuplicate struct {
+	Page int
+	Of   int
+}
+
+// isBlankImage reports whether a rendered page is all paper, or all one color: the
+// backs of plates and the blank pages at the ends of a section, as scanned
+func isBlankImage(img image.Image) bool {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return true
+	}
+	gray := grayImage(imaging.Resize(img, min(blankSamples, bounds.Dx()), 0, imaging.Box))
+
+	// The paper is the level most of the page is at
+	histogram := grayHistogram(gray)
+	paper := 0
+	for level, count := range histogram {
+		if count > histogram[paper] {
+			paper = level
+		}
+	}
+
+	printed := 0
+	for level, count := range histogram {
+		if level <= paper-blankContrast || level >= paper+blankContrast {
+			printed += count
+		}
+	}
+	total := gray.Bounds().Dx() * gray.Bounds().Dy()
+	return float64(printed) <= float64(total)*blankInkShare
+}
+
+// pageFingerprint identifies what's on a page, so the same page scanned or included twice
+// can be found ("" = too little on it to tell). Call it before the page's images are
+// offloaded.
+func pageFingerprint(page PDFPage) string {
+	text := strings.TrimSpace(page.Text)
+	if len(page.ImageData) == 0 && len(page.Images) == 0 && len(text) < minDuplicateText {
+		return ""
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\x00%s\x00", page.PageType, text)
+	hash.Write(page.ImageData)
+	for _, figure := range page.Images {
+		fmt.Fprintf(hash, "\x00%v %.4f", figure.Image.Bounds(), figure.Position)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// removeRedundantPages leaves out blank image pages and pages that are the same as an
+// earlier one, returning the pages kept and those left out
+func removeRedundantPages(pages []PDFPage) ([]PDFPage, []int, []PageDuplicate) {
+	var blank []int
+	var duplicates []PageDuplicate
+	seen := make(map[string]int)
+
+	kept := make([]PDFPage, 0, len(pages))
+	for _, page := range pages {
+		if page.Blank {
+			blank = append(blank, page.Number)
+			continue
+		}
+		if page.fingerprint != "" {
+			if first, ok := seen[page.fingerprint]; ok {
+				duplicates = append(duplicates, PageDuplicate{Page: page.Number, Of: first})
+				continue
+			}
+			seen[page.fingerprint] = page.Number
+		}
+		kept = append(kept, page)
+	}
+	return kept, blank, duplicates
+}
+
+// formatPageDuplicates lists duplicates as "p57 (of p56), p90 (of p12)"
+func formatPageDuplicates(duplicates []PageDuplicate) string {
+	parts := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		parts[i] = fmt.Sprintf("p%d (of p%d)", duplicate.Page, duplicate.Of)
+	}
+	return strings.Join(parts, ", ")
+}
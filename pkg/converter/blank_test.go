@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestIsBlankImage(t *testing.T) {
+	// A scanned blank page: gray paper, grain, faint bleed-through and a speck of dust
+	rng := rand.New(rand.NewSource(1))
+	scan := image.NewGray(image.Rect(0, 0, 1700, 2200))
+	for i := range scan.Pix {
+		scan.Pix[i] = uint8(225 + rng.Intn(12))
+	}
+	draw.Draw(scan, image.Rect(200, 300, 1500, 1900), image.NewUniform(color.Gray{Y: 205}), image.Point{}, draw.Src)
+	draw.Draw(scan, image.Rect(900, 1000, 903, 1003), image.Black, image.Point{}, draw.Src)
+	if !isBlankImage(scan) {
+		t.Error("Expected a scanned blank page to be blank")
+	}
+
+	// A dedication: one short line in the middle of the page
+	dedication := pageWithInk(1700, 2200)
+	for x := 700; x < 1000; x += 30 {
+		draw.Draw(dedication, image.Rect(x, 1000, x+20, 1030), image.Black, image.Point{}, draw.Src)
+	}
+	if isBlankImage(dedication) {
+		t.Error("Expected a page with one short line not to be blank")
+	}
+
+	if !isBlankImage(pageWithInk(1700, 2200, image.Rect(0, 0, 1700, 2200))) {
+		t.Error("Expected an all-black page to be blank")
+	}
+}
+
+func TestRemoveRedundantPages(t *testing.T) {
+	text := strings.Repeat("It was a bright cold day in April, and the clocks were striking thirteen. ", 3)
+	pages := []PDFPage{
+		{Number: 1, Text: text},
+		{Number: 2, Text: text + "Winston Smith, his chin nuzzled into his breast."},
+		{Number: 3, Text: text}, // Scanned twice
+		{Number: 4, PageType: PageTypeImage, ImageData: []byte("plate"), Blank: true},
+		{Number: 5, PageType: PageTypeImage, ImageData: []byte("map")},
+		{Number: 6, PageType: PageTypeImage, ImageData: []byte("map")},
+		{Number: 7, Text: "Part Two"},
+		{Number: 8, Text: "Part Two"}, // Too short to call a duplicate
+	}
+	for i := range pages {
+		pages[i].fingerprint = pageFingerprint(pages[i])
+	}
+
+	kept, blank, duplicates := removeRedundantPages(pages)
+	if len(kept) != 5 {
+		t.Errorf("Expected 5 pages kept, got %d", len(kept))
+	}
+	if len(blank) != 1 || blank[0] != 4 {
+		t.Errorf("Expected page 4 left out as blank, got %v", blank)
+	}
+	if got := formatPageDuplicates(duplicates); got != "p3 (of p1), p6 (of p5)" {
+		t.Errorf("Unexpected duplicates %q", got)
+	}
+}
@@ -0,0 +1,233 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmaupin/go-epub"
+	"github.com/disintegration/imaging"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// budgetQualityStep is how much quality an image gives up each time it's re-encoded
+	budgetQualityStep = 10
+	// budgetFloorQuality is as low as the size budget takes image quality. Below it JPEG
+	// blocks and smeared text show on any screen.
+	budgetFloorQuality = 40
+	// budgetScaleStep is how much smaller an image is made each time, once it's at the
+	// floor quality (or is a PNG, where quality doesn't come into it)
+	budgetScaleStep = 0.8
+	// budgetFloorScale is as small as the size budget makes an image, of its optimized size
+	budgetFloorScale = 0.5
+)
+
+// SizeBudget is what keeping the book within its target size cost
+type SizeBudget struct {
+	Target    int64   // Size the book was to be kept within
+	Size      int64   // About what it came to
+	Reencoded int     // Images encoded again at a lower quality or size
+	Quality   int     // Lowest quality they went down to (0 = none went down)
+	Scaled    int     // Images made smaller
+	Scale     float64 // Smallest they were made, of their optimized size (0 = none were)
+}
+
+// Reached reports whether the book came within its target
+func (b SizeBudget) Reached() bool {
+	return b.Size <= b.Target
+}
+
+// budgetImage is an image in the book the size budget can encode again
+type budgetImage struct {
+	entry   string  // Path in the container
+	path    string  // The image as first optimized
+	format  string  // Format it's stored in, which stays the same
+	size    int64   // Bytes it comes to now
+	quality int     // Quality it's encoded at now (0 = as first optimized)
+	scale   float64 // Its size now, of its optimized size
+	data    []byte  // The image encoded again (nil = as first optimized)
+}
+
+// atFloor reports whether the image can't be made any smaller
+func (b *budgetImage) atFloor() bool {
+	lowest := b.format == "png" || (b.quality > 0 && b.quality <= budgetFloorQuality)
+	return lowest && b.scale*budgetScaleStep < budgetFloorScale
+}
+
+// SetTargetSize keeps the book within about bytes by encoding its largest images again at
+// lower quality, and smaller, as far as the floors allow (0 = no limit)
+func (eg *EPUBGenerator) SetTargetSize(bytes int64) {
+	eg.budget.Target = bytes
+}
+
+// SizeBudget returns what keeping to the target size cost, once the book is written
+func (eg *EPUBGenerator) SizeBudget() SizeBudget {
+	return eg.budget
+}
+
+// addToBudget notes an optimized image the size budget may encode again
+func (eg *EPUBGenerator) addToBudget(optimizedPath, internalPath string) {
+	eg.budgetImages = append(eg.budgetImages, &budgetImage{
+		entry:  "EPUB/" + epub.ImageFolderName + "/" + path.Base(internalPath),
+		path:   optimizedPath,
+		format: formatFromPath(optimizedPath),
+		scale:  1,
+	})
+}
+
+// formatFromPath is the format of an optimized image, by its extension
+func formatFromPath(imagePath string) string {
+	switch ext := strings.ToLower(filepath.Ext(imagePath)); ext {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	default:
+		return strings.TrimPrefix(ext, ".")
+	}
+}
+
+// fitBudget encodes images again, largest first, until a book of size bytes would come
+// within the target or every image is at the floor. The images are swapped in as the
+// book is repacked (see finalizeEntry).
+func (eg *EPUBGenerator) fitBudget(size int64) error {
+	eg.budget.Size = size
+	if eg.budget.Target <= 0 || size <= eg.budget.Target {
+		return nil
+	}
+
+	processor, err := eg.imageProcessor()
+	if err != nil {
+		return err
+	}
+	var imageBytes int64
+	for _, img := range eg.budgetImages {
+		info, err := os.Stat(img.path)
+		if err != nil {
+			return fmt.Errorf("failed to measure %s: %w", filepath.Base(img.path), err)
+		}
+		img.size = info.Size()
+		imageBytes += img.size
+	}
+	if size-imageBytes >= eg.budget.Target {
+		return nil // The text and fonts alone are over, so worse images wouldn't get there
+	}
+
+	for eg.budget.Size > eg.budget.Target {
+		var candidates []*budgetImage
+		for _, img := range eg.budgetImages {
+			if !img.atFloor() {
+				candidates = append(candidates, img)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].size > candidates[j].size })
+
+		excess := eg.budget.Size - eg.budget.Target
+		var freed int64
+		for _, img := range candidates {
+			if freed >= excess {
+				break
+			}
+			saved, err := processor.shrink(img)
+			if err != nil {
+				return fmt.Errorf("failed to re-encode %s: %w", filepath.Base(img.path), err)
+			}
+			freed += saved
+		}
+		eg.budget.Size -= freed
+	}
+
+	eg.budget.Reencoded, eg.budget.Scaled = 0, 0
+	for _, img := range eg.budgetImages {
+		if img.data == nil {
+			continue
+		}
+		eg.budget.Reencoded++
+		if img.quality > 0 && (eg.budget.Quality == 0 || img.quality < eg.budget.Quality) {
+			eg.budget.Quality = img.quality
+		}
+		if img.scale < 1 {
+			eg.budget.Scaled++
+			if eg.budget.Scale == 0 || img.scale < eg.budget.Scale {
+				eg.budget.Scale = img.scale
+			}
+		}
+	}
+	return nil
+}
+
+// budgetEntry returns the re-encoded image for a path in the container, if there is one
+func (eg *EPUBGenerator) budgetEntry(name string) ([]byte, bool) {
+	for _, img := range eg.budgetImages {
+		if img.entry == name && img.data != nil {
+			return img.data, true
+		}
+	}
+	return nil, false
+}
+
+// shrink encodes an image again one step down, lower quality first and then smaller, and
+// returns the bytes saved. Steps that don't come out smaller are passed over.
+func (ip *ImageProcessor) shrink(img *budgetImage) (int64, error) {
+	original, err := imaging.Open(img.path)
+	if err != nil {
+		return 0, err
+	}
+
+	settings := ip.profile.ImageProcessingSettings()
+	// The quality asked for here is the quality used
+	plain := *ip
+	plain.profile.Capabilities.AggressiveCompression = false
+
+	for !img.atFloor() {
+		if img.format != "png" && img.quality == 0 {
+			img.quality = settings.Quality
+		}
+		if img.format != "png" && img.quality > budgetFloorQuality {
+			img.quality = max(img.quality-budgetQualityStep, budgetFloorQuality)
+		} else {
+			img.scale *= budgetScaleStep
+		}
+
+		resized := image.Image(original)
+		if img.scale < 1 {
+			bounds := original.Bounds()
+			resized = imaging.Resize(original, max(1, int(float64(bounds.Dx())*img.scale)), 0, imaging.Lanczos)
+		}
+		settings.Quality = img.quality
+		var buf bytes.Buffer
+		if err := plain.encode(&buf, resized, img.format, settings); err != nil {
+			return 0, err
+		}
+
+		if size := int64(buf.Len()); size < img.size {
+			saved := img.size - size
+			img.data, img.size = buf.Bytes(), size
+			return saved, nil
+		}
+	}
+	return 0, nil
+}
+
+// formatSizeBudget describes what the size budget cost, as in "12 images re-encoded at
+// quality 60, 3 of them scaled to 64%, to keep within 2.1 MB"
+func formatSizeBudget(budget SizeBudget) string {
+	text := "1 image re-encoded"
+	if budget.Reencoded != 1 {
+		text = fmt.Sprintf("%d images re-encoded", budget.Reencoded)
+	}
+	if budget.Quality > 0 {
+		text += fmt.Sprintf(" at quality %d", budget.Quality)
+	}
+	if budget.Scaled > 0 {
+		text += fmt.Sprintf(", %d of them scaled to %.0f%%", budget.Scaled, budget.Scale*100)
+	}
+	return text + fmt.Sprintf(", to keep within %s", humanize.Bytes(uint64(budget.Target)))
+}
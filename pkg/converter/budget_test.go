@@ -0,0 +1,62 @@
+package converter
+
+import "testing"
+
+func TestBudgetImageAtFloor(t *testing.T) {
+	tests := []struct {
+		name  string
+		image budgetImage
+		want  bool
+	}{
+		{"as optimized", budgetImage{format: "jpeg", scale: 1}, false},
+		{"quality still to give", budgetImage{format: "jpeg", quality: 50, scale: 0.5}, false},
+		{"size still to give", budgetImage{format: "webp", quality: budgetFloorQuality, scale: 0.8}, false},
+		{"both given", budgetImage{format: "jpeg", quality: budgetFloorQuality, scale: 0.512}, true},
+		{"profile already below the floor", budgetImage{format: "jpeg", quality: 30, scale: 0.512}, true},
+		{"PNG as optimized", budgetImage{format: "png", scale: 1}, false},
+		{"PNG at half size", budgetImage{format: "png", scale: 0.512}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.image.atFloor(); got != tt.want {
+			t.Errorf("%s: atFloor() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"/tmp/page_0001.jpg":  "jpeg",
+		"/tmp/page_0001.JPEG": "jpeg",
+		"/tmp/page_0002.png":  "png",
+		"/tmp/figure_3.avif":  "avif",
+	} {
+		if got := formatFromPath(path); got != want {
+			t.Errorf("formatFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestBudgetEntry(t *testing.T) {
+	eg := &EPUBGenerator{}
+	eg.addToBudget("/tmp/work/page_0001.jpg", "../images/page_0001.jpg")
+	eg.addToBudget("/tmp/work/page_0002.jpg", "../images/page_0002.jpg")
+	eg.budgetImages[1].data = []byte("smaller")
+
+	if _, ok := eg.budgetEntry("EPUB/images/page_0001.jpg"); ok {
+		t.Error("Expected an image that wasn't re-encoded to be stored as it was")
+	}
+	if data, ok := eg.budgetEntry("EPUB/images/page_0002.jpg"); !ok || string(data) != "smaller" {
+		t.Error("Expected the re-encoded image swapped in")
+	}
+}
+
+func TestFormatSizeBudget(t *testing.T) {
+	budget := SizeBudget{Target: 2_100_000, Reencoded: 12, Quality: 60, Scaled: 3, Scale: 0.64}
+	if got := formatSizeBudget(budget); got != "12 images re-encoded at quality 60, 3 of them scaled to 64%, to keep within 2.1 MB" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+	png := SizeBudget{Target: 800_000, Reencoded: 1, Scaled: 1, Scale: 0.8}
+	if got := formatSizeBudget(png); got != "1 image re-encoded, 1 of them scaled to 80%, to keep within 800 kB" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
@@ -0,0 +1,268 @@
+package converter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/pkg/storage"
+)
+
+// checkpointStateFile describes what the pages in a checkpoint were extracted from
+const checkpointStateFile = "checkpoint.json"
+
+// CheckpointPath is where a conversion to outputPath keeps its checkpoint: a hidden
+// directory next to the output, so it's found again without being asked for
+func CheckpointPath(outputPath string) string {
+	return filepath.Join(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".checkpoint")
+}
+
+// CheckpointPrefix is where in a shared store a conversion to outputPath keeps its
+// checkpoint: under the output's name and a hash of its full path, so conversions to
+// the same file find it again and no others do
+func CheckpointPrefix(outputPath string) string {
+	if abs, err := filepath.Abs(outputPath); err == nil {
+		outputPath = abs
+	}
+	sum := sha256.Sum256([]byte(outputPath))
+	return "checkpoints/" + filepath.Base(outputPath) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// checkpointKey is everything that changes what a page extracts to. A checkpoint is only
+// resumed when it matches. The page selection isn't part of it: pages are stored one by
+// one, so a different --pages simply reuses the ones it shares.
+type checkpointKey struct {
+	InputSize             int64
+	InputModified         time.Time
+	ImagePageRange        string
+	EnableOCR             bool
+	OCRLanguage           string
+	OCREngine             string
+	OCRPreprocess         string
+	SkipPages             string
+	BleedThreshold        float64
+	DisableBleedDetection bool
+	SkipFigures           bool
+	SkipDescreen          bool
+	SkipStraighten        bool
+	SkipLayout            bool
+}
+
+// checkpointPage is a processed page as stored on disk
+type checkpointPage struct {
+	Number        int
+	Label         string
+	Text          string
+	Width         float64
+	Height        float64
+	HasText       bool
+	HasImage      bool
+	PageType      PageType
+	Centered      bool
+	Vertical      bool
+	OCR           bool
+	OCRConfidence int
+	Straightened  Straightening
+	Blank         bool
+	Rejected      bool // Failed bleed-through validation
+	ImageData     []byte
+	Figures       []checkpointFigure
+}
+
+type checkpointFigure struct {
+	PNG      []byte
+	Position float64
+}
+
+// checkpoint stores every processed page, so an interrupted conversion (Ctrl-C, a crash,
+// a laptop lid) can pick up where it stopped instead of redoing hours of OCR
+type checkpoint struct {
+	store   storage.Store
+	resumed map[int]bool // Pages found in the checkpoint when it was opened
+}
+
+// openCheckpoint starts a checkpoint in a store. With resume set, the pages already in it
+// are kept, provided they were extracted from the same file with the same settings;
+// otherwise it starts empty.
+func openCheckpoint(store storage.Store, key checkpointKey, resume bool) (*checkpoint, error) {
+	cp := &checkpoint{store: store, resumed: make(map[int]bool)}
+
+	if resume {
+		found, err := cp.matches(key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			if err := cp.scan(); err != nil {
+				return nil, err
+			}
+			return cp, nil
+		}
+	}
+
+	if err := storage.DeleteAll(store, ""); err != nil {
+		return nil, fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+	state, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := store.Put(checkpointStateFile, state); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+// matches reports whether there's a checkpoint for key. One for anything else is an error,
+// since resuming it would mix pages from two different conversions.
+func (cp *checkpoint) matches(key checkpointKey) (bool, error) {
+	data, err := cp.store.Get(checkpointStateFile)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var stored checkpointKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return false, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if stored.InputSize != key.InputSize || !stored.InputModified.Equal(key.InputModified) {
+		return false, fmt.Errorf("the checkpoint is for a different version of the PDF; run without --resume to start over")
+	}
+	stored.InputModified = key.InputModified
+	if stored != key {
+		return false, fmt.Errorf("the checkpoint was made with different settings; use the same ones, or run without --resume to start over")
+	}
+	return true, nil
+}
+
+// scan finds the pages already stored
+func (cp *checkpoint) scan() error {
+	keys, err := cp.store.List("page")
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	for _, key := range keys {
+		var number int
+		if _, err := fmt.Sscanf(key, "page%d.json", &number); err == nil && strings.HasSuffix(key, ".json") {
+			cp.resumed[number] = true
+		}
+	}
+	return nil
+}
+
+// resumedCount returns how many pages were found in the checkpoint
+func (cp *checkpoint) resumedCount() int {
+	if cp == nil {
+		return 0
+	}
+	return len(cp.resumed)
+}
+
+func pageKey(number int) string {
+	return fmt.Sprintf("page%04d.json", number)
+}
+
+// load returns a page from the checkpoint, if it was stored before this run
+func (cp *checkpoint) load(number int) (page PDFPage, rejected bool, ok bool, err error) {
+	if cp == nil || !cp.resumed[number] {
+		return PDFPage{}, false, false, nil
+	}
+
+	data, err := cp.store.Get(pageKey(number))
+	if err != nil {
+		return PDFPage{}, false, false, fmt.Errorf("failed to read page %d from checkpoint: %w", number, err)
+	}
+	var stored checkpointPage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return PDFPage{}, false, false, fmt.Errorf("failed to parse page %d in checkpoint: %w", number, err)
+	}
+
+	page = PDFPage{
+		Number:    stored.Number,
+		Label:     stored.Label,
+		Text:      stored.Text,
+		Width:     stored.Width,
+		Height:    stored.Height,
+		HasText:   stored.HasText,
+		HasImage:  stored.HasImage,
+		PageType:  stored.PageType,
+		Centered:  stored.Centered,
+		Vertical:  stored.Vertical,
+		ImageData: stored.ImageData,
+
+		OCR:           stored.OCR,
+		OCRConfidence: stored.OCRConfidence,
+		Straightened:  stored.Straightened,
+		Blank:         stored.Blank,
+	}
+	for i, figure := range stored.Figures {
+		img, err := png.Decode(bytes.NewReader(figure.PNG))
+		if err != nil {
+			return PDFPage{}, false, false, fmt.Errorf("failed to decode figure %d of page %d in checkpoint: %w", i+1, number, err)
+		}
+		page.Images = append(page.Images, PageImage{Image: img, Position: figure.Position})
+	}
+
+	return page, stored.Rejected, true, nil
+}
+
+// save stores a processed page. Stores replace values whole, so an interruption mid-write
+// loses that page and nothing else.
+func (cp *checkpoint) save(page PDFPage, rejected bool) error {
+	if cp == nil {
+		return nil
+	}
+
+	stored := checkpointPage{
+		Number:        page.Number,
+		Label:         page.Label,
+		Text:          page.Text,
+		Width:         page.Width,
+		Height:        page.Height,
+		HasText:       page.HasText,
+		HasImage:      page.HasImage,
+		PageType:      page.PageType,
+		Centered:      page.Centered,
+		Vertical:      page.Vertical,
+		OCR:           page.OCR,
+		OCRConfidence: page.OCRConfidence,
+		Straightened:  page.Straightened,
+		Blank:         page.Blank,
+		Rejected:      rejected,
+		ImageData:     page.ImageData,
+	}
+	for i, figure := range page.Images {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, figure.Image); err != nil {
+			return fmt.Errorf("failed to encode figure %d of page %d: %w", i+1, page.Number, err)
+		}
+		stored.Figures = append(stored.Figures, checkpointFigure{PNG: buf.Bytes(), Position: figure.Position})
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode page %d: %w", page.Number, err)
+	}
+	if err := cp.store.Put(pageKey(page.Number), data); err != nil {
+		return fmt.Errorf("failed to checkpoint page %d: %w", page.Number, err)
+	}
+	return nil
+}
+
+// remove deletes the checkpoint once the EPUB is written
+func (cp *checkpoint) remove() {
+	if cp != nil {
+		storage.DeleteAll(cp.store, "")
+	}
+}
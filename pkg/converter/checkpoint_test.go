@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alde/publify/pkg/storage"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".book.epub.checkpoint")
+	store := storage.NewDir(dir)
+	key := checkpointKey{InputSize: 1234, InputModified: time.Unix(1700000000, 0), EnableOCR: true, OCRLanguage: "eng"}
+
+	cp, err := openCheckpoint(store, key, false)
+	if err != nil {
+		t.Fatalf("openCheckpoint failed: %v", err)
+	}
+
+	figure := image.NewGray(image.Rect(0, 0, 2, 2))
+	pages := []PDFPage{
+		{Number: 3, Text: "Chapter One", HasText: true, Width: 612, Height: 792, Centered: true},
+		{Number: 4, PageType: PageTypeImage, HasImage: true, ImageData: []byte{0x89, 'P', 'N', 'G'}},
+		{Number: 5, Images: []PageImage{{Image: figure, Position: 0.25}}, HasImage: true},
+	}
+	for _, page := range pages {
+		if err := cp.save(page, page.Number == 4); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+
+	// A run that isn't resuming doesn't see them
+	if _, _, ok, _ := cp.load(3); ok {
+		t.Error("Expected pages saved in this run not to be loaded back")
+	}
+
+	resumed, err := openCheckpoint(store, key, true)
+	if err != nil {
+		t.Fatalf("openCheckpoint failed: %v", err)
+	}
+	if resumed.resumedCount() != 3 {
+		t.Errorf("Expected 3 resumed pages, got %d", resumed.resumedCount())
+	}
+
+	for _, page := range pages[:2] {
+		loaded, rejected, ok, err := resumed.load(page.Number)
+		if err != nil || !ok {
+			t.Fatalf("load(%d) = %v, %v", page.Number, ok, err)
+		}
+		if !reflect.DeepEqual(loaded, page) {
+			t.Errorf("load(%d) = %+v, expected %+v", page.Number, loaded, page)
+		}
+		if rejected != (page.Number == 4) {
+			t.Errorf("load(%d) rejected = %v", page.Number, rejected)
+		}
+	}
+	loaded, _, _, err := resumed.load(5)
+	if err != nil || len(loaded.Images) != 1 || loaded.Images[0].Position != 0.25 {
+		t.Errorf("Figure not restored: %+v (%v)", loaded.Images, err)
+	}
+	if _, _, ok, _ := resumed.load(6); ok {
+		t.Error("Expected no page 6 in the checkpoint")
+	}
+
+	changed := key
+	changed.OCRLanguage = "swe"
+	if _, err := openCheckpoint(store, changed, true); err == nil || !strings.Contains(err.Error(), "different settings") {
+		t.Errorf("Expected a settings mismatch, got %v", err)
+	}
+	changed = key
+	changed.InputSize++
+	if _, err := openCheckpoint(store, changed, true); err == nil || !strings.Contains(err.Error(), "different version") {
+		t.Errorf("Expected an input mismatch, got %v", err)
+	}
+
+	// Starting over clears it
+	if _, err := openCheckpoint(store, changed, false); err != nil {
+		t.Fatalf("openCheckpoint failed: %v", err)
+	}
+	fresh, err := openCheckpoint(store, changed, true)
+	if err != nil || fresh.resumedCount() != 0 {
+		t.Errorf("Expected an empty checkpoint after starting over, got %d pages (%v)", fresh.resumedCount(), err)
+	}
+
+	fresh.remove()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint directory removed, got %v", err)
+	}
+}
+
+func TestCheckpointPrefix(t *testing.T) {
+	prefix := CheckpointPrefix("/books/mort.epub")
+	if !strings.HasPrefix(prefix, "checkpoints/mort.epub-") {
+		t.Errorf("CheckpointPrefix = %q, expected it under checkpoints/ by the output's name", prefix)
+	}
+	if prefix != CheckpointPrefix("/books/mort.epub") || prefix == CheckpointPrefix("/other/mort.epub") {
+		t.Error("Expected the prefix to follow the output's full path")
+	}
+}
@@ -6,11 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/logging"
 	"github.com/alde/publify/internal/worker"
+	"github.com/alde/publify/pkg/fingerprint"
+	"github.com/alde/publify/pkg/metadata"
+	"github.com/alde/publify/pkg/progress"
 	"github.com/alde/publify/pkg/reader"
+	"github.com/alde/publify/pkg/storage"
 	"github.com/dustin/go-humanize"
 )
 
@@ -22,9 +29,130 @@ type Options struct {
 	WorkerCount    int
 	Verbose        bool
 	EnableOCR      bool
+	NoAutoOCR      bool // Don't turn OCR on for PDFs that look scanned
 	OCRLanguage    string
+	OCREngine      string          // Engine that reads the pages ("" = Tesseract)
+	OCRCredentials *OCRCredentials // Accounts for the cloud OCR engines
+	OCRPreprocess  string          // Clean-up steps for page images before OCR ("" = all)
+
+	// MinOCRConfidence marks pages OCR read with less mean word confidence than this
+	// (0-100) with a visible note in the book (0 = none are marked)
+	MinOCRConfidence int
+
 	ImagePageRange string
+	PageRange      string // Pages to convert, e.g. "10-250" ("" = all)
 	SkipPages      string
+
+	// Bleed-through detection (0 threshold = DefaultBleedThreshold)
+	BleedThreshold        float64
+	DisableBleedDetection bool
+
+	SkipFigures    bool // Don't carry embedded images from text pages into the EPUB
+	Fast           bool // Skip bleed-through detection, layout analysis, progress display and the output check
+	SkipDescreen   bool // Keep halftone dot patterns in image pages
+	SkipStraighten bool // Leave image pages turned and tilted as scanned (OCR has its own steps in OCRPreprocess)
+	KeepBlankPages bool // Keep blank image pages and pages that are the same as an earlier one
+	SkipSizeBudget bool // Keep image quality whatever size the book comes to against the profile's TargetSizeRatio
+	TextRender     bool // Store image pages that are plain text as 1-bit PNGs
+	TextLayer      bool // Put image pages' text (OCR or PDF) over them as an invisible layer
+
+	// FixedLayout gives image pages a fixed layout, sized to the reader's screen. Without
+	// an ImagePageRange every page is an image page.
+	FixedLayout bool
+
+	CropMargins  bool // Crop the blank paper around image pages
+	SplitSpreads bool // Split image pages of two pages side by side into two pages
+
+	// Characters the reader's fonts can't draw are drawn with FallbackFont if it has them
+	// ("" = no fallback font), or else transliterated if Transliterate is set. Either
+	// turns on the check, which is reported in the summary.
+	FallbackFont  string
+	Transliterate bool
+
+	// WritingMode sets the book in horizontal lines or vertical columns ("" = vertical for
+	// Japanese laid out in columns, on readers that support it)
+	WritingMode string
+
+	// Direction sets the book right to left or left to right whatever its language
+	// (DirectionAuto = right to left for languages written that way, Arabic and Hebrew)
+	Direction string
+
+	Compression  string // Output zip level: store, fast, default or best ("" = go-epub defaults)
+	Backup       bool   // Keep an existing output file as <output>.bak
+	StableNames  bool   // Derive generated names from the input file, the same on every run
+	Reproducible bool   // The same input converted the same way gives the same bytes (implies StableNames)
+	DryRun       bool   // Process the PDF and print the chapter plan without writing an EPUB
+	MaxSize      int64  // Warn up front if the EPUB looks set to be larger (0 = the reader's limit)
+	Resume       bool   // Reuse the pages an interrupted conversion to the same output got through
+	MemoryLimit  uint64 // Heap size up to which page images stay in memory (0 = to disk as they're processed)
+	ReadingWPM   int    // Reading speed the summary's reading time is estimated at (0 = metadata.DefaultWPM)
+
+	// Storage keeps checkpoints, under CheckpointPrefix, in a store shared between runs
+	// and machines (nil = a hidden directory next to the output)
+	Storage storage.Store
+
+	Cover     string // Cover image path, or CoverAuto to render one from the PDF
+	CoverPage int    // Page rendered for CoverAuto (0 = first page)
+
+	// Title and Author override the PDF's own metadata ("" = use the PDF's)
+	Title  string
+	Author string
+
+	// Templates for chapters, cover, title page and colophon (nil = built-in)
+	Templates *Templates
+
+	// CSS is a stylesheet linked from every chapter after the built-in styles, to set
+	// margins, line height, justification and the like ("" = none)
+	CSS string
+
+	// EmbedFonts are fonts (.ttf or .otf) embedded for a typeface the book needs, cut down
+	// to the characters it uses. The text is set in the first; CSS can use the others.
+	EmbedFonts []string
+
+	// ObfuscateFonts scrambles the embedded fonts with the IDPF algorithm
+	ObfuscateFonts bool
+
+	// Generated front and back matter
+	TitlePage   bool
+	Colophon    bool
+	Publisher   string
+	ToolVersion string // Publify version, noted in the colophon
+
+	// Owner stamps the book as this person's copy, in its rights statement, metadata and
+	// colophon ("" = no stamp). A name and email address make a copy passed around the
+	// family traceable.
+	Owner string
+
+	// OwnerMark also writes the owner faintly at the foot of every image page
+	OwnerMark bool
+
+	// FetchMetadata looks the book up online once its text is known (nil = no lookup).
+	// It may prompt the user; returning nil keeps the metadata from the PDF.
+	FetchMetadata func(query MetadataQuery) (*FetchedMetadata, error)
+
+	// DescribeImage supplies text descriptions for figures and image pages, used as their
+	// alt text (nil = captions found in the PDF only)
+	DescribeImage func(img ImageToDescribe) (string, error)
+
+	// ReviewChapters lets the user merge, split, rename and drop from the automatic
+	// chapter grouping before the EPUB is generated (nil = use it as is)
+	ReviewChapters func(review *ChapterReview) error
+}
+
+// MetadataQuery describes the book being converted, for online lookups
+type MetadataQuery struct {
+	ISBN   string // Found on the copyright page, if any
+	Title  string
+	Author string
+}
+
+// FetchedMetadata is what an online lookup found; empty fields are left alone
+type FetchedMetadata struct {
+	Title       string
+	Author      string
+	Description string
+	Publisher   string
+	CoverPath   string // Downloaded cover image, used unless a cover was given explicitly
 }
 
 // Converter handles the PDF to EPUB conversion process (with the thoroughness of a Swedish quality inspector)
@@ -32,27 +160,48 @@ type Converter struct {
 	options   Options
 	pdfProc   *PDFProcessor
 	epubGen   *EPUBGenerator
+	pdfMeta   PDFMetadata
+	names     *NameSource
 	stats     ConversionStats
 	startTime time.Time
 }
 
 // ConversionStats tracks conversion metrics (numbers that make developers feel accomplished)
 type ConversionStats struct {
-	InputFileSize    uint64
-	OutputFileSize   uint64
-	PageCount        int
-	ProcessedPages   int
-	ChapterCount     int
-	TextCharCount    int
-	ImageCount       int
-	ProcessingTime   time.Duration
-	CompressionRatio float64
+	InputFileSize     uint64
+	OutputFileSize    uint64
+	PageCount         int
+	ProcessedPages    int
+	ChapterCount      int
+	TextCharCount     int
+	WordCount         int
+	ChapterWords      []metadata.ChapterWords // Words in each chapter, in reading order
+	ReadingTime       time.Duration           // Estimated at Options.ReadingWPM
+	ImageCount        int
+	Images            ImageStats // What optimizing the figures and page images came to
+	UndescribedImages int        // Pictures with no text description
+	ProcessingTime    time.Duration
+	CompressionRatio  float64
+	Languages         []LanguageShare // Share of the text in each language, largest first
+	MissingGlyphs     GlyphReport     // Characters the reader's fonts can't draw
+	VerticalWriting   bool            // Set in vertical columns
+	RightToLeft       bool            // Set right to left
+
+	Degraded           []string         // Features left out for a missing dependency, and why
+	OCRPages           []PageConfidence // Pages whose text came from OCR, with its confidence
+	LowConfidencePages []int            // OCR'd pages marked as read below MinOCRConfidence
+	Corrections        []PageCorrection // Pages turned or levelled for OCR or as images
+	BlankPages         []int            // Blank image pages left out
+	DuplicatePages     []PageDuplicate  // Pages left out for being the same as an earlier one
+	SizeBudget         SizeBudget       // Images given up quality or size to keep to TargetSizeRatio
+	Rights             Rights           // What the PDF says about its licence, and who it was sold to
 }
 
 // New creates a new converter instance
 func New(opts Options) *Converter {
 	return &Converter{
 		options:   opts,
+		names:     RandomNameSource(),
 		startTime: time.Now(),
 	}
 }
@@ -74,17 +223,31 @@ func (c *Converter) Convert() error {
 	}
 	c.stats.InputFileSize = uint64(inputSize)
 
-	// Create worker pool with progress tracking (Swedish efficiency meets Go concurrency)
-	pool := worker.NewPoolWithProgress(c.options.WorkerCount, c.pdfProc.GetPageCount())
+	// Say what size to expect before the long part, while the settings can still change
+	c.reportEstimate(c.estimateOutputSize())
+
+	// Create worker pool with progress tracking (Swedish efficiency meets Go concurrency).
+	// Fast mode doesn't spend time drawing it, unless something is reading JSON progress.
+	var pool *worker.Pool
+	if c.options.Fast && !progress.JSONRequested() {
+		pool = worker.NewPool(c.workerCount())
+	} else {
+		pool = worker.NewPoolWithProgress(c.workerCount(), len(c.pdfProc.SelectedPages()))
+	}
 	pool.Start()
 	defer pool.Stop()
 
-	if c.options.Verbose {
-		fmt.Printf("Starting conversion of %s to %s\n", c.options.InputPath, c.options.OutputPath)
-		fmt.Printf("Target reader: %s (%s)\n", c.options.Profile.Name, c.options.Profile.Manufacturer)
-		fmt.Printf("Using %d worker goroutines\n", pool.WorkerCount())
+	if c.options.Resume {
+		if resumed := c.pdfProc.ResumedPages(); resumed > 0 {
+			console.Printf("Resuming with %s from the checkpoint\n", pluralPages(resumed))
+		} else {
+			console.Printf("No checkpoint to resume, starting from the first page\n")
+		}
 	}
 
+	logging.Module("converter").Debug("Starting conversion", "input", c.options.InputPath, "output", c.options.OutputPath,
+		"reader", c.options.Profile.Name, "workers", pool.WorkerCount())
+
 	// Process PDF pages (where the magic happens, or at least where we pretend it does)
 	pages, err := c.pdfProc.ProcessPages(ctx, pool, nil) // Progress handled by worker pool now
 	if err != nil {
@@ -93,9 +256,35 @@ func (c *Converter) Convert() error {
 
 	c.stats.PageCount = len(pages)
 	c.stats.ProcessedPages = len(pages)
+	if !c.options.KeepBlankPages {
+		pages, c.stats.BlankPages, c.stats.DuplicatePages = removeRedundantPages(pages)
+	}
+	c.stats.OCRPages = ocrConfidences(pages)
+	c.stats.LowConfidencePages = lowConfidencePages(c.stats.OCRPages, c.options.MinOCRConfidence)
+	c.stats.Corrections = pageCorrections(pages)
+	c.stats.Rights = c.rights(pages)
 
-	if c.options.Verbose {
-		fmt.Printf("\nProcessed %d pages\n", len(pages))
+	logging.Module("converter").Debug("Processed pages", "pages", len(pages), "ocr", len(c.stats.OCRPages))
+
+	if c.options.DryRun {
+		if len(pages) == 0 {
+			return fmt.Errorf("no pages to convert")
+		}
+		c.displayPlan(c.buildPlan(pages))
+		return nil
+	}
+
+	if c.options.FetchMetadata != nil {
+		// The catalogues being out of reach isn't worth the conversion
+		if err := c.fetchMetadata(pages); err != nil {
+			c.degrade(fmt.Sprintf("Metadata lookup failed (%v), so the book keeps the PDF's own metadata", err))
+		}
+	}
+
+	if c.options.TitlePage {
+		if err := c.epubGen.AddTitlePage(); err != nil {
+			return fmt.Errorf("EPUB generation failed: %w", err)
+		}
 	}
 
 	// Generate EPUB content
@@ -103,16 +292,44 @@ func (c *Converter) Convert() error {
 		return fmt.Errorf("EPUB generation failed: %w", err)
 	}
 
+	if c.options.Colophon {
+		if err := c.epubGen.AddColophon(c.colophonSummary(), c.colophonSettings()); err != nil {
+			return fmt.Errorf("EPUB generation failed: %w", err)
+		}
+	}
+
+	if err := c.addCover(); err != nil {
+		return fmt.Errorf("failed to add cover: %w", err)
+	}
+
 	// Write EPUB file
+	if ratio := c.options.Profile.Capabilities.TargetSizeRatio; ratio > 0 && !c.options.SkipSizeBudget {
+		c.epubGen.SetTargetSize(int64(float64(c.stats.InputFileSize) * ratio))
+	}
 	if err := c.epubGen.Write(c.options.OutputPath); err != nil {
 		return fmt.Errorf("failed to write EPUB: %w", err)
 	}
+	c.stats.SizeBudget = c.epubGen.SizeBudget()
+	c.pdfProc.RemoveCheckpoint()
 
 	// Calculate final statistics
 	if err := c.calculateFinalStats(); err != nil {
 		return fmt.Errorf("failed to calculate final statistics: %w", err)
 	}
 
+	// Make sure the chunking kept every content document within the reader's limits.
+	// It means reading the whole EPUB back, which fast mode leaves to publify compress --reader.
+	if !c.options.Fast {
+		optimizer := NewEPUBOptimizer(c.options.Profile)
+		warnings, err := optimizer.CheckContentDocuments(c.options.OutputPath)
+		if err != nil {
+			return fmt.Errorf("failed to check content documents: %w", err)
+		}
+		for _, warning := range warnings {
+			logging.Module("converter").Warn("Content document over the reader's limit", "path", warning.Path, "size", warning.Size, "limit", warning.MaxBytes)
+		}
+	}
+
 	// Display results
 	c.displayResults()
 
@@ -121,33 +338,344 @@ func (c *Converter) Convert() error {
 
 // initialize sets up the converter components
 func (c *Converter) initialize() error {
+	if c.options.StableNames || c.options.Reproducible {
+		names, err := StableNameSource(c.options.InputPath)
+		if err != nil {
+			return err
+		}
+		c.names = names
+	}
+
 	// Initialize PDF processor with image page ranges and OCR options
-	pdfProc, err := NewPDFProcessor(c.options.InputPath, c.options.ImagePageRange, c.options.EnableOCR, c.options.OCRLanguage, c.options.SkipPages)
+	pdfProc, err := NewPDFProcessor(c.options.InputPath, PDFProcessorOptions{
+		ImagePageRange:        c.options.ImagePageRange,
+		AllImagePages:         c.options.FixedLayout && c.options.ImagePageRange == "",
+		PageRange:             c.options.PageRange,
+		EnableOCR:             c.options.EnableOCR,
+		AutoOCR:               !c.options.NoAutoOCR,
+		OCRLanguage:           c.options.OCRLanguage,
+		OCREngine:             c.options.OCREngine,
+		OCRCredentials:        c.options.OCRCredentials,
+		OCRPreprocess:         c.options.OCRPreprocess,
+		SkipPages:             c.options.SkipPages,
+		BleedThreshold:        c.options.BleedThreshold,
+		DisableBleedDetection: c.options.DisableBleedDetection || c.options.Fast,
+		SkipFigures:           c.options.SkipFigures,
+		SkipDescreen:          c.options.SkipDescreen,
+		SkipStraighten:        c.options.SkipStraighten,
+		SkipLayout:            c.options.Fast,
+		Workers:               c.workerCount(),
+		Checkpoint:            c.checkpointStore(),
+		Resume:                c.options.Resume,
+		MemoryLimit:           c.options.MemoryLimit,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create PDF processor: %w", err)
 	}
 	c.pdfProc = pdfProc
 
+	// OCR may have been turned on for a scan, or off for want of Tesseract
+	c.options.EnableOCR = pdfProc.OCREnabled()
+	if pdfProc.OCRAutoEnabled() {
+		console.Printf("The PDF looks scanned, reading its pages with OCR (%s)\n", c.options.OCRLanguage)
+	}
+	c.stats.Degraded = append(c.stats.Degraded, pdfProc.Degraded()...)
+
+	// Missing metadata isn't fatal, we just fall back to the filename
+	pdfMeta, err := pdfProc.Metadata()
+	if err != nil {
+		logging.Module("converter").Info("Could not read PDF metadata", "err", err)
+	}
+	c.pdfMeta = pdfMeta
+
+	if !pdfMeta.IsEmpty() {
+		logging.Module("converter").Debug("PDF metadata", "title", pdfMeta.Title, "author", pdfMeta.Author)
+	}
+
+	// Cloud OCR hands the pages to someone else, which a licence may well not allow. Only
+	// the metadata is known yet; stamps on the pages turn up once they've been sent.
+	if c.options.EnableOCR && c.options.OCREngine != "" && c.options.OCREngine != OCREngineTesseract && c.rights(nil).Licensed() {
+		logging.Module("converter").Warn("The PDF's rights metadata restricts it to its owner, and its pages will be sent to a cloud OCR service",
+			"engine", c.options.OCREngine, "rights", pdfMeta.Rights, "terms", pdfMeta.UsageTerms)
+	}
+
 	// Create EPUB options from input file
 	epubOpts := c.createEPUBOptions()
 
 	// Initialize EPUB generator
 	c.epubGen = NewEPUBGenerator(c.options.Profile, epubOpts)
 
+	if c.options.FallbackFont != "" || c.options.Transliterate {
+		if err := c.epubGen.SetGlyphFallback(c.options.FallbackFont, c.options.Transliterate); err != nil {
+			return err
+		}
+	}
+
+	if len(c.options.EmbedFonts) > 0 {
+		if err := c.epubGen.SetEmbeddedFonts(c.options.EmbedFonts); err != nil {
+			return err
+		}
+	}
+
+	if c.options.CSS != "" {
+		if err := c.epubGen.SetCustomStylesheet(c.options.CSS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// workerCount is how many pages are processed at once
+func (c *Converter) workerCount() int {
+	if c.options.WorkerCount > 0 {
+		return c.options.WorkerCount
+	}
+	return runtime.NumCPU()
+}
+
+// checkpointStore is where processed pages are kept until the EPUB is written. A dry run
+// writes nothing, so it keeps no checkpoint either.
+func (c *Converter) checkpointStore() storage.Store {
+	if c.options.DryRun || c.options.OutputPath == "" {
+		return nil
+	}
+	if c.options.Storage != nil {
+		return storage.Sub(c.options.Storage, CheckpointPrefix(c.options.OutputPath))
+	}
+	return storage.NewDir(CheckpointPath(c.options.OutputPath))
+}
+
+// addCover sets the EPUB cover from an image file or a rendered PDF page
+func (c *Converter) addCover() error {
+	if c.options.Cover != CoverAuto {
+		return c.epubGen.SetCover(c.options.Cover)
+	}
+
+	coverPage := c.options.CoverPage
+	if coverPage == 0 {
+		coverPage = 1
+	}
+
+	img, err := c.pdfProc.RenderCover(coverPage)
+	if err != nil {
+		return err
+	}
+
+	logging.Module("converter").Debug("Rendered cover", "page", coverPage, "width", img.Bounds().Dx(), "height", img.Bounds().Dy())
+
+	return c.epubGen.SetCoverImage(img)
+}
+
+// fetchMetadata looks the book up online and applies whatever the lookup returns
+func (c *Converter) fetchMetadata(pages []PDFPage) error {
+	// The ISBN lives on the copyright page, somewhere in the front matter
+	var frontMatter strings.Builder
+	for _, page := range pages {
+		if page.Number > frontMatterPageLimit {
+			break
+		}
+		frontMatter.WriteString(page.Text)
+		frontMatter.WriteString("\n")
+	}
+
+	current := c.epubGen.GetMetadata()
+	fetched, err := c.options.FetchMetadata(MetadataQuery{
+		ISBN:   metadata.FindISBN(frontMatter.String()),
+		Title:  current.Title,
+		Author: current.Author,
+	})
+	if err != nil || fetched == nil {
+		return err
+	}
+
+	// A publisher given on the command line wins over the catalogue's
+	if c.options.Publisher != "" {
+		fetched.Publisher = ""
+	}
+
+	for name, value := range map[string]string{
+		"title":       fetched.Title,
+		"author":      fetched.Author,
+		"description": fetched.Description,
+		"publisher":   fetched.Publisher,
+	} {
+		if value != "" {
+			c.epubGen.AddMetadata(name, value)
+		}
+	}
+
+	if fetched.CoverPath != "" && c.options.Cover == "" {
+		c.options.Cover = fetched.CoverPath
+	}
+
 	return nil
 }
 
-// createEPUBOptions creates EPUB options from the input file
+// colophonSummary describes where the book came from, for the colophon
+func (c *Converter) colophonSummary() string {
+	tool := "Publify"
+	if c.options.ToolVersion != "" {
+		tool += " " + c.options.ToolVersion
+	}
+
+	summary := fmt.Sprintf("This edition was converted from %s on %s using %s.",
+		filepath.Base(c.options.InputPath), c.startTime.Format("2 January 2006"), tool)
+	if c.options.Reproducible {
+		// The day it was converted on would make every run different
+		summary = fmt.Sprintf("This edition was converted from %s using %s.", filepath.Base(c.options.InputPath), tool)
+	}
+	if c.options.Owner != "" {
+		summary += fmt.Sprintf(" It is the personal copy of %s.", c.options.Owner)
+	}
+	return summary
+}
+
+// colophonSettings lists the conversion settings that shaped this edition
+func (c *Converter) colophonSettings() []string {
+	settings := []string{fmt.Sprintf("Optimized for %s", c.options.Profile.Name)}
+
+	if c.options.EnableOCR {
+		if c.options.OCREngine == "" || c.options.OCREngine == OCREngineTesseract {
+			settings = append(settings, fmt.Sprintf("Text recognized with OCR (%s)", c.options.OCRLanguage))
+		} else {
+			settings = append(settings, fmt.Sprintf("Text recognized with %s OCR (%s)", c.options.OCREngine, c.options.OCRLanguage))
+		}
+	}
+	if len(c.stats.LowConfidencePages) > 0 {
+		settings = append(settings, fmt.Sprintf("Pages OCR read with under %d%% confidence are marked", c.options.MinOCRConfidence))
+	}
+	if c.options.PageRange != "" {
+		settings = append(settings, fmt.Sprintf("Pages converted: %s", c.options.PageRange))
+	}
+	if c.options.ImagePageRange != "" {
+		settings = append(settings, fmt.Sprintf("Pages kept as images: %s", c.options.ImagePageRange))
+	}
+	if c.options.FixedLayout {
+		settings = append(settings, "Image pages laid out as printed (fixed layout)")
+	}
+	if c.options.CropMargins {
+		settings = append(settings, "Margins cropped from image pages")
+	}
+	if c.options.SplitSpreads {
+		settings = append(settings, "Two-page spreads split into single pages")
+	}
+	if c.options.SkipPages != "" {
+		settings = append(settings, fmt.Sprintf("Pages left out: %s", c.options.SkipPages))
+	}
+	if c.options.Fast {
+		settings = append(settings, "Fast mode: no bleed-through detection or layout analysis")
+	} else if c.options.DisableBleedDetection {
+		settings = append(settings, "Bleed-through detection disabled")
+	}
+	if c.options.SkipFigures {
+		settings = append(settings, "Embedded figures left out")
+	}
+	if c.options.TextRender {
+		settings = append(settings, "Text image pages stored as black and white")
+	}
+	if c.options.TextLayer {
+		settings = append(settings, "Image pages carry an invisible text layer for search")
+	}
+	if c.options.CSS != "" {
+		settings = append(settings, fmt.Sprintf("Styled with %s", filepath.Base(c.options.CSS)))
+	}
+	if len(c.options.EmbedFonts) > 0 {
+		names := make([]string, len(c.options.EmbedFonts))
+		for i, font := range c.options.EmbedFonts {
+			names[i] = filepath.Base(font)
+		}
+		settings = append(settings, fmt.Sprintf("Fonts embedded: %s", strings.Join(names, ", ")))
+	}
+	if c.options.SkipDescreen {
+		settings = append(settings, "Halftone descreening disabled")
+	}
+	if c.options.SkipStraighten {
+		settings = append(settings, "Image page straightening disabled")
+	}
+	if c.options.KeepBlankPages {
+		settings = append(settings, "Blank and duplicate pages kept")
+	}
+	if c.options.SkipSizeBudget {
+		settings = append(settings, "Image quality kept whatever the book's size")
+	}
+	if c.options.FallbackFont != "" {
+		settings = append(settings, "Characters the reader's fonts lack drawn with an embedded fallback font")
+	}
+	if c.options.Transliterate {
+		settings = append(settings, "Characters the reader's fonts lack transliterated")
+	}
+	if c.stats.VerticalWriting {
+		settings = append(settings, "Set in vertical columns, read right to left")
+	}
+	switch c.options.Direction {
+	case DirectionRTL:
+		settings = append(settings, "Set right to left")
+	case DirectionLTR:
+		settings = append(settings, "Set left to right")
+	}
+
+	return settings
+}
+
+// createEPUBOptions creates EPUB options from the input file, preferring the PDF's own metadata
 func (c *Converter) createEPUBOptions() EPUBOptions {
 	inputName := filepath.Base(c.options.InputPath)
 	title := strings.TrimSuffix(inputName, filepath.Ext(inputName))
+	author := "Unknown Author"
+	description := fmt.Sprintf("Converted from %s by Publify", inputName)
+
+	if c.pdfMeta.Title != "" {
+		title = c.pdfMeta.Title
+	}
+	if c.pdfMeta.Author != "" {
+		author = c.pdfMeta.Author
+	}
+	if c.pdfMeta.Subject != "" {
+		description = c.pdfMeta.Subject
+	}
+	if c.options.Title != "" {
+		title = c.options.Title
+	}
+	if c.options.Author != "" {
+		author = c.options.Author
+	}
 
 	return EPUBOptions{
 		Title:       title,
-		Author:      "Unknown Author",
+		Author:      author,
 		Language:    "en",
-		Identifier:  fmt.Sprintf("publify-%d", time.Now().Unix()),
-		Description: fmt.Sprintf("Converted from %s by Publify", inputName),
+		Identifier:  c.names.Identifier(),
+		Description: description,
+		Compression: c.options.Compression,
+		TextRender:  c.options.TextRender,
+		TextLayer:   c.options.TextLayer,
+		FixedLayout: c.options.FixedLayout,
+		Subjects:    c.pdfMeta.Keywords,
+		Publisher:   c.options.Publisher,
+		Rights:      rightsStatement(c.pdfMeta.Rights, c.options.Owner),
+		Owner:       c.options.Owner,
+		OwnerMark:   c.options.OwnerMark,
+		Backup:      c.options.Backup,
+		Templates:   c.options.Templates,
+
+		Reproducible: c.options.Reproducible,
+
+		MinOCRConfidence: c.options.MinOCRConfidence,
+		ObfuscateFonts:   c.options.ObfuscateFonts,
+		DescribeImage:    c.options.DescribeImage,
+		CropMargins:      c.options.CropMargins,
+		SplitSpreads:     c.options.SplitSpreads,
+	}
+}
+
+// rights gathers what the PDF says about its licence with the stamps on its pages
+func (c *Converter) rights(pages []PDFPage) Rights {
+	return Rights{
+		Statement:  c.pdfMeta.Rights,
+		UsageTerms: c.pdfMeta.UsageTerms,
+		Marked:     c.pdfMeta.RightsMarked,
+		Watermarks: findWatermarks(pages),
 	}
 }
 
@@ -160,18 +688,78 @@ func (c *Converter) generateEPUB(pages []PDFPage) error {
 	// Group pages into reasonable chapters (because nobody wants 200 tiny chapters)
 	chapters := c.groupPagesIntoChapters(pages)
 
+	var titles []string
+	if c.options.ReviewChapters != nil {
+		review := newChapterReview(chapters)
+		if err := c.options.ReviewChapters(review); err != nil {
+			return err
+		}
+		titles, chapters = review.result()
+		if len(chapters) == 0 {
+			return fmt.Errorf("no pages left to convert after review")
+		}
+	}
+
+	// The book is in the language most of it is written in; chapters and passages in
+	// another one are tagged as they're added
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\n")
+	}
+	language, _ := detectLanguage(text.String())
+	if language != "" {
+		c.epubGen.SetLanguage(language)
+	}
+	c.epubGen.SetFingerprint(fingerprint.Compute(text.String()).String())
+
+	// Japanese set in columns stays in columns, where the reader can show them
+	if c.verticalWriting(pages, language) {
+		c.epubGen.SetVerticalWriting()
+		c.stats.VerticalWriting = true
+	}
+
+	// Arabic and Hebrew run right to left, pages and all
+	if c.rightToLeft(language) {
+		c.epubGen.SetRightToLeft()
+		c.stats.RightToLeft = true
+	}
+
+	// Encoding images is most of the work in a book of scans, and pages are added one
+	// after the other, so their images are optimized ahead on every core
+	var kept []PDFPage
+	for _, chapter := range chapters {
+		kept = append(kept, chapter...)
+	}
+	if err := c.epubGen.optimizeImages(kept, c.workerCount()); err != nil {
+		return fmt.Errorf("failed to optimize images: %w", err)
+	}
+
 	for i, chapter := range chapters {
 		chapterTitle := fmt.Sprintf("Chapter %d", i+1)
+		if titles != nil {
+			chapterTitle = titles[i]
+		}
 		if err := c.epubGen.AddChapter(chapterTitle, chapter); err != nil {
 			return fmt.Errorf("failed to add chapter %d: %w", i+1, err)
 		}
 
 		// Update statistics
+		words := 0
 		for _, page := range chapter {
 			c.stats.TextCharCount += len(page.Text)
+			words += metadata.CountWords(page.Text)
 		}
 		c.stats.ChapterCount++
+		c.stats.WordCount += words
+		c.stats.ChapterWords = append(c.stats.ChapterWords, metadata.ChapterWords{Title: chapterTitle, Words: words})
 	}
+	c.stats.ReadingTime = metadata.ReadingTime(c.stats.WordCount, c.options.ReadingWPM)
+	c.stats.ImageCount = c.epubGen.ImageCount()
+	c.stats.Images = c.epubGen.ImageStats()
+	c.stats.UndescribedImages = c.epubGen.UndescribedImages()
+	c.stats.Languages = c.epubGen.LanguageMix()
+	c.stats.MissingGlyphs = c.epubGen.GlyphReport()
 
 	// Validate EPUB before writing
 	if err := c.epubGen.Validate(); err != nil {
@@ -181,6 +769,28 @@ func (c *Converter) generateEPUB(pages []PDFPage) error {
 	return nil
 }
 
+// rightToLeft decides whether the book runs right to left
+func (c *Converter) rightToLeft(language string) bool {
+	switch c.options.Direction {
+	case DirectionRTL:
+		return true
+	case DirectionLTR:
+		return false
+	}
+	return languageDirection(language) == directionRTL
+}
+
+// verticalWriting decides whether the book is set in vertical columns
+func (c *Converter) verticalWriting(pages []PDFPage, language string) bool {
+	switch c.options.WritingMode {
+	case WritingModeVertical:
+		return true
+	case WritingModeHorizontal:
+		return false
+	}
+	return language == "ja" && c.options.Profile.Capabilities.SupportsVerticalWriting && isVerticalBook(pages)
+}
+
 // calculateFinalStats computes final conversion statistics
 func (c *Converter) calculateFinalStats() error {
 	// Get output file size
@@ -201,45 +811,184 @@ func (c *Converter) calculateFinalStats() error {
 	return nil
 }
 
+// degrade warns that a feature was left out because something it needs is missing, and
+// notes it for the summary
+func (c *Converter) degrade(warning string) {
+	logging.Module("converter").Warn(warning)
+	c.stats.Degraded = append(c.stats.Degraded, warning)
+}
+
 // displayResults shows the conversion results
 func (c *Converter) displayResults() {
-	fmt.Printf("\nConversion completed successfully\n")
-	fmt.Printf("================================================================\n")
-	fmt.Printf("Conversion Summary\n")
-	fmt.Printf("================================================================\n")
+	console.Printf("\nConversion completed successfully\n")
+	console.Printf("================================================================\n")
+	console.Printf("Conversion Summary\n")
+	console.Printf("================================================================\n")
 
 	// File sizes
-	fmt.Printf("Input:         %s (%s)\n", filepath.Base(c.options.InputPath), humanize.Bytes(c.stats.InputFileSize))
-	fmt.Printf("Output:        %s (%s)\n", filepath.Base(c.options.OutputPath), humanize.Bytes(c.stats.OutputFileSize))
+	console.Printf("Input:         %s (%s)\n", filepath.Base(c.options.InputPath), humanize.Bytes(c.stats.InputFileSize))
+	console.Printf("Output:        %s (%s)\n", filepath.Base(c.options.OutputPath), humanize.Bytes(c.stats.OutputFileSize))
 
 	// Compression info
 	if c.stats.CompressionRatio < 1.0 {
-		fmt.Printf("Compression:   %.1f%% size reduction\n", (1.0-c.stats.CompressionRatio)*100)
+		console.Printf("Compression:   %.1f%% size reduction\n", (1.0-c.stats.CompressionRatio)*100)
 	} else {
-		fmt.Printf("Size change:   %.1f%% increase (likely due to text extraction)\n", (c.stats.CompressionRatio-1.0)*100)
+		console.Printf("Size change:   %.1f%% increase (likely due to text extraction)\n", (c.stats.CompressionRatio-1.0)*100)
+	}
+	if budget := c.stats.SizeBudget; budget.Reencoded > 0 {
+		console.Printf("Size budget:   %s\n", formatSizeBudget(budget))
+		if !budget.Reached() {
+			logging.Module("converter").Warn("The EPUB is still over its size budget with its images as small as they go",
+				"budget", humanize.Bytes(uint64(budget.Target)), "size", humanize.Bytes(uint64(budget.Size)))
+		}
 	}
 
 	// Content statistics
-	fmt.Printf("Pages:         %d processed\n", c.stats.ProcessedPages)
-	fmt.Printf("Text content:  %s characters\n", humanize.Comma(int64(c.stats.TextCharCount)))
-	fmt.Printf("Target reader: %s\n", c.options.Profile.Name)
+	console.Printf("Pages:         %d processed\n", c.stats.ProcessedPages)
+	console.Printf("Text content:  %s characters\n", humanize.Comma(int64(c.stats.TextCharCount)))
+	if c.stats.WordCount > 0 {
+		console.Printf("Words:         %s, about %s to read\n", humanize.Comma(int64(c.stats.WordCount)),
+			metadata.FormatReadingTime(c.stats.ReadingTime))
+		if c.options.Verbose {
+			for _, chapter := range c.stats.ChapterWords {
+				console.Printf("               %6s  %-14s %s\n", humanize.Comma(int64(chapter.Words)),
+					metadata.FormatReadingTime(metadata.ReadingTime(chapter.Words, c.options.ReadingWPM)), chapter.Title)
+			}
+		}
+	}
+	if c.stats.ImageCount > 0 {
+		console.Printf("Images:        %d%s\n", c.stats.ImageCount, formatImageStats(c.stats.Images))
+	}
+	if len(c.stats.Languages) > 0 {
+		console.Printf("Languages:     %s\n", formatLanguageMix(c.stats.Languages))
+	}
+	if len(c.stats.OCRPages) > 0 {
+		mean, lowest := meanOCRConfidence(c.stats.OCRPages)
+		console.Printf("OCR:           %s, mean confidence %d%% (lowest %d%% on page %d)\n",
+			pluralPages(len(c.stats.OCRPages)), mean, lowest.Confidence, lowest.Page)
+		if c.options.Verbose {
+			console.Printf("               %s\n", formatPageConfidences(c.stats.OCRPages))
+		}
+	}
+	if corrections := c.stats.Corrections; len(corrections) > 0 {
+		shown := corrections
+		if len(shown) > maxCorrectionsShown && !c.options.Verbose {
+			shown = shown[:maxCorrectionsShown]
+		}
+		more := ""
+		if len(shown) < len(corrections) {
+			more = fmt.Sprintf(" and %d more (--verbose lists them all)", len(corrections)-len(shown))
+		}
+		console.Printf("Straightened:  %s (%s%s)\n", pluralPages(len(corrections)), formatPageCorrections(shown), more)
+	}
+	if c.stats.VerticalWriting {
+		console.Printf("Writing mode:  vertical, right to left\n")
+	} else if c.stats.RightToLeft {
+		console.Printf("Writing mode:  right to left\n")
+	}
+	console.Printf("Target reader: %s\n", c.options.Profile.Name)
 
 	// Performance
-	fmt.Printf("Processing:    %v\n", c.stats.ProcessingTime.Round(time.Millisecond))
+	console.Printf("Processing:    %v\n", c.stats.ProcessingTime.Round(time.Millisecond))
 
 	// Validation results
 	if c.pdfProc != nil {
 		rejectedPages := c.pdfProc.GetRejectedPages()
 		if len(rejectedPages) > 0 {
-			fmt.Printf("\n")
-			fmt.Printf("Validation Results:\n")
-			fmt.Printf("Pages rejected by bleed-through detection: %v\n", rejectedPages)
-			fmt.Printf("Suggestion: Consider adding --skip \"%s\" for faster processing\n", formatPageList(rejectedPages))
+			console.Printf("\n")
+			console.Printf("Validation Results:\n")
+			console.Printf("Pages rejected by bleed-through detection: %v\n", rejectedPages)
+			console.Printf("Suggestion: Consider adding --skip \"%s\" for faster processing\n", formatPageList(rejectedPages))
 		}
 	}
 
-	fmt.Printf("================================================================\n")
-	fmt.Printf("Ready for your %s\n", c.options.Profile.Name)
+	if blank, duplicates := c.stats.BlankPages, c.stats.DuplicatePages; len(blank) > 0 || len(duplicates) > 0 {
+		console.Printf("\n")
+		console.Printf("Pages left out:\n")
+		if len(blank) > 0 {
+			console.Printf("  Blank:      %s\n", compactPageList(blank))
+		}
+		if len(duplicates) > 0 {
+			console.Printf("  Duplicates: %s\n", formatPageDuplicates(duplicates))
+		}
+		console.Printf("Suggestion: Consider adding --keep-blank-pages to keep them\n")
+	}
+
+	if low := c.stats.LowConfidencePages; len(low) > 0 {
+		console.Printf("\n")
+		console.Printf("Pages OCR read with under %d%% confidence, marked in the book: %s\n", c.options.MinOCRConfidence, compactPageList(low))
+		console.Printf("Suggestion: Check them against the PDF, or keep them as images with --image-pages \"%s\"\n", compactPageList(low))
+	}
+
+	if rights := c.stats.Rights; rights.Licensed() {
+		console.Printf("\n")
+		console.Printf("This book is licensed, not free to share:\n")
+		if rights.Statement != "" {
+			console.Printf("  Rights: %s\n", rights.Statement)
+		}
+		if rights.UsageTerms != "" {
+			console.Printf("  Terms:  %s\n", rights.UsageTerms)
+		}
+		for _, watermark := range formatWatermarks(rights.Watermarks) {
+			console.Printf("  Stamp:  %s\n", watermark)
+		}
+		console.Printf("It carries no DRM, and the EPUB won't either. Keep it for your own reading rather than\n")
+		console.Printf("a shared library, sync folder or server.\n")
+		if c.options.Owner == "" {
+			console.Printf("Suggestion: Consider adding --owner \"Your Name\" to mark it as your copy\n")
+		}
+	}
+
+	if len(c.stats.Degraded) > 0 {
+		console.Printf("\n")
+		console.Printf("Left out for a missing dependency:\n")
+		for _, warning := range c.stats.Degraded {
+			console.Printf("  %s\n", warning)
+		}
+		console.Printf("Suggestion: Run publify doctor to see what's missing\n")
+	}
+
+	if undescribed := c.stats.UndescribedImages; undescribed > 0 {
+		console.Printf("\n")
+		console.Printf("Pictures without a text description: %d, which screen readers can only skip\n", undescribed)
+		console.Printf("Suggestion: Describe them with --alt-text descriptions.json\n")
+	}
+
+	if missing := c.stats.MissingGlyphs; len(missing) > 0 {
+		console.Printf("\n")
+		console.Printf("Characters the reader's fonts lack: %s\n", humanize.Comma(int64(missing.Count())))
+		shown := missing
+		if len(shown) > maxGlyphsShown && !c.options.Verbose {
+			shown = shown[:maxGlyphsShown]
+		}
+		for _, glyph := range shown {
+			console.Printf("  %s\n", formatMissingGlyph(glyph))
+		}
+		if len(shown) < len(missing) {
+			console.Printf("  and %d more (--verbose lists them all)\n", len(missing)-len(shown))
+		}
+		if unresolved := missing.Unresolved(); unresolved > 0 {
+			console.Printf("Left to show as boxes: %s\n", humanize.Comma(int64(unresolved)))
+			if !c.options.Transliterate {
+				console.Printf("Suggestion: Consider adding --transliterate to spell them with characters the reader has\n")
+			}
+		}
+	}
+
+	console.Printf("================================================================\n")
+	console.Printf("Ready for your %s\n", c.options.Profile.Name)
+}
+
+// formatLanguageMix lists languages with their share of the text, e.g. "English 92%, French 8%"
+func formatLanguageMix(shares []LanguageShare) string {
+	var parts []string
+	for i, share := range shares {
+		if i > 0 && share.Share < 0.005 {
+			break // A stray paragraph or two, not worth a "0%"
+		}
+		parts = append(parts, fmt.Sprintf("%s %.0f%%", share.Name, share.Share*100))
+	}
+	return strings.Join(parts, ", ")
 }
 
 // formatPageList formats a list of page numbers into a comma-separated string
@@ -342,4 +1091,7 @@ func (c *Converter) cleanup() {
 	if c.pdfProc != nil {
 		c.pdfProc.Close()
 	}
+	if c.epubGen != nil {
+		c.epubGen.Cleanup()
+	}
 }
@@ -61,6 +61,34 @@ func TestCreateEPUBOptions(t *testing.T) {
 	}
 }
 
+func TestCreateEPUBOptionsFromPDFMetadata(t *testing.T) {
+	converter := New(Options{InputPath: "/path/to/scan_0042.pdf"})
+	converter.pdfMeta = PDFMetadata{
+		Title:    "Air Babylon",
+		Author:   "Imogen Edwards-Jones",
+		Subject:  "Air travel -- Anecdotes",
+		Keywords: []string{"aviation"},
+	}
+
+	epubOpts := converter.createEPUBOptions()
+
+	if epubOpts.Title != "Air Babylon" {
+		t.Errorf("Expected title 'Air Babylon', got '%s'", epubOpts.Title)
+	}
+
+	if epubOpts.Author != "Imogen Edwards-Jones" {
+		t.Errorf("Expected author 'Imogen Edwards-Jones', got '%s'", epubOpts.Author)
+	}
+
+	if epubOpts.Description != "Air travel -- Anecdotes" {
+		t.Errorf("Expected description from PDF subject, got '%s'", epubOpts.Description)
+	}
+
+	if len(epubOpts.Subjects) != 1 || epubOpts.Subjects[0] != "aviation" {
+		t.Errorf("Expected subjects from PDF keywords, got %v", epubOpts.Subjects)
+	}
+}
+
 func TestGetStats(t *testing.T) {
 	converter := New(Options{})
 
@@ -159,6 +187,39 @@ func TestGenerateEPUBWithValidPages(t *testing.T) {
 	}
 }
 
+func TestGenerateEPUBCountsWords(t *testing.T) {
+	profile := reader.Profile{
+		Name:         "Test Reader",
+		Capabilities: reader.DeviceCapabilities{DefaultFontSize: 12},
+	}
+
+	converter := New(Options{Profile: profile})
+	converter.epubGen = NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book"})
+	defer converter.epubGen.Cleanup()
+
+	// A page opening with a chapter marker ends the chapter it's in
+	pages := []PDFPage{
+		{Number: 1, Text: "This is the first page of text content.", HasText: true},
+		{Number: 2, Text: "Chapter notes close the first part.", HasText: true},
+		{Number: 3, Text: "This is the second page with more content.", HasText: true},
+	}
+
+	if err := converter.generateEPUB(pages); err != nil {
+		t.Fatalf("Unexpected error generating EPUB: %v", err)
+	}
+
+	if converter.stats.WordCount != 22 {
+		t.Errorf("Expected WordCount 22, got %d", converter.stats.WordCount)
+	}
+	chapterWords := converter.stats.ChapterWords
+	if len(chapterWords) != 2 || chapterWords[0].Words != 14 || chapterWords[1].Words != 8 {
+		t.Errorf("Expected 14 and 8 words in 2 chapters, got %+v", chapterWords)
+	}
+	if converter.stats.ReadingTime == 0 {
+		t.Error("ReadingTime should be estimated from the word count")
+	}
+}
+
 func TestCleanup(t *testing.T) {
 	converter := New(Options{})
 
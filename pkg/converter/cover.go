@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+// CoverAuto asks the converter to render the cover from a PDF page instead of using an image file
+const CoverAuto = "auto"
+
+const (
+	// coverDPI renders covers sharper than image pages, they're the first thing anyone sees
+	coverDPI = 300
+	// marginTolerance is how far from pure white a pixel may be and still count as page margin
+	marginTolerance = 24
+	// marginNoise is the fraction of darker pixels a margin row or column may contain (scan dust)
+	marginNoise = 0.005
+)
+
+// RenderCover renders a page at cover resolution with its blank margins cropped away
+func (p *PDFProcessor) RenderCover(pageNum int) (image.Image, error) {
+	if pageNum < 1 || pageNum > p.GetPageCount() {
+		return nil, fmt.Errorf("cover page %d out of range (1-%d)", pageNum, p.GetPageCount())
+	}
+
+	document, err := p.documents.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.documents.release(document)
+	instance, doc := document.instance, document.doc
+
+	rendered, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc,
+				Index:    pageNum - 1,
+			},
+		},
+		DPI: coverDPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render cover page: %w", err)
+	}
+	defer rendered.Cleanup()
+
+	// Cropping copies the pixels, so the result outlives the rendered buffer
+	return cropMargins(rendered.Result.Image), nil
+}
+
+// cropMargins trims near-white borders from an image. Blank images are returned as a copy.
+func cropMargins(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	isMargin := func(x0, y0, x1, y1 int) bool {
+		total := (x1 - x0) * (y1 - y0)
+		dark := 0
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				// Rec. 601 luma on 8-bit values
+				luma := (299*(r>>8) + 587*(g>>8) + 114*(b>>8)) / 1000
+				if luma < 255-marginTolerance {
+					dark++
+				}
+			}
+		}
+		return float64(dark) <= float64(total)*marginNoise
+	}
+
+	top, bottom := bounds.Min.Y, bounds.Max.Y
+	for top < bottom && isMargin(bounds.Min.X, top, bounds.Max.X, top+1) {
+		top++
+	}
+	for bottom > top && isMargin(bounds.Min.X, bottom-1, bounds.Max.X, bottom) {
+		bottom--
+	}
+
+	if top >= bottom {
+		return imaging.Clone(img)
+	}
+
+	left, right := bounds.Min.X, bounds.Max.X
+	for left < right && isMargin(left, top, left+1, bottom) {
+		left++
+	}
+	for right > left && isMargin(right-1, top, right, bottom) {
+		right--
+	}
+
+	return imaging.Crop(img, image.Rect(left, top, right, bottom))
+}
+
+// Where go-epub puts the files the cover touches
+const (
+	packagePath   = "EPUB/package.opf"
+	navPath       = "EPUB/nav.xhtml"
+	coverPagePath = "EPUB/xhtml/cover.xhtml"
+	coverPageHref = "xhtml/cover.xhtml" // Relative to the package document
+)
+
+// coverImage describes the cover image once it's in the EPUB
+type coverImage struct {
+	internalPath string // Relative to the xhtml folder, e.g. ../images/cover.webp
+	width        int
+	height       int
+}
+
+// decodeImageConfig reads an image file's dimensions without decoding the pixels
+func decodeImageConfig(path string) (image.Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	return config, err
+}
+
+// createCoverPage builds a cover document that fills the device screen. The image is
+// wrapped in SVG so readers scale it to the viewport instead of flowing it like text.
+func (eg *EPUBGenerator) createCoverPage() ([]byte, error) {
+	viewportWidth := eg.profile.Capabilities.ScreenWidth
+	viewportHeight := eg.profile.Capabilities.ScreenHeight
+	if viewportWidth == 0 || viewportHeight == 0 {
+		viewportWidth, viewportHeight = eg.cover.width, eg.cover.height
+	}
+
+	page, err := eg.options.Templates.render(CoverTemplate, CoverData{
+		Language:       eg.epub.Lang(),
+		Title:          eg.epub.Title(),
+		ViewportWidth:  viewportWidth,
+		ViewportHeight: viewportHeight,
+		ImageWidth:     eg.cover.width,
+		ImageHeight:    eg.cover.height,
+		ImagePath:      eg.cover.internalPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(xmlDeclaration + strings.TrimLeft(page, " \t\r\n")), nil
+}
+
+// addCoverToPackage flags the cover document's SVG content and adds an EPUB 2 guide
+// reference, which older readers (and Kindle conversions) use to find the cover
+func addCoverToPackage(content []byte) []byte {
+	opf := string(content)
+
+	item := `href="` + coverPageHref + `" media-type="application/xhtml+xml"`
+	opf = strings.Replace(opf, item, item+` properties="svg"`, 1)
+
+	if !strings.Contains(opf, "<guide>") {
+		guide := "  <guide>\n    <reference type=\"cover\" title=\"Cover\" href=\"" + coverPageHref + "\"></reference>\n  </guide>\n</package>"
+		opf = strings.Replace(opf, "</package>", guide, 1)
+	}
+
+	return []byte(opf)
+}
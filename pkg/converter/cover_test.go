@@ -0,0 +1,28 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestCropMargins(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(30, 40, 170, 260), image.NewUniform(color.RGBA{R: 20, G: 40, B: 120, A: 255}), image.Point{}, draw.Src)
+
+	// A speck of scan dust in the margin shouldn't stop the crop
+	img.Set(5, 5, color.Black)
+
+	cropped := cropMargins(img)
+	if cropped.Bounds().Dx() != 140 || cropped.Bounds().Dy() != 220 {
+		t.Errorf("Expected 140x220 after cropping, got %dx%d", cropped.Bounds().Dx(), cropped.Bounds().Dy())
+	}
+
+	blank := image.NewRGBA(image.Rect(0, 0, 50, 50))
+	draw.Draw(blank, blank.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if got := cropMargins(blank).Bounds(); got.Dx() != 50 || got.Dy() != 50 {
+		t.Errorf("Expected blank image to be left uncropped, got %dx%d", got.Dx(), got.Dy())
+	}
+}
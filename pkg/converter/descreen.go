@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// descreenBlock is the size of the squares halftone detection looks at, in pixels
+	descreenBlock = 32
+	// descreenSigma blurs away the dots of a 100-175 lpi screen rendered at imagePageDPI
+	descreenSigma = 1.4
+	// halftoneResidual is how far (in luma) a pixel may differ from its blurred self before it counts as dot texture
+	halftoneResidual = 20
+	// halftoneCoverage is the fraction of textured pixels a block needs to be considered halftone
+	halftoneCoverage = 0.35
+	// halftoneSmoothness: in halftone the dots vanish when blurred, in text and line art the strokes survive.
+	// The blurred block's gradient must be this many times smaller than the dot texture.
+	halftoneSmoothness = 2.0
+	// descreenSharpenSigma and descreenSharpenAmount restore edges the blur softened, without bringing the dots back
+	descreenSharpenSigma  = 2.5
+	descreenSharpenAmount = 0.6
+)
+
+// descreen removes the halftone dot pattern from the printed illustrations in a scanned page,
+// so they don't turn into moiré when scaled down for the reader. Text and line art are left
+// untouched; images without halftone are returned as-is.
+func descreen(img image.Image) image.Image {
+	gray := imaging.Grayscale(img)
+	blurredGray := imaging.Blur(gray, descreenSigma)
+
+	mask := halftoneBlocks(gray, blurredGray)
+	if len(mask) == 0 {
+		return img
+	}
+
+	result := imaging.Clone(img)
+	restored := unsharpMask(imaging.Blur(result, descreenSigma), descreenSharpenSigma, descreenSharpenAmount).(*image.NRGBA)
+
+	width := result.Bounds().Dx()
+	for block := range mask {
+		x0, y0 := block.X*descreenBlock, block.Y*descreenBlock
+		x1 := min(x0+descreenBlock, width)
+		y1 := min(y0+descreenBlock, result.Bounds().Dy())
+		for y := y0; y < y1; y++ {
+			start := y*result.Stride + x0*4
+			end := y*result.Stride + x1*4
+			copy(result.Pix[start:end], restored.Pix[start:end])
+		}
+	}
+
+	return result
+}
+
+// halftoneBlocks finds the blocks of a page that are printed as halftone
+func halftoneBlocks(gray, blurred *image.NRGBA) map[image.Point]bool {
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	luma := func(img *image.NRGBA, x, y int) float64 {
+		return float64(img.Pix[y*img.Stride+x*4])
+	}
+
+	mask := make(map[image.Point]bool)
+	for by := 0; by*descreenBlock < height; by++ {
+		for bx := 0; bx*descreenBlock < width; bx++ {
+			x0, y0 := bx*descreenBlock, by*descreenBlock
+			x1 := min(x0+descreenBlock, width-1)
+			y1 := min(y0+descreenBlock, height-1)
+			if x1-x0 < 2 || y1-y0 < 2 {
+				continue
+			}
+
+			textured := 0
+			var residual, gradient float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					diff := math.Abs(luma(gray, x, y) - luma(blurred, x, y))
+					residual += diff
+					if diff > halftoneResidual {
+						textured++
+					}
+					gradient += math.Abs(luma(blurred, x+1, y)-luma(blurred, x, y)) +
+						math.Abs(luma(blurred, x, y+1)-luma(blurred, x, y))
+				}
+			}
+
+			total := float64((x1 - x0) * (y1 - y0))
+			if float64(textured) >= halftoneCoverage*total && residual >= halftoneSmoothness*gradient {
+				mask[image.Pt(bx, by)] = true
+			}
+		}
+	}
+
+	return mask
+}
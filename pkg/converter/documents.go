@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+// openDocument is the PDF opened in one PDFium instance. Parsing a large PDF is far from
+// free, so each instance opens it once and keeps it open for every page it processes.
+// PDFium reads the file through its own handle as it needs it, so a multi-gigabyte scan
+// never has to fit in memory.
+type openDocument struct {
+	instance pdfium.Pdfium
+	doc      references.FPDF_DOCUMENT
+	file     *os.File
+}
+
+func (d *openDocument) close() {
+	d.instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: d.doc})
+	d.instance.Close()
+	d.file.Close()
+}
+
+// documentCache hands out open documents, one goroutine at a time each (PDFium instances
+// aren't safe for concurrent use), opening new ones up to a limit
+type documentCache struct {
+	pool  pdfium.Pool
+	path  string
+	idle  chan *openDocument
+	slots chan struct{} // One token per document that may still be opened
+}
+
+func newDocumentCache(pool pdfium.Pool, path string, size int) *documentCache {
+	cache := &documentCache{
+		pool:  pool,
+		path:  path,
+		idle:  make(chan *openDocument, size),
+		slots: make(chan struct{}, size),
+	}
+	for i := 0; i < size; i++ {
+		cache.slots <- struct{}{}
+	}
+	return cache
+}
+
+// acquire returns an open document, waiting for one to be released if the limit is reached
+func (c *documentCache) acquire() (*openDocument, error) {
+	// Prefer an idle document over opening another
+	select {
+	case d := <-c.idle:
+		return d, nil
+	default:
+	}
+
+	select {
+	case d := <-c.idle:
+		return d, nil
+	case <-c.slots:
+		d, err := c.open()
+		if err != nil {
+			c.slots <- struct{}{}
+			return nil, err
+		}
+		return d, nil
+	}
+}
+
+// release returns a document to the cache for the next page
+func (c *documentCache) release(d *openDocument) {
+	c.idle <- d
+}
+
+func (c *documentCache) open() (*openDocument, error) {
+	// Every document reads through its own handle, since they seek independently
+	file, err := os.Open(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read PDF file: %w", err)
+	}
+
+	instance, err := c.pool.GetInstance(time.Second * 30)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to get PDFium instance: %w", err)
+	}
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{
+		FileReader:     file,
+		FileReaderSize: info.Size(),
+	})
+	if err != nil {
+		instance.Close()
+		file.Close()
+		return nil, fmt.Errorf("failed to open PDF document: %w", err)
+	}
+
+	return &openDocument{instance: instance, doc: doc.Document, file: file}, nil
+}
+
+// close closes every idle document. Documents still in use are the caller's problem.
+func (c *documentCache) close() {
+	for {
+		select {
+		case d := <-c.idle:
+			d.close()
+		default:
+			return
+		}
+	}
+}
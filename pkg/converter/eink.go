@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"image"
+	"math"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+// defaultGrayLevels is how many shades of gray most e-ink screens show
+const defaultGrayLevels = 16
+
+// einkTuned reports whether any e-ink tone setting is on
+func einkTuned(settings reader.ImageSettings) bool {
+	return settings.Dither || settings.Gamma > 0 || settings.Contrast != 0 || settings.WhitePoint > 0
+}
+
+// tuneForEInk applies the e-ink tone settings to a grayscale image in place: the white
+// point, gamma and contrast as one lookup table, then dithering down to the screen's
+// shades of gray. Plain grayscale conversion tends to come out muddy on e-ink, with dark
+// midtones and paper tone as a gray wash; banding where the screen runs out of shades
+// goes away with dithering.
+func tuneForEInk(img *image.NRGBA, settings reader.ImageSettings) {
+	table := toneTable(settings.Gamma, settings.Contrast, settings.WhitePoint)
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := table[img.Pix[i]]
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2] = v, v, v
+	}
+
+	if settings.Dither {
+		levels := settings.GrayLevels
+		if levels < 2 {
+			levels = defaultGrayLevels
+		}
+		ditherFloydSteinberg(img, levels)
+	}
+}
+
+// toneTable maps each gray level through the white point (grays at or above it become
+// white, the rest are stretched to fill the range), then gamma (above 1 lightens the
+// midtones) and contrast (percent, around the middle gray). Zero leaves each alone.
+func toneTable(gamma, contrast float64, whitePoint int) [256]uint8 {
+	var table [256]uint8
+	for v := range table {
+		x := float64(v) / 255
+		if whitePoint > 0 && whitePoint < 255 {
+			x = math.Min(1, float64(v)/float64(whitePoint))
+		}
+		if gamma > 0 {
+			x = math.Pow(x, 1/gamma)
+		}
+		if contrast != 0 {
+			x = (x-0.5)*(100+contrast)/100 + 0.5
+		}
+		table[v] = clampByte(x * 255)
+	}
+	return table
+}
+
+// ditherFloydSteinberg snaps every pixel to the nearest of levels evenly spaced grays,
+// spreading the difference onto the pixels not yet done: 7/16 to the right, 3/16, 5/16
+// and 1/16 to the row below
+func ditherFloydSteinberg(img *image.NRGBA, levels int) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	step := 255 / float64(levels-1)
+
+	current := make([]float64, width+2) // Errors carried into this row, one pixel of margin each side
+	next := make([]float64, width+2)
+	for y := 0; y < bounds.Dy(); y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			old := float64(row[x*4]) + current[x+1]
+			quantized := math.Round(old/step) * step
+			v := clampByte(quantized)
+			row[x*4], row[x*4+1], row[x*4+2] = v, v, v
+
+			diff := old - quantized
+			current[x+2] += diff * 7 / 16
+			next[x] += diff * 3 / 16
+			next[x+1] += diff * 5 / 16
+			next[x+2] += diff * 1 / 16
+		}
+		current, next = next, current
+		clear(next)
+	}
+}
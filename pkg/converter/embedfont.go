@@ -0,0 +1,301 @@
+package converter
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmaupin/go-epub"
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	// fontsCSSName is the stylesheet declaring the embedded fonts
+	fontsCSSName = "fonts.css"
+
+	encryptionPath = "META-INF/encryption.xml"
+
+	// obfuscationAlgorithm is the IDPF font obfuscation, which scrambles the start of a
+	// font file with a key from the book's identifier so it can't simply be copied out
+	obfuscationAlgorithm = "http://www.idpf.org/2008/embedding"
+	obfuscatedLength     = 1040
+)
+
+// embeddedFont is a font of the user's embedded in the book, for a typeface it needs.
+// Like the fallback font, it's cut down to the characters the book uses.
+type embeddedFont struct {
+	name   string // File name in the EPUB
+	family string
+	weight int  // CSS font-weight, 100-900
+	italic bool // Italic or oblique
+	data   []byte
+	font   *sfnt.Font
+}
+
+func loadEmbeddedFont(fontPath string) (*embeddedFont, error) {
+	data, err := os.ReadFile(fontPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font %s: %w", fontPath, err)
+	}
+
+	// The typographic family groups weights that older software lists as families of
+	// their own ("Inter SemiBold")
+	var buf sfnt.Buffer
+	family, err := font.Name(&buf, sfnt.NameIDTypographicFamily)
+	if err != nil || family == "" {
+		family, _ = font.Name(&buf, sfnt.NameIDFamily)
+	}
+	if family == "" {
+		family = strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+	}
+
+	weight, italic := fontStyle(data)
+	return &embeddedFont{
+		name:   fontFileName(fontPath),
+		family: family,
+		weight: weight,
+		italic: italic,
+		data:   data,
+		font:   font,
+	}, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fontFileName is a font's file name made safe for the manifest and encryption.xml
+func fontFileName(fontPath string) string {
+	return unsafeFileNameChars.ReplaceAllString(filepath.Base(fontPath), "-")
+}
+
+// fontStyle reads a font's weight and whether it's italic from its OS/2 table
+func fontStyle(data []byte) (weight int, italic bool) {
+	os2 := sfntTable(data, "OS/2")
+	if len(os2) < 64 {
+		return 400, false
+	}
+	weight = int(binary.BigEndian.Uint16(os2[4:]))
+	if weight < 100 || weight > 900 {
+		weight = 400
+	}
+	selection := binary.BigEndian.Uint16(os2[62:])
+	return weight, selection&(1|1<<9) != 0 // Italic or oblique
+}
+
+// sfntTable finds a table in an sfnt font file (nil = not there)
+func sfntTable(data []byte, tag string) []byte {
+	if len(data) < 12 {
+		return nil
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := range numTables {
+		if len(data) < 12+16*(i+1) {
+			return nil
+		}
+		record := data[12+16*i:]
+		if string(record[:4]) != tag {
+			continue
+		}
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil
+		}
+		return data[offset : offset+length]
+	}
+	return nil
+}
+
+// subset returns the font with only the glyphs for the given characters
+func (f *embeddedFont) subset(text map[rune]bool) ([]byte, error) {
+	var buf sfnt.Buffer
+	keep := make(map[uint16]bool)
+	for r := range text {
+		if index, err := f.font.GlyphIndex(&buf, r); err == nil && index != 0 {
+			keep[uint16(index)] = true
+		}
+	}
+	return subsetTrueType(f.data, keep)
+}
+
+// fontFaceCSS declares the embedded fonts and sets the text in the first of them
+func fontFaceCSS(fonts []*embeddedFont) string {
+	var css strings.Builder
+	for _, font := range fonts {
+		style := "normal"
+		if font.italic {
+			style = "italic"
+		}
+		fmt.Fprintf(&css, `@font-face {
+  font-family: %q;
+  font-weight: %d;
+  font-style: %s;
+  src: url("../%s/%s");
+}
+`, font.family, font.weight, style, epub.FontFolderName, font.name)
+	}
+	if len(fonts) > 0 {
+		fmt.Fprintf(&css, "body {\n  font-family: %q, serif;\n}\n", fonts[0].family)
+	}
+	return css.String()
+}
+
+// obfuscateFont scrambles the start of a font with the IDPF algorithm: XOR with the SHA-1
+// of the book's unique identifier, whitespace removed. Reading systems undo it on loading.
+func obfuscateFont(data []byte, identifier string) []byte {
+	identifier = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, identifier)
+	key := sha1.Sum([]byte(identifier))
+
+	obfuscated := append([]byte(nil), data...)
+	for i := range min(len(obfuscated), obfuscatedLength) {
+		obfuscated[i] ^= key[i%len(key)]
+	}
+	return obfuscated
+}
+
+// encryptionXML lists the obfuscated fonts, by their path in the container, so reading
+// systems know to undo it
+func encryptionXML(paths []string) []byte {
+	var doc strings.Builder
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+`)
+	for _, fontPath := range paths {
+		fmt.Fprintf(&doc, `  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="%s"/>
+    <enc:CipherData>
+      <enc:CipherReference URI="%s"/>
+    </enc:CipherData>
+  </enc:EncryptedData>
+`, obfuscationAlgorithm, html.EscapeString(fontPath))
+	}
+	doc.WriteString("</encryption>\n")
+	return []byte(doc.String())
+}
+
+var fontItemPattern = regexp.MustCompile(`<item [^>]*href="` + epub.FontFolderName + `/[^"]+\.(ttf|otf)"[^>]*>`)
+
+var mediaTypeAttr = regexp.MustCompile(`media-type="[^"]*"`)
+
+// setFontMediaTypes gives font manifest items the EPUB core media type for their format.
+// go-epub sniffs it from the file, which some fonts leave as application/octet-stream.
+func setFontMediaTypes(content []byte) []byte {
+	return fontItemPattern.ReplaceAllFunc(content, func(item []byte) []byte {
+		mediaType := "font/ttf"
+		if fontItemPattern.FindSubmatch(item)[1][0] == 'o' {
+			mediaType = "font/otf"
+		}
+		return mediaTypeAttr.ReplaceAll(item, []byte(`media-type="`+mediaType+`"`))
+	})
+}
+
+// SetEmbeddedFonts embeds fonts of the user's (.ttf or .otf), declared with @font-face in
+// a stylesheet linked from every content document. The book's text is set in the first;
+// the rest are there for --css to use by family name.
+func (eg *EPUBGenerator) SetEmbeddedFonts(fontPaths []string) error {
+	for _, fontPath := range fontPaths {
+		font, err := loadEmbeddedFont(fontPath)
+		if err != nil {
+			return err
+		}
+		// The whole font goes in for now; it's cut down when the book is written
+		if _, err := eg.epub.AddFont(fontPath, font.name); err != nil {
+			return fmt.Errorf("failed to add font %s: %w", fontPath, err)
+		}
+		eg.fonts = append(eg.fonts, font)
+	}
+
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return err
+	}
+	cssFile := filepath.Join(tempDir, fontsCSSName)
+	if err := os.WriteFile(cssFile, []byte(fontFaceCSS(eg.fonts)), 0644); err != nil {
+		return fmt.Errorf("failed to write font stylesheet: %w", err)
+	}
+	internalPath, err := eg.epub.AddCSS(cssFile, fontsCSSName)
+	if err != nil {
+		return fmt.Errorf("failed to add font stylesheet: %w", err)
+	}
+	eg.fontsCSS = internalPath
+	return nil
+}
+
+// embeddedFontAt is the embedded font stored under a name in the EPUB (nil = none)
+func (eg *EPUBGenerator) embeddedFontAt(name string) *embeddedFont {
+	for _, font := range eg.fonts {
+		if name == eg.fontPath(font) {
+			return font
+		}
+	}
+	return nil
+}
+
+// fontPath is where an embedded font is in the container
+func (eg *EPUBGenerator) fontPath(font *embeddedFont) string {
+	return path.Join("EPUB", epub.FontFolderName, font.name)
+}
+
+// finishFont cuts an embedded font down to the book's characters and obfuscates it if asked
+func (eg *EPUBGenerator) finishFont(font *embeddedFont) ([]byte, error) {
+	data, err := font.subset(eg.bookText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subset font %s: %w", font.name, err)
+	}
+	if eg.options.ObfuscateFonts {
+		data = obfuscateFont(data, eg.epub.Identifier())
+	}
+	return data, nil
+}
+
+// fontEntries is what the EPUB needs added for its embedded fonts: encryption.xml, when
+// they're obfuscated
+func (eg *EPUBGenerator) fontEntries() map[string][]byte {
+	if len(eg.fonts) == 0 || !eg.options.ObfuscateFonts {
+		return nil
+	}
+	paths := make([]string, len(eg.fonts))
+	for i, font := range eg.fonts {
+		paths[i] = eg.fontPath(font)
+	}
+	return map[string][]byte{encryptionPath: encryptionXML(paths)}
+}
+
+// collectBookText gathers the characters of every content document, which is all the
+// embedded fonts need to draw. Spaces and hyphens are kept for the reader's hyphenation.
+func collectBookText(src *zip.Reader) (map[rune]bool, error) {
+	text := map[rune]bool{' ': true, '-': true, '\u2010': true}
+	for _, file := range src.File {
+		if path.Ext(file.Name) != ".xhtml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		for _, r := range html.UnescapeString(stripTags(string(content))) {
+			text[r] = true
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func writeFontFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	fontPath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fontPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fontPath
+}
+
+func TestLoadEmbeddedFont(t *testing.T) {
+	tests := []struct {
+		file         string
+		data         []byte
+		name, family string
+		weight       int
+		italic       bool
+	}{
+		{"Go-Regular.ttf", goregular.TTF, "Go-Regular.ttf", "Go", 400, false},
+		{"Go Italic.ttf", goitalic.TTF, "Go-Italic.ttf", "Go", 400, true},
+	}
+	for _, test := range tests {
+		font, err := loadEmbeddedFont(writeFontFile(t, test.file, test.data))
+		if err != nil {
+			t.Fatalf("loadEmbeddedFont(%s) failed: %v", test.file, err)
+		}
+		if font.name != test.name || font.family != test.family || font.weight != test.weight || font.italic != test.italic {
+			t.Errorf("loadEmbeddedFont(%s) = %s %q %d italic=%v, expected %s %q %d italic=%v", test.file,
+				font.name, font.family, font.weight, font.italic, test.name, test.family, test.weight, test.italic)
+		}
+	}
+
+	if _, err := loadEmbeddedFont(writeFontFile(t, "broken.ttf", []byte("not a font"))); err == nil {
+		t.Error("Expected an error for a file that isn't a font")
+	}
+}
+
+func TestFontFaceCSS(t *testing.T) {
+	css := fontFaceCSS([]*embeddedFont{
+		{name: "Dyslexic-Regular.otf", family: "OpenDyslexic", weight: 400},
+		{name: "Dyslexic-Italic.otf", family: "OpenDyslexic", weight: 400, italic: true},
+	})
+	for _, expected := range []string{
+		"font-family: \"OpenDyslexic\";\n  font-weight: 400;\n  font-style: normal;\n  src: url(\"../fonts/Dyslexic-Regular.otf\");",
+		"font-style: italic;\n  src: url(\"../fonts/Dyslexic-Italic.otf\");",
+		"body {\n  font-family: \"OpenDyslexic\", serif;\n}",
+	} {
+		if !strings.Contains(css, expected) {
+			t.Errorf("Expected the stylesheet to contain %q, got:\n%s", expected, css)
+		}
+	}
+}
+
+func TestObfuscateFont(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 2000)
+	obfuscated := obfuscateFont(data, " urn:uuid:0a1b2c3d\n")
+
+	if bytes.Equal(obfuscated[:obfuscatedLength], data[:obfuscatedLength]) {
+		t.Error("Expected the start of the font to be scrambled")
+	}
+	if !bytes.Equal(obfuscated[obfuscatedLength:], data[obfuscatedLength:]) {
+		t.Error("Expected the font past the first 1040 bytes to be left alone")
+	}
+
+	// It's its own inverse, and whitespace in the identifier doesn't count
+	if !bytes.Equal(obfuscateFont(obfuscated, "urn:uuid:0a1b2c3d"), data) {
+		t.Error("Expected obfuscating twice to give the font back")
+	}
+	if bytes.Equal(obfuscateFont(data, "urn:uuid:ffffffff"), obfuscated) {
+		t.Error("Expected another identifier to give another key")
+	}
+}
+
+func TestSetFontMediaTypes(t *testing.T) {
+	content := []byte(`<manifest>
+    <item id="a.ttf" href="fonts/a.ttf" media-type="application/octet-stream"></item>
+    <item id="b.otf" href="fonts/b.otf" media-type="application/vnd.ms-opentype"></item>
+    <item id="c.css" href="css/c.css" media-type="text/css"></item>
+  </manifest>`)
+	result := string(setFontMediaTypes(content))
+	for _, expected := range []string{
+		`href="fonts/a.ttf" media-type="font/ttf"`,
+		`href="fonts/b.otf" media-type="font/otf"`,
+		`href="css/c.css" media-type="text/css"`,
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %q in %s", expected, result)
+		}
+	}
+}
+
+func TestEmbeddedFonts(t *testing.T) {
+	profile := reader.Profile{Name: "Test Reader"}
+	generator := NewEPUBGenerator(profile, EPUBOptions{
+		Title:          "Mort",
+		Identifier:     "urn:uuid:6f1c1a9e-0e58-4d3c-9f1e-6f2c3d4e5f60",
+		ObfuscateFonts: true,
+	})
+	defer generator.Cleanup()
+
+	if err := generator.SetEmbeddedFonts([]string{writeFontFile(t, "Go-Regular.ttf", goregular.TTF)}); err != nil {
+		t.Fatalf("Unexpected error embedding font: %v", err)
+	}
+	if err := generator.AddChapter("Chapter 1", []PDFPage{{Number: 1, Text: "Mort.", HasText: true}}); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "fonts.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	if !strings.Contains(files[packagePath], `href="fonts/Go-Regular.ttf" media-type="font/ttf"`) {
+		t.Errorf("Expected the font in the manifest, got %s", files[packagePath])
+	}
+	if !strings.Contains(files[encryptionPath], `<enc:CipherReference URI="EPUB/fonts/Go-Regular.ttf"/>`) {
+		t.Errorf("Expected the font listed in encryption.xml, got %q", files[encryptionPath])
+	}
+	if !strings.Contains(files["EPUB/xhtml/page0001.xhtml"], `href="../css/`+fontsCSSName+`"`) {
+		t.Error("Expected the chapter to link the font stylesheet")
+	}
+
+	// Undone, the font is whole but only draws the book's characters
+	fontData := obfuscateFont([]byte(files["EPUB/fonts/Go-Regular.ttf"]), "urn:uuid:6f1c1a9e-0e58-4d3c-9f1e-6f2c3d4e5f60")
+	font, err := sfnt.Parse(fontData)
+	if err != nil {
+		t.Fatalf("Embedded font doesn't parse once deobfuscated: %v", err)
+	}
+	if len(fontData) >= len(goregular.TTF) {
+		t.Errorf("Expected the font cut down, got %d bytes of %d", len(fontData), len(goregular.TTF))
+	}
+	var buf sfnt.Buffer
+	index, err := font.GlyphIndex(&buf, 'Z')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segments, err := font.LoadGlyph(&buf, index, 1000, nil); err != nil || len(segments) != 0 {
+		t.Errorf("Expected no outline for a character the book doesn't use, got %d segments (%v)", len(segments), err)
+	}
+}
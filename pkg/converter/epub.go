@@ -1,21 +1,75 @@
 package converter
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"image/color"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/alde/publify/internal/epubzip"
+	"github.com/alde/publify/internal/safefile"
 	"github.com/alde/publify/pkg/reader"
 	"github.com/bmaupin/go-epub"
 )
 
+// contentDocOverhead reserves room for the XHTML wrapper go-epub puts around each section body
+const contentDocOverhead = 1024
+
+// minChunkBytes is the least text a content document is given, for readers whose limit
+// leaves little or no room beside the wrapper
+const minChunkBytes = 512
+
 // EPUBGenerator handles EPUB file creation
 type EPUBGenerator struct {
-	epub    *epub.Epub
-	profile reader.Profile
-	options EPUBOptions
+	epub       *epub.Epub
+	profile    reader.Profile
+	options    EPUBOptions
+	tempDir    string // Optimized images live here until the EPUB is written
+	imageCount int
+	cover      *coverImage
+
+	frontMatterCSS string // Internal path of the title page/colophon stylesheet, once added
+	customCSS      string // Internal path of the user's stylesheet ("" = none)
+	fontsCSS       string // Internal path of the embedded fonts' stylesheet ("" = none)
+
+	fonts    []*embeddedFont // Fonts of the user's, cut down to bookText as the book is written
+	bookText map[rune]bool   // Characters used in the book, once it's being written
+
+	fingerprint string // Of the book's text, stored in the metadata ("" = none)
+
+	pages []pageTarget // Where each PDF page begins, in reading order, for the page-list
+
+	titlePage    bool   // A title page was added
+	firstChapter string // File name of the first chapter, where the text starts
+	undescribed  int    // Pictures without a text description
+
+	sectionLanguages map[string]string // Sections in another language than the book, by file name
+	sectionNames     map[string]int    // Times each chapter file name was wanted, to keep them apart
+	languageMix      map[string]int    // Words per language
+
+	glyphs *glyphFallback // Handles characters the reader's fonts lack (nil = left as they are)
+
+	fixedPages map[string]FixedPageData // Fixed-layout pages, by file name
+
+	vertical bool // Set in vertical columns, pages turning right to left
+	rtl      bool // Set right to left, as Arabic and Hebrew are
+
+	budget       SizeBudget     // Size the book is kept within, and what that cost
+	budgetImages []*budgetImage // Figures and page images the budget may encode again
+
+	optimized      map[string]optimizedImage // Images optimized ahead, by name (see optimizeImages)
+	originalSizes  []int64                   // Of each image added, as it came from the PDF
+	optimizedSizes []int64                   // Of each image added, as optimized
 }
 
 // EPUBOptions defines EPUB generation settings
@@ -26,6 +80,64 @@ type EPUBOptions struct {
 	Identifier  string
 	Description string
 	CoverPath   string
+
+	// Compression is the output zip level (store/fast/default/best, "" = go-epub defaults).
+	// Already-compressed images are stored rather than deflated a second time.
+	Compression string
+
+	Subjects  []string // Written as dc:subject entries
+	Publisher string
+	Rights    string // Written as dc:rights ("" = none)
+
+	// Owner is who the book was converted for, stamped into the package metadata
+	// ("" = no one)
+	Owner string
+
+	// OwnerMark also writes the owner faintly at the foot of every image page
+	OwnerMark bool
+
+	// ObfuscateFonts scrambles embedded fonts with the IDPF algorithm, listed in
+	// META-INF/encryption.xml, so they can't be copied straight out of the book
+	ObfuscateFonts bool
+
+	// TextRender stores image pages that turn out to be plain text as 1-bit PNGs,
+	// much smaller and crisper on e-ink than a grayscale scan
+	TextRender bool
+
+	// Backup keeps an existing file at the output path as <output>.bak
+	Backup bool
+
+	// Reproducible stamps the book and every file in it with epubzip.SourceDate, and
+	// writes the files in name order, so the same book always comes out the same bytes
+	Reproducible bool
+
+	// TextLayer puts an image page's text over the image, invisible, so search and
+	// dictionary lookup still work on scanned pages
+	TextLayer bool
+
+	// Templates for the generated markup (nil = built-in templates)
+	Templates *Templates
+
+	// MinOCRConfidence marks OCR'd pages read with less confidence than this (0-100,
+	// 0 = none are marked)
+	MinOCRConfidence int
+
+	// FixedLayout gives every image page a fixed-layout page of its own, sized to the
+	// reader's screen, so art books and sheet music look just as printed. The rest of
+	// the book still reflows.
+	FixedLayout bool
+
+	// CropMargins crops the blank paper around image pages, so what's printed fills more
+	// of a small screen
+	CropMargins bool
+
+	// SplitSpreads splits image pages of two pages side by side into two pages, in
+	// reading order
+	SplitSpreads bool
+
+	// DescribeImage supplies a text description for a figure or image page, used as its
+	// alt text ahead of any caption ("" or nil = the caption, if one is found)
+	DescribeImage func(img ImageToDescribe) (string, error)
 }
 
 // NewEPUBGenerator creates a new EPUB generator
@@ -49,13 +161,16 @@ func NewEPUBGenerator(profile reader.Profile, opts EPUBOptions) *EPUBGenerator {
 	}
 
 	// Add generator metadata
-	e.SetPpd("publify-cli")
 	e.SetDescription(opts.Description + " (Generated with Publify CLI)")
 
 	return &EPUBGenerator{
-		epub:    e,
-		profile: profile,
-		options: opts,
+		epub:             e,
+		profile:          profile,
+		options:          opts,
+		sectionLanguages: make(map[string]string),
+		sectionNames:     make(map[string]int),
+		languageMix:      make(map[string]int),
+		fixedPages:       make(map[string]FixedPageData),
 	}
 }
 
@@ -64,7 +179,16 @@ func (eg *EPUBGenerator) AddChapter(title string, pages []PDFPage) error {
 	if len(pages) == 0 {
 		return fmt.Errorf("no pages provided for chapter '%s'", title)
 	}
+	if eg.options.FixedLayout {
+		return eg.addFixedLayoutChapter(title, pages)
+	}
+	_, err := eg.addChapter(title, pages, 0)
+	return err
+}
 
+// addChapter adds pages to the EPUB as the parts of a chapter from firstPart on, and
+// returns how many parts they made. Only part 0 carries the title.
+func (eg *EPUBGenerator) addChapter(title string, pages []PDFPage, firstPart int) (int, error) {
 	// Process text from all pages
 	textProcessor := NewTextProcessor(TextProcessingOptions{
 		PreserveFormatting: true,
@@ -73,48 +197,407 @@ func (eg *EPUBGenerator) AddChapter(title string, pages []PDFPage) error {
 	})
 
 	var allText strings.Builder
+	hasContent := false
+	needsStylesheet := eg.vertical // The stylesheet sets the writing mode
 	for _, page := range pages {
-		if page.HasText {
-			processedText := textProcessor.ProcessText(page.Text)
-			if processedText != "" {
-				allText.WriteString(processedText)
-				allText.WriteString("\n\n")
+		page, err := page.withPayload()
+		if err != nil {
+			return 0, err
+		}
+
+		// Mark where the page begins, for the page-list
+		allText.WriteString(pageBreakHTML(page))
+
+		// Image pages are shown as the rendered page; their text would only duplicate it,
+		// unless it's wanted as an invisible text layer
+		if len(page.ImageData) > 0 {
+			if eg.options.TextLayer && page.HasText {
+				needsStylesheet = true
+			}
+			pageHTML, err := eg.addPageImage(page)
+			if err != nil {
+				return 0, fmt.Errorf("failed to add image for page %d: %w", page.Number, err)
 			}
+			allText.WriteString(addParagraphIDs(pageHTML, page.Number))
+			allText.WriteString("\n\n")
+			hasContent = true
+			continue
+		}
+
+		figures, err := eg.addPageFigures(page)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add figures for page %d: %w", page.Number, err)
+		}
+
+		processedText := ""
+		if page.HasText {
+			processedText = addParagraphIDs(textProcessor.ProcessText(page.Text), page.Number)
+		}
+
+		// Keep dedications and epigraphs centered on a page of their own
+		if page.Centered && processedText != "" {
+			processedText = centerPageHTML(processedText)
+			needsStylesheet = true
+		}
+
+		// Say so where OCR was unsure, rather than pass off its guesses as the text
+		if page.OCR && processedText != "" && page.OCRConfidence < eg.options.MinOCRConfidence {
+			processedText = markLowConfidence(processedText, page.Number, page.OCRConfidence)
+			needsStylesheet = true
+		}
+
+		pageHTML := placeFigures(processedText, figures)
+		eg.undescribed += strings.Count(pageHTML, `alt=""`)
+		if pageHTML != "" {
+			allText.WriteString(pageHTML)
+			allText.WriteString("\n\n")
+			hasContent = true
 		}
 	}
 
 	content := allText.String()
-	if content == "" {
-		content = "<p>No text content found on these pages.</p>"
+	if !hasContent {
+		content += "<p>No text content found on these pages.</p>"
+	}
+
+	// Readers pick dictionaries and hyphenation by language, so passages in another
+	// language than the book are marked as such
+	language := eg.chapterLanguage(pages)
+	content = tagParagraphLanguages(content, language, eg.languageMix)
+	if language == "ja" {
+		content = addRuby(content, eg.profile.Capabilities.SupportsRuby)
+	}
+
+	// Older readers draw characters their fonts lack as empty boxes
+	if eg.glyphs != nil {
+		var usedFont bool
+		content, usedFont = eg.glyphs.apply(content)
+		needsStylesheet = needsStylesheet || usedFont
+		title = eg.glyphs.text(title)
+	}
+
+	// Split oversized chapters so no content document exceeds what the reader can handle
+	chunks := []string{content}
+	if maxBytes := eg.profile.Capabilities.MaxContentDocBytes; maxBytes > 0 {
+		chunks = textProcessor.SplitIntoChunks(content, max(maxBytes-contentDocOverhead, minChunkBytes))
+	}
+
+	cssPath := ""
+	if needsStylesheet {
+		var err error
+		if cssPath, err = eg.frontMatterStylesheet(); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, chunk := range chunks {
+		// Only the first part carries the title; continuations stay out of the TOC
+		sectionTitle := ""
+		if firstPart+i == 0 {
+			sectionTitle = title
+		}
+
+		htmlContent, err := eg.createHTMLContent(title, firstPart+i, chunk)
+		if err != nil {
+			return 0, err
+		}
+
+		filename := eg.sectionFilename(pages[0].Number, i, chunk)
+		section, err := eg.epub.AddSection(htmlContent, sectionTitle, filename, cssPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add chapter '%s': %w", title, err)
+		}
+		if language != eg.epub.Lang() {
+			eg.sectionLanguages[path.Base(section)] = language
+		}
+		if eg.firstChapter == "" {
+			eg.firstChapter = path.Base(section)
+		}
+		eg.addPageTargets(path.Base(section), chunk)
+	}
+
+	return len(chunks), nil
+}
+
+// chapterLanguage is the language a chapter is written in, or the book's if it can't tell
+func (eg *EPUBGenerator) chapterLanguage(pages []PDFPage) string {
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\n")
+	}
+	if language, _ := detectLanguage(text.String()); language != "" {
+		return language
+	}
+	return eg.epub.Lang()
+}
+
+// SetLanguage sets the book's language, which chapters are tagged against
+func (eg *EPUBGenerator) SetLanguage(language string) {
+	eg.epub.SetLang(language)
+}
+
+// SetFingerprint stores a fingerprint of the book's text in its metadata, for publify
+// fingerprint to compare with other copies of the book
+func (eg *EPUBGenerator) SetFingerprint(fingerprint string) {
+	eg.fingerprint = fingerprint
+}
+
+// LanguageMix returns the share of the book's text in each language, largest first
+func (eg *EPUBGenerator) LanguageMix() []LanguageShare {
+	return languageShares(eg.languageMix)
+}
+
+// addPageFigures optimizes a page's embedded images for the reader and adds them to the EPUB
+func (eg *EPUBGenerator) addPageFigures(page PDFPage) ([]pageFigure, error) {
+	if len(page.Images) == 0 {
+		return nil, nil
+	}
+
+	processor, err := eg.imageProcessor()
+	if err != nil {
+		return nil, err
+	}
+
+	var figures []pageFigure
+	for i, pageImage := range page.Images {
+		name := figureName(page.Number, i)
+		optimized, ok := eg.optimized[name]
+		if !ok {
+			optimizedPath, err := processor.ProcessDecodedImage(pageImage.Image, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to optimize figure %d: %w", i+1, err)
+			}
+			if optimized, err = newOptimizedImage(optimizedPath, false, pageImage.size); err != nil {
+				return nil, err
+			}
+		}
+
+		internalPath, err := eg.epub.AddImage(optimized.path, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to add figure %d: %w", i+1, err)
+		}
+		eg.addToBudget(optimized.path, internalPath)
+		eg.countImage(optimized)
+
+		id := fmt.Sprintf("page%04d-figure%d", page.Number, i+1)
+		description, err := eg.describeImage(ImageToDescribe{
+			ID:    id,
+			Page:  page.Number,
+			Image: pageImage.Image,
+			Text:  page.Text,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		figures = append(figures, pageFigure{
+			Position: pageImage.Position,
+			HTML:     fmt.Sprintf(`<div class="figure"><img src="%s" alt="%s"/></div>`, internalPath, html.EscapeString(description)),
+			ID:       id,
+		})
+		eg.imageCount++
+	}
+
+	return figures, nil
+}
+
+// pageImage is an image page once it's in the EPUB
+type pageImage struct {
+	internalPath string // Relative to the xhtml folder
+	alt          string
+	width        int
+	height       int
+}
+
+// addPageImage optimizes a rendered image page for the reader and returns its full-page markup
+func (eg *EPUBGenerator) addPageImage(page PDFPage) (string, error) {
+	added, err := eg.addPageImageFiles(page)
+	if err != nil {
+		return "", err
+	}
+
+	textLayer := ""
+	if eg.options.TextLayer && page.HasText {
+		textLayer = textLayerHTML(page.Text)
+	}
+
+	// A split spread's text goes over its first page, which is where it starts
+	var pageHTML strings.Builder
+	for i, file := range added {
+		if i > 0 {
+			textLayer = ""
+			pageHTML.WriteString("\n")
+		}
+		fmt.Fprintf(&pageHTML, `<div class="page-image"><img src="%s" alt="%s"/>%s</div>`, file.internalPath, html.EscapeString(file.alt), textLayer)
+	}
+	return pageHTML.String(), nil
+}
+
+// addPageImageFiles optimizes a rendered image page for the reader and adds it to the
+// EPUB, as two images if it's a spread to be split
+func (eg *EPUBGenerator) addPageImageFiles(page PDFPage) ([]*pageImage, error) {
+	img, _, err := image.Decode(bytes.NewReader(page.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page image: %w", err)
+	}
+
+	parts := eg.pageParts(img)
+	added := make([]*pageImage, 0, len(parts))
+	for i, part := range parts {
+		id := pageImageID(page.Number, i, len(parts))
+		file, err := eg.addPageImageFile(page, part, id, int64(len(page.ImageData)/len(parts)))
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, file)
+	}
+	return added, nil
+}
+
+// addPageImageFile optimizes an image from a rendered page for the reader, unless it was
+// optimized ahead, adds it to the EPUB and works out its alt text. original is what the
+// image came to as rendered.
+func (eg *EPUBGenerator) addPageImageFile(page PDFPage, img image.Image, id string, original int64) (*pageImage, error) {
+	name := id + ".png"
+	optimized, ok := eg.optimized[name]
+	if !ok {
+		processor, err := eg.imageProcessor()
+		if err != nil {
+			return nil, err
+		}
+		if optimized, err = eg.optimizePageImage(processor, img, name, original); err != nil {
+			return nil, fmt.Errorf("failed to optimize page image: %w", err)
+		}
 	}
 
-	// Create HTML content with proper structure
-	htmlContent := eg.createHTMLContent(title, content)
+	config, err := decodeImageConfig(optimized.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page image: %w", err)
+	}
+	internalPath, err := eg.epub.AddImage(optimized.path, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to add page image: %w", err)
+	}
+	if !optimized.text {
+		// Pages rendered as 1-bit text are as small as they get
+		eg.addToBudget(optimized.path, internalPath)
+	}
+	eg.countImage(optimized)
+	eg.imageCount++
+
+	// The page's text isn't shown, but a caption on it still says what the picture is
+	caption := findCaptionInText(page.Text)
+	alt, err := eg.describeImage(ImageToDescribe{
+		ID:      id,
+		Page:    page.Number,
+		Image:   img,
+		Caption: caption,
+		Text:    page.Text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if alt == "" && caption != "" {
+		alt = altText(caption)
+	}
+	if alt == "" {
+		alt = fmt.Sprintf("Page %d", page.Number)
+		eg.undescribed++
+	}
 
-	// Add chapter to EPUB
-	_, err := eg.epub.AddSection(htmlContent, title, "", "")
+	return &pageImage{internalPath: internalPath, alt: alt, width: config.Width, height: config.Height}, nil
+}
+
+// textLayerHTML marks up a page's text as a transparent layer over the page image.
+// Paragraphs are kept on consecutive lines so chapter splitting never separates them
+// from their image.
+func textLayerHTML(text string) string {
+	var layer strings.Builder
+	layer.WriteString("\n<div class=\"page-text\">")
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		paragraph = strings.Join(strings.Fields(paragraph), " ")
+		if paragraph != "" {
+			layer.WriteString("\n<p>" + html.EscapeString(paragraph) + "</p>")
+		}
+	}
+	layer.WriteString("\n</div>\n")
+	return layer.String()
+}
+
+// markOwner writes the owner at the foot of a page image, if asked to
+func (eg *EPUBGenerator) markOwner(img image.Image, shade color.Gray) (image.Image, error) {
+	if !eg.options.OwnerMark || eg.options.Owner == "" {
+		return img, nil
+	}
+	marked, err := drawOwnerMark(img, eg.options.Owner, shade)
 	if err != nil {
-		return fmt.Errorf("failed to add chapter '%s': %w", title, err)
+		return nil, fmt.Errorf("failed to mark page with its owner: %w", err)
 	}
+	return marked, nil
+}
 
+// textPage returns the page in grayscale if text rendering is on and the page is plain text
+func (eg *EPUBGenerator) textPage(img image.Image) *image.Gray {
+	if !eg.options.TextRender {
+		return nil
+	}
+	if gray := grayImage(img); looksLikeText(gray) {
+		return gray
+	}
 	return nil
 }
 
-// AddPage adds a single page as a chapter (legacy method, prefer AddChapter for better organization)
-func (eg *EPUBGenerator) AddPage(page PDFPage) error {
-	return eg.AddChapter("Chapter", []PDFPage{page})
+// imageProcessor returns an image processor writing into the generator's temp directory
+func (eg *EPUBGenerator) imageProcessor() (*ImageProcessor, error) {
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewImageProcessor(eg.profile, tempDir), nil
 }
 
-func (eg *EPUBGenerator) createHTMLContent(title, content string) string {
-	// Only add h1 title if it's not generic
-	if title == "Chapter" {
-		return content // Skip generic titles to avoid repetitive headings
+// workDir returns the temp directory for files go-epub reads at write time, creating it on first use
+func (eg *EPUBGenerator) workDir() (string, error) {
+	if eg.tempDir == "" {
+		tempDir, err := os.MkdirTemp("", "publify-images-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		eg.tempDir = tempDir
+	}
+
+	return eg.tempDir, nil
+}
+
+// ImageCount returns the number of images added to the EPUB
+func (eg *EPUBGenerator) ImageCount() int {
+	return eg.imageCount
+}
+
+// Cleanup removes temporary files; call it after Write
+func (eg *EPUBGenerator) Cleanup() error {
+	if eg.tempDir == "" {
+		return nil
 	}
+	return os.RemoveAll(eg.tempDir)
+}
 
-	html := fmt.Sprintf(`<h1>%s</h1>
-%s`, title, content)
+// AddPage adds a single page as a chapter (legacy method, prefer AddChapter for better organization)
+func (eg *EPUBGenerator) AddPage(page PDFPage) error {
+	return eg.AddChapter("Chapter", []PDFPage{page})
+}
 
-	return html
+// createHTMLContent runs a part of a chapter through the chapter template
+func (eg *EPUBGenerator) createHTMLContent(title string, part int, content string) (string, error) {
+	return eg.options.Templates.render(ChapterTemplate, ChapterData{
+		Title: title,
+		// Skip generic titles to avoid repetitive headings
+		ShowTitle: part == 0 && title != "Chapter",
+		Part:      part + 1,
+		Content:   template.HTML(content),
+	})
 }
 
 // SetCover sets the cover image for the EPUB
@@ -129,24 +612,56 @@ func (eg *EPUBGenerator) SetCover(imagePath string) error {
 		return fmt.Errorf("failed to process cover image: %w", err)
 	}
 
-	// Add cover to EPUB
-	_, err = eg.epub.AddImage(processedPath, "cover.jpg")
+	return eg.addCover(processedPath)
+}
+
+// SetCoverImage sets an in-memory image (e.g. a rendered PDF page) as the cover
+func (eg *EPUBGenerator) SetCoverImage(img image.Image) error {
+	processor, err := eg.imageProcessor()
+	if err != nil {
+		return err
+	}
+
+	processedPath, err := processor.ProcessDecodedImage(img, "cover.png")
+	if err != nil {
+		return fmt.Errorf("failed to process cover image: %w", err)
+	}
+
+	return eg.addCover(processedPath)
+}
+
+// addCover adds an optimized image to the EPUB and marks it as the cover
+func (eg *EPUBGenerator) addCover(processedPath string) error {
+	config, err := decodeImageConfig(processedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read cover image: %w", err)
+	}
+
+	internalPath, err := eg.epub.AddImage(processedPath, "cover"+filepath.Ext(processedPath))
 	if err != nil {
 		return fmt.Errorf("failed to add cover image: %w", err)
 	}
 
+	eg.epub.SetCover(internalPath, "")
+	if eg.cover == nil {
+		eg.imageCount++
+	}
+	eg.cover = &coverImage{
+		internalPath: internalPath,
+		width:        config.Width,
+		height:       config.Height,
+	}
+
 	return nil
 }
 
 // processImage optimizes an image for the target reader
 func (eg *EPUBGenerator) processImage(imagePath string) (string, error) {
-	tempDir, err := os.MkdirTemp("", "publify-images-*")
+	processor, err := eg.imageProcessor()
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		return "", err
 	}
 
-	processor := NewImageProcessor(eg.profile, tempDir)
-
 	optimizedPath, err := processor.ProcessImage(imagePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to process image: %w", err)
@@ -165,6 +680,8 @@ func (eg *EPUBGenerator) AddMetadata(name, value string) {
 		eg.epub.SetDescription(value)
 	case "language":
 		eg.epub.SetLang(value)
+	case "publisher":
+		eg.options.Publisher = value
 	}
 }
 
@@ -174,13 +691,174 @@ func (eg *EPUBGenerator) Write(outputPath string) error {
 	if dir != "." {
 	}
 
-	// Write the EPUB file
-	err := eg.epub.Write(outputPath)
+	// go-epub doesn't expose its zip writer or let us touch the package documents,
+	// so build in memory and repack with our own levels and finishing touches
+	if err := eg.addFallbackFont(); err != nil {
+		return err
+	}
+	if err := eg.updateStylesheet(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := eg.epub.WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to write EPUB file: %w", err)
+	}
+
+	src, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return fmt.Errorf("failed to read generated EPUB: %w", err)
+	}
+
+	// go-epub can only write the book once, so images over the budget are swapped in
+	// as it's repacked
+	if err := eg.fitBudget(int64(buf.Len())); err != nil {
+		return err
+	}
+
+	// Embedded fonts are cut down to what the finished book uses
+	if len(eg.fonts) > 0 {
+		if eg.bookText, err = collectBookText(src); err != nil {
+			return err
+		}
+	}
+
+	// Write next to the destination and rename into place, so an existing book is only
+	// replaced by a complete one
+	outFile, err := safefile.Create(outputPath, eg.options.Backup)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Abort()
+
+	// No explicit level keeps go-epub's behaviour: deflate everything at the default level
+	err = epubzip.RepackAdding(src, outFile, epubzip.Options{
+		Level:                eg.options.Compression,
+		StoreCompressedMedia: eg.options.Compression != "",
+		Reproducible:         eg.options.Reproducible,
+	}, eg.finalizeEntry, eg.fontEntries())
 	if err != nil {
 		return fmt.Errorf("failed to write EPUB file: %w", err)
 	}
 
-	return nil
+	return outFile.Commit()
+}
+
+// finalizeEntry applies the changes go-epub can't make itself to a generated file
+func (eg *EPUBGenerator) finalizeEntry(name string, content []byte) ([]byte, error) {
+	if data, ok := eg.budgetEntry(name); ok {
+		return data, nil
+	}
+
+	switch name {
+	case packagePath:
+		content = addDCElements(content, "subject", eg.options.Subjects)
+		if eg.options.Publisher != "" {
+			content = addDCElements(content, "publisher", []string{eg.options.Publisher})
+		}
+		if eg.options.Rights != "" {
+			content = addDCElements(content, "rights", []string{eg.options.Rights})
+		}
+		if eg.options.Owner != "" {
+			content = addPublifyMeta(content, "owner", eg.options.Owner)
+		}
+		if eg.fingerprint != "" {
+			content = addPublifyMeta(content, "fingerprint", eg.fingerprint)
+		}
+		if eg.cover != nil {
+			content = addCoverToPackage(content)
+		}
+		content = addAccessibilityMetadata(content, eg.accessibilityMetadata())
+		content = setFontMediaTypes(content)
+		content = setImageMediaTypes(content)
+		content = setFixedLayout(content, eg.fixedPages)
+		if eg.options.Reproducible {
+			content = setModifiedDate(content, epubzip.SourceDate())
+		}
+		if eg.vertical {
+			content = setVerticalProgression(content)
+		} else if eg.rtl {
+			content = setRightToLeftProgression(content)
+		}
+	case ncxPath:
+		content = addNCXPageList(content, eg.pages)
+	case coverPagePath:
+		if eg.cover != nil {
+			return eg.createCoverPage()
+		}
+	case navPath:
+		content = addNavRoles(content)
+		content = addPageList(content, eg.pages)
+		content = addLandmarks(content, eg.landmarks())
+		content = addDocumentLanguage(content, eg.epub.Lang())
+		if eg.rtl {
+			content = addDocumentDirection(content, directionRTL)
+		}
+	default:
+		if font := eg.embeddedFontAt(name); font != nil {
+			return eg.finishFont(font)
+		}
+		if page, ok := eg.fixedPages[path.Base(name)]; ok && strings.HasPrefix(name, "EPUB/xhtml/") {
+			return eg.createFixedPage(page)
+		}
+		if partition := documentPartition(name); partition != "" {
+			content = addBodyType(content, partition)
+			content = addDocumentLanguage(content, eg.documentLanguage(name))
+			content = addDocumentDirection(content, eg.documentDirection(name))
+			if eg.fontsCSS != "" {
+				content = addStylesheetLink(content, eg.fontsCSS)
+			}
+			if eg.customCSS != "" {
+				content = addStylesheetLink(content, eg.customCSS)
+			}
+		}
+	}
+
+	return content, nil
+}
+
+// documentLanguage is the language of a content document: its own if it differs from the
+// book's, the book's otherwise
+func (eg *EPUBGenerator) documentLanguage(name string) string {
+	if language, ok := eg.sectionLanguages[path.Base(name)]; ok {
+		return language
+	}
+	return eg.epub.Lang()
+}
+
+// addDCElements adds Dublin Core elements go-epub has no setter for (subject, publisher, rights)
+func addDCElements(content []byte, name string, values []string) []byte {
+	if len(values) == 0 {
+		return content
+	}
+
+	var elements strings.Builder
+	for _, value := range values {
+		elements.WriteString("    <dc:" + name + ">")
+		xml.EscapeText(&elements, []byte(value))
+		elements.WriteString("</dc:" + name + ">\n")
+	}
+	elements.WriteString("  </metadata>")
+
+	return []byte(strings.Replace(string(content), "  </metadata>", elements.String(), 1))
+}
+
+// addPublifyMeta records something publify knows about the book, such as who it was
+// converted for, where a library tool can read it back
+func addPublifyMeta(content []byte, name, value string) []byte {
+	var meta strings.Builder
+	meta.WriteString(`    <meta name="publify:` + name + `" content="`)
+	xml.EscapeText(&meta, []byte(value))
+	meta.WriteString("\"/>\n  </metadata>")
+	return []byte(strings.Replace(string(content), "  </metadata>", meta.String(), 1))
+}
+
+// modifiedPattern matches the package's last-modified date, which go-epub sets to now
+var modifiedPattern = regexp.MustCompile(`(<meta property="dcterms:modified">)[^<]*(</meta>)`)
+
+// setModifiedDate sets the package's last-modified date
+func setModifiedDate(content []byte, when time.Time) []byte {
+	return modifiedPattern.ReplaceAll(content, []byte("${1}"+when.UTC().Format("2006-01-02T15:04:05Z")+"${2}"))
 }
 
 // EPUBMetadata contains EPUB metadata information
@@ -1,9 +1,19 @@
 package converter
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alde/publify/pkg/reader"
 )
@@ -223,7 +233,10 @@ func TestCreateHTMLContent(t *testing.T) {
 	title := "Test Chapter"
 	content := "<p>This is test content.</p>"
 
-	html := generator.createHTMLContent(title, content)
+	html, err := generator.createHTMLContent(title, 0, content)
+	if err != nil {
+		t.Fatalf("createHTMLContent failed: %v", err)
+	}
 
 	// Check that HTML contains expected elements
 	if !containsString(html, title) {
@@ -249,6 +262,316 @@ func TestCreateHTMLContent(t *testing.T) {
 	}
 }
 
+func TestEPUBGeneratorSplitsOversizedChapters(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize:    12,
+			MaxContentDocBytes: 4096,
+		},
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book"})
+
+	var pages []PDFPage
+	for i := 1; i <= 10; i++ {
+		pages = append(pages, PDFPage{
+			Number:  i,
+			Text:    strings.Repeat("This sentence pads the page so the chapter grows large. ", 20),
+			HasText: true,
+		})
+	}
+
+	if err := generator.AddChapter("Long Chapter", pages); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "split.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	// The first part is named after the page the chapter starts on, the others after it
+	// and the paragraph they start with
+	partName := regexp.MustCompile(`^page0001-p\d+-[0-9a-f]{6}\.xhtml$`)
+	first, parts := false, 0
+	for _, file := range zipReader.File {
+		switch name := path.Base(file.Name); {
+		case name == "page0001.xhtml":
+			first = true
+		case partName.MatchString(name):
+			parts++
+		case strings.HasPrefix(name, "page0001"):
+			t.Errorf("Expected a further part named after its opening paragraph, got %s", name)
+		}
+	}
+	if !first || parts == 0 {
+		t.Errorf("Expected chapter to be split into page0001.xhtml and further parts, got %d further parts", parts)
+	}
+
+	warnings, err := NewEPUBOptimizer(profile).CheckContentDocuments(outputPath)
+	if err != nil {
+		t.Fatalf("Unexpected error checking content documents: %v", err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("Expected no oversized content documents, got %v", warnings)
+	}
+
+	// A stricter profile should flag the same book
+	strict := profile
+	strict.Capabilities.MaxContentDocBytes = 512
+	warnings, err = NewEPUBOptimizer(strict).CheckContentDocuments(outputPath)
+	if err != nil {
+		t.Fatalf("Unexpected error checking content documents: %v", err)
+	}
+	if len(warnings) == 0 {
+		t.Error("Expected warnings for content documents over 512 bytes")
+	}
+}
+
+func TestSplitIntoChunksSplitsOversizedParagraph(t *testing.T) {
+	sentences := []string{"It was a dark night.", "The rain fell in torrents!", "Who could say <em>why?</em>"}
+	var text strings.Builder
+	for i := 0; i < 30; i++ {
+		text.WriteString(sentences[i%len(sentences)] + " ")
+	}
+	paragraph := `<p id="p1-abcdef">` + strings.TrimSpace(text.String()) + `</p>`
+
+	const limit = 200
+	chunks := NewTextProcessor(TextProcessingOptions{}).SplitIntoChunks(paragraph, limit)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the paragraph split into several chunks, got %d", len(chunks))
+	}
+
+	var words []string
+	for i, chunk := range chunks {
+		if len(chunk) > limit {
+			t.Errorf("Chunk %d is %d bytes, over the %d byte limit", i, len(chunk), limit)
+		}
+		open := `<p>`
+		if i == 0 {
+			open = `<p id="p1-abcdef">`
+		}
+		if !strings.HasPrefix(chunk, open) || !strings.HasSuffix(chunk, "</p>") {
+			t.Errorf("Expected chunk %d wrapped in %s...</p>, got %q", i, open, chunk)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(chunk))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("Chunk %d is not well-formed: %v: %q", i, err, chunk)
+				break
+			}
+		}
+		words = append(words, strings.Fields(stripTags(chunk))...)
+	}
+
+	// Every sentence comes through with its punctuation
+	if got, expected := strings.Join(words, " "), strings.Join(strings.Fields(stripTags(paragraph)), " "); got != expected {
+		t.Errorf("Expected the text kept as it was\n got: %s\nwant: %s", got, expected)
+	}
+}
+
+func TestEPUBGeneratorCompression(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize: 12,
+		},
+	}
+
+	tests := []struct {
+		level      string
+		textMethod uint16
+	}{
+		{"store", zip.Store},
+		{"fast", zip.Deflate},
+		{"default", zip.Deflate},
+		{"best", zip.Deflate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.level, func(t *testing.T) {
+			generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book", Compression: test.level})
+			defer generator.Cleanup()
+
+			page := PDFPage{Number: 1, Text: "Test content", HasText: true}
+			if err := generator.AddPage(page); err != nil {
+				t.Fatalf("Unexpected error adding page: %v", err)
+			}
+
+			outputPath := filepath.Join(t.TempDir(), "compressed.epub")
+			if err := generator.Write(outputPath); err != nil {
+				t.Fatalf("Unexpected error writing EPUB: %v", err)
+			}
+
+			zipReader, err := zip.OpenReader(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to open generated EPUB: %v", err)
+			}
+			defer zipReader.Close()
+
+			first := zipReader.File[0]
+			if first.Name != "mimetype" || first.Method != zip.Store {
+				t.Errorf("Expected stored mimetype as first entry, got %s (method %d)", first.Name, first.Method)
+			}
+
+			for _, file := range zipReader.File {
+				if strings.HasSuffix(file.Name, ".xhtml") && file.Method != test.textMethod {
+					t.Errorf("Expected %s to use method %d, got %d", file.Name, test.textMethod, file.Method)
+				}
+			}
+		})
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book", Compression: "extreme"})
+	generator.AddPage(PDFPage{Number: 1, Text: "Test content", HasText: true})
+	if err := generator.Write(filepath.Join(t.TempDir(), "invalid.epub")); err == nil {
+		t.Error("Expected error for unknown compression level")
+	}
+}
+
+func TestEPUBGeneratorImagePages(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize:       12,
+			ScreenWidth:           600,
+			ScreenHeight:          800,
+			SupportedImageFormats: []string{"jpeg", "png"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 120, 160))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book"})
+	defer generator.Cleanup()
+
+	pages := []PDFPage{
+		{Number: 1, Text: "Cover text that should not be repeated", HasText: true, HasImage: true, PageType: PageTypeImage, ImageData: buf.Bytes()},
+		{Number: 2, Text: "Regular text page.", HasText: true},
+	}
+	if err := generator.AddChapter("Chapter 1", pages); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	if generator.ImageCount() != 1 {
+		t.Errorf("Expected 1 image, got %d", generator.ImageCount())
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "images.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	var images int
+	var content strings.Builder
+	for _, file := range zipReader.File {
+		if strings.Contains(file.Name, "/images/") {
+			images++
+		}
+		if strings.HasSuffix(file.Name, ".xhtml") {
+			rc, err := file.Open()
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", file.Name, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			content.Write(data)
+		}
+	}
+
+	if images != 1 {
+		t.Errorf("Expected 1 image in EPUB, got %d", images)
+	}
+	if !strings.Contains(content.String(), `class="page-image"`) {
+		t.Error("Expected full-page image markup in chapter")
+	}
+	if strings.Contains(content.String(), "Cover text") {
+		t.Error("Image page text should not be duplicated in the chapter")
+	}
+}
+
+func TestEPUBGeneratorCoverPage(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize:       12,
+			ScreenWidth:           600,
+			ScreenHeight:          800,
+			MaxImageWidth:         600,
+			MaxImageHeight:        800,
+			PreferredImageFormat:  "png",
+			SupportedImageFormats: []string{"jpeg", "png"},
+		},
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book"})
+	defer generator.Cleanup()
+
+	if err := generator.AddPage(PDFPage{Number: 1, Text: "Test content", HasText: true}); err != nil {
+		t.Fatalf("Unexpected error adding page: %v", err)
+	}
+	if err := generator.SetCoverImage(image.NewGray(image.Rect(0, 0, 300, 400))); err != nil {
+		t.Fatalf("Unexpected error setting cover: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "cover.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	checks := []struct {
+		file     string
+		expected string
+	}{
+		{coverPagePath, `<meta name="viewport" content="width=600, height=800"/>`},
+		{coverPagePath, `<body epub:type="cover">`},
+		{coverPagePath, `viewBox="0 0 300 400"`},
+		{packagePath, `properties="cover-image"`},
+		{packagePath, `<reference type="cover" title="Cover" href="xhtml/cover.xhtml">`},
+		{navPath, `<a epub:type="cover" href="xhtml/cover.xhtml">Cover</a>`},
+	}
+	for _, check := range checks {
+		if !strings.Contains(files[check.file], check.expected) {
+			t.Errorf("Expected %s to contain %q", check.file, check.expected)
+		}
+	}
+}
+
 // Helper function to check if a string contains a substring
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) &&
@@ -258,3 +581,23 @@ func containsString(s, substr string) bool {
 					s[len(s)-len(substr):] == substr ||
 					containsString(s[1:], substr)))
 }
+
+func TestTextLayerHTML(t *testing.T) {
+	layer := textLayerHTML("CHAPTER ONE\n\nFish & chips were\nserved at noon.\n\n")
+
+	expected := "\n<div class=\"page-text\">\n<p>CHAPTER ONE</p>\n<p>Fish &amp; chips were served at noon.</p>\n</div>\n"
+	if layer != expected {
+		t.Errorf("textLayerHTML() = %q, expected %q", layer, expected)
+	}
+	if strings.Contains(layer, "\n\n") {
+		t.Error("Text layer must not contain blank lines, or chapter splitting could separate it from its image")
+	}
+}
+
+func TestSetModifiedDate(t *testing.T) {
+	opf := []byte(`<metadata><meta property="dcterms:modified">2026-10-16T09:12:44Z</meta></metadata>`)
+	got := setModifiedDate(opf, time.Unix(1700000000, 0))
+	if want := `<metadata><meta property="dcterms:modified">2023-11-14T22:13:20Z</meta></metadata>`; string(got) != want {
+		t.Errorf("setModifiedDate() = %s, want %s", got, want)
+	}
+}
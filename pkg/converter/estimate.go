@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+	"github.com/alde/publify/internal/logging"
+	"github.com/alde/publify/pkg/reader"
+	"github.com/dustin/go-humanize"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+const (
+	// textPageBytes is about what a page of text comes to in the EPUB, markup and zip
+	// compression included
+	textPageBytes = 1500
+	// epubOverheadBytes covers the package document, navigation, stylesheet and the like
+	epubOverheadBytes = 30 << 10
+	// sizeSampleSize is how many pages are measured for the size of the book's pages
+	sizeSampleSize = 5
+)
+
+// bytesPerPixel is about what an image page takes per pixel once compressed, by format, in
+// color and in grayscale. Scans and artwork compress worse than flat diagrams, so these
+// lean high.
+var bytesPerPixel = map[string][2]float64{
+	"avif": {0.15, 0.10},
+	"jxl":  {0.18, 0.12},
+	"webp": {0.22, 0.14},
+	"jpeg": {0.40, 0.25},
+	"png":  {1.40, 0.55},
+}
+
+// ditheredBytesPerPixel is a dithered grayscale page, always a PNG
+const ditheredBytesPerPixel = 0.45
+
+// SizeEstimate is what the EPUB should come to, worked out before any page is processed
+type SizeEstimate struct {
+	TextPages  int
+	ImagePages int
+	TextBytes  int64 // Text pages, figures included
+	ImageBytes int64 // Image pages and the cover
+	Total      int64
+	Limit      int64 // Largest book the reader takes (0 = no limit)
+}
+
+// TooLarge reports whether the book looks set to be larger than the reader takes
+func (e SizeEstimate) TooLarge() bool {
+	return e.Limit > 0 && e.Total > e.Limit
+}
+
+// sizeInputs is what a size estimate goes on
+type sizeInputs struct {
+	inputSize             int64
+	textPages, imagePages int
+	pageWidth, pageHeight float64 // Points
+	figures               bool    // Images embedded in text pages are kept
+}
+
+// predictSize works out the EPUB's size from how its pages will be made: text pages by the
+// page, with the images embedded in the PDF shrunk by the profile's TargetSizeRatio, and
+// image pages by the pixels they'll be scaled to and how those compress in the profile's
+// image format
+func predictSize(profile reader.Profile, in sizeInputs) SizeEstimate {
+	estimate := SizeEstimate{
+		TextPages:  in.textPages,
+		ImagePages: in.imagePages,
+		Limit:      profile.Capabilities.MaxBookBytes,
+	}
+
+	estimate.TextBytes = int64(in.textPages) * textPageBytes
+	if pages := in.textPages + in.imagePages; in.figures && pages > 0 {
+		shrunk := float64(in.inputSize) * float64(in.textPages) / float64(pages) * profile.Capabilities.TargetSizeRatio
+		estimate.TextBytes = max(estimate.TextBytes, int64(shrunk))
+	}
+
+	// The cover is a page image too
+	estimate.ImageBytes = int64(in.imagePages+1) * imagePageBytes(profile, in.pageWidth, in.pageHeight)
+	estimate.Total = estimate.TextBytes + estimate.ImageBytes + epubOverheadBytes
+	return estimate
+}
+
+// imagePageBytes is about what a page rendered as an image comes to for the reader
+func imagePageBytes(profile reader.Profile, pageWidth, pageHeight float64) int64 {
+	settings := profile.ImageProcessingSettings()
+	width := pageWidth / 72 * imagePageDPI
+	height := pageHeight / 72 * imagePageDPI
+	if settings.MaxWidth > 0 && settings.MaxHeight > 0 {
+		scale := math.Min(1, math.Min(float64(settings.MaxWidth)/width, float64(settings.MaxHeight)/height))
+		width, height = width*scale, height*scale
+	}
+
+	perPixel := ditheredBytesPerPixel
+	if !settings.Grayscale || !settings.Dither {
+		rates, ok := bytesPerPixel[NewImageProcessor(profile, "").selectOptimalFormat(settings)]
+		if !ok {
+			rates = bytesPerPixel["jpeg"]
+		}
+		perPixel = rates[0]
+		if settings.Grayscale {
+			perPixel = rates[1]
+		}
+	}
+	return int64(width * height * perPixel)
+}
+
+// classifyPages splits the pages to be converted into those read as text and those kept
+// as images
+func (p *PDFProcessor) classifyPages() ([]int, []int) {
+	var text, images []int
+	for _, page := range p.SelectedPages() {
+		switch {
+		case p.skipPages[page]:
+		case GetPageType(page, p.imagePageRange) == PageTypeImage:
+			images = append(images, page)
+		default:
+			text = append(text, page)
+		}
+	}
+	return text, images
+}
+
+// pageSize measures a sample of pages, returning their mean width and height in points, or
+// those of a US Letter page if none can be measured
+func (p *PDFProcessor) pageSize(pages []int) (float64, float64) {
+	width, height := 612.0, 792.0
+	sample := samplePages(pages, sizeSampleSize)
+	if len(sample) == 0 {
+		return width, height
+	}
+
+	document, err := p.documents.acquire()
+	if err != nil {
+		return width, height
+	}
+	defer p.documents.release(document)
+
+	var totalWidth, totalHeight float64
+	measured := 0
+	for _, number := range sample {
+		page := requests.Page{ByIndex: &requests.PageByIndex{Document: document.doc, Index: number - 1}}
+		widthResp, err := document.instance.FPDF_GetPageWidthF(&requests.FPDF_GetPageWidthF{Page: page})
+		if err != nil {
+			continue
+		}
+		heightResp, err := document.instance.FPDF_GetPageHeightF(&requests.FPDF_GetPageHeightF{Page: page})
+		if err != nil {
+			continue
+		}
+		totalWidth += float64(widthResp.PageWidth)
+		totalHeight += float64(heightResp.PageHeight)
+		measured++
+	}
+	if measured == 0 {
+		return width, height
+	}
+	return totalWidth / float64(measured), totalHeight / float64(measured)
+}
+
+// estimateOutputSize predicts the EPUB's size before the pages are processed
+func (c *Converter) estimateOutputSize() SizeEstimate {
+	textPages, imagePages := c.pdfProc.classifyPages()
+	// Image pages are rendered at their own size; text pages only matter if there are none
+	measure := imagePages
+	if len(measure) == 0 {
+		measure = textPages
+	}
+	width, height := c.pdfProc.pageSize(measure)
+
+	estimate := predictSize(c.options.Profile, sizeInputs{
+		inputSize:  int64(c.stats.InputFileSize),
+		textPages:  len(textPages),
+		imagePages: len(imagePages),
+		pageWidth:  width,
+		pageHeight: height,
+		figures:    !c.options.SkipFigures,
+	})
+	if c.options.MaxSize > 0 {
+		estimate.Limit = c.options.MaxSize
+	}
+	return estimate
+}
+
+// reportEstimate says what size the book should come to, and warns before the long part of
+// the conversion if that's more than the reader takes
+func (c *Converter) reportEstimate(estimate SizeEstimate) {
+	console.Printf("Estimated size: about %s (%s as text, %s as images)\n",
+		humanize.Bytes(uint64(estimate.Total)), pluralPages(estimate.TextPages), pluralPages(estimate.ImagePages))
+	if !estimate.TooLarge() {
+		return
+	}
+
+	logging.Module("converter").Warn(fmt.Sprintf("The EPUB looks set to come to about %s, more than %s",
+		humanize.Bytes(uint64(estimate.Total)), c.sizeLimit(estimate.Limit)))
+	if suggestions := c.sizeSuggestions(estimate); len(suggestions) > 0 {
+		console.Printf("Suggestion: to make it smaller, try %s\n", strings.Join(suggestions, ", or "))
+	}
+}
+
+// sizeLimit says what the size limit is and where it comes from
+func (c *Converter) sizeLimit(limit int64) string {
+	if c.options.MaxSize > 0 {
+		return fmt.Sprintf("the %s --max-size allows", humanize.Bytes(uint64(limit)))
+	}
+	return fmt.Sprintf("the %s the %s takes", humanize.Bytes(uint64(limit)), c.options.Profile.Name)
+}
+
+// sizeSuggestions are the settings that would bring the book's size down the most
+func (c *Converter) sizeSuggestions(estimate SizeEstimate) []string {
+	var suggestions []string
+	if estimate.ImageBytes > estimate.TextBytes {
+		if c.options.ImagePageRange != "" || c.options.FixedLayout {
+			suggestions = append(suggestions, "keeping fewer pages as images (--image-pages)")
+		}
+		if !c.options.TextRender {
+			suggestions = append(suggestions, "--text-render for scanned pages of plain text")
+		}
+		if c.options.Profile.Capabilities.SupportsColor {
+			suggestions = append(suggestions, "a grayscale reader profile")
+		}
+	} else if !c.options.SkipFigures {
+		suggestions = append(suggestions, "--no-figures to leave out the pictures on text pages")
+	}
+	return suggestions
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestPredictSize(t *testing.T) {
+	kindle, err := reader.GetProfile("kindle")
+	if err != nil {
+		t.Fatalf("Failed to get profile: %v", err)
+	}
+
+	// A novel: text pages, a cover, and a PDF that's mostly fonts
+	novel := predictSize(kindle, sizeInputs{
+		inputSize: 2 << 20, textPages: 300, pageWidth: 612, pageHeight: 792, figures: true,
+	})
+	if novel.TextBytes != 300*textPageBytes || novel.TooLarge() {
+		t.Errorf("Expected a novel's text by the page and well within the limit, got %+v", novel)
+	}
+
+	// A scanned letter-size page is scaled to 1200x1553 and stored as grayscale JPEG
+	page := imagePageBytes(kindle, 612, 792)
+	if page < 400<<10 || page > 500<<10 {
+		t.Errorf("Expected about 450 KB for a scanned page, got %d", page)
+	}
+
+	scan := predictSize(kindle, sizeInputs{
+		inputSize: 300 << 20, textPages: 0, imagePages: 150, pageWidth: 612, pageHeight: 792, figures: true,
+	})
+	if scan.ImageBytes != 151*page || !scan.TooLarge() {
+		t.Errorf("Expected 150 scanned pages and the cover past Send-to-Kindle's 50 MB, got %+v", scan)
+	}
+
+	// Embedded figures make up the text pages' share of the PDF, shrunk for the reader
+	illustrated := predictSize(kindle, sizeInputs{
+		inputSize: 100 << 20, textPages: 100, pageWidth: 612, pageHeight: 792, figures: true,
+	})
+	if illustrated.TextBytes != int64(float64(100<<20)*kindle.Capabilities.TargetSizeRatio) {
+		t.Errorf("Expected the figures shrunk by the target size ratio, got %d", illustrated.TextBytes)
+	}
+	withoutFigures := predictSize(kindle, sizeInputs{
+		inputSize: 100 << 20, textPages: 100, pageWidth: 612, pageHeight: 792,
+	})
+	if withoutFigures.TextBytes != 100*textPageBytes {
+		t.Errorf("Expected text alone without figures, got %d", withoutFigures.TextBytes)
+	}
+}
@@ -0,0 +1,321 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+const (
+	// minFigurePixels skips decorations like rules, bullets and ornaments
+	minFigurePixels = 48
+	// maxFigureCoverage skips images covering nearly the whole page, which are scans rather than figures
+	maxFigureCoverage = 0.8
+)
+
+// PageImage is a raster image embedded in a PDF page
+type PageImage struct {
+	Image image.Image
+	// Position is the vertical position of the image's top edge as a fraction of
+	// the page height, measured from the top (0 = top of page, 1 = bottom)
+	Position float64
+
+	size int64 // Bytes the image came to as extracted, once it's been to disk (0 = not known)
+}
+
+// extractPageImages pulls the embedded raster images out of a page, in reading order
+func extractPageImages(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pageIndex int, pageWidth, pageHeight float64) ([]PageImage, error) {
+	page := requests.Page{
+		ByIndex: &requests.PageByIndex{
+			Document: doc,
+			Index:    pageIndex,
+		},
+	}
+
+	countResp, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{Page: page})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count page objects: %w", err)
+	}
+
+	var images []PageImage
+	for i := 0; i < countResp.Count; i++ {
+		objResp, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{Page: page, Index: i})
+		if err != nil {
+			continue
+		}
+
+		typeResp, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: objResp.PageObject})
+		if err != nil || typeResp.Type != enums.FPDF_PAGEOBJ_IMAGE {
+			continue
+		}
+
+		bounds, err := instance.FPDFPageObj_GetBounds(&requests.FPDFPageObj_GetBounds{PageObject: objResp.PageObject})
+		if err != nil {
+			continue
+		}
+
+		// Full-page images are scanned pages, not figures - OCR and image pages handle those
+		coverage := float64(bounds.Right-bounds.Left) * float64(bounds.Top-bounds.Bottom) / (pageWidth * pageHeight)
+		if coverage > maxFigureCoverage {
+			continue
+		}
+
+		bitmapResp, err := instance.FPDFImageObj_GetRenderedBitmap(&requests.FPDFImageObj_GetRenderedBitmap{
+			Document:    doc,
+			Page:        page,
+			ImageObject: objResp.PageObject,
+		})
+		if err != nil {
+			continue
+		}
+
+		img, err := bitmapToImage(instance, bitmapResp.Bitmap)
+		instance.FPDFBitmap_Destroy(&requests.FPDFBitmap_Destroy{Bitmap: bitmapResp.Bitmap})
+		if err != nil {
+			continue
+		}
+
+		if img.Bounds().Dx() < minFigurePixels || img.Bounds().Dy() < minFigurePixels {
+			continue
+		}
+
+		// PDF coordinates start at the bottom of the page
+		position := 1.0 - float64(bounds.Top)/pageHeight
+		images = append(images, PageImage{
+			Image:    img,
+			Position: math.Max(0, math.Min(1, position)),
+		})
+	}
+
+	// Keep figures in reading order (top to bottom), regardless of content stream order
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Position < images[j].Position
+	})
+
+	return images, nil
+}
+
+// bitmapToImage copies a PDFium bitmap into a Go image
+func bitmapToImage(instance pdfium.Pdfium, bitmap references.FPDF_BITMAP) (image.Image, error) {
+	widthResp, err := instance.FPDFBitmap_GetWidth(&requests.FPDFBitmap_GetWidth{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	heightResp, err := instance.FPDFBitmap_GetHeight(&requests.FPDFBitmap_GetHeight{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	strideResp, err := instance.FPDFBitmap_GetStride(&requests.FPDFBitmap_GetStride{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	formatResp, err := instance.FPDFBitmap_GetFormat(&requests.FPDFBitmap_GetFormat{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+	bufferResp, err := instance.FPDFBitmap_GetBuffer(&requests.FPDFBitmap_GetBuffer{Bitmap: bitmap})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeBitmap(bufferResp.Buffer, widthResp.Width, heightResp.Height, strideResp.Stride, formatResp.Format)
+}
+
+// decodeBitmap converts raw PDFium pixel data (BGR byte order) to a Go image
+func decodeBitmap(buffer []byte, width, height, stride int, format enums.FPDF_BITMAP_FORMAT) (image.Image, error) {
+	var bytesPerPixel int
+	switch format {
+	case enums.FPDF_BITMAP_FORMAT_GRAY:
+		bytesPerPixel = 1
+	case enums.FPDF_BITMAP_FORMAT_BGR:
+		bytesPerPixel = 3
+	case enums.FPDF_BITMAP_FORMAT_BGRX, enums.FPDF_BITMAP_FORMAT_BGRA:
+		bytesPerPixel = 4
+	default:
+		return nil, fmt.Errorf("unsupported bitmap format: %d", format)
+	}
+
+	if width <= 0 || height <= 0 || len(buffer) < stride*(height-1)+width*bytesPerPixel {
+		return nil, fmt.Errorf("invalid bitmap dimensions %dx%d (stride %d, %d bytes)", width, height, stride, len(buffer))
+	}
+
+	if format == enums.FPDF_BITMAP_FORMAT_GRAY {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			copy(img.Pix[y*img.Stride:y*img.Stride+width], buffer[y*stride:y*stride+width])
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := y*stride + x*bytesPerPixel
+			alpha := uint8(255)
+			if format == enums.FPDF_BITMAP_FORMAT_BGRA {
+				alpha = buffer[offset+3]
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: buffer[offset+2], G: buffer[offset+1], B: buffer[offset], A: alpha})
+		}
+	}
+
+	return img, nil
+}
+
+// pageFigure is an optimized image ready to be placed in a chapter
+type pageFigure struct {
+	Position float64
+	HTML     string // Markup with an empty alt attribute, filled in from a caption if one is found
+	ID       string // Used to link a long caption to the image ("" = no link)
+}
+
+const (
+	// maxAltLength keeps alt text short enough to be read out comfortably; longer
+	// captions are linked with aria-describedby instead of being crammed into alt
+	maxAltLength = 150
+)
+
+// captionPattern matches the start of a figure caption: "Figure 3.", "Fig. 12:", "Plate IV" and so on
+var captionPattern = regexp.MustCompile(`(?i)^(?:fig(?:ure)?\.?|plate|illustration|map|photo(?:graph)?|chart|diagram)\s*(?:[0-9]+(?:\.[0-9]+)?|[ivxlc]+)\b`)
+
+var blockTags = regexp.MustCompile(`<[^>]+>`)
+
+var blockIDPattern = regexp.MustCompile(`^<p id="([^"]+)">`)
+
+// blockText returns the plain text of a block of page HTML
+func blockText(block string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(blockTags.ReplaceAllString(block, " "))), " ")
+}
+
+// findCaption returns the text of a block if it reads like a figure caption
+func findCaption(block string) string {
+	text := blockText(block)
+	if !captionPattern.MatchString(text) {
+		return ""
+	}
+	return text
+}
+
+// findCaptionInText looks for a caption line in a page's raw text, for image pages
+// whose text isn't shown
+func findCaptionInText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !captionPattern.MatchString(line) {
+			continue
+		}
+		// Captions run on until the next blank line
+		caption := []string{line}
+		for _, next := range lines[i+1:] {
+			if strings.TrimSpace(next) == "" {
+				break
+			}
+			caption = append(caption, strings.TrimSpace(next))
+		}
+		return strings.Join(caption, " ")
+	}
+	return ""
+}
+
+// altText shortens a caption to something that works as alt text, cutting at a word boundary
+func altText(caption string) string {
+	if len(caption) <= maxAltLength {
+		return caption
+	}
+	cut := strings.LastIndex(caption[:maxAltLength], " ")
+	if cut <= 0 {
+		cut = maxAltLength
+	}
+	return strings.TrimRight(caption[:cut], " ,.;:") + "…"
+}
+
+// describeFigure fills in a figure's alt text from its caption. A caption too long for
+// alt gets an id, and the image points to it with aria-describedby so nothing is lost.
+func describeFigure(figure pageFigure, captionBlock *string) string {
+	caption := findCaption(*captionBlock)
+	if caption == "" || !strings.Contains(figure.HTML, `alt=""`) {
+		return figure.HTML
+	}
+
+	attributes := fmt.Sprintf(`alt="%s"`, html.EscapeString(altText(caption)))
+	if len(caption) > maxAltLength && figure.ID != "" {
+		captionID := ""
+		if match := blockIDPattern.FindStringSubmatch(*captionBlock); match != nil {
+			captionID = match[1] // The caption already has its paragraph id
+		} else if strings.HasPrefix(*captionBlock, "<p>") {
+			captionID = figure.ID + "-caption"
+			*captionBlock = fmt.Sprintf(`<p id="%s">`, captionID) + strings.TrimPrefix(*captionBlock, "<p>")
+		}
+		if captionID != "" {
+			attributes += fmt.Sprintf(` aria-describedby="%s"`, captionID)
+		}
+	}
+
+	return strings.Replace(figure.HTML, `alt=""`, attributes, 1)
+}
+
+// placeFigures interleaves figure markup into a page's HTML, putting each figure at the
+// block boundary closest to where it sat vertically on the original page
+func placeFigures(pageHTML string, figures []pageFigure) string {
+	if len(figures) == 0 {
+		return pageHTML
+	}
+
+	// Split into complete blocks so figures never land inside a paragraph
+	var blocks []string
+	var current []string
+	for _, line := range strings.Split(pageHTML, "\n") {
+		if line == "" {
+			continue
+		}
+		current = append(current, line)
+		if line == "</p>" || strings.HasPrefix(line, "<h2>") {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+
+	// Work out where each figure goes, then describe it from the caption below it
+	// (or above, where some books put them)
+	figures = append([]pageFigure(nil), figures...)
+	slots := make([]int, len(figures))
+	for i, figure := range figures {
+		slots[i] = min(int(math.Round(figure.Position*float64(len(blocks)))), len(blocks))
+	}
+	for i := range figures {
+		switch {
+		case slots[i] < len(blocks) && findCaption(blocks[slots[i]]) != "":
+			figures[i].HTML = describeFigure(figures[i], &blocks[slots[i]])
+		case slots[i] > 0 && findCaption(blocks[slots[i]-1]) != "":
+			figures[i].HTML = describeFigure(figures[i], &blocks[slots[i]-1])
+		}
+	}
+
+	var result []string
+	next := 0
+	for i := 0; i <= len(blocks); i++ {
+		for next < len(figures) && slots[next] <= i {
+			result = append(result, figures[next].HTML)
+			next++
+		}
+		if i < len(blocks) {
+			result = append(result, blocks[i])
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
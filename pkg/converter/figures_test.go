@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/klippa-app/go-pdfium/enums"
+)
+
+func TestPlaceFigures(t *testing.T) {
+	pageHTML := "<h2>HEADING</h2>\n<p>\nFirst paragraph<br/>\n</p>\n<p>\nSecond paragraph<br/>\n</p>"
+
+	tests := []struct {
+		name     string
+		position float64
+		before   string
+		after    string
+	}{
+		{"top of page", 0.0, `<img src="fig"/>`, "<h2>HEADING</h2>"},
+		{"middle of page", 0.5, "First paragraph", "Second paragraph"},
+		{"bottom of page", 1.0, "Second paragraph", `<img src="fig"/>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := placeFigures(pageHTML, []pageFigure{{Position: test.position, HTML: `<img src="fig"/>`}})
+
+			if strings.Count(result, `<img src="fig"/>`) != 1 {
+				t.Fatalf("Expected figure exactly once, got %q", result)
+			}
+			if strings.Index(result, test.before) > strings.Index(result, test.after) {
+				t.Errorf("Expected %q before %q in %q", test.before, test.after, result)
+			}
+			if strings.Contains(result, "<br/>\n<img") {
+				t.Errorf("Figure was placed inside a paragraph: %q", result)
+			}
+		})
+	}
+
+	if placeFigures("", []pageFigure{{Position: 0.3, HTML: "<img/>"}}) != "<img/>" {
+		t.Error("Figures on pages without text should still be kept")
+	}
+}
+
+func TestPlaceFiguresUsesCaptionsAsAltText(t *testing.T) {
+	figure := pageFigure{Position: 0.5, HTML: `<div class="figure"><img src="fig" alt=""/></div>`, ID: "page0003-figure1"}
+
+	pageHTML := "<p>\nSome text<br/>\n</p>\n<p>\nFigure 2. The harbour at &quot;dawn&quot;<br/>\n</p>"
+	result := placeFigures(pageHTML, []pageFigure{figure})
+	if !strings.Contains(result, `alt="Figure 2. The harbour at &#34;dawn&#34;"`) {
+		t.Errorf("Expected caption as alt text, got %q", result)
+	}
+
+	// Captions too long for alt are shortened and linked instead
+	long := "Plate IV. " + strings.Repeat("A very long description of the picture. ", 6)
+	pageHTML = "<p>\nSome text<br/>\n</p>\n<p>\n" + long + "<br/>\n</p>"
+	result = placeFigures(pageHTML, []pageFigure{figure})
+	if !strings.Contains(result, `aria-describedby="page0003-figure1-caption"`) || !strings.Contains(result, `<p id="page0003-figure1-caption">`) {
+		t.Errorf("Expected long caption to be linked, got %q", result)
+	}
+	if !strings.Contains(result, `…"`) {
+		t.Errorf("Expected shortened alt text, got %q", result)
+	}
+
+	// A caption with a paragraph id keeps it
+	pageHTML = "<p id=\"p3-1a2b3c\">\nSome text<br/>\n</p>\n<p id=\"p3-4d5e6f\">\n" + long + "<br/>\n</p>"
+	result = placeFigures(pageHTML, []pageFigure{figure})
+	if !strings.Contains(result, `aria-describedby="p3-4d5e6f"`) || strings.Contains(result, "figure1-caption") {
+		t.Errorf("Expected long caption to be linked by its own id, got %q", result)
+	}
+
+	// Ordinary paragraphs aren't captions
+	result = placeFigures("<p>\nSome text<br/>\n</p>\n<p>\nMore text<br/>\n</p>", []pageFigure{figure})
+	if !strings.Contains(result, `alt=""`) {
+		t.Errorf("Expected empty alt without a caption, got %q", result)
+	}
+}
+
+func TestFindCaptionInText(t *testing.T) {
+	text := "CHAPTER 3\n\nFig. 12: Map of the\nnorthern coast\n\nPage 41"
+	if caption := findCaptionInText(text); caption != "Fig. 12: Map of the northern coast" {
+		t.Errorf("Unexpected caption %q", caption)
+	}
+	if caption := findCaptionInText("Figures of speech are everywhere"); caption != "" {
+		t.Errorf("Expected no caption, got %q", caption)
+	}
+}
+
+func TestDecodeBitmap(t *testing.T) {
+	// Two BGRA pixels: pure blue, half-transparent red
+	buffer := []byte{255, 0, 0, 255, 0, 0, 255, 128}
+
+	img, err := decodeBitmap(buffer, 2, 1, 8, enums.FPDF_BITMAP_FORMAT_BGRA)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding bitmap: %v", err)
+	}
+
+	r, g, b, _ := img.At(0, 0).RGBA()
+	if r != 0 || g != 0 || b == 0 {
+		t.Errorf("Expected first pixel to be blue, got r=%d g=%d b=%d", r, g, b)
+	}
+
+	if _, err := decodeBitmap(buffer, 4, 4, 8, enums.FPDF_BITMAP_FORMAT_BGRA); err == nil {
+		t.Error("Expected error for buffer smaller than the bitmap dimensions")
+	}
+
+	if _, err := decodeBitmap(buffer, 2, 1, 8, enums.FPDF_BITMAP_FORMAT_UNKNOWN); err == nil {
+		t.Error("Expected error for unknown bitmap format")
+	}
+}
@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// With --fixed-layout every image page becomes a page of its own with a fixed layout, shown
+// whole and just as printed rather than flowed like text, which art books and sheet music
+// need. Text pages around them still reflow. A book of nothing but image pages is fixed
+// layout throughout, which is what readers handle best.
+
+// fixedPage says whether a page is shown as an image, and so gets a fixed layout
+func fixedPage(page PDFPage) bool {
+	return len(page.ImageData) > 0 || (page.payload != nil && page.payload.imageData != "")
+}
+
+// addFixedLayoutChapter adds a chapter whose image pages each get a fixed-layout page, and
+// whose runs of other pages are added as usual
+func (eg *EPUBGenerator) addFixedLayoutChapter(title string, pages []PDFPage) error {
+	part := 0
+	for start := 0; start < len(pages); {
+		if fixedPage(pages[start]) {
+			parts, err := eg.addFixedPage(title, part, pages[start])
+			if err != nil {
+				return fmt.Errorf("failed to add image for page %d: %w", pages[start].Number, err)
+			}
+			part += parts
+			start++
+			continue
+		}
+
+		end := start + 1
+		for end < len(pages) && !fixedPage(pages[end]) {
+			end++
+		}
+		parts, err := eg.addChapter(title, pages[start:end], part)
+		if err != nil {
+			return err
+		}
+		part += parts
+		start = end
+	}
+	return nil
+}
+
+// addFixedPage adds an image page as fixed-layout documents, two for a spread that's split,
+// and returns how many it added. go-epub only writes reflowable ones, so each gets the
+// page's usual markup and the document is replaced when the EPUB is written.
+func (eg *EPUBGenerator) addFixedPage(title string, part int, page PDFPage) (int, error) {
+	page, err := page.withPayload()
+	if err != nil {
+		return 0, err
+	}
+	added, err := eg.addPageImageFiles(page)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, file := range added {
+		// The page break and text layer go with the first page of a split spread
+		textLayer, pageBreak := "", ""
+		if i == 0 {
+			if eg.options.TextLayer && page.HasText {
+				textLayer = textLayerHTML(page.Text)
+			}
+			pageBreak = pageBreakHTML(page)
+		}
+		body := fmt.Sprintf(`%s<div class="page-image"><img src="%s" alt="%s"/>%s</div>`,
+			pageBreak, file.internalPath, html.EscapeString(file.alt), textLayer)
+
+		sectionTitle := ""
+		if part+i == 0 {
+			sectionTitle = title
+		}
+		section, err := eg.epub.AddSection(body, sectionTitle, eg.sectionFilename(page.Number, i, ""), "")
+		if err != nil {
+			return 0, fmt.Errorf("failed to add page: %w", err)
+		}
+		name := path.Base(section)
+
+		width, height := fixedViewport(file.width, file.height,
+			eg.profile.Capabilities.ScreenWidth, eg.profile.Capabilities.ScreenHeight)
+		eg.fixedPages[name] = FixedPageData{
+			Title:          title,
+			ViewportWidth:  width,
+			ViewportHeight: height,
+			ImageWidth:     file.width,
+			ImageHeight:    file.height,
+			ImagePath:      file.internalPath,
+			Alt:            file.alt,
+			PageBreak:      template.HTML(pageBreak),
+			TextLayer:      template.HTML(textLayer),
+		}
+		if eg.firstChapter == "" {
+			eg.firstChapter = name
+		}
+		eg.addPageTargets(name, pageBreak)
+	}
+	return len(added), nil
+}
+
+// createFixedPage renders a fixed-layout page document
+func (eg *EPUBGenerator) createFixedPage(data FixedPageData) ([]byte, error) {
+	data.Language = eg.epub.Lang()
+	if data.Title == "" || data.Title == "Chapter" {
+		data.Title = eg.epub.Title()
+	}
+	page, err := eg.options.Templates.render(FixedPageTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xmlDeclaration + strings.TrimLeft(page, " \t\r\n")), nil
+}
+
+// fixedViewport is the size of a fixed-layout page: the image scaled to fit the reader's
+// screen, keeping its shape, or the image itself when the screen size is unknown
+func fixedViewport(imageWidth, imageHeight, screenWidth, screenHeight int) (int, int) {
+	if screenWidth <= 0 || screenHeight <= 0 || imageWidth <= 0 || imageHeight <= 0 {
+		return imageWidth, imageHeight
+	}
+	// Compare imageWidth/imageHeight with screenWidth/screenHeight without dividing
+	if imageWidth*screenHeight > screenWidth*imageHeight {
+		return screenWidth, max(1, (imageHeight*screenWidth+imageWidth/2)/imageWidth)
+	}
+	return max(1, (imageWidth*screenHeight+imageHeight/2)/imageHeight), screenHeight
+}
+
+var itemrefPattern = regexp.MustCompile(`<itemref idref="([^"]+)"`)
+
+// setFixedLayout marks the fixed-layout pages in a package document's spine, and the whole
+// book as fixed layout when nothing else but the cover is in it
+func setFixedLayout(content []byte, pages map[string]FixedPageData) []byte {
+	if len(pages) == 0 {
+		return content
+	}
+
+	reflowable := 0
+	opf := itemrefPattern.ReplaceAllStringFunc(string(content), func(itemref string) string {
+		idref := itemrefPattern.FindStringSubmatch(itemref)[1]
+		if _, ok := pages[idref]; ok {
+			return itemref + ` properties="rendition:layout-pre-paginated"`
+		}
+		if idref != path.Base(coverPageHref) {
+			reflowable++
+		}
+		return itemref
+	})
+
+	meta := `    <meta property="rendition:spread">auto</meta>` + "\n"
+	if reflowable == 0 {
+		meta = `    <meta property="rendition:layout">pre-paginated</meta>` + "\n" + meta
+	}
+	return []byte(strings.Replace(opf, "  </metadata>", meta+"  </metadata>", 1))
+}
@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestFixedViewport(t *testing.T) {
+	tests := []struct {
+		name                          string
+		imageWidth, imageHeight       int
+		screenWidth, screenHeight     int
+		expectedWidth, expectedHeight int
+	}{
+		{"taller than the screen", 1600, 2400, 1072, 1448, 965, 1448},
+		{"wider than the screen", 2400, 1200, 1072, 1448, 1072, 536},
+		{"same shape", 600, 800, 1200, 1600, 1200, 1600},
+		{"unknown screen", 1600, 2400, 0, 0, 1600, 2400},
+	}
+	for _, tt := range tests {
+		width, height := fixedViewport(tt.imageWidth, tt.imageHeight, tt.screenWidth, tt.screenHeight)
+		if width != tt.expectedWidth || height != tt.expectedHeight {
+			t.Errorf("%s: expected %dx%d, got %dx%d", tt.name, tt.expectedWidth, tt.expectedHeight, width, height)
+		}
+	}
+}
+
+func TestSetFixedLayout(t *testing.T) {
+	opf := `<package>
+  <metadata>
+  </metadata>
+  <spine toc="ncx">
+    <itemref idref="cover.xhtml"></itemref>
+    <itemref idref="page0001.xhtml"></itemref>
+    <itemref idref="page0002.xhtml"></itemref>
+  </spine>
+</package>`
+
+	mixed := string(setFixedLayout([]byte(opf), map[string]FixedPageData{"page0001.xhtml": {}}))
+	if !strings.Contains(mixed, `<itemref idref="page0001.xhtml" properties="rendition:layout-pre-paginated">`) {
+		t.Errorf("Expected the image page marked pre-paginated:\n%s", mixed)
+	}
+	if strings.Contains(mixed, `<itemref idref="page0002.xhtml" properties=`) || strings.Contains(mixed, `"rendition:layout">`) {
+		t.Errorf("Expected the text page and the book left reflowable:\n%s", mixed)
+	}
+
+	whole := string(setFixedLayout([]byte(opf), map[string]FixedPageData{"page0001.xhtml": {}, "page0002.xhtml": {}}))
+	if !strings.Contains(whole, `<meta property="rendition:layout">pre-paginated</meta>`) {
+		t.Errorf("Expected a book of image pages to be fixed layout throughout:\n%s", whole)
+	}
+
+	if unchanged := string(setFixedLayout([]byte(opf), nil)); unchanged != opf {
+		t.Errorf("Expected a book without fixed pages unchanged, got:\n%s", unchanged)
+	}
+}
+
+func TestEPUBGeneratorFixedLayout(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize:       12,
+			ScreenWidth:           600,
+			ScreenHeight:          800,
+			MaxImageWidth:         600,
+			MaxImageHeight:        800,
+			PreferredImageFormat:  "png",
+			SupportedImageFormats: []string{"jpeg", "png"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Sonatas", FixedLayout: true})
+	defer generator.Cleanup()
+
+	pages := []PDFPage{
+		{Number: 1, Text: "Preface to the sonatas.", HasText: true},
+		{Number: 2, Text: "Sonata No. 1", HasText: true, HasImage: true, PageType: PageTypeImage, ImageData: buf.Bytes()},
+		{Number: 3, Text: "Notes on the sonata.", HasText: true},
+	}
+	if err := generator.AddChapter("Sonata No. 1", pages); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "fixed.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	checks := []struct {
+		file     string
+		expected string
+	}{
+		{"EPUB/xhtml/page0002.xhtml", `<meta name="viewport" content="width=600, height=400"/>`},
+		{"EPUB/xhtml/page0002.xhtml", `viewBox="0 0 300 200"`},
+		{"EPUB/xhtml/page0002.xhtml", `id="page0002"`},
+		{"EPUB/xhtml/page0001.xhtml", "Preface to the sonatas."},
+		{"EPUB/xhtml/page0003.xhtml", "Notes on the sonata."},
+		{packagePath, `<itemref idref="page0002.xhtml" properties="rendition:layout-pre-paginated">`},
+		{navPath, `<a href="xhtml/page0002.xhtml#page0002">2</a>`},
+	}
+	for _, check := range checks {
+		if !strings.Contains(files[check.file], check.expected) {
+			t.Errorf("Expected %s to contain %q", check.file, check.expected)
+		}
+	}
+	if strings.Contains(files[packagePath], `"rendition:layout">`) {
+		t.Error("Expected a book with text pages to stay reflowable")
+	}
+	if strings.Contains(files["EPUB/xhtml/page0003.xhtml"], "<h1>") {
+		t.Error("Expected only the first part of the chapter to show its title")
+	}
+}
@@ -0,0 +1,285 @@
+package converter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// fallbackFont is a font embedded for the characters the reader's own fonts lack. Only
+// the glyphs the book uses are kept, since a font covering a whole script runs to
+// megabytes.
+type fallbackFont struct {
+	name string // File name in the EPUB
+	data []byte
+	font *sfnt.Font
+	buf  sfnt.Buffer
+
+	glyphs map[rune]sfnt.GlyphIndex // The characters drawn with it so far
+}
+
+func loadFallbackFont(path string) (*fallbackFont, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fallback font: %w", err)
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fallback font %s: %w", path, err)
+	}
+	return &fallbackFont{
+		name:   "fallback" + strings.ToLower(filepath.Ext(path)),
+		data:   data,
+		font:   font,
+		glyphs: make(map[rune]sfnt.GlyphIndex),
+	}, nil
+}
+
+// has reports whether the font has a glyph for a character, remembering it if so
+func (f *fallbackFont) has(r rune) bool {
+	if _, ok := f.glyphs[r]; ok {
+		return true
+	}
+	index, err := f.font.GlyphIndex(&f.buf, r)
+	if err != nil || index == 0 {
+		return false
+	}
+	f.glyphs[r] = index
+	return true
+}
+
+// subset returns the font with only the glyphs used so far
+func (f *fallbackFont) subset() ([]byte, error) {
+	keep := make(map[uint16]bool, len(f.glyphs))
+	for _, index := range f.glyphs {
+		keep[uint16(index)] = true
+	}
+	return subsetTrueType(f.data, keep)
+}
+
+// fontTable is a table of an sfnt font file
+type fontTable struct {
+	tag  string
+	data []byte
+}
+
+// subsetTrueType empties the outlines of the glyphs not kept. Glyph numbers stay as they
+// are, so the character map, metrics and layout tables still hold; it's the outlines that
+// take up the room. Fonts with PostScript outlines (.otf) are returned unchanged.
+func subsetTrueType(data []byte, keep map[uint16]bool) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) == "OTTO" {
+		return data, nil
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, fmt.Errorf("font table directory is truncated")
+	}
+	tables := make([]fontTable, 0, numTables)
+	byTag := make(map[string]int)
+	for i := range numTables {
+		record := data[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("font table %q is truncated", record[:4])
+		}
+		tag := string(record[:4])
+		if tag == "DSIG" {
+			continue // A signature for the whole font, which won't hold for the subset
+		}
+		byTag[tag] = len(tables)
+		tables = append(tables, fontTable{tag: tag, data: data[offset : offset+length]})
+	}
+
+	headIndex, hasHead := byTag["head"]
+	locaIndex, hasLoca := byTag["loca"]
+	glyfIndex, hasGlyf := byTag["glyf"]
+	if !hasHead || !hasLoca || !hasGlyf || len(tables[headIndex].data) < 54 {
+		return data, nil // No TrueType outlines to subset
+	}
+	longOffsets := binary.BigEndian.Uint16(tables[headIndex].data[50:]) == 1
+
+	loca, glyf := tables[locaIndex].data, tables[glyfIndex].data
+	offsets, err := readLoca(loca, longOffsets, len(glyf))
+	if err != nil {
+		return nil, err
+	}
+
+	// .notdef is drawn for anything missing, and composite glyphs need their parts
+	keep[0] = true
+	addGlyphComponents(keep, glyf, offsets)
+
+	// Short offsets count in two-byte units, and can't reach as far if every glyph is padded
+	align := 2
+	if longOffsets {
+		align = 4
+	}
+	var newGlyf []byte
+	newOffsets := make([]int, len(offsets))
+	for glyph := 0; glyph < len(offsets)-1; glyph++ {
+		newOffsets[glyph] = len(newGlyf)
+		if keep[uint16(glyph)] {
+			newGlyf = append(newGlyf, glyf[offsets[glyph]:offsets[glyph+1]]...)
+			for len(newGlyf)%align != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	newOffsets[len(offsets)-1] = len(newGlyf)
+
+	tables[glyfIndex].data = newGlyf
+	tables[locaIndex].data = writeLoca(newOffsets, longOffsets)
+
+	head := append([]byte(nil), tables[headIndex].data...)
+	binary.BigEndian.PutUint32(head[8:], 0) // checkSumAdjustment, set once the file is laid out
+	tables[headIndex].data = head
+
+	return writeSfnt(data[:4], tables), nil
+}
+
+func readLoca(loca []byte, long bool, glyfLength int) ([]int, error) {
+	size := 2
+	if long {
+		size = 4
+	}
+	offsets := make([]int, len(loca)/size)
+	for i := range offsets {
+		if long {
+			offsets[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+		} else {
+			offsets[i] = int(binary.BigEndian.Uint16(loca[2*i:])) * 2
+		}
+		if offsets[i] > glyfLength || (i > 0 && offsets[i] < offsets[i-1]) {
+			return nil, fmt.Errorf("font glyph locations are out of order")
+		}
+	}
+	if len(offsets) < 2 {
+		return nil, fmt.Errorf("font has no glyph locations")
+	}
+	return offsets, nil
+}
+
+func writeLoca(offsets []int, long bool) []byte {
+	if long {
+		loca := make([]byte, 4*len(offsets))
+		for i, offset := range offsets {
+			binary.BigEndian.PutUint32(loca[4*i:], uint32(offset))
+		}
+		return loca
+	}
+	loca := make([]byte, 2*len(offsets))
+	for i, offset := range offsets {
+		binary.BigEndian.PutUint16(loca[2*i:], uint16(offset/2))
+	}
+	return loca
+}
+
+// Composite glyph flags
+const (
+	argsAreWords   = 0x0001
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// addGlyphComponents adds the glyphs that kept composite glyphs are built from, such as
+// the letter and the accent of an accented letter
+func addGlyphComponents(keep map[uint16]bool, glyf []byte, offsets []int) {
+	queue := make([]uint16, 0, len(keep))
+	for glyph := range keep {
+		queue = append(queue, glyph)
+	}
+
+	for len(queue) > 0 {
+		glyph := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if int(glyph) >= len(offsets)-1 {
+			continue
+		}
+		outline := glyf[offsets[glyph]:offsets[glyph+1]]
+		if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) >= 0 {
+			continue // Empty, or a simple glyph with contours of its own
+		}
+
+		for pos := 10; pos+4 <= len(outline); {
+			flags := binary.BigEndian.Uint16(outline[pos:])
+			component := binary.BigEndian.Uint16(outline[pos+2:])
+			if !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+			if flags&moreComponents == 0 {
+				break
+			}
+
+			pos += 4 + 2 // Flags, glyph and two byte-sized arguments
+			if flags&argsAreWords != 0 {
+				pos += 2
+			}
+			switch {
+			case flags&haveScale != 0:
+				pos += 2
+			case flags&haveXYScale != 0:
+				pos += 4
+			case flags&haveTwoByTwo != 0:
+				pos += 8
+			}
+		}
+	}
+}
+
+// writeSfnt lays out a font file: the table directory, then the tables in tag order,
+// each on a four-byte boundary with its checksum
+func writeSfnt(version []byte, tables []fontTable) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(tables) {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	out := make([]byte, 12+16*len(tables))
+	copy(out, version)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(tables)))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange*16))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(len(tables)*16-searchRange*16))
+
+	headOffset := 0
+	for i, table := range tables {
+		record := out[12+16*i:]
+		copy(record, table.tag)
+		binary.BigEndian.PutUint32(record[4:], fontChecksum(table.data))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table.data)))
+
+		if table.tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, table.data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+
+	binary.BigEndian.PutUint32(out[headOffset+8:], 0xb1b0afba-fontChecksum(out))
+	return out
+}
+
+// fontChecksum sums data as big-endian 32-bit words, the way sfnt checksums are taken
+func fontChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
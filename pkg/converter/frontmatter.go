@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// frontMatterCSS styles the generated title page and colophon, the centered pages
+// (dedications, epigraphs) found in the PDF, the text layer over image pages and the
+// notes on pages OCR had trouble reading.
+// Sizes are relative so the reader's own font settings still apply.
+const frontMatterCSS = `.titlepage {
+  text-align: center;
+  margin-top: 30%;
+}
+.titlepage .title {
+  font-size: 2em;
+  margin-bottom: 1em;
+}
+.titlepage .author {
+  font-size: 1.3em;
+}
+.titlepage .publisher {
+  margin-top: 4em;
+  font-size: 0.9em;
+}
+.colophon {
+  font-size: 0.85em;
+  margin-top: 20%;
+}
+.colophon ul {
+  list-style: none;
+  padding: 0;
+}
+.centered-page {
+  text-align: center;
+  margin: 25% 10%;
+  page-break-before: always;
+  page-break-after: always;
+}
+.centered-page p {
+  text-indent: 0;
+}
+.page-image {
+  position: relative;
+}
+.page-text {
+  position: absolute;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  overflow: hidden;
+  color: transparent;
+}
+.page-text p {
+  margin: 0;
+  text-indent: 0;
+}
+.ocr-warning {
+  font-size: 0.85em;
+  font-style: italic;
+  text-indent: 0;
+  margin: 1em 0;
+}
+`
+
+// centerPageHTML wraps a dedication or epigraph page in a centered block. Blank lines are
+// collapsed so chapter splitting never separates the block from its contents.
+func centerPageHTML(pageHTML string) string {
+	inner := strings.ReplaceAll(strings.TrimSpace(pageHTML), "\n\n", "\n")
+	return "<div class=\"centered-page\">\n" + inner + "\n</div>"
+}
+
+// AddTitlePage adds a typographic title page with the title, author and publisher.
+// Call it before adding chapters so it lands at the front of the book.
+func (eg *EPUBGenerator) AddTitlePage() error {
+	cssPath, err := eg.frontMatterStylesheet()
+	if err != nil {
+		return err
+	}
+
+	body, err := eg.options.Templates.render(TitlePageTemplate, TitlePageData{
+		Title:     eg.epub.Title(),
+		Author:    eg.epub.Author(),
+		Publisher: eg.options.Publisher,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Untitled so it stays out of the table of contents
+	if _, err := eg.epub.AddSection(body, "", "titlepage.xhtml", cssPath); err != nil {
+		return fmt.Errorf("failed to add title page: %w", err)
+	}
+	eg.titlePage = true
+
+	return nil
+}
+
+// AddColophon adds a closing page describing where the book came from and how it
+// was converted. Call it after the last chapter.
+func (eg *EPUBGenerator) AddColophon(summary string, settings []string) error {
+	cssPath, err := eg.frontMatterStylesheet()
+	if err != nil {
+		return err
+	}
+
+	body, err := eg.options.Templates.render(ColophonTemplate, ColophonData{
+		Summary:  summary,
+		Settings: settings,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := eg.epub.AddSection(body, "", "colophon.xhtml", cssPath); err != nil {
+		return fmt.Errorf("failed to add colophon: %w", err)
+	}
+
+	return nil
+}
+
+// frontMatterStylesheet adds the title page/colophon stylesheet once and returns its internal path
+func (eg *EPUBGenerator) frontMatterStylesheet() (string, error) {
+	if eg.frontMatterCSS != "" {
+		return eg.frontMatterCSS, nil
+	}
+
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return "", err
+	}
+
+	cssFile := filepath.Join(tempDir, "frontmatter.css")
+	if err := os.WriteFile(cssFile, []byte(eg.stylesheet()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write front matter stylesheet: %w", err)
+	}
+
+	cssPath, err := eg.epub.AddCSS(cssFile, "frontmatter.css")
+	if err != nil {
+		return "", fmt.Errorf("failed to add front matter stylesheet: %w", err)
+	}
+	eg.frontMatterCSS = cssPath
+
+	return cssPath, nil
+}
+
+// stylesheet is the front matter stylesheet plus whatever the book's text turned out to need
+func (eg *EPUBGenerator) stylesheet() string {
+	css := frontMatterCSS
+	if eg.vertical {
+		css += verticalWritingCSS
+	}
+	if eg.glyphs != nil && eg.glyphs.font != nil && len(eg.glyphs.font.glyphs) > 0 {
+		css += fallbackFontCSS(eg.glyphs.font.name)
+	}
+	return css
+}
+
+// updateStylesheet rewrites the stylesheet once every chapter is in. go-epub only reads it
+// when the book is written, so it can still change until then.
+func (eg *EPUBGenerator) updateStylesheet() error {
+	if eg.frontMatterCSS == "" {
+		return nil
+	}
+
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "frontmatter.css"), []byte(eg.stylesheet()), 0644); err != nil {
+		return fmt.Errorf("failed to write front matter stylesheet: %w", err)
+	}
+	return nil
+}
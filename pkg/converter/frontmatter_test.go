@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestTitlePageAndColophon(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize: 12,
+		},
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{
+		Title:     "Romeo & Juliet",
+		Author:    "William Shakespeare",
+		Publisher: "Globe Press",
+	})
+	defer generator.Cleanup()
+
+	if err := generator.AddTitlePage(); err != nil {
+		t.Fatalf("Unexpected error adding title page: %v", err)
+	}
+	if err := generator.AddPage(PDFPage{Number: 1, Text: "Two households, both alike in dignity.", HasText: true}); err != nil {
+		t.Fatalf("Unexpected error adding page: %v", err)
+	}
+	if err := generator.AddColophon("This edition was converted from romeo.pdf.", []string{"Optimized for Test Reader"}); err != nil {
+		t.Fatalf("Unexpected error adding colophon: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "frontmatter.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	titlePage := files["EPUB/xhtml/titlepage.xhtml"]
+	for _, expected := range []string{`epub:type="titlepage"`, "Romeo &amp; Juliet", "William Shakespeare", "Globe Press", "frontmatter.css"} {
+		if !strings.Contains(titlePage, expected) {
+			t.Errorf("Expected title page to contain %q", expected)
+		}
+	}
+
+	colophon := files["EPUB/xhtml/colophon.xhtml"]
+	for _, expected := range []string{`epub:type="colophon"`, "romeo.pdf", "<li>Optimized for Test Reader</li>"} {
+		if !strings.Contains(colophon, expected) {
+			t.Errorf("Expected colophon to contain %q", expected)
+		}
+	}
+
+	opf := files[packagePath]
+	if !strings.Contains(opf, "<dc:publisher>Globe Press</dc:publisher>") {
+		t.Error("Expected publisher in package document")
+	}
+
+	// Title page first, colophon last, neither in the table of contents
+	spine := opf[strings.Index(opf, "<spine"):]
+	if strings.Index(spine, "titlepage.xhtml") > strings.Index(spine, "page0001.xhtml") ||
+		strings.Index(spine, "colophon.xhtml") < strings.Index(spine, "page0001.xhtml") {
+		t.Errorf("Unexpected spine order: %s", spine)
+	}
+	// The landmarks nav links to the title page, the table of contents mustn't
+	toc := files[navPath][strings.Index(files[navPath], `<nav epub:type="toc"`):]
+	toc = toc[:strings.Index(toc, "</nav>")]
+	if strings.Contains(toc, "titlepage.xhtml") || strings.Contains(toc, "colophon.xhtml") {
+		t.Error("Title page and colophon should not be in the table of contents")
+	}
+}
@@ -0,0 +1,308 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bmaupin/go-epub"
+	"golang.org/x/text/unicode/norm"
+)
+
+// fallbackFontClass is the class put on text drawn with the fallback font
+const fallbackFontClass = "fallback"
+
+// maxGlyphsShown is how many missing characters the summary lists unless verbose
+const maxGlyphsShown = 10
+
+// MissingGlyph is a character the reader's fonts can't draw and what became of it
+type MissingGlyph struct {
+	Char           rune
+	Count          int
+	Transliterated bool
+	Replacement    string // What it was transliterated to, which may be nothing (ъ)
+	Embedded       bool   // Drawn with the fallback font
+}
+
+// GlyphReport lists the characters the reader's fonts can't draw, most common first
+type GlyphReport []MissingGlyph
+
+// Count is how many characters the reader can't draw there were in all
+func (r GlyphReport) Count() int {
+	total := 0
+	for _, glyph := range r {
+		total += glyph.Count
+	}
+	return total
+}
+
+// Unresolved is how many of them are left as they were, to show up as boxes
+func (r GlyphReport) Unresolved() int {
+	total := 0
+	for _, glyph := range r {
+		if !glyph.Transliterated && !glyph.Embedded {
+			total += glyph.Count
+		}
+	}
+	return total
+}
+
+// glyphFallback finds characters the reader's fonts have no glyph for, which older readers
+// draw as empty boxes. They are drawn with an embedded fallback font if it has them,
+// transliterated if that's enabled, and counted either way.
+type glyphFallback struct {
+	canDisplay    func(rune) bool
+	font          *fallbackFont // nil = no fallback font
+	transliterate bool
+
+	missing map[rune]*MissingGlyph
+}
+
+func newGlyphFallback(canDisplay func(rune) bool, transliterate bool) *glyphFallback {
+	return &glyphFallback{
+		canDisplay:    canDisplay,
+		transliterate: transliterate,
+		missing:       make(map[rune]*MissingGlyph),
+	}
+}
+
+// apply handles the characters the reader can't draw in an XHTML fragment, leaving the
+// markup alone. It reports whether any text was put in the fallback font.
+func (g *glyphFallback) apply(content string) (string, bool) {
+	var out strings.Builder
+	inTag, inFallback, usedFont := false, false, false
+
+	for _, r := range content {
+		switch {
+		case inTag:
+			inTag = r != '>'
+		case r == '<':
+			inTag = true
+		case g.canDisplay(r):
+		default:
+			glyph := g.record(r)
+			if g.font != nil && g.font.has(r) {
+				if !inFallback {
+					out.WriteString(`<span class="` + fallbackFontClass + `">`)
+					inFallback, usedFont = true, true
+				}
+				glyph.Embedded = true
+				out.WriteRune(r)
+				continue
+			}
+			if replacement, ok := g.replacement(r); ok {
+				glyph.Transliterated, glyph.Replacement = true, replacement
+				if inFallback {
+					out.WriteString("</span>")
+					inFallback = false
+				}
+				out.WriteString(replacement)
+				continue
+			}
+		}
+
+		// Anything but a missing character ends a run in the fallback font, except for
+		// spaces between its words
+		if inFallback && !(r == ' ' && !inTag) {
+			out.WriteString("</span>")
+			inFallback = false
+		}
+		out.WriteRune(r)
+	}
+	if inFallback {
+		out.WriteString("</span>")
+	}
+
+	return out.String(), usedFont
+}
+
+// text handles the characters the reader can't draw in plain text, like a chapter title in
+// the table of contents, where the fallback font can't be used
+func (g *glyphFallback) text(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		if g.canDisplay(r) {
+			out.WriteRune(r)
+			continue
+		}
+		glyph := g.record(r)
+		if replacement, ok := g.replacement(r); ok {
+			glyph.Transliterated, glyph.Replacement = true, replacement
+			out.WriteString(replacement)
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func (g *glyphFallback) record(r rune) *MissingGlyph {
+	glyph, ok := g.missing[r]
+	if !ok {
+		glyph = &MissingGlyph{Char: r}
+		g.missing[r] = glyph
+	}
+	glyph.Count++
+	return glyph
+}
+
+func (g *glyphFallback) replacement(r rune) (string, bool) {
+	if !g.transliterate {
+		return "", false
+	}
+	return transliterateRune(r, g.canDisplay)
+}
+
+// report lists the missing characters, most common first
+func (g *glyphFallback) report() GlyphReport {
+	report := make(GlyphReport, 0, len(g.missing))
+	for _, glyph := range g.missing {
+		report = append(report, *glyph)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		return report[i].Char < report[j].Char
+	})
+	return report
+}
+
+// transliterations spell letters in Latin, and punctuation in ASCII. Capitals are looked up
+// by their small letter.
+var transliterations = map[rune]string{
+	// Punctuation
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '“': `"`, '”': `"`, '„': `"`, '‟': `"`,
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "--", '―': "--", '…': "...", '•': "*",
+	'′': "'", '″': `"`, '‹': "<", '›': ">", '\u200b': "", '\u200c': "", '\u200d': "",
+	'€': "EUR", '™': "(TM)", '№': "No.",
+
+	// Latin letters that don't decompose into a plain letter and an accent
+	'ł': "l", 'đ': "d", 'ħ': "h", 'ı': "i", 'ŋ': "ng", 'œ': "oe", 'ŧ': "t", 'ſ': "s",
+	'ĳ': "ij", 'ŀ': "l", 'ə': "e", 'ƒ': "f", 'ȷ': "j", 'ß': "ss", 'æ': "ae", 'ø': "o",
+	'þ': "th", 'ð': "d",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+
+	// Cyrillic, Russian and Ukrainian
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+}
+
+// transliterateRune spells a character with ones the reader can draw, if there's a way to
+func transliterateRune(r rune, canDisplay func(rune) bool) (string, bool) {
+	if replacement, ok := transliterations[r]; ok {
+		return replacement, true
+	}
+	if lower := unicode.ToLower(r); lower != r {
+		if replacement, ok := transliterations[lower]; ok {
+			return capitalize(replacement), true
+		}
+	}
+
+	// Accented letters lose their accents: ő becomes o, ά becomes α and then a
+	decomposed := norm.NFD.String(string(r))
+	if base, size := utf8.DecodeRuneInString(decomposed); size < len(decomposed) {
+		if canDisplay(base) {
+			return string(base), true
+		}
+		return transliterateRune(base, canDisplay)
+	}
+	return "", false
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// formatMissingGlyph shows a missing character and what became of it, e.g. "ł (U+0142) ×12 → l"
+func formatMissingGlyph(glyph MissingGlyph) string {
+	line := fmt.Sprintf("%c (U+%04X) ×%d", glyph.Char, glyph.Char, glyph.Count)
+	switch {
+	case glyph.Embedded:
+		line += " → fallback font"
+	case glyph.Transliterated && glyph.Replacement == "":
+		line += " → removed"
+	case glyph.Transliterated:
+		line += " → " + glyph.Replacement
+	}
+	return line
+}
+
+// SetGlyphFallback turns on the pass for characters the reader's fonts can't draw. They
+// are drawn with the font at fontPath if it has them ("" = no fallback font), otherwise
+// transliterated if that's asked for, and listed in GlyphReport either way.
+func (eg *EPUBGenerator) SetGlyphFallback(fontPath string, transliterate bool) error {
+	glyphs := newGlyphFallback(eg.profile.Capabilities.CanDisplay, transliterate)
+	if fontPath != "" {
+		font, err := loadFallbackFont(fontPath)
+		if err != nil {
+			return err
+		}
+		glyphs.font = font
+	}
+	eg.glyphs = glyphs
+	return nil
+}
+
+// GlyphReport lists the characters the reader's fonts couldn't draw, if the pass is on
+func (eg *EPUBGenerator) GlyphReport() GlyphReport {
+	if eg.glyphs == nil {
+		return nil
+	}
+	return eg.glyphs.report()
+}
+
+// addFallbackFont embeds the fallback font, cut down to the characters the book used it
+// for. Nothing is embedded if the font wasn't needed; its font face is added to the
+// stylesheet when that's written.
+func (eg *EPUBGenerator) addFallbackFont() error {
+	if eg.glyphs == nil || eg.glyphs.font == nil || len(eg.glyphs.font.glyphs) == 0 {
+		return nil
+	}
+	font := eg.glyphs.font
+
+	data, err := font.subset()
+	if err != nil {
+		return fmt.Errorf("failed to subset fallback font: %w", err)
+	}
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return err
+	}
+	fontFile := filepath.Join(tempDir, font.name)
+	if err := os.WriteFile(fontFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write fallback font: %w", err)
+	}
+	if _, err := eg.epub.AddFont(fontFile, font.name); err != nil {
+		return fmt.Errorf("failed to add fallback font: %w", err)
+	}
+	return nil
+}
+
+// fallbackFontCSS declares the fallback font and the class that draws text with it
+func fallbackFontCSS(name string) string {
+	return fmt.Sprintf(`@font-face {
+  font-family: "Publify Fallback";
+  src: url("../%s/%s");
+}
+.%s {
+  font-family: "Publify Fallback", serif;
+}
+`, epub.FontFolderName, name, fallbackFontClass)
+}
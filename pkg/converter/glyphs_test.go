@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// asciiOnly stands in for a reader whose fonts only have ASCII
+func asciiOnly(r rune) bool {
+	return r < 0x80
+}
+
+func TestTransliterateRune(t *testing.T) {
+	tests := []struct {
+		char     rune
+		expected string
+		ok       bool
+	}{
+		{'ł', "l", true},
+		{'é', "e", true},
+		{'Ő', "O", true},
+		{'Ж', "Zh", true},
+		{'ъ', "", true},
+		{'ά', "a", true}, // Via α
+		{'—', "--", true},
+		{'☃', "", false},
+	}
+
+	for _, tt := range tests {
+		replacement, ok := transliterateRune(tt.char, asciiOnly)
+		if replacement != tt.expected || ok != tt.ok {
+			t.Errorf("transliterateRune(%q) = %q, %v, expected %q, %v", tt.char, replacement, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestGlyphFallbackApply(t *testing.T) {
+	glyphs := newGlyphFallback(asciiOnly, true)
+
+	result, usedFont := glyphs.apply(`<p title="Ωmega">Ωmega – ŋ ☃ café café</p>`)
+	if expected := `<p title="Ωmega">Omega - ng ☃ cafe cafe</p>`; result != expected {
+		t.Errorf("apply() = %q, expected %q", result, expected)
+	}
+	if usedFont {
+		t.Error("Expected no fallback font use without a font")
+	}
+
+	if title := glyphs.text("Ἀθῆναι"); title != "Athinai" {
+		t.Errorf("text() = %q, expected Athinai", title)
+	}
+
+	report := glyphs.report()
+	if len(report) != 11 || report[0].Char != 'é' || report[0].Count != 2 {
+		t.Errorf("Report = %+v, expected é first of 11", report)
+	}
+	if report.Unresolved() != 1 {
+		t.Errorf("Unresolved() = %d, expected only the snowman", report.Unresolved())
+	}
+	if report.Count() != 12 {
+		t.Errorf("Count() = %d, expected 12", report.Count())
+	}
+}
+
+func TestGlyphFallbackWithoutTransliteration(t *testing.T) {
+	glyphs := newGlyphFallback(asciiOnly, false)
+
+	if result, _ := glyphs.apply("<p>łódź</p>"); result != "<p>łódź</p>" {
+		t.Errorf("Expected the text left alone, got %q", result)
+	}
+	if unresolved := glyphs.report().Unresolved(); unresolved != 3 {
+		t.Errorf("Unresolved() = %d, expected 3", unresolved)
+	}
+}
+
+func TestFormatMissingGlyph(t *testing.T) {
+	tests := []struct {
+		glyph    MissingGlyph
+		expected string
+	}{
+		{MissingGlyph{Char: 'ł', Count: 12, Transliterated: true, Replacement: "l"}, "ł (U+0142) ×12 → l"},
+		{MissingGlyph{Char: 'ъ', Count: 3, Transliterated: true}, "ъ (U+044A) ×3 → removed"},
+		{MissingGlyph{Char: 'א', Count: 1, Embedded: true}, "א (U+05D0) ×1 → fallback font"},
+		{MissingGlyph{Char: '☃', Count: 2}, "☃ (U+2603) ×2"},
+	}
+
+	for _, tt := range tests {
+		if line := formatMissingGlyph(tt.glyph); line != tt.expected {
+			t.Errorf("formatMissingGlyph(%q) = %q, expected %q", tt.glyph.Char, line, tt.expected)
+		}
+	}
+}
+
+// fontTableData finds a table in an sfnt font file
+func fontTableData(t *testing.T, data []byte, tag string) []byte {
+	t.Helper()
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := range numTables {
+		record := data[12+16*i:]
+		if string(record[:4]) == tag {
+			offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+			return data[offset : offset+length]
+		}
+	}
+	t.Fatalf("Font has no %s table", tag)
+	return nil
+}
+
+func TestSubsetTrueType(t *testing.T) {
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[50:], 1) // Long loca offsets
+
+	simple := func(size int) []byte {
+		glyph := make([]byte, size)
+		binary.BigEndian.PutUint16(glyph, 1) // One contour
+		return glyph
+	}
+	composite := make([]byte, 16)
+	binary.BigEndian.PutUint16(composite, 0xffff) // Composite
+	binary.BigEndian.PutUint16(composite[12:], 1) // Built from glyph 1
+
+	// .notdef, the glyph the composite uses, the composite and one nobody uses
+	var glyf []byte
+	offsets := []int{0}
+	for _, glyph := range [][]byte{simple(12), simple(12), composite, simple(12)} {
+		glyf = append(glyf, glyph...)
+		offsets = append(offsets, len(glyf))
+	}
+
+	font := writeSfnt([]byte{0, 1, 0, 0}, []fontTable{
+		{tag: "head", data: head},
+		{tag: "loca", data: writeLoca(offsets, true)},
+		{tag: "glyf", data: glyf},
+	})
+
+	subset, err := subsetTrueType(font, map[uint16]bool{2: true})
+	if err != nil {
+		t.Fatalf("subsetTrueType() failed: %v", err)
+	}
+
+	newOffsets, err := readLoca(fontTableData(t, subset, "loca"), true, len(fontTableData(t, subset, "glyf")))
+	if err != nil {
+		t.Fatalf("Subset has unreadable glyph locations: %v", err)
+	}
+	expected := []int{0, 12, 24, 40, 40}
+	for i := range expected {
+		if newOffsets[i] != expected[i] {
+			t.Fatalf("Glyph offsets = %v, expected %v", newOffsets, expected)
+		}
+	}
+
+	if sum := fontChecksum(subset); sum != 0xb1b0afba {
+		t.Errorf("Font checksum = %#x, expected 0xb1b0afba", sum)
+	}
+}
@@ -5,13 +5,33 @@ import (
 	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/alde/publify/internal/logging"
 	"github.com/alde/publify/pkg/reader"
-	"github.com/chai2010/webp"
+	"github.com/bmaupin/go-epub"
 	"github.com/disintegration/imaging"
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/jpegxl"
+	"github.com/gen2brain/webp"
+)
+
+// ImageFormats are the formats images can be stored in
+var ImageFormats = []string{"jpeg", "png", "webp", "avif", "jxl"}
+
+// modernFormats compress far better than JPEG, and are used whenever the reader takes one
+var modernFormats = map[string]bool{"webp": true, "avif": true, "jxl": true}
+
+const (
+	// avifSpeed trades encoding time for size, 0 (slowest) to 10. Below 6 a book of scans
+	// takes minutes longer for a few percent.
+	avifSpeed = 6
+	// jxlEffort is the same for JPEG XL, 1 (fastest) to 10
+	jxlEffort = 7
 )
 
 // ImageProcessor handles image optimization for e-readers
@@ -36,19 +56,38 @@ func (ip *ImageProcessor) ProcessImage(inputPath string) (string, error) {
 		return "", fmt.Errorf("failed to open image: %w", err)
 	}
 
+	return ip.ProcessDecodedImage(img, inputPath)
+}
+
+// ProcessDecodedImage optimizes an in-memory image (e.g. one extracted from a PDF).
+// The name is only used to derive the output filename.
+func (ip *ImageProcessor) ProcessDecodedImage(img image.Image, name string) (string, error) {
+	inputPath := name
+
 	// Get optimal processing settings
 	settings := ip.profile.ImageProcessingSettings()
 
-	// Resize if needed
-	img = ip.resizeImage(img, settings)
+	// Resize if needed, sharpening afterwards since downscaling softens edges
+	resized := ip.resizeImage(img, settings)
+	if resized.Bounds() != img.Bounds() && settings.Sharpen > 0 {
+		resized = unsharpMask(resized, settings.SharpenSigma, settings.Sharpen)
+	}
+	img = resized
 
-	// Convert to grayscale if needed
+	// Convert to grayscale if needed, tuned for e-ink if asked
 	if settings.Grayscale {
-		img = imaging.Grayscale(img)
+		gray := imaging.Grayscale(img)
+		if einkTuned(settings) {
+			tuneForEInk(gray, settings)
+		}
+		img = gray
 	}
 
-	// Determine output format
+	// Determine output format. Lossy compression would smear a dither pattern into noise.
 	outputFormat := ip.selectOptimalFormat(settings)
+	if settings.Grayscale && settings.Dither {
+		outputFormat = "png"
+	}
 
 	// Generate output filename
 	outputPath := ip.generateOutputPath(inputPath, outputFormat)
@@ -58,6 +97,34 @@ func (ip *ImageProcessor) ProcessImage(inputPath string) (string, error) {
 		return "", fmt.Errorf("failed to save optimized image: %w", err)
 	}
 
+	logging.Module("image").Debug("Optimized image", "name", filepath.Base(name), "format", outputFormat,
+		"width", img.Bounds().Dx(), "height", img.Bounds().Dy(), "grayscale", settings.Grayscale, "dither", settings.Dither)
+	return outputPath, nil
+}
+
+// ProcessTextImage turns a scanned page of text into a 1-bit PNG. Scaling happens in
+// grayscale first so letters keep their shape, then everything is snapped to black or white.
+func (ip *ImageProcessor) ProcessTextImage(gray *image.Gray, name string) (string, error) {
+	settings := ip.profile.ImageProcessingSettings()
+	resized := imaging.Grayscale(ip.resizeImage(gray, settings))
+
+	bounds := resized.Bounds()
+	scaled := image.NewGray(bounds)
+	for i := 0; i < len(scaled.Pix); i++ {
+		scaled.Pix[i] = resized.Pix[i*4]
+	}
+
+	outputPath := ip.generateOutputPath(name, "png")
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	if err := ip.saveAsPNG(binarize(scaled), outFile, settings); err != nil {
+		return "", fmt.Errorf("failed to save text image: %w", err)
+	}
+
 	return outputPath, nil
 }
 
@@ -90,12 +157,47 @@ func (ip *ImageProcessor) resizeImage(img image.Image, settings reader.ImageSett
 	return imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
 }
 
-// selectOptimalFormat chooses the best image format for the reader
+// sharpenThreshold keeps the unsharp mask from amplifying flat areas (paper texture, JPEG noise)
+const sharpenThreshold = 3
+
+// unsharpMask sharpens an image by adding back amount times the difference from a blurred copy
+func unsharpMask(img image.Image, sigma, amount float64) image.Image {
+	src := imaging.Clone(img)
+	blurred := imaging.Blur(src, sigma)
+
+	out := image.NewNRGBA(src.Bounds())
+	for i := 0; i < len(src.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			original := float64(src.Pix[i+c])
+			diff := original - float64(blurred.Pix[i+c])
+			if diff > -sharpenThreshold && diff < sharpenThreshold {
+				out.Pix[i+c] = src.Pix[i+c]
+				continue
+			}
+			out.Pix[i+c] = clampByte(original + amount*diff)
+		}
+		out.Pix[i+3] = src.Pix[i+3]
+	}
+
+	return out
+}
+
+func clampByte(v float64) uint8 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// selectOptimalFormat chooses the best image format for the reader: the first modern
+// format it supports, in its order of preference
 func (ip *ImageProcessor) selectOptimalFormat(settings reader.ImageSettings) string {
-	// Check if reader supports WebP (best compression)
 	for _, format := range ip.profile.Capabilities.SupportedImageFormats {
-		if format == "webp" {
-			return "webp"
+		if modernFormats[format] {
+			return format
 		}
 	}
 
@@ -112,6 +214,10 @@ func (ip *ImageProcessor) generateOutputPath(inputPath, format string) string {
 	switch format {
 	case "webp":
 		ext = ".webp"
+	case "avif":
+		ext = ".avif"
+	case "jxl":
+		ext = ".jxl"
 	case "png":
 		ext = ".png"
 	default:
@@ -130,20 +236,31 @@ func (ip *ImageProcessor) saveImage(img image.Image, outputPath, format string,
 	}
 	defer outFile.Close()
 
+	return ip.encode(outFile, img, format, settings)
+}
+
+// encode writes an image in the specified format
+func (ip *ImageProcessor) encode(w io.Writer, img image.Image, format string, settings reader.ImageSettings) error {
 	switch format {
 	case "webp":
-		return ip.saveAsWebP(img, outFile, settings)
+		return ip.saveAsWebP(img, w, settings)
+
+	case "avif":
+		return ip.saveAsAVIF(img, w, settings)
+
+	case "jxl":
+		return ip.saveAsJXL(img, w, settings)
 
 	case "png":
-		return ip.saveAsPNG(img, outFile, settings)
+		return ip.saveAsPNG(img, w, settings)
 
 	default: // JPEG
-		return ip.saveAsJPEG(img, outFile, settings)
+		return ip.saveAsJPEG(img, w, settings)
 	}
 }
 
 // saveAsJPEG saves an image as JPEG with specified quality
-func (ip *ImageProcessor) saveAsJPEG(img image.Image, file *os.File, settings reader.ImageSettings) error {
+func (ip *ImageProcessor) saveAsJPEG(img image.Image, w io.Writer, settings reader.ImageSettings) error {
 	quality := settings.Quality
 
 	// Adjust quality based on compression level
@@ -157,12 +274,12 @@ func (ip *ImageProcessor) saveAsJPEG(img image.Image, file *os.File, settings re
 	}
 
 	options := &jpeg.Options{Quality: quality}
-	return jpeg.Encode(file, img, options)
+	return jpeg.Encode(w, img, options)
 }
 
 // saveAsWebP saves an image as WebP with high compression
-func (ip *ImageProcessor) saveAsWebP(img image.Image, file *os.File, settings reader.ImageSettings) error {
-	quality := float32(settings.Quality)
+func (ip *ImageProcessor) saveAsWebP(img image.Image, w io.Writer, settings reader.ImageSettings) error {
+	quality := settings.Quality
 
 	// Adjust quality for WebP - it's more efficient so we can use higher values
 	if ip.profile.Capabilities.AggressiveCompression {
@@ -177,20 +294,75 @@ func (ip *ImageProcessor) saveAsWebP(img image.Image, file *os.File, settings re
 	}
 
 	// WebP quality is 0-100, same as JPEG
-	options := &webp.Options{
-		Lossless: false,
-		Quality:  quality,
+	return webp.Encode(w, img, webp.Options{
+		Quality: quality,
+		Method:  webp.DefaultMethod,
+	})
+}
+
+// saveAsAVIF saves an image as AVIF, which needs a lower quality than JPEG for the same look
+func (ip *ImageProcessor) saveAsAVIF(img image.Image, w io.Writer, settings reader.ImageSettings) error {
+	quality := clampQuality(settings.Quality - 25) // 60 looks about like JPEG at 85
+
+	if ip.profile.Capabilities.AggressiveCompression {
+		switch settings.CompressionLevel {
+		case "high":
+			quality = 45
+		case "medium":
+			quality = 55
+		default:
+			quality = 65
+		}
 	}
 
-	return webp.Encode(file, img, options)
+	return avif.Encode(w, img, avif.Options{
+		Quality:      quality,
+		QualityAlpha: quality,
+		Speed:        avifSpeed,
+	})
+}
+
+// saveAsJXL saves an image as JPEG XL, whose quality scale follows JPEG's (100 = lossless)
+func (ip *ImageProcessor) saveAsJXL(img image.Image, w io.Writer, settings reader.ImageSettings) error {
+	quality := clampQuality(settings.Quality)
+
+	if ip.profile.Capabilities.AggressiveCompression {
+		switch settings.CompressionLevel {
+		case "high":
+			quality = min(quality, 70)
+		case "medium":
+			quality = min(quality, 80)
+		}
+	}
+
+	return jpegxl.Encode(w, img, jpegxl.Options{
+		Quality: quality,
+		Effort:  jxlEffort,
+	})
+}
+
+// clampQuality keeps an encoder quality within 1-100
+func clampQuality(quality int) int {
+	return max(1, min(quality, 100))
 }
 
 // saveAsPNG saves an image as PNG
-func (ip *ImageProcessor) saveAsPNG(img image.Image, file *os.File, settings reader.ImageSettings) error {
+func (ip *ImageProcessor) saveAsPNG(img image.Image, w io.Writer, settings reader.ImageSettings) error {
 	encoder := &png.Encoder{
 		CompressionLevel: png.BestCompression, // Always use best compression for file size
 	}
-	return encoder.Encode(file, img)
+	return encoder.Encode(w, img)
+}
+
+var imageItemPattern = regexp.MustCompile(`<item [^>]*href="` + epub.ImageFolderName + `/[^"]+\.(avif|jxl)"[^>]*>`)
+
+// setImageMediaTypes gives AVIF and JPEG XL manifest items their media types. go-epub
+// sniffs them from the file and doesn't know either format.
+func setImageMediaTypes(content []byte) []byte {
+	return imageItemPattern.ReplaceAllFunc(content, func(item []byte) []byte {
+		mediaType := "image/" + string(imageItemPattern.FindSubmatch(item)[1])
+		return mediaTypeAttr.ReplaceAll(item, []byte(`media-type="`+mediaType+`"`))
+	})
 }
 
 // GetOptimizedSize estimates the size reduction from optimization
@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+	"github.com/disintegration/imaging"
+)
+
+func TestUnsharpMask(t *testing.T) {
+	// A soft vertical edge: dark on the left, light on the right, with a gray ramp between
+	img := image.NewGray(image.Rect(0, 0, 20, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 20; x++ {
+			value := uint8(60)
+			switch {
+			case x >= 12:
+				value = 200
+			case x >= 8:
+				value = uint8(60 + (x-7)*28)
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	sharpened := unsharpMask(img, 1.0, 1.0)
+
+	luma := func(x int) uint8 {
+		return color.GrayModel.Convert(sharpened.At(x, 2)).(color.Gray).Y
+	}
+
+	// The edge gets more contrast on both sides...
+	if luma(7) >= 60 {
+		t.Errorf("Expected dark side of edge to get darker, got %d", luma(7))
+	}
+	if luma(12) <= 200 {
+		t.Errorf("Expected light side of edge to get lighter, got %d", luma(12))
+	}
+
+	// ...while flat areas are left alone
+	if luma(0) != 60 || luma(19) != 200 {
+		t.Errorf("Expected flat areas unchanged, got %d and %d", luma(0), luma(19))
+	}
+}
+
+// halftoneImage prints a smooth left-to-right gradient as a dot screen with the given period in pixels
+func halftoneImage(width, height int, period float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			tone := 0.35 + 0.3*float64(x)/float64(width)
+			screen := (math.Cos(2*math.Pi*float64(x)/period)*math.Cos(2*math.Pi*float64(y)/period) + 1) / 2
+			value := uint8(255)
+			if screen < tone {
+				value = 0
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+func TestDescreenRemovesHalftone(t *testing.T) {
+	img := halftoneImage(128, 128, 2.7)
+
+	// Mean difference between horizontal neighbours: high for dots, low for continuous tone
+	roughness := func(img image.Image) float64 {
+		gray := imaging.Grayscale(img)
+		var total float64
+		for y := 0; y < 128; y++ {
+			for x := 0; x < 127; x++ {
+				total += math.Abs(float64(gray.Pix[y*gray.Stride+x*4]) - float64(gray.Pix[y*gray.Stride+(x+1)*4]))
+			}
+		}
+		return total / (128 * 127)
+	}
+
+	before, after := roughness(img), roughness(descreen(img))
+	if after > before/4 {
+		t.Errorf("Expected dot pattern to be smoothed out, roughness went from %.1f to %.1f", before, after)
+	}
+}
+
+func TestDescreenKeepsLineArt(t *testing.T) {
+	// Black 3px strokes on white, like text or an engraving
+	img := image.NewGray(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			value := uint8(255)
+			if (x/3)%4 == 0 {
+				value = 0
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	if result := descreen(img); result != image.Image(img) {
+		t.Error("Expected line art to be left untouched")
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	// Gray ink on yellowed paper, the way scans come out
+	page := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			value := uint8(175)
+			if y%10 < 2 && x%5 < 3 {
+				value = 60
+			}
+			page.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	if !looksLikeText(page) {
+		t.Error("Expected ink on paper to look like text")
+	}
+
+	// A smooth gradient is all midtones
+	photo := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			photo.SetGray(x, y, color.Gray{Y: uint8(x * 255 / 100)})
+		}
+	}
+	if looksLikeText(photo) {
+		t.Error("Expected a gradient not to look like text")
+	}
+}
+
+func TestBinarize(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 4, 1))
+	for x, value := range []uint8{40, 70, 180, 210} {
+		gray.SetGray(x, 0, color.Gray{Y: value})
+	}
+
+	result := binarize(gray)
+
+	if len(result.Palette) != 2 {
+		t.Fatalf("Expected a two-color palette, got %d colors", len(result.Palette))
+	}
+	for x, want := range []uint8{0, 0, 1, 1} {
+		if got := result.ColorIndexAt(x, 0); got != want {
+			t.Errorf("Pixel %d: expected index %d, got %d", x, want, got)
+		}
+	}
+}
+
+func TestToneTable(t *testing.T) {
+	identity := toneTable(0, 0, 0)
+	for v := range identity {
+		if identity[v] != uint8(v) {
+			t.Fatalf("Expected no change without settings, got %d for %d", identity[v], v)
+		}
+	}
+
+	clipped := toneTable(0, 0, 200)
+	if clipped[200] != 255 || clipped[230] != 255 || clipped[100] != 128 {
+		t.Errorf("Expected paper tone white and the rest stretched, got %d, %d, %d", clipped[200], clipped[230], clipped[100])
+	}
+
+	lightened := toneTable(2, 0, 0)
+	if lightened[64] <= 64 || lightened[0] != 0 || lightened[255] != 255 {
+		t.Errorf("Expected gamma 2 to lighten midtones only, got %d, %d, %d", lightened[64], lightened[0], lightened[255])
+	}
+
+	contrasty := toneTable(0, 50, 0)
+	if contrasty[64] >= 64 || contrasty[192] <= 192 {
+		t.Errorf("Expected more contrast to spread the tones, got %d and %d", contrasty[64], contrasty[192])
+	}
+}
+
+func TestDitherFloydSteinberg(t *testing.T) {
+	img := imaging.New(40, 40, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+	ditherFloydSteinberg(img, 2)
+
+	var sum, black int
+	for i := 0; i < len(img.Pix); i += 4 {
+		switch img.Pix[i] {
+		case 0:
+			black++
+		case 255:
+		default:
+			t.Fatalf("Expected only black and white with 2 levels, got %d", img.Pix[i])
+		}
+		sum += int(img.Pix[i])
+	}
+
+	// The pattern keeps the tone: 100 is about 61% black
+	if mean := sum / (40 * 40); mean < 90 || mean > 110 {
+		t.Errorf("Expected the dithered image to average about 100, got %d", mean)
+	}
+	if black == 0 || black == 40*40 {
+		t.Error("Expected a mix of black and white")
+	}
+}
+
+func TestSelectOptimalFormat(t *testing.T) {
+	for _, tt := range []struct {
+		supported []string
+		expected  string
+	}{
+		{[]string{"webp", "jpeg", "png"}, "webp"},
+		{[]string{"jpeg", "avif", "webp"}, "avif"},
+		{[]string{"jxl"}, "jxl"},
+		{[]string{"jpeg", "png"}, "jpeg"},
+	} {
+		profile := reader.Profile{Capabilities: reader.DeviceCapabilities{
+			SupportedImageFormats: tt.supported,
+			PreferredImageFormat:  "jpeg",
+		}}
+		processor := NewImageProcessor(profile, "")
+		if format := processor.selectOptimalFormat(profile.ImageProcessingSettings()); format != tt.expected {
+			t.Errorf("%v: expected %s, got %s", tt.supported, tt.expected, format)
+		}
+	}
+}
+
+func TestSetImageMediaTypes(t *testing.T) {
+	content := []byte(`<manifest>
+    <item id="a.avif" href="images/a.avif" media-type="application/octet-stream"></item>
+    <item id="b.jxl" href="images/b.jxl" media-type="application/octet-stream"></item>
+    <item id="c.jpg" href="images/c.jpg" media-type="image/jpeg"></item>
+  </manifest>`)
+	result := string(setImageMediaTypes(content))
+	for _, expected := range []string{
+		`href="images/a.avif" media-type="image/avif"`,
+		`href="images/b.jxl" media-type="image/jxl"`,
+		`href="images/c.jpg" media-type="image/jpeg"`,
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %s in %s", expected, result)
+		}
+	}
+}
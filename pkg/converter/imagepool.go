@@ -0,0 +1,241 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/alde/publify/internal/worker"
+	"github.com/dustin/go-humanize"
+)
+
+// minParallelImages is how many pages with images a book needs before they're optimized
+// ahead on every core. Below it starting the workers isn't worth it, and each image is
+// optimized as its page is added.
+const minParallelImages = 4
+
+// optimizedImage is an image optimized for the reader, ready to be added to the book
+type optimizedImage struct {
+	path     string // The optimized file
+	text     bool   // A page of plain text, stored as 1-bit
+	original int64  // Bytes it came to as rendered or extracted from the PDF (0 = not known)
+	size     int64  // Bytes it comes to optimized
+}
+
+// newOptimizedImage measures an optimized image file
+func newOptimizedImage(path string, text bool, original int64) (optimizedImage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return optimizedImage{}, fmt.Errorf("failed to measure optimized image: %w", err)
+	}
+	return optimizedImage{path: path, text: text, original: original, size: info.Size()}, nil
+}
+
+// pageImageID names a rendered page's image, or one part of it for a spread that's split
+func pageImageID(pageNum, part, parts int) string {
+	id := fmt.Sprintf("page%04d", pageNum)
+	if parts > 1 {
+		id += fmt.Sprintf("-%d", part+1)
+	}
+	return id
+}
+
+// figureName names a page's figure, as optimized
+func figureName(pageNum, figure int) string {
+	return fmt.Sprintf("page%04d_figure%d.png", pageNum, figure+1)
+}
+
+// optimizePageImage optimizes an image from a rendered page: as 1-bit if it's plain text
+// and text rendering is on, with the owner written on it if asked
+func (eg *EPUBGenerator) optimizePageImage(processor *ImageProcessor, img image.Image, name string, original int64) (optimizedImage, error) {
+	if gray := eg.textPage(img); gray != nil {
+		// A grayscale page is drawn on as it is
+		if _, err := eg.markOwner(gray, ownerMarkTextShade); err != nil {
+			return optimizedImage{}, err
+		}
+		path, err := processor.ProcessTextImage(gray, name)
+		if err != nil {
+			return optimizedImage{}, err
+		}
+		return newOptimizedImage(path, true, original)
+	}
+
+	img, err := eg.markOwner(img, ownerMarkShade)
+	if err != nil {
+		return optimizedImage{}, err
+	}
+	path, err := processor.ProcessDecodedImage(img, name)
+	if err != nil {
+		return optimizedImage{}, err
+	}
+	return newOptimizedImage(path, false, original)
+}
+
+// optimizePage optimizes all of a page's images, by name: the rendered page for an image
+// page, in two if it's a spread to be split, and otherwise its figures
+func (eg *EPUBGenerator) optimizePage(processor *ImageProcessor, page PDFPage) (map[string]optimizedImage, error) {
+	optimized := make(map[string]optimizedImage)
+
+	if len(page.ImageData) > 0 {
+		img, _, err := image.Decode(bytes.NewReader(page.ImageData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode page image: %w", err)
+		}
+		parts := eg.pageParts(img)
+		for i, part := range parts {
+			name := pageImageID(page.Number, i, len(parts)) + ".png"
+			optimizedPart, err := eg.optimizePageImage(processor, part, name, int64(len(page.ImageData)/len(parts)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to optimize page image: %w", err)
+			}
+			optimized[name] = optimizedPart
+		}
+		return optimized, nil
+	}
+
+	for i, figure := range page.Images {
+		name := figureName(page.Number, i)
+		path, err := processor.ProcessDecodedImage(figure.Image, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to optimize figure %d: %w", i+1, err)
+		}
+		if optimized[name], err = newOptimizedImage(path, false, figure.size); err != nil {
+			return nil, err
+		}
+	}
+	return optimized, nil
+}
+
+// optimizeImages optimizes the pages' images ahead of adding them, on workers goroutines,
+// so a book of scans isn't encoded one page after the other. Pages added afterwards pick
+// their images up from here.
+func (eg *EPUBGenerator) optimizeImages(pages []PDFPage, workers int) error {
+	var withImages []PDFPage
+	for _, page := range pages {
+		if page.payload != nil || len(page.ImageData) > 0 || len(page.Images) > 0 {
+			withImages = append(withImages, page)
+		}
+	}
+	if len(withImages) < minParallelImages || workers < 2 {
+		return nil
+	}
+
+	// Created before the workers start, so they don't race to make the temp directory
+	processor, err := eg.imageProcessor()
+	if err != nil {
+		return err
+	}
+
+	pool := worker.NewPool(min(workers, len(withImages)))
+	pool.Start()
+	defer pool.Stop()
+
+	// Cancelled on the first failure, so the jobs still queued skip their page
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := pool.Results()
+	optimizedPages := make(chan imageOptimizationResult, len(withImages))
+	go func() {
+		for _, page := range withImages {
+			pool.Submit(&imageOptimizationJob{
+				ctx:        ctx,
+				generator:  eg,
+				processor:  processor,
+				page:       page,
+				resultChan: optimizedPages,
+			})
+		}
+	}()
+
+	// Collect every result, even after a failure, so no worker is left blocked on a send
+	optimized := make(map[string]optimizedImage)
+	var firstErr error
+	completedJobs, receivedPages := 0, 0
+	for completedJobs < len(withImages) || receivedPages < len(withImages) {
+		select {
+		case <-results:
+			completedJobs++
+		case result := <-optimizedPages:
+			receivedPages++
+			if result.Error != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("page %d: %w", result.PageNum, result.Error)
+				}
+				cancel()
+				continue
+			}
+			for name, img := range result.Images {
+				optimized[name] = img
+			}
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	eg.optimized = optimized
+	return nil
+}
+
+// countImage adds an image going into the book to the optimization statistics
+func (eg *EPUBGenerator) countImage(img optimizedImage) {
+	eg.originalSizes = append(eg.originalSizes, img.original)
+	eg.optimizedSizes = append(eg.optimizedSizes, img.size)
+}
+
+// ImageStats returns what optimizing the book's figures and page images came to
+func (eg *EPUBGenerator) ImageStats() ImageStats {
+	return NewImageProcessor(eg.profile, eg.tempDir).CalculateImageStats(eg.originalSizes, eg.optimizedSizes)
+}
+
+// imageOptimizationResult holds the optimized images of a single page
+type imageOptimizationResult struct {
+	PageNum int
+	Images  map[string]optimizedImage
+	Error   error
+}
+
+// imageOptimizationJob implements the worker.Job interface for optimizing a page's images
+type imageOptimizationJob struct {
+	ctx        context.Context // Cancelled once another page has failed
+	generator  *EPUBGenerator
+	processor  *ImageProcessor
+	page       PDFPage
+	resultChan chan<- imageOptimizationResult
+}
+
+func (j *imageOptimizationJob) ID() string {
+	return fmt.Sprintf("images-%d", j.page.Number)
+}
+
+func (j *imageOptimizationJob) Process(ctx context.Context) error {
+	if err := j.ctx.Err(); err != nil {
+		j.resultChan <- imageOptimizationResult{PageNum: j.page.Number, Error: err}
+		return err
+	}
+
+	page, err := j.page.withPayload()
+	var images map[string]optimizedImage
+	if err == nil {
+		images, err = j.generator.optimizePage(j.processor, page)
+	}
+
+	j.resultChan <- imageOptimizationResult{
+		PageNum: j.page.Number,
+		Images:  images,
+		Error:   err,
+	}
+	return err
+}
+
+// formatImageStats describes what optimizing the images came to, as in ", optimized from
+// 84 MB to 12 MB", or "" if what they came from isn't known
+func formatImageStats(stats ImageStats) string {
+	if stats.OriginalSize == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", optimized from %s to %s", humanize.Bytes(uint64(stats.OriginalSize)), humanize.Bytes(uint64(stats.OptimizedSize)))
+}
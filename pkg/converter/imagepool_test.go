@@ -0,0 +1,73 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestOptimizeImages(t *testing.T) {
+	var buf bytes.Buffer
+	scan := image.NewRGBA(image.Rect(0, 0, 300, 400))
+	for i := range scan.Pix {
+		scan.Pix[i] = uint8(i * 7)
+	}
+	if err := png.Encode(&buf, scan); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	figure := image.NewGray(image.Rect(0, 0, 80, 60))
+	figure.SetGray(10, 10, color.Gray{Y: 200})
+
+	var pages []PDFPage
+	for number := 1; number <= 6; number++ {
+		pages = append(pages, PDFPage{Number: number, PageType: PageTypeImage, HasImage: true, ImageData: buf.Bytes()})
+	}
+	pages = append(pages, PDFPage{
+		Number:  7,
+		Text:    strings.Repeat("The plate opposite shows the harbour. ", 5),
+		HasText: true,
+		Images:  []PageImage{{Image: figure, Position: 0.5}},
+	})
+
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{Title: "Harbours"})
+	defer generator.Cleanup()
+
+	if err := generator.optimizeImages(pages, 4); err != nil {
+		t.Fatalf("Unexpected error optimizing images: %v", err)
+	}
+	if len(generator.optimized) != 7 {
+		t.Fatalf("Expected the 6 page images and the figure optimized ahead, got %d", len(generator.optimized))
+	}
+	if _, ok := generator.optimized["page0007_figure1.png"]; !ok {
+		t.Error("Expected the figure optimized ahead")
+	}
+
+	if err := generator.AddChapter("Chapter 1", pages); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+	stats := generator.ImageStats()
+	if stats.ProcessedImages != 7 || generator.ImageCount() != 7 {
+		t.Errorf("Expected 7 images added, got %d (%d counted)", stats.ProcessedImages, generator.ImageCount())
+	}
+	if stats.OriginalSize != 6*int64(buf.Len()) {
+		t.Errorf("Expected the page images' rendered size counted, got %d", stats.OriginalSize)
+	}
+}
+
+func TestOptimizeImagesFewPages(t *testing.T) {
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{Title: "Pamphlet"})
+	defer generator.Cleanup()
+
+	pages := []PDFPage{{Number: 1, HasImage: true, ImageData: []byte{0x89, 'P'}}}
+	if err := generator.optimizeImages(pages, 4); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if generator.optimized != nil {
+		t.Error("Expected a single image page left to be optimized as it's added")
+	}
+}
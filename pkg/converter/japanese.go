@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Writing modes for Options.WritingMode
+const (
+	WritingModeAuto       = ""
+	WritingModeHorizontal = "horizontal"
+	WritingModeVertical   = "vertical"
+)
+
+// minVerticalShare is the share of a book's text pages that must be set in columns before
+// the EPUB is; a few vertical pages in a horizontal book are usually title pages
+const minVerticalShare = 0.5
+
+// verticalWritingCSS sets the whole book in top-to-bottom columns read right to left.
+// Kobo and Kindle go by the prefixed properties; newer readers by the plain one.
+const verticalWritingCSS = `html {
+  writing-mode: vertical-rl;
+  -webkit-writing-mode: vertical-rl;
+  -epub-writing-mode: vertical-rl;
+}
+`
+
+// ValidateWritingMode checks a --writing-mode value
+func ValidateWritingMode(mode string) error {
+	switch mode {
+	case WritingModeAuto, WritingModeHorizontal, WritingModeVertical:
+		return nil
+	}
+	return fmt.Errorf("unknown writing mode %q (use horizontal or vertical)", mode)
+}
+
+// isVerticalBook reports whether most of a book's text pages are set in vertical columns
+func isVerticalBook(pages []PDFPage) bool {
+	text, vertical := 0, 0
+	for _, page := range pages {
+		if !page.HasText || len(page.ImageData) > 0 {
+			continue
+		}
+		text++
+		if page.Vertical {
+			vertical++
+		}
+	}
+	return text > 0 && float64(vertical) >= minVerticalShare*float64(text)
+}
+
+// SetVerticalWriting sets the book in vertical columns that run right to left, with pages
+// turning the same way
+func (eg *EPUBGenerator) SetVerticalWriting() {
+	eg.vertical = true
+}
+
+// setVerticalProgression makes a package document's pages turn right to left and tells
+// Kindle the book is set vertically, which it doesn't work out from the stylesheet
+func setVerticalProgression(content []byte) []byte {
+	opf := string(setRightToLeftProgression(content))
+	if !strings.Contains(opf, `name="primary-writing-mode"`) {
+		opf = strings.Replace(opf, "</metadata>", `  <meta name="primary-writing-mode" content="vertical-rl"/>`+"\n  </metadata>", 1)
+	}
+	return []byte(opf)
+}
+
+// rubyPattern is Aozora Bunko's ruby notation, which Japanese texts prepared for the
+// screen use: 漢字《かんじ》 annotates the kanji just before the brackets, and ｜ marks
+// where the annotated text starts when it isn't all kanji (｜東京タワー《とうきょうたわー》)
+var rubyPattern = regexp.MustCompile(`[｜|]([^｜|《》<>\n]+)《([^《》<>\n]+)》|(\p{Han}+)《([^《》<>\n]+)》`)
+
+// addRuby turns ruby notation into ruby markup, or into the reading in brackets after its
+// text on readers that can't draw ruby
+func addRuby(content string, supported bool) string {
+	return rubyPattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := rubyPattern.FindStringSubmatch(match)
+		base, reading := parts[1], parts[2]
+		if base == "" {
+			base, reading = parts[3], parts[4]
+		}
+
+		if !supported {
+			return base + "（" + reading + "）"
+		}
+		return "<ruby>" + base + "<rp>（</rp><rt>" + reading + "</rt><rp>）</rp></ruby>"
+	})
+}
@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestAddRuby(t *testing.T) {
+	tests := []struct {
+		content   string
+		supported bool
+		expected  string
+	}{
+		{"<p>吾輩《わがはい》は猫である。</p>", true, "<p><ruby>吾輩<rp>（</rp><rt>わがはい</rt><rp>）</rp></ruby>は猫である。</p>"},
+		{"<p>これは｜東京タワー《とうきょうたわー》です。</p>", true, "<p>これは<ruby>東京タワー<rp>（</rp><rt>とうきょうたわー</rt><rp>）</rp></ruby>です。</p>"},
+		{"<p>吾輩《わがはい》は猫である。</p>", false, "<p>吾輩（わがはい）は猫である。</p>"},
+		{"<p>ひらがな《だけ》</p>", true, "<p>ひらがな《だけ》</p>"}, // Nothing marks where the base starts
+		{"<p>No ruby here.</p>", true, "<p>No ruby here.</p>"},
+	}
+
+	for _, tt := range tests {
+		if result := addRuby(tt.content, tt.supported); result != tt.expected {
+			t.Errorf("addRuby(%q, %v) = %q, expected %q", tt.content, tt.supported, result, tt.expected)
+		}
+	}
+}
+
+func TestSetVerticalProgression(t *testing.T) {
+	opf := "<package>\n  <metadata>\n    <dc:language>ja</dc:language>\n  </metadata>\n  <spine toc=\"ncx\">\n  </spine>\n</package>\n"
+
+	result := string(setVerticalProgression([]byte(opf)))
+	if !strings.Contains(result, `<spine toc="ncx" page-progression-direction="rtl">`) {
+		t.Errorf("Expected right-to-left page progression, got %q", result)
+	}
+	if !strings.Contains(result, `<meta name="primary-writing-mode" content="vertical-rl"/>`+"\n  </metadata>") {
+		t.Errorf("Expected the writing mode in the metadata, got %q", result)
+	}
+
+	if again := string(setVerticalProgression([]byte(result))); again != result {
+		t.Errorf("Expected a second pass to change nothing, got %q", again)
+	}
+}
+
+func TestEPUBGeneratorWritesVerticalSpine(t *testing.T) {
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{Title: "吾輩は猫である"})
+	defer generator.Cleanup()
+
+	generator.SetLanguage("ja")
+	generator.SetVerticalWriting()
+	if err := generator.AddChapter("一", []PDFPage{{Number: 1, Text: "吾輩は猫である。名前はまだ無い。", HasText: true, Vertical: true}}); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	opf := writtenPackageDocument(t, generator)
+	if !strings.Contains(opf, `<spine toc="ncx" page-progression-direction="rtl">`) {
+		t.Errorf("Expected the spine to run right to left, got %s", opf)
+	}
+	if !strings.Contains(opf, `<meta name="primary-writing-mode" content="vertical-rl"/>`) {
+		t.Errorf("Expected the writing mode in the metadata, got %s", opf)
+	}
+}
+
+func TestIsVerticalBook(t *testing.T) {
+	pages := []PDFPage{
+		{Number: 1, HasText: true},                                // Title page, set horizontally
+		{Number: 2, HasImage: true, ImageData: []byte{0x89, 'P'}}, // Illustration
+		{Number: 3, HasText: true, Vertical: true},
+		{Number: 4, HasText: true, Vertical: true},
+	}
+	if !isVerticalBook(pages) {
+		t.Error("Expected a book of mostly vertical pages to be vertical")
+	}
+	if isVerticalBook(pages[:2]) {
+		t.Error("Expected a book of horizontal pages not to be vertical")
+	}
+}
+
+func TestValidateWritingMode(t *testing.T) {
+	for _, mode := range []string{WritingModeAuto, WritingModeHorizontal, WritingModeVertical} {
+		if err := ValidateWritingMode(mode); err != nil {
+			t.Errorf("ValidateWritingMode(%q) failed: %v", mode, err)
+		}
+	}
+	if err := ValidateWritingMode("diagonal"); err == nil {
+		t.Error("Expected an error for an unknown writing mode")
+	}
+}
@@ -0,0 +1,234 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// languageMinWords is the fewest words a paragraph needs before its language is
+	// guessed; shorter ones take their chapter's
+	languageMinWords = 8
+	// languageMinHits is how many of a language's common words a text must contain, with
+	// words shared between languages counting for a part
+	languageMinHits = 3
+	// languageMargin is how far ahead of the runner-up the best language must be
+	languageMargin = 1.3
+	// minKanaShare is the share of kana among Japanese characters that tells Japanese from
+	// Chinese, which is written with the same Han characters and no kana. Even kanji-heavy
+	// prose is well above it.
+	minKanaShare = 0.1
+)
+
+// languageProfile is a language's most common words. Function words make up a large share
+// of any text and differ clearly between languages, which is enough to tell a French
+// passage from the English around it.
+type languageProfile struct {
+	code  string // BCP 47, as used in xml:lang and dc:language
+	name  string
+	words map[string]bool
+}
+
+func newLanguageProfile(code, name, words string) languageProfile {
+	profile := languageProfile{code: code, name: name, words: make(map[string]bool)}
+	for _, word := range strings.Fields(words) {
+		profile.words[word] = true
+	}
+	return profile
+}
+
+var languageProfiles = []languageProfile{
+	newLanguageProfile("en", "English", "the of and to a i in is was that it he for with as his on be at by had not but from they she her you which or we this have were are been their has would what will there if can all when who one so no an its my me him them"),
+	newLanguageProfile("fr", "French", "le la les de des du et est un une que qui dans pour pas sur au aux avec ce cette il elle ne se sont par plus mais ou comme son sa ses nous vous je lui leur été était avait tout"),
+	newLanguageProfile("de", "German", "der die das und ist nicht ein eine zu den von mit sich des auf für im dem es ich er sie auch als an nach wie aus bei noch war hat wird dass oder aber nur"),
+	newLanguageProfile("es", "Spanish", "el la los las de del y que en un una es por con para no se su sus al lo como más pero le ya fue ha muy sin sobre también me hay este esta todo"),
+	newLanguageProfile("it", "Italian", "il lo la gli le di del della dei e che è un una per non con si da al alla sono ma come anche più nel nella questo questa era ha essere mi ci"),
+	newLanguageProfile("pt", "Portuguese", "o os a as de do da dos das e que em um uma não para com por se na no mais mas como ao foi ele ela seu sua são também já muito isso"),
+	newLanguageProfile("nl", "Dutch", "de het een en van in is dat op te zijn niet met voor er hij ze maar ook aan als bij nog wel om dan naar uit wat werd zich heeft was"),
+	newLanguageProfile("sv", "Swedish", "och att det som en på är av för med till den inte jag har de om ett var men han hon så sig från vi kan hade eller när skulle man också efter"),
+	newLanguageProfile("da", "Danish", "og at det som en på er af for med til den ikke jeg har de om et var men han hun så sig fra vi kan havde eller når skulle man også efter"),
+	newLanguageProfile("fi", "Finnish", "ja on ei se että hän oli ovat mutta kun niin myös tai jos joka ole sen kuin mitä vain nyt tämä ne minä sinä me te he"),
+	newLanguageProfile("pl", "Polish", "i w nie na się z to że do jest jak o co ale po tak za od jego jej już tylko być przez dla był była są"),
+}
+
+// wordLanguages counts the languages each common word is in. A word several languages
+// share ("de", "en", "in") says less about which one a text is in, so it counts for less.
+var wordLanguages = func() map[string]int {
+	counts := make(map[string]int)
+	for _, profile := range languageProfiles {
+		for word := range profile.words {
+			counts[word]++
+		}
+	}
+	return counts
+}()
+
+// languageName returns a language's English name, or its code if it isn't one we detect
+func languageName(code string) string {
+	if name, ok := scriptLanguageNames[code]; ok {
+		return name
+	}
+	for _, profile := range languageProfiles {
+		if profile.code == code {
+			return profile.name
+		}
+	}
+	return code
+}
+
+// detectLanguage guesses the language of a text from its common words. It returns "" when
+// the text is too short or too mixed to tell, along with the number of words in the text.
+func detectLanguage(text string) (string, int) {
+	if language, words := detectScriptLanguage(text); language != "" {
+		return language, words
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make([]float64, len(languageProfiles))
+	for _, word := range words {
+		for i, profile := range languageProfiles {
+			if profile.words[word] {
+				scores[i] += 1 / float64(wordLanguages[word])
+			}
+		}
+	}
+
+	best, runnerUp := 0, 0.0
+	for i, score := range scores[1:] {
+		if score > scores[best] {
+			runnerUp = scores[best]
+			best = i + 1
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+
+	if scores[best] < languageMinHits || scores[best] < runnerUp*languageMargin {
+		return "", len(words)
+	}
+	return languageProfiles[best].code, len(words)
+}
+
+// scriptLanguageNames are the languages detectScriptLanguage knows
+var scriptLanguageNames = map[string]string{"ja": "Japanese", "ar": "Arabic", "he": "Hebrew"}
+
+// detectScriptLanguage recognizes languages by their script, which says more than common
+// words for those with one of their own. Persian and Urdu come out as Arabic. It returns ""
+// for text mostly in Latin script, or in a script it doesn't know.
+func detectScriptLanguage(text string) (string, int) {
+	letters, kana, han, arabic, hebrew := 0, 0, 0, 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+
+	// Japanese isn't written with spaces between words, so each kana or kanji counts as a
+	// word, which is about what it carries
+	japanese := kana + han
+	switch {
+	case arabic > letters && arabic > hebrew && arabic > japanese:
+		return "ar", len(strings.Fields(text))
+	case hebrew > letters && hebrew > arabic && hebrew > japanese:
+		return "he", len(strings.Fields(text))
+	case japanese > 0 && japanese >= letters && float64(kana) >= minKanaShare*float64(japanese):
+		return "ja", japanese
+	}
+	return "", 0
+}
+
+var paragraphPattern = regexp.MustCompile(`(?s)<p(?: id="[^"]*")?>(.*?)</p>`)
+
+// tagParagraphLanguages marks paragraphs that are clearly in another language than their
+// chapter with xml:lang, so readers switch dictionary and hyphenation for them, and with dir
+// where that language runs the other way. It counts the words in each language as it goes.
+func tagParagraphLanguages(content, chapterLanguage string, mix map[string]int) string {
+	return paragraphPattern.ReplaceAllStringFunc(content, func(paragraph string) string {
+		text := stripTags(paragraph)
+		language, words := detectLanguage(text)
+		if words < languageMinWords || language == "" {
+			language = chapterLanguage
+		}
+		mix[language] += words
+
+		if language == chapterLanguage {
+			return paragraph
+		}
+		attributes := fmt.Sprintf(` xml:lang="%s" lang="%s"`, language, language)
+		if direction := languageDirection(language); direction != languageDirection(chapterLanguage) {
+			attributes += fmt.Sprintf(` dir="%s"`, direction)
+		}
+		return "<p" + attributes + paragraph[len("<p"):]
+	})
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(html string) string {
+	return tagPattern.ReplaceAllString(html, " ")
+}
+
+// addDocumentLanguage sets the language on a content document's html element, which
+// go-epub leaves without one
+func addDocumentLanguage(content []byte, language string) []byte {
+	const root = `<html xmlns="http://www.w3.org/1999/xhtml"`
+	start := bytes.Index(content, []byte(root))
+	if language == "" || start < 0 {
+		return content
+	}
+	end := bytes.IndexByte(content[start:], '>')
+	if end < 0 || bytes.Contains(content[start:start+end], []byte("xml:lang=")) {
+		return content
+	}
+
+	attributes := fmt.Sprintf(` xml:lang="%s" lang="%s"`, language, language)
+	return bytes.Replace(content, []byte(root), []byte(root+attributes), 1)
+}
+
+// LanguageShare is the part of a book written in one language
+type LanguageShare struct {
+	Code  string
+	Name  string
+	Share float64 // Of all words, 0-1
+}
+
+// languageShares turns word counts per language into shares, largest first
+func languageShares(mix map[string]int) []LanguageShare {
+	total := 0
+	for _, words := range mix {
+		total += words
+	}
+	if total == 0 {
+		return nil
+	}
+
+	shares := make([]LanguageShare, 0, len(mix))
+	for code, words := range mix {
+		if words > 0 {
+			shares = append(shares, LanguageShare{Code: code, Name: languageName(code), Share: float64(words) / float64(total)})
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Share != shares[j].Share {
+			return shares[i].Share > shares[j].Share
+		}
+		return shares[i].Code < shares[j].Code
+	})
+	return shares
+}
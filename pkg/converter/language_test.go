@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"It was the best of times, it was the worst of times, it was the age of wisdom.", "en"},
+		{"Le chat est sur la table et il ne veut pas descendre avec nous, mais elle dit que ce n'est pas grave.", "fr"},
+		{"Es war einmal ein kleines Mädchen, das war so lieb, dass sie jeder gern hatte, der sie nur ansah.", "de"},
+		{"En un lugar de la Mancha, de cuyo nombre no quiero acordarme, no ha mucho tiempo que vivía un hidalgo.", "es"},
+		{"Det var en gång en katt som inte ville gå hem, och hon sa att det var för kallt ute för att jag skulle gå.", "sv"},
+		{"Der var engang en kat, som ikke ville gå hjem, og hun sagde, at det var for koldt, og at jeg skulle gå.", "da"},
+		{"吾輩は猫である。名前はまだ無い。どこで生れたかとんと見当がつかぬ。", "ja"},
+		{"学而时习之，不亦说乎？有朋自远方来，不亦乐乎？", ""}, // Chinese, which we don't detect
+		{"كان يا ما كان في قديم الزمان، ملك عادل يحكم مدينة كبيرة.", "ar"},
+		{"בראשית ברא אלוהים את השמים ואת הארץ.", "he"},
+		{"Too short", ""},
+	}
+
+	for _, tt := range tests {
+		if language, _ := detectLanguage(tt.text); language != tt.expected {
+			t.Errorf("detectLanguage(%q) = %q, expected %q", tt.text, language, tt.expected)
+		}
+	}
+}
+
+func TestTagParagraphLanguages(t *testing.T) {
+	content := "<p>It was the best of times, it was the worst of times, it was the age of wisdom.</p>\n" +
+		"<p>Le chat est sur la table et il ne veut pas descendre avec nous, mais elle dit que ce n'est pas grave.</p>\n" +
+		"<p>C'est la vie.</p>"
+
+	mix := make(map[string]int)
+	tagged := tagParagraphLanguages(content, "en", mix)
+
+	if strings.Count(tagged, `xml:lang="fr" lang="fr"`) != 1 {
+		t.Errorf("Expected the French paragraph tagged, got:\n%s", tagged)
+	}
+	if !strings.Contains(tagged, "<p>C'est la vie.</p>") {
+		t.Error("Expected a paragraph too short to tell to take the chapter's language")
+	}
+	if mix["en"] != 21 || mix["fr"] != 22 {
+		t.Errorf("Word counts = %v, expected 21 English and 22 French", mix)
+	}
+
+	shares := languageShares(mix)
+	if len(shares) != 2 || shares[0].Name != "French" || shares[1].Share != 21.0/43 {
+		t.Errorf("Shares = %+v", shares)
+	}
+}
+
+func TestAddDocumentLanguage(t *testing.T) {
+	doc := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body><p xml:lang="fr" lang="fr">Oui</p></body></html>`)
+
+	result := string(addDocumentLanguage(doc, "sv"))
+	if !strings.HasPrefix(result, `<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="sv" lang="sv" xmlns:epub=`) {
+		t.Errorf("Expected the language on the html element, got %s", result)
+	}
+	if again := string(addDocumentLanguage([]byte(result), "en")); again != result {
+		t.Error("Expected a document that has a language to keep it")
+	}
+}
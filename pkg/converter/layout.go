@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/responses"
+)
+
+const (
+	// frontMatterPageLimit is how far into the book dedications and epigraphs are looked for
+	frontMatterPageLimit = 20
+	// maxCenteredPageChars keeps ordinary short pages (chapter endings) from qualifying
+	maxCenteredPageChars = 400
+	// centerTolerance is how unequal a line's left and right margins may be, as a fraction of page width
+	centerTolerance = 0.06
+	// maxCenteredLineWidth: lines wider than this fill the measure and say nothing about alignment
+	maxCenteredLineWidth = 0.75
+	// minColumnAspect is how many times taller than wide a run of text must be to count as a
+	// vertical column
+	minColumnAspect = 2.0
+)
+
+// textLine is the extent of one line of text on a page, in points
+type textLine struct {
+	left, right float64
+	low, high   float64 // Vertical extent; PDF coordinates grow upwards
+}
+
+// isCenteredPage reports whether a short page has all of its lines centered,
+// the way dedications and epigraphs are typeset
+func isCenteredPage(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pdfPage *PDFPage) bool {
+	if len(strings.TrimSpace(pdfPage.Text)) > maxCenteredPageChars || pdfPage.Width <= 0 {
+		return false
+	}
+
+	structured, err := instance.GetPageTextStructured(&requests.GetPageTextStructured{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc,
+				Index:    pdfPage.Number - 1,
+			},
+		},
+		Mode: requests.GetPageTextStructuredModeRects,
+	})
+	if err != nil {
+		return false
+	}
+
+	return isCenteredLayout(groupTextLines(structured.Rects), pdfPage.Width)
+}
+
+// groupTextLines merges text rects that share a line
+func groupTextLines(rects []*responses.GetPageTextStructuredRect) []textLine {
+	var lines []textLine
+	for _, rect := range rects {
+		if strings.TrimSpace(rect.Text) == "" {
+			continue
+		}
+
+		pos := rect.PointPosition
+		low, high := math.Min(pos.Top, pos.Bottom), math.Max(pos.Top, pos.Bottom)
+
+		merged := false
+		for i := range lines {
+			// Same line if the rects overlap vertically by more than half their height
+			overlap := math.Min(lines[i].high, high) - math.Max(lines[i].low, low)
+			if overlap > 0.5*(high-low) {
+				lines[i].left = math.Min(lines[i].left, pos.Left)
+				lines[i].right = math.Max(lines[i].right, pos.Right)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			lines = append(lines, textLine{left: pos.Left, right: pos.Right, low: low, high: high})
+		}
+	}
+
+	return lines
+}
+
+// isCenteredLayout checks that every line sits in the middle of the page, and that
+// at least one line is short enough for that to mean something
+func isCenteredLayout(lines []textLine, pageWidth float64) bool {
+	if len(lines) == 0 {
+		return false
+	}
+
+	hasShortLine := false
+	for _, line := range lines {
+		width := line.right - line.left
+		if width > maxCenteredLineWidth*pageWidth {
+			continue
+		}
+		hasShortLine = true
+
+		leftMargin := line.left
+		rightMargin := pageWidth - line.right
+		if math.Abs(leftMargin-rightMargin) > centerTolerance*pageWidth {
+			return false
+		}
+	}
+
+	return hasShortLine
+}
+
+// isVerticalPage reports whether a page of Japanese or Chinese text is set in vertical
+// columns, the way most Japanese novels are
+func isVerticalPage(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pdfPage *PDFPage) bool {
+	if !hasCJKText(pdfPage.Text) {
+		return false
+	}
+
+	structured, err := instance.GetPageTextStructured(&requests.GetPageTextStructured{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc,
+				Index:    pdfPage.Number - 1,
+			},
+		},
+		Mode: requests.GetPageTextStructuredModeRects,
+	})
+	if err != nil {
+		return false
+	}
+
+	return isVerticalLayout(structured.Rects)
+}
+
+// hasCJKText reports whether a text has any Han characters or kana
+func hasCJKText(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+	}) >= 0
+}
+
+// isVerticalLayout checks whether most runs of text on a page are columns rather than lines.
+// PDFium gives a run per line of horizontal text and per column of vertical text; single
+// characters are square either way and say nothing.
+func isVerticalLayout(rects []*responses.GetPageTextStructuredRect) bool {
+	columns, lines := 0, 0
+	for _, rect := range rects {
+		if utf8.RuneCountInString(strings.TrimSpace(rect.Text)) < 2 {
+			continue
+		}
+
+		pos := rect.PointPosition
+		width := math.Abs(pos.Right - pos.Left)
+		height := math.Abs(pos.Top - pos.Bottom)
+		switch {
+		case height > minColumnAspect*width:
+			columns++
+		case width > minColumnAspect*height:
+			lines++
+		}
+	}
+
+	return columns > lines
+}
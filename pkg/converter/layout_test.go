@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/klippa-app/go-pdfium/responses"
+)
+
+func TestIsCenteredLayout(t *testing.T) {
+	const pageWidth = 600.0
+
+	rect := func(text string, left, right, top, bottom float64) *responses.GetPageTextStructuredRect {
+		return &responses.GetPageTextStructuredRect{
+			Text:          text,
+			PointPosition: responses.CharPosition{Left: left, Right: right, Top: top, Bottom: bottom},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		rects    []*responses.GetPageTextStructuredRect
+		expected bool
+	}{
+		{
+			name: "dedication",
+			rects: []*responses.GetPageTextStructuredRect{
+				rect("For my mother,", 250, 350, 500, 488),
+				rect("who never doubted", 240, 360, 480, 468),
+			},
+			expected: true,
+		},
+		{
+			name: "line split into rects by font change",
+			rects: []*responses.GetPageTextStructuredRect{
+				rect("— William ", 230, 300, 400, 388),
+				rect("Shakespeare", 300, 370, 400, 388),
+			},
+			expected: true,
+		},
+		{
+			name: "left aligned short lines",
+			rects: []*responses.GetPageTextStructuredRect{
+				rect("The end.", 72, 130, 500, 488),
+			},
+			expected: false,
+		},
+		{
+			name: "full width justified text only",
+			rects: []*responses.GetPageTextStructuredRect{
+				rect(strings.Repeat("word ", 20), 72, 528, 500, 488),
+			},
+			expected: false,
+		},
+		{
+			name:     "no text",
+			rects:    nil,
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := isCenteredLayout(groupTextLines(test.rects), pageWidth)
+			if result != test.expected {
+				t.Errorf("isCenteredLayout() = %v, expected %v", result, test.expected)
+			}
+		})
+	}
+}
+
+func TestIsVerticalLayout(t *testing.T) {
+	rect := func(text string, left, right, top, bottom float64) *responses.GetPageTextStructuredRect {
+		return &responses.GetPageTextStructuredRect{
+			Text:          text,
+			PointPosition: responses.CharPosition{Left: left, Right: right, Top: top, Bottom: bottom},
+		}
+	}
+
+	vertical := []*responses.GetPageTextStructuredRect{
+		rect("吾輩は猫である。", 500, 512, 700, 600),
+		rect("名前はまだ無い。", 480, 492, 700, 600),
+		rect("一", 300, 312, 720, 708), // Page number, square either way
+	}
+	if !isVerticalLayout(vertical) {
+		t.Error("Expected columns to be vertical")
+	}
+
+	horizontal := []*responses.GetPageTextStructuredRect{
+		rect("吾輩は猫である。", 72, 172, 700, 688),
+		rect("名前はまだ無い。", 72, 172, 680, 668),
+	}
+	if isVerticalLayout(horizontal) {
+		t.Error("Expected lines not to be vertical")
+	}
+	if isVerticalLayout(nil) {
+		t.Error("Expected an empty page not to be vertical")
+	}
+}
+
+func TestCenterPageHTML(t *testing.T) {
+	result := centerPageHTML("<p>\nFor my mother\n</p>\n\n<p>\nand father\n</p>\n")
+
+	if !strings.HasPrefix(result, `<div class="centered-page">`) || !strings.HasSuffix(result, "</div>") {
+		t.Errorf("Expected centered block, got %q", result)
+	}
+
+	if strings.Contains(result, "\n\n") {
+		t.Error("Centered block should not contain paragraph breaks that chapter splitting could cut at")
+	}
+}
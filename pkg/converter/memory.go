@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memorySampleInterval is how often the watchdog looks at the heap
+const memorySampleInterval = 250 * time.Millisecond
+
+// memoryWatchdog samples the heap during page processing and trips, for good, once it
+// passes the limit
+type memoryWatchdog struct {
+	limit    uint64
+	exceeded atomic.Bool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func startMemoryWatchdog(limit uint64) *memoryWatchdog {
+	w := &memoryWatchdog{limit: limit, stop: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *memoryWatchdog) run() {
+	ticker := time.NewTicker(memorySampleInterval)
+	defer ticker.Stop()
+
+	var stats runtime.MemStats
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > w.limit {
+				w.exceeded.Store(true)
+				return
+			}
+		}
+	}
+}
+
+// Exceeded reports whether the heap has passed the limit
+func (w *memoryWatchdog) Exceeded() bool {
+	return w.exceeded.Load()
+}
+
+// Stop ends the sampling
+func (w *memoryWatchdog) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
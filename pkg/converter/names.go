@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// NameSource generates every name in the EPUB that isn't derived from the book itself,
+// such as the package identifier. Seeded from the input file, the same source gives the
+// same names on every run, so converting a book twice doesn't make every cache miss and
+// every diff noisy. Temp files and directories stay unique regardless: they never end up
+// in the EPUB, and two conversions running at once mustn't share them.
+type NameSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewNameSource creates a name source from a seed
+func NewNameSource(seed int64) *NameSource {
+	return &NameSource{rng: rand.New(rand.NewSource(seed))}
+}
+
+// RandomNameSource creates a name source that differs on every run
+func RandomNameSource() *NameSource {
+	return NewNameSource(time.Now().UnixNano())
+}
+
+// StableNameSource creates a name source seeded from a file's contents, so the same
+// input always gets the same names
+func StableNameSource(path string) (*NameSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return NewNameSource(int64(binary.BigEndian.Uint64(hash.Sum(nil)))), nil
+}
+
+// Identifier returns a urn:uuid package identifier
+func (n *NameSource) Identifier() string {
+	var id [16]byte
+	n.read(id[:])
+
+	// A version 4 UUID, just not necessarily a random one
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+func (n *NameSource) read(p []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rng.Read(p)
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestNameSourceIdentifier(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	id := NewNameSource(42).Identifier()
+	if !uuidPattern.MatchString(id) {
+		t.Errorf("Identifier %q is not a version 4 urn:uuid", id)
+	}
+	if again := NewNameSource(42).Identifier(); again != id {
+		t.Errorf("Same seed gave %q and %q", id, again)
+	}
+	if other := NewNameSource(43).Identifier(); other == id {
+		t.Error("Different seeds gave the same identifier")
+	}
+}
+
+func TestStableNameSource(t *testing.T) {
+	dir := t.TempDir()
+	book := filepath.Join(dir, "book.pdf")
+	duplicate := filepath.Join(dir, "copy.pdf")
+	other := filepath.Join(dir, "other.pdf")
+	os.WriteFile(book, []byte("%PDF-1.4 the same book"), 0644)
+	os.WriteFile(duplicate, []byte("%PDF-1.4 the same book"), 0644)
+	os.WriteFile(other, []byte("%PDF-1.4 another book"), 0644)
+
+	identifier := func(path string) string {
+		names, err := StableNameSource(path)
+		if err != nil {
+			t.Fatalf("StableNameSource failed: %v", err)
+		}
+		return names.Identifier()
+	}
+
+	if identifier(book) != identifier(duplicate) {
+		t.Error("Files with the same contents should get the same identifier")
+	}
+	if identifier(book) == identifier(other) {
+		t.Error("Different files should get different identifiers")
+	}
+	if _, err := StableNameSource(filepath.Join(dir, "missing.pdf")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -1,94 +1,209 @@
 package converter
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
+// ErrTesseractMissing is returned when OCR is asked of Tesseract and it isn't installed
+var ErrTesseractMissing = errors.New("tesseract isn't installed")
+
+// installTesseract says how to get Tesseract, for warnings about it missing
+const installTesseract = "install it (apt install tesseract-ocr, brew install tesseract)"
+
+// TesseractStatus describes the Tesseract scans are read with
+type TesseractStatus struct {
+	Linked    bool   // Built in with -tags tesseract, rather than run as a command
+	Path      string // The tesseract command ("" = linked in)
+	Version   string
+	Languages []string // Installed language data (nil = couldn't be listed)
+}
+
 type OCRProcessor struct {
-	language string
+	language  string
+	languages []string
+	scripts   []string // Scripts the languages are written in, if pages are read again in one
+	engine    OCREngine
+}
+
+// OCRWord is a recognized word and where it is on the page
+type OCRWord struct {
+	Text       string
+	Box        image.Rectangle // In pixels of the image that was recognized
+	Confidence float64         // 0-100, as Tesseract reports it
 }
 
 type OCRResult struct {
 	Text       string
-	Confidence int
+	Language   string // The languages the page was read with, joined with +
+	Words      []OCRWord
+	Confidence int // Mean word confidence, 0-100
 	WordCount  int
 	CharCount  int
 }
 
+// OCREngine recognizes the text in a PNG-encoded page image. Languages are given the way
+// Tesseract takes them, joined with + ("eng+fra"). The text is laid out a line per line,
+// with a blank line between paragraphs.
+//
+// Tesseract comes in two: the native bindings when built with -tags tesseract (see
+// ocr_native.go), and the tesseract command otherwise. The cloud services are set up in
+// ocr_cloud.go.
+type OCREngine interface {
+	Recognize(image []byte, language string) (text string, words []OCRWord, err error)
+	Close() error
+}
+
+// NewOCRProcessor sets up OCR in one or more languages, joined with + the way Tesseract
+// takes them ("eng+fra")
 func NewOCRProcessor(language string) (*OCRProcessor, error) {
 	if !IsOCRAvailable() {
-		return nil, fmt.Errorf("tesseract not available")
+		return nil, ErrTesseractMissing
+	}
+
+	languages, err := ParseOCRLanguages(language)
+	if err != nil {
+		return nil, err
+	}
+	// If the installed languages can't be listed, recognition will say what's missing
+	if installed, err := availableOCRLanguages(); err == nil {
+		if missing := missingOCRLanguages(languages, installed); len(missing) > 0 {
+			return nil, fmt.Errorf("no Tesseract language data for %s (installed: %s)",
+				strings.Join(missing, ", "), strings.Join(installed, ", "))
+		}
+	}
+
+	engine, err := newTesseractEngine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tesseract: %w", err)
 	}
 
 	return &OCRProcessor{
-		language: language,
+		language:  strings.Join(languages, "+"),
+		languages: languages,
+		scripts:   ocrScripts(languages),
+		engine:    engine,
 	}, nil
 }
 
-func (ocr *OCRProcessor) ExtractTextFromImage(img image.Image) (string, error) {
-	tempFile, err := ocr.saveImageToTemp(img)
+// NewOCRProcessorWithEngine sets up OCR with an engine other than Tesseract, which is
+// closed along with the processor. Engines that aren't Tesseract are left to tell the
+// languages apart themselves, so pages aren't read again in fewer of them.
+func NewOCRProcessorWithEngine(language string, engine OCREngine) (*OCRProcessor, error) {
+	languages, err := ParseOCRLanguages(language)
 	if err != nil {
-		return "", fmt.Errorf("failed to save image to temp file: %w", err)
+		return nil, err
 	}
-	defer os.Remove(tempFile)
 
-	return ocr.ExtractTextFromFile(tempFile)
+	return &OCRProcessor{
+		language:  strings.Join(languages, "+"),
+		languages: languages,
+		engine:    engine,
+	}, nil
+}
+
+func (ocr *OCRProcessor) ExtractTextFromImage(img image.Image) (string, error) {
+	result, err := ocr.ExtractTextWithStats(img)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
 }
 
 func (ocr *OCRProcessor) ExtractTextFromFile(imagePath string) (string, error) {
-	cmd := exec.Command("tesseract", imagePath, "stdout", "-l", ocr.language)
-	output, err := cmd.Output()
+	data, err := os.ReadFile(imagePath)
 	if err != nil {
-		return "", fmt.Errorf("OCR text extraction failed: %w", err)
+		return "", fmt.Errorf("failed to read image: %w", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	result, err := ocr.recognize(data)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
 }
 
 func (ocr *OCRProcessor) ExtractTextWithStats(img image.Image) (OCRResult, error) {
-	text, err := ocr.ExtractTextFromImage(img)
+	data, err := encodeOCRImage(img)
 	if err != nil {
-		return OCRResult{}, err
+		return OCRResult{}, fmt.Errorf("failed to encode image for OCR: %w", err)
+	}
+	return ocr.recognize(data)
+}
+
+// recognize reads a page with all the languages. When they're written in more than one
+// script, Tesseract will happily read a Latin "p" as a Cyrillic "р", so a page that turns
+// out to be almost all in one script is read again with only the languages written in it.
+// Languages sharing a script (English with French quotations) are left to Tesseract, which
+// picks between them word by word.
+func (ocr *OCRProcessor) recognize(data []byte) (OCRResult, error) {
+	result, err := ocr.recognizeIn(data, ocr.language)
+	if err != nil || len(ocr.scripts) < 2 {
+		return result, err
 	}
 
-	words := strings.Fields(text)
+	script, share := dominantScript(result.Text, ocr.scripts)
+	if share < ocrScriptDominance {
+		return result, nil
+	}
+	language := languagesInScript(ocr.languages, script)
+	if language == "" || language == ocr.language {
+		return result, nil
+	}
+	if narrowed, err := ocr.recognizeIn(data, language); err == nil {
+		return narrowed, nil
+	}
+	return result, nil
+}
+
+func (ocr *OCRProcessor) recognizeIn(data []byte, language string) (OCRResult, error) {
+	text, words, err := ocr.engine.Recognize(data, language)
+	if err != nil {
+		return OCRResult{}, fmt.Errorf("OCR text extraction failed: %w", err)
+	}
+	text = strings.TrimSpace(text)
 
 	return OCRResult{
 		Text:       text,
-		Confidence: 0, // Not available with direct binary call
-		WordCount:  len(words),
+		Language:   language,
+		Words:      words,
+		Confidence: meanConfidence(words),
+		WordCount:  len(strings.Fields(text)),
 		CharCount:  len(text),
 	}, nil
 }
 
-func (ocr *OCRProcessor) saveImageToTemp(img image.Image) (string, error) {
-	tempFile, err := os.CreateTemp("", "publify-ocr-*.png")
-	if err != nil {
-		return "", err
+// encodeOCRImage hands images to Tesseract as uncompressed PNG, which is quick to write
+// and read and never touches the disk
+func encodeOCRImage(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
 	}
-	defer tempFile.Close()
+	return buf.Bytes(), nil
+}
 
-	if err := png.Encode(tempFile, img); err != nil {
-		os.Remove(tempFile.Name())
-		return "", err
+func meanConfidence(words []OCRWord) int {
+	if len(words) == 0 {
+		return 0
 	}
-
-	return tempFile.Name(), nil
+	total := 0.0
+	for _, word := range words {
+		total += word.Confidence
+	}
+	return int(math.Round(total / float64(len(words))))
 }
 
 func (ocr *OCRProcessor) Close() error {
-	return nil
-}
-
-func IsOCRAvailable() bool {
-	_, err := exec.LookPath("tesseract")
-	return err == nil
+	return ocr.engine.Close()
 }
 
 func (ocr *OCRProcessor) ProcessImageFile(imagePath string) (string, error) {
@@ -0,0 +1,130 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// azureReadPollInterval is how long to wait before asking whether a page is read yet
+const azureReadPollInterval = time.Second
+
+// azureReadTimeout bounds how long a page may take to read, queueing included
+const azureReadTimeout = 2 * time.Minute
+
+// azureReadEngine reads pages with the Azure AI Vision Read API. Reading is asynchronous:
+// the image is submitted, then the result is polled for until it's ready.
+type azureReadEngine struct {
+	client       *http.Client
+	credentials  AzureReadCredentials
+	pollInterval time.Duration
+}
+
+func newAzureReadEngine(client *http.Client, credentials AzureReadCredentials) *azureReadEngine {
+	return &azureReadEngine{client: client, credentials: credentials, pollInterval: azureReadPollInterval}
+}
+
+type azureReadResult struct {
+	Status        string `json:"status"` // notStarted, running, succeeded or failed
+	AnalyzeResult struct {
+		ReadResults []struct {
+			Lines []struct {
+				BoundingBox []float64 `json:"boundingBox"`
+				Text        string    `json:"text"`
+				Words       []struct {
+					BoundingBox []float64 `json:"boundingBox"`
+					Text        string    `json:"text"`
+					Confidence  float64   `json:"confidence"` // 0-1
+				} `json:"words"`
+			} `json:"lines"`
+		} `json:"readResults"`
+	} `json:"analyzeResult"`
+}
+
+func (e *azureReadEngine) Recognize(data []byte, language string) (string, []OCRWord, error) {
+	data, err := compressOCRImage(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Read takes one language; with several it's left to detect them
+	analyze := strings.TrimRight(e.credentials.Endpoint, "/") + "/vision/v3.2/read/analyze"
+	if hints := ocrLanguageHints(language); len(hints) == 1 {
+		analyze += "?language=" + url.QueryEscape(hints[0])
+	}
+
+	resp, _, err := sendCloudOCRRequest(e.client, "Azure Read", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, analyze, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Ocp-Apim-Subscription-Key", e.credentials.Key)
+		return req, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	operation := resp.Header.Get("Operation-Location")
+	if operation == "" {
+		return "", nil, fmt.Errorf("Azure Read gave no operation to follow")
+	}
+
+	deadline := time.Now().Add(azureReadTimeout)
+	for {
+		time.Sleep(e.pollInterval)
+
+		_, body, err := sendCloudOCRRequest(e.client, "Azure Read", func() (*http.Request, error) {
+			req, err := http.NewRequest(http.MethodGet, operation, nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Ocp-Apim-Subscription-Key", e.credentials.Key)
+			return req, nil
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		var result azureReadResult
+		if err := json.Unmarshal(body, &result); err != nil {
+			return "", nil, fmt.Errorf("failed to read Azure Read response: %w", err)
+		}
+		switch result.Status {
+		case "succeeded":
+			text, words := parseAzureReadResult(result)
+			return text, words, nil
+		case "failed":
+			return "", nil, fmt.Errorf("Azure Read couldn't read the page")
+		}
+		if time.Now().After(deadline) {
+			return "", nil, fmt.Errorf("Azure Read took longer than %v", azureReadTimeout)
+		}
+	}
+}
+
+func parseAzureReadResult(result azureReadResult) (string, []OCRWord) {
+	var lines []ocrLine
+	var words []OCRWord
+	for _, page := range result.AnalyzeResult.ReadResults {
+		for _, line := range page.Lines {
+			lines = append(lines, ocrLine{text: line.Text, box: polygonBounds(line.BoundingBox)})
+			for _, word := range line.Words {
+				words = append(words, OCRWord{
+					Text:       word.Text,
+					Box:        polygonBounds(word.BoundingBox),
+					Confidence: word.Confidence * 100,
+				})
+			}
+		}
+	}
+	return layoutOCRLines(lines), words
+}
+
+func (e *azureReadEngine) Close() error {
+	return nil
+}
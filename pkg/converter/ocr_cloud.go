@@ -0,0 +1,273 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OCR engines. Tesseract runs locally; the others send page images to a cloud service,
+// which copes better with poor scans but needs an account (see OCRCredentials).
+const (
+	OCREngineTesseract = "tesseract"
+	OCREngineGoogle    = "google"   // Google Cloud Vision
+	OCREngineAzure     = "azure"    // Azure AI Vision Read
+	OCREngineTextract  = "textract" // AWS Textract
+)
+
+// OCREngines lists the engine names --ocr-engine takes
+var OCREngines = []string{OCREngineTesseract, OCREngineGoogle, OCREngineAzure, OCREngineTextract}
+
+// cloudOCRTimeout bounds a single request to a cloud OCR service
+const cloudOCRTimeout = 60 * time.Second
+
+// cloudOCRAttempts is how many times a request is tried when the service is throttling or
+// briefly unavailable, which happens readily with several pages in flight
+const cloudOCRAttempts = 4
+
+// OCRCredentials are the accounts for the cloud OCR engines. They are read from a JSON
+// file rather than taken as flags, so keys stay out of shell history and process lists:
+//
+//	{
+//	  "google":   {"api_key": "..."},
+//	  "azure":    {"endpoint": "https://<resource>.cognitiveservices.azure.com", "key": "..."},
+//	  "textract": {"region": "eu-west-1", "access_key_id": "...", "secret_access_key": "..."}
+//	}
+//
+// Only the engine in use needs an entry.
+type OCRCredentials struct {
+	Google   GoogleVisionCredentials `json:"google"`
+	Azure    AzureReadCredentials    `json:"azure"`
+	Textract TextractCredentials     `json:"textract"`
+}
+
+// GoogleVisionCredentials is an API key for a project with the Cloud Vision API enabled
+type GoogleVisionCredentials struct {
+	APIKey string `json:"api_key"`
+}
+
+// AzureReadCredentials is an Azure AI Vision (Computer Vision) resource
+type AzureReadCredentials struct {
+	Endpoint string `json:"endpoint"`
+	Key      string `json:"key"`
+}
+
+// TextractCredentials is an AWS access key allowed textract:DetectDocumentText
+type TextractCredentials struct {
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"` // For temporary credentials only
+}
+
+// DefaultOCRCredentialsPath is where the credentials file is looked for unless another is
+// given: publify/ocr.json in the user's config directory (~/.config on Linux)
+func DefaultOCRCredentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "publify", "ocr.json"), nil
+}
+
+// LoadOCRCredentials reads the credentials file ("" = DefaultOCRCredentialsPath)
+func LoadOCRCredentials(path string) (*OCRCredentials, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultOCRCredentialsPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no OCR credentials at %s (see publify convert --help for the format)", path)
+		}
+		return nil, fmt.Errorf("failed to read OCR credentials: %w", err)
+	}
+
+	var credentials OCRCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to read OCR credentials %s: %w", path, err)
+	}
+	return &credentials, nil
+}
+
+// ValidateOCREngine checks an engine name
+func ValidateOCREngine(name string) error {
+	for _, engine := range OCREngines {
+		if name == engine {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown OCR engine %q (use %s)", name, strings.Join(OCREngines, ", "))
+}
+
+// NewCloudOCREngine connects to a cloud OCR service with the account for it
+func NewCloudOCREngine(name string, credentials *OCRCredentials) (OCREngine, error) {
+	if credentials == nil {
+		credentials = &OCRCredentials{}
+	}
+	client := &http.Client{Timeout: cloudOCRTimeout}
+
+	switch name {
+	case OCREngineGoogle:
+		if credentials.Google.APIKey == "" {
+			return nil, fmt.Errorf("no Google Cloud Vision api_key in the OCR credentials")
+		}
+		return newGoogleVisionEngine(client, credentials.Google), nil
+	case OCREngineAzure:
+		if credentials.Azure.Endpoint == "" || credentials.Azure.Key == "" {
+			return nil, fmt.Errorf("the Azure OCR credentials need an endpoint and a key")
+		}
+		return newAzureReadEngine(client, credentials.Azure), nil
+	case OCREngineTextract:
+		textract := credentials.Textract
+		if textract.Region == "" || textract.AccessKeyID == "" || textract.SecretAccessKey == "" {
+			return nil, fmt.Errorf("the Textract OCR credentials need a region, access_key_id and secret_access_key")
+		}
+		return newTextractEngine(client, textract), nil
+	default:
+		return nil, fmt.Errorf("%q is not a cloud OCR engine", name)
+	}
+}
+
+// newPageOCRProcessor sets up the OCR engine a conversion asked for
+func newPageOCRProcessor(engine, language string, credentials *OCRCredentials) (*OCRProcessor, error) {
+	if engine == "" || engine == OCREngineTesseract {
+		return NewOCRProcessor(language)
+	}
+
+	cloud, err := NewCloudOCREngine(engine, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return NewOCRProcessorWithEngine(language, cloud)
+}
+
+// compressOCRImage deflates a page image before it's uploaded. The uncompressed PNG
+// Tesseract gets would run past the services' size limits for a colour page.
+func compressOCRImage(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to compress page image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sendCloudOCRRequest sends a request, trying again with growing pauses while the service
+// says it's throttling or unavailable. newRequest is called for each attempt, since a
+// request body can only be read once. Anything but a 2xx response is an error, with what
+// the service said about it.
+func sendCloudOCRRequest(client *http.Client, service string, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s request failed: %w", service, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s response: %w", service, err)
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if retry && attempt < cloudOCRAttempts {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			time.Sleep(wait)
+			wait *= 2
+			continue
+		}
+		if resp.StatusCode/100 != 2 {
+			if message := cloudErrorMessage(body); message != "" {
+				return nil, nil, fmt.Errorf("%s returned %s: %s", service, resp.Status, message)
+			}
+			return nil, nil, fmt.Errorf("%s returned %s", service, resp.Status)
+		}
+		return resp, body, nil
+	}
+}
+
+// cloudErrorMessage picks the message out of an error response. Each service nests it
+// differently, so it looks in the usual places and falls back to the body as it is.
+func cloudErrorMessage(body []byte) string {
+	var response struct {
+		Message      string `json:"message"`
+		UpperMessage string `json:"Message"`
+		Error        struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		for _, message := range []string{response.Error.Message, response.Message, response.UpperMessage} {
+			if message != "" {
+				return message
+			}
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// ocrLine is a line of text a cloud service found, for services that don't group lines
+// into paragraphs
+type ocrLine struct {
+	text string
+	box  image.Rectangle
+}
+
+// layoutOCRLines lays lines out the way Tesseract does, starting a paragraph where the
+// gap to the previous line is taller than that line. The lines are taken in the order the
+// service read them, which follows columns.
+func layoutOCRLines(lines []ocrLine) string {
+	var text strings.Builder
+	var previous image.Rectangle
+	for i, line := range lines {
+		switch {
+		case i == 0:
+		case line.box.Min.Y-previous.Max.Y > previous.Dy() || line.box.Max.Y < previous.Min.Y:
+			text.WriteString("\n\n") // A gap, or back up to the top of the next column
+		default:
+			text.WriteString("\n")
+		}
+		text.WriteString(line.text)
+		previous = line.box
+	}
+	return text.String()
+}
+
+// polygonBounds is the rectangle around a polygon given as x, y pairs
+func polygonBounds(points []float64) image.Rectangle {
+	if len(points) < 2 {
+		return image.Rectangle{}
+	}
+	xs, ys := make([]float64, 0, len(points)/2), make([]float64, 0, len(points)/2)
+	for i := 0; i+1 < len(points); i += 2 {
+		xs = append(xs, points[i])
+		ys = append(ys, points[i+1])
+	}
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+	return image.Rect(int(xs[0]), int(ys[0]), int(xs[len(xs)-1]), int(ys[len(ys)-1]))
+}
@@ -0,0 +1,176 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// The get-vanilla case from AWS's Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := TextractCredentials{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signAWSRequest(req, nil, credentials, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if authorization := req.Header.Get("Authorization"); authorization != expected {
+		t.Errorf("Authorization = %q, expected %q", authorization, expected)
+	}
+	if date := req.Header.Get("X-Amz-Date"); date != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q", date)
+	}
+}
+
+func TestParseGoogleVisionResponse(t *testing.T) {
+	body := `{"responses": [{"fullTextAnnotation": {"pages": [{"blocks": [{"paragraphs": [
+		{"words": [
+			{"boundingBox": {"vertices": [{"x": 10, "y": 10}, {"x": 50, "y": 10}, {"x": 50, "y": 30}, {"x": 10, "y": 30}]},
+			 "symbols": [{"text": "G"}, {"text": "o"}, {"text": "o"}, {"text": "d", "property": {"detectedBreak": {"type": "SPACE"}}}],
+			 "confidence": 0.9},
+			{"symbols": [{"text": "m"}, {"text": "o"}, {"text": "r"}, {"text": "n", "property": {"detectedBreak": {"type": "HYPHEN"}}}]},
+			{"symbols": [{"text": "i"}, {"text": "n"}, {"text": "g", "property": {"detectedBreak": {"type": "LINE_BREAK"}}}]}
+		]},
+		{"words": [
+			{"symbols": [{"text": "B"}, {"text": "y"}, {"text": "e", "property": {"detectedBreak": {"type": "LINE_BREAK"}}}]}
+		]}
+	]}]}]}}]}`
+
+	text, words, err := parseGoogleVisionResponse([]byte(body))
+	if err != nil {
+		t.Fatalf("parseGoogleVisionResponse() failed: %v", err)
+	}
+	if expected := "Good morn-\ning\n\nBye"; text != expected {
+		t.Errorf("Text = %q, expected %q", text, expected)
+	}
+	if len(words) != 4 || words[1].Text != "morn" {
+		t.Fatalf("Words = %+v", words)
+	}
+	if words[0].Box != image.Rect(10, 10, 50, 30) || words[0].Confidence != 90 {
+		t.Errorf("First word = %+v", words[0])
+	}
+
+	if _, _, err := parseGoogleVisionResponse([]byte(`{"responses": [{"error": {"message": "Bad image data."}}]}`)); err == nil ||
+		!strings.Contains(err.Error(), "Bad image data.") {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+}
+
+func TestParseTextractResponse(t *testing.T) {
+	body := `{"Blocks": [
+		{"BlockType": "PAGE"},
+		{"BlockType": "LINE", "Text": "Hello world", "Geometry": {"BoundingBox": {"Left": 0.1, "Top": 0.1, "Width": 0.5, "Height": 0.02}}},
+		{"BlockType": "LINE", "Text": "Again", "Geometry": {"BoundingBox": {"Left": 0.1, "Top": 0.125, "Width": 0.2, "Height": 0.02}}},
+		{"BlockType": "LINE", "Text": "New", "Geometry": {"BoundingBox": {"Left": 0.1, "Top": 0.2, "Width": 0.2, "Height": 0.02}}},
+		{"BlockType": "WORD", "Text": "Hello", "Confidence": 99.5, "Geometry": {"BoundingBox": {"Left": 0.1, "Top": 0.1, "Width": 0.2, "Height": 0.02}}}
+	]}`
+
+	text, words, err := parseTextractResponse([]byte(body), 1000, 2000)
+	if err != nil {
+		t.Fatalf("parseTextractResponse() failed: %v", err)
+	}
+	if expected := "Hello world\nAgain\n\nNew"; text != expected {
+		t.Errorf("Text = %q, expected %q", text, expected)
+	}
+	if len(words) != 1 || words[0].Box != image.Rect(100, 200, 300, 240) || words[0].Confidence != 99.5 {
+		t.Errorf("Words = %+v", words)
+	}
+}
+
+func TestAzureReadEngine(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/vision/v3.2/read/analyze":
+			if language := r.URL.Query().Get("language"); language != "sv" {
+				t.Errorf("Language = %q, expected sv", language)
+			}
+			w.Header().Set("Operation-Location", "http://"+r.Host+"/vision/v3.2/read/analyzeResults/1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && r.URL.Path == "/vision/v3.2/read/analyzeResults/1":
+			polls++
+			if polls == 1 {
+				w.Write([]byte(`{"status": "running"}`))
+				return
+			}
+			w.Write([]byte(`{"status": "succeeded", "analyzeResult": {"readResults": [{"lines": [
+				{"boundingBox": [10, 10, 200, 10, 200, 40, 10, 40], "text": "Hej då",
+				 "words": [{"boundingBox": [10, 10, 80, 10, 80, 40, 10, 40], "text": "Hej", "confidence": 0.99},
+				           {"boundingBox": [90, 10, 200, 10, 200, 40, 90, 40], "text": "då", "confidence": 0.5}]}
+			]}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	engine := newAzureReadEngine(server.Client(), AzureReadCredentials{Endpoint: server.URL + "/", Key: "secret"})
+	engine.pollInterval = time.Millisecond
+
+	var page bytes.Buffer
+	if err := png.Encode(&page, image.NewGray(image.Rect(0, 0, 20, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	text, words, err := engine.Recognize(page.Bytes(), "swe")
+	if err != nil {
+		t.Fatalf("Recognize() failed: %v", err)
+	}
+	if text != "Hej då" {
+		t.Errorf("Text = %q, expected %q", text, "Hej då")
+	}
+	if len(words) != 2 || words[1].Box != image.Rect(90, 10, 200, 40) || words[1].Confidence != 50 {
+		t.Errorf("Words = %+v", words)
+	}
+	if polls != 2 {
+		t.Errorf("Expected the result polled for until it was ready, polled %d times", polls)
+	}
+
+	engine.credentials.Key = "wrong"
+	if _, _, err := engine.Recognize(page.Bytes(), "swe"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the service's refusal, got %v", err)
+	}
+}
+
+func TestLoadOCRCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocr.json")
+	if err := os.WriteFile(path, []byte(`{"azure": {"endpoint": "https://example.cognitiveservices.azure.com", "key": "k"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := LoadOCRCredentials(path)
+	if err != nil {
+		t.Fatalf("LoadOCRCredentials() failed: %v", err)
+	}
+	if credentials.Azure.Key != "k" {
+		t.Errorf("Credentials = %+v", credentials)
+	}
+
+	if _, err := NewCloudOCREngine(OCREngineAzure, credentials); err != nil {
+		t.Errorf("Expected an Azure engine, got %v", err)
+	}
+	if _, err := NewCloudOCREngine(OCREngineGoogle, credentials); err == nil {
+		t.Error("Expected an error for Google without an API key")
+	}
+	if _, err := LoadOCRCredentials(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing credentials file")
+	}
+}
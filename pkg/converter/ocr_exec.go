@@ -0,0 +1,139 @@
+//go:build !tesseract
+
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// execEngine runs the tesseract command once per image. The image goes in on stdin and
+// the words come back as TSV, which has the confidences and boxes plain text lacks.
+type execEngine struct{}
+
+func newTesseractEngine() (OCREngine, error) {
+	return &execEngine{}, nil
+}
+
+func (e *execEngine) Recognize(data []byte, language string) (string, []OCRWord, error) {
+	cmd := exec.Command("tesseract", "stdin", "stdout", "-l", language, "tsv")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return "", nil, err
+	}
+
+	text, words := parseTesseractTSV(string(output))
+	return text, words, nil
+}
+
+func (e *execEngine) Close() error {
+	return nil
+}
+
+func IsOCRAvailable() bool {
+	_, err := exec.LookPath("tesseract")
+	return err == nil
+}
+
+// Tesseract finds the tesseract command and asks it what it is
+func Tesseract() (TesseractStatus, error) {
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		return TesseractStatus{}, ErrTesseractMissing
+	}
+
+	status := TesseractStatus{Path: path}
+	// Older versions print it to stderr
+	if output, err := exec.Command(path, "--version").CombinedOutput(); err == nil {
+		status.Version = parseTesseractVersion(string(output))
+	}
+	status.Languages, _ = availableOCRLanguages()
+	return status, nil
+}
+
+// parseTesseractVersion reads the first line of tesseract --version: "tesseract 5.3.0"
+func parseTesseractVersion(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "tesseract"))
+}
+
+// availableOCRLanguages asks tesseract which languages it has data for
+func availableOCRLanguages() ([]string, error) {
+	output, err := exec.Command("tesseract", "--list-langs").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseTesseractLanguages(string(output)), nil
+}
+
+// parseTesseractLanguages reads the output of tesseract --list-langs, which starts with a
+// line saying where the data is
+func parseTesseractLanguages(output string) []string {
+	var languages []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.ContainsAny(line, " :") {
+			continue
+		}
+		languages = append(languages, line)
+	}
+	return languages
+}
+
+// parseTesseractTSV reads tesseract's TSV output back into text, laid out the way its
+// plain text output would be: a line per line and a blank line between paragraphs
+func parseTesseractTSV(tsv string) (string, []OCRWord) {
+	var text strings.Builder
+	var words []OCRWord
+	var lastParagraph, lastLine string
+
+	for _, row := range strings.Split(tsv, "\n") {
+		// level page block par line word left top width height conf text
+		fields := strings.SplitN(strings.TrimRight(row, "\r"), "\t", 12)
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		word := strings.TrimSpace(fields[11])
+		if word == "" {
+			continue
+		}
+
+		paragraph := strings.Join(fields[1:4], ".")
+		line := paragraph + "." + fields[4]
+		switch {
+		case text.Len() == 0:
+		case paragraph != lastParagraph:
+			text.WriteString("\n\n")
+		case line != lastLine:
+			text.WriteString("\n")
+		default:
+			text.WriteString(" ")
+		}
+		text.WriteString(word)
+		lastParagraph, lastLine = paragraph, line
+
+		var box [4]int
+		for i := range box {
+			box[i], _ = strconv.Atoi(fields[6+i])
+		}
+		confidence, _ := strconv.ParseFloat(fields[10], 64)
+		words = append(words, OCRWord{
+			Text:       word,
+			Box:        image.Rect(box[0], box[1], box[0]+box[2], box[1]+box[3]),
+			Confidence: confidence,
+		})
+	}
+
+	return text.String(), words
+}
@@ -0,0 +1,59 @@
+//go:build !tesseract
+
+package converter
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseTesseractTSV(t *testing.T) {
+	tsv := "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+		"1\t1\t0\t0\t0\t0\t0\t0\t1000\t1400\t-1\t\n" +
+		"4\t1\t1\t1\t1\t0\t100\t100\t400\t30\t-1\t\n" +
+		"5\t1\t1\t1\t1\t1\t100\t100\t80\t30\t96.5\tThe\n" +
+		"5\t1\t1\t1\t1\t2\t190\t100\t120\t30\t91.0\tweather\n" +
+		"5\t1\t1\t1\t2\t1\t100\t140\t60\t30\t88.25\twas\n" +
+		"5\t1\t1\t2\t1\t1\t100\t220\t90\t30\t40\tfine.\n" +
+		"5\t1\t1\t2\t1\t2\t200\t220\t10\t30\t95\t \n"
+
+	text, words := parseTesseractTSV(tsv)
+
+	expected := "The weather\nwas\n\nfine."
+	if text != expected {
+		t.Errorf("Text = %q, expected %q", text, expected)
+	}
+	if len(words) != 4 {
+		t.Fatalf("Expected 4 words, got %d", len(words))
+	}
+	if words[1].Box != image.Rect(190, 100, 310, 130) {
+		t.Errorf("Box = %v", words[1].Box)
+	}
+	if words[2].Confidence != 88.25 {
+		t.Errorf("Confidence = %v, expected 88.25", words[2].Confidence)
+	}
+	if mean := meanConfidence(words); mean != 79 {
+		t.Errorf("Mean confidence = %d, expected 79", mean)
+	}
+}
+
+func TestParseTesseractLanguages(t *testing.T) {
+	output := "List of available languages in \"/usr/share/tesseract-ocr/5/tessdata/\" (3):\neng\nosd\nswe\n"
+
+	languages := parseTesseractLanguages(output)
+	if len(languages) != 3 || languages[0] != "eng" || languages[2] != "swe" {
+		t.Errorf("Languages = %v, expected [eng osd swe]", languages)
+	}
+}
+
+func TestParseTesseractVersion(t *testing.T) {
+	for output, expected := range map[string]string{
+		"tesseract 5.3.0\n leptonica-1.82.0\n  libgif 5.2.1 : libjpeg 8d\n": "5.3.0",
+		"tesseract 4.1.1\r\n leptonica-1.79.0\n":                            "4.1.1",
+		"":                                                                  "",
+	} {
+		if version := parseTesseractVersion(output); version != expected {
+			t.Errorf("parseTesseractVersion(%q) = %q, expected %q", output, version, expected)
+		}
+	}
+}
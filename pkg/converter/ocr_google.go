@@ -0,0 +1,169 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// googleVisionEngine reads pages with Google Cloud Vision's document text detection,
+// which is tuned for dense text rather than signs and labels in photos
+type googleVisionEngine struct {
+	client      *http.Client
+	endpoint    string
+	credentials GoogleVisionCredentials
+}
+
+func newGoogleVisionEngine(client *http.Client, credentials GoogleVisionCredentials) *googleVisionEngine {
+	return &googleVisionEngine{
+		client:      client,
+		endpoint:    "https://vision.googleapis.com/v1/images:annotate",
+		credentials: credentials,
+	}
+}
+
+type googleVisionRequest struct {
+	Requests []googleVisionImageRequest `json:"requests"`
+}
+
+type googleVisionImageRequest struct {
+	Image struct {
+		Content []byte `json:"content"` // Base64 in the JSON, as the API wants it
+	} `json:"image"`
+	Features     []googleVisionFeature `json:"features"`
+	ImageContext struct {
+		LanguageHints []string `json:"languageHints,omitempty"`
+	} `json:"imageContext"`
+}
+
+type googleVisionFeature struct {
+	Type string `json:"type"`
+}
+
+type googleVisionResponse struct {
+	Responses []struct {
+		FullTextAnnotation struct {
+			Pages []struct {
+				Blocks []struct {
+					Paragraphs []struct {
+						Words []googleVisionWord `json:"words"`
+					} `json:"paragraphs"`
+				} `json:"blocks"`
+			} `json:"pages"`
+		} `json:"fullTextAnnotation"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"responses"`
+}
+
+type googleVisionWord struct {
+	BoundingBox struct {
+		Vertices []struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"vertices"`
+	} `json:"boundingBox"`
+	Symbols []struct {
+		Text     string `json:"text"`
+		Property struct {
+			DetectedBreak struct {
+				Type string `json:"type"`
+			} `json:"detectedBreak"`
+		} `json:"property"`
+	} `json:"symbols"`
+	Confidence float64 `json:"confidence"` // 0-1
+}
+
+func (e *googleVisionEngine) Recognize(data []byte, language string) (string, []OCRWord, error) {
+	data, err := compressOCRImage(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var request googleVisionImageRequest
+	request.Image.Content = data
+	request.Features = []googleVisionFeature{{Type: "DOCUMENT_TEXT_DETECTION"}}
+	request.ImageContext.LanguageHints = ocrLanguageHints(language)
+	body, err := json.Marshal(googleVisionRequest{Requests: []googleVisionImageRequest{request}})
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, responseBody, err := sendCloudOCRRequest(e.client, "Google Cloud Vision", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, e.endpoint+"?key="+url.QueryEscape(e.credentials.APIKey), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return parseGoogleVisionResponse(responseBody)
+}
+
+// parseGoogleVisionResponse lays out the paragraphs Vision found. It gives words as
+// characters, each noting whether a space or line break follows it.
+func parseGoogleVisionResponse(body []byte) (string, []OCRWord, error) {
+	var response googleVisionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", nil, fmt.Errorf("failed to read Google Cloud Vision response: %w", err)
+	}
+	if len(response.Responses) == 0 {
+		return "", nil, nil
+	}
+	result := response.Responses[0]
+	if result.Error != nil {
+		return "", nil, fmt.Errorf("Google Cloud Vision: %s", result.Error.Message)
+	}
+
+	var paragraphs []string
+	var words []OCRWord
+	for _, page := range result.FullTextAnnotation.Pages {
+		for _, block := range page.Blocks {
+			for _, paragraph := range block.Paragraphs {
+				var text strings.Builder
+				for _, word := range paragraph.Words {
+					var wordText strings.Builder
+					for _, symbol := range word.Symbols {
+						wordText.WriteString(symbol.Text)
+						text.WriteString(symbol.Text)
+						switch symbol.Property.DetectedBreak.Type {
+						case "SPACE", "SURE_SPACE":
+							text.WriteString(" ")
+						case "EOL_SURE_SPACE", "LINE_BREAK":
+							text.WriteString("\n")
+						case "HYPHEN":
+							text.WriteString("-\n")
+						}
+					}
+
+					var points []float64
+					for _, vertex := range word.BoundingBox.Vertices {
+						points = append(points, vertex.X, vertex.Y)
+					}
+					words = append(words, OCRWord{
+						Text:       wordText.String(),
+						Box:        polygonBounds(points),
+						Confidence: word.Confidence * 100,
+					})
+				}
+				if paragraphText := strings.TrimSpace(text.String()); paragraphText != "" {
+					paragraphs = append(paragraphs, paragraphText)
+				}
+			}
+		}
+	}
+
+	return strings.Join(paragraphs, "\n\n"), words, nil
+}
+
+func (e *googleVisionEngine) Close() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build tesseract
+
+package converter
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// nativeEngine calls libtesseract directly, so a page costs no process start and no
+// temporary files. A gosseract client isn't safe to share between goroutines and is slow
+// to set up, so each is kept for reuse once a page is done with it. Clients are kept by
+// the languages they were set up for, since a page may be read again in fewer of them.
+type nativeEngine struct {
+	mu      sync.Mutex
+	clients map[string][]*gosseract.Client
+}
+
+func newTesseractEngine() (OCREngine, error) {
+	return &nativeEngine{clients: make(map[string][]*gosseract.Client)}, nil
+}
+
+func (e *nativeEngine) acquire(language string) (*gosseract.Client, error) {
+	e.mu.Lock()
+	if idle := e.clients[language]; len(idle) > 0 {
+		client := idle[len(idle)-1]
+		e.clients[language] = idle[:len(idle)-1]
+		e.mu.Unlock()
+		return client, nil
+	}
+	e.mu.Unlock()
+
+	client := gosseract.NewClient()
+	if err := client.SetLanguage(strings.Split(language, "+")...); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (e *nativeEngine) release(language string, client *gosseract.Client) {
+	e.mu.Lock()
+	e.clients[language] = append(e.clients[language], client)
+	e.mu.Unlock()
+}
+
+func (e *nativeEngine) Recognize(data []byte, language string) (string, []OCRWord, error) {
+	client, err := e.acquire(language)
+	if err != nil {
+		return "", nil, err
+	}
+	defer e.release(language, client)
+
+	if err := client.SetImageFromBytes(data); err != nil {
+		return "", nil, err
+	}
+	text, err := client.Text()
+	if err != nil {
+		return "", nil, err
+	}
+	boxes, err := client.GetBoundingBoxes(gosseract.RIL_WORD)
+	if err != nil {
+		return "", nil, err
+	}
+
+	words := make([]OCRWord, 0, len(boxes))
+	for _, box := range boxes {
+		if strings.TrimSpace(box.Word) == "" {
+			continue
+		}
+		words = append(words, OCRWord{Text: box.Word, Box: box.Box, Confidence: box.Confidence})
+	}
+	return text, words, nil
+}
+
+func (e *nativeEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, idle := range e.clients {
+		for _, client := range idle {
+			client.Close()
+		}
+	}
+	clear(e.clients)
+	return nil
+}
+
+// IsOCRAvailable is always true here: Tesseract is linked in
+func IsOCRAvailable() bool {
+	return true
+}
+
+// Tesseract describes the linked-in Tesseract
+func Tesseract() (TesseractStatus, error) {
+	languages, _ := availableOCRLanguages()
+	return TesseractStatus{Linked: true, Version: gosseract.Version(), Languages: languages}, nil
+}
+
+// availableOCRLanguages lists the language data in Tesseract's data directory
+func availableOCRLanguages() ([]string, error) {
+	return gosseract.GetAvailableLanguages()
+}
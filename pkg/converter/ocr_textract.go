@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// textractEngine reads pages with AWS Textract's DetectDocumentText. Requests are signed
+// with the access key here rather than pulling in the AWS SDK for a single call.
+type textractEngine struct {
+	client      *http.Client
+	endpoint    string
+	credentials TextractCredentials
+}
+
+func newTextractEngine(client *http.Client, credentials TextractCredentials) *textractEngine {
+	return &textractEngine{
+		client:      client,
+		endpoint:    "https://textract." + credentials.Region + ".amazonaws.com/",
+		credentials: credentials,
+	}
+}
+
+type textractResponse struct {
+	Blocks []struct {
+		BlockType  string  `json:"BlockType"` // PAGE, LINE or WORD
+		Text       string  `json:"Text"`
+		Confidence float64 `json:"Confidence"` // 0-100
+		Geometry   struct {
+			BoundingBox textractBox `json:"BoundingBox"`
+		} `json:"Geometry"`
+	} `json:"Blocks"`
+}
+
+// textractBox is a box in fractions of the page's width and height
+type textractBox struct {
+	Left   float64 `json:"Left"`
+	Top    float64 `json:"Top"`
+	Width  float64 `json:"Width"`
+	Height float64 `json:"Height"`
+}
+
+func (e *textractEngine) Recognize(data []byte, language string) (string, []OCRWord, error) {
+	// Textract works out the language itself, and only reads a few Latin-script ones
+	data, err := compressOCRImage(data)
+	if err != nil {
+		return "", nil, err
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read page image: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]any{"Document": map[string]any{"Bytes": data}})
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, responseBody, err := sendCloudOCRRequest(e.client, "Textract", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "Textract.DetectDocumentText")
+		signAWSRequest(req, body, e.credentials, "textract", time.Now())
+		return req, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return parseTextractResponse(responseBody, config.Width, config.Height)
+}
+
+// parseTextractResponse lays out the lines Textract found, with boxes in pixels of a
+// width by height page
+func parseTextractResponse(body []byte, width, height int) (string, []OCRWord, error) {
+	var response textractResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", nil, fmt.Errorf("failed to read Textract response: %w", err)
+	}
+
+	pixels := func(box textractBox) image.Rectangle {
+		return image.Rect(
+			int(box.Left*float64(width)), int(box.Top*float64(height)),
+			int((box.Left+box.Width)*float64(width)), int((box.Top+box.Height)*float64(height)))
+	}
+
+	var lines []ocrLine
+	var words []OCRWord
+	for _, block := range response.Blocks {
+		switch block.BlockType {
+		case "LINE":
+			lines = append(lines, ocrLine{text: block.Text, box: pixels(block.Geometry.BoundingBox)})
+		case "WORD":
+			words = append(words, OCRWord{
+				Text:       block.Text,
+				Box:        pixels(block.Geometry.BoundingBox),
+				Confidence: block.Confidence,
+			})
+		}
+	}
+	return layoutOCRLines(lines), words, nil
+}
+
+func (e *textractEngine) Close() error {
+	return nil
+}
+
+// signAWSRequest signs a request with AWS Signature Version 4, covering every header set
+// on it so far along with the host and the body
+func signAWSRequest(req *http.Request, body []byte, credentials TextractCredentials, service string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	req.Header.Set("X-Amz-Date", timestamp)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + credentials.Region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, credentials.Region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
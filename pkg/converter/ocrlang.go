@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ocrScriptDominance is the share of a page's letters one script needs before the page is
+// read again with only the languages written in it
+const ocrScriptDominance = 0.9
+
+// ocrLanguagePattern matches Tesseract language names: eng, chi_sim, script/Latin
+var ocrLanguagePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(/[A-Za-z0-9_]+)?$`)
+
+// ocrLanguageScripts are the scripts Tesseract's languages are written in, named as in
+// unicode.Scripts. Languages not listed are kept for every page.
+var ocrLanguageScripts = func() map[string][]string {
+	scripts := map[string][]string{
+		"chi_sim": {"Han"},
+		"chi_tra": {"Han"},
+		"jpn":     {"Han", "Hiragana", "Katakana"},
+		"kor":     {"Hangul", "Han"},
+	}
+	for script, languages := range map[string]string{
+		"Latin":      "eng fra deu spa ita por nld swe dan nor fin pol ces slk hun tur ron lat cat isl est lav lit slv hrv ind vie gle eus glg afr msa",
+		"Cyrillic":   "rus ukr bel bul srp mkd kaz",
+		"Greek":      "ell grc",
+		"Arabic":     "ara fas urd",
+		"Hebrew":     "heb yid",
+		"Devanagari": "hin mar nep san",
+		"Thai":       "tha",
+	} {
+		for _, language := range strings.Fields(languages) {
+			scripts[language] = []string{script}
+		}
+	}
+	return scripts
+}()
+
+// ocrLanguageCodes are the ISO 639-1 codes for Tesseract's languages, which is how the
+// cloud OCR services take them
+var ocrLanguageCodes = map[string]string{
+	"eng": "en", "fra": "fr", "deu": "de", "spa": "es", "ita": "it", "por": "pt", "nld": "nl",
+	"swe": "sv", "dan": "da", "nor": "no", "fin": "fi", "isl": "is", "pol": "pl", "ces": "cs",
+	"slk": "sk", "hun": "hu", "tur": "tr", "ron": "ro", "cat": "ca", "est": "et", "lav": "lv",
+	"lit": "lt", "slv": "sl", "hrv": "hr", "ind": "id", "vie": "vi", "gle": "ga", "eus": "eu",
+	"glg": "gl", "afr": "af", "msa": "ms", "lat": "la", "rus": "ru", "ukr": "uk", "bel": "be",
+	"bul": "bg", "srp": "sr", "mkd": "mk", "kaz": "kk", "ell": "el", "ara": "ar", "fas": "fa",
+	"urd": "ur", "heb": "he", "yid": "yi", "hin": "hi", "mar": "mr", "nep": "ne", "tha": "th",
+	"jpn": "ja", "kor": "ko", "chi_sim": "zh-Hans", "chi_tra": "zh-Hant",
+}
+
+// ocrLanguageHints turns a Tesseract language list into ISO codes, leaving out languages
+// that have none (like grc or script/Latin)
+func ocrLanguageHints(language string) []string {
+	var hints []string
+	for _, name := range strings.Split(language, "+") {
+		if code, ok := ocrLanguageCodes[name]; ok {
+			hints = append(hints, code)
+		}
+	}
+	return hints
+}
+
+// ParseOCRLanguages splits a Tesseract language list such as "eng+fra" into its languages.
+// An empty list means English.
+func ParseOCRLanguages(spec string) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return []string{"eng"}, nil
+	}
+
+	var languages []string
+	for _, language := range strings.Split(spec, "+") {
+		language = strings.TrimSpace(language)
+		if !ocrLanguagePattern.MatchString(language) {
+			return nil, fmt.Errorf("invalid OCR language %q in %q (join languages with +, like eng+fra)", language, spec)
+		}
+		languages = append(languages, language)
+	}
+	return languages, nil
+}
+
+// missingOCRLanguages returns the languages Tesseract has no data installed for
+func missingOCRLanguages(languages, installed []string) []string {
+	have := make(map[string]bool, len(installed))
+	for _, language := range installed {
+		have[language] = true
+	}
+	var missing []string
+	for _, language := range languages {
+		if !have[language] {
+			missing = append(missing, language)
+		}
+	}
+	return missing
+}
+
+// ocrScripts lists the scripts a set of languages is written in, in order of appearance
+func ocrScripts(languages []string) []string {
+	var scripts []string
+	seen := make(map[string]bool)
+	for _, language := range languages {
+		for _, script := range ocrLanguageScripts[language] {
+			if !seen[script] {
+				seen[script] = true
+				scripts = append(scripts, script)
+			}
+		}
+	}
+	return scripts
+}
+
+// dominantScript finds which of the given scripts most of a text's letters are in, and
+// what share of all its letters that is
+func dominantScript(text string, scripts []string) (string, float64) {
+	counts := make([]int, len(scripts))
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for i, script := range scripts {
+			if table := unicode.Scripts[script]; table != nil && unicode.Is(table, r) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+
+	best := 0
+	for i := range counts {
+		if counts[i] > counts[best] {
+			best = i
+		}
+	}
+	return scripts[best], float64(counts[best]) / float64(letters)
+}
+
+// languagesInScript keeps the languages written in a script, along with any whose script
+// isn't known, joined the way Tesseract takes them
+func languagesInScript(languages []string, script string) string {
+	var kept []string
+	for _, language := range languages {
+		scripts, known := ocrLanguageScripts[language]
+		if !known {
+			kept = append(kept, language)
+			continue
+		}
+		for _, s := range scripts {
+			if s == script {
+				kept = append(kept, language)
+				break
+			}
+		}
+	}
+	return strings.Join(kept, "+")
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOCRLanguages(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected []string
+	}{
+		{"", []string{"eng"}},
+		{"eng", []string{"eng"}},
+		{"eng+fra", []string{"eng", "fra"}},
+		{" swe + eng ", []string{"swe", "eng"}},
+		{"chi_sim+script/Latin", []string{"chi_sim", "script/Latin"}},
+	}
+	for _, tt := range tests {
+		languages, err := ParseOCRLanguages(tt.spec)
+		if err != nil {
+			t.Errorf("ParseOCRLanguages(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(languages, tt.expected) {
+			t.Errorf("ParseOCRLanguages(%q) = %v, expected %v", tt.spec, languages, tt.expected)
+		}
+	}
+
+	for _, spec := range []string{"eng+", "eng,fra", "eng fra", "+"} {
+		if _, err := ParseOCRLanguages(spec); err == nil {
+			t.Errorf("ParseOCRLanguages(%q) should fail", spec)
+		}
+	}
+}
+
+func TestMissingOCRLanguages(t *testing.T) {
+	missing := missingOCRLanguages([]string{"eng", "swe", "fra"}, []string{"eng", "osd", "fra"})
+	if !reflect.DeepEqual(missing, []string{"swe"}) {
+		t.Errorf("Missing = %v, expected [swe]", missing)
+	}
+}
+
+func TestDominantScript(t *testing.T) {
+	scripts := ocrScripts([]string{"eng", "fra", "rus"})
+	if !reflect.DeepEqual(scripts, []string{"Latin", "Cyrillic"}) {
+		t.Fatalf("Scripts = %v", scripts)
+	}
+
+	script, share := dominantScript("Война и мир, 1869", scripts)
+	if script != "Cyrillic" || share != 1 {
+		t.Errorf("Got %s at %.2f, expected all Cyrillic", script, share)
+	}
+
+	script, share = dominantScript("War and Peace (Война и мир)", scripts)
+	if script != "Latin" || share > ocrScriptDominance {
+		t.Errorf("Got %s at %.2f, expected mostly but not all Latin", script, share)
+	}
+
+	if script, _ := dominantScript("1869 - 42", scripts); script != "" {
+		t.Errorf("Text without letters got script %q", script)
+	}
+}
+
+func TestLanguagesInScript(t *testing.T) {
+	languages := []string{"eng", "rus", "fra", "xyz", "jpn"}
+
+	if got := languagesInScript(languages, "Latin"); got != "eng+fra+xyz" {
+		t.Errorf("Latin = %q", got)
+	}
+	if got := languagesInScript(languages, "Cyrillic"); got != "rus+xyz" {
+		t.Errorf("Cyrillic = %q", got)
+	}
+	if got := languagesInScript(languages, "Han"); got != "xyz+jpn" {
+		t.Errorf("Han = %q", got)
+	}
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// PageConfidence is how sure OCR was of the text it read on a page
+type PageConfidence struct {
+	Page       int
+	Confidence int // Mean word confidence, 0-100
+}
+
+// ocrConfidences lists the pages whose text came from OCR, in page order
+func ocrConfidences(pages []PDFPage) []PageConfidence {
+	var confidences []PageConfidence
+	for _, page := range pages {
+		if page.OCR && page.HasText {
+			confidences = append(confidences, PageConfidence{Page: page.Number, Confidence: page.OCRConfidence})
+		}
+	}
+	return confidences
+}
+
+// meanOCRConfidence is the mean confidence over the OCR'd pages, and the least sure page
+func meanOCRConfidence(confidences []PageConfidence) (int, PageConfidence) {
+	if len(confidences) == 0 {
+		return 0, PageConfidence{}
+	}
+	total := 0
+	lowest := confidences[0]
+	for _, page := range confidences {
+		total += page.Confidence
+		if page.Confidence < lowest.Confidence {
+			lowest = page
+		}
+	}
+	return int(math.Round(float64(total) / float64(len(confidences)))), lowest
+}
+
+// lowConfidencePages are the OCR'd pages read with less than minimum confidence
+func lowConfidencePages(confidences []PageConfidence, minimum int) []int {
+	var pages []int
+	for _, page := range confidences {
+		if page.Confidence < minimum {
+			pages = append(pages, page.Page)
+		}
+	}
+	return pages
+}
+
+// formatPageConfidences lists each page with its confidence, e.g. "p1 92%, p2 41%"
+func formatPageConfidences(confidences []PageConfidence) string {
+	parts := make([]string, len(confidences))
+	for i, page := range confidences {
+		parts[i] = fmt.Sprintf("p%d %d%%", page.Page, page.Confidence)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// markLowConfidence puts a note ahead of a page's text saying OCR had trouble reading it,
+// so the reader knows to take what follows with a pinch of salt. It's plain text in
+// brackets, which still shows on readers that ignore the stylesheet.
+func markLowConfidence(pageHTML string, page, confidence int) string {
+	note := fmt.Sprintf("<p class=\"ocr-warning\">[Page %d was hard to read (OCR confidence %d%%); the text below may be garbled.]</p>",
+		page, confidence)
+	return note + "\n\n" + pageHTML
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOCRConfidences(t *testing.T) {
+	pages := []PDFPage{
+		{Number: 1, HasText: true},
+		{Number: 2, HasText: true, OCR: true, OCRConfidence: 91},
+		{Number: 3, HasText: true, OCR: true, OCRConfidence: 38},
+		{Number: 4, OCR: true}, // Read, then thrown out as bleed-through
+		{Number: 5, HasText: true, OCR: true, OCRConfidence: 74},
+	}
+
+	confidences := ocrConfidences(pages)
+	expected := []PageConfidence{{Page: 2, Confidence: 91}, {Page: 3, Confidence: 38}, {Page: 5, Confidence: 74}}
+	if !slices.Equal(confidences, expected) {
+		t.Fatalf("ocrConfidences() = %v, expected %v", confidences, expected)
+	}
+
+	mean, lowest := meanOCRConfidence(confidences)
+	if mean != 68 || lowest.Page != 3 {
+		t.Errorf("meanOCRConfidence() = %d, %v, expected 68 and page 3", mean, lowest)
+	}
+	if low := lowConfidencePages(confidences, 75); !slices.Equal(low, []int{3, 5}) {
+		t.Errorf("lowConfidencePages() = %v, expected pages 3 and 5", low)
+	}
+	if low := lowConfidencePages(confidences, 0); len(low) != 0 {
+		t.Errorf("Expected no pages below a minimum of 0, got %v", low)
+	}
+}
+
+func TestMarkLowConfidence(t *testing.T) {
+	marked := markLowConfidence("<p>Tbe qnick brown fox</p>", 12, 41)
+
+	if !strings.HasPrefix(marked, `<p class="ocr-warning">[Page 12 was hard to read (OCR confidence 41%)`) {
+		t.Errorf("Expected a note ahead of the text, got %q", marked)
+	}
+	if !strings.HasSuffix(marked, "\n\n<p>Tbe qnick brown fox</p>") {
+		t.Errorf("Expected the text kept after the note, got %q", marked)
+	}
+}
@@ -1,7 +1,9 @@
 package converter
 
 import (
+	"archive/zip"
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 
@@ -45,6 +47,9 @@ func (eo *EPUBOptimizer) OptimizeHTML(html string) string {
 		optimized = eo.stripColorInformation(optimized)
 	}
 
+	// Pixel sizes were meant for a desktop screen
+	optimized = eo.RelativeUnitsHTML(optimized)
+
 	// Optimize font sizing
 	optimized = eo.optimizeFonts(optimized)
 
@@ -77,66 +82,25 @@ func (eo *EPUBOptimizer) minifyHTML(html string) string {
 
 // stripUnsupportedCSS removes CSS properties that the reader doesn't support
 func (eo *EPUBOptimizer) stripUnsupportedCSS(html string) string {
-	// Remove advanced CSS properties that many e-readers don't support
-	unsupportedProperties := []string{
-		`box-shadow[^;]*;`,
-		`text-shadow[^;]*;`,
-		`border-radius[^;]*;`,
-		`gradient[^;]*;`,
-		`transform[^;]*;`,
-		`animation[^;]*;`,
-		`transition[^;]*;`,
-		`filter[^;]*;`,
-		`backdrop-filter[^;]*;`,
-		`clip-path[^;]*;`,
-		`mask[^;]*;`,
-	}
-
-	for _, prop := range unsupportedProperties {
-		html = regexp.MustCompile(prop).ReplaceAllString(html, "")
-	}
+	html = filterCSSDeclarations(html, isUnsupportedDeclaration)
 
 	// Clean up empty style attributes
-	html = regexp.MustCompile(`style="[\s]*"`).ReplaceAllString(html, "")
+	html = regexp.MustCompile(`style="[\s;]*"`).ReplaceAllString(html, "")
 
 	return html
 }
 
 // stripAdvancedTypography removes advanced typography features
 func (eo *EPUBOptimizer) stripAdvancedTypography(html string) string {
-	// Remove font features that basic readers don't support
-	advancedFeatures := []string{
-		`font-feature-settings[^;]*;`,
-		`font-variant-ligatures[^;]*;`,
-		`font-variant-caps[^;]*;`,
-		`font-variant-numeric[^;]*;`,
-		`font-kerning[^;]*;`,
-		`text-rendering[^;]*;`,
-		`-webkit-font-smoothing[^;]*;`,
-		`-moz-osx-font-smoothing[^;]*;`,
-	}
-
-	for _, feature := range advancedFeatures {
-		html = regexp.MustCompile(feature).ReplaceAllString(html, "")
-	}
-
-	return html
+	return filterCSSDeclarations(html, func(property, value string) bool {
+		return advancedTypographyProperties[property]
+	})
 }
 
 // stripColorInformation removes color-related CSS for grayscale readers
 func (eo *EPUBOptimizer) stripColorInformation(html string) string {
 	// Remove color properties
-	colorProperties := []string{
-		`color:\s*[^;]*;`,
-		`background-color:\s*[^;]*;`,
-		`border-color:\s*[^;]*;`,
-		`outline-color:\s*[^;]*;`,
-		`text-decoration-color:\s*[^;]*;`,
-	}
-
-	for _, prop := range colorProperties {
-		html = regexp.MustCompile(prop).ReplaceAllString(html, "")
-	}
+	html = filterCSSDeclarations(html, isColorDeclaration)
 
 	// Replace color values in compound properties
 	html = regexp.MustCompile(`#[0-9a-fA-F]{3,6}`).ReplaceAllString(html, "")
@@ -159,9 +123,10 @@ func (eo *EPUBOptimizer) optimizeFonts(html string) string {
 	// Replace complex font families with basic ones
 	html = regexp.MustCompile(`font-family:\s*[^;]*;`).ReplaceAllString(html, fmt.Sprintf("font-family: %s;", basicFontStack))
 
-	// Set reasonable default font size
+	// Set reasonable default font size, in place of absolute sizes only; relative ones
+	// (including converted pixel sizes) already follow the reader's font setting
 	defaultSize := eo.profile.Capabilities.DefaultFontSize
-	html = regexp.MustCompile(`font-size:\s*[^;]*;`).ReplaceAllString(html, fmt.Sprintf("font-size: %dpt;", defaultSize))
+	html = regexp.MustCompile(`font-size:\s*[\d.]+(pt|px|pc|in|cm|mm)\s*;`).ReplaceAllString(html, fmt.Sprintf("font-size: %dpt;", defaultSize))
 
 	return html
 }
@@ -181,6 +146,9 @@ func (eo *EPUBOptimizer) OptimizeCSS(css string) string {
 	// Remove unsupported properties
 	css = eo.stripUnsupportedCSSProperties(css)
 
+	// Pixel sizes were meant for a desktop screen
+	css = eo.RelativeUnits(css)
+
 	// Optimize for grayscale if needed
 	if !eo.profile.Capabilities.SupportsColor {
 		css = eo.stripCSSColors(css)
@@ -209,16 +177,10 @@ func (eo *EPUBOptimizer) minifyCSS(css string) string {
 
 // stripUnsupportedCSSProperties removes CSS properties not supported by e-readers
 func (eo *EPUBOptimizer) stripUnsupportedCSSProperties(css string) string {
-	// List of commonly unsupported CSS properties in e-readers
+	css = filterCSSDeclarations(css, isUnsupportedDeclaration)
+
+	// Rules readers ignore altogether
 	unsupported := []string{
-		`[^}]*box-shadow[^;]*;`,
-		`[^}]*text-shadow[^;]*;`,
-		`[^}]*border-radius[^;]*;`,
-		`[^}]*gradient[^;]*;`,
-		`[^}]*transform[^;]*;`,
-		`[^}]*animation[^;]*;`,
-		`[^}]*transition[^;]*;`,
-		`[^}]*filter[^;]*;`,
 		`[^}]*@keyframes[^}]*}`,
 		`[^}]*@media[^}]*}`,
 	}
@@ -232,18 +194,85 @@ func (eo *EPUBOptimizer) stripUnsupportedCSSProperties(css string) string {
 
 // stripCSSColors removes color-related CSS properties
 func (eo *EPUBOptimizer) stripCSSColors(css string) string {
-	// Remove color properties
-	colorProps := []string{
-		`[^}]*color[^;]*;`,
-		`[^}]*background-color[^;]*;`,
-		`[^}]*border-color[^;]*;`,
-	}
+	return filterCSSDeclarations(css, isColorDeclaration)
+}
 
-	for _, prop := range colorProps {
-		css = regexp.MustCompile(prop).ReplaceAllString(css, "")
-	}
+// preservedCSSProperties are never stripped, whatever the reader: without them right-to-left
+// and vertical text is laid out the wrong way round
+var preservedCSSProperties = map[string]bool{
+	"direction":            true,
+	"unicode-bidi":         true,
+	"writing-mode":         true,
+	"-webkit-writing-mode": true,
+	"-epub-writing-mode":   true,
+}
 
-	return css
+// unsupportedProperties are properties many e-readers don't support, vendor prefixes aside.
+// Properties starting with animation, transition or mask go too.
+var unsupportedProperties = map[string]bool{
+	"box-shadow":      true,
+	"text-shadow":     true,
+	"border-radius":   true,
+	"transform":       true,
+	"filter":          true,
+	"backdrop-filter": true,
+	"clip-path":       true,
+}
+
+// advancedTypographyProperties are font features basic readers don't support
+var advancedTypographyProperties = map[string]bool{
+	"font-feature-settings":   true,
+	"font-variant-ligatures":  true,
+	"font-variant-caps":       true,
+	"font-variant-numeric":    true,
+	"font-kerning":            true,
+	"text-rendering":          true,
+	"-webkit-font-smoothing":  true,
+	"-moz-osx-font-smoothing": true,
+}
+
+var vendorPrefixPattern = regexp.MustCompile(`^-(webkit|moz|ms|o)-`)
+
+func isUnsupportedDeclaration(property, value string) bool {
+	property = vendorPrefixPattern.ReplaceAllString(property, "")
+	return unsupportedProperties[property] ||
+		strings.HasPrefix(property, "animation") ||
+		strings.HasPrefix(property, "transition") ||
+		strings.HasPrefix(property, "mask") ||
+		strings.Contains(value, "gradient")
+}
+
+func isColorDeclaration(property, value string) bool {
+	return property == "color" || strings.HasSuffix(property, "-color")
+}
+
+// declarationBlockPattern finds declarations: style attributes and the bodies of CSS rules
+var declarationBlockPattern = regexp.MustCompile(`style="[^"]*"|\{[^{}]*\}`)
+
+// filterCSSDeclarations removes the declarations drop picks from every style attribute and
+// CSS rule, one declaration at a time so the ones around it are left alone. Direction and
+// writing mode are always kept.
+func filterCSSDeclarations(text string, drop func(property, value string) bool) string {
+	return declarationBlockPattern.ReplaceAllStringFunc(text, func(block string) string {
+		prefix, suffix := "{", "}"
+		if strings.HasPrefix(block, "style=") {
+			prefix, suffix = `style="`, `"`
+		}
+		body := block[len(prefix) : len(block)-len(suffix)]
+
+		declarations := strings.Split(body, ";")
+		kept := declarations[:0]
+		for _, declaration := range declarations {
+			property, value, found := strings.Cut(declaration, ":")
+			property = strings.ToLower(strings.TrimSpace(property))
+			value = strings.ToLower(strings.TrimSpace(value))
+			if found && !preservedCSSProperties[property] && drop(property, value) {
+				continue
+			}
+			kept = append(kept, declaration)
+		}
+		return prefix + strings.Join(kept, ";") + suffix
+	})
 }
 
 // OptimizeText optimizes text content for file size
@@ -296,3 +325,48 @@ type OptimizationStats struct {
 	SizeReduction float64 // Percentage
 	BytesSaved    int
 }
+
+// ContentDocWarning describes a content document that exceeds the reader's size limit
+type ContentDocWarning struct {
+	Path     string
+	Size     int64
+	MaxBytes int
+}
+
+func (w ContentDocWarning) String() string {
+	return fmt.Sprintf("%s is %d bytes (reader limit %d bytes)", w.Path, w.Size, w.MaxBytes)
+}
+
+// CheckContentDocuments reports XHTML content documents in an EPUB that are larger than
+// the profile's MaxContentDocBytes. Works on any EPUB, not just ones we generated.
+func (eo *EPUBOptimizer) CheckContentDocuments(epubPath string) ([]ContentDocWarning, error) {
+	maxBytes := eo.profile.Capabilities.MaxContentDocBytes
+	if maxBytes <= 0 {
+		return nil, nil
+	}
+
+	zipReader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer zipReader.Close()
+
+	var warnings []ContentDocWarning
+	for _, file := range zipReader.File {
+		switch strings.ToLower(path.Ext(file.Name)) {
+		case ".xhtml", ".html", ".htm":
+		default:
+			continue
+		}
+
+		if file.UncompressedSize64 > uint64(maxBytes) {
+			warnings = append(warnings, ContentDocWarning{
+				Path:     file.Name,
+				Size:     int64(file.UncompressedSize64),
+				MaxBytes: maxBytes,
+			})
+		}
+	}
+
+	return warnings, nil
+}
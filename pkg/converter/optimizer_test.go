@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestOptimizeCSSKeepsDirection(t *testing.T) {
+	profile := reader.Profile{Capabilities: reader.DeviceCapabilities{StripUnsupportedContent: true}}
+	optimizer := NewEPUBOptimizer(profile)
+
+	css := `p { direction: rtl; unicode-bidi: embed; color: #333; text-shadow: 1px 1px gray; margin: 0; }
+.vertical { -epub-writing-mode: vertical-rl; box-shadow: none; }`
+	result := optimizer.OptimizeCSS(css)
+
+	for _, kept := range []string{"direction:rtl", "unicode-bidi:embed", "margin:0", "-epub-writing-mode:vertical-rl"} {
+		if !strings.Contains(result, kept) {
+			t.Errorf("Expected %q kept, got %q", kept, result)
+		}
+	}
+	for _, stripped := range []string{"color", "shadow"} {
+		if strings.Contains(result, stripped) {
+			t.Errorf("Expected %q stripped, got %q", stripped, result)
+		}
+	}
+}
+
+func TestOptimizeHTMLKeepsDirection(t *testing.T) {
+	profile := reader.Profile{Capabilities: reader.DeviceCapabilities{StripUnsupportedContent: true}}
+	optimizer := NewEPUBOptimizer(profile)
+
+	html := `<p style="color: red; direction: rtl; unicode-bidi: bidi-override; transform: none;">שלום</p><p style="box-shadow: none;">x</p>`
+	result := optimizer.OptimizeHTML(html)
+
+	if !strings.Contains(result, "direction: rtl") || !strings.Contains(result, "unicode-bidi: bidi-override") {
+		t.Errorf("Expected direction and unicode-bidi kept, got %q", result)
+	}
+	if strings.Contains(result, "color") || strings.Contains(result, "transform") {
+		t.Errorf("Expected color and transform stripped, got %q", result)
+	}
+	if strings.Contains(result, "box-shadow") {
+		t.Errorf("Expected box-shadow stripped, got %q", result)
+	}
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// ownerMarkHeight is the height of the owner line on an image page, as a share of the
+	// page's; it stays readable once the page is scaled to the screen without drawing the eye
+	ownerMarkHeight = 0.012
+	// minOwnerMarkSize is the smallest text size in pixels, for small page images
+	minOwnerMarkSize = 9
+)
+
+// ownerMarkShade is how dark the owner line is drawn: light enough to read past, dark
+// enough to survive being reduced to black and white, which turns anything paler white
+var (
+	ownerMarkShade     = color.Gray{Y: 150}
+	ownerMarkTextShade = color.Gray{Y: 100}
+)
+
+var (
+	ownerMarkFont     *opentype.Font
+	ownerMarkFontErr  error
+	ownerMarkFontOnce sync.Once
+)
+
+// drawOwnerMark writes the owner's name faintly at the foot of a page image, centered in
+// the bottom margin, so a copy of a scanned book still says whose it was. The image is
+// copied if it can't be drawn on.
+func drawOwnerMark(img image.Image, owner string, shade color.Gray) (image.Image, error) {
+	ownerMarkFontOnce.Do(func() {
+		ownerMarkFont, ownerMarkFontErr = opentype.Parse(goregular.TTF)
+	})
+	if ownerMarkFontErr != nil {
+		return nil, fmt.Errorf("failed to load font: %w", ownerMarkFontErr)
+	}
+
+	bounds := img.Bounds()
+	size := max(float64(bounds.Dy())*ownerMarkHeight, minOwnerMarkSize)
+	face, err := opentype.NewFace(ownerMarkFont, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	defer face.Close()
+
+	dst, ok := img.(draw.Image)
+	if !ok {
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+		dst = rgba
+	}
+
+	drawer := &font.Drawer{Dst: dst, Src: image.NewUniform(shade), Face: face}
+	width := drawer.MeasureString(owner).Round()
+	x := bounds.Min.X + max((bounds.Dx()-width)/2, 0)
+	y := bounds.Max.Y - int(size) // Baseline a line's height up from the edge
+	drawer.Dot = fixed.P(x, y)
+	drawer.DrawString(owner)
+
+	return dst, nil
+}
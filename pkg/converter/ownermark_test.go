@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestDrawOwnerMark(t *testing.T) {
+	page := image.NewGray(image.Rect(0, 0, 1000, 1400))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+
+	marked, err := drawOwnerMark(page, "Jane Doe <jane@example.com>", ownerMarkShade)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if marked != image.Image(page) {
+		t.Error("Expected a grayscale page to be drawn on in place")
+	}
+
+	// The mark sits in the bottom margin, centered, and no darker than its shade
+	top, left, right := page.Bounds().Dy(), page.Bounds().Dx(), 0
+	for y := 0; y < page.Bounds().Dy(); y++ {
+		for x := 0; x < page.Bounds().Dx(); x++ {
+			value := page.GrayAt(x, y).Y
+			if value == 255 {
+				continue
+			}
+			if value < ownerMarkShade.Y {
+				t.Fatalf("Pixel at %d,%d is darker (%d) than the mark's shade", x, y, value)
+			}
+			top, left, right = min(top, y), min(left, x), max(right, x)
+		}
+	}
+	if top < page.Bounds().Dy()-60 {
+		t.Errorf("Expected the mark at the foot of the page, found ink from row %d", top)
+	}
+	if margin := left - (page.Bounds().Dx() - 1 - right); margin < -4 || margin > 4 {
+		t.Errorf("Expected the mark centered, it runs from column %d to %d", left, right)
+	}
+}
+
+func TestDrawOwnerMarkCopiesReadOnlyImages(t *testing.T) {
+	page := image.NewYCbCr(image.Rect(0, 0, 400, 600), image.YCbCrSubsampleRatio420)
+	for i := range page.Y {
+		page.Y[i] = 255
+	}
+	for i := range page.Cb {
+		page.Cb[i], page.Cr[i] = 128, 128
+	}
+
+	marked, err := drawOwnerMark(page, "Jane Doe", ownerMarkShade)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if marked.Bounds() != page.Bounds() {
+		t.Errorf("Expected the marked page to keep its size, got %v", marked.Bounds())
+	}
+	found := false
+	for x := 0; x < 400 && !found; x++ {
+		for y := 560; y < 600; y++ {
+			if r, _, _, _ := marked.At(x, y).RGBA(); r < 0xffff {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected the mark on the copied page")
+	}
+}
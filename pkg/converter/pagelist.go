@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Every PDF page starts with a pagebreak marker named after the number printed on it, and
+// the navigation document lists them all in a page-list, so "page 137" in a citation or a
+// class is found on the reader as it is in print. toc.ncx gets the same list for readers
+// that only know EPUB 2.
+
+// ncxPath is where go-epub puts the EPUB 2 table of contents
+const ncxPath = "EPUB/toc.ncx"
+
+// pageTarget is a printed page's place in the book
+type pageTarget struct {
+	label string // As printed: "137", "xii"
+	href  string // Relative to the package document
+}
+
+// printedPageNumber is the number a page is known by in print: its label, or else its
+// place in the PDF
+func printedPageNumber(page PDFPage) string {
+	if page.Label != "" {
+		return page.Label
+	}
+	return strconv.Itoa(page.Number)
+}
+
+// pageBreakHTML marks where a PDF page begins
+func pageBreakHTML(page PDFPage) string {
+	label := html.EscapeString(printedPageNumber(page))
+	return fmt.Sprintf(`<span epub:type="pagebreak" role="doc-pagebreak" id="page%04d" title="%s" aria-label="%s"></span>`,
+		page.Number, label, label)
+}
+
+var pageBreakPattern = regexp.MustCompile(`<span epub:type="pagebreak" role="doc-pagebreak" id="([^"]+)" title="([^"]*)"`)
+
+// addPageTargets records the page breaks in a content document for the page-list
+func (eg *EPUBGenerator) addPageTargets(section, content string) {
+	href := "xhtml/" + section
+	for _, match := range pageBreakPattern.FindAllStringSubmatch(content, -1) {
+		eg.pages = append(eg.pages, pageTarget{
+			label: html.UnescapeString(match[2]),
+			href:  href + "#" + match[1],
+		})
+	}
+}
+
+// addPageList adds the page-list nav to the navigation document, hidden as readers show
+// it in their own "go to page" rather than as a page of the book
+func addPageList(content []byte, pages []pageTarget) []byte {
+	if len(pages) == 0 || strings.Contains(string(content), `epub:type="page-list"`) {
+		return content
+	}
+
+	var nav strings.Builder
+	nav.WriteString("    <nav epub:type=\"page-list\" role=\"doc-pagelist\" hidden=\"\">\n      <h2>Pages</h2>\n      <ol>\n")
+	for _, page := range pages {
+		fmt.Fprintf(&nav, "        <li><a href=\"%s\">%s</a></li>\n", html.EscapeString(page.href), html.EscapeString(page.label))
+	}
+	nav.WriteString("      </ol>\n    </nav>\n</body>")
+
+	return []byte(strings.Replace(string(content), "</body>", nav.String(), 1))
+}
+
+// addNCXPageList adds the page-list to toc.ncx. Pages numbered in roman numerals are
+// front matter, others with letters in them special pages (plates, "A-3").
+func addNCXPageList(content []byte, pages []pageTarget) []byte {
+	if len(pages) == 0 || strings.Contains(string(content), "<pageList>") {
+		return content
+	}
+
+	var list strings.Builder
+	list.WriteString("  <pageList>\n    <navLabel>\n      <text>Pages</text>\n    </navLabel>\n")
+	for i, page := range pages {
+		pageType, value := "normal", ""
+		if n, err := strconv.Atoi(page.label); err == nil {
+			value = fmt.Sprintf(` value="%d"`, n)
+		} else if isRomanNumeral(page.label) {
+			pageType = "front"
+		} else {
+			pageType = "special"
+		}
+		fmt.Fprintf(&list, "    <pageTarget id=\"pageTarget-%d\" type=\"%s\"%s>\n      <navLabel>\n        <text>%s</text>\n      </navLabel>\n      <content src=\"%s\"></content>\n    </pageTarget>\n",
+			i+1, pageType, value, html.EscapeString(page.label), html.EscapeString(page.href))
+	}
+	list.WriteString("  </pageList>\n</ncx>")
+
+	return []byte(strings.Replace(string(content), "</ncx>", list.String(), 1))
+}
+
+var romanNumeralPattern = regexp.MustCompile(`^(?i)m*(cm|cd|d?c{0,3})(xc|xl|l?x{0,3})(ix|iv|v?i{0,3})$`)
+
+// isRomanNumeral tells whether a page label is a roman numeral, as front matter is numbered
+func isRomanNumeral(label string) bool {
+	return label != "" && romanNumeralPattern.MatchString(label)
+}
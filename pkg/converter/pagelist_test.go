@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestPageBreakHTML(t *testing.T) {
+	tests := []struct {
+		page     PDFPage
+		expected string
+	}{
+		{PDFPage{Number: 149, Label: "137"}, `id="page0149" title="137" aria-label="137"`},
+		{PDFPage{Number: 12, Label: "xii"}, `id="page0012" title="xii" aria-label="xii"`},
+		{PDFPage{Number: 3}, `id="page0003" title="3" aria-label="3"`}, // No labels: its place in the PDF
+		{PDFPage{Number: 200, Label: `Plate "A"`}, `title="Plate &#34;A&#34;"`},
+	}
+	for _, test := range tests {
+		result := pageBreakHTML(test.page)
+		if !strings.Contains(result, test.expected) || !strings.HasPrefix(result, `<span epub:type="pagebreak" role="doc-pagebreak"`) {
+			t.Errorf("pageBreakHTML(%d %q) = %s, expected it to contain %s", test.page.Number, test.page.Label, result, test.expected)
+		}
+	}
+}
+
+func TestAddPageTargets(t *testing.T) {
+	eg := &EPUBGenerator{}
+	content := pageBreakHTML(PDFPage{Number: 12, Label: "xii"}) + "<p>Preface</p>\n\n" +
+		pageBreakHTML(PDFPage{Number: 13, Label: "1"}) + "<p>Chapter one</p>"
+	eg.addPageTargets("page0012.xhtml", content)
+
+	expected := []pageTarget{
+		{label: "xii", href: "xhtml/page0012.xhtml#page0012"},
+		{label: "1", href: "xhtml/page0012.xhtml#page0013"},
+	}
+	if len(eg.pages) != len(expected) {
+		t.Fatalf("Expected %d pages, got %v", len(expected), eg.pages)
+	}
+	for i := range expected {
+		if eg.pages[i] != expected[i] {
+			t.Errorf("Page %d: expected %v, got %v", i, expected[i], eg.pages[i])
+		}
+	}
+}
+
+func TestAddPageList(t *testing.T) {
+	nav := []byte("<body>\n    <nav epub:type=\"toc\"></nav>\n</body>\n</html>")
+	pages := []pageTarget{
+		{label: "xii", href: "xhtml/page0012.xhtml#page0012"},
+		{label: "1", href: "xhtml/page0012.xhtml#page0013"},
+	}
+
+	result := string(addPageList(nav, pages))
+	for _, expected := range []string{
+		`<nav epub:type="page-list" role="doc-pagelist" hidden="">`,
+		`<li><a href="xhtml/page0012.xhtml#page0012">xii</a></li>`,
+		`<li><a href="xhtml/page0012.xhtml#page0013">1</a></li>`,
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %q in %s", expected, result)
+		}
+	}
+
+	if again := string(addPageList([]byte(result), pages)); again != result {
+		t.Error("Expected a second page-list not to be added")
+	}
+	if unchanged := addPageList(nav, nil); string(unchanged) != string(nav) {
+		t.Error("Expected no page-list without pages")
+	}
+}
+
+func TestAddNCXPageList(t *testing.T) {
+	ncx := []byte("<ncx>\n  <navMap>\n  </navMap>\n</ncx>")
+	result := string(addNCXPageList(ncx, []pageTarget{
+		{label: "xii", href: "xhtml/page0012.xhtml#page0012"},
+		{label: "137", href: "xhtml/page0140.xhtml#page0149"},
+		{label: "A-3", href: "xhtml/page0200.xhtml#page0200"},
+	}))
+
+	for _, expected := range []string{
+		`<pageTarget id="pageTarget-1" type="front">`,
+		`<pageTarget id="pageTarget-2" type="normal" value="137">`,
+		`<pageTarget id="pageTarget-3" type="special">`,
+		`<content src="xhtml/page0140.xhtml#page0149"></content>`,
+		"</pageList>\n</ncx>",
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected %q in %s", expected, result)
+		}
+	}
+}
+
+func TestIsRomanNumeral(t *testing.T) {
+	for label, expected := range map[string]bool{
+		"i": true, "iv": true, "xii": true, "XLIV": true, "mcmxc": true,
+		"": false, "137": false, "iiii": false, "A-3": false, "vix": false,
+	} {
+		if result := isRomanNumeral(label); result != expected {
+			t.Errorf("isRomanNumeral(%q) = %v, expected %v", label, result, expected)
+		}
+	}
+}
+
+func TestPageListInEPUB(t *testing.T) {
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{Title: "Mort"})
+	defer generator.Cleanup()
+
+	err := generator.AddChapter("Chapter 1", []PDFPage{
+		{Number: 9, Label: "1", Text: "Death came.", HasText: true},
+		{Number: 10, Label: "2", Text: "Mort followed.", HasText: true},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "pages.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	if !strings.Contains(files["EPUB/xhtml/page0009.xhtml"], `id="page0010" title="2"`) {
+		t.Errorf("Expected a page break for page 2 in the chapter, got %s", files["EPUB/xhtml/page0009.xhtml"])
+	}
+	if !strings.Contains(files[navPath], `<li><a href="xhtml/page0009.xhtml#page0010">2</a></li>`) {
+		t.Errorf("Expected page 2 in the page-list, got %s", files[navPath])
+	}
+	if !strings.Contains(files[ncxPath], `value="2"`) {
+		t.Errorf("Expected page 2 in toc.ncx, got %s", files[ncxPath])
+	}
+	if !strings.Contains(files[packagePath], `<meta property="schema:accessibilityFeature">pageNavigation</meta>`) {
+		t.Error("Expected the package to say it has print page numbers")
+	}
+}
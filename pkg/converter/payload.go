@@ -0,0 +1,170 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+// pagePayload is where a page's image data went when it was moved to disk
+type pagePayload struct {
+	imageData string   // Raw ImageData, "" if the page had none
+	figures   []string // One PNG per entry in Images
+}
+
+// pageStore keeps the heavy part of processed pages, their rendered images and figures,
+// in scratch files. Pages are held from the first one processed until the EPUB is
+// assembled, so with the images in memory a scanned book needed about as much RAM as the
+// EPUB is big. The text and everything else stays in PDFPage; EPUB generation reads the
+// images back one page at a time.
+//
+// With a memory limit, images stay in memory until the watchdog sees the heap pass it,
+// which saves the disk round trip for books that fit; without one they go to disk as soon
+// as their page is processed.
+type pageStore struct {
+	mu       sync.Mutex
+	dir      string
+	watchdog *memoryWatchdog // nil = no limit, images always go to disk
+	notice   sync.Once
+}
+
+// newPageStore returns a store that keeps page images in memory until the heap passes
+// limit (0 = write them to disk straight away)
+func newPageStore(limit uint64) *pageStore {
+	if limit == 0 {
+		return &pageStore{}
+	}
+	return &pageStore{watchdog: startMemoryWatchdog(limit)}
+}
+
+// inMemory reports whether page images can still be kept in memory
+func (s *pageStore) inMemory() bool {
+	return s.watchdog != nil && !s.watchdog.Exceeded()
+}
+
+// directory creates the scratch directory when the first page needs it
+func (s *pageStore) directory() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "publify-pages-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		s.dir = dir
+	}
+	return s.dir, nil
+}
+
+// offload moves a page's images to disk, unless memory is still below the limit
+func (s *pageStore) offload(page *PDFPage) error {
+	if s.inMemory() || page.payload != nil || (len(page.ImageData) == 0 && len(page.Images) == 0) {
+		return nil
+	}
+	if s.watchdog != nil {
+		s.notice.Do(func() {
+			fmt.Printf("\nMemory use passed %s, keeping page images on disk from here on\n", humanize.Bytes(s.watchdog.limit))
+		})
+	}
+
+	dir, err := s.directory()
+	if err != nil {
+		return err
+	}
+
+	payload := &pagePayload{}
+	if len(page.ImageData) > 0 {
+		payload.imageData = filepath.Join(dir, fmt.Sprintf("page%04d.img", page.Number))
+		if err := os.WriteFile(payload.imageData, page.ImageData, 0600); err != nil {
+			return fmt.Errorf("failed to write page %d to disk: %w", page.Number, err)
+		}
+	}
+
+	figures := make([]PageImage, len(page.Images))
+	for i, figure := range page.Images {
+		path := filepath.Join(dir, fmt.Sprintf("page%04d_figure%d.png", page.Number, i+1))
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, figure.Image); err != nil {
+			return fmt.Errorf("failed to encode figure %d of page %d: %w", i+1, page.Number, err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			return fmt.Errorf("failed to write page %d to disk: %w", page.Number, err)
+		}
+		payload.figures = append(payload.figures, path)
+		figures[i] = PageImage{Position: figure.Position}
+	}
+
+	page.ImageData = nil
+	page.Images = figures
+	page.payload = payload
+	return nil
+}
+
+// offloadAll offloads every page that still holds its images. Pages processed before
+// the watchdog tripped are what's filling memory, so they go to disk along with the rest.
+func (s *pageStore) offloadAll(pages map[int]PDFPage) error {
+	if s.inMemory() {
+		return nil
+	}
+	for n, page := range pages {
+		if err := s.offload(&page); err != nil {
+			return err
+		}
+		pages[n] = page
+	}
+	return nil
+}
+
+// close stops the watchdog and removes the scratch files
+func (s *pageStore) close() {
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+}
+
+// withPayload returns the page with any images moved to disk read back in
+func (page PDFPage) withPayload() (PDFPage, error) {
+	if page.payload == nil {
+		return page, nil
+	}
+
+	if page.payload.imageData != "" {
+		data, err := os.ReadFile(page.payload.imageData)
+		if err != nil {
+			return page, fmt.Errorf("failed to read page %d from disk: %w", page.Number, err)
+		}
+		page.ImageData = data
+	}
+
+	figures := make([]PageImage, len(page.Images))
+	for i, path := range page.payload.figures {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return page, fmt.Errorf("failed to read page %d from disk: %w", page.Number, err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return page, fmt.Errorf("failed to decode figure %d of page %d: %w", i+1, page.Number, err)
+		}
+		figures[i] = PageImage{Image: img, Position: page.Images[i].Position, size: int64(len(data))}
+	}
+	page.Images = figures
+	page.payload = nil
+
+	return page, nil
+}
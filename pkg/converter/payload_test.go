@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPageStoreRoundTrip(t *testing.T) {
+	store := &pageStore{}
+	defer store.close()
+
+	figure := image.NewGray(image.Rect(0, 0, 4, 3))
+	figure.SetGray(1, 2, color.Gray{Y: 200})
+
+	page := PDFPage{
+		Number:    7,
+		Text:      "Some text",
+		ImageData: []byte("not really a PNG"),
+		Images:    []PageImage{{Image: figure, Position: 0.5}},
+	}
+
+	if err := store.offload(&page); err != nil {
+		t.Fatalf("offload failed: %v", err)
+	}
+	if page.ImageData != nil || page.Images[0].Image != nil {
+		t.Error("Expected images to be released after offloading")
+	}
+	if page.Text != "Some text" || page.Images[0].Position != 0.5 {
+		t.Error("Expected text and figure positions to stay in memory")
+	}
+
+	loaded, err := page.withPayload()
+	if err != nil {
+		t.Fatalf("withPayload failed: %v", err)
+	}
+	if !bytes.Equal(loaded.ImageData, []byte("not really a PNG")) {
+		t.Errorf("ImageData = %q after loading", loaded.ImageData)
+	}
+	if len(loaded.Images) != 1 || loaded.Images[0].Position != 0.5 {
+		t.Fatalf("Figures not restored: %+v", loaded.Images)
+	}
+	if got := color.GrayModel.Convert(loaded.Images[0].Image.At(1, 2)).(color.Gray); got.Y != 200 {
+		t.Errorf("Figure pixel = %d, expected 200", got.Y)
+	}
+
+	dir := store.dir
+	store.close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected scratch directory %s to be removed", dir)
+	}
+}
+
+func TestPageStoreTextOnly(t *testing.T) {
+	store := &pageStore{}
+	defer store.close()
+
+	page := PDFPage{Number: 1, Text: "Just words", HasText: true}
+	if err := store.offload(&page); err != nil {
+		t.Fatalf("offload failed: %v", err)
+	}
+	if page.payload != nil || store.dir != "" {
+		t.Error("Expected nothing written for a page without images")
+	}
+}
+
+func TestPageStoreMemoryLimit(t *testing.T) {
+	store := newPageStore(1 << 50)
+	defer store.close()
+	time.Sleep(2 * memorySampleInterval)
+
+	page := PDFPage{Number: 1, ImageData: []byte{1, 2, 3}}
+	if err := store.offload(&page); err != nil {
+		t.Fatalf("offload failed: %v", err)
+	}
+	if page.payload != nil || len(page.ImageData) != 3 {
+		t.Error("Expected the page to stay in memory below the limit")
+	}
+
+	// A tripped watchdog, rather than waiting for the heap to grow: the pages held so far
+	// go to disk along with the next
+	store.watchdog.exceeded.Store(true)
+	pages := map[int]PDFPage{1: page, 2: {Number: 2, ImageData: []byte{4, 5}}}
+	if err := store.offloadAll(pages); err != nil {
+		t.Fatalf("offloadAll failed: %v", err)
+	}
+	for n, page := range pages {
+		if page.payload == nil || page.ImageData != nil {
+			t.Errorf("Expected page %d on disk past the limit", n)
+		}
+	}
+}
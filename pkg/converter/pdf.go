@@ -1,106 +1,189 @@
 package converter
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
+	"image/png"
 	"math"
 	"os"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
-	"time"
+	"sync"
+	"unicode"
 
+	"github.com/alde/publify/internal/logging"
 	"github.com/alde/publify/internal/worker"
+	"github.com/alde/publify/pkg/storage"
 	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
 	"github.com/klippa-app/go-pdfium/requests"
 	"github.com/klippa-app/go-pdfium/webassembly"
 )
 
 type PDFPage struct {
 	Number    int
+	Label     string // Page number printed on the page, from the PDF's page labels ("" = none)
 	Text      string
-	Images    []image.Image
+	Images    []PageImage // Embedded figures extracted from text pages
 	Width     float64
 	Height    float64
 	HasText   bool
 	HasImage  bool
 	PageType  PageType
 	ImageData []byte // Raw image data for image pages
+	Centered  bool   // Short centered page such as a dedication or epigraph
+	Vertical  bool   // Japanese or Chinese text set in vertical columns
+
+	OCR           bool // The text was read with OCR
+	OCRConfidence int  // Mean word confidence of the OCR'd text, 0-100
+
+	Straightened Straightening // How the page was turned and levelled for OCR or as an image
+	Blank        bool          // An image page with nothing printed on it
+
+	payload     *pagePayload // Where ImageData and Images are kept between processing and the EPUB
+	fingerprint string       // What's on the page, for finding duplicates (see pageFingerprint)
+}
+
+// DefaultBleedThreshold is the Markov chain score below which text is treated as bleed-through.
+// Real English text scores around -1.5 to -2.5, garbled OCR around -4.0 to -6.0 or worse.
+const DefaultBleedThreshold = -3.8
+
+// imagePageDPI is high enough for the largest reader screens without bloating memory
+const imagePageDPI = 200
+
+// PDFProcessorOptions configures how a PDF is read and how its pages are processed
+type PDFProcessorOptions struct {
+	ImagePageRange        string
+	AllImagePages         bool   // Every page is an image page, whatever ImagePageRange says
+	PageRange             string // Pages to convert ("" = all of them)
+	EnableOCR             bool
+	AutoOCR               bool // Turn OCR on if most sampled pages have no text layer
+	OCRLanguage           string
+	OCREngine             string          // Which engine reads the pages ("" = OCREngineTesseract)
+	OCRCredentials        *OCRCredentials // Accounts for the cloud OCR engines
+	OCRPreprocess         string          // Clean-up steps for page images before OCR ("" = all, see ParseOCRPreprocessing)
+	SkipPages             string
+	BleedThreshold        float64       // Markov chain score threshold (0 = DefaultBleedThreshold)
+	DisableBleedDetection bool          // Keep all extracted text, even if it looks like bleed-through
+	SkipFigures           bool          // Don't extract embedded images from text pages
+	SkipDescreen          bool          // Keep halftone dot patterns in image pages
+	SkipStraighten        bool          // Leave image pages turned and tilted as they were scanned
+	SkipLayout            bool          // Don't look for centered pages (dedications, epigraphs)
+	Workers               int           // Pages processed at once, one PDFium instance each (0 = number of CPUs)
+	MemoryLimit           uint64        // Heap size up to which page images stay in memory (0 = none, straight to disk)
+	Checkpoint            storage.Store // Where to store processed pages as they finish (nil = nowhere)
+	Resume                bool          // Reuse the pages already in the checkpoint
 }
 
 type PDFProcessor struct {
-	filePath       string
-	pdfBytes       []byte
-	imagePageRange *PageRangeSet
-	pool           pdfium.Pool
-	pageCount      int
-	enableOCR      bool
-	ocrProcessor   *OCRProcessor
-	markovChain    *MarkovChain
-	skipPages      map[int]bool
-	rejectedPages  []int // Pages that failed Markov chain validation
-}
-
-func NewPDFProcessor(filePath, imagePageRangeStr string, enableOCR bool, ocrLanguage string, skipPagesStr string) (*PDFProcessor, error) {
-	imagePageRange, err := ParsePageRanges(imagePageRangeStr)
+	filePath              string
+	fileSize              int64
+	imagePageRange        *PageRangeSet
+	pageRange             *PageRangeSet
+	pool                  pdfium.Pool
+	documents             *documentCache
+	pageCount             int
+	enableOCR             bool
+	autoOCR               bool // OCR was turned on because the PDF looked scanned
+	ocrProcessor          *OCRProcessor
+	ocrPreprocess         OCRPreprocessing
+	markovChain           *MarkovChain
+	skipPages             map[int]bool
+	bleedThreshold        float64
+	disableBleedDetection bool
+	skipFigures           bool
+	skipDescreen          bool
+	skipStraighten        bool
+	skipLayout            bool
+	store                 *pageStore
+	checkpoint            *checkpoint
+	rejectedPages         []int    // Pages that failed Markov chain validation
+	degraded              []string // Features left out for a missing dependency, and why
+	mu                    sync.Mutex
+}
+
+func NewPDFProcessor(filePath string, opts PDFProcessorOptions) (*PDFProcessor, error) {
+	imagePageRange, err := ParsePageRanges(opts.ImagePageRange)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse image page ranges: %w", err)
 	}
 
+	pageRange, err := ParsePageRanges(opts.PageRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page selection: %w", err)
+	}
+
+	ocrPreprocess, err := ParseOCRPreprocessing(opts.OCRPreprocess)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse skip pages
-	skipPages, err := parseSkipPages(skipPagesStr)
+	skipPages, err := parseSkipPages(opts.SkipPages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse skip pages: %w", err)
 	}
 
-	pdfBytes, err := os.ReadFile(filePath)
+	bleedThreshold := opts.BleedThreshold
+	if bleedThreshold == 0 {
+		bleedThreshold = DefaultBleedThreshold
+	}
+
+	info, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read PDF file: %w", err)
 	}
 
+	// One PDFium instance per worker, so page jobs never wait on each other for one
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
 	pool, err := webassembly.Init(webassembly.Config{
 		MinIdle:  1,
-		MaxIdle:  2,
-		MaxTotal: 4,
+		MaxIdle:  workers,
+		MaxTotal: workers,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize PDFium: %w", err)
 	}
 
-	instance, err := pool.GetInstance(time.Second * 30)
-	if err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to get PDFium instance: %w", err)
-	}
-
-	doc, err := instance.OpenDocument(&requests.OpenDocument{
-		File: &pdfBytes,
-	})
+	// The document opened for the page count stays open for the first page job
+	documents := newDocumentCache(pool, filePath, workers)
+	document, err := documents.acquire()
 	if err != nil {
-		instance.Close()
 		pool.Close()
-		return nil, fmt.Errorf("failed to open PDF document: %w", err)
+		return nil, err
 	}
 
-	pageCountResp, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
-		Document: doc.Document,
+	pageCountResp, err := document.instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
+		Document: document.doc,
 	})
+	documents.release(document)
 	if err != nil {
-		instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
-		instance.Close()
+		documents.close()
 		pool.Close()
 		return nil, fmt.Errorf("failed to get page count: %w", err)
 	}
 
 	pageCount := pageCountResp.PageCount
 
-	instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
-	instance.Close()
-
 	var ocrProcessor *OCRProcessor
-	if enableOCR {
+	tesseractMissing := false
+	if opts.EnableOCR {
 		var err error
-		ocrProcessor, err = NewOCRProcessor(ocrLanguage)
-		if err != nil {
+		ocrProcessor, err = newPageOCRProcessor(opts.OCREngine, opts.OCRLanguage, opts.OCRCredentials)
+		switch {
+		case errors.Is(err, ErrTesseractMissing):
+			tesseractMissing = true
+			opts.EnableOCR = false
+		case err != nil:
+			documents.close()
 			pool.Close()
 			return nil, fmt.Errorf("failed to initialize OCR processor: %w", err)
 		}
@@ -110,16 +193,26 @@ func NewPDFProcessor(filePath, imagePageRangeStr string, enableOCR bool, ocrLang
 	markovChain := NewEnglishMarkovChain()
 
 	processor := &PDFProcessor{
-		filePath:       filePath,
-		pdfBytes:       pdfBytes,
-		imagePageRange: imagePageRange,
-		pool:           pool,
-		pageCount:      pageCount,
-		enableOCR:      enableOCR,
-		ocrProcessor:   ocrProcessor,
-		markovChain:    markovChain,
-		skipPages:      skipPages,
-		rejectedPages:  make([]int, 0),
+		filePath:              filePath,
+		fileSize:              info.Size(),
+		imagePageRange:        imagePageRange,
+		pageRange:             pageRange,
+		pool:                  pool,
+		documents:             documents,
+		pageCount:             pageCount,
+		enableOCR:             opts.EnableOCR,
+		ocrProcessor:          ocrProcessor,
+		ocrPreprocess:         ocrPreprocess,
+		markovChain:           markovChain,
+		skipPages:             skipPages,
+		bleedThreshold:        bleedThreshold,
+		disableBleedDetection: opts.DisableBleedDetection,
+		skipFigures:           opts.SkipFigures,
+		skipDescreen:          opts.SkipDescreen,
+		skipStraighten:        opts.SkipStraighten,
+		skipLayout:            opts.SkipLayout,
+		store:                 newPageStore(opts.MemoryLimit),
+		rejectedPages:         make([]int, 0),
 	}
 
 	if imagePageRange != nil {
@@ -128,6 +221,59 @@ func NewPDFProcessor(filePath, imagePageRangeStr string, enableOCR bool, ocrLang
 			return nil, fmt.Errorf("invalid page range: %w", err)
 		}
 	}
+	if err := pageRange.ValidateAgainstTotal(pageCount); err != nil {
+		processor.Close()
+		return nil, fmt.Errorf("invalid page selection: %w", err)
+	}
+	if opts.AllImagePages {
+		processor.imagePageRange = &PageRangeSet{ranges: []PageRange{{Start: 1, End: pageCount}}}
+	}
+
+	// OCR asked of a Tesseract that isn't installed is left out rather than failing the
+	// conversion: pages with a text layer still have their text
+	if tesseractMissing {
+		processor.degrade("OCR skipped: Tesseract isn't installed, so pages are read from their text layer only. " +
+			"To read scans, " + installTesseract + " or use a cloud engine with --ocr-engine")
+	}
+
+	if opts.AutoOCR && !opts.EnableOCR && !tesseractMissing {
+		scanned, withoutText, sampled, err := processor.looksScanned()
+		if err != nil {
+			processor.Close()
+			return nil, err
+		}
+		if scanned {
+			processor.enableAutoOCR(opts, withoutText, sampled)
+		}
+	}
+
+	if opts.Checkpoint != nil {
+		processor.checkpoint, err = openCheckpoint(opts.Checkpoint, checkpointKey{
+			InputSize:             info.Size(),
+			InputModified:         info.ModTime(),
+			ImagePageRange:        processor.imagePageRange.String(), // All of them, for a scan OCR couldn't read
+			EnableOCR:             processor.enableOCR,
+			OCRLanguage:           opts.OCRLanguage,
+			OCREngine:             opts.OCREngine,
+			OCRPreprocess:         ocrPreprocess.String(),
+			SkipPages:             opts.SkipPages,
+			BleedThreshold:        bleedThreshold,
+			DisableBleedDetection: opts.DisableBleedDetection,
+			SkipFigures:           opts.SkipFigures,
+			SkipDescreen:          opts.SkipDescreen,
+			SkipStraighten:        opts.SkipStraighten,
+			SkipLayout:            opts.SkipLayout,
+		}, opts.Resume)
+		switch {
+		case err != nil && opts.Resume:
+			processor.Close()
+			return nil, err
+		case err != nil:
+			// Checkpoints only matter if the conversion is interrupted
+			processor.checkpoint = nil
+			processor.degrade(fmt.Sprintf("Checkpoints off: %v, so an interrupted conversion will start over", err))
+		}
+	}
 
 	return processor, nil
 }
@@ -136,6 +282,17 @@ func (p *PDFProcessor) GetPageCount() int {
 	return p.pageCount
 }
 
+// SelectedPages returns the numbers of the pages to convert, in order
+func (p *PDFProcessor) SelectedPages() []int {
+	var selected []int
+	for i := 1; i <= p.pageCount; i++ {
+		if len(p.pageRange.GetRanges()) == 0 || p.pageRange.Contains(i) {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
 func (p *PDFProcessor) ProcessPages(ctx context.Context, pool *worker.Pool, progressCallback func(int, int)) ([]PDFPage, error) {
 	if pool == nil {
 		return p.processSequentially(ctx, progressCallback)
@@ -144,77 +301,101 @@ func (p *PDFProcessor) ProcessPages(ctx context.Context, pool *worker.Pool, prog
 }
 
 func (p *PDFProcessor) processSequentially(ctx context.Context, progressCallback func(int, int)) ([]PDFPage, error) {
-	pageCount := p.GetPageCount()
-	pages := make([]PDFPage, pageCount)
+	selected := p.SelectedPages()
+	pages := make([]PDFPage, len(selected))
 
-	for i := 0; i < pageCount; i++ {
+	for i, pageNum := range selected {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 		}
 
-		page, err := p.ProcessPage(i + 1)
+		page, err := p.ProcessPage(pageNum)
 		if err != nil {
-			return nil, fmt.Errorf("failed to process page %d: %w", i+1, err)
+			return nil, fmt.Errorf("failed to process page %d: %w", pageNum, err)
 		}
 
 		pages[i] = page
+		if !p.store.inMemory() {
+			for j := range pages[:i+1] {
+				if err := p.store.offload(&pages[j]); err != nil {
+					return nil, err
+				}
+			}
+		}
 
 		if progressCallback != nil {
-			progressCallback(i+1, pageCount)
+			progressCallback(i+1, len(selected))
 		}
 	}
 
 	return pages, nil
 }
 
-// processWithWorkerPool processes pages using the worker pool for concurrency
+// processWithWorkerPool processes pages using the worker pool for concurrency, one job per
+// page. Pages finish in any order; they're put back in page order at the end.
 func (p *PDFProcessor) processWithWorkerPool(ctx context.Context, pool *worker.Pool) ([]PDFPage, error) {
-	pageCount := p.GetPageCount()
-	pages := make([]PDFPage, pageCount)
+	selected := p.SelectedPages()
+	pageCount := len(selected)
 	results := pool.Results()
 	pageResults := make(chan PageResult, pageCount)
 
-	// Submit all page processing jobs
-	for i := 1; i <= pageCount; i++ {
-		job := &PageProcessingJob{
-			processor:  p,
-			pageNum:    i,
-			resultChan: pageResults,
+	// Cancelled on the first failure, so the jobs still queued skip their page
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Submit from a goroutine: the queue is shorter than the book, and workers can only
+	// take more jobs while we're collecting results below
+	go func() {
+		for _, pageNum := range selected {
+			pool.Submit(&PageProcessingJob{
+				ctx:        ctx,
+				processor:  p,
+				pageNum:    pageNum,
+				resultChan: pageResults,
+			})
 		}
-		pool.Submit(job)
-	}
+	}()
 
-	// Collect results from both channels
+	// Collect every result, even after a failure, so no worker is left blocked on a send
 	receivedResults := make(map[int]PDFPage)
-	completedJobs := 0
+	var firstErr error
+	completedJobs, receivedPages := 0, 0
 
-	for completedJobs < pageCount {
+	for completedJobs < pageCount || receivedPages < pageCount {
 		select {
-		case result := <-results:
-			if result.Error != nil {
-				return nil, fmt.Errorf("page processing failed: %w", result.Error)
-			}
+		case <-results:
 			completedJobs++
 		case pageResult := <-pageResults:
+			receivedPages++
 			if pageResult.Error != nil {
-				return nil, fmt.Errorf("page %d processing failed: %w", pageResult.PageNum, pageResult.Error)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("page %d processing failed: %w", pageResult.PageNum, pageResult.Error)
+				}
+				cancel()
+				continue
 			}
 			receivedResults[pageResult.PageNum] = pageResult.Page
-		case <-ctx.Done():
-			return nil, ctx.Err()
+			if err := p.store.offloadAll(receivedResults); err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
 		}
 	}
 
-	// Arrange results in correct order
-	for i := 1; i <= pageCount; i++ {
-		if page, exists := receivedResults[i]; exists {
-			pages[i-1] = page
-		} else {
-			// Return empty page if processing failed
-			pages[i-1] = PDFPage{Number: i}
-		}
+	if err := ctx.Err(); firstErr == nil && err != nil {
+		// The caller's context, ours is only cancelled after a failure
+		firstErr = err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// Arrange results in page order
+	pages := make([]PDFPage, pageCount)
+	for i, pageNum := range selected {
+		pages[i] = receivedResults[pageNum]
 	}
 
 	return pages, nil
@@ -229,6 +410,7 @@ type PageResult struct {
 
 // PageProcessingJob implements the worker.Job interface for processing PDF pages
 type PageProcessingJob struct {
+	ctx        context.Context // Cancelled once another page has failed
 	processor  *PDFProcessor
 	pageNum    int
 	resultChan chan<- PageResult
@@ -239,6 +421,11 @@ func (j *PageProcessingJob) ID() string {
 }
 
 func (j *PageProcessingJob) Process(ctx context.Context) error {
+	if err := j.ctx.Err(); err != nil {
+		j.resultChan <- PageResult{PageNum: j.pageNum, Error: err}
+		return err
+	}
+
 	page, err := j.processor.ProcessPage(j.pageNum)
 
 	// Send result through channel
@@ -251,7 +438,38 @@ func (j *PageProcessingJob) Process(ctx context.Context) error {
 	return err // Also return error for worker pool tracking
 }
 
+// ProcessPage extracts a page, or takes it from the checkpoint if an earlier run already did.
+// Its images are moved to scratch files (see pageStore) and read back by the EPUB generator,
+// once memory use passes the limit if there is one.
 func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
+	page, rejected, ok, err := p.checkpoint.load(pageNum)
+	if err != nil {
+		return PDFPage{}, err
+	}
+	if ok {
+		if rejected {
+			p.mu.Lock()
+			p.rejectedPages = append(p.rejectedPages, pageNum)
+			p.mu.Unlock()
+		}
+	} else {
+		page, err = p.processPage(pageNum)
+		if err != nil {
+			return PDFPage{}, err
+		}
+		if err := p.checkpoint.save(page, p.isRejected(pageNum)); err != nil {
+			return PDFPage{}, err
+		}
+	}
+
+	page.fingerprint = pageFingerprint(page)
+	if err := p.store.offload(&page); err != nil {
+		return PDFPage{}, err
+	}
+	return page, nil
+}
+
+func (p *PDFProcessor) processPage(pageNum int) (PDFPage, error) {
 	if pageNum < 1 || pageNum > p.GetPageCount() {
 		return PDFPage{}, fmt.Errorf("page number %d out of range (1-%d)", pageNum, p.GetPageCount())
 	}
@@ -269,19 +487,12 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 		}, nil
 	}
 
-	instance, err := p.pool.GetInstance(time.Second * 30)
-	if err != nil {
-		return PDFPage{}, fmt.Errorf("failed to get PDFium instance: %w", err)
-	}
-	defer instance.Close()
-
-	doc, err := instance.OpenDocument(&requests.OpenDocument{
-		File: &p.pdfBytes,
-	})
+	document, err := p.documents.acquire()
 	if err != nil {
-		return PDFPage{}, fmt.Errorf("failed to open PDF document: %w", err)
+		return PDFPage{}, err
 	}
-	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+	defer p.documents.release(document)
+	instance, doc := document.instance, document.doc
 
 	pageType := GetPageType(pageNum, p.imagePageRange)
 
@@ -290,12 +501,13 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 		PageType: pageType,
 		Width:    612.0,
 		Height:   792.0,
+		Label:    pageLabel(instance, doc, pageNum-1),
 	}
 
 	pageText, err := instance.GetPageText(&requests.GetPageText{
 		Page: requests.Page{
 			ByIndex: &requests.PageByIndex{
-				Document: doc.Document,
+				Document: doc,
 				Index:    pageNum - 1,
 			},
 		},
@@ -305,7 +517,7 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 	if err != nil || pageText.Text == "" {
 		text = ""
 	} else {
-		text = cleanText(pageText.Text)
+		text = unshapeText(cleanText(pageText.Text))
 	}
 
 	// If text extraction failed or returned minimal text, try OCR
@@ -316,19 +528,28 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 		pageImage, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{
 			Page: requests.Page{
 				ByIndex: &requests.PageByIndex{
-					Document: doc.Document,
+					Document: doc,
 					Index:    pageNum - 1,
 				},
 			},
 			DPI: 300,
 		})
+		if err != nil {
+			logging.Module("ocr").Warn("Could not render page for OCR", "page", pageNum, "err", err)
+		}
 		if err == nil && pageImage.Result.Image != nil {
 			// Clean up the image when done
 			defer pageImage.Cleanup()
 
 			// Try OCR and use it if it provides significantly more text
-			ocrText, ocrErr := p.ocrProcessor.ExtractTextFromImage(pageImage.Result.Image)
-			if ocrErr == nil {
+			prepared, straightened := p.ocrPreprocess.apply(pageImage.Result.Image)
+			ocrResult, ocrErr := p.ocrProcessor.ExtractTextWithStats(prepared)
+			if ocrErr != nil {
+				logging.Module("ocr").Warn("OCR failed", "page", pageNum, "err", ocrErr)
+			} else {
+				logging.Module("ocr").Debug("OCR", "page", pageNum, "language", ocrResult.Language, "confidence", ocrResult.Confidence, "words", ocrResult.WordCount)
+				logging.Module("ocr").Log(context.Background(), logging.LevelTrace, "OCR text", "page", pageNum, "text", ocrResult.Text)
+				ocrText := ocrResult.Text
 				ocrTextClean := strings.TrimSpace(ocrText)
 				textClean := strings.TrimSpace(text)
 
@@ -337,6 +558,9 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 					// Check if OCR text looks like garbled bleed-through
 					if !p.isLikelyBleedThrough(pageNum, ocrTextClean) {
 						text = ocrText
+						pdfPage.OCR = true
+						pdfPage.OCRConfidence = ocrResult.Confidence
+						pdfPage.Straightened = straightened
 					}
 				}
 			}
@@ -348,6 +572,7 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 		if p.isLikelyBleedThrough(pageNum, text) {
 			// If the text is bleed-through, clear it
 			text = ""
+			pdfPage.OCR = false
 		}
 	}
 
@@ -355,12 +580,130 @@ func (p *PDFProcessor) ProcessPage(pageNum int) (PDFPage, error) {
 	pdfPage.HasText = len(strings.TrimSpace(text)) > 0
 
 	if pageType == PageTypeImage {
+		rendered, err := renderPageImage(instance, doc, pageNum-1, !p.skipDescreen, !p.skipStraighten)
+		if err != nil {
+			return PDFPage{}, fmt.Errorf("failed to render image page %d: %w", pageNum, err)
+		}
+		if !rendered.straightened.IsZero() {
+			logging.Module("image").Debug("Straightened page", "page", pageNum, "rotation", rendered.straightened.Rotation, "skew", rendered.straightened.Skew)
+			pdfPage.Straightened = rendered.straightened
+		}
+		pdfPage.ImageData = rendered.data
+		pdfPage.Blank = rendered.blank
 		pdfPage.HasImage = true
 	}
 
+	if pageType == PageTypeText {
+		p.measurePage(instance, doc, &pdfPage)
+
+		// Pull out inline figures so text pages don't lose their illustrations
+		if !p.skipFigures {
+			p.extractFigures(instance, doc, &pdfPage)
+		}
+
+		// Dedications and epigraphs are set centered; keep them that way
+		if pdfPage.HasText && pageNum <= frontMatterPageLimit && !p.skipLayout {
+			pdfPage.Centered = isCenteredPage(instance, doc, &pdfPage)
+		}
+		if pdfPage.HasText && !p.skipLayout {
+			pdfPage.Vertical = isVerticalPage(instance, doc, &pdfPage)
+		}
+	}
+
 	return pdfPage, nil
 }
 
+// measurePage fills in the real page dimensions, keeping the defaults if PDFium can't tell
+func (p *PDFProcessor) measurePage(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pdfPage *PDFPage) {
+	page := requests.Page{
+		ByIndex: &requests.PageByIndex{
+			Document: doc,
+			Index:    pdfPage.Number - 1,
+		},
+	}
+
+	widthResp, err := instance.FPDF_GetPageWidthF(&requests.FPDF_GetPageWidthF{Page: page})
+	if err != nil {
+		return
+	}
+	heightResp, err := instance.FPDF_GetPageHeightF(&requests.FPDF_GetPageHeightF{Page: page})
+	if err != nil {
+		return
+	}
+	pdfPage.Width = float64(widthResp.PageWidth)
+	pdfPage.Height = float64(heightResp.PageHeight)
+}
+
+// pageLabel reads the number a page has in print ("xii", "137") from the PDF's page
+// labels, which front matter and books starting past page 1 need ("" = it has none)
+func pageLabel(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pageIndex int) string {
+	label, err := instance.FPDF_GetPageLabel(&requests.FPDF_GetPageLabel{
+		Document: doc,
+		Page:     pageIndex,
+	})
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(label.Label)
+}
+
+// extractFigures fills in the embedded images for a text page.
+// Failures are non-fatal: the page keeps its text and simply has no figures.
+func (p *PDFProcessor) extractFigures(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pdfPage *PDFPage) {
+	images, err := extractPageImages(instance, doc, pdfPage.Number-1, pdfPage.Width, pdfPage.Height)
+	if err != nil {
+		return
+	}
+
+	pdfPage.Images = images
+	pdfPage.HasImage = len(images) > 0
+}
+
+// renderedPage is a page rendered as an image, and what was found and done on the way
+type renderedPage struct {
+	data         []byte // PNG
+	straightened Straightening
+	blank        bool
+}
+
+// renderPageImage renders a whole page as PNG, for pages that only make sense as pictures
+// (covers, maps, plates). The EPUB generator scales it down to fit the reader, so printed
+// halftones are descreened first to keep them from turning into moiré. Scans put on the
+// glass crooked or the wrong way round are straightened; blank pages are left as they are.
+func renderPageImage(instance pdfium.Pdfium, doc references.FPDF_DOCUMENT, pageIndex int, descreenHalftones, straightenScans bool) (renderedPage, error) {
+	var page renderedPage
+	rendered, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc,
+				Index:    pageIndex,
+			},
+		},
+		DPI: imagePageDPI,
+	})
+	if err != nil {
+		return page, err
+	}
+	defer rendered.Cleanup()
+
+	var img image.Image = rendered.Result.Image
+	page.blank = isBlankImage(img)
+	if descreenHalftones && !page.blank {
+		img = descreen(img)
+	}
+	if straightenScans && !page.blank {
+		img, page.straightened = straighten(img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return page, fmt.Errorf("failed to encode page image: %w", err)
+	}
+	page.data = buf.Bytes()
+
+	return page, nil
+}
+
 // parseSkipPages converts a comma-separated string of page numbers to a map
 func parseSkipPages(skipPagesStr string) (map[int]bool, error) {
 	skipPages := make(map[int]bool)
@@ -561,33 +904,49 @@ func (mc *MarkovChain) calculateSingleCharPenalty(text string) float64 {
 
 // isLikelyBleedThrough detects OCR bleed-through using Markov chain analysis
 func (p *PDFProcessor) isLikelyBleedThrough(pageNum int, text string) bool {
+	if p.disableBleedDetection {
+		return false
+	}
+
 	text = strings.TrimSpace(text)
 	if len(text) < 20 {
 		return false
 	}
 
-	fmt.Printf("DEBUG: Analyzing text: '%.50s...'\n", text)
+	// The chain knows English letter pairs; text in another script would all look garbled
+	if !isLatinText(text) {
+		return false
+	}
 
-	// Use Markov chain to score the text
+	// Use Markov chain to score the text against the configured threshold
 	score := p.markovChain.scoreText(text)
-	fmt.Printf("DEBUG: Markov chain score: %.3f\n", score)
-
-	// Typical scores with enhanced algorithm:
-	// Real English text: around -1.5 to -2.5
-	// Garbled OCR text: around -4.0 to -6.0 or worse
-	threshold := -3.8
-
-	isBleedThrough := score < threshold
-	fmt.Printf("DEBUG: Score %.3f vs threshold %.3f, is bleed-through: %t\n", score, threshold, isBleedThrough)
+	isBleedThrough := score < p.bleedThreshold
+	logging.Module("pdf").Debug("Markov chain score", "page", pageNum, "score", score, "threshold", p.bleedThreshold, "rejected", isBleedThrough)
 
 	// Track pages that were rejected for post-conversion reporting
 	if isBleedThrough {
+		p.mu.Lock()
 		p.rejectedPages = append(p.rejectedPages, pageNum)
+		p.mu.Unlock()
 	}
 
 	return isBleedThrough
 }
 
+// isLatinText reports whether most of a text's letters are Latin
+func isLatinText(text string) bool {
+	latin, other := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.IsLetter(r):
+			other++
+		}
+	}
+	return latin >= other
+}
+
 func cleanText(text string) string {
 	text = strings.ReplaceAll(text, "\r\n", "\n")
 	text = strings.ReplaceAll(text, "\r", "\n")
@@ -608,19 +967,61 @@ func cleanText(text string) string {
 }
 
 func (p *PDFProcessor) GetFileSize() (int64, error) {
-	return int64(len(p.pdfBytes)), nil
+	return p.fileSize, nil
 }
 
 func (p *PDFProcessor) Close() error {
+	if p.documents != nil {
+		p.documents.close()
+	}
 	if p.pool != nil {
 		p.pool.Close()
 	}
+	if p.store != nil {
+		p.store.close()
+	}
+	if p.ocrProcessor != nil {
+		p.ocrProcessor.Close()
+	}
 	return nil
 }
 
-// GetRejectedPages returns the list of pages that were rejected by Markov chain validation
+// ResumedPages returns how many pages were taken from an earlier run's checkpoint
+func (p *PDFProcessor) ResumedPages() int {
+	return p.checkpoint.resumedCount()
+}
+
+// RemoveCheckpoint deletes the checkpoint, once it's no longer needed
+func (p *PDFProcessor) RemoveCheckpoint() {
+	p.checkpoint.remove()
+}
+
+func (p *PDFProcessor) isRejected(pageNum int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Contains(p.rejectedPages, pageNum)
+}
+
+// GetRejectedPages returns the pages that were rejected by Markov chain validation, in order
 func (p *PDFProcessor) GetRejectedPages() []int {
-	return p.rejectedPages
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Pages are processed in parallel, so they're rejected in whatever order they finish
+	rejected := append([]int(nil), p.rejectedPages...)
+	sort.Ints(rejected)
+	return rejected
+}
+
+// degrade warns that a feature was left out because something it needs is missing
+func (p *PDFProcessor) degrade(warning string) {
+	logging.Module("pdf").Warn(warning)
+	p.degraded = append(p.degraded, warning)
+}
+
+// Degraded returns warnings about features left out for a missing dependency
+func (p *PDFProcessor) Degraded() []string {
+	return p.degraded
 }
 
 // ValidateTextContent tests text content against the Markov chain bleed-through detection
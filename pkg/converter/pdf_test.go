@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -166,3 +167,71 @@ func TestPDFPageDimensions(t *testing.T) {
 		})
 	}
 }
+
+func TestBleedThroughDetectionSettings(t *testing.T) {
+	garbled := "xq zjv kpw qzx vbn mkl jhq wzx qpv zkj xnm bvq"
+
+	tests := []struct {
+		name      string
+		processor *PDFProcessor
+		expected  bool
+	}{
+		{
+			name:      "default threshold rejects garbled text",
+			processor: &PDFProcessor{markovChain: NewEnglishMarkovChain(), bleedThreshold: DefaultBleedThreshold},
+			expected:  true,
+		},
+		{
+			name:      "permissive threshold keeps garbled text",
+			processor: &PDFProcessor{markovChain: NewEnglishMarkovChain(), bleedThreshold: -50.0},
+			expected:  false,
+		},
+		{
+			name: "disabled detection keeps garbled text",
+			processor: &PDFProcessor{
+				markovChain:           NewEnglishMarkovChain(),
+				bleedThreshold:        DefaultBleedThreshold,
+				disableBleedDetection: true,
+			},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.processor.isLikelyBleedThrough(1, garbled)
+			if result != test.expected {
+				t.Errorf("isLikelyBleedThrough() = %v, expected %v", result, test.expected)
+			}
+
+			if result && len(test.processor.GetRejectedPages()) != 1 {
+				t.Errorf("Expected rejected page to be recorded, got %v", test.processor.GetRejectedPages())
+			}
+		})
+	}
+}
+
+func TestSelectedPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		pages    string
+		expected string
+	}{
+		{name: "no selection converts everything", pages: "", expected: "[1 2 3 4 5 6]"},
+		{name: "single range", pages: "2-4", expected: "[2 3 4]"},
+		{name: "ranges and pages in order", pages: "5-6,1", expected: "[1 5 6]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pageRange, err := ParsePageRanges(test.pages)
+			if err != nil {
+				t.Fatalf("ParsePageRanges failed: %v", err)
+			}
+			processor := &PDFProcessor{pageCount: 6, pageRange: pageRange}
+			if result := fmt.Sprint(processor.SelectedPages()); result != test.expected {
+				t.Errorf("SelectedPages() = %s, expected %s", result, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/responses"
+)
+
+// infoSampleSize is how many pages are looked at for the text layer and fonts in Info;
+// more than for telling a scan apart, since the fonts of one chapter aren't another's
+const infoSampleSize = 12
+
+// PDFInfo is what publify info reports about a PDF, to help pick conversion flags
+type PDFInfo struct {
+	PageCount int
+
+	// Pages with a text layer out of the pages sampled
+	TextPages    int
+	SampledPages int
+
+	Encrypted bool
+	CanCopy   bool // The permissions allow copying or extracting text
+	CanPrint  bool
+
+	// Fonts used by the text on the sampled pages, by name
+	Fonts []PDFFont
+
+	Bookmarks int // Counting nested bookmarks
+}
+
+// PDFFont is a font the PDF's text is set in
+type PDFFont struct {
+	Name     string
+	Embedded bool
+}
+
+// Info looks the PDF over: its pages, text layer, encryption, fonts and bookmarks. The
+// text layer and fonts come from a sample of pages spread through the book, not all of them.
+func (p *PDFProcessor) Info() (PDFInfo, error) {
+	document, err := p.documents.acquire()
+	if err != nil {
+		return PDFInfo{}, err
+	}
+	defer p.documents.release(document)
+	instance, doc := document.instance, document.doc
+
+	info := PDFInfo{PageCount: p.pageCount, CanCopy: true, CanPrint: true}
+
+	revision, err := instance.FPDF_GetSecurityHandlerRevision(&requests.FPDF_GetSecurityHandlerRevision{Document: doc})
+	if err == nil && revision.SecurityHandlerRevision >= 0 {
+		info.Encrypted = true
+		if permissions, err := instance.FPDF_GetDocPermissions(&requests.FPDF_GetDocPermissions{Document: doc}); err == nil {
+			info.CanCopy = permissions.CopyOrExtractText || permissions.ExtractTextAndGraphics
+			info.CanPrint = permissions.PrintDocument
+		}
+	}
+
+	if bookmarks, err := instance.GetBookmarks(&requests.GetBookmarks{Document: doc}); err == nil {
+		info.Bookmarks = countBookmarks(bookmarks.Bookmarks)
+	}
+
+	pages := make([]int, p.pageCount)
+	for i := range pages {
+		pages[i] = i + 1
+	}
+	fonts := make(map[string]bool)
+	for _, page := range samplePages(pages, infoSampleSize) {
+		byIndex := requests.Page{ByIndex: &requests.PageByIndex{Document: doc, Index: page - 1}}
+		info.SampledPages++
+
+		pageText, err := instance.GetPageText(&requests.GetPageText{Page: byIndex})
+		if err == nil && len(strings.TrimSpace(pageText.Text)) >= minTextLayerChars {
+			info.TextPages++
+		}
+
+		objects, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{Page: byIndex})
+		if err != nil {
+			continue
+		}
+		for i := 0; i < objects.Count; i++ {
+			object, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{Page: byIndex, Index: i})
+			if err != nil {
+				continue
+			}
+			objectType, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{PageObject: object.PageObject})
+			if err != nil || objectType.Type != enums.FPDF_PAGEOBJ_TEXT {
+				continue
+			}
+			font, err := instance.FPDFTextObj_GetFont(&requests.FPDFTextObj_GetFont{PageObject: object.PageObject})
+			if err != nil {
+				continue
+			}
+			name, err := instance.FPDFFont_GetBaseFontName(&requests.FPDFFont_GetBaseFontName{Font: font.Font})
+			if err != nil || name.BaseFontName == "" {
+				continue
+			}
+			if _, seen := fonts[name.BaseFontName]; seen {
+				continue
+			}
+			embedded, err := instance.FPDFFont_GetIsEmbedded(&requests.FPDFFont_GetIsEmbedded{Font: font.Font})
+			fonts[name.BaseFontName] = err == nil && embedded.IsEmbedded
+		}
+	}
+	info.Fonts = sortedFonts(fonts)
+
+	return info, nil
+}
+
+// countBookmarks counts bookmarks and the bookmarks nested under them
+func countBookmarks(bookmarks []responses.GetBookmarksBookmark) int {
+	count := len(bookmarks)
+	for _, bookmark := range bookmarks {
+		count += countBookmarks(bookmark.Children)
+	}
+	return count
+}
+
+// sortedFonts lists fonts by name. Subset fonts carry a tag ("ABCDEF+Garamond"), which is
+// left off, so a font subset on several pages is listed once.
+func sortedFonts(fonts map[string]bool) []PDFFont {
+	byName := make(map[string]bool)
+	for name, embedded := range fonts {
+		if tag, rest, ok := strings.Cut(name, "+"); ok && len(tag) == 6 && strings.ToUpper(tag) == tag {
+			name = rest
+		}
+		byName[name] = byName[name] || embedded
+	}
+
+	list := make([]PDFFont, 0, len(byName))
+	for name, embedded := range byName {
+		list = append(list, PDFFont{Name: name, Embedded: embedded})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/klippa-app/go-pdfium/responses"
+)
+
+func TestCountBookmarks(t *testing.T) {
+	bookmarks := []responses.GetBookmarksBookmark{
+		{Title: "Part One", Children: []responses.GetBookmarksBookmark{
+			{Title: "Chapter 1"},
+			{Title: "Chapter 2", Children: []responses.GetBookmarksBookmark{{Title: "Notes"}}},
+		}},
+		{Title: "Index"},
+	}
+
+	if count := countBookmarks(bookmarks); count != 5 {
+		t.Errorf("countBookmarks() = %d, expected 5 counting nested bookmarks", count)
+	}
+	if count := countBookmarks(nil); count != 0 {
+		t.Errorf("countBookmarks(nil) = %d, expected 0", count)
+	}
+}
+
+func TestSortedFonts(t *testing.T) {
+	fonts := sortedFonts(map[string]bool{
+		"ABCDEF+Garamond": true,
+		"GHIJKL+Garamond": true,
+		"Helvetica":       false,
+		"Times+Roman":     false, // Not a subset tag
+	})
+
+	expected := []PDFFont{
+		{Name: "Garamond", Embedded: true},
+		{Name: "Helvetica"},
+		{Name: "Times+Roman"},
+	}
+	if !slices.Equal(fonts, expected) {
+		t.Errorf("sortedFonts() = %v, expected %v", fonts, expected)
+	}
+}
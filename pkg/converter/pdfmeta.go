@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+// PDFMetadata is the bibliographic information stored in a PDF
+type PDFMetadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+
+	// Rights, from the XMP packet only; the information dictionary has no field for them
+	Rights       string // dc:rights
+	UsageTerms   string // xmpRights:UsageTerms
+	RightsMarked bool   // xmpRights:Marked is True
+}
+
+// IsEmpty reports whether the PDF had no usable metadata at all
+func (m PDFMetadata) IsEmpty() bool {
+	return m.Title == "" && m.Author == "" && m.Subject == "" && len(m.Keywords) == 0
+}
+
+// Metadata reads the document information dictionary, filling any gaps from the XMP packet
+func (p *PDFProcessor) Metadata() (PDFMetadata, error) {
+	document, err := p.documents.acquire()
+	if err != nil {
+		return PDFMetadata{}, err
+	}
+	defer p.documents.release(document)
+	instance, doc := document.instance, document.doc
+
+	info := make(map[string]string)
+	for _, tag := range []string{"Title", "Author", "Subject", "Keywords"} {
+		resp, err := instance.FPDF_GetMetaText(&requests.FPDF_GetMetaText{Document: doc, Tag: tag})
+		if err == nil {
+			info[tag] = strings.TrimSpace(resp.Value)
+		}
+	}
+
+	metadata := PDFMetadata{
+		Title:    cleanPDFTitle(info["Title"]),
+		Author:   info["Author"],
+		Subject:  info["Subject"],
+		Keywords: splitKeywords(info["Keywords"]),
+	}
+
+	// PDFium doesn't expose XMP, but the packet is stored uncompressed so it can be found in the raw file
+	packet, err := readXMPPacket(p.filePath)
+	if err != nil {
+		return PDFMetadata{}, err
+	}
+	xmp := parseXMP(packet)
+	if metadata.Title == "" {
+		metadata.Title = cleanPDFTitle(xmp.Title)
+	}
+	if metadata.Author == "" {
+		metadata.Author = xmp.Author
+	}
+	if metadata.Subject == "" {
+		metadata.Subject = xmp.Subject
+	}
+	if len(metadata.Keywords) == 0 {
+		metadata.Keywords = xmp.Keywords
+	}
+	metadata.Rights, metadata.UsageTerms, metadata.RightsMarked = xmp.Rights, xmp.UsageTerms, xmp.RightsMarked
+
+	return metadata, nil
+}
+
+// cleanPDFTitle drops the junk authoring tools put in the Title field
+func cleanPDFTitle(title string) string {
+	title = strings.TrimSpace(title)
+
+	// "Microsoft Word - thesis_final.docx" and friends
+	for _, prefix := range []string{"Microsoft Word - ", "Microsoft PowerPoint - "} {
+		title = strings.TrimPrefix(title, prefix)
+	}
+
+	switch strings.ToLower(title) {
+	case "untitled", "untitled document", "document":
+		return ""
+	}
+
+	return title
+}
+
+// splitKeywords splits a keyword string on commas or semicolons, dropping URLs
+// (scanning services like to put the source link there)
+func splitKeywords(keywords string) []string {
+	var result []string
+	for _, keyword := range strings.FieldsFunc(keywords, func(r rune) bool { return r == ',' || r == ';' }) {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" || strings.HasPrefix(keyword, "http://") || strings.HasPrefix(keyword, "https://") {
+			continue
+		}
+		result = append(result, keyword)
+	}
+	return result
+}
+
+// xmpDescription matches the rdf:Description elements carrying Dublin Core and PDF properties.
+// Field tags without a namespace match any namespace, which is what we want here.
+type xmpDescription struct {
+	Title        []string `xml:"title>Alt>li"`
+	Creators     []string `xml:"creator>Seq>li"`
+	Description  []string `xml:"description>Alt>li"`
+	Subjects     []string `xml:"subject>Bag>li"`
+	Keywords     string   `xml:"Keywords"`
+	KeywordsAttr string   `xml:"Keywords,attr"`
+	Rights       []string `xml:"rights>Alt>li"`
+	UsageTerms   []string `xml:"UsageTerms>Alt>li"`
+	Marked       string   `xml:"Marked"`
+	MarkedAttr   string   `xml:"Marked,attr"`
+}
+
+type xmpPacket struct {
+	Descriptions []xmpDescription `xml:"RDF>Description"`
+}
+
+// xmpReadSize is how much of the PDF is scanned for XMP packets at a time
+const xmpReadSize = 1 << 20
+
+// maxXMPPacketSize gives up on a packet that never ends, rather than reading the rest of the
+// file into memory looking for its end
+const maxXMPPacketSize = 4 << 20
+
+var (
+	xmpStartTag = []byte("<x:xmpmeta")
+	xmpEndTag   = []byte("</x:xmpmeta>")
+)
+
+// readXMPPacket returns the last complete XMP packet in a PDF, or nil if it has none. The
+// file is read in chunks, since scanned books can be far larger than what's worth holding.
+func readXMPPacket(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	defer file.Close()
+
+	var last, pending []byte
+	inPacket := false
+	chunk := make([]byte, xmpReadSize)
+	for {
+		n, readErr := file.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+
+		for {
+			if !inPacket {
+				start := bytes.Index(pending, xmpStartTag)
+				if start == -1 {
+					// Keep enough to find a start tag split across two reads
+					if keep := len(xmpStartTag) - 1; len(pending) > keep {
+						pending = append(pending[:0], pending[len(pending)-keep:]...)
+					}
+					break
+				}
+				pending = pending[start:]
+				inPacket = true
+			}
+
+			end := bytes.Index(pending, xmpEndTag)
+			if end == -1 {
+				if len(pending) > maxXMPPacketSize {
+					inPacket = false
+					pending = pending[len(xmpStartTag):]
+					continue
+				}
+				break
+			}
+			end += len(xmpEndTag)
+			last = append(last[:0], pending[:end]...)
+			pending = pending[end:]
+			inPacket = false
+		}
+
+		if readErr == io.EOF {
+			return last, nil
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read PDF file: %w", readErr)
+		}
+	}
+}
+
+// parseXMP extracts metadata from the last (most recent) XMP packet in a PDF
+func parseXMP(pdfBytes []byte) PDFMetadata {
+	start := bytes.LastIndex(pdfBytes, []byte("<x:xmpmeta"))
+	if start == -1 {
+		return PDFMetadata{}
+	}
+	end := bytes.Index(pdfBytes[start:], []byte("</x:xmpmeta>"))
+	if end == -1 {
+		return PDFMetadata{}
+	}
+
+	var packet xmpPacket
+	if err := xml.Unmarshal(pdfBytes[start:start+end+len("</x:xmpmeta>")], &packet); err != nil {
+		return PDFMetadata{}
+	}
+
+	var metadata PDFMetadata
+	for _, desc := range packet.Descriptions {
+		if metadata.Title == "" && len(desc.Title) > 0 {
+			metadata.Title = strings.TrimSpace(desc.Title[0])
+		}
+		if metadata.Author == "" && len(desc.Creators) > 0 {
+			metadata.Author = strings.TrimSpace(strings.Join(desc.Creators, ", "))
+		}
+		if metadata.Subject == "" && len(desc.Description) > 0 {
+			metadata.Subject = strings.TrimSpace(desc.Description[0])
+		}
+		for _, subject := range desc.Subjects {
+			metadata.Keywords = append(metadata.Keywords, splitKeywords(subject)...)
+		}
+		metadata.Keywords = append(metadata.Keywords, splitKeywords(desc.Keywords+";"+desc.KeywordsAttr)...)
+		if metadata.Rights == "" && len(desc.Rights) > 0 {
+			metadata.Rights = strings.TrimSpace(desc.Rights[0])
+		}
+		if metadata.UsageTerms == "" && len(desc.UsageTerms) > 0 {
+			metadata.UsageTerms = strings.TrimSpace(desc.UsageTerms[0])
+		}
+		if strings.EqualFold(strings.TrimSpace(desc.Marked+desc.MarkedAttr), "true") {
+			metadata.RightsMarked = true
+		}
+	}
+
+	return metadata
+}
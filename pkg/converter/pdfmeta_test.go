@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseXMP(t *testing.T) {
+	pdf := []byte(`%PDF-1.7
+1 0 obj << /Type /Metadata /Subtype /XML >> stream
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" pdf:Keywords="drama; tragedy, https://example.com/book"/>
+    <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+      <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Romeo and Juliet</rdf:li></rdf:Alt></dc:title>
+      <dc:creator><rdf:Seq><rdf:li>William Shakespeare</rdf:li></rdf:Seq></dc:creator>
+      <dc:description><rdf:Alt><rdf:li xml:lang="x-default">A play</rdf:li></rdf:Alt></dc:description>
+      <dc:subject><rdf:Bag><rdf:li>Verona</rdf:li></rdf:Bag></dc:subject>
+      <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">© 2021 Example Press. All rights reserved.</rdf:li></rdf:Alt></dc:rights>
+    </rdf:Description>
+    <rdf:Description rdf:about="" xmlns:xmpRights="http://ns.adobe.com/xap/1.0/rights/" xmpRights:Marked="True">
+      <xmpRights:UsageTerms><rdf:Alt><rdf:li xml:lang="x-default">For personal use only</rdf:li></rdf:Alt></xmpRights:UsageTerms>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>
+endstream endobj`)
+
+	expected := PDFMetadata{
+		Title:    "Romeo and Juliet",
+		Author:   "William Shakespeare",
+		Subject:  "A play",
+		Keywords: []string{"drama", "tragedy", "Verona"},
+
+		Rights:       "© 2021 Example Press. All rights reserved.",
+		UsageTerms:   "For personal use only",
+		RightsMarked: true,
+	}
+
+	if result := parseXMP(pdf); !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseXMP() = %+v, expected %+v", result, expected)
+	}
+
+	if result := parseXMP([]byte("%PDF-1.4 no metadata here")); !result.IsEmpty() {
+		t.Errorf("Expected empty metadata without an XMP packet, got %+v", result)
+	}
+}
+
+func TestReadXMPPacket(t *testing.T) {
+	older := `<x:xmpmeta><rdf:RDF/>old</x:xmpmeta>`
+	newer := `<x:xmpmeta><rdf:RDF/>new</x:xmpmeta>`
+
+	// The older packet straddles the first chunk boundary, the newer one comes after it
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.7\n")
+	pdf.Write(bytes.Repeat([]byte("x"), xmpReadSize-pdf.Len()-5))
+	pdf.WriteString(older)
+	pdf.Write(bytes.Repeat([]byte("y"), xmpReadSize))
+	pdf.WriteString(newer)
+	pdf.WriteString("\n%%EOF")
+
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, pdf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := readXMPPacket(path)
+	if err != nil {
+		t.Fatalf("readXMPPacket failed: %v", err)
+	}
+	if string(packet) != newer {
+		t.Errorf("readXMPPacket() = %q, expected %q", packet, newer)
+	}
+
+	if err := os.WriteFile(path, []byte("%PDF-1.4 no metadata here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if packet, err := readXMPPacket(path); err != nil || packet != nil {
+		t.Errorf("Expected no packet, got %q (%v)", packet, err)
+	}
+}
+
+func TestCleanPDFTitle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Air Babylon", "Air Babylon"},
+		{"Microsoft Word - thesis_final.docx", "thesis_final.docx"},
+		{"  Untitled ", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if result := cleanPDFTitle(test.input); result != test.expected {
+			t.Errorf("cleanPDFTitle(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alde/publify/internal/console"
+)
+
+// maxOpeningLength is how much of a chapter's first line the plan shows
+const maxOpeningLength = 50
+
+// ChapterPlan describes one chapter the conversion would produce
+type ChapterPlan struct {
+	Title     string
+	FirstPage int
+	LastPage  int
+	PageCount int
+	Opening   string // First line of text, to check the chapter breaks against the book
+}
+
+// ConversionPlan is what a dry run found: the chapters and the pages worth a second look
+type ConversionPlan struct {
+	Chapters      []ChapterPlan
+	ImagePages    []int // Pages kept as images, whether asked for or detected
+	RejectedPages []int // Pages dropped by bleed-through detection
+}
+
+// buildPlan groups the pages into chapters the same way generateEPUB does
+func (c *Converter) buildPlan(pages []PDFPage) ConversionPlan {
+	var plan ConversionPlan
+
+	for i, chapter := range c.groupPagesIntoChapters(pages) {
+		plan.Chapters = append(plan.Chapters, ChapterPlan{
+			Title:     fmt.Sprintf("Chapter %d", i+1),
+			FirstPage: chapter[0].Number,
+			LastPage:  chapter[len(chapter)-1].Number,
+			PageCount: len(chapter),
+			Opening:   chapterOpening(chapter),
+		})
+	}
+
+	for _, page := range pages {
+		if page.PageType == PageTypeImage {
+			plan.ImagePages = append(plan.ImagePages, page.Number)
+		}
+	}
+	if c.pdfProc != nil {
+		plan.RejectedPages = c.pdfProc.GetRejectedPages()
+	}
+
+	return plan
+}
+
+// chapterOpening returns the first line of text in a chapter, shortened for display
+func chapterOpening(chapter []PDFPage) string {
+	for _, page := range chapter {
+		for _, line := range strings.Split(page.Text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if runes := []rune(line); len(runes) > maxOpeningLength {
+				line = string(runes[:maxOpeningLength-1]) + "…"
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// displayPlan prints the chapter plan for a dry run
+func (c *Converter) displayPlan(plan ConversionPlan) {
+	console.Displayf("\nConversion Plan (dry run, nothing written)\n")
+	console.Displayf("================================================================\n")
+
+	for _, chapter := range plan.Chapters {
+		pages := fmt.Sprintf("page %d", chapter.FirstPage)
+		if chapter.LastPage != chapter.FirstPage {
+			pages = fmt.Sprintf("pages %d-%d", chapter.FirstPage, chapter.LastPage)
+		}
+		console.Displayf("%-12s %-16s (%s)", chapter.Title, pages, pluralPages(chapter.PageCount))
+		if chapter.Opening != "" {
+			console.Displayf("  %q", chapter.Opening)
+		}
+		console.Displayf("\n")
+	}
+
+	console.Displayf("================================================================\n")
+	console.Displayf("Chapters:      %d\n", len(plan.Chapters))
+	if len(plan.ImagePages) > 0 {
+		console.Displayf("Image pages:   %s\n", compactPageList(plan.ImagePages))
+	}
+	if len(plan.RejectedPages) > 0 {
+		console.Displayf("Bleed-through: %s\n", compactPageList(plan.RejectedPages))
+		console.Displayf("Suggestion: Consider adding --skip \"%s\" for faster processing\n", formatPageList(plan.RejectedPages))
+	}
+}
+
+// pluralPages says how many pages there are, "1 page" or "3 pages"
+func pluralPages(n int) string {
+	if n == 1 {
+		return "1 page"
+	}
+	return fmt.Sprintf("%d pages", n)
+}
+
+// compactPageList formats sorted page numbers with runs collapsed, like "1-2,5,10-15"
+func compactPageList(pages []int) string {
+	set := &PageRangeSet{}
+	for _, page := range pages {
+		if n := len(set.ranges); n > 0 && set.ranges[n-1].End == page-1 {
+			set.ranges[n-1].End = page
+			continue
+		}
+		set.ranges = append(set.ranges, PageRange{Start: page, End: page})
+	}
+	return set.String()
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuildPlan(t *testing.T) {
+	converter := New(Options{})
+
+	var pages []PDFPage
+	for i := 1; i <= 20; i++ {
+		pages = append(pages, PDFPage{Number: i + 9, Text: fmt.Sprintf("Page %d of a long story.", i+9), HasText: true})
+	}
+	pages[0].PageType = PageTypeImage
+	pages[1].PageType = PageTypeImage
+
+	plan := converter.buildPlan(pages)
+
+	if len(plan.Chapters) == 0 {
+		t.Fatal("Expected at least one chapter")
+	}
+	first := plan.Chapters[0]
+	if first.Title != "Chapter 1" || first.FirstPage != 10 {
+		t.Errorf("Unexpected first chapter: %+v", first)
+	}
+	if first.Opening != "Page 10 of a long story." {
+		t.Errorf("Expected the chapter's first line as its opening, got %q", first.Opening)
+	}
+
+	total := 0
+	for _, chapter := range plan.Chapters {
+		total += chapter.PageCount
+	}
+	if total != len(pages) {
+		t.Errorf("Chapters cover %d pages, expected %d", total, len(pages))
+	}
+
+	if got := compactPageList(plan.ImagePages); got != "10-11" {
+		t.Errorf("Expected image pages 10-11, got %s", got)
+	}
+}
+
+func TestCompactPageList(t *testing.T) {
+	if got := compactPageList([]int{1, 2, 5, 10, 11, 12, 15}); got != "1-2,5,10-12,15" {
+		t.Errorf("compactPageList() = %s", got)
+	}
+	if got := compactPageList(nil); got != "" {
+		t.Errorf("compactPageList(nil) = %q, expected empty", got)
+	}
+}
@@ -0,0 +1,270 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// contrastClip is the share of pixels at each end of the histogram that contrast
+	// normalization lets go to pure black or white, so a few specks don't set the range
+	contrastClip = 0.005
+	// maxSkew is the largest tilt deskewing looks for, in degrees. Scans tilted further
+	// than that were put on the glass sideways, which is a different problem.
+	maxSkew     = 5.0
+	skewStep    = 0.1
+	minSkew     = 0.2 // Smaller tilts aren't worth the blur of rotating
+	skewSamples = 800 // Width the page is scaled to for measuring its tilt
+	// speckleArea is the largest patch of ink despeckling removes, in pixels at the 300 DPI
+	// pages are rendered at for OCR. A full stop is about three times that.
+	speckleArea = 4
+)
+
+// OCRPreprocessing are the clean-up steps a page image goes through before OCR. Raw renders
+// of poor scans (gray paper, faint ink, a tilt, dust) read badly, and the garbled text
+// that comes out then gets thrown away as bleed-through.
+type OCRPreprocessing struct {
+	Contrast  bool // Stretch the gray levels so the paper is white and the ink black
+	Rotate    bool // Turn pages scanned sideways or upside down the right way up
+	Deskew    bool // Straighten tilted pages
+	Binarize  bool // Reduce the page to black and white
+	Despeckle bool // Remove specks of dust and noise (after binarizing)
+}
+
+// ocrPreprocessingSteps are the step names, in the order they're applied
+var ocrPreprocessingSteps = []string{"contrast", "rotate", "deskew", "binarize", "despeckle"}
+
+// ParseOCRPreprocessing reads a comma-separated list of steps: contrast, rotate, deskew,
+// binarize and despeckle, or "all" or "none". An empty string means all of them.
+func ParseOCRPreprocessing(spec string) (OCRPreprocessing, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	switch spec {
+	case "", "all":
+		return OCRPreprocessing{Contrast: true, Rotate: true, Deskew: true, Binarize: true, Despeckle: true}, nil
+	case "none":
+		return OCRPreprocessing{}, nil
+	}
+
+	var pp OCRPreprocessing
+	for _, step := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(step) {
+		case "contrast":
+			pp.Contrast = true
+		case "rotate":
+			pp.Rotate = true
+		case "deskew":
+			pp.Deskew = true
+		case "binarize":
+			pp.Binarize = true
+		case "despeckle":
+			pp.Despeckle = true
+		default:
+			return OCRPreprocessing{}, fmt.Errorf("unknown OCR preprocessing step %q (use %s, all or none)",
+				strings.TrimSpace(step), strings.Join(ocrPreprocessingSteps, ", "))
+		}
+	}
+	return pp, nil
+}
+
+// String lists the steps in the form ParseOCRPreprocessing reads
+func (pp OCRPreprocessing) String() string {
+	var steps []string
+	for i, enabled := range []bool{pp.Contrast, pp.Rotate, pp.Deskew, pp.Binarize, pp.Despeckle} {
+		if enabled {
+			steps = append(steps, ocrPreprocessingSteps[i])
+		}
+	}
+	if len(steps) == 0 {
+		return "none"
+	}
+	return strings.Join(steps, ",")
+}
+
+// IsZero reports whether no steps are enabled
+func (pp OCRPreprocessing) IsZero() bool {
+	return pp == OCRPreprocessing{}
+}
+
+// apply runs the enabled steps on a page image, and says how it was straightened. The
+// result is grayscale, which is all Tesseract looks at anyway.
+func (pp OCRPreprocessing) apply(img image.Image) (image.Image, Straightening) {
+	var straightened Straightening
+	if pp.IsZero() {
+		return img, straightened
+	}
+
+	gray := grayImage(img)
+	if pp.Contrast {
+		gray = normalizeContrast(gray)
+	}
+	switch {
+	case pp.Rotate:
+		// Straightening levels the page too, once it's the right way up
+		var turned image.Image
+		turned, straightened = straighten(gray)
+		if !pp.Deskew && straightened.Skew != 0 {
+			turned, straightened = turnClockwise(gray, straightened.Rotation), Straightening{Rotation: straightened.Rotation}
+		}
+		gray = grayImage(turned)
+	case pp.Deskew:
+		if angle := estimateSkew(gray); math.Abs(angle) >= minSkew {
+			gray = grayImage(imaging.Rotate(gray, -angle, color.White))
+			straightened.Skew = angle
+		}
+	}
+	if pp.Binarize || pp.Despeckle {
+		gray = threshold(gray, otsuThreshold(grayHistogram(gray)))
+	}
+	if pp.Despeckle {
+		despeckle(gray)
+	}
+	return gray, straightened
+}
+
+// normalizeContrast stretches the gray levels so the darkest ink is black and the paper
+// white, leaving out the extreme contrastClip at either end
+func normalizeContrast(gray *image.Gray) *image.Gray {
+	histogram := grayHistogram(gray)
+	total := gray.Bounds().Dx() * gray.Bounds().Dy()
+	clip := int(float64(total) * contrastClip)
+
+	low, count := 0, 0
+	for ; low < 255; low++ {
+		count += histogram[low]
+		if count > clip {
+			break
+		}
+	}
+	high, count := 255, 0
+	for ; high > 0; high-- {
+		count += histogram[high]
+		if count > clip {
+			break
+		}
+	}
+	if high-low < 16 || (low == 0 && high == 255) {
+		return gray // Blank, or nothing to stretch
+	}
+
+	var table [256]uint8
+	for value := range table {
+		table[value] = clampByte(float64(value-low) * 255 / float64(high-low))
+	}
+
+	result := image.NewGray(gray.Bounds())
+	for i, value := range gray.Pix {
+		result.Pix[i] = table[value]
+	}
+	return result
+}
+
+// estimateSkew measures how far the lines of text on a page are tilted counter-clockwise,
+// in degrees, by finding the angle at which the ink lines up in the sharpest rows
+func estimateSkew(gray *image.Gray) float64 {
+	small := grayImage(imaging.Resize(gray, min(skewSamples, gray.Bounds().Dx()), 0, imaging.Box))
+	cutoff := otsuThreshold(grayHistogram(small))
+
+	bounds := small.Bounds()
+	var ink []image.Point
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if small.GrayAt(x, y).Y <= cutoff {
+				ink = append(ink, image.Point{x - bounds.Min.X, y - bounds.Min.Y})
+			}
+		}
+	}
+	if len(ink) == 0 || len(ink) > bounds.Dx()*bounds.Dy()/2 {
+		return 0 // Blank, or not ink on paper
+	}
+
+	// Rows may shift by up to width*sin(maxSkew) either way
+	margin := int(float64(bounds.Dx())*math.Sin(maxSkew*math.Pi/180)) + 1
+	rows := make([]int, bounds.Dy()+2*margin)
+
+	best, bestScore := 0.0, -1.0
+	for step := -int(maxSkew / skewStep); step <= int(maxSkew/skewStep); step++ {
+		angle := float64(step) * skewStep
+		tan := math.Tan(angle * math.Pi / 180)
+
+		clear(rows)
+		for _, p := range ink {
+			row := p.Y + margin - int(math.Round(float64(p.X)*tan))
+			if row >= 0 && row < len(rows) {
+				rows[row]++
+			}
+		}
+
+		// Straight lines of text give tall peaks with gaps between them
+		score := 0.0
+		for i := 1; i < len(rows); i++ {
+			d := float64(rows[i] - rows[i-1])
+			score += d * d
+		}
+		if score > bestScore {
+			best, bestScore = angle, score
+		}
+	}
+
+	// Lines that line up sloping down to the right are tilted clockwise
+	return -best
+}
+
+// threshold reduces a grayscale image to pure black and white
+func threshold(gray *image.Gray, level uint8) *image.Gray {
+	result := image.NewGray(gray.Bounds())
+	for i, value := range gray.Pix {
+		if value > level {
+			result.Pix[i] = 255
+		}
+	}
+	return result
+}
+
+// despeckle whitens patches of ink no bigger than speckleArea in a black and white image
+func despeckle(bw *image.Gray) {
+	bounds := bw.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	seen := make([]bool, width*height)
+	var patch, stack []int
+
+	for start := range seen {
+		if seen[start] || bw.Pix[start/width*bw.Stride+start%width] != 0 {
+			continue
+		}
+
+		// Flood fill the patch of ink this pixel belongs to
+		patch, stack = patch[:0], append(stack[:0], start)
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			patch = append(patch, i)
+
+			x, y := i%width, i/width
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					j := ny*width + nx
+					if !seen[j] && bw.Pix[ny*bw.Stride+nx] == 0 {
+						seen[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+
+		if len(patch) <= speckleArea {
+			for _, i := range patch {
+				bw.Pix[i/width*bw.Stride+i%width] = 255
+			}
+		}
+	}
+}
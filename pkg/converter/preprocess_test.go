@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// textPage draws rows of word-sized blocks, like lines of text on a page
+func textPage(width, height int) *image.Gray {
+	page := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.NewUniform(color.Gray{Y: 235}), image.Point{}, draw.Src)
+	ink := image.NewUniform(color.Gray{Y: 40})
+	for y := 100; y+30 < height-100; y += 60 {
+		for x := 100; x+90 < width-100; x += 110 {
+			draw.Draw(page, image.Rect(x, y, x+90, y+30), ink, image.Point{}, draw.Src)
+		}
+	}
+	return page
+}
+
+func TestEstimateSkew(t *testing.T) {
+	page := textPage(1200, 1600)
+	if angle := estimateSkew(page); math.Abs(angle) > 0.15 {
+		t.Errorf("Straight page measured as tilted %.2f°", angle)
+	}
+
+	for _, tilt := range []float64{2, -3.5} {
+		tilted := grayImage(imaging.Rotate(page, tilt, color.Gray{Y: 235}))
+		angle := estimateSkew(tilted)
+		if math.Abs(angle-tilt) > 0.25 {
+			t.Errorf("Page tilted %.1f° measured as %.2f°", tilt, angle)
+		}
+
+		straightened, done := OCRPreprocessing{Deskew: true}.apply(tilted)
+		if math.Abs(done.Skew-tilt) > 0.25 {
+			t.Errorf("Page tilted %.1f° reported as straightened by %.2f°", tilt, done.Skew)
+		}
+		if angle := estimateSkew(grayImage(straightened)); math.Abs(angle) > 0.25 {
+			t.Errorf("Page tilted %.1f° still tilted %.2f° after deskewing", tilt, angle)
+		}
+	}
+}
+
+func TestNormalizeContrast(t *testing.T) {
+	page := textPage(600, 800)
+	normalized := normalizeContrast(page)
+
+	histogram := grayHistogram(normalized)
+	if histogram[0] == 0 || histogram[255] == 0 {
+		t.Error("Expected ink stretched to black and paper to white")
+	}
+}
+
+func TestDespeckle(t *testing.T) {
+	page := image.NewGray(image.Rect(0, 0, 50, 50))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	page.SetGray(5, 5, color.Gray{})                                                  // A speck
+	draw.Draw(page, image.Rect(20, 20, 22, 22), image.Black, image.Point{}, draw.Src) // A bigger speck
+	draw.Draw(page, image.Rect(30, 30, 33, 33), image.Black, image.Point{}, draw.Src) // A full stop
+	draw.Draw(page, image.Rect(10, 40, 40, 42), image.Black, image.Point{}, draw.Src) // A stroke
+
+	despeckle(page)
+
+	for _, tt := range []struct {
+		at   image.Point
+		kept bool
+	}{{image.Pt(5, 5), false}, {image.Pt(21, 21), false}, {image.Pt(31, 31), true}, {image.Pt(25, 41), true}} {
+		if kept := page.GrayAt(tt.at.X, tt.at.Y).Y == 0; kept != tt.kept {
+			t.Errorf("Ink at %v kept = %v, expected %v", tt.at, kept, tt.kept)
+		}
+	}
+}
+
+func TestParseOCRPreprocessing(t *testing.T) {
+	all, err := ParseOCRPreprocessing("")
+	if err != nil || all.String() != "contrast,rotate,deskew,binarize,despeckle" {
+		t.Errorf("Default = %v (%v), expected every step", all, err)
+	}
+
+	none, err := ParseOCRPreprocessing("none")
+	if err != nil || !none.IsZero() {
+		t.Errorf("none = %v (%v), expected no steps", none, err)
+	}
+
+	some, err := ParseOCRPreprocessing("Deskew, contrast")
+	if err != nil || some.String() != "contrast,deskew" {
+		t.Errorf("Deskew, contrast = %v (%v)", some, err)
+	}
+
+	if _, err := ParseOCRPreprocessing("deskew,sharpen"); err == nil {
+		t.Error("Expected an error for an unknown step")
+	}
+}
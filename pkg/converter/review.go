@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChapterReview holds the proposed chapters while the user edits them. Chapters are
+// numbered from 1, as shown to the user; chapters without a title of their own are
+// numbered by position, so merging and splitting renumbers them.
+type ChapterReview struct {
+	pages    map[int]PDFPage
+	chapters []reviewChapter
+	dropped  []int
+}
+
+type reviewChapter struct {
+	title string // "" = "Chapter N"
+	pages []int
+}
+
+// newChapterReview starts a review from the automatic grouping
+func newChapterReview(groups [][]PDFPage) *ChapterReview {
+	review := &ChapterReview{pages: make(map[int]PDFPage)}
+	for _, group := range groups {
+		var chapter reviewChapter
+		for _, page := range group {
+			review.pages[page.Number] = page
+			chapter.pages = append(chapter.pages, page.Number)
+		}
+		review.chapters = append(review.chapters, chapter)
+	}
+	return review
+}
+
+// Chapters describes the chapters as they currently stand
+func (r *ChapterReview) Chapters() []ChapterPlan {
+	plans := make([]ChapterPlan, len(r.chapters))
+	for i := range r.chapters {
+		pages := r.chapterPages(i)
+		plans[i] = ChapterPlan{
+			Title:     r.title(i),
+			FirstPage: pages[0].Number,
+			LastPage:  pages[len(pages)-1].Number,
+			PageCount: len(pages),
+			Opening:   chapterOpening(pages),
+		}
+	}
+	return plans
+}
+
+// Dropped returns the pages left out of the book, in order
+func (r *ChapterReview) Dropped() []int {
+	return r.dropped
+}
+
+// Merge joins chapter n with the one after it, keeping chapter n's title
+func (r *ChapterReview) Merge(n int) error {
+	if err := r.checkChapter(n); err != nil {
+		return err
+	}
+	if n == len(r.chapters) {
+		return fmt.Errorf("chapter %d is the last chapter, there is nothing to merge it with", n)
+	}
+
+	i := n - 1
+	r.chapters[i].pages = append(r.chapters[i].pages, r.chapters[i+1].pages...)
+	r.chapters = append(r.chapters[:i+1], r.chapters[i+2:]...)
+	return nil
+}
+
+// Split starts a new chapter at the given page
+func (r *ChapterReview) Split(page int) error {
+	i, index := r.findPage(page)
+	if i < 0 {
+		return fmt.Errorf("page %d isn't in any chapter", page)
+	}
+	if index == 0 {
+		return fmt.Errorf("page %d already starts chapter %d", page, i+1)
+	}
+
+	head := append([]int(nil), r.chapters[i].pages[:index]...)
+	tail := append([]int(nil), r.chapters[i].pages[index:]...)
+	r.chapters[i].pages = head
+	r.chapters = append(r.chapters[:i+1], append([]reviewChapter{{pages: tail}}, r.chapters[i+1:]...)...)
+	return nil
+}
+
+// Rename sets the title of chapter n. An empty title goes back to "Chapter N".
+func (r *ChapterReview) Rename(n int, title string) error {
+	if err := r.checkChapter(n); err != nil {
+		return err
+	}
+	r.chapters[n-1].title = title
+	return nil
+}
+
+// Drop leaves a page out of the book. A chapter that loses all its pages goes with them.
+func (r *ChapterReview) Drop(page int) error {
+	i, index := r.findPage(page)
+	if i < 0 {
+		return fmt.Errorf("page %d isn't in any chapter", page)
+	}
+
+	chapter := &r.chapters[i]
+	chapter.pages = append(chapter.pages[:index], chapter.pages[index+1:]...)
+	if len(chapter.pages) == 0 {
+		r.chapters = append(r.chapters[:i], r.chapters[i+1:]...)
+	}
+
+	r.dropped = append(r.dropped, page)
+	sort.Ints(r.dropped)
+	return nil
+}
+
+// result returns the chapter titles and pages as edited
+func (r *ChapterReview) result() ([]string, [][]PDFPage) {
+	titles := make([]string, len(r.chapters))
+	groups := make([][]PDFPage, len(r.chapters))
+	for i := range r.chapters {
+		titles[i] = r.title(i)
+		groups[i] = r.chapterPages(i)
+	}
+	return titles, groups
+}
+
+func (r *ChapterReview) title(i int) string {
+	if r.chapters[i].title != "" {
+		return r.chapters[i].title
+	}
+	return fmt.Sprintf("Chapter %d", i+1)
+}
+
+func (r *ChapterReview) chapterPages(i int) []PDFPage {
+	pages := make([]PDFPage, len(r.chapters[i].pages))
+	for j, number := range r.chapters[i].pages {
+		pages[j] = r.pages[number]
+	}
+	return pages
+}
+
+// findPage returns the chapter holding a page and the page's position in it, or -1
+func (r *ChapterReview) findPage(page int) (int, int) {
+	for i, chapter := range r.chapters {
+		for j, number := range chapter.pages {
+			if number == page {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+func (r *ChapterReview) checkChapter(n int) error {
+	if n < 1 || n > len(r.chapters) {
+		return fmt.Errorf("no chapter %d (there are %d)", n, len(r.chapters))
+	}
+	return nil
+}
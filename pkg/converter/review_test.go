@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func reviewPages(numbers ...int) []PDFPage {
+	var pages []PDFPage
+	for _, n := range numbers {
+		pages = append(pages, PDFPage{Number: n, Text: fmt.Sprintf("Page %d", n), HasText: true})
+	}
+	return pages
+}
+
+func TestChapterReviewEdits(t *testing.T) {
+	review := newChapterReview([][]PDFPage{reviewPages(1, 2, 3), reviewPages(4, 5), reviewPages(6)})
+
+	if err := review.Merge(2); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := review.Split(2); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if err := review.Rename(3, "The Long Way Home"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := review.Drop(1); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+
+	titles, chapters := review.result()
+	got := fmt.Sprint(titles)
+	if want := "[Chapter 1 The Long Way Home]"; got != want {
+		t.Errorf("Titles = %s, expected %s", got, want)
+	}
+
+	var pages [][]int
+	for _, chapter := range chapters {
+		var numbers []int
+		for _, page := range chapter {
+			numbers = append(numbers, page.Number)
+		}
+		pages = append(pages, numbers)
+	}
+	if got, want := fmt.Sprint(pages), "[[2 3] [4 5 6]]"; got != want {
+		t.Errorf("Chapter pages = %s, expected %s", got, want)
+	}
+	if got := fmt.Sprint(review.Dropped()); got != "[1]" {
+		t.Errorf("Dropped = %s, expected [1]", got)
+	}
+
+	plans := review.Chapters()
+	if plans[1].FirstPage != 4 || plans[1].LastPage != 6 || plans[1].Opening != "Page 4" {
+		t.Errorf("Unexpected chapter plan: %+v", plans[1])
+	}
+}
+
+func TestChapterReviewErrors(t *testing.T) {
+	review := newChapterReview([][]PDFPage{reviewPages(1, 2), reviewPages(3)})
+
+	if err := review.Merge(2); err == nil {
+		t.Error("Expected an error merging the last chapter")
+	}
+	if err := review.Rename(5, "Nope"); err == nil {
+		t.Error("Expected an error renaming a missing chapter")
+	}
+	if err := review.Split(3); err == nil {
+		t.Error("Expected an error splitting at a chapter's first page")
+	}
+	if err := review.Drop(9); err == nil {
+		t.Error("Expected an error dropping a page that isn't there")
+	}
+
+	// Dropping a chapter's only page removes the chapter
+	if err := review.Drop(3); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+	if len(review.Chapters()) != 1 {
+		t.Errorf("Expected 1 chapter left, got %d", len(review.Chapters()))
+	}
+}
@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rights is what a PDF says about who may do what with it: its rights metadata, and the
+// purchase stamps shops print on the pages of books sold without DRM
+type Rights struct {
+	Statement  string      // dc:rights, e.g. "© 2021 Example Press. All rights reserved."
+	UsageTerms string      // xmpRights:UsageTerms
+	Marked     bool        // xmpRights:Marked, the publisher's "this is rights-managed"
+	Watermarks []Watermark // Purchase stamps found on the pages
+}
+
+// Watermark is a purchase stamp, such as "Purchased by Jane Doe (jane@example.com)"
+type Watermark struct {
+	Text  string // The stamp as first found
+	Owner string // Who it names
+	Pages []int
+}
+
+// watermarkPattern matches the phrases shops open their stamps with. Stamps are short
+// lines of their own, so a phrase in the middle of a paragraph isn't one.
+var watermarkPattern = regexp.MustCompile(`(?i)^(?:this (?:copy|book|ebook|e-book) (?:is )?(?:belongs to|licensed to|purchased by)|purchased by|licensed to|licensed exclusively to|personal copy of|prepared exclusively for|copy issued to|sold to)\s*:?\s*(.+)$`)
+
+var emailPattern = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+
+// maxWatermarkLength is the longest line taken for a stamp; longer is a sentence
+const maxWatermarkLength = 160
+
+// openLicenseMarkers are the words of a rights statement that let anyone share the book
+var openLicenseMarkers = []string{"creative commons", "cc by", "cc-by", "cc0", "public domain", "gnu free documentation", "open access"}
+
+// restrictedMarkers are the words of a rights statement that keep the book to its buyer
+var restrictedMarkers = []string{"all rights reserved", "©", "(c)", "copyright", "licensed", "may not be reproduced", "personal use"}
+
+// Licensed reports whether the book is someone's to read rather than anyone's to share:
+// stamped with its buyer, marked as rights-managed, or under a restrictive rights statement
+// rather than an open licence
+func (r Rights) Licensed() bool {
+	if len(r.Watermarks) > 0 || r.Marked {
+		return true
+	}
+	terms := strings.ToLower(r.Statement + " " + r.UsageTerms)
+	for _, marker := range openLicenseMarkers {
+		if strings.Contains(terms, marker) {
+			return false
+		}
+	}
+	for _, marker := range restrictedMarkers {
+		if strings.Contains(terms, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// findWatermarks finds the purchase stamps on a book's pages. A stamp counts when it
+// turns up on more than one page, as they're printed in the margin of every page, or
+// when it gives an email address; a single "sold to" in the text is the story's.
+func findWatermarks(pages []PDFPage) []Watermark {
+	byOwner := make(map[string]*Watermark)
+	var order []string
+	for _, page := range pages {
+		for _, line := range strings.Split(page.Text, "\n") {
+			line = strings.Join(strings.Fields(line), " ")
+			if len(line) > maxWatermarkLength {
+				continue
+			}
+			match := watermarkPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			owner := watermarkOwner(match[1])
+			if owner == "" {
+				continue
+			}
+
+			key := strings.ToLower(owner)
+			watermark, ok := byOwner[key]
+			if !ok {
+				watermark = &Watermark{Text: line, Owner: owner}
+				byOwner[key] = watermark
+				order = append(order, key)
+			}
+			if n := len(watermark.Pages); n == 0 || watermark.Pages[n-1] != page.Number {
+				watermark.Pages = append(watermark.Pages, page.Number)
+			}
+		}
+	}
+
+	var watermarks []Watermark
+	for _, key := range order {
+		watermark := byOwner[key]
+		if len(watermark.Pages) > 1 || emailPattern.MatchString(watermark.Text) {
+			sort.Ints(watermark.Pages)
+			watermarks = append(watermarks, *watermark)
+		}
+	}
+	return watermarks
+}
+
+// watermarkOwner picks the name out of what follows a stamp's opening phrase, leaving
+// behind the email address, order number and date shops add after it. A stamp giving only
+// an email address names that.
+func watermarkOwner(text string) string {
+	owner := text
+	if i := strings.IndexAny(owner, ",;(<[|"); i >= 0 {
+		owner = owner[:i]
+	}
+	for _, separator := range []string{" on ", " - ", " – ", " order", " Order", " transaction", " Transaction"} {
+		if i := strings.Index(owner, separator); i >= 0 {
+			owner = owner[:i]
+		}
+	}
+	if loc := emailPattern.FindStringIndex(owner); loc != nil {
+		owner = owner[:loc[0]]
+	}
+	owner = strings.Trim(owner, " .:-–")
+	if owner == "" {
+		owner = emailPattern.FindString(text)
+	}
+	return owner
+}
+
+// rightsStatement is the dc:rights written into the EPUB: the PDF's own statement, with
+// the owner it was converted for added when there is one
+func rightsStatement(statement, owner string) string {
+	statement = strings.TrimSpace(statement)
+	if owner == "" {
+		return statement
+	}
+	stamp := "Personal copy of " + owner + "."
+	if statement == "" {
+		return stamp
+	}
+	if !strings.HasSuffix(statement, ".") {
+		statement += "."
+	}
+	return statement + " " + stamp
+}
+
+// formatWatermarks describes the stamps for the summary, e.g.
+// "\"Purchased by Jane Doe\" on 212 pages"
+func formatWatermarks(watermarks []Watermark) []string {
+	lines := make([]string, len(watermarks))
+	for i, watermark := range watermarks {
+		lines[i] = "\"" + watermark.Text + "\" on " + pluralPages(len(watermark.Pages))
+	}
+	return lines
+}
@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindWatermarks(t *testing.T) {
+	pages := []PDFPage{
+		{Number: 1, Text: "Mort\nTerry Pratchett\nPurchased by Jane Doe (jane@example.com) on 2021-03-01"},
+		{Number: 2, Text: "Chapter One\nPurchased by  Jane Doe (jane@example.com) on 2021-03-01"},
+		{Number: 3, Text: "He had sold to the highest bidder.\nSold to: the man in the hat"},
+		{Number: 4, Text: "Licensed to order-4411@shop.example"},
+	}
+
+	expected := []Watermark{
+		{Text: "Purchased by Jane Doe (jane@example.com) on 2021-03-01", Owner: "Jane Doe", Pages: []int{1, 2}},
+		{Text: "Licensed to order-4411@shop.example", Owner: "order-4411@shop.example", Pages: []int{4}},
+	}
+	if result := findWatermarks(pages); !reflect.DeepEqual(result, expected) {
+		t.Errorf("findWatermarks() = %+v, expected %+v", result, expected)
+	}
+}
+
+func TestWatermarkOwner(t *testing.T) {
+	tests := map[string]string{
+		"Jane Doe":                         "Jane Doe",
+		"Jane Doe <jane@example.com>":      "Jane Doe",
+		"Jane Doe, Order #12345":           "Jane Doe",
+		"Jane Doe - Transaction 998":       "Jane Doe",
+		"jane@example.com":                 "jane@example.com",
+		"Jane Doe jane@example.com 2021.":  "Jane Doe",
+		"Jane Doe on 1 March 2021 at Shop": "Jane Doe",
+	}
+	for text, expected := range tests {
+		if result := watermarkOwner(text); result != expected {
+			t.Errorf("watermarkOwner(%q) = %q, expected %q", text, result, expected)
+		}
+	}
+}
+
+func TestRightsLicensed(t *testing.T) {
+	tests := []struct {
+		name     string
+		rights   Rights
+		expected bool
+	}{
+		{"nothing said", Rights{}, false},
+		{"all rights reserved", Rights{Statement: "© 2021 Example Press. All rights reserved."}, true},
+		{"usage terms", Rights{UsageTerms: "For personal use only"}, true},
+		{"marked", Rights{Marked: true}, true},
+		{"creative commons", Rights{Statement: "Copyright 2020 Jane Doe, licensed under CC BY 4.0"}, false},
+		{"public domain", Rights{Statement: "This work is in the public domain"}, false},
+		{"stamped", Rights{Statement: "Public domain", Watermarks: []Watermark{{Owner: "Jane Doe"}}}, true},
+	}
+	for _, test := range tests {
+		if result := test.rights.Licensed(); result != test.expected {
+			t.Errorf("%s: Licensed() = %v, expected %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestRightsStatement(t *testing.T) {
+	tests := []struct {
+		statement, owner, expected string
+	}{
+		{"", "", ""},
+		{"© 2021 Example Press", "", "© 2021 Example Press"},
+		{"", "Jane Doe", "Personal copy of Jane Doe."},
+		{"© 2021 Example Press", "Jane Doe", "© 2021 Example Press. Personal copy of Jane Doe."},
+		{"All rights reserved.", "Jane Doe", "All rights reserved. Personal copy of Jane Doe."},
+	}
+	for _, test := range tests {
+		if result := rightsStatement(test.statement, test.owner); result != test.expected {
+			t.Errorf("rightsStatement(%q, %q) = %q, expected %q", test.statement, test.owner, result, test.expected)
+		}
+	}
+}
+
+func TestAddPublifyMeta(t *testing.T) {
+	opf := "<package>\n  <metadata>\n    <dc:title>Mort</dc:title>\n  </metadata>\n</package>"
+	result := string(addPublifyMeta([]byte(opf), "owner", `Jane "JD" Doe`))
+	if !strings.Contains(result, `<meta name="publify:owner" content="Jane &#34;JD&#34; Doe"/>`+"\n  </metadata>") {
+		t.Errorf("Owner meta missing or unescaped:\n%s", result)
+	}
+}
@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Text directions, as used in dir attributes
+const (
+	directionLTR = "ltr"
+	directionRTL = "rtl"
+)
+
+// Directions for Options.Direction
+const (
+	DirectionAuto = ""           // From the book's language
+	DirectionRTL  = directionRTL // Right to left, as manga and books without a text layer need
+	DirectionLTR  = directionLTR // Left to right, even for a book in Arabic or Hebrew
+)
+
+// ValidateDirection checks a --direction value
+func ValidateDirection(direction string) error {
+	switch direction {
+	case DirectionAuto, DirectionRTL, DirectionLTR:
+		return nil
+	}
+	return fmt.Errorf("unknown direction %q (use rtl or ltr)", direction)
+}
+
+// rightToLeftLanguages are written right to left (BCP 47 primary subtags)
+var rightToLeftLanguages = map[string]bool{
+	"ar": true, // Arabic
+	"fa": true, // Persian
+	"he": true, // Hebrew
+	"ps": true, // Pashto
+	"ur": true, // Urdu
+	"yi": true, // Yiddish
+}
+
+// languageDirection is the direction a language is written in
+func languageDirection(code string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if rightToLeftLanguages[primary] {
+		return directionRTL
+	}
+	return directionLTR
+}
+
+// SetRightToLeft makes the book run right to left: pages turn the other way and text
+// starts from the right, except in chapters in a left-to-right language
+func (eg *EPUBGenerator) SetRightToLeft() {
+	eg.rtl = true
+}
+
+// documentDirection is the direction a content document runs in, or "" if it's left to
+// right in a left-to-right book and needs nothing said
+func (eg *EPUBGenerator) documentDirection(name string) string {
+	direction := directionLTR
+	if eg.rtl {
+		direction = directionRTL
+	}
+	if language, ok := eg.sectionLanguages[path.Base(name)]; ok {
+		direction = languageDirection(language)
+	}
+
+	if direction == directionLTR && !eg.rtl {
+		return ""
+	}
+	return direction
+}
+
+// spinePattern is a package document's spine start tag
+var spinePattern = regexp.MustCompile(`<spine\b[^>]*>`)
+
+// progressionPattern is the page progression direction a spine already gives
+var progressionPattern = regexp.MustCompile(`\s+page-progression-direction="[^"]*"`)
+
+// setRightToLeftProgression makes a package document's pages turn right to left,
+// replacing whatever direction the spine gave before
+func setRightToLeftProgression(content []byte) []byte {
+	return spinePattern.ReplaceAllFunc(content, func(spine []byte) []byte {
+		spine = progressionPattern.ReplaceAll(spine, nil)
+		return append(spine[:len(spine)-1:len(spine)-1], ` page-progression-direction="rtl">`...)
+	})
+}
+
+// addDocumentDirection sets dir on a document's html element, which the reader lays out
+// its text and the bidirectional algorithm from
+func addDocumentDirection(content []byte, direction string) []byte {
+	const root = `<html xmlns="http://www.w3.org/1999/xhtml"`
+	start := bytes.Index(content, []byte(root))
+	if direction == "" || start < 0 {
+		return content
+	}
+	end := bytes.IndexByte(content[start:], '>')
+	if end < 0 || bytes.Contains(content[start:start+end], []byte(" dir=")) {
+		return content
+	}
+
+	return bytes.Replace(content, []byte(root), []byte(root+fmt.Sprintf(` dir="%s"`, direction)), 1)
+}
+
+// isPresentationForm reports whether a character is one of the Hebrew and Arabic
+// presentation forms: letters already in the shape for their place in a word, ligatures
+// like lam-alef included
+func isPresentationForm(r rune) bool {
+	return (r >= 0xFB1D && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFC)
+}
+
+// unshapeText replaces presentation forms with the letters they're shapes of. Some PDFs
+// store Arabic that way, as drawn; readers shape letters themselves as they lay text out,
+// and can't join, search or look up letters that come already shaped.
+func unshapeText(text string) string {
+	if !strings.ContainsFunc(text, isPresentationForm) {
+		return text
+	}
+
+	var unshaped strings.Builder
+	for _, r := range text {
+		if isPresentationForm(r) {
+			unshaped.WriteString(norm.NFKC.String(string(r)))
+		} else {
+			unshaped.WriteRune(r)
+		}
+	}
+	return unshaped.String()
+}
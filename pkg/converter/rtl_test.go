@@ -0,0 +1,191 @@
+package converter
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestLanguageDirection(t *testing.T) {
+	tests := map[string]string{
+		"ar":    directionRTL,
+		"he":    directionRTL,
+		"fa-IR": directionRTL,
+		"en":    directionLTR,
+		"ja":    directionLTR,
+		"":      directionLTR,
+	}
+	for code, expected := range tests {
+		if direction := languageDirection(code); direction != expected {
+			t.Errorf("languageDirection(%q) = %q, expected %q", code, direction, expected)
+		}
+	}
+}
+
+func TestTagParagraphDirections(t *testing.T) {
+	content := "<p>كان يا ما كان في قديم الزمان، ملك عادل يحكم مدينة كبيرة جدا.</p>\n" +
+		"<p>It was the best of times, it was the worst of times, it was the age of wisdom.</p>"
+
+	tagged := tagParagraphLanguages(content, "ar", make(map[string]int))
+	if !strings.Contains(tagged, `<p xml:lang="en" lang="en" dir="ltr">It was`) {
+		t.Errorf("Expected the English paragraph to run left to right, got:\n%s", tagged)
+	}
+	if !strings.HasPrefix(tagged, "<p>كان") {
+		t.Errorf("Expected the Arabic paragraph left as it is, got:\n%s", tagged)
+	}
+}
+
+func TestDocumentDirection(t *testing.T) {
+	eg := &EPUBGenerator{sectionLanguages: map[string]string{"section0002.xhtml": "he"}}
+	if direction := eg.documentDirection("EPUB/xhtml/section0001.xhtml"); direction != "" {
+		t.Errorf("Expected nothing said for a left-to-right book, got %q", direction)
+	}
+	if direction := eg.documentDirection("EPUB/xhtml/section0002.xhtml"); direction != directionRTL {
+		t.Errorf("Expected a Hebrew chapter to run right to left, got %q", direction)
+	}
+
+	eg = &EPUBGenerator{rtl: true, sectionLanguages: map[string]string{"section0002.xhtml": "en"}}
+	if direction := eg.documentDirection("EPUB/xhtml/section0001.xhtml"); direction != directionRTL {
+		t.Errorf("Expected a right-to-left book's chapters to run right to left, got %q", direction)
+	}
+	if direction := eg.documentDirection("EPUB/xhtml/section0002.xhtml"); direction != directionLTR {
+		t.Errorf("Expected an English chapter to run left to right, got %q", direction)
+	}
+}
+
+func TestAddDocumentDirection(t *testing.T) {
+	doc := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body></body></html>`)
+
+	result := string(addDocumentDirection(doc, directionRTL))
+	if !strings.HasPrefix(result, `<html xmlns="http://www.w3.org/1999/xhtml" dir="rtl" xmlns:epub=`) {
+		t.Errorf("Expected the direction on the html element, got %s", result)
+	}
+	if again := string(addDocumentDirection([]byte(result), directionLTR)); again != result {
+		t.Error("Expected a document that has a direction to keep it")
+	}
+	if unchanged := string(addDocumentDirection(doc, "")); unchanged != string(doc) {
+		t.Error("Expected no direction to leave the document alone")
+	}
+}
+
+func TestSetRightToLeftProgression(t *testing.T) {
+	opf := "<package>\n  <spine toc=\"ncx\">\n  </spine>\n</package>\n"
+
+	result := string(setRightToLeftProgression([]byte(opf)))
+	if !strings.Contains(result, `<spine toc="ncx" page-progression-direction="rtl">`) {
+		t.Errorf("Expected right-to-left page progression, got %q", result)
+	}
+	if again := string(setRightToLeftProgression([]byte(result))); again != result {
+		t.Errorf("Expected a second pass to change nothing, got %q", again)
+	}
+
+	ltr := "<package>\n  <spine toc=\"ncx\" page-progression-direction=\"ltr\">\n  </spine>\n</package>\n"
+	if result := string(setRightToLeftProgression([]byte(ltr))); result != strings.Replace(ltr, `"ltr"`, `"rtl"`, 1) {
+		t.Errorf("Expected the spine's direction replaced, got %q", result)
+	}
+}
+
+func TestEPUBGeneratorWritesRightToLeftSpine(t *testing.T) {
+	generator := NewEPUBGenerator(reader.Profile{Name: "Test Reader"}, EPUBOptions{Title: "كتاب"})
+	defer generator.Cleanup()
+
+	generator.SetLanguage("ar")
+	generator.SetRightToLeft()
+	if err := generator.AddChapter("الفصل الأول", []PDFPage{{Number: 1, Text: "كان يا ما كان.", HasText: true}}); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+
+	opf := writtenPackageDocument(t, generator)
+	if !strings.Contains(opf, `<spine toc="ncx" page-progression-direction="rtl">`) {
+		t.Errorf("Expected the spine to run right to left, got %s", opf)
+	}
+}
+
+// writtenPackageDocument writes a generator's book and reads its package document back
+func writtenPackageDocument(t *testing.T, generator *EPUBGenerator) string {
+	t.Helper()
+
+	outputPath := filepath.Join(t.TempDir(), "book.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	rc, err := zipReader.Open(packagePath)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", packagePath, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", packagePath, err)
+	}
+	return string(data)
+}
+
+func TestRightToLeft(t *testing.T) {
+	tests := []struct {
+		direction string
+		language  string
+		expected  bool
+	}{
+		{DirectionAuto, "ar", true},
+		{DirectionAuto, "ja", false},
+		{DirectionRTL, "ja", true}, // Manga without a text layer
+		{DirectionLTR, "he", false},
+	}
+	for _, tt := range tests {
+		c := &Converter{options: Options{Direction: tt.direction}}
+		if result := c.rightToLeft(tt.language); result != tt.expected {
+			t.Errorf("rightToLeft(%q) with direction %q = %v, expected %v", tt.language, tt.direction, result, tt.expected)
+		}
+	}
+
+	if err := ValidateDirection("up"); err == nil {
+		t.Error("Expected an error for an unknown direction")
+	}
+}
+
+func TestDirectionRTLWritesRightToLeftSpine(t *testing.T) {
+	// A manga's speech bubbles, in a left-to-right language the book would otherwise follow
+	profile := reader.Profile{Name: "Test Reader"}
+	converter := New(Options{Profile: profile, Direction: DirectionRTL})
+	converter.epubGen = NewEPUBGenerator(profile, EPUBOptions{Title: "Test Manga"})
+	defer converter.epubGen.Cleanup()
+
+	pages := []PDFPage{{Number: 1, Text: "This is the first page of the story.", HasText: true}}
+	if err := converter.generateEPUB(pages); err != nil {
+		t.Fatalf("Unexpected error generating EPUB: %v", err)
+	}
+
+	opf := writtenPackageDocument(t, converter.epubGen)
+	if !strings.Contains(opf, `<spine toc="ncx" page-progression-direction="rtl">`) {
+		t.Errorf("Expected --direction rtl to turn pages right to left, got %s", opf)
+	}
+}
+
+func TestUnshapeText(t *testing.T) {
+	// سلام as stored by a PDF generator: initial seen, medial lam, alef, final meem
+	shaped := "ﺳﻠﺎﻡ world"
+	if unshaped := unshapeText(shaped); unshaped != "سلام world" {
+		t.Errorf("unshapeText() = %q, expected %q", unshaped, "سلام world")
+	}
+
+	// Lam-alef is a single ligature glyph for two letters
+	if unshaped := unshapeText("ﻻ"); unshaped != "لا" {
+		t.Errorf("unshapeText(lam-alef) = %q, expected %q", unshaped, "لا")
+	}
+
+	if plain := "Ünïcödé ﬁne"; unshapeText(plain) != plain {
+		t.Error("Expected text without presentation forms left alone")
+	}
+}
@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+const (
+	// scanSampleSize is how many pages are looked at to tell whether a PDF is scanned
+	scanSampleSize = 5
+	// minTextLayerChars is the least text a page needs to count as having a text layer;
+	// scans sometimes carry a stray page number or watermark
+	minTextLayerChars = 20
+)
+
+// samplePages picks up to n pages spread evenly through the candidates, so a born-digital
+// cover or a few blank pages at the front don't decide for the whole book
+func samplePages(candidates []int, n int) []int {
+	if len(candidates) <= n {
+		return candidates
+	}
+	sample := make([]int, n)
+	for i := range sample {
+		sample[i] = candidates[i*len(candidates)/n]
+	}
+	return sample
+}
+
+// scanCandidates are the pages that will be read as text: selected, not skipped and not
+// kept as images
+func (p *PDFProcessor) scanCandidates() []int {
+	var candidates []int
+	for _, page := range p.SelectedPages() {
+		if !p.skipPages[page] && GetPageType(page, p.imagePageRange) == PageTypeText {
+			candidates = append(candidates, page)
+		}
+	}
+	return candidates
+}
+
+// looksScanned samples the pages that will be read as text, and reports whether most of
+// them have no text layer, along with how many didn't out of how many were looked at
+func (p *PDFProcessor) looksScanned() (bool, int, int, error) {
+	sample := samplePages(p.scanCandidates(), scanSampleSize)
+	if len(sample) == 0 {
+		return false, 0, 0, nil
+	}
+
+	document, err := p.documents.acquire()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	defer p.documents.release(document)
+
+	withoutText := 0
+	for _, page := range sample {
+		pageText, err := document.instance.GetPageText(&requests.GetPageText{
+			Page: requests.Page{
+				ByIndex: &requests.PageByIndex{
+					Document: document.doc,
+					Index:    page - 1,
+				},
+			},
+		})
+		if err != nil || len(strings.TrimSpace(pageText.Text)) < minTextLayerChars {
+			withoutText++
+		}
+	}
+
+	return 2*withoutText > len(sample), withoutText, len(sample), nil
+}
+
+// enableAutoOCR turns OCR on for a PDF that looks scanned, which would otherwise come out
+// as chapters of "No text content found". It wasn't asked for, so if OCR can't start the
+// conversion goes on without it, keeping the pages as pictures, with a warning.
+func (p *PDFProcessor) enableAutoOCR(opts PDFProcessorOptions, withoutText, sampled int) {
+	scanned := fmt.Sprintf("%s looks scanned (%d of %d sampled pages have no text layer) and needs OCR",
+		filepath.Base(p.filePath), withoutText, sampled)
+
+	ocrProcessor, err := newPageOCRProcessor(opts.OCREngine, opts.OCRLanguage, opts.OCRCredentials)
+	if err != nil {
+		reason := fmt.Sprintf("OCR couldn't start (%v)", err)
+		if errors.Is(err, ErrTesseractMissing) {
+			reason = "Tesseract isn't installed"
+		}
+		p.imagePageRange = &PageRangeSet{ranges: []PageRange{{Start: 1, End: p.pageCount}}}
+		p.degrade(fmt.Sprintf("%s, but %s, so its pages are kept as pictures. To read them, %s or use a cloud engine with --ocr-engine",
+			scanned, reason, installTesseract))
+		return
+	}
+	p.ocrProcessor = ocrProcessor
+	p.enableOCR = true
+	p.autoOCR = true
+}
+
+// OCREnabled reports whether pages without a text layer are read with OCR
+func (p *PDFProcessor) OCREnabled() bool {
+	return p.enableOCR
+}
+
+// OCRAutoEnabled reports whether OCR was turned on because the PDF looked scanned
+func (p *PDFProcessor) OCRAutoEnabled() bool {
+	return p.autoOCR
+}
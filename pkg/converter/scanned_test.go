@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSamplePages(t *testing.T) {
+	pages := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+
+	if sample := samplePages(pages, 5); !slices.Equal(sample, []int{1, 5, 9, 13, 17}) {
+		t.Errorf("samplePages() = %v, expected pages spread through the book", sample)
+	}
+	if sample := samplePages(pages[:3], 5); !slices.Equal(sample, []int{1, 2, 3}) {
+		t.Errorf("samplePages() = %v, expected every page of a short book", sample)
+	}
+	if sample := samplePages(nil, 5); len(sample) != 0 {
+		t.Errorf("samplePages() = %v, expected nothing to sample", sample)
+	}
+}
+
+func TestScanCandidates(t *testing.T) {
+	pageRange, err := ParsePageRanges("2-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imagePageRange, err := ParsePageRanges("2-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor := &PDFProcessor{
+		pageCount:      10,
+		pageRange:      pageRange,
+		imagePageRange: imagePageRange,
+		skipPages:      map[int]bool{5: true},
+	}
+
+	if candidates := processor.scanCandidates(); !slices.Equal(candidates, []int{4, 6, 7, 8, 9}) {
+		t.Errorf("scanCandidates() = %v, expected selected text pages that aren't skipped", candidates)
+	}
+}
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+)
+
+// Generated documents are tagged with the EPUB structural semantics vocabulary
+// (https://www.w3.org/TR/epub-ssv-11/) and the matching DPUB-ARIA roles. The sections
+// themselves get their type in the templates; the body gets the part of the book it
+// belongs to, which go-epub gives no way of setting.
+
+// frontMatterDocuments and backMatterDocuments are the generated pages outside the body of the book
+var (
+	frontMatterDocuments = map[string]bool{"titlepage.xhtml": true}
+	backMatterDocuments  = map[string]bool{"colophon.xhtml": true}
+)
+
+// documentPartition returns the epub:type for the body of a content document, or ""
+// for files that aren't content documents (or, like the cover, are typed already)
+func documentPartition(name string) string {
+	if !strings.HasPrefix(name, "EPUB/xhtml/") || path.Ext(name) != ".xhtml" || name == coverPagePath {
+		return ""
+	}
+
+	switch base := path.Base(name); {
+	case frontMatterDocuments[base]:
+		return "frontmatter"
+	case backMatterDocuments[base]:
+		return "backmatter"
+	}
+	return "bodymatter"
+}
+
+// addBodyType sets epub:type on a document's <body>, unless it has one already
+func addBodyType(content []byte, epubType string) []byte {
+	if !bytes.Contains(content, []byte("<body>")) {
+		return content
+	}
+	return bytes.Replace(content, []byte("<body>"), []byte(`<body epub:type="`+epubType+`">`), 1)
+}
+
+// addNavRoles gives the table of contents its ARIA role, and an id for the landmarks
+func addNavRoles(content []byte) []byte {
+	return bytes.Replace(content, []byte(`<nav epub:type="toc">`), []byte(`<nav epub:type="toc" role="doc-toc" id="toc">`), 1)
+}
+
+// addLandmarks adds a landmarks nav, which readers offer as places to go to
+func addLandmarks(content []byte, landmarks []landmark) []byte {
+	nav := string(content)
+	if len(landmarks) == 0 || strings.Contains(nav, `epub:type="landmarks"`) {
+		return content
+	}
+
+	var list strings.Builder
+	list.WriteString("    <nav epub:type=\"landmarks\" hidden=\"\">\n      <h2>Landmarks</h2>\n      <ol>\n")
+	for _, mark := range landmarks {
+		fmt.Fprintf(&list, "        <li>\n          <a epub:type=\"%s\" href=\"%s\">%s</a>\n        </li>\n",
+			mark.epubType, html.EscapeString(mark.href), html.EscapeString(mark.title))
+	}
+	list.WriteString("      </ol>\n    </nav>\n</body>")
+
+	return []byte(strings.Replace(nav, "</body>", list.String(), 1))
+}
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestGeneratedSectionsAreTagged(t *testing.T) {
+	profile := reader.Profile{
+		Name:         "Test Reader",
+		Capabilities: reader.DeviceCapabilities{DefaultFontSize: 12},
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book", Author: "A. Author"})
+	defer generator.Cleanup()
+
+	if err := generator.AddTitlePage(); err != nil {
+		t.Fatalf("Unexpected error adding title page: %v", err)
+	}
+	if err := generator.AddChapter("Chapter One", []PDFPage{{Number: 1, Text: "It was a dark and stormy night.", HasText: true}}); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+	if err := generator.AddColophon("Converted for testing.", nil); err != nil {
+		t.Fatalf("Unexpected error adding colophon: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "semantics.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	expectations := map[string][]string{
+		"EPUB/xhtml/titlepage.xhtml": {`<body epub:type="frontmatter">`, `epub:type="titlepage"`},
+		"EPUB/xhtml/page0001.xhtml":  {`<body epub:type="bodymatter">`, `<section epub:type="chapter" role="doc-chapter">`},
+		"EPUB/xhtml/colophon.xhtml":  {`<body epub:type="backmatter">`, `role="doc-colophon"`},
+		navPath:                      {`<nav epub:type="toc" role="doc-toc" id="toc">`, `<nav epub:type="landmarks" hidden="">`},
+	}
+	for name, expected := range expectations {
+		content, ok := files[name]
+		if !ok {
+			t.Errorf("%s missing from EPUB", name)
+			continue
+		}
+		for _, want := range expected {
+			if !strings.Contains(content, want) {
+				t.Errorf("Expected %s to contain %q", name, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// marginInk is the gray level below which a pixel counts as something printed rather
+	// than paper, when looking for a page's margins
+	marginInk = 160
+	// pageMarginNoise is the share of a row or column that may be ink and still count as
+	// margin, so dust and scanner specks don't stop the crop
+	pageMarginNoise = 0.002
+	// marginPadding is the share of the page's size kept around what's printed, so the
+	// crop doesn't cut right up against the text
+	marginPadding = 0.015
+	// minCropShare is the smallest share of the page's width or height a crop may leave;
+	// anything smaller is a near-blank page, which is better left alone
+	minCropShare = 0.2
+	// spreadRatio is how much wider than tall a page must be to count as two pages side
+	// by side
+	spreadRatio = 1.15
+	// gutterSearch is the share of a spread's width either side of the middle searched
+	// for the gutter
+	gutterSearch = 0.06
+)
+
+// pageParts prepares a rendered image page for the reader: the white margins cropped, if
+// asked, and a two-page spread split into its pages, if asked, in reading order
+func (eg *EPUBGenerator) pageParts(img image.Image) []image.Image {
+	if eg.options.CropMargins {
+		img = cropPageMargins(img)
+	}
+	if !eg.options.SplitSpreads || !isSpread(img) {
+		return []image.Image{img}
+	}
+
+	parts := splitSpread(img, eg.rtl || eg.vertical)
+	if eg.options.CropMargins {
+		for i, part := range parts {
+			parts[i] = cropPageMargins(part) // The gutter is margin too
+		}
+	}
+	return parts
+}
+
+// cropPageMargins crops the blank paper around what's printed on a page, leaving a little
+// padding. Pages with nearly nothing on them come back as they are. The cover's
+// cropMargins trims right up to the picture instead.
+func cropPageMargins(img image.Image) image.Image {
+	gray := grayImage(img)
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return img
+	}
+
+	rows := make([]int, height)
+	columns := make([]int, width)
+	for y := 0; y < height; y++ {
+		row := gray.Pix[y*gray.Stride:]
+		for x := 0; x < width; x++ {
+			if row[x] < marginInk {
+				rows[y]++
+				columns[x]++
+			}
+		}
+	}
+
+	top, bottom := inkSpan(rows, int(float64(width)*pageMarginNoise))
+	left, right := inkSpan(columns, int(float64(height)*pageMarginNoise))
+	if right-left < int(float64(width)*minCropShare) || bottom-top < int(float64(height)*minCropShare) {
+		return img
+	}
+
+	padX, padY := int(float64(width)*marginPadding), int(float64(height)*marginPadding)
+	crop := image.Rect(max(0, left-padX), max(0, top-padY), min(width, right+padX), min(height, bottom+padY))
+	if crop.Dx() == width && crop.Dy() == height {
+		return img
+	}
+	return imaging.Crop(img, crop.Add(bounds.Min))
+}
+
+// inkSpan finds the first and one past the last line with more than noise pixels of ink
+// in it, or an empty span if there is none
+func inkSpan(counts []int, noise int) (int, int) {
+	first, last := 0, len(counts)
+	for first < last && counts[first] <= noise {
+		first++
+	}
+	for last > first && counts[last-1] <= noise {
+		last--
+	}
+	return first, last
+}
+
+// isSpread reports whether a page image is two pages side by side, as scans of open books
+// and manga double pages are
+func isSpread(img image.Image) bool {
+	bounds := img.Bounds()
+	return float64(bounds.Dx()) > float64(bounds.Dy())*spreadRatio
+}
+
+// splitSpread cuts a spread into its two pages at the gutter, the emptiest column near the
+// middle, returning them in reading order: the right-hand page first in a book that runs
+// right to left
+func splitSpread(img image.Image, rightToLeft bool) []image.Image {
+	bounds := img.Bounds()
+	gutter := findGutter(grayImage(img))
+	left := imaging.Crop(img, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+gutter, bounds.Max.Y))
+	right := imaging.Crop(img, image.Rect(bounds.Min.X+gutter, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+	if rightToLeft {
+		return []image.Image{right, left}
+	}
+	return []image.Image{left, right}
+}
+
+// findGutter finds the column, counted from the left edge, between the two pages of a
+// spread: the one with the least ink near the middle, the one closest to the middle of
+// those tied
+func findGutter(gray *image.Gray) int {
+	width, height := gray.Bounds().Dx(), gray.Bounds().Dy()
+	middle := width / 2
+	reach := int(float64(width) * gutterSearch)
+
+	best, bestInk := middle, height+1
+	for offset := 0; offset <= reach; offset++ {
+		for _, x := range []int{middle - offset, middle + offset} {
+			if x <= 0 || x >= width {
+				continue
+			}
+			ink := 0
+			for y := 0; y < height; y++ {
+				if gray.Pix[y*gray.Stride+x] < marginInk {
+					ink++
+				}
+			}
+			if ink < bestInk {
+				best, bestInk = x, ink
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// pageWithInk returns a white page with black blocks where the text would be
+func pageWithInk(width, height int, blocks ...image.Rectangle) *image.Gray {
+	page := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, block := range blocks {
+		draw.Draw(page, block, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	return page
+}
+
+func TestCropPageMargins(t *testing.T) {
+	page := pageWithInk(1000, 1400, image.Rect(200, 300, 800, 1100))
+	page.SetGray(20, 20, color.Gray{}) // A speck of dust in the margin
+
+	bounds := cropPageMargins(page).Bounds()
+	// 1.5% of the page is kept as padding: 15 pixels across, 21 down
+	if bounds.Dx() != 630 || bounds.Dy() != 842 {
+		t.Errorf("Expected the margins cropped to 630x842, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	blank := pageWithInk(1000, 1400, image.Rect(480, 680, 520, 720))
+	if cropped := cropPageMargins(blank); cropped.Bounds() != blank.Bounds() {
+		t.Errorf("Expected a nearly blank page left alone, got %v", cropped.Bounds())
+	}
+}
+
+func TestSplitSpread(t *testing.T) {
+	// Two pages with a gutter a little left of the middle
+	spread := pageWithInk(2000, 1400, image.Rect(100, 100, 900, 1300), image.Rect(1000, 100, 1900, 1300))
+	if !isSpread(spread) {
+		t.Fatal("Expected a page twice as wide as tall to be a spread")
+	}
+	if isSpread(pageWithInk(1000, 1400)) {
+		t.Error("Expected a single page not to be a spread")
+	}
+
+	pages := splitSpread(spread, false)
+	if len(pages) != 2 || pages[0].Bounds().Dx()+pages[1].Bounds().Dx() != 2000 {
+		t.Fatalf("Expected the spread split into two pages, got %d", len(pages))
+	}
+	if width := pages[0].Bounds().Dx(); width < 900 || width > 1000 {
+		t.Errorf("Expected the split in the gutter, between 900 and 1000, got %d", width)
+	}
+
+	rightToLeft := splitSpread(spread, true)
+	if rightToLeft[0].Bounds().Dx() != pages[1].Bounds().Dx() {
+		t.Error("Expected the right-hand page first when reading right to left")
+	}
+}
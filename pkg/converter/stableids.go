@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Reading apps anchor highlights and notes to a content document and an element id in it.
+// Both are derived from the PDF rather than counted as the book is written, so converting
+// the same PDF again with other settings keeps them where they were:
+//
+//   - A chapter's content document is named after the PDF page it starts on
+//     (page0012.xhtml). Where a chapter is split for the reader, each further part is named
+//     after the chapter and the paragraph it starts with (page0012-p15-3fa9c2.xhtml).
+//   - A paragraph's id is its PDF page and a hash of its words (p15-3fa9c2): the first six
+//     hex digits of the SHA-256 of its letters and digits, lowercased, one space between
+//     words. Markup, punctuation, soft hyphens and transliteration don't change it. The same
+//     paragraph twice on a page gets -2, -3 and so on.
+//   - Figures are page0015-figure1, the first figure on page 15.
+
+// paragraphIDLength is how many hex digits of the hash go in a paragraph id
+const paragraphIDLength = 6
+
+var paragraphIDPattern = regexp.MustCompile(`<p id="(p\d+-[0-9a-f]+(?:-\d+)?)">`)
+
+// addParagraphIDs gives each bare paragraph on a page its stable id
+func addParagraphIDs(content string, page int) string {
+	used := make(map[string]int)
+	return paragraphPattern.ReplaceAllStringFunc(content, func(paragraph string) string {
+		if !strings.HasPrefix(paragraph, "<p>") {
+			return paragraph
+		}
+		id := paragraphID(page, stripTags(paragraph))
+		used[id]++
+		if n := used[id]; n > 1 {
+			id = fmt.Sprintf("%s-%d", id, n)
+		}
+		return `<p id="` + id + `">` + paragraph[len("<p>"):]
+	})
+}
+
+// paragraphID is the id of a paragraph with the given text on a page
+func paragraphID(page int, text string) string {
+	sum := sha256.Sum256([]byte(normalizeForID(text)))
+	return fmt.Sprintf("p%d-%s", page, hex.EncodeToString(sum[:])[:paragraphIDLength])
+}
+
+// normalizeForID keeps the words of a text and nothing else, so the id doesn't change
+// with how it's marked up or spelled out
+func normalizeForID(text string) string {
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00ad", "") // Soft hyphens split words
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// sectionFilename names a chapter's content document, or a further part of it, after where
+// it starts in the PDF. A name already taken gets -2, -3 and so on.
+func (eg *EPUBGenerator) sectionFilename(firstPage, part int, content string) string {
+	name := fmt.Sprintf("page%04d", firstPage)
+	if part > 0 {
+		if match := paragraphIDPattern.FindStringSubmatch(content); match != nil {
+			name += "-" + match[1]
+		} else {
+			name += fmt.Sprintf("-part%d", part+1)
+		}
+	}
+
+	eg.sectionNames[name]++
+	if n := eg.sectionNames[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+	return name + ".xhtml"
+}
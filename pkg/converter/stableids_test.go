@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddParagraphIDs(t *testing.T) {
+	content := "<h2>CHAPTER ONE</h2>\n<p>\nIt was a dark and<br/>\nstormy night.<br/>\n</p>\n<p>\n***<br/>\n</p>\n<p>\n***<br/>\n</p>"
+	result := addParagraphIDs(content, 12)
+
+	id := paragraphID(12, "It was a dark and stormy night.")
+	if !strings.Contains(result, `<p id="`+id+`">`) {
+		t.Errorf("Expected paragraph id %s, got %q", id, result)
+	}
+	if !strings.HasPrefix(id, "p12-") || len(id) != len("p12-")+paragraphIDLength {
+		t.Errorf("Unexpected id format %q", id)
+	}
+	if separator := paragraphID(12, "***"); !strings.Contains(result, `<p id="`+separator+`-2">`) {
+		t.Errorf("Expected the repeated paragraph to be numbered, got %q", result)
+	}
+
+	// Paragraphs that have an id keep it
+	if again := addParagraphIDs(result, 13); again != result {
+		t.Errorf("Expected ids to be left alone, got %q", again)
+	}
+}
+
+func TestParagraphIDIgnoresMarkup(t *testing.T) {
+	id := paragraphID(7, "Fish &amp; chips, served at noon!")
+	for _, text := range []string{
+		"Fish & chips served at noon",
+		"  FISH &  CHIPS — served at noon. ",
+		"Fish & chips served at no\u00adon",
+	} {
+		if other := paragraphID(7, text); other != id {
+			t.Errorf("paragraphID(%q) = %s, expected %s", text, other, id)
+		}
+	}
+	if paragraphID(7, "Fish & chips served at dusk") == id {
+		t.Error("Expected other words to give another id")
+	}
+	if paragraphID(8, "Fish & chips served at noon") == id {
+		t.Error("Expected another page to give another id")
+	}
+}
+
+func TestSectionFilename(t *testing.T) {
+	eg := &EPUBGenerator{sectionNames: make(map[string]int)}
+
+	tests := []struct {
+		page, part int
+		content    string
+		expected   string
+	}{
+		{12, 0, `<p id="p12-3fa9c2">`, "page0012.xhtml"},
+		{12, 1, "<h2>Notes</h2>\n<p id=\"p15-0b1c2d\">\nText\n</p>", "page0012-p15-0b1c2d.xhtml"},
+		{12, 2, `<div class="page-image"><img src="p" alt=""/></div>`, "page0012-part3.xhtml"},
+		{12, 0, "", "page0012-2.xhtml"}, // Two chapters starting on one page
+	}
+	for _, test := range tests {
+		if name := eg.sectionFilename(test.page, test.part, test.content); name != test.expected {
+			t.Errorf("sectionFilename(%d, %d) = %q, expected %q", test.page, test.part, name, test.expected)
+		}
+	}
+}
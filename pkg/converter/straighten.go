@@ -0,0 +1,232 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// orientationSamples is the width a page is scaled to for working out which way up it is
+	orientationSamples = 800
+	// lineRatio is how much more sharply the ink must break into lines one way than the
+	// other before a page counts as turned on its side
+	lineRatio = 2.0
+	// uprightRatio is how much more ink must stick out above the lines of text than below
+	// them (ascenders and capitals against descenders) before a page counts as one way up.
+	// Scripts without ascenders come out even, and are left as they are.
+	uprightRatio = 1.4
+	// coreDensity is the share of a line's densest row a row needs to be part of the line's
+	// core, between the baseline and the x-height
+	coreDensity = 0.5
+	// maxCorrectionsShown is how many straightened pages the summary lists without --verbose
+	maxCorrectionsShown = 10
+)
+
+// Straightening is what was done to a page to make it read straight
+type Straightening struct {
+	Rotation int     // Degrees the page was turned clockwise to stand upright: 0, 90, 180 or 270
+	Skew     float64 // Degrees the page was turned clockwise to level its lines
+}
+
+// IsZero reports whether the page was left as it was
+func (s Straightening) IsZero() bool {
+	return s.Rotation == 0 && s.Skew == 0
+}
+
+// String describes the correction, as in "turned 180°, tilted 1.2°"
+func (s Straightening) String() string {
+	var done []string
+	if s.Rotation != 0 {
+		done = append(done, fmt.Sprintf("turned %d°", s.Rotation))
+	}
+	if s.Skew != 0 {
+		done = append(done, fmt.Sprintf("tilted %.1f°", math.Abs(s.Skew)))
+	}
+	return strings.Join(done, ", ")
+}
+
+// PageCorrection is a page that was straightened, for the summary
+type PageCorrection struct {
+	Page int
+	Straightening
+}
+
+// pageCorrections lists the pages that were straightened, in page order
+func pageCorrections(pages []PDFPage) []PageCorrection {
+	var corrections []PageCorrection
+	for _, page := range pages {
+		if !page.Straightened.IsZero() {
+			corrections = append(corrections, PageCorrection{Page: page.Number, Straightening: page.Straightened})
+		}
+	}
+	return corrections
+}
+
+// formatPageCorrections lists corrections as "p12 turned 180°, p40 tilted 1.2°"
+func formatPageCorrections(corrections []PageCorrection) string {
+	parts := make([]string, len(corrections))
+	for i, correction := range corrections {
+		parts[i] = fmt.Sprintf("p%d %s", correction.Page, correction.Straightening)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// straighten turns a page image upright and levels its lines of text, filling the corners
+// with paper white. Pages that aren't text on paper, or whose way up can't be told, are
+// only levelled, if that.
+func straighten(img image.Image) (image.Image, Straightening) {
+	var done Straightening
+	if linesRunDown(grayImage(img)) {
+		// Text on its side reads upright one way round or the other. Japanese and Chinese
+		// set in columns can't be told that way, and stay as they are.
+		turned := turnClockwise(img, 90)
+		switch uprightness(grayImage(turned)) {
+		case 1:
+			img, done.Rotation = turned, 90
+		case -1:
+			img, done.Rotation = turnClockwise(img, 270), 270
+		default:
+			return img, done
+		}
+	}
+
+	if angle := estimateSkew(grayImage(img)); math.Abs(angle) >= minSkew {
+		img = imaging.Rotate(img, -angle, color.White)
+		done.Skew = angle
+	}
+
+	if done.Rotation == 0 && uprightness(grayImage(img)) == -1 {
+		img, done.Rotation = turnClockwise(img, 180), 180
+	}
+	return img, done
+}
+
+// turnClockwise turns an image by a multiple of 90 degrees
+func turnClockwise(img image.Image, degrees int) image.Image {
+	switch degrees {
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// inkProfiles counts the ink in each row and each column of a page scaled down to
+// orientationSamples wide, or returns false if it isn't ink on paper
+func inkProfiles(gray *image.Gray) ([]int, []int, bool) {
+	small := grayImage(imaging.Resize(gray, min(orientationSamples, gray.Bounds().Dx()), 0, imaging.Box))
+	bw := threshold(small, otsuThreshold(grayHistogram(small)))
+
+	bounds := bw.Bounds()
+	rows := make([]int, bounds.Dy())
+	columns := make([]int, bounds.Dx())
+	ink := 0
+	for y := 0; y < bounds.Dy(); y++ {
+		row := bw.Pix[y*bw.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			if row[x] == 0 {
+				rows[y]++
+				columns[x]++
+				ink++
+			}
+		}
+	}
+	return rows, columns, ink > 0 && ink <= bounds.Dx()*bounds.Dy()/2
+}
+
+// linesRunDown reports whether a page's lines of text run down it rather than across, as
+// on a page scanned on its side
+func linesRunDown(gray *image.Gray) bool {
+	rows, columns, ok := inkProfiles(gray)
+	return ok && lineBreaks(columns) > lineBreaks(rows)*lineRatio
+}
+
+// lineBreaks measures how sharply a projection of the ink breaks into lines with gaps
+// between them, independent of how much ink there is
+func lineBreaks(profile []int) float64 {
+	var changes, total float64
+	for i, count := range profile {
+		total += float64(count) * float64(count)
+		if i > 0 {
+			d := float64(count - profile[i-1])
+			changes += d * d
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return changes / total
+}
+
+// uprightness tells from the ink sticking out of each line of text whether a page is the
+// right way up (1), upside down (-1), or neither clearly (0). Ascenders and capitals are
+// more common than descenders in Latin scripts, so more ink sticks out above the lines.
+func uprightness(gray *image.Gray) int {
+	rows, columns, ok := inkProfiles(gray)
+	if !ok || lineBreaks(rows) < lineBreaks(columns)*lineRatio {
+		return 0 // Not lines of text across the page
+	}
+
+	var above, below int
+	for _, line := range findLines(rows) {
+		densest := 0
+		for _, count := range rows[line[0]:line[1]] {
+			densest = max(densest, count)
+		}
+		coreStart, coreEnd := line[0], line[1]
+		for coreStart < coreEnd && float64(rows[coreStart]) < float64(densest)*coreDensity {
+			coreStart++
+		}
+		for coreEnd > coreStart && float64(rows[coreEnd-1]) < float64(densest)*coreDensity {
+			coreEnd--
+		}
+		for _, count := range rows[line[0]:coreStart] {
+			above += count
+		}
+		for _, count := range rows[coreEnd:line[1]] {
+			below += count
+		}
+	}
+
+	switch {
+	case above > 0 && float64(above) > float64(below)*uprightRatio:
+		return 1
+	case below > 0 && float64(below) > float64(above)*uprightRatio:
+		return -1
+	}
+	return 0
+}
+
+// findLines finds the runs of rows with more than a speck of ink in them, as [start, end)
+// pairs
+func findLines(rows []int) [][2]int {
+	densest := 0
+	for _, count := range rows {
+		densest = max(densest, count)
+	}
+	speck := densest / 50
+
+	var lines [][2]int
+	start := -1
+	for i, count := range rows {
+		switch {
+		case count > speck && start < 0:
+			start = i
+		case count <= speck && start >= 0:
+			lines = append(lines, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		lines = append(lines, [2]int{start, len(rows)})
+	}
+	return lines
+}
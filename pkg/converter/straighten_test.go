@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// latinPage draws lines of words with ascenders sticking up out of them more often than
+// descenders hang down, as in a page of English
+func latinPage(width, height int) *image.Gray {
+	page := pageWithInk(width, height)
+	ink := image.NewUniform(color.Black)
+	word := 0
+	for y := 120; y+40 < height-120; y += 70 {
+		for x := 100; x+80 < width-100; x += 100 {
+			draw.Draw(page, image.Rect(x, y, x+80, y+20), ink, image.Point{}, draw.Src)
+			switch word % 3 {
+			case 0, 1: // An ascender: a d, an l, a capital
+				draw.Draw(page, image.Rect(x+10, y-12, x+16, y), ink, image.Point{}, draw.Src)
+			}
+			if word%4 == 0 { // A descender: a p or a g
+				draw.Draw(page, image.Rect(x+50, y+20, x+56, y+30), ink, image.Point{}, draw.Src)
+			}
+			word++
+		}
+	}
+	return page
+}
+
+func TestStraighten(t *testing.T) {
+	page := latinPage(1200, 1600)
+	if _, done := straighten(page); !done.IsZero() {
+		t.Errorf("Expected an upright page left alone, got %s", done)
+	}
+
+	for _, rotation := range []int{90, 180, 270} {
+		// Scanned the wrong way round: turning it by what's left brings it back
+		scanned := turnClockwise(page, 360-rotation)
+		upright, done := straighten(scanned)
+		if done.Rotation != rotation {
+			t.Errorf("Page scanned turned %d° turned %d° back", 360-rotation, done.Rotation)
+		}
+		if bounds := upright.Bounds(); bounds.Dx() != 1200 || bounds.Dy() != 1600 {
+			t.Errorf("Expected the page standing upright at 1200x1600, got %dx%d", bounds.Dx(), bounds.Dy())
+		}
+	}
+
+	tilted := imaging.Rotate(turnClockwise(page, 180), 2, color.White)
+	_, done := straighten(tilted)
+	if done.Rotation != 180 || math.Abs(done.Skew-2) > 0.25 {
+		t.Errorf("Expected an upside down page tilted 2° turned 180° and levelled, got %s", done)
+	}
+
+	// Lines without ascenders can't be told one way up from the other, and lines running
+	// down the page may be Japanese set in columns
+	blocks := textPage(1200, 1600)
+	if _, done := straighten(turnClockwise(blocks, 180)); done.Rotation != 0 {
+		t.Errorf("Expected lines without ascenders left the way up they are, got %s", done)
+	}
+	if _, done := straighten(turnClockwise(blocks, 90)); done.Rotation != 0 {
+		t.Errorf("Expected columns of text left as they are, got %s", done)
+	}
+}
+
+func TestFormatPageCorrections(t *testing.T) {
+	corrections := pageCorrections([]PDFPage{
+		{Number: 3},
+		{Number: 12, Straightened: Straightening{Rotation: 180}},
+		{Number: 40, Straightened: Straightening{Skew: -1.24}},
+	})
+	if got := formatPageCorrections(corrections); got != "p12 turned 180°, p40 tilted 1.2°" {
+		t.Errorf("Unexpected report %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/alde/publify/internal/logging"
+)
+
+// customCSSName is what the user's stylesheet is called inside the EPUB
+const customCSSName = "custom.css"
+
+// cssURLPattern finds url() references, to fonts and images the stylesheet would need
+var cssURLPattern = regexp.MustCompile(`url\(\s*['"]?([^'")]+)`)
+
+// SetCustomStylesheet embeds a stylesheet of the user's, linked from every content document
+// after publify's own so its rules win. It's optimized for the reader like any other
+// stylesheet, so properties the reader can't handle are dropped and pixel sizes made relative.
+func (eg *EPUBGenerator) SetCustomStylesheet(cssPath string) error {
+	css, err := os.ReadFile(cssPath)
+	if err != nil {
+		return fmt.Errorf("failed to read stylesheet: %w", err)
+	}
+
+	// Only the stylesheet goes in; fonts and images it refers to would be missing
+	for _, match := range cssURLPattern.FindAllSubmatch(css, -1) {
+		if !bytes.HasPrefix(match[1], []byte("data:")) {
+			logging.Module("epub").Warn("The stylesheet refers to a file that isn't embedded", "url", string(match[1]))
+		}
+	}
+
+	tempDir, err := eg.workDir()
+	if err != nil {
+		return err
+	}
+	optimized := NewEPUBOptimizer(eg.profile).OptimizeCSS(string(css))
+	cssFile := filepath.Join(tempDir, customCSSName)
+	if err := os.WriteFile(cssFile, []byte(optimized), 0644); err != nil {
+		return fmt.Errorf("failed to write stylesheet: %w", err)
+	}
+
+	internalPath, err := eg.epub.AddCSS(cssFile, customCSSName)
+	if err != nil {
+		return fmt.Errorf("failed to add stylesheet: %w", err)
+	}
+	eg.customCSS = internalPath
+	return nil
+}
+
+// addStylesheetLink links a stylesheet from a content document, after the ones it already has
+func addStylesheetLink(content []byte, href string) []byte {
+	link := `<link rel="stylesheet" type="text/css" href="` + href + `"></link>`
+	return bytes.Replace(content, []byte("</head>"), []byte("  "+link+"\n  </head>"), 1)
+}
@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestCustomStylesheet(t *testing.T) {
+	profile := reader.Profile{
+		Name: "Test Reader",
+		Capabilities: reader.DeviceCapabilities{
+			DefaultFontSize:         12,
+			StripUnsupportedContent: true,
+		},
+	}
+
+	cssPath := filepath.Join(t.TempDir(), "my-style.css")
+	css := "/* Roomier */\np {\n  line-height: 1.6;\n  text-align: justify;\n  box-shadow: 0 0 2px black;\n}\n"
+	if err := os.WriteFile(cssPath, []byte(css), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Mort", Author: "Terry Pratchett"})
+	defer generator.Cleanup()
+
+	if err := generator.SetCustomStylesheet(cssPath); err != nil {
+		t.Fatalf("Unexpected error adding stylesheet: %v", err)
+	}
+	if err := generator.AddChapter("Chapter 1", []PDFPage{{Number: 1, Text: "This is a story about Mort.", HasText: true}}); err != nil {
+		t.Fatalf("Unexpected error adding chapter: %v", err)
+	}
+	if err := generator.AddColophon("Converted from mort.pdf.", nil); err != nil {
+		t.Fatalf("Unexpected error adding colophon: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "styled.epub")
+	if err := generator.Write(outputPath); err != nil {
+		t.Fatalf("Unexpected error writing EPUB: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open generated EPUB: %v", err)
+	}
+	defer zipReader.Close()
+
+	files := make(map[string]string)
+	for _, file := range zipReader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	stylesheet, ok := files["EPUB/css/"+customCSSName]
+	if !ok {
+		t.Fatal("Expected the stylesheet in the EPUB")
+	}
+	if !strings.Contains(stylesheet, "line-height:1.6") || !strings.Contains(stylesheet, "text-align:justify") {
+		t.Errorf("Expected the stylesheet's rules to be kept, got %q", stylesheet)
+	}
+	if strings.Contains(stylesheet, "box-shadow") || strings.Contains(stylesheet, "Roomier") {
+		t.Errorf("Expected the stylesheet to be optimized for the reader, got %q", stylesheet)
+	}
+
+	// Every content document links it, after the built-in stylesheet where there is one
+	link := `href="../css/` + customCSSName + `"`
+	for name, content := range files {
+		if documentPartition(name) == "" {
+			continue
+		}
+		if !strings.Contains(content, link) {
+			t.Errorf("Expected %s to link the stylesheet", name)
+		}
+		if builtIn := strings.Index(content, "frontmatter.css"); builtIn != -1 && builtIn > strings.Index(content, link) {
+			t.Errorf("Expected %s to link the stylesheet after the built-in one", name)
+		}
+	}
+}
+
+func TestAddStylesheetLink(t *testing.T) {
+	document := "<html>\n  <head>\n    <title>Mort</title>\n  </head>\n  <body></body>\n</html>"
+	expected := "<html>\n  <head>\n    <title>Mort</title>\n    <link rel=\"stylesheet\" type=\"text/css\" href=\"../css/custom.css\"></link>\n  </head>\n  <body></body>\n</html>"
+	if result := string(addStylesheetLink([]byte(document), "../css/custom.css")); result != expected {
+		t.Errorf("addStylesheetLink() = %q, expected %q", result, expected)
+	}
+}
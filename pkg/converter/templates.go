@@ -0,0 +1,211 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Template names. A template directory overrides a built-in template with a file of the same name.
+const (
+	ChapterTemplate   = "chapter.html.tmpl"
+	CoverTemplate     = "cover.xhtml.tmpl"
+	TitlePageTemplate = "titlepage.html.tmpl"
+	ColophonTemplate  = "colophon.html.tmpl"
+	FixedPageTemplate = "fixedpage.xhtml.tmpl"
+)
+
+// xmlDeclaration starts every full document. html/template would escape it, so it's
+// added after rendering instead of living in the template.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// defaultTemplates reproduce publify's standard markup. Chapter, title page and colophon
+// templates render the body of a section (go-epub adds the surrounding document);
+// the cover and fixed-layout page templates render a whole document.
+var defaultTemplates = map[string]string{
+	ChapterTemplate: `<section epub:type="chapter" role="doc-chapter">
+{{if .ShowTitle}}<h1>{{.Title}}</h1>
+{{end}}{{.Content}}
+</section>`,
+
+	TitlePageTemplate: `<section epub:type="titlepage" class="titlepage">
+<h1 class="title">{{.Title}}</h1>
+{{if .Author}}<p class="author">{{.Author}}</p>
+{{end}}{{if .Publisher}}<p class="publisher">{{.Publisher}}</p>
+{{end}}</section>`,
+
+	ColophonTemplate: `<section epub:type="colophon" role="doc-colophon" class="colophon">
+<p>{{.Summary}}</p>
+{{if .Settings}}<ul>
+{{range .Settings}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</section>`,
+
+	CoverTemplate: `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+  <head>
+    <title>{{.Title}}</title>
+    <meta name="viewport" content="width={{.ViewportWidth}}, height={{.ViewportHeight}}"/>
+    <style type="text/css">
+      html, body { margin: 0; padding: 0; height: 100%; background-color: #FFFFFF; text-align: center; }
+      svg { display: block; width: 100%; height: 100%; }
+    </style>
+  </head>
+  <body epub:type="cover">
+    <svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" role="doc-cover" aria-label="{{.Title}}" width="100%" height="100%" viewBox="0 0 {{.ImageWidth}} {{.ImageHeight}}" preserveAspectRatio="xMidYMid meet">
+      <image width="{{.ImageWidth}}" height="{{.ImageHeight}}" xlink:href="{{.ImagePath}}"/>
+    </svg>
+  </body>
+</html>
+`,
+
+	FixedPageTemplate: `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+  <head>
+    <title>{{.Title}}</title>
+    <meta name="viewport" content="width={{.ViewportWidth}}, height={{.ViewportHeight}}"/>
+    <style type="text/css">
+      html, body { margin: 0; padding: 0; width: {{.ViewportWidth}}px; height: {{.ViewportHeight}}px; overflow: hidden; background-color: #FFFFFF; }
+      svg { position: absolute; top: 0; left: 0; width: 100%; height: 100%; }
+      .page-text { position: absolute; top: 0; left: 0; width: 100%; height: 100%; overflow: hidden; color: transparent; }
+      .page-text p { margin: 0; }
+    </style>
+  </head>
+  <body epub:type="bodymatter">
+    {{.PageBreak}}
+    <svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" role="img" aria-label="{{.Alt}}" width="100%" height="100%" viewBox="0 0 {{.ImageWidth}} {{.ImageHeight}}" preserveAspectRatio="xMidYMid meet">
+      <image width="{{.ImageWidth}}" height="{{.ImageHeight}}" xlink:href="{{.ImagePath}}"/>
+    </svg>{{.TextLayer}}
+  </body>
+</html>
+`,
+}
+
+// ChapterData is what the chapter template gets. Long chapters are split into parts;
+// the template runs once per part.
+type ChapterData struct {
+	Title     string
+	ShowTitle bool          // Only the first part of a chapter with a real title shows it
+	Part      int           // 1-based
+	Content   template.HTML // The converted page text and images
+}
+
+// TitlePageData is what the title page template gets
+type TitlePageData struct {
+	Title     string
+	Author    string
+	Publisher string
+}
+
+// ColophonData is what the colophon template gets
+type ColophonData struct {
+	Summary  string
+	Settings []string
+}
+
+// CoverData is what the cover template gets
+type CoverData struct {
+	Language       string
+	Title          string
+	ViewportWidth  int // The reader's screen, or the image when the screen size is unknown
+	ViewportHeight int
+	ImageWidth     int
+	ImageHeight    int
+	ImagePath      string // Relative to the cover document
+}
+
+// FixedPageData is what the fixed-layout page template gets, once per image page
+type FixedPageData struct {
+	Language       string
+	Title          string
+	ViewportWidth  int // The page scaled to fit the reader's screen
+	ViewportHeight int
+	ImageWidth     int
+	ImageHeight    int
+	ImagePath      string        // Relative to the page document
+	Alt            string        // What the page shows, for screen readers
+	PageBreak      template.HTML // The pagebreak marker the page-list points at
+	TextLayer      template.HTML // The page's text as an invisible layer ("" = none)
+}
+
+// Templates holds the markup templates for generated sections
+type Templates struct {
+	set map[string]*template.Template
+}
+
+var builtinTemplates = mustParseDefaults()
+
+func mustParseDefaults() *Templates {
+	t := &Templates{set: make(map[string]*template.Template)}
+	for name, text := range defaultTemplates {
+		t.set[name] = template.Must(template.New(name).Parse(text))
+	}
+	return t
+}
+
+// LoadTemplates reads templates from a directory, falling back to the built-in ones
+// for any that aren't there. An empty dir gives the built-in templates.
+func LoadTemplates(dir string) (*Templates, error) {
+	if dir == "" {
+		return builtinTemplates, nil
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("template directory not found: %s", dir)
+	}
+
+	t := &Templates{set: make(map[string]*template.Template)}
+	for name, tmpl := range builtinTemplates.set {
+		t.set[name] = tmpl
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".tmpl") {
+			continue
+		}
+		if _, known := defaultTemplates[name]; !known {
+			return nil, fmt.Errorf("unknown template %s (expected %s)", name, strings.Join(TemplateNames(), ", "))
+		}
+
+		text, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		tmpl, err := template.New(name).Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		t.set[name] = tmpl
+	}
+
+	return t, nil
+}
+
+// TemplateNames lists the templates that can be overridden
+func TemplateNames() []string {
+	return []string{ChapterTemplate, TitlePageTemplate, ColophonTemplate, CoverTemplate, FixedPageTemplate}
+}
+
+// DefaultTemplate returns the source of a built-in template, as a starting point for your own
+func DefaultTemplate(name string) string {
+	return defaultTemplates[name]
+}
+
+func (t *Templates) render(name string, data any) (string, error) {
+	if t == nil {
+		t = builtinTemplates
+	}
+
+	var buf bytes.Buffer
+	if err := t.set[name].Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
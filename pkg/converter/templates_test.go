@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestLoadTemplatesOverridesBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	chapter := `<section class="chapter">{{if .ShowTitle}}<h2 class="chapter-title">{{.Title}}</h2>{{end}}{{.Content}}</section>`
+	if err := os.WriteFile(filepath.Join(dir, ChapterTemplate), []byte(chapter), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+
+	profile := reader.Profile{
+		Name:         "Test Reader",
+		Capabilities: reader.DeviceCapabilities{DefaultFontSize: 12},
+	}
+	generator := NewEPUBGenerator(profile, EPUBOptions{Title: "Test Book", Templates: templates})
+	defer generator.Cleanup()
+
+	html, err := generator.createHTMLContent("Fish & Chips", 0, "<p>Text</p>")
+	if err != nil {
+		t.Fatalf("createHTMLContent failed: %v", err)
+	}
+	if want := `<section class="chapter"><h2 class="chapter-title">Fish &amp; Chips</h2><p>Text</p></section>`; html != want {
+		t.Errorf("Custom chapter template gave %q, want %q", html, want)
+	}
+
+	// Templates that weren't overridden keep the built-in markup
+	titlePage, err := templates.render(TitlePageTemplate, TitlePageData{Title: "Test Book"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(titlePage, `<h1 class="title">Test Book</h1>`) {
+		t.Errorf("Built-in title page template not used: %s", titlePage)
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	if _, err := LoadTemplates(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing template directory")
+	}
+
+	unknown := t.TempDir()
+	os.WriteFile(filepath.Join(unknown, "footnotes.html.tmpl"), []byte("x"), 0644)
+	if _, err := LoadTemplates(unknown); err == nil {
+		t.Error("Expected an error for an unknown template name")
+	}
+
+	broken := t.TempDir()
+	os.WriteFile(filepath.Join(broken, ColophonTemplate), []byte("{{if .Summary}"), 0644)
+	if _, err := LoadTemplates(broken); err == nil {
+		t.Error("Expected an error for a template that doesn't parse")
+	}
+}
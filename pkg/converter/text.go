@@ -294,71 +294,140 @@ func (tp *TextProcessor) EstimateTextSize(text string) int {
 	return len([]byte(processed))
 }
 
+// SplitIntoChunks splits chapter HTML into chunks of at most maxChunkSize bytes, between
+// its top-level elements. An element too big for a chunk of its own is split between
+// sentences, each part wrapped in the element's tags, so every chunk stays well-formed.
 func (tp *TextProcessor) SplitIntoChunks(text string, maxChunkSize int) []string {
 	if len(text) <= maxChunkSize {
 		return []string{text}
 	}
 
 	var chunks []string
-	paragraphs := strings.Split(text, "\n\n")
-	currentChunk := ""
-
-	for _, paragraph := range paragraphs {
-		if len(currentChunk)+len(paragraph)+2 > maxChunkSize {
-			if currentChunk != "" {
-				chunks = append(chunks, strings.TrimSpace(currentChunk))
-				currentChunk = ""
-			}
-
-			if len(paragraph) > maxChunkSize {
-				sentences := tp.splitBySentences(paragraph, maxChunkSize)
-				chunks = append(chunks, sentences...)
-			} else {
-				currentChunk = paragraph
-			}
-		} else {
-			if currentChunk != "" {
-				currentChunk += "\n\n"
-			}
-			currentChunk += paragraph
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
 		}
 	}
 
-	if currentChunk != "" {
-		chunks = append(chunks, strings.TrimSpace(currentChunk))
+	for _, block := range splitBlocks(text) {
+		if current.Len() > 0 && current.Len()+len(block)+1 > maxChunkSize {
+			flush()
+		}
+		if len(block) > maxChunkSize {
+			chunks = append(chunks, tp.splitBySentences(block, maxChunkSize)...)
+			continue
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(block)
 	}
+	flush()
 
 	return chunks
 }
 
-func (tp *TextProcessor) splitBySentences(paragraph string, maxSize int) []string {
-	sentences := regexp.MustCompile(`[.!?]+\s+`).Split(paragraph, -1)
-	var chunks []string
-	currentChunk := ""
+// splitBlocks splits HTML into its top-level elements, and any text between them
+func splitBlocks(text string) []string {
+	var blocks []string
+	add := func(block string) {
+		if block = strings.TrimSpace(block); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
 
-	for _, sentence := range sentences {
-		if len(currentChunk)+len(sentence)+1 > maxSize {
-			if currentChunk != "" {
-				chunks = append(chunks, strings.TrimSpace(currentChunk))
-				currentChunk = ""
-			}
+	depth, start := 0, 0
+	for _, loc := range tagPattern.FindAllStringIndex(text, -1) {
+		if depth == 0 {
+			add(text[start:loc[0]])
+			start = loc[0]
+		}
+		depth += tagDepth(text[loc[0]:loc[1]])
+		if depth <= 0 {
+			depth = 0
+			add(text[start:loc[1]])
+			start = loc[1]
+		}
+	}
+	add(text[start:])
 
-			if len(sentence) > maxSize {
-				chunks = append(chunks, strings.TrimSpace(sentence))
-			} else {
-				currentChunk = sentence
-			}
-		} else {
-			if currentChunk != "" {
-				currentChunk += " "
-			}
-			currentChunk += sentence
+	return blocks
+}
+
+// tagDepth is how much a tag changes the nesting depth: one deeper for a start tag, one
+// shallower for an end tag, and no change for empty elements, comments and declarations
+func tagDepth(tag string) int {
+	switch {
+	case strings.HasPrefix(tag, "</"):
+		return -1
+	case strings.HasSuffix(tag, "/>"), strings.HasPrefix(tag, "<!"), strings.HasPrefix(tag, "<?"):
+		return 0
+	}
+	return 1
+}
+
+// sentenceBreakPattern matches where a paragraph may be cut: after the end of a sentence,
+// closing quotes and brackets included, or after a line break
+var sentenceBreakPattern = regexp.MustCompile(`[.!?]+["'\x{201D}\x{2019})\]]*\s+|<br\s*/>\s*`)
+
+var idAttributePattern = regexp.MustCompile(`\s+id="[^"]*"`)
+
+// splitBySentences splits a block too big for one chunk between its sentences, keeping
+// their punctuation. Where the block is an element, each part is wrapped in its tags, with
+// the id left on the first part only. Sentences are only cut between, never inside, the
+// element's children.
+func (tp *TextProcessor) splitBySentences(block string, maxSize int) []string {
+	open, inner, closing := "", block, ""
+	if first := tagPattern.FindStringIndex(block); first != nil && first[0] == 0 && tagDepth(block[:first[1]]) == 1 {
+		if last := strings.LastIndex(block, "</"); last >= first[1] && strings.HasSuffix(block, ">") {
+			open, inner, closing = block[:first[1]], block[first[1]:last], block[last:]
 		}
 	}
 
-	if currentChunk != "" {
-		chunks = append(chunks, strings.TrimSpace(currentChunk))
+	var chunks []string
+	var current strings.Builder
+	budget := maxSize - len(open) - len(closing)
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			chunks = append(chunks, open+part+closing)
+			open = idAttributePattern.ReplaceAllString(open, "")
+		}
+		current.Reset()
 	}
 
+	for _, sentence := range splitSentences(inner) {
+		if current.Len() > 0 && current.Len()+len(sentence) > budget {
+			flush()
+		}
+		current.WriteString(sentence) // A sentence over budget gets a chunk to itself
+	}
+	flush()
+
 	return chunks
 }
+
+// splitSentences cuts HTML text after each sentence and line break outside its child
+// elements. The pieces put back together are the text as it was.
+func splitSentences(text string) []string {
+	tags := tagPattern.FindAllStringIndex(text, -1)
+	var sentences []string
+	depth, tag, start := 0, 0, 0
+	for _, brk := range sentenceBreakPattern.FindAllStringIndex(text, -1) {
+		for tag < len(tags) && tags[tag][1] <= brk[0] {
+			depth += tagDepth(text[tags[tag][0]:tags[tag][1]])
+			tag++
+		}
+		insideTag := tag < len(tags) && tags[tag][0] < brk[0]
+		if depth > 0 || insideTag || brk[0] < start {
+			continue
+		}
+		sentences = append(sentences, text[start:brk[1]])
+		start = brk[1]
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
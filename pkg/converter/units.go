@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cssPixelsPerInch and pointsPerInch are fixed by CSS: 1px = 1/96in, 1pt = 1/72in
+const (
+	cssPixelsPerInch = 96.0
+	pointsPerInch    = 72.0
+)
+
+var (
+	cssDeclarationPattern = regexp.MustCompile(`([a-zA-Z-]+)(\s*:\s*)([^;{}"<>]+)`)
+	pixelValuePattern     = regexp.MustCompile(`(^|[\s(,])(-?\d*\.?\d+)px\b`)
+	styleAttributePattern = regexp.MustCompile(`(\sstyle\s*=\s*")([^"]*)(")`)
+	styleElementPattern   = regexp.MustCompile(`(?s)(<style[^>]*>)(.*?)(</style>)`)
+)
+
+// widthProperties become a percentage of the screen; anything vertical stays in em,
+// since a percentage height depends on a container the reader decides
+var widthProperties = map[string]bool{
+	"width":     true,
+	"min-width": true,
+	"max-width": true,
+	"left":      true,
+	"right":     true,
+}
+
+// RelativeUnits rewrites pixel lengths in a stylesheet as em, or as a percentage of the
+// screen width for widths, so source styles made for a desktop screen come out the same
+// size on any reader. An em is taken as the profile's base font size; margins and padding
+// on text set in another size will be off by that factor, which beats being off by the
+// ratio between a monitor's DPI and an e-ink screen's. Borders keep their pixels so
+// hairlines stay hairlines.
+func (eo *EPUBOptimizer) RelativeUnits(css string) string {
+	return cssDeclarationPattern.ReplaceAllStringFunc(css, func(declaration string) string {
+		parts := cssDeclarationPattern.FindStringSubmatch(declaration)
+		property := strings.ToLower(parts[1])
+		if strings.HasPrefix(property, "border") || strings.HasPrefix(property, "outline") {
+			return declaration
+		}
+
+		value := pixelValuePattern.ReplaceAllStringFunc(parts[3], func(match string) string {
+			m := pixelValuePattern.FindStringSubmatch(match)
+			px, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				return match
+			}
+			return m[1] + eo.relativeLength(property, px)
+		})
+		return parts[1] + parts[2] + value
+	})
+}
+
+// RelativeUnitsHTML applies RelativeUnits to the style attributes and style elements
+// of an XHTML document, leaving its text alone
+func (eo *EPUBOptimizer) RelativeUnitsHTML(html string) string {
+	rewrite := func(pattern *regexp.Regexp) func(string) string {
+		return func(match string) string {
+			parts := pattern.FindStringSubmatch(match)
+			return parts[1] + eo.RelativeUnits(parts[2]) + parts[3]
+		}
+	}
+
+	html = styleElementPattern.ReplaceAllStringFunc(html, rewrite(styleElementPattern))
+	return styleAttributePattern.ReplaceAllStringFunc(html, rewrite(styleAttributePattern))
+}
+
+// relativeLength converts a CSS pixel length for the given property
+func (eo *EPUBOptimizer) relativeLength(property string, px float64) string {
+	if px == 0 {
+		return "0"
+	}
+
+	caps := eo.profile.Capabilities
+	if widthProperties[property] && caps.ScreenWidth > 0 && caps.DPI > 0 {
+		devicePixels := px * float64(caps.DPI) / cssPixelsPerInch
+		return formatLength(math.Min(devicePixels/float64(caps.ScreenWidth)*100, 100), "%")
+	}
+
+	fontSize := caps.DefaultFontSize
+	if fontSize <= 0 {
+		fontSize = 12
+	}
+	basePixels := float64(fontSize) * cssPixelsPerInch / pointsPerInch
+	return formatLength(px/basePixels, "em")
+}
+
+// formatLength rounds a length to three decimals, which is finer than any screen
+func formatLength(value float64, unit string) string {
+	return strconv.FormatFloat(math.Round(value*1000)/1000, 'f', -1, 64) + unit
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/alde/publify/pkg/reader"
+)
+
+func TestRelativeUnits(t *testing.T) {
+	optimizer := NewEPUBOptimizer(reader.Profile{
+		Capabilities: reader.DeviceCapabilities{ScreenWidth: 1200, DPI: 300, DefaultFontSize: 12},
+	})
+
+	tests := []struct {
+		css      string
+		expected string
+	}{
+		// 12pt is 16 CSS pixels
+		{"p { font-size: 24px; }", "p { font-size: 1.5em; }"},
+		{"p{margin:0px 8px -4px 16px}", "p{margin:0 0.5em -0.25em 1em}"},
+		// 192 CSS pixels are 2 inches, 600 of the screen's 1200 dots
+		{"img { max-width: 192px; }", "img { max-width: 50%; }"},
+		{"div { width: 2000px; }", "div { width: 100%; }"},
+		{"hr { border-top: 1px solid; }", "hr { border-top: 1px solid; }"},
+		{"p { text-indent: 1.5em; line-height: 120%; }", "p { text-indent: 1.5em; line-height: 120%; }"},
+	}
+
+	for _, test := range tests {
+		if result := optimizer.RelativeUnits(test.css); result != test.expected {
+			t.Errorf("RelativeUnits(%q) = %q, expected %q", test.css, result, test.expected)
+		}
+	}
+}
+
+func TestRelativeUnitsHTML(t *testing.T) {
+	optimizer := NewEPUBOptimizer(reader.Profile{
+		Capabilities: reader.DeviceCapabilities{DefaultFontSize: 12},
+	})
+
+	html := `<style>h1 { font-size: 32px; }</style><p style="margin-left: 16px">Set in 12px: height 10px</p>`
+	expected := `<style>h1 { font-size: 2em; }</style><p style="margin-left: 1em">Set in 12px: height 10px</p>`
+	if result := optimizer.RelativeUnitsHTML(html); result != expected {
+		t.Errorf("RelativeUnitsHTML() = %q, expected %q", result, expected)
+	}
+}
@@ -0,0 +1,189 @@
+// Package eval scores conversions against golden text, to tell whether a new version or a
+// different setting does better or worse on a set of books.
+//
+// A corpus is a directory of PDFs, each with its golden text next to it under the same
+// name: book.pdf and book.txt. The golden text has the pages separated by form feeds,
+// which is what pdftotext writes, so it can be made with pdftotext and then corrected by
+// hand. PDFs without golden text are left out.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/internal/worker"
+	"github.com/alde/publify/pkg/converter"
+)
+
+// pageSeparator separates pages in golden text
+const pageSeparator = "\f"
+
+// Fixture is a PDF and its golden text
+type Fixture struct {
+	Name   string
+	PDF    string
+	Golden string
+}
+
+// FindFixtures lists the PDFs in a directory that have golden text, in name order
+func FindFixtures(dir string) ([]Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus directory: %w", err)
+	}
+
+	var fixtures []Fixture
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".pdf") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		golden := filepath.Join(dir, name+".txt")
+		if _, err := os.Stat(golden); err != nil {
+			continue
+		}
+		fixtures = append(fixtures, Fixture{
+			Name:   name,
+			PDF:    filepath.Join(dir, entry.Name()),
+			Golden: golden,
+		})
+	}
+
+	return fixtures, nil
+}
+
+// PageScore is one page's score
+type PageScore struct {
+	Page  int   `json:"page"`
+	Score Score `json:"score"`
+}
+
+// Result is how one fixture did
+type Result struct {
+	Name     string        `json:"name"`
+	Score    Score         `json:"score"`
+	Pages    []PageScore   `json:"pages"`
+	Duration time.Duration `json:"duration"`
+}
+
+// WorstPages returns up to n pages with the most word errors, worst first
+func (r Result) WorstPages(n int) []PageScore {
+	pages := make([]PageScore, 0, len(r.Pages))
+	for _, page := range r.Pages {
+		if page.Score.WordErrors > 0 {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Score.WordErrors > pages[j].Score.WordErrors
+	})
+	if len(pages) > n {
+		pages = pages[:n]
+	}
+	return pages
+}
+
+// Run converts a fixture's pages with the given settings and scores their text. Pages
+// past the end of the golden text are scored against nothing, so text the converter
+// invents there counts against it.
+func Run(fixture Fixture, opts converter.PDFProcessorOptions) (Result, error) {
+	start := time.Now()
+
+	golden, err := os.ReadFile(fixture.Golden)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read golden text: %w", err)
+	}
+	goldenPages := strings.Split(string(golden), pageSeparator)
+
+	processor, err := converter.NewPDFProcessor(fixture.PDF, opts)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to open %s: %w", fixture.PDF, err)
+	}
+	defer processor.Close()
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	pool := worker.NewPool(workers)
+	pool.Start()
+	defer pool.Stop()
+
+	pages, err := processor.ProcessPages(context.Background(), pool, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to process %s: %w", fixture.PDF, err)
+	}
+
+	result := Result{Name: fixture.Name}
+	for _, page := range pages {
+		var reference string
+		if page.Number <= len(goldenPages) {
+			reference = goldenPages[page.Number-1]
+		}
+		score := Compare(reference, page.Text)
+		result.Pages = append(result.Pages, PageScore{Page: page.Number, Score: score})
+		result.Score.Add(score)
+	}
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// Report is the outcome of a run over a corpus, saved to compare later runs against
+type Report struct {
+	Version  string    `json:"version"`
+	Settings []string  `json:"settings,omitempty"`
+	Created  time.Time `json:"created"`
+	Results  []Result  `json:"results"`
+}
+
+// Total is the corpus-wide score
+func (r Report) Total() Score {
+	var total Score
+	for _, result := range r.Results {
+		total.Add(result.Score)
+	}
+	return total
+}
+
+// Find returns the result for a fixture, if the report has one
+func (r Report) Find(name string) (Result, bool) {
+	for _, result := range r.Results {
+		if result.Name == name {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// Save writes the report as JSON
+func (r Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// LoadReport reads a report saved earlier
+func LoadReport(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return report, nil
+}
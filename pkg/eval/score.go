@@ -0,0 +1,80 @@
+package eval
+
+import "strings"
+
+// Score counts the edits needed to turn converted text into its golden text, in words and
+// in characters. Scores add up, so a book's score is the sum of its pages'.
+type Score struct {
+	WordErrors int `json:"word_errors"`
+	Words      int `json:"words"` // Words in the golden text
+	CharErrors int `json:"char_errors"`
+	Chars      int `json:"chars"` // Characters in the golden text
+}
+
+// WER is the word error rate: edits per golden word, 0 for a perfect match. It can go
+// past 1 when the conversion adds text that isn't in the golden text.
+func (s Score) WER() float64 {
+	return rate(s.WordErrors, s.Words)
+}
+
+// CER is the character error rate, which is kinder to OCR that gets most of a word right
+func (s Score) CER() float64 {
+	return rate(s.CharErrors, s.Chars)
+}
+
+func (s *Score) Add(other Score) {
+	s.WordErrors += other.WordErrors
+	s.Words += other.Words
+	s.CharErrors += other.CharErrors
+	s.Chars += other.Chars
+}
+
+func rate(errors, total int) float64 {
+	if total == 0 {
+		if errors == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(errors) / float64(total)
+}
+
+// Compare scores converted text against golden text. White space is normalized first:
+// where the line breaks fall is layout, not text.
+func Compare(golden, converted string) Score {
+	goldenWords := strings.Fields(golden)
+	convertedWords := strings.Fields(converted)
+	goldenChars := []rune(strings.Join(goldenWords, " "))
+	convertedChars := []rune(strings.Join(convertedWords, " "))
+
+	return Score{
+		WordErrors: editDistance(goldenWords, convertedWords),
+		Words:      len(goldenWords),
+		CharErrors: editDistance(goldenChars, convertedChars),
+		Chars:      len(goldenChars),
+	}
+}
+
+// editDistance is the Levenshtein distance: insertions, deletions and substitutions.
+// It keeps two rows of the table, so a page's worth of characters is no trouble.
+func editDistance[T comparable](a, b []T) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
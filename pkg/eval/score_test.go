@@ -0,0 +1,76 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name       string
+		golden     string
+		converted  string
+		wordErrors int
+		charErrors int
+	}{
+		{"identical", "The quick brown fox", "The quick brown fox", 0, 0},
+		{"line breaks don't count", "The quick\nbrown  fox", "The quick brown\nfox", 0, 0},
+		{"substitution", "The quick brown fox", "The quick brawn fox", 1, 1},
+		{"missing word", "The quick brown fox", "The quick fox", 1, 6},
+		{"extra word", "The fox", "The red fox", 1, 4},
+		{"empty conversion", "The fox", "", 2, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Compare(tt.golden, tt.converted)
+			if score.WordErrors != tt.wordErrors {
+				t.Errorf("WordErrors = %d, expected %d", score.WordErrors, tt.wordErrors)
+			}
+			if score.CharErrors != tt.charErrors {
+				t.Errorf("CharErrors = %d, expected %d", score.CharErrors, tt.charErrors)
+			}
+		})
+	}
+}
+
+func TestScoreRates(t *testing.T) {
+	var total Score
+	total.Add(Compare("one two three four", "one two three for"))
+	total.Add(Compare("five six seven eight", "five six seven eight"))
+
+	if total.Words != 8 || total.WordErrors != 1 {
+		t.Fatalf("Expected 1 error in 8 words, got %d in %d", total.WordErrors, total.Words)
+	}
+	if wer := total.WER(); wer != 0.125 {
+		t.Errorf("WER = %v, expected 0.125", wer)
+	}
+
+	if wer := Compare("", "invented text").WER(); wer != 1 {
+		t.Errorf("WER against empty golden text = %v, expected 1", wer)
+	}
+	if wer := Compare("", "").WER(); wer != 0 {
+		t.Errorf("WER of two empty texts = %v, expected 0", wer)
+	}
+}
+
+func TestFindFixtures(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.pdf", "a.txt", "b.pdf", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fixtures, err := FindFixtures(dir)
+	if err != nil {
+		t.Fatalf("FindFixtures failed: %v", err)
+	}
+	if len(fixtures) != 1 || fixtures[0].Name != "a" {
+		t.Fatalf("Expected only the PDF with golden text, got %+v", fixtures)
+	}
+	if fixtures[0].Golden != filepath.Join(dir, "a.txt") {
+		t.Errorf("Golden = %s", fixtures[0].Golden)
+	}
+}
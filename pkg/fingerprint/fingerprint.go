@@ -0,0 +1,153 @@
+// Package fingerprint tells whether two files hold the same book, whatever their format.
+//
+// A fingerprint is a sketch of a text's shingles, the runs of a few consecutive words in
+// it: the smallest hashes among them. Two texts' sketches estimate how many shingles they
+// share, so a PDF and the EPUB converted from it come out nearly the same, while line
+// breaks, hyphenation, running heads and page numbers barely count.
+package fingerprint
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// prefix marks a fingerprint and the version of the scheme, which fingerprints made
+	// differently can't be compared with
+	prefix = "fp1:"
+
+	shingleWords = 5  // Words in a shingle
+	sketchSize   = 64 // Hashes kept; more gives a closer estimate and a longer fingerprint
+
+	// minWords is the least text worth fingerprinting: anything shorter matches too easily
+	minWords = 50
+)
+
+// Similarity levels at which two books are taken to be the same, or the same text with
+// differences, such as another edition or a conversion with OCR errors
+const (
+	Same    = 0.8
+	Related = 0.3
+)
+
+// Fingerprint is a sketch of a text, its shingle hashes in ascending order
+type Fingerprint []uint32
+
+// Compute fingerprints a text. Texts too short to tell apart get an empty fingerprint.
+func Compute(text string) Fingerprint {
+	words := Words(text)
+	if len(words) < minWords {
+		return nil
+	}
+
+	seen := make(map[uint32]bool)
+	for i := 0; i+shingleWords <= len(words); i++ {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Join(words[i:i+shingleWords], " ")))
+		seen[h.Sum32()] = true
+	}
+
+	sketch := make(Fingerprint, 0, len(seen))
+	for hash := range seen {
+		sketch = append(sketch, hash)
+	}
+	sort.Slice(sketch, func(i, j int) bool { return sketch[i] < sketch[j] })
+	if len(sketch) > sketchSize {
+		sketch = sketch[:sketchSize]
+	}
+	return sketch
+}
+
+// Words splits a text into the words a fingerprint is made of: lowercased letters and
+// digits, without numbers standing alone, which are mostly page numbers
+func Words(text string) []string {
+	text = strings.ReplaceAll(text, "\u00ad", "") // Soft hyphens split words
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	kept := words[:0]
+	for _, word := range words {
+		if strings.IndexFunc(word, unicode.IsLetter) != -1 {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+// String encodes a fingerprint to store in metadata ("" = empty)
+func (f Fingerprint) String() string {
+	if len(f) == 0 {
+		return ""
+	}
+	data := make([]byte, 4*len(f))
+	for i, hash := range f {
+		binary.BigEndian.PutUint32(data[4*i:], hash)
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Parse decodes a fingerprint written by String
+func Parse(s string) (Fingerprint, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(s), prefix)
+	if !ok {
+		return nil, fmt.Errorf("not a fingerprint this version can read: %q", s)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data)%4 != 0 {
+		return nil, fmt.Errorf("malformed fingerprint: %q", s)
+	}
+
+	f := make(Fingerprint, len(data)/4)
+	for i := range f {
+		f[i] = binary.BigEndian.Uint32(data[4*i:])
+	}
+	if !sort.SliceIsSorted(f, func(i, j int) bool { return f[i] < f[j] }) {
+		return nil, fmt.Errorf("malformed fingerprint: %q", s)
+	}
+	return f, nil
+}
+
+// Similarity estimates the share of shingles two texts have in common, from 0 for
+// nothing to 1 for the same text. The smallest hashes of the two together are a random
+// sample of all their shingles; the share of the sample found in both is the estimate.
+func (f Fingerprint) Similarity(other Fingerprint) float64 {
+	if len(f) == 0 || len(other) == 0 {
+		return 0
+	}
+
+	size := min(max(len(f), len(other)), sketchSize)
+	inBoth, sampled := 0, 0
+	i, j := 0, 0
+	for sampled < size && (i < len(f) || j < len(other)) {
+		switch {
+		case j == len(other) || (i < len(f) && f[i] < other[j]):
+			i++
+		case i == len(f) || other[j] < f[i]:
+			j++
+		default:
+			inBoth++
+			i++
+			j++
+		}
+		sampled++
+	}
+	return float64(inBoth) / float64(sampled)
+}
+
+// Verdict says in words what a similarity means
+func Verdict(similarity float64) string {
+	switch {
+	case similarity >= Same:
+		return "the same book"
+	case similarity >= Related:
+		return "the same book with differences (another edition, or OCR errors)"
+	default:
+		return "different books"
+	}
+}
@@ -0,0 +1,94 @@
+package fingerprint
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readGolden(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/romeo-and-juliet.txt")
+	if err != nil {
+		t.Skipf("No fixture text: %v", err)
+	}
+	return string(data)
+}
+
+func TestSimilarity(t *testing.T) {
+	text := readGolden(t)
+	words := strings.Fields(text)
+	half := len(words) / 2
+
+	// The same text laid out as an EPUB would have it: one paragraph per line, without
+	// the page breaks and numbers
+	var epub strings.Builder
+	for i, page := range strings.Split(text, "\f") {
+		fmt.Fprintf(&epub, "%s\n%d\n", strings.Join(strings.Fields(page), " "), i+1)
+	}
+
+	// Every twentieth word misread
+	garbled := append([]string(nil), words...)
+	for i := 0; i < len(garbled); i += 20 {
+		garbled[i] = "x" + garbled[i]
+	}
+
+	original := Compute(text)
+	tests := []struct {
+		name     string
+		other    string
+		expected string
+	}{
+		{"reflowed", epub.String(), Verdict(Same)},
+		{"misread", strings.Join(garbled, " "), Verdict(Related)},
+	}
+	for _, test := range tests {
+		similarity := original.Similarity(Compute(test.other))
+		if verdict := Verdict(similarity); verdict != test.expected {
+			t.Errorf("%s: similarity %.2f is %q, expected %q", test.name, similarity, verdict, test.expected)
+		}
+	}
+
+	if Compute(strings.Join(words[:half], " ")).Similarity(Compute(strings.Join(words[half:], " "))) >= Related {
+		t.Error("Expected the two halves of the play to be different books")
+	}
+}
+
+func TestShortText(t *testing.T) {
+	short := Compute("Two households, both alike in dignity.")
+	if len(short) != 0 || short.String() != "" {
+		t.Errorf("Expected no fingerprint for a short text, got %q", short)
+	}
+	if short.Similarity(short) != 0 {
+		t.Error("Expected empty fingerprints not to match")
+	}
+}
+
+func TestParse(t *testing.T) {
+	original := Compute(readGolden(t))
+	if len(original) != sketchSize {
+		t.Fatalf("Expected %d hashes, got %d", sketchSize, len(original))
+	}
+
+	parsed, err := Parse(original.String())
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if parsed.Similarity(original) != 1 {
+		t.Error("Expected a parsed fingerprint to match the original")
+	}
+
+	for _, bad := range []string{"", "fp2:AAAA", "fp1:not base64!", "fp1:AAAB", "fp1:AAAAAgAAAAE"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", bad)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	got := strings.Join(Words("Two house-\nholds, both ALIKE in dig\u00adnity.\n\n12\n\nIn fair Verona"), " ")
+	if expected := "two house holds both alike in dignity in fair verona"; got != expected {
+		t.Errorf("Words() = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,323 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Citation styles
+const (
+	CiteBibTeX  = "bibtex"
+	CiteRIS     = "ris"
+	CiteCSLJSON = "csl-json"
+)
+
+// CitationStyles lists the styles publify cite writes
+var CitationStyles = []string{CiteBibTeX, CiteRIS, CiteCSLJSON}
+
+var doiPattern = regexp.MustCompile(`(?i)\b(10\.\d{4,9}/[^\s"<>]+)`)
+
+// FindDOI returns the DOI in an identifier or text ("doi:10.1000/xyz", "https://doi.org/10.1000/xyz"),
+// or "" if there isn't one
+func FindDOI(text string) string {
+	match := doiPattern.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimRight(match[1], ".,;")
+}
+
+// Cite writes citation entries for books, one after the other (or as one list for CSL JSON)
+func Cite(books []EPUBMetadata, style string) (string, error) {
+	keys := citationKeys(books)
+	switch style {
+	case CiteBibTeX:
+		entries := make([]string, len(books))
+		for i, book := range books {
+			entries[i] = citeBibTeX(book, keys[i])
+		}
+		return strings.Join(entries, "\n"), nil
+	case CiteRIS:
+		entries := make([]string, len(books))
+		for i, book := range books {
+			entries[i] = citeRIS(book)
+		}
+		return strings.Join(entries, "\n"), nil
+	case CiteCSLJSON:
+		items := make([]cslItem, len(books))
+		for i, book := range books {
+			items[i] = newCSLItem(book, keys[i])
+		}
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to write CSL JSON: %w", err)
+		}
+		return string(data) + "\n", nil
+	}
+	return "", fmt.Errorf("unknown citation style %q (use %s)", style, strings.Join(CitationStyles, ", "))
+}
+
+// citedPeople splits a book's people into its authors, editors and translators, wherever
+// they were credited. A book credited only to editors (an anthology) has no authors.
+func citedPeople(book EPUBMetadata) (authors, editors, translators []string) {
+	for _, person := range append(append([]Contributor{}, book.Creators...), book.Contributors...) {
+		switch person.Role {
+		case "aut":
+			authors = append(authors, person.Name)
+		case "edt":
+			editors = append(editors, person.Name)
+		case "trl":
+			translators = append(translators, person.Name)
+		}
+	}
+	if len(authors) == 0 && len(editors) == 0 && book.Author != "" {
+		authors = []string{book.Author}
+	}
+	return authors, editors, translators
+}
+
+// splitName splits a name into family and given names. "Pratchett, Terry" says so itself;
+// otherwise the family name is taken to be the last word.
+func splitName(name string) (family, given string) {
+	name = strings.TrimSpace(name)
+	if family, given, found := strings.Cut(name, ","); found {
+		return strings.TrimSpace(family), strings.TrimSpace(given)
+	}
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return name[i+1:], strings.TrimSpace(name[:i])
+	}
+	return name, ""
+}
+
+// invertedName is a name family name first, "Pratchett, Terry", as BibTeX and RIS want it
+func invertedName(name string) string {
+	family, given := splitName(name)
+	if given == "" {
+		return family
+	}
+	return family + ", " + given
+}
+
+// year is the year the book was published, or "" if it doesn't say
+func year(book EPUBMetadata) string {
+	if book.Created.IsZero() {
+		return ""
+	}
+	return strconv.Itoa(book.Created.Year())
+}
+
+// citationKeys gives each book a key, with a letter added where two would otherwise share
+// one (pratchett1987mort, pratchett1987mortb)
+func citationKeys(books []EPUBMetadata) []string {
+	keys := make([]string, len(books))
+	seen := make(map[string]int)
+	for i, book := range books {
+		key := citationKey(book)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key += string(rune('a' + n - 1))
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// citationKey is a key from the first author's family name, the year and the first word
+// of the title that isn't an article or the like, such as pratchett1987mort
+func citationKey(book EPUBMetadata) string {
+	authors, editors, _ := citedPeople(book)
+	people := authors
+	if len(people) == 0 {
+		people = editors
+	}
+	name := ""
+	if len(people) > 0 {
+		name, _ = splitName(people[0])
+	}
+
+	word := ""
+	for _, field := range strings.Fields(book.Title) {
+		part := keyPart(field)
+		if word == "" || len(part) > 3 {
+			word = part
+		}
+		if len(part) > 3 {
+			break
+		}
+	}
+
+	key := keyPart(name) + year(book) + word
+	if key == "" {
+		return "book"
+	}
+	return key
+}
+
+// keyPart lowercases a word to plain ASCII letters and digits, dropping accents
+func keyPart(word string) string {
+	var key strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(word)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+var bibTeXEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`, "}", `\}`,
+	"&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+	"~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
+
+func citeBibTeX(book EPUBMetadata, key string) string {
+	authors, editors, translators := citedPeople(book)
+	names := func(people []string) string {
+		inverted := make([]string, len(people))
+		for i, person := range people {
+			inverted[i] = invertedName(person)
+		}
+		return strings.Join(inverted, " and ")
+	}
+
+	var fields [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{name, bibTeXEscaper.Replace(value)})
+		}
+	}
+	add("author", names(authors))
+	add("editor", names(editors))
+	add("translator", names(translators))
+	add("title", book.Title)
+	add("series", book.Series)
+	if book.SeriesIndex > 0 {
+		add("volume", strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64))
+	}
+	add("publisher", book.Publisher)
+	add("year", year(book))
+	add("isbn", book.ISBN)
+	add("doi", book.DOI)
+	add("language", book.Language)
+	add("keywords", strings.Join(book.Subjects, ", "))
+
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "@book{%s,\n", key)
+	for i, field := range fields {
+		fmt.Fprintf(&entry, "  %s = {%s}", field[0], field[1])
+		if i < len(fields)-1 {
+			entry.WriteString(",")
+		}
+		entry.WriteString("\n")
+	}
+	entry.WriteString("}\n")
+	return entry.String()
+}
+
+func citeRIS(book EPUBMetadata) string {
+	authors, editors, translators := citedPeople(book)
+
+	var entry strings.Builder
+	add := func(tag, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&entry, "%s  - %s\n", tag, strings.Join(strings.Fields(value), " "))
+		}
+	}
+	add("TY", "BOOK")
+	for _, author := range authors {
+		add("AU", invertedName(author))
+	}
+	for _, editor := range editors {
+		add("A2", invertedName(editor))
+	}
+	for _, translator := range translators {
+		add("A4", invertedName(translator))
+	}
+	add("TI", book.Title)
+	add("T3", book.Series)
+	if book.SeriesIndex > 0 {
+		add("VL", strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64))
+	}
+	add("PB", book.Publisher)
+	add("PY", year(book))
+	add("SN", book.ISBN)
+	add("DO", book.DOI)
+	add("LA", book.Language)
+	for _, subject := range book.Subjects {
+		add("KW", subject)
+	}
+	entry.WriteString("ER  - \n")
+	return entry.String()
+}
+
+// cslItem is a CSL JSON item, as Zotero, Pandoc and citeproc read them
+type cslItem struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	Title            string    `json:"title,omitempty"`
+	Author           []cslName `json:"author,omitempty"`
+	Editor           []cslName `json:"editor,omitempty"`
+	Translator       []cslName `json:"translator,omitempty"`
+	CollectionTitle  string    `json:"collection-title,omitempty"`
+	CollectionNumber string    `json:"collection-number,omitempty"`
+	Publisher        string    `json:"publisher,omitempty"`
+	Issued           *cslDate  `json:"issued,omitempty"`
+	ISBN             string    `json:"ISBN,omitempty"`
+	DOI              string    `json:"DOI,omitempty"`
+	Language         string    `json:"language,omitempty"`
+	Keyword          string    `json:"keyword,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"` // A name that doesn't split, such as an organisation
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func newCSLItem(book EPUBMetadata, key string) cslItem {
+	authors, editors, translators := citedPeople(book)
+	names := func(people []string) []cslName {
+		var result []cslName
+		for _, person := range people {
+			if family, given := splitName(person); given != "" {
+				result = append(result, cslName{Family: family, Given: given})
+			} else {
+				result = append(result, cslName{Literal: family})
+			}
+		}
+		return result
+	}
+
+	item := cslItem{
+		ID:              key,
+		Type:            "book",
+		Title:           book.Title,
+		Author:          names(authors),
+		Editor:          names(editors),
+		Translator:      names(translators),
+		CollectionTitle: book.Series,
+		Publisher:       book.Publisher,
+		ISBN:            book.ISBN,
+		DOI:             book.DOI,
+		Language:        book.Language,
+		Keyword:         strings.Join(book.Subjects, ", "),
+	}
+	if book.SeriesIndex > 0 {
+		item.CollectionNumber = strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64)
+	}
+	if !book.Created.IsZero() {
+		item.Issued = &cslDate{DateParts: [][]int{{book.Created.Year()}}}
+	}
+	return item
+}
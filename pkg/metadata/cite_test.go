@@ -0,0 +1,153 @@
+package metadata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var citedBook = EPUBMetadata{
+	Title:        "Mort",
+	Creators:     []Contributor{{Name: "Terry Pratchett", Role: "aut"}},
+	Contributors: []Contributor{{Name: "Andrzej Sapkowski", Role: "trl"}},
+	Series:       "Discworld",
+	SeriesIndex:  4,
+	Publisher:    "Victor Gollancz & Co",
+	Language:     "en",
+	ISBN:         "9780575041714",
+	DOI:          "10.1000/mort",
+	Created:      time.Date(1987, 11, 12, 0, 0, 0, 0, time.UTC),
+	Subjects:     []string{"Fantasy", "Death"},
+}
+
+func TestCiteBibTeX(t *testing.T) {
+	entry, err := Cite([]EPUBMetadata{citedBook}, CiteBibTeX)
+	if err != nil {
+		t.Fatalf("Cite() failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"@book{pratchett1987mort,\n",
+		"  author = {Pratchett, Terry},\n",
+		"  translator = {Sapkowski, Andrzej},\n",
+		"  volume = {4},\n",
+		`  publisher = {Victor Gollancz \& Co},` + "\n",
+		"  isbn = {9780575041714},\n",
+		"  doi = {10.1000/mort},\n",
+		"  keywords = {Fantasy, Death}\n}\n",
+	} {
+		if !strings.Contains(entry, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, entry)
+		}
+	}
+}
+
+func TestCiteRIS(t *testing.T) {
+	entry, err := Cite([]EPUBMetadata{citedBook}, CiteRIS)
+	if err != nil {
+		t.Fatalf("Cite() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(entry, "TY  - BOOK\nAU  - Pratchett, Terry\nA4  - Sapkowski, Andrzej\nTI  - Mort\n") {
+		t.Errorf("Unexpected start of entry:\n%s", entry)
+	}
+	for _, expected := range []string{"PY  - 1987\n", "SN  - 9780575041714\n", "DO  - 10.1000/mort\n", "KW  - Death\n"} {
+		if !strings.Contains(entry, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, entry)
+		}
+	}
+	if !strings.HasSuffix(entry, "ER  - \n") {
+		t.Errorf("Expected the entry to end with ER:\n%s", entry)
+	}
+}
+
+func TestCiteCSLJSON(t *testing.T) {
+	anthology := EPUBMetadata{Title: "The Folio Anthology", Creators: []Contributor{{Name: "Folio Society", Role: "edt"}}}
+	output, err := Cite([]EPUBMetadata{citedBook, anthology}, CiteCSLJSON)
+	if err != nil {
+		t.Fatalf("Cite() failed: %v", err)
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		t.Fatalf("Output isn't JSON: %v\n%s", err, output)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0]["id"] != "pratchett1987mort" || items[0]["ISBN"] != "9780575041714" || items[0]["collection-number"] != "4" {
+		t.Errorf("Unexpected item: %v", items[0])
+	}
+	author := items[0]["author"].([]any)[0].(map[string]any)
+	if author["family"] != "Pratchett" || author["given"] != "Terry" {
+		t.Errorf("Unexpected author: %v", author)
+	}
+	if _, ok := items[1]["author"]; ok {
+		t.Errorf("Expected an anthology to have editors only: %v", items[1])
+	}
+	if items[1]["id"] != "societyfolio" {
+		t.Errorf("Expected the editor and title in the key, got %v", items[1]["id"])
+	}
+}
+
+func TestCiteUnknownStyle(t *testing.T) {
+	if _, err := Cite([]EPUBMetadata{citedBook}, "mla"); err == nil {
+		t.Error("Expected an error for an unknown style")
+	}
+}
+
+func TestCitationKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		book     EPUBMetadata
+		expected string
+	}{
+		{"family name first", EPUBMetadata{Author: "Gaiman, Neil", Title: "Good Omens"}, "gaimangood"},
+		{"skips short words", EPUBMetadata{Author: "Terry Pratchett", Title: "The Colour of Magic"}, "pratchettcolour"},
+		{"drops accents", EPUBMetadata{Author: "Gabriel García Márquez", Title: "Cien años de soledad"}, "marquezcien"},
+		{"nothing to go on", EPUBMetadata{}, "book"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := citationKey(tt.book); key != tt.expected {
+				t.Errorf("citationKey() = %q, expected %q", key, tt.expected)
+			}
+		})
+	}
+
+	keys := citationKeys([]EPUBMetadata{citedBook, citedBook, citedBook})
+	if strings.Join(keys, ",") != "pratchett1987mort,pratchett1987mortb,pratchett1987mortc" {
+		t.Errorf("Expected duplicate keys told apart, got %v", keys)
+	}
+}
+
+func TestFindDOI(t *testing.T) {
+	tests := map[string]string{
+		"doi:10.1000/xyz123":                 "10.1000/xyz123",
+		"https://doi.org/10.1038/nphys1170.": "10.1038/nphys1170",
+		"urn:isbn:9780575041714":             "",
+		"urn:uuid:8b3b0e1e-10.1000-4a4b":     "",
+	}
+	for text, expected := range tests {
+		if doi := FindDOI(text); doi != expected {
+			t.Errorf("FindDOI(%q) = %q, expected %q", text, doi, expected)
+		}
+	}
+}
+
+func TestParseOPFDate(t *testing.T) {
+	for value, expected := range map[string]int{
+		"1987":                 1987,
+		"1987-11":              1987,
+		"1987-11-12":           1987,
+		"1987-11-12T00:00:00Z": 1987,
+	} {
+		if date := parseOPFDate(value); date.Year() != expected {
+			t.Errorf("parseOPFDate(%q) = %v", value, date)
+		}
+	}
+	if date := parseOPFDate("sometime"); !date.IsZero() {
+		t.Errorf("Expected no date for an unreadable value, got %v", date)
+	}
+}
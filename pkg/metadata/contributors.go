@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Contributor is a person credited in the book, with a MARC relator code describing their role
+type Contributor struct {
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"` // MARC relator code, e.g. aut, edt, ill, trl
+}
+
+// Roles maps the MARC relator codes publify knows to readable names.
+// See https://www.loc.gov/marc/relators/relaterm.html for the full list.
+var Roles = map[string]string{
+	"aut": "Author",
+	"edt": "Editor",
+	"ill": "Illustrator",
+	"trl": "Translator",
+	"nrt": "Narrator",
+	"aui": "Author of introduction",
+	"aft": "Author of afterword",
+	"art": "Artist",
+	"cov": "Cover designer",
+	"pht": "Photographer",
+	"bkp": "Book producer",
+	"ctb": "Contributor",
+}
+
+// roleCodes lists the known codes in a sensible order for help and error messages
+var roleCodes = []string{"aut", "edt", "ill", "trl", "nrt", "aui", "aft", "art", "cov", "pht", "bkp", "ctb"}
+
+// RoleName returns the readable name of a relator code, or the code itself if it's unknown
+func RoleName(code string) string {
+	if name, ok := Roles[code]; ok {
+		return name
+	}
+	return code
+}
+
+// ParseContributor parses "Name:role", e.g. "Jane Doe:trl". Without a role suffix the
+// default role is used. Unknown role codes are rejected so typos don't end up in the book.
+func ParseContributor(value, defaultRole string) (Contributor, error) {
+	name, role := strings.TrimSpace(value), defaultRole
+	if idx := strings.LastIndex(value, ":"); idx != -1 {
+		name = strings.TrimSpace(value[:idx])
+		role = strings.ToLower(strings.TrimSpace(value[idx+1:]))
+		if _, ok := Roles[role]; !ok {
+			return Contributor{}, fmt.Errorf("unknown role %q in %q (known roles: %s)", role, value, knownRoles())
+		}
+	}
+
+	if name == "" {
+		return Contributor{}, fmt.Errorf("missing name in %q", value)
+	}
+
+	return Contributor{Name: name, Role: role}, nil
+}
+
+func knownRoles() string {
+	return strings.Join(roleCodes, ", ")
+}
+
+// replacePeople removes every creator and contributor (and the metas refining them) from
+// the OPF, then adds the given ones. EPUB 3 packages get their roles as refining metas,
+// EPUB 2 packages as opf:role attributes.
+func replacePeople(opf *opfDocument, creators, contributors []Contributor) {
+	for _, element := range append(opf.dcElements("creator"), opf.dcElements("contributor")...) {
+		if id := element.attr("id"); id != "" {
+			for _, meta := range opf.metas() {
+				if meta.attr("refines") == "#"+id {
+					opf.remove(meta)
+				}
+			}
+		}
+		opf.remove(element)
+	}
+
+	epub2 := strings.HasPrefix(opf.version(), "2")
+	add := func(element, idPrefix string, people []Contributor) {
+		for i, person := range people {
+			id := fmt.Sprintf("%s%02d", idPrefix, i+1)
+			credit := opf.newDC(element, person.Name)
+			credit.setAttr("id", id)
+			if epub2 && person.Role != "" {
+				credit.attrs = append(credit.attrs, opf.opfAttr("role", person.Role))
+			}
+			opf.add(credit)
+
+			if !epub2 && person.Role != "" {
+				role := opf.newMeta()
+				role.setAttr("refines", "#"+id)
+				role.setAttr("property", "role")
+				role.setAttr("scheme", "marc:relators")
+				role.setText(person.Role)
+				opf.add(role)
+			}
+		}
+	}
+	add("creator", "creator", creators)
+	add("contributor", "contributor", contributors)
+}
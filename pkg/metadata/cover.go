@@ -0,0 +1,295 @@
+package metadata
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// coverMediaTypes are the media types of the formats a cover image can be in
+var coverMediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+var bodyContent = regexp.MustCompile(`(?s)<body[^>]*>(.*)</body>`)
+
+// coverItem returns the manifest item of the book's cover image: the one <meta name="cover">
+// names, or else the one with the cover-image property
+func (d *opfDocument) coverItem() *xmlNode {
+	items := d.manifestItems()
+	for _, meta := range d.metas() {
+		if meta.attr("name") != "cover" {
+			continue
+		}
+		for _, item := range items {
+			if item.attr("id") == meta.attr("content") {
+				return item
+			}
+		}
+	}
+	for _, item := range items {
+		if hasProperty(item, "cover-image") {
+			return item
+		}
+	}
+	return nil
+}
+
+// manifestItems returns the items of the package's manifest
+func (d *opfDocument) manifestItems() []*xmlNode {
+	if manifest := d.pkg.child("manifest"); manifest != nil {
+		return manifest.elements("item")
+	}
+	return nil
+}
+
+func hasProperty(item *xmlNode, property string) bool {
+	return slices.Contains(strings.Fields(item.attr("properties")), property)
+}
+
+// resolveHref returns where a manifest href relative to opfDir is in the archive
+func resolveHref(opfDir, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(opfDir, href)
+}
+
+// relativePath returns the path from a folder in the archive to a file in it, as a
+// content document in dir would link to it
+func relativePath(dir, name string) string {
+	from := strings.Split(dir, "/")
+	if dir == "." {
+		from = nil
+	}
+	to := strings.Split(name, "/")
+	for len(from) > 0 && len(to) > 1 && from[0] == to[0] {
+		from, to = from[1:], to[1:]
+	}
+	return strings.Repeat("../", len(from)) + strings.Join(to, "/")
+}
+
+// coverArchive is the book's files as the cover is being changed, with the changes so far
+type coverArchive struct {
+	files   map[string]*zip.File
+	changes map[string][]byte // New content by path (nil = removed)
+}
+
+// has reports whether the archive has a file at name, counting changes
+func (a *coverArchive) has(name string) bool {
+	if content, ok := a.changes[name]; ok {
+		return content != nil
+	}
+	return a.files[name] != nil
+}
+
+// read returns a file's content, as changed so far
+func (a *coverArchive) read(name string) ([]byte, error) {
+	if content, ok := a.changes[name]; ok && content != nil {
+		return content, nil
+	}
+	return readZipEntry(a.files[name])
+}
+
+// uniqueHref returns href, numbered if a file is already there, as in images/cover2.jpg
+func (a *coverArchive) uniqueHref(opfDir, href string) string {
+	ext := path.Ext(href)
+	base := strings.TrimSuffix(href, ext)
+	for i := 2; a.has(resolveHref(opfDir, href)); i++ {
+		href = fmt.Sprintf("%s%d%s", base, i, ext)
+	}
+	return href
+}
+
+// documents returns the manifest items of the book's XHTML documents
+func (d *opfDocument) documents() []*xmlNode {
+	var documents []*xmlNode
+	for _, item := range d.manifestItems() {
+		if item.attr("media-type") == "application/xhtml+xml" {
+			documents = append(documents, item)
+		}
+	}
+	return documents
+}
+
+// replaceCover puts the new cover image in place of the old one, or adds it if the book
+// has none. The manifest item gets the cover-image property (EPUB 3) and <meta name="cover">
+// points to it. If the new image is in another format, it's renamed to match and the pages
+// showing the old one are pointed at it.
+func (e *EPUBEditor) replaceCover(opf *opfDocument, opfPath string, archive *coverArchive) error {
+	image, err := os.ReadFile(e.newCover)
+	if err != nil {
+		return fmt.Errorf("failed to read cover image: %w", err)
+	}
+	ext := strings.ToLower(filepath.Ext(e.newCover))
+	mediaType, ok := coverMediaTypes[ext]
+	if !ok {
+		return fmt.Errorf("unsupported cover image format: %s", ext)
+	}
+	manifest := opf.pkg.child("manifest")
+	if manifest == nil {
+		return fmt.Errorf("no <manifest> element in the OPF")
+	}
+	opfDir := path.Dir(opfPath)
+
+	item := opf.coverItem()
+	switch {
+	case item == nil:
+		item = &xmlNode{name: xml.Name{Space: manifest.name.Space, Local: "item"}}
+		item.setAttr("id", uniqueID(opf, "cover-image"))
+		item.setAttr("href", archive.uniqueHref(opfDir, "images/cover"+ext))
+		item.setAttr("media-type", mediaType)
+		manifest.appendIndented(item)
+	case coverMediaTypes[strings.ToLower(path.Ext(item.attr("href")))] != mediaType:
+		oldHref := item.attr("href")
+		oldPath := resolveHref(opfDir, oldHref)
+		archive.changes[oldPath] = nil
+		newHref := archive.uniqueHref(opfDir, strings.TrimSuffix(oldHref, path.Ext(oldHref))+ext)
+		repointReferences(opf, opfDir, archive, oldPath, resolveHref(opfDir, newHref))
+		item.setAttr("href", newHref)
+		item.setAttr("media-type", mediaType)
+	}
+
+	if strings.HasPrefix(opf.version(), "3") && !hasProperty(item, "cover-image") {
+		item.setAttr("properties", strings.TrimSpace(item.attr("properties")+" cover-image"))
+	}
+	opf.setNamedMeta("cover", item.attr("id"))
+	archive.changes[resolveHref(opfDir, item.attr("href"))] = image
+
+	return nil
+}
+
+// removeCover takes the cover image out of the book, along with its manifest item, the
+// <meta name="cover"> and any cover page that shows nothing but the image
+func (e *EPUBEditor) removeCover(opf *opfDocument, opfPath string, archive *coverArchive) error {
+	item := opf.coverItem()
+	if item == nil {
+		return fmt.Errorf("the book has no cover to remove")
+	}
+	opfDir := path.Dir(opfPath)
+	imagePath := resolveHref(opfDir, item.attr("href"))
+
+	archive.changes[imagePath] = nil
+	item.parent.removeChild(item)
+	for _, meta := range opf.metas() {
+		if meta.attr("name") == "cover" {
+			opf.remove(meta)
+		}
+	}
+
+	for _, document := range opf.documents() {
+		if hasProperty(document, "nav") {
+			continue
+		}
+		documentPath := resolveHref(opfDir, document.attr("href"))
+		content, err := archive.read(documentPath)
+		if err != nil {
+			continue // Missing documents aren't the cover's problem
+		}
+		if !isImagePage(content, relativePath(path.Dir(documentPath), imagePath)) {
+			continue
+		}
+		removeDocument(opf, opfDir, archive, document)
+	}
+
+	return nil
+}
+
+// isImagePage reports whether a content document shows the image at src and has no text,
+// as a cover page does
+func isImagePage(content []byte, src string) bool {
+	body := bodyContent.FindSubmatch(content)
+	if body == nil || !strings.Contains(string(body[1]), `"`+src+`"`) {
+		return false
+	}
+	return strings.TrimSpace(html.UnescapeString(markupTag.ReplaceAllString(string(body[1]), ""))) == ""
+}
+
+// removeDocument takes a content document out of the book: the file, its manifest item,
+// its place in the spine and guide, and the links to it in the navigation document
+func removeDocument(opf *opfDocument, opfDir string, archive *coverArchive, document *xmlNode) {
+	documentPath := resolveHref(opfDir, document.attr("href"))
+	archive.changes[documentPath] = nil
+	document.parent.removeChild(document)
+
+	if spine := opf.pkg.child("spine"); spine != nil {
+		for _, itemref := range spine.elements("itemref") {
+			if itemref.attr("idref") == document.attr("id") {
+				spine.removeChild(itemref)
+			}
+		}
+	}
+	if guide := opf.pkg.child("guide"); guide != nil {
+		for _, reference := range guide.elements("reference") {
+			href, _, _ := strings.Cut(reference.attr("href"), "#")
+			if resolveHref(opfDir, href) == documentPath {
+				guide.removeChild(reference)
+			}
+		}
+	}
+
+	for _, nav := range opf.manifestItems() {
+		if !hasProperty(nav, "nav") {
+			continue
+		}
+		navPath := resolveHref(opfDir, nav.attr("href"))
+		content, err := archive.read(navPath)
+		if err != nil {
+			continue
+		}
+		link := regexp.MustCompile(`(?s)[ \t]*<li[^>]*>\s*<a\s[^>]*href="` + regexp.QuoteMeta(relativePath(path.Dir(navPath), documentPath)) + `(?:#[^"]*)?"[^>]*>.*?</a>\s*</li>[ \t]*\n?`)
+		if updated := link.ReplaceAll(content, nil); len(updated) != len(content) {
+			archive.changes[navPath] = updated
+		}
+	}
+}
+
+// repointReferences points the content documents that show the image at oldPath to newPath
+func repointReferences(opf *opfDocument, opfDir string, archive *coverArchive, oldPath, newPath string) {
+	for _, document := range opf.documents() {
+		documentPath := resolveHref(opfDir, document.attr("href"))
+		content, err := archive.read(documentPath)
+		if err != nil {
+			continue
+		}
+		dir := path.Dir(documentPath)
+		oldRef, newRef := relativePath(dir, oldPath), relativePath(dir, newPath)
+		updated := strings.NewReplacer(`"`+oldRef+`"`, `"`+newRef+`"`, `'`+oldRef+`'`, `'`+newRef+`'`).Replace(string(content))
+		if updated != string(content) {
+			archive.changes[documentPath] = []byte(updated)
+		}
+	}
+}
+
+// uniqueID returns id, numbered if an element in the package already has it
+func uniqueID(opf *opfDocument, id string) string {
+	taken := make(map[string]bool)
+	var collect func(n *xmlNode)
+	collect = func(n *xmlNode) {
+		if n.isElement() {
+			taken[n.attr("id")] = true
+		}
+		for _, child := range n.children {
+			collect(child)
+		}
+	}
+	collect(opf.pkg)
+
+	candidate := id
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", id, i)
+	}
+	return candidate
+}
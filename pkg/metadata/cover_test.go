@@ -0,0 +1,214 @@
+package metadata
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const coverOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1</dc:identifier>
+    <dc:title>Nils</dc:title>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover-page" href="text/cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="art" href="images/front.jpg" media-type="image/jpeg" properties="cover-image"/>
+  </manifest>
+  <spine>
+    <itemref idref="cover-page"/>
+    <itemref idref="c1"/>
+  </spine>
+</package>
+`
+
+// writeCoverBook writes an EPUB 3 book with a cover image and a cover page
+func writeCoverBook(t *testing.T) string {
+	t.Helper()
+	epubPath := filepath.Join(t.TempDir(), "nils.epub")
+	out, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, file := range [][2]string{
+		{"mimetype", epubMimetype},
+		{"META-INF/container.xml", string(ContainerXML("OEBPS/content.opf"))},
+		{"OEBPS/content.opf", coverOPF},
+		{"OEBPS/nav.xhtml", `<html><body><nav epub:type="toc"><ol>
+      <li><a href="text/cover.xhtml">Cover</a></li>
+      <li><a href="text/one.xhtml">Chapter One</a></li>
+    </ol></nav></body></html>`},
+		{"OEBPS/text/cover.xhtml", `<html><body><div><img src="../images/front.jpg" alt=""/></div></body></html>`},
+		{"OEBPS/text/one.xhtml", `<html><body><p>The boy was lazy.</p></body></html>`},
+		{"OEBPS/images/front.jpg", "\xff\xd8\xff old cover"},
+	} {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	return epubPath
+}
+
+// editCover applies edit to the book, saves it and returns its entries
+func editCover(t *testing.T, epubPath string, edit func(*EPUBEditor)) map[string]string {
+	t.Helper()
+	editor, err := NewEPUBEditor(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	edit(editor)
+	if err := editor.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	zr, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("The saved EPUB doesn't open: %v", err)
+	}
+	defer zr.Close()
+	entries := make(map[string]string)
+	for _, file := range zr.File {
+		content, err := readZipEntry(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = string(content)
+	}
+	if problems, err := ValidateStructure(&zr.Reader, nil); err != nil || len(problems) > 0 {
+		t.Errorf("Expected a sound book after the edit, got %v (%v)", problems, err)
+	}
+	return entries
+}
+
+func writeCoverImage(t *testing.T, name, content string) string {
+	t.Helper()
+	coverPath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(coverPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return coverPath
+}
+
+func TestSetCoverReplacesInPlace(t *testing.T) {
+	epubPath := writeCoverBook(t)
+	coverPath := writeCoverImage(t, "new.jpeg", "\xff\xd8\xff new cover")
+
+	entries := editCover(t, epubPath, func(e *EPUBEditor) { e.SetCover(coverPath) })
+	if entries["OEBPS/images/front.jpg"] != "\xff\xd8\xff new cover" {
+		t.Error("Expected the new cover in the old one's place")
+	}
+	if len(entries) != 7 {
+		t.Errorf("Expected no entries added or removed, got %d", len(entries))
+	}
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="art"/>`) {
+		t.Errorf("Expected <meta name=\"cover\"> pointing at the cover item:\n%s", entries["OEBPS/content.opf"])
+	}
+}
+
+func TestSetCoverInAnotherFormat(t *testing.T) {
+	epubPath := writeCoverBook(t)
+	coverPath := writeCoverImage(t, "new.png", "\x89PNG new cover")
+
+	entries := editCover(t, epubPath, func(e *EPUBEditor) { e.SetCover(coverPath) })
+	if _, ok := entries["OEBPS/images/front.jpg"]; ok {
+		t.Error("Expected the old JPEG gone")
+	}
+	if entries["OEBPS/images/front.png"] != "\x89PNG new cover" {
+		t.Error("Expected the new cover as front.png")
+	}
+	if !strings.Contains(entries["OEBPS/content.opf"], `<item id="art" href="images/front.png" media-type="image/png" properties="cover-image"/>`) {
+		t.Errorf("Expected the manifest item pointed at the PNG:\n%s", entries["OEBPS/content.opf"])
+	}
+	if !strings.Contains(entries["OEBPS/text/cover.xhtml"], `src="../images/front.png"`) {
+		t.Errorf("Expected the cover page showing the PNG: %s", entries["OEBPS/text/cover.xhtml"])
+	}
+}
+
+func TestSetCoverAddsOne(t *testing.T) {
+	epubPath := writeCoverBook(t)
+	editCover(t, epubPath, func(e *EPUBEditor) { e.RemoveCover() })
+	coverPath := writeCoverImage(t, "new.jpg", "\xff\xd8\xff new cover")
+
+	entries := editCover(t, epubPath, func(e *EPUBEditor) { e.SetCover(coverPath) })
+	opf := entries["OEBPS/content.opf"]
+	for _, want := range []string{
+		`<item id="cover-image" href="images/cover.jpg" media-type="image/jpeg" properties="cover-image"/>`,
+		`<meta name="cover" content="cover-image"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("Expected %s in:\n%s", want, opf)
+		}
+	}
+	if entries["OEBPS/images/cover.jpg"] != "\xff\xd8\xff new cover" {
+		t.Error("Expected the cover next to the package document's images")
+	}
+}
+
+func TestRemoveCover(t *testing.T) {
+	epubPath := writeCoverBook(t)
+
+	entries := editCover(t, epubPath, func(e *EPUBEditor) { e.RemoveCover() })
+	for _, gone := range []string{"OEBPS/images/front.jpg", "OEBPS/text/cover.xhtml"} {
+		if _, ok := entries[gone]; ok {
+			t.Errorf("Expected %s removed", gone)
+		}
+	}
+	opf := entries["OEBPS/content.opf"]
+	if strings.Contains(opf, "cover") {
+		t.Errorf("Expected nothing about the cover left in the package:\n%s", opf)
+	}
+	if strings.Contains(entries["OEBPS/nav.xhtml"], "Cover") || !strings.Contains(entries["OEBPS/nav.xhtml"], "Chapter One") {
+		t.Errorf("Expected only the cover taken out of the contents: %s", entries["OEBPS/nav.xhtml"])
+	}
+
+	editor, err := NewEPUBEditor(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	editor.RemoveCover()
+	if err := editor.Save(); err == nil {
+		t.Error("Expected removing a cover that isn't there to fail")
+	}
+}
+
+func TestExportCover(t *testing.T) {
+	reader, err := NewEPUBReader(writeCoverBook(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	dir := t.TempDir()
+	written, err := reader.ExportCover(filepath.Join(dir, "thumb"))
+	if err != nil {
+		t.Fatalf("ExportCover failed: %v", err)
+	}
+	if filepath.Base(written) != "thumb.jpg" {
+		t.Errorf("Expected the cover's extension added, got %s", written)
+	}
+	if content, _ := os.ReadFile(written); string(content) != "\xff\xd8\xff old cover" {
+		t.Error("Expected the cover written as it is in the book")
+	}
+
+	if _, err := reader.ExportCover(filepath.Join(dir, "cover.jpeg")); err != nil {
+		t.Errorf("Expected .jpeg accepted for a JPEG cover: %v", err)
+	}
+	if _, err := reader.ExportCover(filepath.Join(dir, "cover.png")); err == nil {
+		t.Error("Expected a PNG name for a JPEG cover refused")
+	}
+}
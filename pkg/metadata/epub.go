@@ -5,23 +5,39 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/alde/publify/internal/epubzip"
+	"github.com/alde/publify/internal/safefile"
 )
 
 // EPUBMetadata contains EPUB metadata information
 type EPUBMetadata struct {
-	Title       string
-	Author      string
-	Language    string
-	Identifier  string
-	Description string
-	Publisher   string
-	Created     time.Time
-	Modified    time.Time
-	CoverPath   string
+	Title        string        `json:"title,omitempty"`
+	Author       string        `json:"author,omitempty"`       // First creator, for the common single-author case
+	Creators     []Contributor `json:"creators,omitempty"`     // dc:creator, in order
+	Contributors []Contributor `json:"contributors,omitempty"` // dc:contributor (editors, translators, illustrators...)
+	Language     string        `json:"language,omitempty"`
+	Identifier   string        `json:"identifier,omitempty"`
+	ISBN         string        `json:"isbn,omitempty"` // From whichever dc:identifier is one
+	DOI          string        `json:"doi,omitempty"`
+	Description  string        `json:"description,omitempty"`
+	Publisher    string        `json:"publisher,omitempty"`
+	Created      time.Time     `json:"created,omitzero"`
+	Modified     time.Time     `json:"modified,omitzero"`
+	CoverPath    string        `json:"coverPath,omitempty"`
+	Series       string        `json:"series,omitempty"`      // calibre:series
+	SeriesIndex  float64       `json:"seriesIndex,omitempty"` // Position in the series (0 = not set)
+	Subjects     []string      `json:"subjects,omitempty"`    // dc:subject, one per element
+	Rights       string        `json:"rights,omitempty"`      // dc:rights
+	Fingerprint  string        `json:"fingerprint,omitempty"` // Of the text, stored by publify convert
 }
 
 // EPUBReader provides read-only access to EPUB metadata
@@ -36,14 +52,22 @@ type EPUBEditor struct {
 	tempDir  string
 	metadata EPUBMetadata
 	modified bool
-	newCover string // Track if a new cover was explicitly set
+	newCover string    // Track if a new cover was explicitly set
+	noCover  bool      // Take the cover out of the book
+	newTOC   []Chapter // Table of contents to write into the book (nil = leave it)
+
+	peopleChanged bool // Creators/contributors were replaced and need rewriting
+	reproducible  bool // Stamp the book with epubzip.SourceDate rather than now
 }
 
-// Chapter represents a chapter in the EPUB
+// Chapter represents a chapter in the EPUB, or an entry of its table of contents
 type Chapter struct {
-	ID    string
-	Title string
-	Path  string
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Path     string    `json:"path"`
+	Fragment string    `json:"fragment,omitempty"` // Where in the document a table of contents entry links to
+	Depth    int       `json:"depth"`              // How deep in the table of contents it is (0 = top level)
+	Children []Chapter `json:"children,omitempty"` // Table of contents entries nested under it
 }
 
 // NewEPUBReader creates a new EPUB reader
@@ -95,7 +119,57 @@ func (r *EPUBReader) GetMetadata() (EPUBMetadata, error) {
 	return metadata, nil
 }
 
-// GetChapterList returns a list of chapters in the EPUB
+// ReadCover returns the cover image and its file extension. Books without a cover
+// return an error.
+func (r *EPUBReader) ReadCover() ([]byte, string, error) {
+	meta, err := r.GetMetadata()
+	if err != nil {
+		return nil, "", err
+	}
+	if meta.CoverPath == "" {
+		return nil, "", fmt.Errorf("EPUB has no cover image")
+	}
+
+	opfPath, err := r.findOPFFile()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find OPF file: %w", err)
+	}
+
+	// Manifest hrefs are relative to the package document
+	coverPath := path.Join(path.Dir(opfPath), meta.CoverPath)
+	data, err := r.readFileFromZip(coverPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read cover image: %w", err)
+	}
+
+	return data, strings.ToLower(path.Ext(coverPath)), nil
+}
+
+// ExportCover writes the cover image to dst and returns where it went. A dst without an
+// extension gets the cover's; one naming another format is refused, since the image is
+// written as it is in the book rather than converted.
+func (r *EPUBReader) ExportCover(dst string) (string, error) {
+	cover, ext, err := r.ReadCover()
+	if err != nil {
+		return "", err
+	}
+
+	dstExt := strings.ToLower(filepath.Ext(dst))
+	if dstExt == "" {
+		dst += ext
+	} else if dstExt != ext && (coverMediaTypes[dstExt] == "" || coverMediaTypes[dstExt] != coverMediaTypes[ext]) {
+		return "", fmt.Errorf("the cover is a %s image, not %s (name the file %s)", strings.TrimPrefix(ext, "."), dstExt,
+			strings.TrimSuffix(filepath.Base(dst), filepath.Ext(dst))+ext)
+	}
+
+	if err := os.WriteFile(dst, cover, 0644); err != nil {
+		return "", fmt.Errorf("failed to write cover image: %w", err)
+	}
+	return dst, nil
+}
+
+// GetChapterList returns the documents of the spine in reading order, titled as the table
+// of contents has them. Documents it doesn't list are titled by their first heading.
 func (r *EPUBReader) GetChapterList() ([]Chapter, error) {
 	// Find and read the OPF file
 	opfPath, err := r.findOPFFile()
@@ -114,9 +188,61 @@ func (r *EPUBReader) GetChapterList() ([]Chapter, error) {
 		return nil, fmt.Errorf("failed to parse chapters: %w", err)
 	}
 
+	// A book without a usable table of contents still has its documents' headings
+	if toc, err := r.GetTableOfContents(); err == nil {
+		spineTitles(chapters, toc)
+	}
+	for i := range chapters {
+		if chapters[i].Title != "" {
+			continue
+		}
+		if content, err := r.ReadContent(chapters[i].Path); err == nil {
+			chapters[i].Title = documentTitle(content, chapters[i].Path)
+		} else {
+			chapters[i].Title = fmt.Sprintf("Chapter %d", i+1)
+		}
+	}
+
 	return chapters, nil
 }
 
+// ReadContent returns a file by its path relative to the package document, which is how
+// chapter paths and manifest hrefs are given
+func (r *EPUBReader) ReadContent(href string) ([]byte, error) {
+	opfPath, err := r.findOPFFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OPF file: %w", err)
+	}
+
+	// hrefs are URLs, so "my chapter.xhtml" is stored as "my%20chapter.xhtml"
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return r.readFileFromZip(path.Join(path.Dir(opfPath), href))
+}
+
+// markupPattern matches the markup of a content document: its head, and tags
+var markupPattern = regexp.MustCompile(`(?s)<head\b.*?</head>|<[^>]*>`)
+
+// ReadText returns the text of the book's spine documents in reading order, without markup
+func (r *EPUBReader) ReadText() (string, error) {
+	chapters, err := r.GetChapterList()
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, chapter := range chapters {
+		content, err := r.ReadContent(chapter.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", chapter.Path, err)
+		}
+		text.WriteString(documentText(content))
+		text.WriteString("\n")
+	}
+	return text.String(), nil
+}
+
 // findOPFFile locates the OPF file within the EPUB
 func (r *EPUBReader) findOPFFile() (string, error) {
 	// First, check META-INF/container.xml
@@ -167,16 +293,22 @@ func parseOPFMetadata(opfContent []byte) (EPUBMetadata, error) {
 	// Simple OPF structure for metadata parsing
 	type OPF struct {
 		Metadata struct {
-			Title       []string `xml:"title"`
-			Creator     []string `xml:"creator"`
-			Language    []string `xml:"language"`
-			Identifier  []string `xml:"identifier"`
-			Description []string `xml:"description"`
-			Publisher   []string `xml:"publisher"`
-			Date        []string `xml:"date"`
+			Title       []string    `xml:"title"`
+			Creator     []opfPerson `xml:"creator"`
+			Contributor []opfPerson `xml:"contributor"`
+			Language    []string    `xml:"language"`
+			Identifier  []string    `xml:"identifier"`
+			Description []string    `xml:"description"`
+			Publisher   []string    `xml:"publisher"`
+			Date        []string    `xml:"date"`
+			Subject     []string    `xml:"subject"`
+			Rights      []string    `xml:"rights"`
 			Meta        []struct {
-				Name    string `xml:"name,attr"`
-				Content string `xml:"content,attr"`
+				Name     string `xml:"name,attr"`
+				Content  string `xml:"content,attr"`
+				Refines  string `xml:"refines,attr"`
+				Property string `xml:"property,attr"`
+				Value    string `xml:",chardata"`
 			} `xml:"meta"`
 		} `xml:"metadata"`
 		Manifest struct {
@@ -198,8 +330,35 @@ func parseOPFMetadata(opfContent []byte) (EPUBMetadata, error) {
 	if len(opf.Metadata.Title) > 0 {
 		metadata.Title = opf.Metadata.Title[0]
 	}
-	if len(opf.Metadata.Creator) > 0 {
-		metadata.Author = opf.Metadata.Creator[0]
+	// EPUB 3 puts roles in refining metas, EPUB 2 in opf:role attributes
+	roles := make(map[string]string)
+	for _, meta := range opf.Metadata.Meta {
+		if meta.Property == "role" && strings.HasPrefix(meta.Refines, "#") {
+			roles[strings.TrimPrefix(meta.Refines, "#")] = strings.TrimSpace(meta.Value)
+		}
+	}
+	people := func(elements []opfPerson, defaultRole string) []Contributor {
+		var result []Contributor
+		for _, person := range elements {
+			name := strings.TrimSpace(person.Name)
+			if name == "" {
+				continue
+			}
+			role := person.Role
+			if refined, ok := roles[person.ID]; ok && person.ID != "" {
+				role = refined
+			}
+			if role == "" {
+				role = defaultRole
+			}
+			result = append(result, Contributor{Name: name, Role: role})
+		}
+		return result
+	}
+	metadata.Creators = people(opf.Metadata.Creator, "aut")
+	metadata.Contributors = people(opf.Metadata.Contributor, "ctb")
+	if len(metadata.Creators) > 0 {
+		metadata.Author = metadata.Creators[0].Name
 	}
 	if len(opf.Metadata.Language) > 0 {
 		metadata.Language = opf.Metadata.Language[0]
@@ -207,6 +366,14 @@ func parseOPFMetadata(opfContent []byte) (EPUBMetadata, error) {
 	if len(opf.Metadata.Identifier) > 0 {
 		metadata.Identifier = opf.Metadata.Identifier[0]
 	}
+	for _, identifier := range opf.Metadata.Identifier {
+		if isbn, ok := NormalizeISBN(identifier); ok && metadata.ISBN == "" {
+			metadata.ISBN = isbn
+		}
+		if doi := FindDOI(identifier); doi != "" && metadata.DOI == "" {
+			metadata.DOI = doi
+		}
+	}
 	if len(opf.Metadata.Description) > 0 {
 		metadata.Description = opf.Metadata.Description[0]
 	}
@@ -214,13 +381,34 @@ func parseOPFMetadata(opfContent []byte) (EPUBMetadata, error) {
 		metadata.Publisher = opf.Metadata.Publisher[0]
 	}
 
-	// Parse date if available
-	if len(opf.Metadata.Date) > 0 {
-		if created, err := time.Parse(time.RFC3339, opf.Metadata.Date[0]); err == nil {
-			metadata.Created = created
+	if len(opf.Metadata.Rights) > 0 {
+		metadata.Rights = opf.Metadata.Rights[0]
+	}
+	for _, subject := range opf.Metadata.Subject {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			metadata.Subjects = append(metadata.Subjects, subject)
 		}
 	}
 
+	// Series information uses calibre's meta tags, which most library apps understand
+	for _, meta := range opf.Metadata.Meta {
+		switch meta.Name {
+		case "calibre:series":
+			metadata.Series = meta.Content
+		case "calibre:series_index":
+			if index, err := strconv.ParseFloat(meta.Content, 64); err == nil {
+				metadata.SeriesIndex = index
+			}
+		case "publify:fingerprint":
+			metadata.Fingerprint = meta.Content
+		}
+	}
+
+	// Parse date if available; publication dates are often just a year
+	if len(opf.Metadata.Date) > 0 {
+		metadata.Created = parseOPFDate(opf.Metadata.Date[0])
+	}
+
 	// Extract cover path from metadata and manifest
 	var coverItemID string
 
@@ -255,7 +443,25 @@ func parseOPFMetadata(opfContent []byte) (EPUBMetadata, error) {
 	return metadata, nil
 }
 
-// parseOPFChapters extracts chapter information from OPF content
+// parseOPFDate reads a dc:date, which may be a full timestamp or only part of a date
+func parseOPFDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02", "2006-01", "2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// opfPerson is a dc:creator or dc:contributor element
+type opfPerson struct {
+	Name string `xml:",chardata"`
+	ID   string `xml:"id,attr"`
+	Role string `xml:"role,attr"` // EPUB 2 opf:role
+}
+
+// parseOPFChapters extracts the spine's documents from OPF content, untitled
 func parseOPFChapters(opfContent []byte) ([]Chapter, error) {
 	// Simple parsing - in a full implementation this would be more robust
 	type OPF struct {
@@ -285,12 +491,11 @@ func parseOPFChapters(opfContent []byte) ([]Chapter, error) {
 
 	// Build chapter list from spine
 	var chapters []Chapter
-	for i, itemRef := range opf.Spine.ItemRef {
+	for _, itemRef := range opf.Spine.ItemRef {
 		if href, exists := idToHref[itemRef.IDRef]; exists {
 			chapter := Chapter{
-				ID:    itemRef.IDRef,
-				Title: fmt.Sprintf("Chapter %d", i+1), // Simple title
-				Path:  href,
+				ID:   itemRef.IDRef,
+				Path: href,
 			}
 			chapters = append(chapters, chapter)
 		}
@@ -327,6 +532,13 @@ func NewEPUBEditor(filePath string) (*EPUBEditor, error) {
 	}, nil
 }
 
+// SetReproducible has the saved EPUB stamped with SOURCE_DATE_EPOCH (or 1980) instead of
+// the time it's saved, with its files in name order, so the same edit to the same book
+// always gives the same bytes
+func (e *EPUBEditor) SetReproducible(reproducible bool) {
+	e.reproducible = reproducible
+}
+
 // Close cleans up the EPUB editor
 func (e *EPUBEditor) Close() error {
 	if e.tempDir != "" {
@@ -345,6 +557,29 @@ func (e *EPUBEditor) SetTitle(title string) error {
 // SetAuthor sets the book author
 func (e *EPUBEditor) SetAuthor(author string) error {
 	e.metadata.Author = author
+	if len(e.metadata.Creators) > 0 {
+		e.metadata.Creators[0].Name = author
+	}
+	e.modified = true
+	return nil
+}
+
+// SetCreators replaces all creators (authors, or whoever the book is primarily by)
+func (e *EPUBEditor) SetCreators(creators []Contributor) error {
+	if len(creators) == 0 {
+		return fmt.Errorf("at least one creator is required")
+	}
+	e.metadata.Creators = creators
+	e.metadata.Author = creators[0].Name
+	e.peopleChanged = true
+	e.modified = true
+	return nil
+}
+
+// SetContributors replaces all contributors (editors, translators, illustrators...)
+func (e *EPUBEditor) SetContributors(contributors []Contributor) error {
+	e.metadata.Contributors = contributors
+	e.peopleChanged = true
 	e.modified = true
 	return nil
 }
@@ -370,338 +605,196 @@ func (e *EPUBEditor) SetPublisher(publisher string) error {
 	return nil
 }
 
+// SetSeries sets the name of the series the book belongs to
+func (e *EPUBEditor) SetSeries(series string) error {
+	e.metadata.Series = series
+	e.modified = true
+	return nil
+}
+
+// SetSeriesIndex sets the book's position in its series (e.g. 2, or 2.5 for a novella)
+func (e *EPUBEditor) SetSeriesIndex(index float64) error {
+	if index <= 0 {
+		return fmt.Errorf("series index must be positive, got %g", index)
+	}
+	e.metadata.SeriesIndex = index
+	e.modified = true
+	return nil
+}
+
+// SetSubjects replaces the book's subjects (genres, tags)
+func (e *EPUBEditor) SetSubjects(subjects []string) error {
+	var cleaned []string
+	for _, subject := range subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			cleaned = append(cleaned, subject)
+		}
+	}
+	e.metadata.Subjects = cleaned
+	e.modified = true
+	return nil
+}
+
+// SetRights sets the rights statement (copyright, license)
+func (e *EPUBEditor) SetRights(rights string) error {
+	e.metadata.Rights = rights
+	e.modified = true
+	return nil
+}
+
 // SetCover sets the book cover image
 func (e *EPUBEditor) SetCover(coverPath string) error {
 	// Copy cover image to temp directory
 	coverExt := strings.ToLower(filepath.Ext(coverPath))
 	tempCoverPath := filepath.Join(e.tempDir, "cover"+coverExt)
 
-	if err := copyFile(coverPath, tempCoverPath); err != nil {
+	if err := safefile.Copy(coverPath, tempCoverPath); err != nil {
 		return fmt.Errorf("failed to copy cover image: %w", err)
 	}
 
 	e.newCover = tempCoverPath
+	e.noCover = false
 	e.modified = true
 	return nil
 }
 
-// Save saves the changes to the EPUB file
+// RemoveCover takes the cover image out of the book, along with a cover page that shows
+// nothing else
+func (e *EPUBEditor) RemoveCover() {
+	e.newCover = ""
+	e.noCover = true
+	e.modified = true
+}
+
+// Save saves the changes to the EPUB file. Only the package document, the cover and the
+// table of contents are written again; every other entry is copied across as it is, still
+// compressed.
 func (e *EPUBEditor) Save() error {
 	if !e.modified {
 		return nil // No changes to save
 	}
 
-	// 1. Extract EPUB to temp directory
-	extractDir := filepath.Join(e.tempDir, "extracted")
-	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return fmt.Errorf("failed to create extraction directory: %w", err)
-	}
-
-	if err := e.extractEPUB(extractDir); err != nil {
-		return fmt.Errorf("failed to extract EPUB: %w", err)
-	}
-
-	// 2. Find and modify the OPF file
-	if err := e.updateOPFMetadata(extractDir); err != nil {
-		return fmt.Errorf("failed to update OPF metadata: %w", err)
-	}
-
-	// 3. Add cover image if provided
-	if e.newCover != "" {
-		if err := e.updateCoverImage(extractDir); err != nil {
-			return fmt.Errorf("failed to update cover image: %w", err)
-		}
-	}
-
-	// 4. Repackage as EPUB
-	newEPUBPath := e.filePath + ".new"
-	if err := e.repackageEPUB(extractDir, newEPUBPath); err != nil {
-		return fmt.Errorf("failed to repackage EPUB: %w", err)
-	}
-
-	// 5. Replace original file
-	if err := os.Rename(newEPUBPath, e.filePath); err != nil {
-		return fmt.Errorf("failed to replace original file: %w", err)
-	}
-
-	e.metadata.Modified = time.Now()
-	return nil
-}
-
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
+	reader, err := NewEPUBReader(e.filePath)
 	if err != nil {
 		return err
 	}
-	defer sourceFile.Close()
+	defer reader.Close()
 
-	destFile, err := os.Create(dst)
+	// 1. Update the package document
+	opfPath, err := reader.findOPFFile()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to update OPF metadata: %w", err)
 	}
-	defer destFile.Close()
-
-	_, err = destFile.ReadFrom(sourceFile)
-	return err
-}
-
-// extractEPUB extracts the EPUB file to the specified directory
-func (e *EPUBEditor) extractEPUB(extractDir string) error {
-	zipReader, err := zip.OpenReader(e.filePath)
+	opfContent, err := reader.readFileFromZip(opfPath)
 	if err != nil {
-		return fmt.Errorf("failed to open EPUB: %w", err)
-	}
-	defer zipReader.Close()
-
-	for _, file := range zipReader.File {
-		filePath := filepath.Join(extractDir, file.Name)
-
-		// Create directory if needed
-		if file.FileInfo().IsDir() {
-			if err := os.MkdirAll(filePath, file.FileInfo().Mode()); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", filePath, err)
-			}
-			continue
-		}
-
-		// Create parent directories
-		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-			return fmt.Errorf("failed to create parent directory for %s: %w", filePath, err)
-		}
-
-		// Extract file
-		src, err := file.Open()
-		if err != nil {
-			return fmt.Errorf("failed to open file %s in ZIP: %w", file.Name, err)
-		}
-
-		dst, err := os.Create(filePath)
-		if err != nil {
-			src.Close()
-			return fmt.Errorf("failed to create file %s: %w", filePath, err)
-		}
-
-		_, err = io.Copy(dst, src)
-		src.Close()
-		dst.Close()
-
-		if err != nil {
-			return fmt.Errorf("failed to copy file %s: %w", file.Name, err)
-		}
+		return fmt.Errorf("failed to read OPF file: %w", err)
 	}
-
-	return nil
-}
-
-// updateOPFMetadata updates the metadata in the OPF file
-func (e *EPUBEditor) updateOPFMetadata(extractDir string) error {
-	// Find OPF file
-	containerPath := filepath.Join(extractDir, "META-INF", "container.xml")
-	containerContent, err := os.ReadFile(containerPath)
+	opf, err := parseOPF(opfContent)
 	if err != nil {
-		return fmt.Errorf("failed to read container.xml: %w", err)
+		return fmt.Errorf("failed to update OPF content: %w", err)
 	}
+	e.updateOPF(opf)
 
-	type Container struct {
-		Rootfiles struct {
-			Rootfile []struct {
-				FullPath string `xml:"full-path,attr"`
-			} `xml:"rootfile"`
-		} `xml:"rootfiles"`
+	// 2. Replace or remove the cover, with the manifest entries and pages that go with it,
+	// and rewrite the table of contents
+	archive := &coverArchive{files: make(map[string]*zip.File), changes: make(map[string][]byte)}
+	for _, file := range reader.zipReader.File {
+		archive.files[file.Name] = file
 	}
-
-	var container Container
-	if err := xml.Unmarshal(containerContent, &container); err != nil {
-		return fmt.Errorf("failed to parse container.xml: %w", err)
+	if e.newCover != "" {
+		if err := e.replaceCover(opf, opfPath, archive); err != nil {
+			return fmt.Errorf("failed to update cover image: %w", err)
+		}
 	}
-
-	if len(container.Rootfiles.Rootfile) == 0 {
-		return fmt.Errorf("no rootfile found in container.xml")
+	if e.noCover {
+		if err := e.removeCover(opf, opfPath, archive); err != nil {
+			return fmt.Errorf("failed to remove cover: %w", err)
+		}
 	}
+	if e.newTOC != nil {
+		if err := e.writeTOC(opf, opfPath, archive); err != nil {
+			return fmt.Errorf("failed to update table of contents: %w", err)
+		}
+	}
+	changes := archive.changes
+	changes[opfPath] = opf.bytes()
 
-	opfPath := filepath.Join(extractDir, container.Rootfiles.Rootfile[0].FullPath)
-	opfContent, err := os.ReadFile(opfPath)
+	// 3. Write the new EPUB next to the original and rename it into place
+	outFile, err := safefile.Create(e.filePath, false)
 	if err != nil {
-		return fmt.Errorf("failed to read OPF file: %w", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
+	defer outFile.Abort()
 
-	// Update metadata in OPF content
-	updatedOPF, err := e.updateOPFContent(opfContent)
-	if err != nil {
-		return fmt.Errorf("failed to update OPF content: %w", err)
+	if err := e.patchEPUB(&reader.zipReader.Reader, outFile, changes); err != nil {
+		return fmt.Errorf("failed to repackage EPUB: %w", err)
 	}
+	reader.Close()
 
-	// Write back to file
-	if err := os.WriteFile(opfPath, updatedOPF, 0644); err != nil {
-		return fmt.Errorf("failed to write updated OPF file: %w", err)
+	if err := outFile.Commit(); err != nil {
+		return fmt.Errorf("failed to replace original file: %w", err)
 	}
 
+	e.metadata.Modified = time.Now()
 	return nil
 }
 
-// updateOPFContent updates the metadata within OPF XML content
-func (e *EPUBEditor) updateOPFContent(opfContent []byte) ([]byte, error) {
-	// Parse OPF
-	opfStr := string(opfContent)
-
+// updateOPF sets the edited metadata in the package document. The package document is
+// parsed and written back, so prefixes, attributes such as opf:role and id, and entities
+// come through as they were.
+func (e *EPUBEditor) updateOPF(opf *opfDocument) {
 	// Update title
-	opfStr = e.replaceXMLElement(opfStr, "dc:title", e.metadata.Title)
+	if e.metadata.Title != "" {
+		opf.setDC("title", e.metadata.Title)
+	}
 
-	// Update creator/author
-	opfStr = e.replaceXMLElement(opfStr, "dc:creator", e.metadata.Author)
+	// Update creator/author, rewriting the whole list if it changed
+	if e.peopleChanged {
+		replacePeople(opf, e.metadata.Creators, e.metadata.Contributors)
+	} else if e.metadata.Author != "" {
+		opf.setDC("creator", e.metadata.Author)
+	}
 
-	// Update description
+	// Description, language and publisher are added if the book has none
 	if e.metadata.Description != "" {
-		opfStr = e.replaceXMLElement(opfStr, "dc:description", e.metadata.Description)
+		opf.setDC("description", e.metadata.Description)
 	}
-
-	// Update language
 	if e.metadata.Language != "" {
-		opfStr = e.replaceXMLElement(opfStr, "dc:language", e.metadata.Language)
+		opf.setDC("language", e.metadata.Language)
 	}
-
-	// Update publisher
 	if e.metadata.Publisher != "" {
-		opfStr = e.replaceXMLElement(opfStr, "dc:publisher", e.metadata.Publisher)
+		opf.setDC("publisher", e.metadata.Publisher)
 	}
 
-	// Update modified timestamp
-	modifiedTime := time.Now().Format(time.RFC3339)
-	opfStr = e.replaceMetaProperty(opfStr, "dcterms:modified", modifiedTime)
-
-	return []byte(opfStr), nil
-}
-
-// replaceXMLElement replaces the content of an XML element
-func (e *EPUBEditor) replaceXMLElement(content, element, newValue string) string {
-	// Find the opening tag (with possible attributes)
-	startPattern := fmt.Sprintf(`<%s`, element)
-	startIdx := strings.Index(content, startPattern)
-	if startIdx == -1 {
-		return content
-	}
-
-	// Find the end of the opening tag
-	tagEndIdx := strings.Index(content[startIdx:], ">")
-	if tagEndIdx == -1 {
-		return content
+	// Rights, subjects and series are often missing entirely too
+	if e.metadata.Rights != "" {
+		opf.setDC("rights", e.metadata.Rights)
 	}
-	tagEndIdx += startIdx + 1
-
-	// Find the closing tag
-	endPattern := fmt.Sprintf(`</%s>`, element)
-	endIdx := strings.Index(content[tagEndIdx:], endPattern)
-	if endIdx == -1 {
-		return content
-	}
-	endIdx += tagEndIdx
-
-	// Replace the content between tags
-	before := content[:tagEndIdx]
-	after := content[endIdx:]
-
-	return before + newValue + after
-}
 
-// replaceMetaProperty replaces the content of a meta property
-func (e *EPUBEditor) replaceMetaProperty(content, property, newValue string) string {
-	pattern := fmt.Sprintf(`property="%s"`, property)
-	if strings.Contains(content, pattern) {
-		// Find the meta tag with this property
-		startIdx := strings.LastIndex(content[:strings.Index(content, pattern)], "<meta")
-		if startIdx != -1 {
-			endIdx := strings.Index(content[startIdx:], "/>")
-			if endIdx != -1 {
-				endIdx += startIdx + 2
-				// Replace or add the content
-				newMetaTag := fmt.Sprintf(`<meta property="%s">%s</meta>`, property, newValue)
-				return content[:startIdx] + newMetaTag + content[endIdx:]
-			}
+	if len(e.metadata.Subjects) > 0 {
+		opf.removeDC("subject")
+		for _, subject := range e.metadata.Subjects {
+			opf.add(opf.newDC("subject", subject))
 		}
 	}
-	return content
-}
 
-// updateCoverImage updates the cover image in the EPUB
-func (e *EPUBEditor) updateCoverImage(extractDir string) error {
-	// Copy cover image to images directory
-	imagesDir := filepath.Join(extractDir, "EPUB", "images")
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		return fmt.Errorf("failed to create images directory: %w", err)
+	if e.metadata.Series != "" {
+		opf.setNamedMeta("calibre:series", e.metadata.Series)
 	}
-
-	coverExt := filepath.Ext(e.newCover)
-	destPath := filepath.Join(imagesDir, "cover"+coverExt)
-
-	return copyFile(e.newCover, destPath)
-}
-
-// repackageEPUB creates a new EPUB file from the extracted directory
-func (e *EPUBEditor) repackageEPUB(extractDir, outputPath string) error {
-	// Create ZIP file
-	zipFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	if e.metadata.SeriesIndex > 0 {
+		opf.setNamedMeta("calibre:series_index", strconv.FormatFloat(e.metadata.SeriesIndex, 'f', -1, 64))
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// Add mimetype first (uncompressed)
-	mimetypePath := filepath.Join(extractDir, "mimetype")
-	if _, err := os.Stat(mimetypePath); err == nil {
-		mimetypeData, err := os.ReadFile(mimetypePath)
-		if err != nil {
-			return fmt.Errorf("failed to read mimetype: %w", err)
-		}
 
-		w, err := zipWriter.CreateHeader(&zip.FileHeader{
-			Name:   "mimetype",
-			Method: zip.Store, // No compression
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create mimetype entry: %w", err)
-		}
-
-		if _, err := w.Write(mimetypeData); err != nil {
-			return fmt.Errorf("failed to write mimetype: %w", err)
-		}
+	// Update modified timestamp
+	modifiedTime := time.Now().Format(time.RFC3339)
+	if e.reproducible {
+		modifiedTime = epubzip.SourceDate().Format(time.RFC3339)
 	}
+	opf.replaceMetaProperty("dcterms:modified", modifiedTime)
+}
 
-	// Add all other files
-	return filepath.Walk(extractDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip mimetype (already added) and directories
-		relPath, err := filepath.Rel(extractDir, path)
-		if err != nil {
-			return err
-		}
-
-		if relPath == "mimetype" || info.IsDir() {
-			return nil
-		}
-
-		// Create ZIP entry
-		w, err := zipWriter.Create(relPath)
-		if err != nil {
-			return fmt.Errorf("failed to create ZIP entry for %s: %w", relPath, err)
-		}
-
-		// Copy file content
-		fileData, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", path, err)
-		}
-
-		if _, err := w.Write(fileData); err != nil {
-			return fmt.Errorf("failed to write file %s to ZIP: %w", relPath, err)
-		}
-
-		return nil
-	})
+// patchEPUB writes the book to w with the changed entries swapped in (or added)
+func (e *EPUBEditor) patchEPUB(src *zip.Reader, w io.Writer, changes map[string][]byte) error {
+	// New images are stored, since they're compressed already, and the package document deflated
+	return epubzip.Patch(src, w, epubzip.Options{StoreCompressedMedia: true, Reproducible: e.reproducible}, changes)
 }
@@ -0,0 +1,318 @@
+package metadata
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="pub-id" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Old Title</dc:title>
+    <dc:creator id="creator">Someone</dc:creator>
+    <dc:subject>Old Subject</dc:subject>
+    <meta property="dcterms:modified">2020-01-01T00:00:00Z</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+  </manifest>
+</package>`
+
+func TestSaveSeriesSubjectsRights(t *testing.T) {
+	editor := openTestEditor(t, testOPF)
+	editor.SetTitle("Guards & Guards")
+	editor.SetAuthor("Terry Pratchett")
+	editor.SetSeries("Discworld")
+	editor.SetSeriesIndex(8)
+	editor.SetSubjects([]string{"Fantasy", " ", "Humour"})
+	editor.SetRights("© 1989")
+
+	updated := savedOPF(t, editor)
+
+	metadata, err := parseOPFMetadata(updated)
+	if err != nil {
+		t.Fatalf("Updated OPF doesn't parse: %v\n%s", err, updated)
+	}
+
+	if metadata.Title != "Guards & Guards" {
+		t.Errorf("Expected escaped title to round-trip, got %q", metadata.Title)
+	}
+	if metadata.Series != "Discworld" || metadata.SeriesIndex != 8 {
+		t.Errorf("Expected Discworld #8, got %q #%g", metadata.Series, metadata.SeriesIndex)
+	}
+	if strings.Join(metadata.Subjects, ",") != "Fantasy,Humour" {
+		t.Errorf("Expected subjects to be replaced, got %v", metadata.Subjects)
+	}
+	if metadata.Rights != "© 1989" {
+		t.Errorf("Expected rights to be added, got %q", metadata.Rights)
+	}
+
+	// Updating the modified date must not eat the elements that follow it
+	if !strings.Contains(string(updated), `<item id="nav"`) {
+		t.Errorf("Manifest item lost while updating metadata:\n%s", updated)
+	}
+}
+
+// openTestEditor opens an editor on a book with the given package document
+func openTestEditor(t *testing.T, opf string) *EPUBEditor {
+	t.Helper()
+
+	editor, err := NewEPUBEditor(writeTestEPUB(t, [][2]string{{"OEBPS/content.opf", opf}}))
+	if err != nil {
+		t.Fatalf("NewEPUBEditor failed: %v", err)
+	}
+	t.Cleanup(func() { editor.Close() })
+	return editor
+}
+
+// savedOPF saves an editor's changes and reads the package document back from the book
+func savedOPF(t *testing.T, editor *EPUBEditor) []byte {
+	t.Helper()
+
+	if err := editor.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reader, err := NewEPUBReader(editor.filePath)
+	if err != nil {
+		t.Fatalf("The saved EPUB doesn't open: %v", err)
+	}
+	defer reader.Close()
+
+	opf, err := reader.readFileFromZip("OEBPS/content.opf")
+	if err != nil {
+		t.Fatalf("Failed to read the saved package document: %v", err)
+	}
+	return opf
+}
+
+func TestSetSeriesIndexRejectsNonPositive(t *testing.T) {
+	editor := &EPUBEditor{}
+	if err := editor.SetSeriesIndex(0); err == nil {
+		t.Error("Expected an error for series index 0")
+	}
+}
+
+func TestCreatorsAndContributorsRoundTrip(t *testing.T) {
+	// The original creator and its role meta must both go
+	opf := strings.Replace(testOPF, `<dc:creator id="creator">Someone</dc:creator>`,
+		`<dc:creator id="creator">Someone</dc:creator>
+    <meta refines="#creator" property="role" scheme="marc:relators" id="role">aut</meta>`, 1)
+
+	editor := openTestEditor(t, opf)
+	editor.SetCreators([]Contributor{{Name: "Neil Gaiman", Role: "aut"}, {Name: "Terry Pratchett", Role: "aut"}})
+	editor.SetContributors([]Contributor{{Name: "Jane Doe", Role: "ill"}})
+
+	updated := savedOPF(t, editor)
+	if strings.Contains(string(updated), "Someone") || strings.Contains(string(updated), `refines="#creator"`) {
+		t.Errorf("Expected old creator to be removed:\n%s", updated)
+	}
+
+	metadata, err := parseOPFMetadata(updated)
+	if err != nil {
+		t.Fatalf("Updated OPF doesn't parse: %v", err)
+	}
+
+	if metadata.Author != "Neil Gaiman" || len(metadata.Creators) != 2 || metadata.Creators[1].Name != "Terry Pratchett" {
+		t.Errorf("Expected both authors, got %+v", metadata.Creators)
+	}
+	if len(metadata.Contributors) != 1 || metadata.Contributors[0] != (Contributor{Name: "Jane Doe", Role: "ill"}) {
+		t.Errorf("Expected illustrator, got %+v", metadata.Contributors)
+	}
+}
+
+func TestParseEPUB2Roles(t *testing.T) {
+	opf := `<package xmlns="http://www.idpf.org/2007/opf" xmlns:opf="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator opf:role="aut">Selma Lagerlöf</dc:creator>
+    <dc:contributor opf:role="trl">Velma Swanston Howard</dc:contributor>
+  </metadata>
+</package>`
+
+	metadata, err := parseOPFMetadata([]byte(opf))
+	if err != nil {
+		t.Fatalf("parseOPFMetadata failed: %v", err)
+	}
+	if len(metadata.Contributors) != 1 || metadata.Contributors[0].Role != "trl" {
+		t.Errorf("Expected translator role from opf:role, got %+v", metadata.Contributors)
+	}
+}
+
+func TestParseContributor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Contributor
+		wantErr bool
+	}{
+		{"Jane Doe", Contributor{Name: "Jane Doe", Role: "ctb"}, false},
+		{"Jane Doe:trl", Contributor{Name: "Jane Doe", Role: "trl"}, false},
+		{"Jane Doe: ILL", Contributor{Name: "Jane Doe", Role: "ill"}, false},
+		{"Jane Doe:nope", Contributor{}, true},
+		{":trl", Contributor{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseContributor(test.value, "ctb")
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseContributor(%q) = %+v, %v; want %+v", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestReadText(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Romeo &amp; Juliet</dc:title>
+    <meta name="publify:fingerprint" content="fp1:AAAAAQ"/>
+  </metadata>
+  <manifest>
+    <item id="one" href="xhtml/one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="two" href="xhtml/two%20b.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="two"/>
+    <itemref idref="one"/>
+  </spine>
+</package>`
+	epubPath := filepath.Join(t.TempDir(), "romeo.epub")
+	out, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, file := range [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?><container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`},
+		{"EPUB/package.opf", opf},
+		{"EPUB/xhtml/one.xhtml", "<html><head><title>Act II</title></head><body><p>But soft!</p></body></html>"},
+		{"EPUB/xhtml/two b.xhtml", "<html><head><title>Act I</title></head><body><h2>Prologue</h2><p>Two households, both alike in dignity &amp; fair Verona</p></body></html>"},
+	} {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	reader, err := NewEPUBReader(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	text, err := reader.ReadText()
+	if err != nil {
+		t.Fatalf("ReadText failed: %v", err)
+	}
+	if got := strings.Join(strings.Fields(text), " "); got != "Prologue Two households, both alike in dignity & fair Verona But soft!" {
+		t.Errorf("Unexpected text %q", got)
+	}
+
+	meta, err := reader.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Fingerprint != "fp1:AAAAAQ" {
+		t.Errorf("Expected the stored fingerprint, got %q", meta.Fingerprint)
+	}
+}
+
+func TestSaveCopiesUntouchedEntries(t *testing.T) {
+	chapter := "<html><body>" + strings.Repeat("<p>Call me Ishmael.</p>", 200) + "</body></html>"
+	epubPath := filepath.Join(t.TempDir(), "moby.epub")
+	out, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, file := range [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", string(ContainerXML("OEBPS/content.opf"))},
+		{"OEBPS/content.opf", testOPF},
+		{"OEBPS/chapter1.xhtml", chapter},
+		{"OEBPS/fonts/Body.woff2", "wOF2 not really a font"},
+	} {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	before := make(map[string]zip.FileHeader)
+	zr, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range zr.File {
+		before[file.Name] = file.FileHeader
+	}
+	zr.Close()
+
+	coverPath := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(coverPath, []byte("\x89PNG not really a PNG"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	editor, err := NewEPUBEditor(epubPath)
+	if err != nil {
+		t.Fatalf("NewEPUBEditor failed: %v", err)
+	}
+	defer editor.Close()
+	editor.SetTitle("Moby-Dick; or, The Whale")
+	if err := editor.SetCover(coverPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	zr, err = zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("The saved EPUB doesn't open: %v", err)
+	}
+	defer zr.Close()
+
+	if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+		t.Errorf("Expected mimetype first and stored, got %s", zr.File[0].Name)
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/chapter1.xhtml", "OEBPS/fonts/Body.woff2"} {
+		var got *zip.File
+		for _, file := range zr.File {
+			if file.Name == name {
+				got = file
+			}
+		}
+		if got == nil {
+			t.Fatalf("%s went missing", name)
+		}
+		want := before[name]
+		if got.CRC32 != want.CRC32 || got.CompressedSize64 != want.CompressedSize64 || got.Method != want.Method {
+			t.Errorf("Expected %s copied as it was", name)
+		}
+	}
+
+	reader := &EPUBReader{zipReader: zr}
+	opf, err := reader.readFileFromZip("OEBPS/content.opf")
+	if err != nil || !strings.Contains(string(opf), "<dc:title>Moby-Dick; or, The Whale</dc:title>") {
+		t.Errorf("Expected the new title in the package document, got %s (%v)", opf, err)
+	}
+	cover := zr.File[len(zr.File)-1]
+	if cover.Name != "OEBPS/images/cover.png" || cover.Method != zip.Store {
+		t.Errorf("Expected the cover added last and stored, got %s (method %d)", cover.Name, cover.Method)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(epubPath), ".*.tmp")); len(leftovers) > 0 {
+		t.Errorf("Expected no temporary file left behind, got %v", leftovers)
+	}
+}
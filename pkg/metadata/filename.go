@@ -0,0 +1,196 @@
+package metadata
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultFilenameTemplate matches the "Author - Title.epub" naming most libraries use
+const DefaultFilenameTemplate = "{author} - {title}"
+
+// filenameFields lists the placeholders file name templates may use
+var filenameFields = []string{"title", "author", "series", "series_index", "publisher", "language"}
+
+// authorSeparator joins several authors in a file name
+const authorSeparator = " & "
+
+var placeholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// ParseFilename reads metadata out of a file name using a template like
+// "{author} - {title}". The directory and extension are ignored. Several authors
+// in one name are separated by " & ".
+func ParseFilename(path, template string) (Sidecar, error) {
+	pattern, fields, err := filenamePattern(template)
+	if err != nil {
+		return Sidecar{}, err
+	}
+
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	match := pattern.FindStringSubmatch(name)
+	if match == nil {
+		return Sidecar{}, fmt.Errorf("file name %q doesn't match template %q", name, template)
+	}
+
+	var sidecar Sidecar
+	for i, field := range fields {
+		value := strings.TrimSpace(match[i+1])
+		switch field {
+		case "title":
+			sidecar.Title = value
+		case "author":
+			for _, author := range strings.Split(value, authorSeparator) {
+				if author = strings.TrimSpace(author); author != "" {
+					sidecar.Authors = append(sidecar.Authors, Contributor{Name: author, Role: "aut"})
+				}
+			}
+		case "series":
+			sidecar.Series = value
+		case "series_index":
+			index, err := strconv.ParseFloat(value, 64)
+			if err != nil || index <= 0 {
+				return Sidecar{}, fmt.Errorf("invalid series index %q in file name %q", value, name)
+			}
+			sidecar.SeriesIndex = index
+		case "publisher":
+			sidecar.Publisher = value
+		case "language":
+			sidecar.Language = value
+		}
+	}
+
+	return sidecar, nil
+}
+
+// filenamePattern turns a template into a regular expression with one group per placeholder
+func filenamePattern(template string) (*regexp.Regexp, []string, error) {
+	var pattern strings.Builder
+	var fields []string
+	seen := make(map[string]bool)
+
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(template, -1) {
+		field := template[loc[2]:loc[3]]
+		if err := checkFilenameField(field); err != nil {
+			return nil, nil, err
+		}
+		if seen[field] {
+			return nil, nil, fmt.Errorf("placeholder {%s} used twice in template %q", field, template)
+		}
+		seen[field] = true
+
+		pattern.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		pattern.WriteString("(.+?)")
+		fields = append(fields, field)
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString("$")
+
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("template %q has no placeholders (use %s)", template, placeholderList())
+	}
+
+	return regexp.MustCompile(pattern.String()), fields, nil
+}
+
+// FormatFilename builds a file name (without extension) from a book's metadata. Every
+// placeholder in the template must have a value, so no file ends up named " - Title".
+func FormatFilename(meta EPUBMetadata, template string) (string, error) {
+	var missing []string
+	var formatErr error
+
+	name := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		field := match[1 : len(match)-1]
+		if err := checkFilenameField(field); err != nil {
+			formatErr = err
+			return ""
+		}
+
+		value := filenameValue(meta, field)
+		if value == "" {
+			missing = append(missing, field)
+		}
+		return value
+	})
+
+	if formatErr != nil {
+		return "", formatErr
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no %s in metadata", strings.Join(missing, ", "))
+	}
+
+	name = strings.TrimSpace(sanitizeFilename(name))
+	if name == "" {
+		return "", fmt.Errorf("template %q gives an empty file name", template)
+	}
+	return name, nil
+}
+
+func filenameValue(meta EPUBMetadata, field string) string {
+	switch field {
+	case "title":
+		return meta.Title
+	case "author":
+		var authors []string
+		for _, creator := range meta.Creators {
+			if creator.Role == "" || creator.Role == "aut" {
+				authors = append(authors, creator.Name)
+			}
+		}
+		if len(authors) == 0 && meta.Author != "" {
+			authors = []string{meta.Author}
+		}
+		return strings.Join(authors, authorSeparator)
+	case "series":
+		return meta.Series
+	case "series_index":
+		if meta.SeriesIndex <= 0 {
+			return ""
+		}
+		return strconv.FormatFloat(meta.SeriesIndex, 'f', -1, 64)
+	case "publisher":
+		return meta.Publisher
+	case "language":
+		return meta.Language
+	}
+	return ""
+}
+
+// sanitizeFilename replaces characters that aren't allowed in file names on common
+// filesystems. Colons become " -" so "Title: Subtitle" stays readable.
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, ": ", " - ")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+func checkFilenameField(field string) error {
+	for _, known := range filenameFields {
+		if field == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown placeholder {%s} (use %s)", field, placeholderList())
+}
+
+func placeholderList() string {
+	names := make([]string, len(filenameFields))
+	for i, field := range filenameFields {
+		names[i] = "{" + field + "}"
+	}
+	return strings.Join(names, ", ")
+}
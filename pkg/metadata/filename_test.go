@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilename(t *testing.T) {
+	tests := []struct {
+		path     string
+		template string
+		want     Sidecar
+	}{
+		{
+			path:     "/books/Terry Pratchett - Guards! Guards!.epub",
+			template: DefaultFilenameTemplate,
+			want: Sidecar{
+				Title:   "Guards! Guards!",
+				Authors: []Contributor{{Name: "Terry Pratchett", Role: "aut"}},
+			},
+		},
+		{
+			// The title may contain the separator itself
+			path:     "Neil Gaiman & Terry Pratchett - Good Omens - The Nice and Accurate Prophecies.pdf",
+			template: DefaultFilenameTemplate,
+			want: Sidecar{
+				Title:   "Good Omens - The Nice and Accurate Prophecies",
+				Authors: []Contributor{{Name: "Neil Gaiman", Role: "aut"}, {Name: "Terry Pratchett", Role: "aut"}},
+			},
+		},
+		{
+			path:     "Discworld 08 - Guards! Guards! (Terry Pratchett).epub",
+			template: "{series} {series_index} - {title} ({author})",
+			want: Sidecar{
+				Title:       "Guards! Guards!",
+				Authors:     []Contributor{{Name: "Terry Pratchett", Role: "aut"}},
+				Series:      "Discworld",
+				SeriesIndex: 8,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFilename(tt.path, tt.template)
+		if err != nil {
+			t.Errorf("ParseFilename(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFilename(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilenameErrors(t *testing.T) {
+	tests := []struct {
+		path     string
+		template string
+	}{
+		{"Just a title.epub", DefaultFilenameTemplate},
+		{"Author - Title.epub", "{author} - {name}"},
+		{"Author - Title.epub", "no placeholders"},
+		{"Series x - Title.epub", "{series} {series_index} - {title}"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseFilename(tt.path, tt.template); err == nil {
+			t.Errorf("ParseFilename(%q, %q) should fail", tt.path, tt.template)
+		}
+	}
+}
+
+func TestFormatFilename(t *testing.T) {
+	meta := EPUBMetadata{
+		Title:        "Mort: A Discworld Novel",
+		Creators:     []Contributor{{Name: "Terry Pratchett", Role: "aut"}, {Name: "Josh Kirby", Role: "ill"}},
+		Series:       "Discworld",
+		SeriesIndex:  4,
+		Contributors: []Contributor{{Name: "Jane Doe", Role: "edt"}},
+	}
+
+	name, err := FormatFilename(meta, "{series} {series_index} - {author} - {title}")
+	if err != nil {
+		t.Fatalf("FormatFilename failed: %v", err)
+	}
+	if want := "Discworld 4 - Terry Pratchett - Mort - A Discworld Novel"; name != want {
+		t.Errorf("FormatFilename = %q, want %q", name, want)
+	}
+
+	if _, err := FormatFilename(meta, "{publisher} - {title}"); err == nil {
+		t.Error("FormatFilename should fail when a placeholder has no value")
+	}
+}
+
+func TestFilenameRoundTrip(t *testing.T) {
+	meta := EPUBMetadata{
+		Title:    "Good Omens",
+		Creators: []Contributor{{Name: "Neil Gaiman", Role: "aut"}, {Name: "Terry Pratchett", Role: "aut"}},
+	}
+
+	name, err := FormatFilename(meta, DefaultFilenameTemplate)
+	if err != nil {
+		t.Fatalf("FormatFilename failed: %v", err)
+	}
+	parsed, err := ParseFilename(name+".epub", DefaultFilenameTemplate)
+	if err != nil {
+		t.Fatalf("ParseFilename failed: %v", err)
+	}
+	if parsed.Title != meta.Title || !reflect.DeepEqual(parsed.Authors, meta.Creators) {
+		t.Errorf("Round trip gave %+v", parsed)
+	}
+}
@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// largestResourceCount is how many of the biggest files Info lists
+const largestResourceCount = 5
+
+// EPUBInfo is what publify info reports about an EPUB
+type EPUBInfo struct {
+	Version      string
+	Chapters     int            // Documents in the spine
+	Images       int            // Image items in the manifest
+	Words        int            // In the spine documents
+	ChapterWords []ChapterWords // Words in each spine document, in reading order
+	TotalSize    uint64         // Of the files, uncompressed
+	Largest      []EPUBResource
+}
+
+// EPUBResource is a file in the book
+type EPUBResource struct {
+	Path      string // In the archive
+	MediaType string // As the manifest has it, if it lists the file
+	Size      uint64 // Uncompressed
+}
+
+// Info looks the book over: its EPUB version, chapters, images, words and biggest files
+func (r *EPUBReader) Info() (EPUBInfo, error) {
+	opfPath, err := r.findOPFFile()
+	if err != nil {
+		return EPUBInfo{}, fmt.Errorf("failed to find OPF file: %w", err)
+	}
+	opfContent, err := r.readFileFromZip(opfPath)
+	if err != nil {
+		return EPUBInfo{}, fmt.Errorf("failed to read OPF file: %w", err)
+	}
+	opf, err := parseOPF(opfContent)
+	if err != nil {
+		return EPUBInfo{}, err
+	}
+	chapters, err := r.WordCounts()
+	if err != nil {
+		return EPUBInfo{}, err
+	}
+
+	info := EPUBInfo{Version: opf.version(), Chapters: len(chapters), ChapterWords: chapters}
+	for _, chapter := range chapters {
+		info.Words += chapter.Words
+	}
+
+	opfDir := path.Dir(opfPath)
+	mediaTypes := make(map[string]string) // By path in the archive
+	for _, item := range opf.manifestItems() {
+		mediaType := item.attr("media-type")
+		mediaTypes[resolveHref(opfDir, item.attr("href"))] = mediaType
+		if strings.HasPrefix(mediaType, "image/") {
+			info.Images++
+		}
+	}
+
+	for _, file := range r.zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		info.TotalSize += file.UncompressedSize64
+		info.Largest = append(info.Largest, EPUBResource{
+			Path:      file.Name,
+			MediaType: mediaTypes[file.Name],
+			Size:      file.UncompressedSize64,
+		})
+	}
+	sort.SliceStable(info.Largest, func(i, j int) bool { return info.Largest[i].Size > info.Largest[j].Size })
+	if len(info.Largest) > largestResourceCount {
+		info.Largest = info.Largest[:largestResourceCount]
+	}
+
+	return info, nil
+}
@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEPUBInfo(t *testing.T) {
+	reader := openTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Info</dc:title></metadata>
+  <manifest>
+    <item id="ch1" href="Text/chapter%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="Text/ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover" href="Images/cover.jpg" media-type="image/jpeg"/>
+    <item id="map" href="Images/map.png" media-type="image/png"/>
+  </manifest>
+  <spine>
+    <itemref idref="ch1"/>
+    <itemref idref="ch2"/>
+  </spine>
+</package>`},
+		{"OEBPS/Text/chapter 1.xhtml", `<html><head><title>Not counted</title></head><body><h1>One</h1><p>It was a dark&#160;and stormy night.</p></body></html>`},
+		{"OEBPS/Text/ch2.xhtml", `<html><body><p>The end.</p></body></html>`},
+		{"OEBPS/Images/cover.jpg", strings.Repeat("x", 5000)},
+		{"OEBPS/Images/map.png", strings.Repeat("x", 3000)},
+	})
+
+	info, err := reader.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != "2.0" {
+		t.Errorf("Version = %q, expected 2.0", info.Version)
+	}
+	if info.Chapters != 2 || info.Images != 2 {
+		t.Errorf("Chapters, Images = %d, %d, expected 2, 2", info.Chapters, info.Images)
+	}
+	// "One It was a dark and stormy night. The end."
+	if info.Words != 10 {
+		t.Errorf("Words = %d, expected 10", info.Words)
+	}
+	if len(info.ChapterWords) != 2 || info.ChapterWords[0].Words != 8 || info.ChapterWords[0].Title != "One" {
+		t.Errorf("ChapterWords = %+v, expected 8 words in the chapter titled One first", info.ChapterWords)
+	}
+	if len(info.Largest) != 5 {
+		t.Fatalf("Largest = %v, expected the 5 biggest files", info.Largest)
+	}
+	if first := info.Largest[0]; first.Path != "OEBPS/Images/cover.jpg" || first.MediaType != "image/jpeg" || first.Size != 5000 {
+		t.Errorf("Largest[0] = %+v, expected the cover with its media type", first)
+	}
+}
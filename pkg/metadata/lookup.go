@@ -0,0 +1,377 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BookInfo is bibliographic data found in an online catalogue
+type BookInfo struct {
+	Title       string
+	Author      string
+	Description string
+	Publisher   string
+	ISBN        string
+	CoverURL    string
+	Source      string // Which catalogue the data came from
+}
+
+// Lookup queries Open Library and Google Books for book metadata
+type Lookup struct {
+	client         *http.Client
+	openLibraryURL string
+	googleBooksURL string
+}
+
+// NewLookup creates a lookup client for the public catalogue APIs
+func NewLookup() *Lookup {
+	return &Lookup{
+		client:         &http.Client{Timeout: 15 * time.Second},
+		openLibraryURL: "https://openlibrary.org",
+		googleBooksURL: "https://www.googleapis.com/books/v1",
+	}
+}
+
+// ByISBN looks up a single book, combining both catalogues: Open Library tends to have
+// better covers and publishers, Google Books has descriptions
+func (l *Lookup) ByISBN(ctx context.Context, value string) (*BookInfo, error) {
+	isbn, ok := NormalizeISBN(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid ISBN: %s", value)
+	}
+
+	olInfo, olErr := l.openLibraryISBN(ctx, isbn)
+	gbInfo, gbErr := l.googleBooks(ctx, "isbn:"+isbn, 1)
+
+	var result *BookInfo
+	switch {
+	case olInfo != nil:
+		result = olInfo
+		if len(gbInfo) > 0 {
+			fillMissing(result, gbInfo[0])
+		}
+	case len(gbInfo) > 0:
+		result = &gbInfo[0]
+	case olErr != nil:
+		return nil, olErr
+	case gbErr != nil:
+		return nil, gbErr
+	default:
+		return nil, fmt.Errorf("no book found for ISBN %s", isbn)
+	}
+
+	result.ISBN = isbn
+	return result, nil
+}
+
+// Search finds candidate books by title and (optionally) author, best matches first
+func (l *Lookup) Search(ctx context.Context, title, author string, limit int) ([]BookInfo, error) {
+	if strings.TrimSpace(title) == "" {
+		return nil, fmt.Errorf("a title is required to search")
+	}
+
+	candidates, olErr := l.openLibrarySearch(ctx, title, author, limit)
+
+	query := "intitle:" + title
+	if author != "" {
+		query += " inauthor:" + author
+	}
+	gbCandidates, gbErr := l.googleBooks(ctx, query, limit)
+	candidates = append(candidates, gbCandidates...)
+
+	if len(candidates) == 0 {
+		if olErr != nil {
+			return nil, olErr
+		}
+		if gbErr != nil {
+			return nil, gbErr
+		}
+	}
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// DownloadCover saves a cover image into dir and returns its path
+func (l *Lookup) DownloadCover(ctx context.Context, coverURL, dir string) (string, error) {
+	resp, err := l.get(ctx, coverURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download cover: %w", err)
+	}
+	defer resp.Body.Close()
+
+	ext := ".jpg"
+	if strings.Contains(resp.Header.Get("Content-Type"), "png") {
+		ext = ".png"
+	}
+
+	coverPath := filepath.Join(dir, "fetched-cover"+ext)
+	file, err := os.Create(coverPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cover file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to save cover: %w", err)
+	}
+
+	return coverPath, nil
+}
+
+// openLibraryISBN uses the Books API, which returns richer data than search for a known ISBN
+func (l *Lookup) openLibraryISBN(ctx context.Context, isbn string) (*BookInfo, error) {
+	var result map[string]struct {
+		Title   string `json:"title"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Publishers []struct {
+			Name string `json:"name"`
+		} `json:"publishers"`
+		Cover struct {
+			Large  string `json:"large"`
+			Medium string `json:"medium"`
+		} `json:"cover"`
+	}
+
+	endpoint := fmt.Sprintf("%s/api/books?bibkeys=ISBN:%s&format=json&jscmd=data", l.openLibraryURL, isbn)
+	if err := l.getJSON(ctx, endpoint, &result); err != nil {
+		return nil, fmt.Errorf("open library lookup failed: %w", err)
+	}
+
+	book, ok := result["ISBN:"+isbn]
+	if !ok {
+		return nil, nil
+	}
+
+	info := &BookInfo{Title: book.Title, Source: "Open Library"}
+	var authors []string
+	for _, author := range book.Authors {
+		authors = append(authors, author.Name)
+	}
+	info.Author = strings.Join(authors, ", ")
+	if len(book.Publishers) > 0 {
+		info.Publisher = book.Publishers[0].Name
+	}
+	info.CoverURL = book.Cover.Large
+	if info.CoverURL == "" {
+		info.CoverURL = book.Cover.Medium
+	}
+
+	return info, nil
+}
+
+func (l *Lookup) openLibrarySearch(ctx context.Context, title, author string, limit int) ([]BookInfo, error) {
+	var result struct {
+		Docs []struct {
+			Title      string   `json:"title"`
+			AuthorName []string `json:"author_name"`
+			Publisher  []string `json:"publisher"`
+			ISBN       []string `json:"isbn"`
+			CoverID    int      `json:"cover_i"`
+		} `json:"docs"`
+	}
+
+	params := url.Values{}
+	params.Set("title", title)
+	if author != "" {
+		params.Set("author", author)
+	}
+	params.Set("limit", fmt.Sprint(limit))
+
+	if err := l.getJSON(ctx, l.openLibraryURL+"/search.json?"+params.Encode(), &result); err != nil {
+		return nil, fmt.Errorf("open library search failed: %w", err)
+	}
+
+	var candidates []BookInfo
+	for _, doc := range result.Docs {
+		info := BookInfo{
+			Title:  doc.Title,
+			Author: strings.Join(doc.AuthorName, ", "),
+			Source: "Open Library",
+		}
+		if len(doc.Publisher) > 0 {
+			info.Publisher = doc.Publisher[0]
+		}
+		for _, isbn := range doc.ISBN {
+			if normalized, ok := NormalizeISBN(isbn); ok {
+				info.ISBN = normalized
+				break
+			}
+		}
+		if doc.CoverID != 0 {
+			info.CoverURL = fmt.Sprintf("https://covers.openlibrary.org/b/id/%d-L.jpg", doc.CoverID)
+		}
+		candidates = append(candidates, info)
+	}
+
+	return candidates, nil
+}
+
+func (l *Lookup) googleBooks(ctx context.Context, query string, limit int) ([]BookInfo, error) {
+	var result struct {
+		Items []struct {
+			VolumeInfo struct {
+				Title               string   `json:"title"`
+				Authors             []string `json:"authors"`
+				Publisher           string   `json:"publisher"`
+				Description         string   `json:"description"`
+				IndustryIdentifiers []struct {
+					Type       string `json:"type"`
+					Identifier string `json:"identifier"`
+				} `json:"industryIdentifiers"`
+				ImageLinks struct {
+					Thumbnail string `json:"thumbnail"`
+				} `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("maxResults", fmt.Sprint(limit))
+
+	if err := l.getJSON(ctx, l.googleBooksURL+"/volumes?"+params.Encode(), &result); err != nil {
+		return nil, fmt.Errorf("google books lookup failed: %w", err)
+	}
+
+	var candidates []BookInfo
+	for _, item := range result.Items {
+		volume := item.VolumeInfo
+		info := BookInfo{
+			Title:       volume.Title,
+			Author:      strings.Join(volume.Authors, ", "),
+			Publisher:   volume.Publisher,
+			Description: volume.Description,
+			Source:      "Google Books",
+			// Google serves thumbnails over http; the https version works too
+			CoverURL: strings.Replace(volume.ImageLinks.Thumbnail, "http://", "https://", 1),
+		}
+		for _, id := range volume.IndustryIdentifiers {
+			if id.Type == "ISBN_13" || (id.Type == "ISBN_10" && info.ISBN == "") {
+				info.ISBN = id.Identifier
+			}
+		}
+		candidates = append(candidates, info)
+	}
+
+	return candidates, nil
+}
+
+func (l *Lookup) getJSON(ctx context.Context, endpoint string, target interface{}) error {
+	resp, err := l.get(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (l *Lookup) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "publify-cli")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// fillMissing copies fields from other into info where info has nothing
+func fillMissing(info *BookInfo, other BookInfo) {
+	if info.Title == "" {
+		info.Title = other.Title
+	}
+	if info.Author == "" {
+		info.Author = other.Author
+	}
+	if info.Description == "" {
+		info.Description = other.Description
+	}
+	if info.Publisher == "" {
+		info.Publisher = other.Publisher
+	}
+	if info.CoverURL == "" {
+		info.CoverURL = other.CoverURL
+	}
+}
+
+var isbnPattern = regexp.MustCompile(`(?i)ISBN(?:-1[03])?:?\s*((?:97[89][\s-]?)?(?:\d[\s-]?){9}[\dX])`)
+
+// FindISBN returns the first valid ISBN mentioned in a text, such as a copyright page
+func FindISBN(text string) string {
+	for _, match := range isbnPattern.FindAllStringSubmatch(text, -1) {
+		if isbn, ok := NormalizeISBN(match[1]); ok {
+			return isbn
+		}
+	}
+	return ""
+}
+
+// NormalizeISBN strips prefixes, spaces and hyphens and validates the check digit.
+// Identifiers like "urn:isbn:978..." are accepted.
+func NormalizeISBN(value string) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "URN:ISBN:")
+	value = strings.TrimPrefix(value, "ISBN")
+	value = strings.TrimLeft(value, ": ")
+	value = strings.NewReplacer("-", "", " ", "").Replace(value)
+
+	switch len(value) {
+	case 10:
+		sum := 0
+		for i, c := range value {
+			var digit int
+			switch {
+			case c >= '0' && c <= '9':
+				digit = int(c - '0')
+			case c == 'X' && i == 9:
+				digit = 10
+			default:
+				return "", false
+			}
+			sum += digit * (10 - i)
+		}
+		return value, sum%11 == 0
+	case 13:
+		sum := 0
+		for i, c := range value {
+			if c < '0' || c > '9' {
+				return "", false
+			}
+			digit := int(c - '0')
+			if i%2 == 1 {
+				digit *= 3
+			}
+			sum += digit
+		}
+		return value, sum%10 == 0
+	}
+
+	return "", false
+}
@@ -0,0 +1,398 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	dcNamespace  = "http://purl.org/dc/elements/1.1/"
+	opfNamespace = "http://www.idpf.org/2007/opf"
+)
+
+// xmlNode is a node of a parsed XML document: an element, text, or a comment, processing
+// instruction or directive kept as it was written. Names keep the prefix they were written
+// with in Space, so a document is written back with the namespaces it came with.
+type xmlNode struct {
+	name     xml.Name   // Element name ("" = not an element)
+	attrs    []xml.Attr // Attributes, in the order they were written
+	children []*xmlNode
+	parent   *xmlNode
+	text     string // Text, unescaped
+	raw      string // Comment, processing instruction or directive, written back as it is
+}
+
+// parseXMLDocument parses a document into a tree, keeping everything needed to write it
+// back unchanged apart from empty elements, which come back self-closing
+func parseXMLDocument(content []byte) (*xmlNode, error) {
+	root := &xmlNode{}
+	current := root
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Entity = xml.HTMLEntity // XHTML documents may use &nbsp; and the like
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &xmlNode{name: t.Name, attrs: append([]xml.Attr(nil), t.Attr...), parent: current}
+			current.children = append(current.children, element)
+			current = element
+		case xml.EndElement:
+			if current == root || current.name != t.Name {
+				return nil, fmt.Errorf("unexpected </%s>", qualifiedName(t.Name))
+			}
+			current = current.parent
+		case xml.CharData:
+			current.children = append(current.children, &xmlNode{text: string(t), parent: current})
+		case xml.Comment:
+			current.children = append(current.children, &xmlNode{raw: "<!--" + string(t) + "-->", parent: current})
+		case xml.ProcInst:
+			raw := "<?" + t.Target
+			if len(t.Inst) > 0 {
+				raw += " " + string(t.Inst)
+			}
+			current.children = append(current.children, &xmlNode{raw: raw + "?>", parent: current})
+		case xml.Directive:
+			current.children = append(current.children, &xmlNode{raw: "<!" + string(t) + ">", parent: current})
+		}
+	}
+	if current != root {
+		return nil, fmt.Errorf("<%s> isn't closed", qualifiedName(current.name))
+	}
+
+	return root, nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// isElement reports whether the node is an element rather than text or markup
+func (n *xmlNode) isElement() bool {
+	return n.name.Local != ""
+}
+
+// write writes the node and everything in it
+func (n *xmlNode) write(b *strings.Builder) {
+	switch {
+	case n.isElement():
+		b.WriteString("<" + qualifiedName(n.name))
+		for _, attr := range n.attrs {
+			b.WriteString(" " + qualifiedName(attr.Name) + `="` + attrEscaper.Replace(attr.Value) + `"`)
+		}
+		if len(n.children) == 0 {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteString(">")
+		for _, child := range n.children {
+			child.write(b)
+		}
+		b.WriteString("</" + qualifiedName(n.name) + ">")
+	case n.raw != "":
+		b.WriteString(n.raw)
+	default:
+		b.WriteString(textEscaper.Replace(n.text))
+	}
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\n", "&#10;", "\r", "&#13;", "\t", "&#9;")
+)
+
+// namespace returns the namespace a prefix stands for at the node ("" = the default namespace)
+func (n *xmlNode) namespace(prefix string) string {
+	for element := n; element != nil; element = element.parent {
+		for _, attr := range element.attrs {
+			if (prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns") ||
+				(prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix) {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// prefixFor returns the prefix a namespace is written with at the node, if it has one
+func (n *xmlNode) prefixFor(space string) (string, bool) {
+	for element := n; element != nil; element = element.parent {
+		for _, attr := range element.attrs {
+			if attr.Value != space {
+				continue
+			}
+			if attr.Name.Space == "xmlns" {
+				return attr.Name.Local, true
+			}
+			if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+				return "", true
+			}
+		}
+	}
+	return "", false
+}
+
+// is reports whether the node is the element local in namespace space
+func (n *xmlNode) is(space, local string) bool {
+	return n.isElement() && n.name.Local == local && n.namespace(n.name.Space) == space
+}
+
+// attr returns an unprefixed attribute's value
+func (n *xmlNode) attr(name string) string {
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// setAttr sets an unprefixed attribute, adding it if it isn't there
+func (n *xmlNode) setAttr(name, value string) {
+	for i, attr := range n.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			n.attrs[i].Value = value
+			return
+		}
+	}
+	n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+// setText replaces everything in the element with text
+func (n *xmlNode) setText(text string) {
+	n.children = []*xmlNode{{text: text, parent: n}}
+}
+
+// isSpace reports whether the node is text that's only whitespace
+func (n *xmlNode) isSpace() bool {
+	return !n.isElement() && n.raw == "" && strings.TrimSpace(n.text) == ""
+}
+
+// opfDocument is a package document being edited
+type opfDocument struct {
+	root     *xmlNode
+	pkg      *xmlNode // The <package> element
+	metadata *xmlNode // Its <metadata> element
+}
+
+// parseOPF parses a package document for editing
+func parseOPF(content []byte) (*opfDocument, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OPF: %w", err)
+	}
+
+	doc := &opfDocument{root: root}
+	for _, child := range root.children {
+		if child.isElement() && child.name.Local == "package" {
+			doc.pkg = child
+		}
+	}
+	if doc.pkg == nil {
+		return nil, fmt.Errorf("no <package> element in the OPF")
+	}
+	for _, child := range doc.pkg.children {
+		if child.isElement() && child.name.Local == "metadata" {
+			doc.metadata = child
+			break
+		}
+	}
+	if doc.metadata == nil {
+		return nil, fmt.Errorf("no <metadata> element in the OPF")
+	}
+
+	return doc, nil
+}
+
+// bytes writes the document back out
+func (d *opfDocument) bytes() []byte {
+	return documentBytes(d.root)
+}
+
+// version returns the package's EPUB version, as in "3.0"
+func (d *opfDocument) version() string {
+	return d.pkg.attr("version")
+}
+
+// dcElements returns the Dublin Core elements of the metadata with the local name, as
+// dc:title or however the book's prefix has them
+func (d *opfDocument) dcElements(local string) []*xmlNode {
+	var elements []*xmlNode
+	for _, child := range d.metadata.children {
+		if child.is(dcNamespace, local) {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// metas returns the metadata's <meta> elements
+func (d *opfDocument) metas() []*xmlNode {
+	var metas []*xmlNode
+	for _, child := range d.metadata.children {
+		if child.isElement() && child.name.Local == "meta" && child.namespace(child.name.Space) != dcNamespace {
+			metas = append(metas, child)
+		}
+	}
+	return metas
+}
+
+// setDC sets the text of the first Dublin Core element with the local name, keeping its
+// attributes, and adds the element if the book doesn't have one
+func (d *opfDocument) setDC(local, value string) {
+	if elements := d.dcElements(local); len(elements) > 0 {
+		elements[0].setText(value)
+		return
+	}
+	d.add(d.newDC(local, value))
+}
+
+// removeDC removes every Dublin Core element with the local name
+func (d *opfDocument) removeDC(local string) {
+	for _, element := range d.dcElements(local) {
+		d.remove(element)
+	}
+}
+
+// setNamedMeta sets a <meta name="..." content="..."/>, as calibre uses, adding it if needed
+func (d *opfDocument) setNamedMeta(name, value string) {
+	for _, meta := range d.metas() {
+		if meta.attr("name") == name {
+			meta.setAttr("content", value)
+			return
+		}
+	}
+	meta := d.newMeta()
+	meta.setAttr("name", name)
+	meta.setAttr("content", value)
+	d.add(meta)
+}
+
+// replaceMetaProperty sets the text of the <meta property="..."> for a property, if the
+// book has one
+func (d *opfDocument) replaceMetaProperty(property, value string) {
+	for _, meta := range d.metas() {
+		if meta.attr("property") == property {
+			meta.setText(value)
+			return
+		}
+	}
+}
+
+// newDC returns a Dublin Core element, written with the prefix the book uses for them
+// (binding dc: on the metadata if it has none)
+func (d *opfDocument) newDC(local, value string) *xmlNode {
+	prefix, ok := d.metadata.prefixFor(dcNamespace)
+	if !ok {
+		prefix = "dc"
+		d.metadata.attrs = append(d.metadata.attrs, xml.Attr{Name: xml.Name{Space: "xmlns", Local: "dc"}, Value: dcNamespace})
+	}
+	element := &xmlNode{name: xml.Name{Space: prefix, Local: local}}
+	element.setText(value)
+	return element
+}
+
+// newMeta returns an empty <meta>, in the metadata's namespace
+func (d *opfDocument) newMeta() *xmlNode {
+	return &xmlNode{name: xml.Name{Space: d.metadata.name.Space, Local: "meta"}}
+}
+
+// opfAttr returns an attribute in the OPF namespace, as opf:role is in EPUB 2 (binding
+// opf: on the metadata if it has no prefix)
+func (d *opfDocument) opfAttr(local, value string) xml.Attr {
+	prefix, ok := d.metadata.prefixFor(opfNamespace)
+	if !ok || prefix == "" {
+		prefix = "opf"
+		if d.metadata.namespace("opf") != opfNamespace {
+			d.metadata.attrs = append(d.metadata.attrs, xml.Attr{Name: xml.Name{Space: "xmlns", Local: "opf"}, Value: opfNamespace})
+		}
+	}
+	return xml.Attr{Name: xml.Name{Space: prefix, Local: local}, Value: value}
+}
+
+// add appends an element to the metadata, indented like the elements already there
+func (d *opfDocument) add(element *xmlNode) {
+	d.metadata.appendIndented(element)
+}
+
+// remove takes an element out of the metadata, along with the whitespace indenting it
+func (d *opfDocument) remove(element *xmlNode) {
+	d.metadata.removeChild(element)
+}
+
+// appendIndented appends an element, indented like the elements already there
+func (n *xmlNode) appendIndented(element *xmlNode) {
+	indent := "\n    "
+	for i, child := range n.children {
+		if child.isElement() {
+			if i > 0 && n.children[i-1].isSpace() {
+				indent = n.children[i-1].text
+			}
+			break
+		}
+	}
+
+	element.parent = n
+	for _, child := range element.children {
+		child.parent = element
+	}
+	added := []*xmlNode{{text: indent, parent: n}, element}
+
+	// Before the whitespace that indents the closing tag, if there is some
+	children := n.children
+	if count := len(children); count > 0 && children[count-1].isSpace() {
+		n.children = append(children[:count-1:count-1], append(added, children[count-1])...)
+		return
+	}
+	n.children = append(append(children, added...), &xmlNode{text: "\n", parent: n})
+}
+
+// removeChild takes an element out, along with the whitespace indenting it
+func (n *xmlNode) removeChild(element *xmlNode) {
+	children := n.children
+	for i, child := range children {
+		if child != element {
+			continue
+		}
+		start := i
+		if i > 0 && children[i-1].isSpace() {
+			start = i - 1
+		}
+		n.children = append(children[:start:start], children[i+1:]...)
+		return
+	}
+}
+
+// child returns the first child element with the local name, if there is one
+func (n *xmlNode) child(local string) *xmlNode {
+	for _, child := range n.children {
+		if child.isElement() && child.name.Local == local {
+			return child
+		}
+	}
+	return nil
+}
+
+// elements returns the child elements with the local name
+func (n *xmlNode) elements(local string) []*xmlNode {
+	var elements []*xmlNode
+	for _, child := range n.children {
+		if child.isElement() && child.name.Local == local {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
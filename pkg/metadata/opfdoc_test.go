@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOPFDocumentRoundTrip(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Made by hand -->
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:title>Fish &amp; Chips &lt;2nd edition&gt;</dc:title>
+    <dc:identifier id="uid" opf:scheme="ISBN">9780000000000</dc:identifier>
+    <meta name="cover" content="cover-image"/>
+  </metadata>
+  <spine toc="ncx"><itemref idref="c1"/></spine>
+</package>
+`
+	doc, err := parseOPF([]byte(opf))
+	if err != nil {
+		t.Fatalf("parseOPF failed: %v", err)
+	}
+	if got := string(doc.bytes()); got != opf {
+		t.Errorf("Expected an untouched OPF written back as it was, got:\n%s", got)
+	}
+}
+
+func TestSaveKeepsPrefixesAndAttributes(t *testing.T) {
+	// calibre and older tools write the metadata with an opf: prefix and roles as attributes
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<opf:package xmlns:opf="http://www.idpf.org/2007/opf" version="2.0">
+  <opf:metadata xmlns:purl="http://purl.org/dc/elements/1.1/">
+    <purl:title>Old</purl:title>
+    <purl:creator opf:role="aut" opf:file-as="Lagerlöf, Selma" id="author">Selma Lagerlöf</purl:creator>
+    <purl:creator opf:role="ill">Bertil Lybeck</purl:creator>
+    <purl:subject>Geese</purl:subject>
+    <opf:meta name="calibre:series" content="None"/>
+  </opf:metadata>
+</opf:package>
+`
+	editor := openTestEditor(t, opf)
+	editor.SetTitle(`The "Wonderful" Adventures of Nils & Akka`)
+	editor.SetAuthor("Selma Ottilia Lovisa Lagerlöf")
+	editor.SetSubjects([]string{"Sweden", "Birds"})
+	editor.SetSeries("Nils")
+
+	updated := savedOPF(t, editor)
+	out := string(updated)
+
+	for _, want := range []string{
+		`<purl:title>The "Wonderful" Adventures of Nils &amp; Akka</purl:title>`,
+		`<purl:creator opf:role="aut" opf:file-as="Lagerlöf, Selma" id="author">Selma Ottilia Lovisa Lagerlöf</purl:creator>`,
+		`<purl:creator opf:role="ill">Bertil Lybeck</purl:creator>`,
+		"<purl:subject>Sweden</purl:subject>\n    <purl:subject>Birds</purl:subject>",
+		`<opf:meta name="calibre:series" content="Nils"/>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Geese") || strings.Contains(out, "<dc:") {
+		t.Errorf("Expected the old subject gone and no dc: prefix made up:\n%s", out)
+	}
+
+	metadata, err := parseOPFMetadata(updated)
+	if err != nil {
+		t.Fatalf("Updated OPF doesn't parse: %v", err)
+	}
+	if metadata.Title != `The "Wonderful" Adventures of Nils & Akka` {
+		t.Errorf("Unexpected title %q", metadata.Title)
+	}
+}
+
+func TestReplacePeopleEPUB2(t *testing.T) {
+	opf := `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:creator>Anonymous</dc:creator>
+  </metadata>
+</package>`
+	doc, err := parseOPF([]byte(opf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacePeople(doc, []Contributor{{Name: "Selma Lagerlöf", Role: "aut"}}, []Contributor{{Name: "Velma Swanston Howard", Role: "trl"}})
+
+	out := string(doc.bytes())
+	if !strings.Contains(out, `xmlns:opf="http://www.idpf.org/2007/opf"`) {
+		t.Errorf("Expected the opf prefix bound for the roles:\n%s", out)
+	}
+	metadata, err := parseOPFMetadata(doc.bytes())
+	if err != nil {
+		t.Fatalf("Updated OPF doesn't parse: %v\n%s", err, out)
+	}
+	if metadata.Author != "Selma Lagerlöf" || len(metadata.Contributors) != 1 || metadata.Contributors[0].Role != "trl" {
+		t.Errorf("Expected the author and translator, got %+v %+v", metadata.Creators, metadata.Contributors)
+	}
+}
+
+func TestParseOPFRejectsBrokenXML(t *testing.T) {
+	if _, err := parseOPF([]byte(`<package><metadata><dc:title>Open</metadata></package>`)); err == nil {
+		t.Error("Expected mismatched tags refused rather than written back")
+	}
+}
+
+func TestSaveAddsMissingElements(t *testing.T) {
+	opf := `<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1</dc:identifier>
+  </metadata>
+</package>`
+	editor := openTestEditor(t, opf)
+	editor.SetTitle("Mort")
+	editor.SetAuthor("Terry Pratchett")
+	editor.SetDescription("Death takes an apprentice.")
+	editor.SetLanguage("en")
+	editor.SetPublisher("Gollancz")
+
+	updated := savedOPF(t, editor)
+	metadata, err := parseOPFMetadata(updated)
+	if err != nil {
+		t.Fatalf("Updated OPF doesn't parse: %v\n%s", err, updated)
+	}
+	if metadata.Title != "Mort" || metadata.Author != "Terry Pratchett" || metadata.Description != "Death takes an apprentice." ||
+		metadata.Language != "en" || metadata.Publisher != "Gollancz" {
+		t.Errorf("Expected every missing element added, got %+v\n%s", metadata, updated)
+	}
+	if !strings.Contains(string(updated), "\n    <dc:publisher>Gollancz</dc:publisher>\n  </metadata>") {
+		t.Errorf("Expected new elements indented like the others:\n%s", updated)
+	}
+}
@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"fmt"
+	"html"
+	"time"
+	"unicode"
+)
+
+// DefaultWPM is the reading speed reading times are estimated at, in words a minute: about
+// what adults manage reading English silently
+const DefaultWPM = 238
+
+// ChapterWords is how long a chapter is
+type ChapterWords struct {
+	Title string `json:"title"`
+	Path  string `json:"path,omitempty"`
+	Words int    `json:"words"`
+}
+
+// CountWords counts the words in text. Chinese and Japanese aren't written with spaces, so
+// each of their characters counts as a word there; runs of punctuation don't count.
+func CountWords(text string) int {
+	words, inWord, counted := 0, false, false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			words++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		default:
+			if !inWord {
+				inWord, counted = true, false
+			}
+			if !counted && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				words++
+				counted = true
+			}
+		}
+	}
+	return words
+}
+
+// ReadingTime estimates how long words take to read at wpm words a minute (0 = DefaultWPM)
+func ReadingTime(words, wpm int) time.Duration {
+	if wpm <= 0 {
+		wpm = DefaultWPM
+	}
+	return time.Duration(float64(words) / float64(wpm) * float64(time.Minute))
+}
+
+// FormatReadingTime gives a reading time to the minute, as "3 h 20 min"
+func FormatReadingTime(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	switch {
+	case minutes == 0:
+		return "under a minute"
+	case minutes < 60:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d h", minutes/60)
+	}
+	return fmt.Sprintf("%d h %d min", minutes/60, minutes%60)
+}
+
+// WordCounts counts the words of each of the book's spine documents, in reading order
+func (r *EPUBReader) WordCounts() ([]ChapterWords, error) {
+	chapters, err := r.GetChapterList()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]ChapterWords, len(chapters))
+	for i, chapter := range chapters {
+		content, err := r.ReadContent(chapter.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", chapter.Path, err)
+		}
+		counts[i] = ChapterWords{Title: chapter.Title, Path: chapter.Path, Words: CountWords(documentText(content))}
+	}
+	return counts, nil
+}
+
+// documentText returns a content document's text, without its markup
+func documentText(content []byte) string {
+	return html.UnescapeString(markupPattern.ReplaceAllString(string(content), " "))
+}
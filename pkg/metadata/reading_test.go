@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"It was a dark and stormy night.", 7},
+		{"  well-known —  words, 1987 ... ", 3},
+		{"吾輩は猫である", 7},
+		{"Natsume 漱石", 3},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CountWords(tt.text); got != tt.expected {
+			t.Errorf("CountWords(%q) = %d, expected %d", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	if got := ReadingTime(476, 0); got != 2*time.Minute {
+		t.Errorf("ReadingTime(476, 0) = %v, expected 2m at the default speed", got)
+	}
+	if got := ReadingTime(900, 300); got != 3*time.Minute {
+		t.Errorf("ReadingTime(900, 300) = %v, expected 3m", got)
+	}
+}
+
+func TestFormatReadingTime(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{20 * time.Second, "under a minute"},
+		{42*time.Minute + 20*time.Second, "42 min"},
+		{2 * time.Hour, "2 h"},
+		{3*time.Hour + 19*time.Minute + 45*time.Second, "3 h 20 min"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatReadingTime(tt.d); got != tt.expected {
+			t.Errorf("FormatReadingTime(%v) = %q, expected %q", tt.d, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package metadata
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/internal/epubzip"
+)
+
+const epubMimetype = "application/epub+zip"
+
+var (
+	rootfilePath  = regexp.MustCompile(`<rootfile\s[^>]*full-path="([^"]+)"`)
+	packageTag    = regexp.MustCompile(`<package\s[^>]*>`)
+	versionAttr   = regexp.MustCompile(`\sversion="([^"]*)"`)
+	manifestItem  = regexp.MustCompile(`[ \t]*<item\s[^>]*?(?:/>|>\s*</item>)[ \t]*\n?`)
+	spineItemref  = regexp.MustCompile(`[ \t]*<itemref\s[^>]*?(?:/>|>\s*</itemref>)[ \t]*\n?`)
+	titleElement  = regexp.MustCompile(`(?s)<title[^>]*>(.*?)</title>`)
+	headingTag    = regexp.MustCompile(`(?s)<h[1-3][^>]*>(.*?)</h[1-3]>`)
+	markupTag     = regexp.MustCompile(`<[^>]+>`)
+	manifestClose = regexp.MustCompile(`\s*</manifest>`)
+)
+
+// xmlAttr reads an attribute from a single tag
+func xmlAttr(tag, name string) string {
+	match := regexp.MustCompile(`\s` + regexp.QuoteMeta(name) + `="([^"]*)"`).FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+	return html.UnescapeString(match[1])
+}
+
+// RepairEPUB copies an EPUB to dst, fixing the problems that most often stop readers from
+// opening a book:
+//   - a missing or wrong mimetype entry, or one that isn't first and uncompressed
+//   - a missing META-INF/container.xml, when the package document can be found
+//   - manifest items (and their spine entries) pointing to files that don't exist
+//   - a missing EPUB 3 navigation document, rebuilt from the spine
+//
+// It returns a description of every fix. When there's nothing to fix, nothing is written.
+func RepairEPUB(src *zip.Reader, dst io.Writer) ([]string, error) {
+	var fixes []string
+
+	entries := make(map[string]*zip.File)
+	for _, file := range src.File {
+		entries[file.Name] = file
+	}
+
+	// mimetype: present, correct, first and stored
+	if file, ok := entries["mimetype"]; !ok {
+		fixes = append(fixes, "Added missing mimetype entry")
+	} else {
+		content, err := readZipEntry(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mimetype: %w", err)
+		}
+		switch {
+		case strings.TrimSpace(string(content)) != epubMimetype:
+			fixes = append(fixes, fmt.Sprintf("Replaced mimetype %q with %q", strings.TrimSpace(string(content)), epubMimetype))
+		case string(content) != epubMimetype:
+			fixes = append(fixes, "Removed whitespace from mimetype entry")
+		case src.File[0] != file:
+			fixes = append(fixes, "Moved mimetype to the start of the archive")
+		case file.Method != zip.Store:
+			fixes = append(fixes, "Stored mimetype uncompressed")
+		}
+	}
+
+	// container.xml: point it at the package document if it's missing
+	var container []byte
+	opfPath := ""
+	if file, ok := entries["META-INF/container.xml"]; ok {
+		content, err := readZipEntry(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read container.xml: %w", err)
+		}
+		if match := rootfilePath.FindSubmatch(content); match != nil {
+			opfPath = string(match[1])
+		}
+	}
+	if _, ok := entries[opfPath]; !ok {
+		found := findPackageDocument(src)
+		if found == "" {
+			return nil, fmt.Errorf("no package document (.opf) in the EPUB, nothing to repair from")
+		}
+		if opfPath == "" {
+			fixes = append(fixes, fmt.Sprintf("Created META-INF/container.xml pointing to %s", found))
+		} else {
+			fixes = append(fixes, fmt.Sprintf("Pointed META-INF/container.xml to %s (was %s)", found, opfPath))
+		}
+		opfPath = found
+		container = ContainerXML(opfPath)
+	}
+
+	// Package document: drop dangling manifest items and make sure there's a nav
+	opfContent, err := readZipEntry(entries[opfPath])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package document: %w", err)
+	}
+	opf, nav, opfFixes := repairPackage(string(opfContent), path.Dir(opfPath), entries)
+	fixes = append(fixes, opfFixes...)
+
+	if len(fixes) == 0 {
+		return nil, nil
+	}
+
+	if err := writeRepaired(src, dst, opfPath, opf, container, nav); err != nil {
+		return nil, err
+	}
+	return fixes, nil
+}
+
+// generatedNav is a navigation document created by repairPackage
+type generatedNav struct {
+	path    string
+	content []byte
+}
+
+// repairPackage fixes the package document, returning the new content and a nav
+// document to add (if one was built)
+func repairPackage(opf, opfDir string, entries map[string]*zip.File) (string, *generatedNav, []string) {
+	var fixes []string
+	resolve := func(href string) string {
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		return path.Join(opfDir, href)
+	}
+
+	// Remove manifest items whose files are gone, and the spine entries that use them
+	removed := make(map[string]bool)
+	hasNav := false
+	opf = manifestItem.ReplaceAllStringFunc(opf, func(item string) string {
+		href := xmlAttr(item, "href")
+		if strings.Contains(href, "://") {
+			return item // Remote resources aren't in the archive
+		}
+		if _, ok := entries[resolve(href)]; !ok {
+			removed[xmlAttr(item, "id")] = true
+			fixes = append(fixes, fmt.Sprintf("Removed manifest entry for missing file %s", href))
+			return ""
+		}
+		if strings.Contains(" "+xmlAttr(item, "properties")+" ", " nav ") {
+			hasNav = true
+		}
+		return item
+	})
+
+	var spine []string
+	opf = spineItemref.ReplaceAllStringFunc(opf, func(itemref string) string {
+		idref := xmlAttr(itemref, "idref")
+		if removed[idref] {
+			return ""
+		}
+		spine = append(spine, idref)
+		return itemref
+	})
+
+	// EPUB 3 requires a navigation document; EPUB 2 books use the NCX instead
+	version := ""
+	if tag := packageTag.FindString(opf); tag != "" {
+		if match := versionAttr.FindStringSubmatch(tag); match != nil {
+			version = match[1]
+		}
+	}
+	if hasNav || !strings.HasPrefix(version, "3") {
+		return opf, nil, fixes
+	}
+
+	hrefs := make(map[string]string)
+	for _, item := range manifestItem.FindAllString(opf, -1) {
+		hrefs[xmlAttr(item, "id")] = xmlAttr(item, "href")
+	}
+
+	var points []navPoint
+	for _, idref := range spine {
+		href, ok := hrefs[idref]
+		if !ok {
+			continue
+		}
+		content, err := readZipEntry(entries[resolve(href)])
+		if err != nil {
+			continue
+		}
+		points = append(points, navPoint{href: href, title: documentTitle(content, href)})
+	}
+
+	navName := "nav.xhtml"
+	for i := 2; entries[path.Join(opfDir, navName)] != nil; i++ {
+		navName = fmt.Sprintf("nav%d.xhtml", i)
+	}
+	opf = manifestClose.ReplaceAllString(opf,
+		fmt.Sprintf("\n    <item id=\"publify-nav\" href=\"%s\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n  </manifest>", navName))
+	fixes = append(fixes, fmt.Sprintf("Built a navigation document (%s) with %d entries from the spine", navName, len(points)))
+
+	return opf, &generatedNav{path: path.Join(opfDir, navName), content: navDocument(points)}, fixes
+}
+
+type navPoint struct {
+	href  string
+	title string
+}
+
+// documentTitle finds a readable title for a content document: its first heading, its
+// <title>, or the file name as a last resort
+func documentTitle(content []byte, href string) string {
+	for _, pattern := range []*regexp.Regexp{headingTag, titleElement} {
+		if match := pattern.FindSubmatch(content); match != nil {
+			title := strings.Join(strings.Fields(html.UnescapeString(markupTag.ReplaceAllString(string(match[1]), ""))), " ")
+			if title != "" {
+				return title
+			}
+		}
+	}
+	name := path.Base(href)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func navDocument(points []navPoint) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+  <head>
+    <title>Contents</title>
+  </head>
+  <body>
+    <nav epub:type="toc" id="toc">
+      <h1>Contents</h1>
+      <ol>
+`)
+	for _, point := range points {
+		fmt.Fprintf(&b, "        <li><a href=\"%s\">%s</a></li>\n", html.EscapeString(point.href), html.EscapeString(point.title))
+	}
+	b.WriteString(`      </ol>
+    </nav>
+  </body>
+</html>
+`)
+	return []byte(b.String())
+}
+
+// ContainerXML returns a META-INF/container.xml pointing to the package document at opfPath
+func ContainerXML(opfPath string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="%s" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`, html.EscapeString(opfPath)))
+}
+
+// findPackageDocument returns the first .opf file in the archive
+func findPackageDocument(src *zip.Reader) string {
+	for _, file := range src.File {
+		if strings.EqualFold(path.Ext(file.Name), ".opf") {
+			return file.Name
+		}
+	}
+	return ""
+}
+
+// writeRepaired writes the archive with the repaired package, container and nav
+func writeRepaired(src *zip.Reader, dst io.Writer, opfPath, opf string, container []byte, nav *generatedNav) error {
+	w, err := epubzip.NewWriter(dst, epubzip.Options{StoreCompressedMedia: true})
+	if err != nil {
+		return err
+	}
+	if err := w.WriteMimetype([]byte(epubMimetype)); err != nil {
+		return err
+	}
+
+	replaced := map[string][]byte{opfPath: []byte(opf)}
+	if container != nil {
+		replaced["META-INF/container.xml"] = container
+	}
+	if nav != nil {
+		replaced[nav.path] = nav.content
+	}
+
+	for _, file := range src.File {
+		if file.Name == "mimetype" || file.FileInfo().IsDir() {
+			continue
+		}
+		if _, ok := replaced[file.Name]; ok {
+			continue
+		}
+		if err := w.Copy(file); err != nil {
+			return fmt.Errorf("failed to copy entry %s: %w", file.Name, err)
+		}
+	}
+
+	// Changed and new files go last, container first among them as readers expect
+	names := []string{"META-INF/container.xml", opfPath}
+	if nav != nil {
+		names = append(names, nav.path)
+	}
+	for _, name := range names {
+		content, ok := replaced[name]
+		if !ok {
+			continue
+		}
+		writer, err := w.Create(&zip.FileHeader{Name: name, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("failed to create entry %s: %w", name, err)
+		}
+		if _, err := writer.Write(content); err != nil {
+			return fmt.Errorf("failed to write entry %s: %w", name, err)
+		}
+	}
+
+	return w.Close()
+}
+
+func readZipEntry(file *zip.File) ([]byte, error) {
+	if file == nil {
+		return nil, fmt.Errorf("entry not found")
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
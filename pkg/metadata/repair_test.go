@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files [][2]string, method uint16) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file[0], Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+const brokenOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata/>
+  <manifest>
+    <item id="c1" href="text/one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="text/gone.xhtml" media-type="application/xhtml+xml"/>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+  </manifest>
+  <spine>
+    <itemref idref="c1"/>
+    <itemref idref="c2"/>
+  </spine>
+</package>
+`
+
+func TestRepairEPUB(t *testing.T) {
+	src := buildZip(t, [][2]string{
+		{"OEBPS/content.opf", brokenOPF},
+		{"OEBPS/text/one.xhtml", "<html><body><h1>Chapter <em>One</em></h1></body></html>"},
+		{"mimetype", "application/epub+zip"},
+	}, zip.Deflate)
+
+	var out bytes.Buffer
+	fixes, err := RepairEPUB(src, &out)
+	if err != nil {
+		t.Fatalf("RepairEPUB failed: %v", err)
+	}
+	if len(fixes) != 5 {
+		t.Errorf("Expected 5 fixes (mimetype, container, two missing files, nav), got %d: %v", len(fixes), fixes)
+	}
+
+	repaired, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("Repaired EPUB isn't a valid zip: %v", err)
+	}
+	if first := repaired.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("mimetype should be the first, stored entry, got %s (method %d)", first.Name, first.Method)
+	}
+
+	read := func(name string) string {
+		for _, file := range repaired.File {
+			if file.Name == name {
+				content, err := readZipEntry(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(content)
+			}
+		}
+		t.Fatalf("%s missing from repaired EPUB", name)
+		return ""
+	}
+
+	if container := read("META-INF/container.xml"); !strings.Contains(container, `full-path="OEBPS/content.opf"`) {
+		t.Errorf("container.xml doesn't point to the package document:\n%s", container)
+	}
+	opf := read("OEBPS/content.opf")
+	if strings.Contains(opf, "gone.xhtml") || strings.Contains(opf, `idref="c2"`) {
+		t.Errorf("Missing file still referenced:\n%s", opf)
+	}
+	if !strings.Contains(opf, `properties="nav"`) {
+		t.Errorf("No nav item in manifest:\n%s", opf)
+	}
+	if nav := read("OEBPS/nav.xhtml"); !strings.Contains(nav, `<a href="text/one.xhtml">Chapter One</a>`) {
+		t.Errorf("Nav doesn't list the chapter:\n%s", nav)
+	}
+
+	// A repaired book needs no further repairs
+	again, err := RepairEPUB(repaired, io.Discard)
+	if err != nil {
+		t.Fatalf("Second RepairEPUB failed: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Repaired book still has problems: %v", again)
+	}
+}
@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sidecar is a metadata record kept next to a book, for editing by hand or applying
+// to other files. Identifiers and dates are left out since they belong to one file only.
+type Sidecar struct {
+	Title        string        `json:"title,omitempty"`
+	Authors      []Contributor `json:"authors,omitempty"`
+	Contributors []Contributor `json:"contributors,omitempty"`
+	Description  string        `json:"description,omitempty"`
+	Language     string        `json:"language,omitempty"`
+	Publisher    string        `json:"publisher,omitempty"`
+	Subjects     []string      `json:"subjects,omitempty"`
+	Series       string        `json:"series,omitempty"`
+	SeriesIndex  float64       `json:"seriesIndex,omitempty"`
+	Rights       string        `json:"rights,omitempty"`
+	Cover        string        `json:"cover,omitempty"` // Image path, relative to the sidecar file
+}
+
+// NewSidecar builds a sidecar record from a book's metadata
+func NewSidecar(meta EPUBMetadata) Sidecar {
+	return Sidecar{
+		Title:        meta.Title,
+		Authors:      meta.Creators,
+		Contributors: meta.Contributors,
+		Description:  meta.Description,
+		Language:     meta.Language,
+		Publisher:    meta.Publisher,
+		Subjects:     meta.Subjects,
+		Series:       meta.Series,
+		SeriesIndex:  meta.SeriesIndex,
+		Rights:       meta.Rights,
+	}
+}
+
+// WriteSidecar saves a sidecar as JSON or YAML, depending on the file extension
+func WriteSidecar(path string, sidecar Sidecar) error {
+	var data []byte
+	switch sidecarFormat(path) {
+	case "json":
+		encoded, err := json.MarshalIndent(sidecar, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode sidecar: %w", err)
+		}
+		data = append(encoded, '\n')
+	case "yaml":
+		data = marshalSidecarYAML(sidecar)
+	default:
+		return fmt.Errorf("unsupported sidecar format: %s (use .yaml, .yml or .json)", filepath.Ext(path))
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	return nil
+}
+
+// ReadSidecar loads a sidecar written by WriteSidecar (or by hand in the same shape).
+// A relative cover path is resolved against the sidecar's directory.
+func ReadSidecar(path string) (Sidecar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Sidecar{}, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+
+	var sidecar Sidecar
+	switch sidecarFormat(path) {
+	case "json":
+		if err := json.Unmarshal(data, &sidecar); err != nil {
+			return Sidecar{}, fmt.Errorf("failed to parse sidecar: %w", err)
+		}
+	case "yaml":
+		sidecar, err = unmarshalSidecarYAML(data)
+		if err != nil {
+			return Sidecar{}, fmt.Errorf("failed to parse sidecar: %w", err)
+		}
+	default:
+		return Sidecar{}, fmt.Errorf("unsupported sidecar format: %s (use .yaml, .yml or .json)", filepath.Ext(path))
+	}
+
+	if sidecar.Cover != "" && !filepath.IsAbs(sidecar.Cover) {
+		sidecar.Cover = filepath.Join(filepath.Dir(path), sidecar.Cover)
+	}
+
+	return sidecar, nil
+}
+
+func sidecarFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
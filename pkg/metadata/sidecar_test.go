@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSidecarRoundTrip(t *testing.T) {
+	sidecar := Sidecar{
+		Title:        "Guards! Guards!",
+		Authors:      []Contributor{{Name: "Terry Pratchett", Role: "aut"}},
+		Contributors: []Contributor{{Name: "Jane Doe", Role: "ill"}},
+		Description:  "The watch: \"reluctant\" heroes.\nSecond line # not a comment",
+		Language:     "en",
+		Subjects:     []string{"Fantasy", "1989", "yes"},
+		Series:       "Discworld",
+		SeriesIndex:  8,
+		Rights:       "© 1989",
+	}
+
+	for _, name := range []string{"meta.yaml", "meta.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := WriteSidecar(path, sidecar); err != nil {
+				t.Fatalf("WriteSidecar failed: %v", err)
+			}
+
+			got, err := ReadSidecar(path)
+			if err != nil {
+				t.Fatalf("ReadSidecar failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, sidecar) {
+				t.Errorf("Round trip changed the sidecar:\ngot  %+v\nwant %+v", got, sidecar)
+			}
+		})
+	}
+}
+
+func TestReadHandWrittenYAML(t *testing.T) {
+	yaml := `# Template for the series
+title: 'It''s a Title'
+authors:
+  - Terry Pratchett
+  - "Neil Gaiman:aut"
+contributors:
+  - name: Jane Doe   # the illustrator
+    role: ill
+subjects: [Fantasy, Humour]
+seriesIndex: 2.5
+cover: covers/front.jpg
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "meta.yml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sidecar, err := ReadSidecar(path)
+	if err != nil {
+		t.Fatalf("ReadSidecar failed: %v", err)
+	}
+
+	want := Sidecar{
+		Title:        "It's a Title",
+		Authors:      []Contributor{{Name: "Terry Pratchett", Role: "aut"}, {Name: "Neil Gaiman", Role: "aut"}},
+		Contributors: []Contributor{{Name: "Jane Doe", Role: "ill"}},
+		Subjects:     []string{"Fantasy", "Humour"},
+		SeriesIndex:  2.5,
+		Cover:        filepath.Join(dir, "covers/front.jpg"),
+	}
+	if !reflect.DeepEqual(sidecar, want) {
+		t.Errorf("got  %+v\nwant %+v", sidecar, want)
+	}
+}
+
+func TestReadSidecarRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.yaml")
+	if err := os.WriteFile(path, []byte("titel: Typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSidecar(path); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/alde/publify/internal/miniyaml"
+)
+
+// The sidecar is top-level scalars, lists of scalars and lists of name/role pairs, all
+// of which miniyaml handles.
+
+func marshalSidecarYAML(sidecar Sidecar) []byte {
+	var buf bytes.Buffer
+
+	scalar := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\n", key, miniyaml.Scalar(value))
+		}
+	}
+	people := func(key string, list []Contributor) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "%s:\n", key)
+		for _, person := range list {
+			fmt.Fprintf(&buf, "  - name: %s\n", miniyaml.Scalar(person.Name))
+			if person.Role != "" {
+				fmt.Fprintf(&buf, "    role: %s\n", miniyaml.Scalar(person.Role))
+			}
+		}
+	}
+
+	scalar("title", sidecar.Title)
+	people("authors", sidecar.Authors)
+	people("contributors", sidecar.Contributors)
+	scalar("description", sidecar.Description)
+	scalar("language", sidecar.Language)
+	scalar("publisher", sidecar.Publisher)
+	if len(sidecar.Subjects) > 0 {
+		buf.WriteString("subjects:\n")
+		for _, subject := range sidecar.Subjects {
+			fmt.Fprintf(&buf, "  - %s\n", miniyaml.Scalar(subject))
+		}
+	}
+	scalar("series", sidecar.Series)
+	if sidecar.SeriesIndex > 0 {
+		fmt.Fprintf(&buf, "seriesIndex: %s\n", strconv.FormatFloat(sidecar.SeriesIndex, 'f', -1, 64))
+	}
+	scalar("rights", sidecar.Rights)
+	scalar("cover", sidecar.Cover)
+
+	return buf.Bytes()
+}
+
+func unmarshalSidecarYAML(data []byte) (Sidecar, error) {
+	entries, err := miniyaml.Parse(data)
+	if err != nil {
+		return Sidecar{}, err
+	}
+
+	var sidecar Sidecar
+	for key, entry := range entries {
+		switch key {
+		case "title":
+			sidecar.Title = entry.Scalar
+		case "description":
+			sidecar.Description = entry.Scalar
+		case "language":
+			sidecar.Language = entry.Scalar
+		case "publisher":
+			sidecar.Publisher = entry.Scalar
+		case "series":
+			sidecar.Series = entry.Scalar
+		case "rights":
+			sidecar.Rights = entry.Scalar
+		case "cover":
+			sidecar.Cover = entry.Scalar
+		case "seriesIndex":
+			if entry.Scalar == "" {
+				continue
+			}
+			index, err := strconv.ParseFloat(entry.Scalar, 64)
+			if err != nil {
+				return Sidecar{}, fmt.Errorf("line %d: invalid seriesIndex %q", entry.Line, entry.Scalar)
+			}
+			sidecar.SeriesIndex = index
+		case "subjects":
+			for _, item := range entry.Items {
+				sidecar.Subjects = append(sidecar.Subjects, item.Scalar)
+			}
+		case "authors", "contributors":
+			defaultRole := "aut"
+			if key == "contributors" {
+				defaultRole = "ctb"
+			}
+			for _, item := range entry.Items {
+				person, err := yamlPerson(item, defaultRole)
+				if err != nil {
+					return Sidecar{}, fmt.Errorf("line %d: %w", entry.Line, err)
+				}
+				if key == "authors" {
+					sidecar.Authors = append(sidecar.Authors, person)
+				} else {
+					sidecar.Contributors = append(sidecar.Contributors, person)
+				}
+			}
+		default:
+			return Sidecar{}, fmt.Errorf("line %d: unknown field %q", entry.Line, key)
+		}
+	}
+
+	return sidecar, nil
+}
+
+// yamlPerson reads a list item as a person: either {name, role} or "Name:role"
+func yamlPerson(item miniyaml.Item, defaultRole string) (Contributor, error) {
+	if item.Fields == nil {
+		return ParseContributor(item.Scalar, defaultRole)
+	}
+
+	person := Contributor{Name: item.Fields["name"], Role: item.Fields["role"]}
+	if person.Name == "" {
+		return Contributor{}, fmt.Errorf("person without a name")
+	}
+	if person.Role == "" {
+		person.Role = defaultRole
+	}
+	return person, nil
+}
@@ -0,0 +1,268 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const ncxMediaType = "application/x-dtbncx+xml"
+
+// tocEntry is an entry of a navigation document or NCX as written, linking to src
+// relative to the file it's in
+type tocEntry struct {
+	title    string
+	src      string
+	children []tocEntry
+}
+
+// GetTableOfContents returns the book's table of contents, nested as the book has it, from
+// its navigation document (EPUB 3) or its NCX (EPUB 2). Entries point to their document by
+// Path, as chapters do, with the place in it they link to in Fragment.
+func (r *EPUBReader) GetTableOfContents() ([]Chapter, error) {
+	opfPath, err := r.findOPFFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OPF file: %w", err)
+	}
+	opfContent, err := r.readFileFromZip(opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OPF file: %w", err)
+	}
+	opf, err := parseOPF(opfContent)
+	if err != nil {
+		return nil, err
+	}
+
+	opfDir := path.Dir(opfPath)
+	items := make(map[string]*xmlNode) // By path in the archive
+	for _, item := range opf.manifestItems() {
+		items[resolveHref(opfDir, item.attr("href"))] = item
+	}
+	nav, ncx := opf.tocItems()
+
+	var source *xmlNode
+	var parse func([]byte) ([]tocEntry, error)
+	switch {
+	case nav != nil:
+		source, parse = nav, parseNavDocument
+	case ncx != nil:
+		source, parse = ncx, parseNCX
+	default:
+		return nil, fmt.Errorf("the book has no navigation document or NCX")
+	}
+
+	sourcePath := resolveHref(opfDir, source.attr("href"))
+	content, err := r.readFileFromZip(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table of contents: %w", err)
+	}
+	entries, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse table of contents %s: %w", sourcePath, err)
+	}
+
+	var convert func(entries []tocEntry, depth int) []Chapter
+	convert = func(entries []tocEntry, depth int) []Chapter {
+		var chapters []Chapter
+		for _, entry := range entries {
+			chapter := Chapter{Title: entry.title, Depth: depth}
+			if entry.src != "" { // Headings that group entries link nowhere
+				src, fragment, _ := strings.Cut(entry.src, "#")
+				target := sourcePath // A bare #fragment links within the file itself
+				if src != "" {
+					target = resolveHref(path.Dir(sourcePath), src)
+				}
+				chapter.Fragment = fragment
+				if item := items[target]; item != nil {
+					chapter.ID, chapter.Path = item.attr("id"), item.attr("href")
+				} else {
+					chapter.Path = relativePath(opfDir, target)
+				}
+			}
+			chapter.Children = convert(entry.children, depth+1)
+			chapters = append(chapters, chapter)
+		}
+		return chapters
+	}
+
+	return convert(entries, 0), nil
+}
+
+// tocItems returns the manifest items of the navigation document and the NCX, if the book
+// has them. The NCX is the one the spine names, or else the one with the NCX media type.
+func (d *opfDocument) tocItems() (nav, ncx *xmlNode) {
+	toc := ""
+	if spine := d.pkg.child("spine"); spine != nil {
+		toc = spine.attr("toc")
+	}
+	for _, item := range d.manifestItems() {
+		switch {
+		case hasProperty(item, "nav"):
+			if nav == nil {
+				nav = item
+			}
+		case item.attr("id") != "" && item.attr("id") == toc:
+			ncx = item
+		case item.attr("media-type") == ncxMediaType && ncx == nil:
+			ncx = item
+		}
+	}
+	return nav, ncx
+}
+
+// parseNavDocument reads the entries of a navigation document's <nav epub:type="toc">
+func parseNavDocument(content []byte) ([]tocEntry, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	toc := tocNav(root)
+	if toc == nil {
+		return nil, fmt.Errorf("no <nav> element")
+	}
+
+	var list func(ol *xmlNode) []tocEntry
+	list = func(ol *xmlNode) []tocEntry {
+		if ol == nil {
+			return nil
+		}
+		var entries []tocEntry
+		for _, li := range ol.elements("li") {
+			label := li.child("a")
+			if label == nil {
+				label = li.child("span") // A heading for the entries under it, with no link
+			}
+			if label == nil {
+				continue
+			}
+			entries = append(entries, tocEntry{
+				title:    strings.Join(strings.Fields(label.textContent()), " "),
+				src:      label.attr("href"),
+				children: list(li.child("ol")),
+			})
+		}
+		return entries
+	}
+
+	return list(toc.child("ol")), nil
+}
+
+// tocNav finds a navigation document's table of contents: the <nav epub:type="toc">, or
+// the first <nav> if none is typed. The landmarks and page list are navs too.
+func tocNav(root *xmlNode) *xmlNode {
+	var navs []*xmlNode
+	var find func(n *xmlNode)
+	find = func(n *xmlNode) {
+		for _, child := range n.children {
+			if child.isElement() && child.name.Local == "nav" {
+				navs = append(navs, child)
+			}
+			find(child)
+		}
+	}
+	find(root)
+
+	for _, nav := range navs {
+		if isTOCNav(nav) {
+			return nav
+		}
+	}
+	if len(navs) > 0 {
+		return navs[0]
+	}
+	return nil
+}
+
+// isTOCNav reports whether a <nav> is typed as the table of contents, as epub:type="toc"
+func isTOCNav(nav *xmlNode) bool {
+	for _, attr := range nav.attrs {
+		if attr.Name.Local == "type" && attr.Name.Space != "" && strings.Contains(" "+attr.Value+" ", " toc ") {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the text in a node and everything in it
+func (n *xmlNode) textContent() string {
+	if !n.isElement() {
+		return n.text
+	}
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(child.textContent())
+	}
+	return b.String()
+}
+
+// ncxPoint is a navPoint of an NCX
+type ncxPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxPoint `xml:"navPoint"`
+}
+
+// parseNCX reads the entries of an NCX's navMap
+func parseNCX(content []byte) ([]tocEntry, error) {
+	var ncx struct {
+		Points []ncxPoint `xml:"navMap>navPoint"`
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&ncx); err != nil {
+		return nil, err
+	}
+
+	var convert func(points []ncxPoint) []tocEntry
+	convert = func(points []ncxPoint) []tocEntry {
+		var entries []tocEntry
+		for _, point := range points {
+			entries = append(entries, tocEntry{
+				title:    strings.Join(strings.Fields(point.Label), " "),
+				src:      point.Content.Src,
+				children: convert(point.Points),
+			})
+		}
+		return entries
+	}
+
+	return convert(ncx.Points), nil
+}
+
+// spineTitles titles the chapters of the spine from the table of contents: each takes the
+// first entry linking to its document, and the depth of that entry. Chapters the table of
+// contents doesn't list keep their titles.
+func spineTitles(chapters []Chapter, toc []Chapter) {
+	first := make(map[string]Chapter)
+	var index func(entries []Chapter)
+	index = func(entries []Chapter) {
+		for _, entry := range entries {
+			key := unescapeHref(entry.Path)
+			if _, ok := first[key]; !ok && entry.Title != "" {
+				first[key] = entry
+			}
+			index(entry.Children)
+		}
+	}
+	index(toc)
+
+	for i := range chapters {
+		if entry, ok := first[unescapeHref(chapters[i].Path)]; ok {
+			chapters[i].Title = entry.Title
+			chapters[i].Depth = entry.Depth
+		}
+	}
+}
+
+func unescapeHref(href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		return unescaped
+	}
+	return href
+}
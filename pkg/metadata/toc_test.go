@@ -0,0 +1,423 @@
+package metadata
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openTestEPUB writes a book with a package document at OEBPS/content.opf and opens it
+func openTestEPUB(t *testing.T, files [][2]string) *EPUBReader {
+	t.Helper()
+
+	reader, err := NewEPUBReader(writeTestEPUB(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	return reader
+}
+
+// writeTestEPUB writes a book with a package document at OEBPS/content.opf
+func writeTestEPUB(t *testing.T, files [][2]string) string {
+	t.Helper()
+
+	epubPath := filepath.Join(t.TempDir(), "book.epub")
+	out, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	files = append([][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", string(ContainerXML("OEBPS/content.opf"))},
+	}, files...)
+	for _, file := range files {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	return epubPath
+}
+
+func TestNavTableOfContents(t *testing.T) {
+	reader := openTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Voyages</dc:title></metadata>
+  <manifest>
+    <item id="nav" href="text/nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="title" href="text/title.xhtml" media-type="application/xhtml+xml"/>
+    <item id="one" href="text/part%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="two" href="text/two.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="title"/>
+    <itemref idref="one"/>
+    <itemref idref="two"/>
+  </spine>
+</package>`},
+		{"OEBPS/text/nav.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+  <body>
+    <nav epub:type="landmarks"><ol><li><a href="two.xhtml">Start</a></li></ol></nav>
+    <nav epub:type="toc">
+      <ol>
+        <li><span>Part&nbsp;One</span>
+          <ol>
+            <li><a href="part%201.xhtml">The <em>First</em>
+              Voyage</a></li>
+            <li><a href="part%201.xhtml#storm">The Storm</a></li>
+          </ol>
+        </li>
+        <li><a href="two.xhtml">The Second Voyage</a></li>
+      </ol>
+    </nav>
+  </body>
+</html>`},
+		{"OEBPS/text/title.xhtml", "<html><body><h1>Voyages</h1></body></html>"},
+		{"OEBPS/text/part 1.xhtml", "<html><body><h2>I</h2></body></html>"},
+		{"OEBPS/text/two.xhtml", "<html><body><h2>II</h2></body></html>"},
+	})
+
+	toc, err := reader.GetTableOfContents()
+	if err != nil {
+		t.Fatalf("GetTableOfContents failed: %v", err)
+	}
+	if len(toc) != 2 || toc[0].Title != "Part One" || toc[0].Path != "" || len(toc[0].Children) != 2 {
+		t.Fatalf("Expected the toc nav with Part One holding two entries, got %+v", toc)
+	}
+	first, storm := toc[0].Children[0], toc[0].Children[1]
+	if first.Title != "The First Voyage" || first.ID != "one" || first.Path != "text/part%201.xhtml" || first.Depth != 1 {
+		t.Errorf("Unexpected first entry %+v", first)
+	}
+	if storm.Fragment != "storm" || storm.Path != "text/part%201.xhtml" {
+		t.Errorf("Expected the storm entry to link into part 1, got %+v", storm)
+	}
+	if toc[1].Title != "The Second Voyage" || toc[1].ID != "two" || toc[1].Depth != 0 {
+		t.Errorf("Unexpected second entry %+v", toc[1])
+	}
+
+	chapters, err := reader.GetChapterList()
+	if err != nil {
+		t.Fatalf("GetChapterList failed: %v", err)
+	}
+	want := []struct {
+		title string
+		depth int
+	}{{"Voyages", 0}, {"The First Voyage", 1}, {"The Second Voyage", 0}}
+	if len(chapters) != len(want) {
+		t.Fatalf("Expected %d chapters, got %+v", len(want), chapters)
+	}
+	for i, chapter := range chapters {
+		if chapter.Title != want[i].title || chapter.Depth != want[i].depth || chapter.Children != nil {
+			t.Errorf("Chapter %d: got %q at depth %d, want %q at depth %d", i+1, chapter.Title, chapter.Depth, want[i].title, want[i].depth)
+		}
+	}
+}
+
+func TestNCXTableOfContents(t *testing.T) {
+	reader := openTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Voyages</dc:title></metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="one" href="one.html" media-type="application/xhtml+xml"/>
+    <item id="two" href="two.html" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="one"/>
+    <itemref idref="two"/>
+  </spine>
+</package>`},
+		{"OEBPS/toc.ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <navMap>
+    <navPoint id="p1" playOrder="1">
+      <navLabel><text>Book One</text></navLabel>
+      <content src="one.html"/>
+      <navPoint id="p2" playOrder="2">
+        <navLabel><text>Chapter the Second</text></navLabel>
+        <content src="two.html#start"/>
+      </navPoint>
+    </navPoint>
+  </navMap>
+</ncx>`},
+		{"OEBPS/one.html", "<html><body><p>One</p></body></html>"},
+		{"OEBPS/two.html", "<html><body><p>Two</p></body></html>"},
+	})
+
+	toc, err := reader.GetTableOfContents()
+	if err != nil {
+		t.Fatalf("GetTableOfContents failed: %v", err)
+	}
+	if len(toc) != 1 || toc[0].Title != "Book One" || toc[0].Path != "one.html" || len(toc[0].Children) != 1 {
+		t.Fatalf("Expected Book One holding one entry, got %+v", toc)
+	}
+	if second := toc[0].Children[0]; second.Title != "Chapter the Second" || second.ID != "two" || second.Fragment != "start" || second.Depth != 1 {
+		t.Errorf("Unexpected nested entry %+v", second)
+	}
+
+	chapters, err := reader.GetChapterList()
+	if err != nil {
+		t.Fatalf("GetChapterList failed: %v", err)
+	}
+	if len(chapters) != 2 || chapters[0].Title != "Book One" || chapters[1].Title != "Chapter the Second" || chapters[1].Depth != 1 {
+		t.Errorf("Expected the spine titled from the NCX, got %+v", chapters)
+	}
+}
+
+func TestChapterListWithoutTableOfContents(t *testing.T) {
+	reader := openTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata/>
+  <manifest>
+    <item id="one" href="one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="gone" href="gone.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="one"/><itemref idref="gone"/></spine>
+</package>`},
+		{"OEBPS/one.xhtml", "<html><head><title>Prologue</title></head><body/></html>"},
+	})
+
+	if _, err := reader.GetTableOfContents(); err == nil {
+		t.Error("Expected an error for a book with no navigation document or NCX")
+	}
+	chapters, err := reader.GetChapterList()
+	if err != nil {
+		t.Fatalf("GetChapterList failed: %v", err)
+	}
+	if len(chapters) != 2 || chapters[0].Title != "Prologue" || chapters[1].Title != "Chapter 2" {
+		t.Errorf("Expected titles from the documents, got %+v", chapters)
+	}
+}
+
+func TestSetTOCRewritesNavAndNCX(t *testing.T) {
+	epubPath := writeTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Voyages</dc:title></metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="one" href="text/part%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="two" href="text/two.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="one"/>
+    <itemref idref="two"/>
+  </spine>
+</package>`},
+		{"OEBPS/nav.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+  <body>
+    <nav epub:type="toc">
+      <h1>Contents</h1>
+      <ol>
+        <li><a href="text/part%201.xhtml">Chapter 1</a></li>
+        <li><a href="text/two.xhtml">Chapter 2</a></li>
+      </ol>
+    </nav>
+    <nav epub:type="landmarks"><ol><li><a epub:type="bodymatter" href="text/two.xhtml">Start</a></li></ol></nav>
+  </body>
+</html>`},
+		{"OEBPS/toc.ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:depth" content="1"/>
+  </head>
+  <navMap>
+    <navPoint id="a" playOrder="1"><navLabel><text>Chapter 1</text></navLabel><content src="text/part%201.xhtml"/></navPoint>
+  </navMap>
+</ncx>`},
+		{"OEBPS/text/part 1.xhtml", "<html><body><h2>I</h2></body></html>"},
+		{"OEBPS/text/two.xhtml", "<html><body><h2>II</h2></body></html>"},
+	})
+
+	editor, err := NewEPUBEditor(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	toc := []Chapter{{Title: "Part One", Children: []Chapter{
+		{Title: "The First Voyage", Path: "text/part%201.xhtml"},
+		{Title: "The Storm", Path: "text/two.xhtml", Fragment: "storm"},
+	}}}
+	if err := editor.SetTOC(toc); err != nil {
+		t.Fatalf("SetTOC failed: %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reader := openSavedEPUB(t, epubPath)
+	got, err := reader.GetTableOfContents()
+	if err != nil {
+		t.Fatalf("GetTableOfContents failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Title != "Part One" || got[0].Path != "" || len(got[0].Children) != 2 ||
+		got[0].Children[1].Title != "The Storm" || got[0].Children[1].Fragment != "storm" {
+		t.Fatalf("Expected the new table of contents back, got %+v", got)
+	}
+
+	nav, err := reader.readFileFromZip("OEBPS/nav.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"      <h1>Contents</h1>\n      <ol>\n        <li><span>Part One</span>\n          <ol>\n",
+		`            <li><a href="text/part%201.xhtml">The First Voyage</a></li>`,
+		`<a epub:type="bodymatter" href="text/two.xhtml">Start</a>`,
+	} {
+		if !strings.Contains(string(nav), want) {
+			t.Errorf("Expected the nav document to contain %q, got:\n%s", want, nav)
+		}
+	}
+
+	ncx, err := reader.readFileFromZip("OEBPS/toc.ncx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parseNCX(ncx)
+	if err != nil {
+		t.Fatalf("The rewritten NCX doesn't parse: %v", err)
+	}
+	// The NCX has no headings, so Part One links where its first entry does
+	if len(entries) != 1 || entries[0].src != "text/part%201.xhtml" || len(entries[0].children) != 2 || entries[0].children[1].src != "text/two.xhtml#storm" {
+		t.Errorf("Unexpected NCX entries %+v", entries)
+	}
+	if !strings.Contains(string(ncx), `<meta name="dtb:depth" content="2"/>`) || !strings.Contains(string(ncx), `<navPoint id="navPoint-2" playOrder="1">`) {
+		t.Errorf("Expected the depth updated and entries linking to the same place in the same play order, got:\n%s", ncx)
+	}
+}
+
+func TestSetTOCAddsNCX(t *testing.T) {
+	epubPath := writeTestEPUB(t, [][2]string{
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Voyages &amp; Other Tales</dc:title>
+    <dc:identifier id="uid">urn:isbn:9780000000000</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="one" href="one.html" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="one"/>
+  </spine>
+</package>`},
+		{"OEBPS/one.html", "<html><body><p>One</p></body></html>"},
+	})
+
+	editor, err := NewEPUBEditor(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	if err := editor.SetTOC([]Chapter{{Title: "Gone", Path: "gone.html"}}); err != nil {
+		t.Fatalf("SetTOC failed: %v", err)
+	}
+	if err := editor.Save(); err == nil {
+		t.Error("Expected an entry linking to a missing document to be refused")
+	}
+	if err := editor.SetTOC([]Chapter{{Title: "Heading without entries"}}); err == nil {
+		t.Error("Expected a heading with nothing under it to be refused")
+	}
+	if err := editor.SetTOC([]Chapter{{Title: "The One", Path: "one.html"}}); err != nil {
+		t.Fatalf("SetTOC failed: %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reader := openSavedEPUB(t, epubPath)
+	opf, err := reader.readFileFromZip("OEBPS/content.opf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<spine toc="ncx">`, `<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>`} {
+		if !strings.Contains(string(opf), want) {
+			t.Errorf("Expected the package document to contain %s, got:\n%s", want, opf)
+		}
+	}
+	ncx, err := reader.readFileFromZip("OEBPS/toc.ncx")
+	if err != nil {
+		t.Fatalf("Expected an NCX added: %v", err)
+	}
+	if !strings.Contains(string(ncx), `content="urn:isbn:9780000000000"`) || !strings.Contains(string(ncx), "<text>Voyages &amp; Other Tales</text>") {
+		t.Errorf("Expected the NCX identified and titled as the book, got:\n%s", ncx)
+	}
+	chapters, err := reader.GetChapterList()
+	if err != nil || len(chapters) != 1 || chapters[0].Title != "The One" {
+		t.Errorf("Expected the chapter titled from the new NCX, got %+v (%v)", chapters, err)
+	}
+}
+
+// openSavedEPUB opens a book an editor saved
+func openSavedEPUB(t *testing.T, epubPath string) *EPUBReader {
+	t.Helper()
+	reader, err := NewEPUBReader(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	return reader
+}
+
+func TestTOCFileRoundTrip(t *testing.T) {
+	toc := []Chapter{
+		{Title: "Part One: Outward", Children: []Chapter{
+			{Title: "The First Voyage", Path: "text/part%201.xhtml"},
+			{Title: "The Storm", Path: "text/part%201.xhtml", Fragment: "storm", Children: []Chapter{
+				{Title: "#1 Aftermath", Path: "text/two.xhtml"},
+			}},
+		}},
+		{Title: "Epilogue", Path: "text/end.xhtml"},
+	}
+
+	for _, name := range []string{"toc.yaml", "toc.json"} {
+		tocPath := filepath.Join(t.TempDir(), name)
+		if err := WriteTOCFile(tocPath, toc); err != nil {
+			t.Fatalf("WriteTOCFile(%s) failed: %v", name, err)
+		}
+		got, err := ReadTOCFile(tocPath)
+		if err != nil {
+			t.Fatalf("ReadTOCFile(%s) failed: %v", name, err)
+		}
+		if want := flattenTOC(toc); !reflect.DeepEqual(flattenTOC(got), want) {
+			t.Errorf("%s: expected %+v back, got %+v", name, want, flattenTOC(got))
+		}
+	}
+
+	// Hand-written, with a depth that skips a level
+	tocPath := filepath.Join(t.TempDir(), "toc.yml")
+	os.WriteFile(tocPath, []byte(`toc:
+  - title: One
+    href: one.xhtml
+  - title: Deep
+    href: "one.xhtml#deep"
+    depth: 3
+  - title: Two
+    href: two.xhtml
+`), 0644)
+	got, err := ReadTOCFile(tocPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(got[0].Children) != 1 || got[0].Children[0].Depth != 1 || got[0].Children[0].Fragment != "deep" {
+		t.Errorf("Expected Deep nested one level under One, got %+v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package metadata
+
+import "fmt"
+
+// TOCEdit holds a table of contents while the user edits it. Entries are kept flat in
+// reading order and numbered from 1, as shown to the user; each is nested under the
+// nearest entry above it with a smaller depth, so moving an entry takes the entries
+// nested under it along.
+type TOCEdit struct {
+	entries []Chapter
+}
+
+// NewTOCEdit starts editing a table of contents
+func NewTOCEdit(toc []Chapter) *TOCEdit {
+	return &TOCEdit{entries: flattenTOC(toc)}
+}
+
+// Entries lists the entries as they currently stand, with their depths
+func (t *TOCEdit) Entries() []Chapter {
+	return append([]Chapter(nil), t.entries...)
+}
+
+// TOC returns the table of contents as edited, nested
+func (t *TOCEdit) TOC() []Chapter {
+	return nestTOC(t.entries)
+}
+
+// Rename sets the title of entry n
+func (t *TOCEdit) Rename(n int, title string) error {
+	if err := t.checkEntry(n); err != nil {
+		return err
+	}
+	if title == "" {
+		return fmt.Errorf("entry %d needs a title", n)
+	}
+	t.entries[n-1].Title = title
+	return nil
+}
+
+// Move puts entry n and the entries under it before entry to, at that entry's depth. A to
+// one past the last entry moves it to the end, at the top level.
+func (t *TOCEdit) Move(n, to int) error {
+	if err := t.checkEntry(n); err != nil {
+		return err
+	}
+	if to < 1 || to > len(t.entries)+1 {
+		return fmt.Errorf("no entry %d, the table of contents has %d", to, len(t.entries))
+	}
+	start, end := n-1, t.subtreeEnd(n-1)
+	if to-1 > start && to-1 < end {
+		return fmt.Errorf("entry %d can't move into itself", n)
+	}
+
+	depth := 0
+	if to <= len(t.entries) {
+		depth = t.entries[to-1].Depth
+	}
+	block := append([]Chapter(nil), t.entries[start:end]...)
+	shift := depth - block[0].Depth
+	for i := range block {
+		block[i].Depth += shift
+	}
+
+	rest := append(append([]Chapter(nil), t.entries[:start]...), t.entries[end:]...)
+	at := to - 1
+	if at > start {
+		at -= end - start
+	}
+	t.entries = append(rest[:at:at], append(block, rest[at:]...)...)
+	return nil
+}
+
+// Indent nests entry n, and the entries under it, under the entry above it
+func (t *TOCEdit) Indent(n int) error {
+	if err := t.checkEntry(n); err != nil {
+		return err
+	}
+	if n == 1 || t.entries[n-2].Depth < t.entries[n-1].Depth {
+		return fmt.Errorf("entry %d has no entry above it to go under", n)
+	}
+	t.shift(n-1, 1)
+	return nil
+}
+
+// Outdent moves entry n, and the entries under it, one level up
+func (t *TOCEdit) Outdent(n int) error {
+	if err := t.checkEntry(n); err != nil {
+		return err
+	}
+	if t.entries[n-1].Depth == 0 {
+		return fmt.Errorf("entry %d is already at the top level", n)
+	}
+	t.shift(n-1, -1)
+	return nil
+}
+
+// Delete removes entry n. The entries under it move up a level.
+func (t *TOCEdit) Delete(n int) error {
+	if err := t.checkEntry(n); err != nil {
+		return err
+	}
+	i := n - 1
+	end := t.subtreeEnd(i)
+	for j := i + 1; j < end; j++ {
+		t.entries[j].Depth--
+	}
+	t.entries = append(t.entries[:i], t.entries[i+1:]...)
+	return nil
+}
+
+// Add puts a new entry after entry n and the entries under it, at its depth (0 = at the
+// start). href is relative to the package document, as chapter paths are.
+func (t *TOCEdit) Add(n int, title, href string) error {
+	if n != 0 {
+		if err := t.checkEntry(n); err != nil {
+			return err
+		}
+	}
+	if title == "" {
+		return fmt.Errorf("a new entry needs a title")
+	}
+
+	entry := Chapter{Title: title}
+	entry.Path, entry.Fragment = cutFragment(href)
+	at := 0
+	if n > 0 {
+		entry.Depth = t.entries[n-1].Depth
+		at = t.subtreeEnd(n - 1)
+	}
+	t.entries = append(t.entries[:at:at], append([]Chapter{entry}, t.entries[at:]...)...)
+	return nil
+}
+
+// subtreeEnd returns the index after the last entry nested under entry i
+func (t *TOCEdit) subtreeEnd(i int) int {
+	end := i + 1
+	for end < len(t.entries) && t.entries[end].Depth > t.entries[i].Depth {
+		end++
+	}
+	return end
+}
+
+// shift changes the depth of entry i and the entries under it
+func (t *TOCEdit) shift(i, by int) {
+	end := t.subtreeEnd(i)
+	for j := i; j < end; j++ {
+		t.entries[j].Depth += by
+	}
+}
+
+func (t *TOCEdit) checkEntry(n int) error {
+	if n < 1 || n > len(t.entries) {
+		return fmt.Errorf("no entry %d, the table of contents has %d", n, len(t.entries))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+// outline shows the entries as "title/depth", to compare in one go
+func outline(edit *TOCEdit) string {
+	var parts []string
+	for _, entry := range edit.Entries() {
+		parts = append(parts, entry.Title+"/"+string(rune('0'+entry.Depth)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTOCEdit(t *testing.T) {
+	edit := NewTOCEdit([]Chapter{
+		{Title: "A", Path: "a.xhtml", Children: []Chapter{{Title: "A1", Path: "a.xhtml", Fragment: "1"}}},
+		{Title: "B", Path: "b.xhtml"},
+		{Title: "C", Path: "c.xhtml"},
+	})
+	if got := outline(edit); got != "A/0 A1/1 B/0 C/0" {
+		t.Fatalf("Unexpected entries %s", got)
+	}
+
+	steps := []struct {
+		name  string
+		apply func() error
+		want  string
+	}{
+		{"indent C under B", func() error { return edit.Indent(4) }, "A/0 A1/1 B/0 C/1"},
+		{"move B and C to the start", func() error { return edit.Move(3, 1) }, "B/0 C/1 A/0 A1/1"},
+		{"move A to the end", func() error { return edit.Move(3, 5) }, "B/0 C/1 A/0 A1/1"},
+		{"move A1 before C", func() error { return edit.Move(4, 2) }, "B/0 A1/1 C/1 A/0"},
+		{"outdent A1", func() error { return edit.Outdent(2) }, "B/0 A1/0 C/1 A/0"},
+		{"delete A1", func() error { return edit.Delete(2) }, "B/0 C/0 A/0"},
+		{"rename C", func() error { return edit.Rename(2, "Sea") }, "B/0 Sea/0 A/0"},
+		{"add after B", func() error { return edit.Add(1, "New", "new.xhtml#top") }, "B/0 New/0 Sea/0 A/0"},
+		{"add at the start", func() error { return edit.Add(0, "First", "first.xhtml") }, "First/0 B/0 New/0 Sea/0 A/0"},
+	}
+	for _, step := range steps {
+		if err := step.apply(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := outline(edit); got != step.want {
+			t.Fatalf("%s: got %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	if added := edit.Entries()[2]; added.Path != "new.xhtml" || added.Fragment != "top" {
+		t.Errorf("Expected the href split into path and fragment, got %+v", added)
+	}
+	if toc := edit.TOC(); len(toc) != 5 {
+		t.Errorf("Expected five top-level entries, got %+v", toc)
+	}
+
+	for name, apply := range map[string]func() error{
+		"indent the first entry":    func() error { return edit.Indent(1) },
+		"outdent a top-level entry": func() error { return edit.Outdent(2) },
+		"rename to nothing":         func() error { return edit.Rename(2, "") },
+		"move a missing entry":      func() error { return edit.Move(9, 1) },
+		"delete entry 0":            func() error { return edit.Delete(0) },
+	} {
+		if err := apply(); err == nil {
+			t.Errorf("Expected an error trying to %s", name)
+		}
+	}
+
+	nested := NewTOCEdit([]Chapter{{Title: "A", Path: "a.xhtml", Children: []Chapter{{Title: "A1", Path: "a.xhtml"}}}})
+	if err := nested.Move(1, 2); err == nil {
+		t.Error("Expected moving an entry into itself to be refused")
+	}
+}
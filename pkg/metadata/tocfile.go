@@ -0,0 +1,167 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alde/publify/internal/miniyaml"
+)
+
+// WriteTOCFile saves a table of contents for editing by hand, as JSON (nested, the way
+// metadata --json shows it) or YAML (one entry after another, with depth giving the
+// nesting), depending on the file extension
+func WriteTOCFile(path string, toc []Chapter) error {
+	var data []byte
+	switch sidecarFormat(path) {
+	case "json":
+		encoded, err := json.MarshalIndent(toc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode table of contents: %w", err)
+		}
+		data = append(encoded, '\n')
+	case "yaml":
+		data = marshalTOCYAML(toc)
+	default:
+		return fmt.Errorf("unsupported table of contents format: %s (use .yaml, .yml or .json)", filepath.Ext(path))
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write table of contents: %w", err)
+	}
+	return nil
+}
+
+// ReadTOCFile loads a table of contents written by WriteTOCFile (or by hand in the same shape)
+func ReadTOCFile(path string) ([]Chapter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table of contents: %w", err)
+	}
+
+	var toc []Chapter
+	switch sidecarFormat(path) {
+	case "json":
+		if err := json.Unmarshal(data, &toc); err != nil {
+			return nil, fmt.Errorf("failed to parse table of contents: %w", err)
+		}
+		toc = nestTOC(flattenTOC(toc)) // Depths as the nesting has them, whatever the file says
+	case "yaml":
+		toc, err = unmarshalTOCYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse table of contents: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported table of contents format: %s (use .yaml, .yml or .json)", filepath.Ext(path))
+	}
+
+	if len(toc) == 0 {
+		return nil, fmt.Errorf("%s has no table of contents entries", path)
+	}
+	return toc, nil
+}
+
+// The YAML file is a flat list, since miniyaml doesn't nest. An entry's depth puts it
+// under the nearest entry above it that's one level up.
+
+func marshalTOCYAML(toc []Chapter) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# One entry per line of the table of contents, in reading order. href is relative\n")
+	buf.WriteString("# to the package document; depth nests an entry under the one above (0 = top level).\n")
+	buf.WriteString("toc:\n")
+	for _, entry := range flattenTOC(toc) {
+		fmt.Fprintf(&buf, "  - title: %s\n", miniyaml.Scalar(entry.Title))
+		if href := entry.href(); href != "" {
+			fmt.Fprintf(&buf, "    href: %s\n", miniyaml.Scalar(href))
+		}
+		if entry.Depth > 0 {
+			fmt.Fprintf(&buf, "    depth: %d\n", entry.Depth)
+		}
+	}
+	return buf.Bytes()
+}
+
+func unmarshalTOCYAML(data []byte) ([]Chapter, error) {
+	entries, err := miniyaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var flat []Chapter
+	for key, entry := range entries {
+		if key != "toc" {
+			return nil, fmt.Errorf("line %d: unknown field %q", entry.Line, key)
+		}
+		for _, item := range entry.Items {
+			if item.Fields == nil {
+				return nil, fmt.Errorf("line %d: expected entries with a title and href", entry.Line)
+			}
+			chapter := Chapter{Title: item.Fields["title"]}
+			chapter.Path, chapter.Fragment = cutFragment(item.Fields["href"])
+			if depth := item.Fields["depth"]; depth != "" {
+				chapter.Depth, err = strconv.Atoi(depth)
+				if err != nil || chapter.Depth < 0 {
+					return nil, fmt.Errorf("line %d: invalid depth %q for %q", entry.Line, depth, chapter.Title)
+				}
+			}
+			flat = append(flat, chapter)
+		}
+	}
+
+	return nestTOC(flat), nil
+}
+
+// href returns where an entry links to, relative to the package document ("" = nowhere)
+func (c Chapter) href() string {
+	if c.Fragment != "" {
+		return c.Path + "#" + c.Fragment
+	}
+	return c.Path
+}
+
+// cutFragment splits an href into the path and the #fragment after it
+func cutFragment(href string) (string, string) {
+	path, fragment, _ := strings.Cut(href, "#")
+	return path, fragment
+}
+
+// flattenTOC lists a table of contents in reading order, each entry with its depth and
+// without its children
+func flattenTOC(toc []Chapter) []Chapter {
+	var flat []Chapter
+	var walk func(entries []Chapter, depth int)
+	walk = func(entries []Chapter, depth int) {
+		for _, entry := range entries {
+			children := entry.Children
+			entry.Depth, entry.Children = depth, nil
+			flat = append(flat, entry)
+			walk(children, depth+1)
+		}
+	}
+	walk(toc, 0)
+	return flat
+}
+
+// nestTOC builds the tree of a flat table of contents, putting each entry under the
+// nearest entry above it with a smaller depth. Depths come out counted from 0 without
+// gaps, so an entry two levels deeper than the one above it ends up one level deeper.
+func nestTOC(flat []Chapter) []Chapter {
+	var nest func(entries []Chapter, depth, parentDepth int) ([]Chapter, []Chapter)
+	nest = func(entries []Chapter, depth, parentDepth int) ([]Chapter, []Chapter) {
+		var level []Chapter
+		for len(entries) > 0 && entries[0].Depth > parentDepth {
+			entry, written := entries[0], entries[0].Depth
+			entry.Depth = depth
+			entry.Children, entries = nest(entries[1:], depth+1, written)
+			level = append(level, entry)
+		}
+		return level, entries
+	}
+
+	toc, _ := nest(flat, 0, -1)
+	return toc
+}
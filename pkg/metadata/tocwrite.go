@@ -0,0 +1,343 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SetTOC replaces the book's table of contents. The navigation document and the NCX are
+// both rewritten, whichever the book has; one is created if it has neither. Entries link
+// by Path and Fragment, relative to the package document as chapter paths are. An entry
+// that links nowhere is a heading for the entries under it, so it needs some.
+func (e *EPUBEditor) SetTOC(toc []Chapter) error {
+	if len(toc) == 0 {
+		return fmt.Errorf("the table of contents has no entries")
+	}
+	for _, entry := range flattenTOC(toc) {
+		if strings.TrimSpace(entry.Title) == "" {
+			return fmt.Errorf("a table of contents entry linking to %s has no title", entry.href())
+		}
+	}
+	var check func(entries []Chapter) error
+	check = func(entries []Chapter) error {
+		for _, entry := range entries {
+			if entry.Path == "" && len(entry.Children) == 0 {
+				return fmt.Errorf("table of contents entry %q links nowhere and has no entries under it", entry.Title)
+			}
+			if err := check(entry.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(toc); err != nil {
+		return err
+	}
+
+	e.newTOC = toc
+	e.modified = true
+	return nil
+}
+
+// writeTOC puts the new table of contents in the navigation document and the NCX
+func (e *EPUBEditor) writeTOC(opf *opfDocument, opfPath string, archive *coverArchive) error {
+	opfDir := path.Dir(opfPath)
+	for _, entry := range flattenTOC(e.newTOC) {
+		if entry.Path != "" && !archive.has(resolveHref(opfDir, entry.Path)) {
+			return fmt.Errorf("%q links to %s, which isn't in the book", entry.Title, entry.Path)
+		}
+	}
+
+	nav, ncx := opf.tocItems()
+	if nav == nil && ncx == nil {
+		var err error
+		if nav, ncx, err = addTOCDocument(opf, opfDir, archive); err != nil {
+			return err
+		}
+	}
+
+	// Links are written relative to the document they're in
+	linker := func(documentPath string) func(Chapter) string {
+		return func(entry Chapter) string {
+			if entry.Path == "" {
+				return ""
+			}
+			relative := relativePath(path.Dir(documentPath), resolveHref(opfDir, entry.Path))
+			href := (&url.URL{Path: relative}).String()
+			if entry.Fragment != "" {
+				href += "#" + entry.Fragment
+			}
+			return href
+		}
+	}
+
+	for _, document := range []struct {
+		item    *xmlNode
+		rewrite func([]byte, []Chapter, func(Chapter) string) ([]byte, error)
+	}{{nav, navWithTOC}, {ncx, ncxWithTOC}} {
+		if document.item == nil {
+			continue
+		}
+		documentPath := resolveHref(opfDir, document.item.attr("href"))
+		content, err := archive.read(documentPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", documentPath, err)
+		}
+		updated, err := document.rewrite(content, e.newTOC, linker(documentPath))
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", documentPath, err)
+		}
+		archive.changes[documentPath] = updated
+	}
+
+	return nil
+}
+
+// addTOCDocument adds the table of contents document the book's version calls for: a
+// navigation document for EPUB 3, an NCX for EPUB 2. Its entries are filled in afterwards.
+func addTOCDocument(opf *opfDocument, opfDir string, archive *coverArchive) (nav, ncx *xmlNode, err error) {
+	manifest := opf.pkg.child("manifest")
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("no <manifest> element in the OPF")
+	}
+	item := &xmlNode{name: xml.Name{Space: manifest.name.Space, Local: "item"}}
+
+	if strings.HasPrefix(opf.version(), "3") {
+		item.setAttr("id", uniqueID(opf, "nav"))
+		item.setAttr("href", archive.uniqueHref(opfDir, "nav.xhtml"))
+		item.setAttr("media-type", "application/xhtml+xml")
+		item.setAttr("properties", "nav")
+		archive.changes[resolveHref(opfDir, item.attr("href"))] = navDocument(nil)
+		manifest.appendIndented(item)
+		return item, nil, nil
+	}
+
+	spine := opf.pkg.child("spine")
+	if spine == nil {
+		return nil, nil, fmt.Errorf("no <spine> element in the OPF")
+	}
+	item.setAttr("id", uniqueID(opf, "ncx"))
+	item.setAttr("href", archive.uniqueHref(opfDir, "toc.ncx"))
+	item.setAttr("media-type", ncxMediaType)
+	archive.changes[resolveHref(opfDir, item.attr("href"))] = ncxDocument(opf)
+	manifest.appendIndented(item)
+	spine.setAttr("toc", item.attr("id"))
+	return nil, item, nil
+}
+
+// ncxDocument returns an NCX with an empty navMap, identified and titled as the book is
+func ncxDocument(opf *opfDocument) []byte {
+	uid, title := "", ""
+	for _, identifier := range opf.dcElements("identifier") {
+		if identifier.attr("id") == opf.pkg.attr("unique-identifier") {
+			uid = strings.TrimSpace(identifier.textContent())
+		}
+	}
+	if titles := opf.dcElements("title"); len(titles) > 0 {
+		title = strings.TrimSpace(titles[0].textContent())
+	}
+
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="%s"/>
+    <meta name="dtb:depth" content="1"/>
+    <meta name="dtb:totalPageCount" content="0"/>
+    <meta name="dtb:maxPageNumber" content="0"/>
+  </head>
+  <docTitle>
+    <text>%s</text>
+  </docTitle>
+  <navMap>
+  </navMap>
+</ncx>
+`, html.EscapeString(uid), html.EscapeString(title)))
+}
+
+// navWithTOC replaces the list in a navigation document's table of contents, leaving the
+// rest of the document (its heading, the landmarks) as it was
+func navWithTOC(content []byte, toc []Chapter, link func(Chapter) string) ([]byte, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	nav := tocNav(root)
+	if nav == nil {
+		return nil, fmt.Errorf("no <nav> element")
+	}
+
+	old := nav.child("ol")
+	if old == nil {
+		nav.appendIndented(navList(nav.name.Space, toc, "\n"+lineIndent(nav)+"  ", link))
+		return documentBytes(root), nil
+	}
+	for i, child := range nav.children {
+		if child != old {
+			continue
+		}
+		list := navList(nav.name.Space, toc, "\n"+lineIndent(old), link)
+		list.parent = nav
+		nav.children[i] = list
+	}
+
+	return documentBytes(root), nil
+}
+
+// navList builds the <ol> of a navigation document's table of contents, each line starting
+// with indent
+func navList(space string, entries []Chapter, indent string, link func(Chapter) string) *xmlNode {
+	element := func(local string) *xmlNode {
+		return &xmlNode{name: xml.Name{Space: space, Local: local}}
+	}
+
+	list := element("ol")
+	for _, entry := range entries {
+		item := element("li")
+		label := element("span")
+		if href := link(entry); href != "" {
+			label = element("a")
+			label.setAttr("href", href)
+		}
+		label.setText(entry.Title)
+		item.appendChildren(label)
+		if len(entry.Children) > 0 {
+			item.appendChildren(
+				&xmlNode{text: indent + "    "},
+				navList(space, entry.Children, indent+"    ", link),
+				&xmlNode{text: indent + "  "})
+		}
+		list.appendChildren(&xmlNode{text: indent + "  "}, item)
+	}
+	list.appendChildren(&xmlNode{text: indent})
+	return list
+}
+
+// ncxWithTOC replaces the navPoints of an NCX's navMap, numbering them in reading order,
+// and sets dtb:depth to match
+func ncxWithTOC(content []byte, toc []Chapter, link func(Chapter) string) ([]byte, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return nil, err
+	}
+	var ncx, navMap *xmlNode
+	for _, child := range root.children {
+		if child.isElement() && child.name.Local == "ncx" {
+			ncx = child
+			navMap = child.child("navMap")
+		}
+	}
+	if navMap == nil {
+		return nil, fmt.Errorf("no <navMap> element")
+	}
+
+	indent := "\n" + lineIndent(navMap) + "  "
+	for _, point := range navMap.elements("navPoint") {
+		navMap.removeChild(point)
+	}
+
+	// Entries linking to the same place share their place in the play order
+	count, order := 0, make(map[string]int)
+	var points func(entries []Chapter, indent string) []*xmlNode
+	points = func(entries []Chapter, indent string) []*xmlNode {
+		element := func(local string) *xmlNode {
+			return &xmlNode{name: xml.Name{Space: navMap.name.Space, Local: local}}
+		}
+		var nodes []*xmlNode
+		for _, entry := range entries {
+			src := firstLink(entry, link)
+			if src == "" {
+				continue
+			}
+			count++
+			if order[src] == 0 {
+				order[src] = len(order) + 1
+			}
+			point := element("navPoint")
+			point.setAttr("id", fmt.Sprintf("navPoint-%d", count))
+			point.setAttr("playOrder", strconv.Itoa(order[src]))
+
+			title := element("text")
+			title.setText(entry.Title)
+			label := element("navLabel")
+			label.appendChildren(&xmlNode{text: indent + "    "}, title, &xmlNode{text: indent + "  "})
+			target := element("content")
+			target.setAttr("src", src)
+
+			point.appendChildren(&xmlNode{text: indent + "  "}, label, &xmlNode{text: indent + "  "}, target)
+			for _, child := range points(entry.Children, indent+"  ") {
+				point.appendChildren(&xmlNode{text: indent + "  "}, child)
+			}
+			point.appendChildren(&xmlNode{text: indent})
+			nodes = append(nodes, point)
+		}
+		return nodes
+	}
+	for _, point := range points(toc, indent) {
+		navMap.appendIndented(point)
+	}
+
+	depth := 0
+	for _, entry := range flattenTOC(toc) {
+		depth = max(depth, entry.Depth+1)
+	}
+	if head := ncx.child("head"); head != nil {
+		for _, meta := range head.elements("meta") {
+			if meta.attr("name") == "dtb:depth" {
+				meta.setAttr("content", strconv.Itoa(depth))
+			}
+		}
+	}
+
+	return documentBytes(root), nil
+}
+
+// firstLink returns where an entry links to, or for a heading that links nowhere, where
+// the first entry under it does. NCX entries all have to link somewhere.
+func firstLink(entry Chapter, link func(Chapter) string) string {
+	if href := link(entry); href != "" {
+		return href
+	}
+	for _, child := range entry.Children {
+		if href := firstLink(child, link); href != "" {
+			return href
+		}
+	}
+	return ""
+}
+
+// lineIndent returns the whitespace an element's line starts with
+func lineIndent(element *xmlNode) string {
+	parent := element.parent
+	if parent == nil {
+		return ""
+	}
+	for i, child := range parent.children {
+		if child == element && i > 0 && parent.children[i-1].isSpace() {
+			space := parent.children[i-1].text
+			return space[strings.LastIndex(space, "\n")+1:]
+		}
+	}
+	return ""
+}
+
+// appendChildren appends nodes to the element as they are, without indenting them
+func (n *xmlNode) appendChildren(children ...*xmlNode) {
+	for _, child := range children {
+		child.parent = n
+		n.children = append(n.children, child)
+	}
+}
+
+// documentBytes writes a parsed document back out
+func documentBytes(root *xmlNode) []byte {
+	var b strings.Builder
+	for _, child := range root.children {
+		child.write(&b)
+	}
+	return []byte(b.String())
+}
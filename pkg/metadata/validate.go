@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/alde/publify/internal/epubzip"
+)
+
+// Problem is something in a book's structure that stops readers opening it, or that
+// EPUB checkers refuse
+type Problem struct {
+	Path    string // File the problem is in
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ValidateStructure checks the structure of a book, unpacked in a folder (os.DirFS) or
+// still zipped (a *zip.Reader): that container.xml points to a package document, and
+// what ValidatePackage checks from there. The mimetype entry isn't checked, since it's
+// written whenever a book is packed.
+//
+// ignore leaves files out as if they weren't there, for files that won't be packed
+// (nil = none are).
+func ValidateStructure(fsys fs.FS, ignore func(name string) bool) ([]Problem, error) {
+	container, err := fs.ReadFile(fsys, "META-INF/container.xml")
+	if err != nil {
+		return []Problem{{Path: "META-INF/container.xml", Message: "missing, so readers can't find the package document"}}, nil
+	}
+	match := rootfilePath.FindSubmatch(container)
+	if match == nil {
+		return []Problem{{Path: "META-INF/container.xml", Message: "has no rootfile pointing to the package document"}}, nil
+	}
+	return ValidatePackage(fsys, string(match[1]), ignore)
+}
+
+// ValidatePackage checks the package document at opfPath against the files in fsys:
+// manifest items whose files are missing, files the manifest doesn't list, duplicate
+// manifest ids, spine entries that aren't in the manifest, an empty spine and a missing
+// EPUB 3 navigation document. Problems come back in file order.
+func ValidatePackage(fsys fs.FS, opfPath string, ignore func(name string) bool) ([]Problem, error) {
+	files := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || epubzip.IsJunk(name) || (ignore != nil && ignore(name)) {
+			return nil
+		}
+		files[name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the book's files: %w", err)
+	}
+
+	if !files[opfPath] {
+		return []Problem{{Path: "META-INF/container.xml", Message: fmt.Sprintf("points to %s, which isn't there", opfPath)}}, nil
+	}
+	content, err := fs.ReadFile(fsys, opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package document: %w", err)
+	}
+	opf := string(content)
+	opfDir := path.Dir(opfPath)
+
+	var problems []Problem
+	report := func(name, format string, args ...any) {
+		problems = append(problems, Problem{Path: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Manifest: every item's file is there, and listed once
+	ids := make(map[string]bool)
+	listed := map[string]bool{"mimetype": true, opfPath: true}
+	hasNav := false
+	for _, item := range manifestItem.FindAllString(opf, -1) {
+		id, href := xmlAttr(item, "id"), xmlAttr(item, "href")
+		if ids[id] {
+			report(opfPath, "manifest id %q is used more than once", id)
+		}
+		ids[id] = true
+		if strings.Contains(" "+xmlAttr(item, "properties")+" ", " nav ") {
+			hasNav = true
+		}
+		if strings.Contains(href, "://") {
+			continue // Remote resources aren't in the book
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		name := path.Join(opfDir, href)
+		listed[name] = true
+		if !files[name] {
+			report(opfPath, "manifest item %q points to %s, which isn't there", id, name)
+		}
+	}
+
+	// Spine: the reading order only uses manifest items
+	itemrefs := spineItemref.FindAllString(opf, -1)
+	if len(itemrefs) == 0 {
+		report(opfPath, "the spine is empty, so there's nothing to read")
+	}
+	for _, itemref := range itemrefs {
+		if idref := xmlAttr(itemref, "idref"); !ids[idref] {
+			report(opfPath, "spine entry %q isn't in the manifest", idref)
+		}
+	}
+
+	// EPUB 3 requires a navigation document; EPUB 2 books use the NCX instead
+	version := ""
+	if tag := packageTag.FindString(opf); tag != "" {
+		if match := versionAttr.FindStringSubmatch(tag); match != nil {
+			version = match[1]
+		}
+	}
+	if strings.HasPrefix(version, "3") && !hasNav {
+		report(opfPath, "no navigation document (an item with properties=\"nav\"), which EPUB 3 requires")
+	}
+
+	// Files the manifest doesn't list
+	var unlisted []string
+	for name := range files {
+		if !listed[name] && !strings.HasPrefix(name, "META-INF/") {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+	for _, name := range unlisted {
+		report(name, "not listed in the manifest")
+	}
+
+	return problems, nil
+}
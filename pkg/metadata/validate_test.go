@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateStructure(t *testing.T) {
+	book := fstest.MapFS{
+		"mimetype":               {Data: []byte(epubMimetype)},
+		"META-INF/container.xml": {Data: ContainerXML("OEBPS/content.opf")},
+		"OEBPS/content.opf":      {Data: []byte(brokenOPF)},
+		"OEBPS/text/one.xhtml":   {Data: []byte("<html/>")},
+		"OEBPS/nav.xhtml":        {Data: []byte("<html/>")},
+		"OEBPS/notes.txt":        {Data: []byte("to do")},
+		"OEBPS/.DS_Store":        {Data: []byte{0}},
+	}
+
+	problems, err := ValidateStructure(book, nil)
+	if err != nil {
+		t.Fatalf("ValidateStructure failed: %v", err)
+	}
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.String())
+	}
+	want := []string{
+		`OEBPS/content.opf: manifest item "c2" points to OEBPS/text/gone.xhtml, which isn't there`,
+		"OEBPS/notes.txt: not listed in the manifest",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(got, "\n"))
+	}
+
+	// A file that won't be packed is as good as missing
+	problems, err = ValidateStructure(book, func(name string) bool { return name == "OEBPS/nav.xhtml" || name == "OEBPS/notes.txt" })
+	if err != nil {
+		t.Fatalf("ValidateStructure failed: %v", err)
+	}
+	if len(problems) != 2 || !strings.Contains(problems[1].Message, "OEBPS/nav.xhtml") {
+		t.Errorf("Expected the ignored nav reported missing, got %v", problems)
+	}
+
+	delete(book, "META-INF/container.xml")
+	problems, _ = ValidateStructure(book, nil)
+	if len(problems) != 1 || problems[0].Path != "META-INF/container.xml" {
+		t.Errorf("Expected only the missing container.xml reported, got %v", problems)
+	}
+}
+
+func TestValidatePackageSpine(t *testing.T) {
+	opf := `<package version="3.0">
+  <manifest>
+    <item id="c1" href="one.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="two.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="c3"/>
+  </spine>
+</package>`
+	book := fstest.MapFS{
+		"content.opf": {Data: []byte(opf)},
+		"one.xhtml":   {Data: []byte("<html/>")},
+		"two.xhtml":   {Data: []byte("<html/>")},
+	}
+
+	problems, err := ValidatePackage(book, "content.opf", nil)
+	if err != nil {
+		t.Fatalf("ValidatePackage failed: %v", err)
+	}
+	if len(problems) != 3 {
+		t.Fatalf("Expected the duplicate id, the unknown spine entry and the missing nav, got %v", problems)
+	}
+	for i, want := range []string{"more than once", "isn't in the manifest", "navigation document"} {
+		if !strings.Contains(problems[i].Message, want) {
+			t.Errorf("Problem %d = %q, want it to mention %q", i, problems[i].Message, want)
+		}
+	}
+}
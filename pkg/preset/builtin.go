@@ -0,0 +1,40 @@
+package preset
+
+import (
+	"fmt"
+	"sort"
+)
+
+// builtin are the presets publify comes with. An installed preset of the same name is
+// used instead.
+var builtin = map[string]string{
+	"manga": `name: manga
+description: Manga and comics, read right to left with every page laid out as drawn
+direction: rtl
+fixed-layout: true
+no-auto-ocr: true
+`,
+}
+
+// Builtin returns a preset publify comes with, and its file
+func Builtin(name string) (*Installed, []byte, error) {
+	data, ok := builtin[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: %w", name, ErrNotInstalled)
+	}
+	p, err := Parse([]byte(data), name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("built-in preset %s: %w", name, err)
+	}
+	return &Installed{Preset: p, Builtin: true}, []byte(data), nil
+}
+
+// BuiltinNames lists the presets publify comes with, sorted
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package preset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxSize is far more than any preset needs, so a wrong URL can't fill the disk
+const maxSize = 64 << 10
+
+// errMissing is returned by download for a file that isn't there
+var errMissing = errors.New("not found")
+
+// Checksum is the hex SHA-256 of a preset file
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Fetch downloads a preset over HTTPS and checks it against its SHA-256 checksum, given
+// in hex. Without one, the checksum is read from the URL with .sha256 appended, as
+// sha256sum writes it; a preset with neither isn't installed.
+func Fetch(ctx context.Context, client *http.Client, url, checksum string) ([]byte, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("presets are only downloaded over https, not from %s", url)
+	}
+
+	if checksum == "" {
+		published, err := download(ctx, client, url+".sha256")
+		if errors.Is(err, errMissing) {
+			return nil, fmt.Errorf("no checksum for %s: give it with --sha256, or ask its author to publish %s.sha256", url, url)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to download checksum: %w", err)
+		}
+		checksum, _, _ = strings.Cut(strings.TrimSpace(string(published)), " ")
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid SHA-256 checksum %q", checksum)
+	}
+
+	data, err := download(ctx, client, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download preset: %w", err)
+	}
+	if sum := Checksum(data); sum != checksum {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, checksum, sum)
+	}
+	return data, nil
+}
+
+// download fetches a small file
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", url, errMissing)
+	case response.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", url, response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s is larger than a preset could be", url)
+	}
+	return data, nil
+}
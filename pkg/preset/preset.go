@@ -0,0 +1,87 @@
+// Package preset reads conversion presets, named sets of publify convert settings tuned
+// for a scanner or a publisher's PDFs, and keeps the ones installed. Communities share
+// them as YAML files of convert flags and their values:
+//
+//	name: scanned-novel
+//	description: Mass-market paperbacks scanned at 300 dpi on a flatbed
+//	ocr-lang: eng
+//	ocr-preprocess: deskew,binarize
+//	bleed-threshold: -3.5
+//	no-figures: true
+//
+// A list sets a repeatable flag more than once. Which flags a preset may set is up to
+// the command applying it.
+package preset
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/alde/publify/internal/miniyaml"
+)
+
+// Preset is a named set of convert settings
+type Preset struct {
+	Name        string
+	Description string
+	Settings    map[string][]string // Flag name to its values
+}
+
+// namePattern keeps names usable as file names and on the command line
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// ValidName reports whether a preset can be called name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Parse reads a preset. One that doesn't give its name is called fallbackName.
+func Parse(data []byte, fallbackName string) (*Preset, error) {
+	entries, err := miniyaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Preset{Name: fallbackName, Settings: make(map[string][]string)}
+	for key, entry := range entries {
+		switch key {
+		case "name":
+			p.Name = entry.Scalar
+		case "description":
+			p.Description = entry.Scalar
+		default:
+			if !namePattern.MatchString(key) {
+				return nil, fmt.Errorf("line %d: %q isn't a flag name", entry.Line, key)
+			}
+			if len(entry.Items) == 0 {
+				p.Settings[key] = []string{entry.Scalar}
+				continue
+			}
+			for _, item := range entry.Items {
+				if item.Fields != nil {
+					return nil, fmt.Errorf("line %d: %s takes plain values", entry.Line, key)
+				}
+				p.Settings[key] = append(p.Settings[key], item.Scalar)
+			}
+		}
+	}
+
+	if !ValidName(p.Name) {
+		return nil, fmt.Errorf("invalid preset name %q (lowercase letters, digits, dots, dashes and underscores)", p.Name)
+	}
+	if len(p.Settings) == 0 {
+		return nil, fmt.Errorf("preset %s has no settings", p.Name)
+	}
+	return p, nil
+}
+
+// Flags returns the names of the flags the preset sets, sorted
+func (p *Preset) Flags() []string {
+	flags := make([]string, 0, len(p.Settings))
+	for flag := range p.Settings {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,170 @@
+package preset
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alde/publify/pkg/storage"
+)
+
+const scannedNovel = `# Tuned for mass-market paperbacks
+name: scanned-novel
+description: "Paperbacks scanned at 300 dpi: deskewed, no figures"
+ocr-lang: eng+fra
+bleed-threshold: -3.5
+no-figures: true
+embed-font:
+  - Literata-Regular.ttf
+  - Literata-Bold.ttf
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(scannedNovel), "fallback")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if p.Name != "scanned-novel" || p.Description != "Paperbacks scanned at 300 dpi: deskewed, no figures" {
+		t.Errorf("Unexpected name and description: %q, %q", p.Name, p.Description)
+	}
+	expected := []string{"bleed-threshold", "embed-font", "no-figures", "ocr-lang"}
+	if flags := p.Flags(); strings.Join(flags, " ") != strings.Join(expected, " ") {
+		t.Errorf("Flags() = %v, expected %v", flags, expected)
+	}
+	if fonts := p.Settings["embed-font"]; len(fonts) != 2 || fonts[1] != "Literata-Bold.ttf" {
+		t.Errorf("Expected a list for a repeated flag, got %v", fonts)
+	}
+	if value := p.Settings["bleed-threshold"]; len(value) != 1 || value[0] != "-3.5" {
+		t.Errorf("Expected bleed-threshold -3.5, got %v", value)
+	}
+
+	unnamed, err := Parse([]byte("ocr-lang: swe\n"), "swedish-scans")
+	if err != nil || unnamed.Name != "swedish-scans" {
+		t.Errorf("Expected a preset without a name to take the fallback, got %v, %v", unnamed, err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for name, data := range map[string]string{
+		"bad name":    "name: ../../etc\nocr-lang: eng\n",
+		"no settings": "name: empty\ndescription: Nothing\n",
+		"bad flag":    "name: odd\n\"--ocr lang\": eng\n",
+		"map value":   "name: odd\nembed-font:\n  - file: a.ttf\n",
+	} {
+		if _, err := Parse([]byte(data), "fallback"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		p, _, err := Builtin(name)
+		if err != nil || p.Name != name || !p.Builtin {
+			t.Errorf("Built-in preset %s: %+v, %v", name, p, err)
+		}
+	}
+	if manga, _, err := Builtin("manga"); err != nil || manga.Settings["direction"][0] != "rtl" {
+		t.Errorf("Expected the manga preset to read right to left, got %+v, %v", manga, err)
+	}
+	if _, _, err := Builtin("nonesuch"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled for an unknown built-in preset, got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	registry := NewRegistry(storage.NewDir(dir))
+
+	p, err := Parse([]byte(scannedNovel), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := registry.Install(p, []byte(scannedNovel), "https://example.com/scanned-novel.yaml"); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	installed, data, err := registry.Get("scanned-novel")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != scannedNovel || installed.Source != "https://example.com/scanned-novel.yaml" ||
+		installed.SHA256 != Checksum([]byte(scannedNovel)) || installed.Edited {
+		t.Errorf("Unexpected installed preset: %+v", installed)
+	}
+
+	// Edited by hand, and one copied in without a record
+	edited := strings.Replace(scannedNovel, "-3.5", "-3.2", 1)
+	if err := os.WriteFile(filepath.Join(dir, "scanned-novel.yaml"), []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "copied.yaml"), []byte("name: other\nocr-lang: swe\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := registry.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "copied" || list[1].Name != "scanned-novel" {
+		t.Fatalf("Expected copied and scanned-novel, got %v", list)
+	}
+	if list[0].Source != "" || !list[1].Edited {
+		t.Errorf("Expected the copied preset without a source and the other edited, got %+v, %+v", list[0], list[1])
+	}
+
+	if err := registry.Remove("scanned-novel"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, _, err := registry.Get("scanned-novel"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled after removing, got %v", err)
+	}
+	if err := registry.Remove("scanned-novel"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled removing twice, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scanned-novel.json")); !os.IsNotExist(err) {
+		t.Error("Expected the record removed with the preset")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	sum := Checksum([]byte(scannedNovel))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scanned-novel.yaml", "/unsigned.yaml":
+			w.Write([]byte(scannedNovel))
+		case "/scanned-novel.yaml.sha256":
+			w.Write([]byte(sum + "  scanned-novel.yaml\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	data, err := Fetch(ctx, server.Client(), server.URL+"/scanned-novel.yaml", "")
+	if err != nil || string(data) != scannedNovel {
+		t.Errorf("Expected the preset checked against its published checksum, got %v", err)
+	}
+	if _, err := Fetch(ctx, server.Client(), server.URL+"/unsigned.yaml", strings.ToUpper(sum)); err != nil {
+		t.Errorf("Expected a checksum given by hand to be used, got %v", err)
+	}
+
+	for name, test := range map[string]struct{ url, checksum, message string }{
+		"no checksum": {server.URL + "/unsigned.yaml", "", "no checksum"},
+		"mismatch":    {server.URL + "/scanned-novel.yaml", strings.Repeat("0", 64), "checksum mismatch"},
+		"bad sum":     {server.URL + "/scanned-novel.yaml", "abc", "invalid SHA-256"},
+		"missing":     {server.URL + "/gone.yaml", sum, "not found"},
+		"plain http":  {"http://example.com/scanned-novel.yaml", sum, "only downloaded over https"},
+	} {
+		_, err := Fetch(ctx, server.Client(), test.url, test.checksum)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an error about %q, got %v", name, test.message, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package preset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alde/publify/pkg/storage"
+)
+
+// ErrNotInstalled is returned for a preset the registry doesn't have
+var ErrNotInstalled = errors.New("no such preset installed")
+
+// Installed is a preset in the registry, and where it came from
+type Installed struct {
+	*Preset
+	Source      string // URL or file it was installed from ("" = copied in by hand)
+	SHA256      string
+	InstalledAt time.Time
+	Edited      bool // Changed by hand since it was installed
+	Builtin     bool // Comes with publify rather than installed
+}
+
+// record is what's kept about an installed preset next to its file
+type record struct {
+	Source      string    `json:"source"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed"`
+}
+
+// Registry keeps installed presets in a store: each as name.yaml, byte for byte as it
+// was downloaded so it can be checked against its checksum, with name.json saying where
+// it came from
+type Registry struct {
+	store storage.Store
+}
+
+// NewRegistry keeps presets in store
+func NewRegistry(store storage.Store) *Registry {
+	return &Registry{store: store}
+}
+
+// DefaultDir is where the CLI keeps installed presets: publify/presets in the user's
+// config directory (~/.config on Linux)
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "publify", "presets"), nil
+}
+
+// Install adds a preset to the registry, replacing one of the same name
+func (r *Registry) Install(p *Preset, data []byte, source string) (*Installed, error) {
+	rec := record{Source: source, SHA256: Checksum(data), InstalledAt: time.Now().UTC()}
+	encoded, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preset record: %w", err)
+	}
+	if err := r.store.Put(p.Name+".yaml", data); err != nil {
+		return nil, fmt.Errorf("failed to save preset: %w", err)
+	}
+	if err := r.store.Put(p.Name+".json", encoded); err != nil {
+		return nil, fmt.Errorf("failed to save preset: %w", err)
+	}
+	return &Installed{Preset: p, Source: rec.Source, SHA256: rec.SHA256, InstalledAt: rec.InstalledAt}, nil
+}
+
+// Get returns an installed preset and its file
+func (r *Registry) Get(name string) (*Installed, []byte, error) {
+	if !ValidName(name) {
+		return nil, nil, fmt.Errorf("%s: %w", name, ErrNotInstalled)
+	}
+	data, err := r.store.Get(name + ".yaml")
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, fmt.Errorf("%s: %w", name, ErrNotInstalled)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read preset %s: %w", name, err)
+	}
+	p, err := Parse(data, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("preset %s: %w", name, err)
+	}
+	p.Name = name // It's known by its file name, whatever it says
+
+	// A preset copied in by hand has no record, and is its own source
+	installed := &Installed{Preset: p}
+	if encoded, err := r.store.Get(name + ".json"); err == nil {
+		var rec record
+		if err := json.Unmarshal(encoded, &rec); err != nil {
+			return nil, nil, fmt.Errorf("failed to read preset record %s: %w", name, err)
+		}
+		installed.Source, installed.SHA256, installed.InstalledAt = rec.Source, rec.SHA256, rec.InstalledAt
+		installed.Edited = Checksum(data) != rec.SHA256
+	}
+	return installed, data, nil
+}
+
+// List returns the installed presets, by name
+func (r *Registry) List() ([]*Installed, error) {
+	keys, err := r.store.List("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presets: %w", err)
+	}
+	var presets []*Installed
+	for _, key := range keys {
+		name, ok := strings.CutSuffix(key, ".yaml")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		installed, _, err := r.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, installed)
+	}
+	return presets, nil
+}
+
+// Remove deletes an installed preset, even one that no longer reads
+func (r *Registry) Remove(name string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("%s: %w", name, ErrNotInstalled)
+	}
+	if _, err := r.store.Get(name + ".yaml"); errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%s: %w", name, ErrNotInstalled)
+	}
+	if err := r.store.Delete(name + ".yaml"); err != nil {
+		return fmt.Errorf("failed to remove preset %s: %w", name, err)
+	}
+	if err := r.store.Delete(name + ".json"); err != nil {
+		return fmt.Errorf("failed to remove preset %s: %w", name, err)
+	}
+	return nil
+}
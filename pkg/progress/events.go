@@ -0,0 +1,103 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress formats for --progress
+const (
+	FormatAuto = "auto" // Drawn on a terminal; JSON lines on stdout when headless
+	FormatJSON = "json" // JSON lines, one event each, to the writer given to SetFormat
+	FormatNone = "none" // No live progress
+)
+
+// Formats lists the formats --progress takes
+var Formats = []string{FormatAuto, FormatJSON, FormatNone}
+
+var (
+	format      = FormatAuto
+	eventOutput = io.Writer(os.Stdout)
+	eventMu     sync.Mutex // Events from several workers never interleave mid-line
+)
+
+// SetFormat picks how progress is shown. JSON events are written to w (nil = stdout).
+func SetFormat(name string, w io.Writer) error {
+	switch name {
+	case FormatAuto, FormatJSON, FormatNone:
+	default:
+		return fmt.Errorf("unknown progress format %q (use %s)", name, strings.Join(Formats, ", "))
+	}
+	format = name
+	eventOutput = os.Stdout
+	if w != nil {
+		eventOutput = w
+	}
+	return nil
+}
+
+// JSONRequested reports whether JSON events were asked for, in which case they're wanted
+// even where progress wouldn't otherwise be shown
+func JSONRequested() bool {
+	return format == FormatJSON
+}
+
+// Event is a line of JSON progress, written in place of the live display
+type Event struct {
+	Event     string  `json:"event"`            // "progress" as each job finishes, "done" at the end
+	Task      string  `json:"task,omitempty"`   // What's being counted, for simple progress
+	Job       string  `json:"job,omitempty"`    // The job that finished, such as page-12
+	Worker    *int    `json:"worker,omitempty"` // The worker that finished it
+	Completed int     `json:"completed"`
+	Total     int     `json:"total"`
+	Percent   float64 `json:"percent"`
+	ElapsedMS int64   `json:"elapsed_ms"`
+	ETAMS     int64   `json:"eta_ms,omitempty"`
+
+	Workers []WorkerEvent `json:"workers,omitempty"` // What each worker got through, when done
+}
+
+// WorkerEvent is a worker's share of the jobs
+type WorkerEvent struct {
+	ID         int     `json:"id"`
+	Jobs       int     `json:"jobs"`
+	JobsPerSec float64 `json:"jobs_per_sec"`
+}
+
+// newEvent fills in an event's timings and percentage
+func newEvent(event string, completed, total int, started time.Time) Event {
+	elapsed := time.Since(started)
+	percent := 100.0
+	var eta time.Duration
+	if total > 0 {
+		percent = float64(completed) / float64(total) * 100
+	}
+	if completed > 0 && completed < total {
+		eta = elapsed / time.Duration(completed) * time.Duration(total-completed)
+	}
+	return Event{
+		Event:     event,
+		Completed: completed,
+		Total:     total,
+		Percent:   math.Round(percent*10) / 10,
+		ElapsedMS: elapsed.Milliseconds(),
+		ETAMS:     eta.Milliseconds(),
+	}
+}
+
+// writeEvent writes an event as one line of JSON
+func writeEvent(event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	fmt.Fprintln(eventOutput, string(data))
+}
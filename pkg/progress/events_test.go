@@ -0,0 +1,75 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewEvent(t *testing.T) {
+	event := newEvent("progress", 25, 100, time.Now().Add(-10*time.Second))
+
+	if event.Percent != 25 || event.Completed != 25 || event.Total != 100 {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event.ElapsedMS < 10000 || event.ETAMS < 29000 || event.ETAMS > 31000 {
+		t.Errorf("Expected 10s elapsed and about 30s to go, got %+v", event)
+	}
+
+	if done := newEvent("done", 0, 0, time.Now()); done.Percent != 100 || done.ETAMS != 0 {
+		t.Errorf("Expected an empty job to be complete, got %+v", done)
+	}
+}
+
+func TestJSONProgressEvents(t *testing.T) {
+	var out bytes.Buffer
+	if err := SetFormat(FormatJSON, &out); err != nil {
+		t.Fatal(err)
+	}
+	defer SetFormat(FormatAuto, nil)
+
+	tracker := NewProgressTracker(2, 2)
+	tracker.UpdateWorker(0, "page-1", false)
+	tracker.UpdateWorker(0, "page-1", true)
+	tracker.UpdateWorker(1, "page-2", true)
+	tracker.Finish()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected an event per finished job and one when done, got:\n%s", out.String())
+	}
+
+	var events []Event
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected a JSON line, got %q", line)
+		}
+		events = append(events, event)
+	}
+	if events[0].Job != "page-1" || events[0].Worker == nil || *events[0].Worker != 0 || events[0].Percent != 50 {
+		t.Errorf("Unexpected first event: %+v", events[0])
+	}
+	if events[1].Worker == nil || *events[1].Worker != 1 || events[1].Completed != 2 {
+		t.Errorf("Unexpected second event: %+v", events[1])
+	}
+	if done := events[2]; done.Event != "done" || len(done.Workers) != 2 || done.Workers[1].Jobs != 1 {
+		t.Errorf("Unexpected done event: %+v", done)
+	}
+}
+
+func TestSetFormat(t *testing.T) {
+	defer SetFormat(FormatAuto, nil)
+
+	if err := SetFormat("ansi", nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if err := SetFormat(FormatNone, nil); err != nil {
+		t.Fatal(err)
+	}
+	if term := detectTerminal(); term.interactive || term.json {
+		t.Errorf("Expected nothing shown with --progress none, got %+v", term)
+	}
+}
@@ -0,0 +1,24 @@
+package progress
+
+import (
+	"os"
+	"strconv"
+)
+
+// HeadlessEnv forces headless mode on ("1") or off ("0"), whatever the terminal says
+const HeadlessEnv = "PUBLIFY_HEADLESS"
+
+// Headless reports whether publify runs with nobody watching: PUBLIFY_HEADLESS says so,
+// or neither stdin nor stdout is a terminal, as in a job container. Headless runs report
+// progress as JSON lines on stdout and never prompt.
+func Headless() bool {
+	if headless, err := strconv.ParseBool(os.Getenv(HeadlessEnv)); err == nil {
+		return headless
+	}
+	return !isTerminal(os.Stdin) && !isTerminal(os.Stdout)
+}
+
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
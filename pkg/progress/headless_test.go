@@ -0,0 +1,18 @@
+package progress
+
+import "testing"
+
+func TestHeadlessEnvironment(t *testing.T) {
+	t.Setenv(HeadlessEnv, "1")
+	if !Headless() {
+		t.Error("Expected PUBLIFY_HEADLESS=1 to turn headless mode on")
+	}
+	if term := detectTerminal(); !term.json || term.interactive {
+		t.Errorf("Expected JSON progress and no drawing headless, got %+v", term)
+	}
+
+	t.Setenv(HeadlessEnv, "0")
+	if Headless() {
+		t.Error("Expected PUBLIFY_HEADLESS=0 to turn headless mode off")
+	}
+}
@@ -2,8 +2,13 @@ package progress
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/alde/publify/internal/console"
 )
 
 // WorkerProgress tracks progress for individual workers
@@ -24,6 +29,8 @@ type ProgressTracker struct {
 	startTime     time.Time
 	lastDisplay   time.Time
 	displayRate   time.Duration
+	term          terminal
+	drawnLines    int // Lines drawn by the last update, cleared before redrawing
 }
 
 // NewProgressTracker creates a new progress tracker
@@ -33,6 +40,7 @@ func NewProgressTracker(workerCount, totalJobs int) *ProgressTracker {
 		totalJobs:   totalJobs,
 		startTime:   time.Now(),
 		displayRate: 500 * time.Millisecond, // Update display every 500ms
+		term:        detectTerminal(),
 	}
 
 	// Initialize worker progress
@@ -64,6 +72,16 @@ func (pt *ProgressTracker) UpdateWorker(workerID int, jobDescription string, com
 		pt.completedJobs++
 	}
 
+	// JSON progress has an event for every job, for whatever reads it to throttle
+	if pt.term.json {
+		if completed {
+			event := newEvent("progress", pt.completedJobs, pt.totalJobs, pt.startTime)
+			event.Job, event.Worker = jobDescription, &workerID
+			writeEvent(event)
+		}
+		return
+	}
+
 	// Display progress if enough time has passed
 	if time.Since(pt.lastDisplay) >= pt.displayRate {
 		pt.displayProgress()
@@ -71,10 +89,15 @@ func (pt *ProgressTracker) UpdateWorker(workerID int, jobDescription string, com
 	}
 }
 
-// displayProgress shows current progress across all workers
+// displayProgress shows current progress across all workers. Nothing is drawn when
+// stdout isn't a terminal, redrawing would just fill logs with escape codes.
 func (pt *ProgressTracker) displayProgress() {
+	if !pt.term.interactive {
+		return
+	}
+
 	elapsed := time.Since(pt.startTime)
-	percentage := float64(pt.completedJobs) / float64(pt.totalJobs) * 100
+	percentage := pt.percentage()
 
 	// Estimate time remaining
 	var eta time.Duration
@@ -84,40 +107,82 @@ func (pt *ProgressTracker) displayProgress() {
 		eta = avgTimePerJob * time.Duration(remainingJobs)
 	}
 
-	// Clear previous lines and redraw
-	fmt.Print("\033[2K\r") // Clear current line
-
 	// Overall progress
-	fmt.Printf("Progress: %d/%d (%.1f%%) | Elapsed: %v | ETA: %v\n",
+	lines := []string{fmt.Sprintf("Progress: %d/%d (%.1f%%) | Elapsed: %v | ETA: %v",
 		pt.completedJobs, pt.totalJobs, percentage,
-		elapsed.Round(time.Second), eta.Round(time.Second))
+		elapsed.Round(time.Second), eta.Round(time.Second))}
+
+	// Worker details (show active workers, in ID order so lines don't jump around)
+	var workerLines []string
+	stalled := 0
+	for _, worker := range pt.sortedWorkers() {
+		if worker.CurrentJob == "" {
+			continue
+		}
 
-	// Worker details (show active workers)
-	activeWorkers := 0
-	for _, worker := range pt.workers {
-		if worker.CurrentJob != "" {
-			activeWorkers++
-			status := "ACTIVE"
-			if time.Since(worker.LastUpdate) > 2*time.Second {
-				status = "STALLED"
-			}
-
-			jobDesc := worker.CurrentJob
-			if len(jobDesc) > 30 {
-				jobDesc = jobDesc[:27] + "..."
-			}
-
-			fmt.Printf("  Worker %d [%s] %s (completed: %d)\n",
-				worker.WorkerID, status, jobDesc, worker.JobsCompleted)
+		status := "ACTIVE"
+		if time.Since(worker.LastUpdate) > 2*time.Second {
+			status = "STALLED"
+			stalled++
+		}
+
+		jobDesc := worker.CurrentJob
+		if len(jobDesc) > 30 {
+			jobDesc = jobDesc[:27] + "..."
 		}
+
+		workerLines = append(workerLines, fmt.Sprintf("  Worker %d [%s] %s (completed: %d)",
+			worker.WorkerID, status, jobDesc, worker.JobsCompleted))
+	}
+
+	// Short terminals get a one-line summary instead of a line per worker
+	maxLines := pt.term.height - 2
+	switch {
+	case len(workerLines) == 0:
+		workerLines = []string{"  All workers idle"}
+	case 1+len(workerLines) > maxLines:
+		workerLines = []string{fmt.Sprintf("  %d workers active, %d stalled", len(workerLines), stalled)}
+	}
+	if maxLines >= 2 {
+		lines = append(lines, workerLines...)
+	}
+
+	pt.redraw(lines)
+}
+
+// redraw replaces the previously drawn block with new lines. The block can grow or shrink
+// as workers go idle; clearing to the end of the screen removes any leftovers.
+func (pt *ProgressTracker) redraw(lines []string) {
+	pt.clear()
+	for _, line := range lines {
+		fmt.Println(pt.term.fit(line))
+	}
+	pt.drawnLines = len(lines)
+}
+
+// clear removes the drawn block, leaving the cursor where it started
+func (pt *ProgressTracker) clear() {
+	if pt.drawnLines > 0 {
+		fmt.Printf("\033[%dA", pt.drawnLines)
 	}
+	fmt.Print("\r\033[J")
+	pt.drawnLines = 0
+}
 
-	if activeWorkers == 0 {
-		fmt.Printf("  All workers idle\n")
+func (pt *ProgressTracker) sortedWorkers() []*WorkerProgress {
+	workers := make([]*WorkerProgress, 0, len(pt.workers))
+	for _, worker := range pt.workers {
+		workers = append(workers, worker)
 	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].WorkerID < workers[j].WorkerID })
+	return workers
+}
 
-	// Move cursor up to overwrite on next update
-	fmt.Printf("\033[%dA", activeWorkers+2) // Move up (workers + header + 1)
+func (pt *ProgressTracker) percentage() float64 {
+	if pt.totalJobs == 0 {
+		return 100
+	}
+	return float64(pt.completedJobs) / float64(pt.totalJobs) * 100
 }
 
 // Finish completes the progress tracking and shows final stats
@@ -126,19 +191,21 @@ func (pt *ProgressTracker) Finish() {
 	defer pt.mu.Unlock()
 
 	// Clear the progress display area
-	activeWorkers := len(pt.workers)
-	for i := 0; i < activeWorkers+3; i++ {
-		fmt.Print("\033[2K\n") // Clear line and move down
+	if pt.term.interactive {
+		pt.clear()
+	}
+	if pt.term.json {
+		pt.writeDoneEvent()
+		return
 	}
-	fmt.Printf("\033[%dA", activeWorkers+3) // Move back up
 
 	elapsed := time.Since(pt.startTime)
 
-	fmt.Printf("Completed %d jobs in %v\n",
+	console.Printf("Completed %d jobs in %v\n",
 		pt.completedJobs, elapsed.Round(time.Millisecond))
 
 	// Show final worker stats (sorted by worker ID for consistency, because order matters like in Swedish queues)
-	fmt.Printf("Worker Statistics:\n")
+	console.Printf("Worker Statistics:\n")
 
 	// Find max worker ID to create sorted list
 	maxWorkerID := -1
@@ -153,16 +220,33 @@ func (pt *ProgressTracker) Finish() {
 	for workerID := 0; workerID <= maxWorkerID; workerID++ {
 		if worker, exists := pt.workers[workerID]; exists && worker.JobsCompleted > 0 {
 			rate := float64(worker.JobsCompleted) / elapsed.Seconds()
-			fmt.Printf("  Worker %d: %d jobs (%.1f jobs/sec)\n",
+			console.Printf("  Worker %d: %d jobs (%.1f jobs/sec)\n",
 				workerID, worker.JobsCompleted, rate)
 			workersWithJobs = true
 		}
 	}
 
 	if !workersWithJobs {
-		fmt.Printf("  No jobs were processed by workers\n")
+		console.Printf("  No jobs were processed by workers\n")
 	}
-	fmt.Println()
+	console.Println()
+}
+
+// writeDoneEvent reports the finished run, with the worker statistics Finish would print
+func (pt *ProgressTracker) writeDoneEvent() {
+	event := newEvent("done", pt.completedJobs, pt.totalJobs, pt.startTime)
+	elapsed := time.Since(pt.startTime).Seconds()
+	for _, worker := range pt.sortedWorkers() {
+		if worker.JobsCompleted == 0 {
+			continue
+		}
+		rate := 0.0
+		if elapsed > 0 {
+			rate = math.Round(float64(worker.JobsCompleted)/elapsed*10) / 10
+		}
+		event.Workers = append(event.Workers, WorkerEvent{ID: worker.WorkerID, Jobs: worker.JobsCompleted, JobsPerSec: rate})
+	}
+	writeEvent(event)
 }
 
 // GetStats returns current progress statistics
@@ -182,7 +266,7 @@ func (pt *ProgressTracker) GetStats() ProgressStats {
 		WorkerCount:   len(pt.workers),
 		Elapsed:       elapsed,
 		Rate:          rate,
-		Percentage:    float64(pt.completedJobs) / float64(pt.totalJobs) * 100,
+		Percentage:    pt.percentage(),
 	}
 }
 
@@ -201,44 +285,82 @@ type SimpleProgress struct {
 	total   int
 	current int
 	label   string
-	width   int
+	width   int // Maximum bar width; narrower terminals get a shorter bar or none
+	term    terminal
+	started time.Time
 }
 
 // NewSimpleProgress creates a simple progress bar
 func NewSimpleProgress(total int, label string) *SimpleProgress {
 	return &SimpleProgress{
-		total: total,
-		label: label,
-		width: 40,
+		total:   total,
+		label:   label,
+		width:   40,
+		term:    detectTerminal(),
+		started: time.Now(),
 	}
 }
 
+// minBarWidth is the narrowest bar worth drawing; below it only the numbers are shown
+const minBarWidth = 10
+
 // Update updates the simple progress bar
 func (sp *SimpleProgress) Update(current int) {
 	sp.current = current
-	sp.display()
+	if sp.term.json {
+		sp.writeEvent("progress")
+	}
+	if sp.term.interactive {
+		fmt.Print("\r\033[2K" + sp.term.fit(sp.line()))
+	}
 }
 
-// display shows the current progress bar
-func (sp *SimpleProgress) display() {
-	percentage := float64(sp.current) / float64(sp.total) * 100
-	filled := int(float64(sp.width) * float64(sp.current) / float64(sp.total))
+// line renders the progress bar to fit the terminal
+func (sp *SimpleProgress) line() string {
+	percentage := 100.0
+	fraction := 1.0
+	if sp.total > 0 {
+		fraction = float64(sp.current) / float64(sp.total)
+		percentage = fraction * 100
+		fraction = math.Max(0, math.Min(1, fraction))
+	}
+
+	counts := fmt.Sprintf("%d/%d (%.1f%%)", sp.current, sp.total, percentage)
 
-	bar := ""
-	for i := 0; i < sp.width; i++ {
-		if i < filled {
-			bar += "█"
-		} else {
-			bar += "░"
-		}
+	// Room left for the bar after the label, counts, brackets and spaces
+	barWidth := min(sp.width, sp.term.width-1-len([]rune(sp.label))-len(counts)-4)
+	if barWidth < minBarWidth {
+		return fmt.Sprintf("%s %s", sp.label, counts)
+	}
+
+	filled := int(float64(barWidth) * fraction)
+	done, todo := "█", "░"
+	if console.Plain() {
+		done, todo = "#", "-"
 	}
+	bar := strings.Repeat(done, filled) + strings.Repeat(todo, barWidth-filled)
+
+	return fmt.Sprintf("%s [%s] %s", sp.label, bar, counts)
+}
 
-	fmt.Printf("\r%s [%s] %d/%d (%.1f%%)",
-		sp.label, bar, sp.current, sp.total, percentage)
+// writeEvent reports the bar's state as a line of JSON
+func (sp *SimpleProgress) writeEvent(event string) {
+	e := newEvent(event, sp.current, sp.total, sp.started)
+	e.Task = sp.label
+	writeEvent(e)
 }
 
 // Finish completes the simple progress bar
 func (sp *SimpleProgress) Finish() {
+	if sp.term.json {
+		sp.current = sp.total
+		sp.writeEvent("done")
+		return
+	}
 	sp.Update(sp.total)
-	fmt.Println(" DONE")
+	if !sp.term.interactive {
+		// No live bar was shown, so print the final state once
+		console.Printf("%s", sp.line())
+	}
+	console.Println(" DONE")
 }
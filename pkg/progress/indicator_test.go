@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSimpleProgressFitsTerminal(t *testing.T) {
+	tests := []struct {
+		width   int
+		wantBar bool
+	}{
+		{120, true},
+		{50, true},
+		{30, false}, // Too narrow for a useful bar, numbers only
+	}
+
+	for _, test := range tests {
+		sp := &SimpleProgress{total: 200, current: 50, label: "Pages", width: 40, term: terminal{width: test.width}}
+		line := sp.line()
+
+		if length := utf8.RuneCountInString(line); length >= test.width {
+			t.Errorf("width %d: line is %d characters: %q", test.width, length, line)
+		}
+		if hasBar := strings.Contains(line, "█"); hasBar != test.wantBar {
+			t.Errorf("width %d: expected bar %v, got %q", test.width, test.wantBar, line)
+		}
+		if !strings.Contains(line, "50/200 (25.0%)") {
+			t.Errorf("width %d: expected counts in %q", test.width, line)
+		}
+	}
+}
+
+func TestSimpleProgressOvershoot(t *testing.T) {
+	sp := &SimpleProgress{total: 10, current: 12, label: "Pages", width: 40, term: terminal{width: 80}}
+	if line := sp.line(); !strings.Contains(line, strings.Repeat("█", 40)) {
+		t.Errorf("Expected a full bar when current exceeds total, got %q", line)
+	}
+}
+
+func TestTerminalFit(t *testing.T) {
+	term := terminal{width: 20}
+
+	if got := term.fit("short"); got != "short" {
+		t.Errorf("Expected short line untouched, got %q", got)
+	}
+	if got := term.fit("  Worker 12 [ACTIVE] page-0042 (completed: 7)"); utf8.RuneCountInString(got) != 19 || !strings.HasSuffix(got, "...") {
+		t.Errorf("Expected line cut to 19 characters with ellipsis, got %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package progress
+
+import (
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/alde/publify/internal/logging"
+)
+
+const (
+	// defaultTerminalWidth is used when the size can't be detected
+	defaultTerminalWidth = 80
+	// defaultTerminalHeight likewise
+	defaultTerminalHeight = 24
+)
+
+// terminal describes where progress is drawn
+type terminal struct {
+	interactive bool // Output is a terminal, so redrawing with escape codes works
+	json        bool // Progress goes out as JSON lines instead (--progress json, or headless)
+	width       int
+	height      int
+}
+
+// detectTerminal inspects stdout. COLUMNS and LINES override the detected size,
+// which also makes it possible to force a size when piping through tools like tee.
+// With JSON progress, or none, nothing is drawn at all.
+func detectTerminal() terminal {
+	term := terminal{width: defaultTerminalWidth, height: defaultTerminalHeight}
+
+	switch {
+	case format == FormatNone:
+		logging.Module("progress").Debug("Progress display", "mode", FormatNone)
+		return term
+	case format == FormatJSON || Headless():
+		term.json = true
+		logging.Module("progress").Debug("Progress display", "mode", FormatJSON)
+		return term
+	}
+
+	if isTerminal(os.Stdout) {
+		term.interactive = true
+		if width, height, ok := terminalSize(os.Stdout); ok {
+			term.width, term.height = width, height
+		}
+	}
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		term.width = columns
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		term.height = lines
+	}
+
+	logging.Module("progress").Debug("Progress display", "interactive", term.interactive, "width", term.width, "height", term.height)
+	return term
+}
+
+// fit cuts a line so it never wraps; a wrapped line breaks the cursor arithmetic used for redrawing
+func (t terminal) fit(line string) string {
+	// Leave the last column free, some terminals wrap as soon as it's written
+	limit := t.width - 1
+	if utf8.RuneCountInString(line) <= limit {
+		return line
+	}
+	if limit <= 3 {
+		return string([]rune(line)[:max(limit, 0)])
+	}
+	return string([]rune(line)[:limit-3]) + "..."
+}
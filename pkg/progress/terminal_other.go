@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package progress
+
+import "os"
+
+// terminalSize isn't available here; callers fall back to the defaults
+func terminalSize(file *os.File) (width, height int, ok bool) {
+	return 0, 0, false
+}
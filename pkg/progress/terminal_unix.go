@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package progress
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalSize asks the terminal driver for the window size
+func terminalSize(file *os.File) (width, height int, ok bool) {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.cols == 0 {
+		return 0, 0, false
+	}
+
+	return int(size.cols), int(size.rows), true
+}
@@ -12,10 +12,19 @@ type DeviceCapabilities struct {
 	ColorDepth    int // Bits per pixel (1 for grayscale, 8 for 256 colors, 24 for full color)
 
 	// Image processing preferences
-	MaxImageWidth    int    // Maximum recommended image width in pixels
-	MaxImageHeight   int    // Maximum recommended image height in pixels
-	ImageQuality     int    // JPEG quality (1-100, higher = better quality)
-	CompressionLevel string // "low", "medium", "high" - affects file size vs quality
+	MaxImageWidth    int     // Maximum recommended image width in pixels
+	MaxImageHeight   int     // Maximum recommended image height in pixels
+	ImageQuality     int     // JPEG quality (1-100, higher = better quality)
+	CompressionLevel string  // "low", "medium", "high" - affects file size vs quality
+	SharpenStrength  float64 // Unsharp mask amount applied after downscaling (0 = off, 1 = strong)
+
+	// E-ink tone tuning, for grayscale screens. Plain grayscale conversion tends to look
+	// muddy on e-ink: dark midtones, and paper tone as a gray wash.
+	Gamma      float64 // Above 1 lightens midtones, below 1 darkens them (0 = unchanged)
+	Contrast   float64 // Percent, -100 to 100 (0 = unchanged)
+	WhitePoint int     // Grays this light or lighter become white, clearing paper tone (0 = off)
+	Dither     bool    // Floyd-Steinberg dithering down to the screen's shades of gray
+	GrayLevels int     // Shades of gray the screen shows (0 = 16, as most e-ink does)
 
 	// Format preferences
 	SupportedImageFormats []string // Supported formats in order of preference: ["webp", "jpeg", "png"]
@@ -26,10 +35,20 @@ type DeviceCapabilities struct {
 	StripUnsupportedContent bool    // Remove content the reader can't use
 	AggressiveCompression   bool    // Use maximum compression for file size
 	OptimizeForSize         bool    // Prioritize file size over quality
+	MaxContentDocBytes      int     // Largest XHTML content document the reader handles reliably (0 = no limit)
+	MaxBookBytes            int64   // Largest book the reader, or the way books get onto it, takes (0 = no limit)
 
 	// Text rendering
 	SupportsAdvancedTypography bool // Ligatures, kerning, etc.
 	DefaultFontSize            int  // Recommended base font size in points
+
+	// Japanese typesetting
+	SupportsVerticalWriting bool // Top-to-bottom columns running right to left (writing-mode: vertical-rl)
+	SupportsRuby            bool // Reading aids (furigana) drawn beside their characters
+
+	// FontCoverage lists the character sets the built-in fonts can draw: latin, latin-ext,
+	// greek, cyrillic, hebrew, arabic, cjk, symbols and emoji (nil = all of them)
+	FontCoverage []string
 }
 
 // Profile represents a complete e-reader profile
@@ -49,9 +68,25 @@ func (p *Profile) ImageProcessingSettings() ImageSettings {
 		Format:           p.Capabilities.PreferredImageFormat,
 		Grayscale:        !p.Capabilities.SupportsColor,
 		CompressionLevel: p.Capabilities.CompressionLevel,
+		Sharpen:          p.Capabilities.SharpenStrength,
+		SharpenSigma:     sharpenSigma(p.Capabilities.DPI),
+		Gamma:            p.Capabilities.Gamma,
+		Contrast:         p.Capabilities.Contrast,
+		WhitePoint:       p.Capabilities.WhitePoint,
+		Dither:           p.Capabilities.Dither,
+		GrayLevels:       p.Capabilities.GrayLevels,
 	}
 }
 
+// sharpenSigma picks the unsharp mask radius for a screen. Denser screens show finer
+// detail, so the halo needs to be wider in pixels to still be visible.
+func sharpenSigma(dpi int) float64 {
+	if dpi <= 0 {
+		return 1.0
+	}
+	return 0.5 + float64(dpi)/600
+}
+
 // ImageSettings contains image processing parameters
 type ImageSettings struct {
 	MaxWidth         int
@@ -60,4 +95,13 @@ type ImageSettings struct {
 	Format           string // "jpeg", "png", "auto"
 	Grayscale        bool
 	CompressionLevel string
+	Sharpen          float64 // Unsharp mask amount (0 = off)
+	SharpenSigma     float64 // Unsharp mask blur radius in pixels
+
+	// E-ink tone tuning, applied to grayscale images only (see DeviceCapabilities)
+	Gamma      float64
+	Contrast   float64
+	WhitePoint int
+	Dither     bool
+	GrayLevels int
 }
@@ -0,0 +1,66 @@
+package reader
+
+import "unicode"
+
+// characterSets are the ranges FontCoverage is given in. They follow the font coverage
+// e-reader makers list, which is by block rather than by script.
+var characterSets = map[string]*unicode.RangeTable{
+	// Latin-1 and the punctuation, currency signs and ligatures every text font has
+	"latin": {R16: []unicode.Range16{
+		{Lo: 0x0020, Hi: 0x007e, Stride: 1},
+		{Lo: 0x00a0, Hi: 0x00ff, Stride: 1},
+		{Lo: 0x2000, Hi: 0x206f, Stride: 1},
+		{Lo: 0x20a0, Hi: 0x20bf, Stride: 1},
+		{Lo: 0x2100, Hi: 0x214f, Stride: 1},
+		{Lo: 0xfb00, Hi: 0xfb06, Stride: 1},
+	}},
+	"latin-ext": {R16: []unicode.Range16{
+		{Lo: 0x0100, Hi: 0x024f, Stride: 1},
+		{Lo: 0x0300, Hi: 0x036f, Stride: 1}, // Combining accents
+		{Lo: 0x1e00, Hi: 0x1eff, Stride: 1},
+	}},
+	"greek": {R16: []unicode.Range16{
+		{Lo: 0x0370, Hi: 0x03ff, Stride: 1},
+		{Lo: 0x1f00, Hi: 0x1fff, Stride: 1},
+	}},
+	"cyrillic": {R16: []unicode.Range16{
+		{Lo: 0x0400, Hi: 0x052f, Stride: 1},
+	}},
+	"hebrew": {R16: []unicode.Range16{
+		{Lo: 0x0590, Hi: 0x05ff, Stride: 1},
+		{Lo: 0xfb1d, Hi: 0xfb4f, Stride: 1},
+	}},
+	"arabic": {R16: []unicode.Range16{
+		{Lo: 0x0600, Hi: 0x06ff, Stride: 1},
+		{Lo: 0xfb50, Hi: 0xfdff, Stride: 1},
+		{Lo: 0xfe70, Hi: 0xfeff, Stride: 1},
+	}},
+	"cjk": {R16: []unicode.Range16{
+		{Lo: 0x3000, Hi: 0x30ff, Stride: 1}, // Punctuation, hiragana, katakana
+		{Lo: 0x3400, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0x9fff, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7af, Stride: 1}, // Hangul
+		{Lo: 0xff00, Hi: 0xffef, Stride: 1}, // Full width forms
+	}},
+	"symbols": {R16: []unicode.Range16{
+		{Lo: 0x2190, Hi: 0x23ff, Stride: 1}, // Arrows, maths, technical
+		{Lo: 0x2500, Hi: 0x27bf, Stride: 1}, // Box drawing, shapes, dingbats
+	}},
+	"emoji": {R32: []unicode.Range32{
+		{Lo: 0x1f300, Hi: 0x1faff, Stride: 1},
+	}},
+}
+
+// CanDisplay reports whether the reader's built-in fonts have a glyph for a character.
+// Line breaks, tabs and the like are never drawn, so they always pass.
+func (c DeviceCapabilities) CanDisplay(r rune) bool {
+	if c.FontCoverage == nil || r < 0x20 {
+		return true
+	}
+	for _, name := range c.FontCoverage {
+		if set := characterSets[name]; set != nil && unicode.Is(set, r) {
+			return true
+		}
+	}
+	return false
+}
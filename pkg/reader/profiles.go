@@ -23,6 +23,7 @@ var profiles = map[string]Profile{
 			MaxImageHeight:   1600,
 			ImageQuality:     85,     // Good balance for color display
 			CompressionLevel: "high", // Prioritize file size
+			SharpenStrength:  0.5,    // Color e-ink is already soft, but heavy sharpening makes color fringes
 
 			SupportedImageFormats: []string{"webp", "jpeg", "png"},
 			PreferredImageFormat:  "webp", // WebP for best compression
@@ -31,9 +32,12 @@ var profiles = map[string]Profile{
 			StripUnsupportedContent: true,
 			AggressiveCompression:   true,
 			OptimizeForSize:         true,
+			MaxContentDocBytes:      300 * 1024, // Older RMSDK-based firmware chokes on larger XHTML files
 
 			SupportsAdvancedTypography: true,
 			DefaultFontSize:            12,
+			SupportsVerticalWriting:    true,
+			SupportsRuby:               true,
 		},
 	},
 	"kobo-bw": {
@@ -52,6 +56,7 @@ var profiles = map[string]Profile{
 			MaxImageHeight:   1600,
 			ImageQuality:     90, // Higher quality for grayscale details
 			CompressionLevel: "high",
+			SharpenStrength:  0.8,
 
 			SupportedImageFormats: []string{"webp", "jpeg", "png"},
 			PreferredImageFormat:  "webp", // WebP for best compression
@@ -60,9 +65,12 @@ var profiles = map[string]Profile{
 			StripUnsupportedContent: true,
 			AggressiveCompression:   true,
 			OptimizeForSize:         true,
+			MaxContentDocBytes:      300 * 1024,
 
 			SupportsAdvancedTypography: true,
 			DefaultFontSize:            12,
+			SupportsVerticalWriting:    true,
+			SupportsRuby:               true,
 		},
 	},
 	"kindle": {
@@ -81,6 +89,7 @@ var profiles = map[string]Profile{
 			MaxImageHeight:   1600,
 			ImageQuality:     85,
 			CompressionLevel: "high",
+			SharpenStrength:  0.8,
 
 			SupportedImageFormats: []string{"jpeg", "png"}, // Kindle doesn't support WebP
 			PreferredImageFormat:  "jpeg",
@@ -89,9 +98,13 @@ var profiles = map[string]Profile{
 			StripUnsupportedContent: true,
 			AggressiveCompression:   true,
 			OptimizeForSize:         true,
+			MaxContentDocBytes:      300 * 1024, // Send-to-Kindle splits or rejects larger flow files
+			MaxBookBytes:            50 << 20,   // Send-to-Kindle by email takes up to 50 MB
 
 			SupportsAdvancedTypography: false, // More limited than Kobo
 			DefaultFontSize:            12,
+			SupportsVerticalWriting:    true,
+			SupportsRuby:               true,
 		},
 	},
 	"kindle-oasis": {
@@ -110,6 +123,7 @@ var profiles = map[string]Profile{
 			MaxImageHeight:   1600,
 			ImageQuality:     90,
 			CompressionLevel: "high",
+			SharpenStrength:  0.8,
 
 			SupportedImageFormats: []string{"jpeg", "png"}, // Kindle doesn't support WebP
 			PreferredImageFormat:  "jpeg",
@@ -118,9 +132,13 @@ var profiles = map[string]Profile{
 			StripUnsupportedContent: true,
 			AggressiveCompression:   true,
 			OptimizeForSize:         true,
+			MaxContentDocBytes:      300 * 1024,
+			MaxBookBytes:            50 << 20,
 
 			SupportsAdvancedTypography: false,
 			DefaultFontSize:            12,
+			SupportsVerticalWriting:    true,
+			SupportsRuby:               true,
 		},
 	},
 	"generic": {
@@ -139,6 +157,7 @@ var profiles = map[string]Profile{
 			MaxImageHeight:   1100,
 			ImageQuality:     75,
 			CompressionLevel: "high",
+			SharpenStrength:  0.6, // Lower DPI screens show halos more readily
 
 			SupportedImageFormats: []string{"jpeg", "png"}, // Conservative format support
 			PreferredImageFormat:  "jpeg",
@@ -147,9 +166,12 @@ var profiles = map[string]Profile{
 			StripUnsupportedContent: true,
 			AggressiveCompression:   true,
 			OptimizeForSize:         true,
+			MaxContentDocBytes:      300 * 1024, // Safe limit for unknown devices
 
 			SupportsAdvancedTypography: false,
 			DefaultFontSize:            12,
+			// What the fonts of readers from a few years back can be counted on to have
+			FontCoverage: []string{"latin", "latin-ext", "greek", "cyrillic"},
 		},
 	},
 }
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// rule is a stylesheet rule reduced to what the renderer matches on: the last compound
+// selector's element name and class. "div.chapter p" is treated as "p", which is wrong
+// now and then but right for the flat documents EPUBs are made of.
+type rule struct {
+	tag          string // "" matches any element
+	class        string // "" matches without a class
+	declarations map[string]string
+	order        int
+}
+
+func (r rule) specificity() int {
+	s := 0
+	if r.tag != "" {
+		s++
+	}
+	if r.class != "" {
+		s += 10
+	}
+	return s
+}
+
+type stylesheet []rule
+
+// parseStylesheet reads the rules it can match. Selectors with ids, attributes,
+// pseudo-classes or combinators it can't check are skipped rather than guessed at.
+func parseStylesheet(css string) stylesheet {
+	var sheet stylesheet
+	css = cssCommentPattern.ReplaceAllString(css, "")
+
+	for _, block := range strings.Split(css, "}") {
+		selectors, body, found := strings.Cut(block, "{")
+		if !found || strings.Contains(selectors, "@") {
+			continue
+		}
+		declarations := parseDeclarations(body)
+		if len(declarations) == 0 {
+			continue
+		}
+
+		for _, selector := range strings.Split(selectors, ",") {
+			fields := strings.Fields(selector)
+			if len(fields) == 0 {
+				continue
+			}
+			last := fields[len(fields)-1]
+			if strings.ContainsAny(last, "#[:>+~*") {
+				continue
+			}
+			tag, class, _ := strings.Cut(strings.ToLower(last), ".")
+			if strings.Contains(class, ".") {
+				continue
+			}
+			sheet = append(sheet, rule{tag: tag, class: class, declarations: declarations, order: len(sheet)})
+		}
+	}
+
+	return sheet
+}
+
+// parseDeclarations reads "property: value; ..." into a map, as found in style attributes
+// and rule bodies
+func parseDeclarations(css string) map[string]string {
+	declarations := make(map[string]string)
+	for _, declaration := range strings.Split(css, ";") {
+		property, value, found := strings.Cut(declaration, ":")
+		if !found {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		if property != "" && value != "" {
+			declarations[property] = strings.ToLower(value)
+		}
+	}
+	return declarations
+}
+
+// match returns the declarations that apply to an element, least specific first
+func (s stylesheet) match(tag string, classes []string) []map[string]string {
+	var matched []rule
+	for _, r := range s {
+		if r.tag != "" && r.tag != tag {
+			continue
+		}
+		if r.class != "" && !slices.Contains(classes, r.class) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].specificity() != matched[j].specificity() {
+			return matched[i].specificity() < matched[j].specificity()
+		}
+		return matched[i].order < matched[j].order
+	})
+
+	declarations := make([]map[string]string, len(matched))
+	for i, r := range matched {
+		declarations[i] = r.declarations
+	}
+	return declarations
+}
+
+// fontSizeKeywords are the CSS size keywords, relative to the surrounding text
+var fontSizeKeywords = map[string]float64{
+	"xx-small": 0.6,
+	"x-small":  0.75,
+	"small":    0.89,
+	"smaller":  0.83,
+	"medium":   1,
+	"large":    1.2,
+	"larger":   1.2,
+	"x-large":  1.5,
+	"xx-large": 2,
+}
+
+// length resolves a CSS length to device pixels. Percentages are of the content width, as
+// they are for margins; em is the element's font size and cssPixel the size of a CSS px.
+func length(value string, emPixels, widthPixels, cssPixel float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "0" || value == "auto" {
+		return 0, true
+	}
+
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"rem", emPixels}, // Close enough: nested sizes are rare in EPUBs
+		{"em", emPixels},
+		{"%", widthPixels / 100},
+		{"px", cssPixel},
+		{"pt", cssPixel * 96 / 72},
+	}
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, false
+			}
+			return n * unit.scale, true
+		}
+	}
+	return 0, false
+}
+
+// boxSides expands a margin shorthand into top, right, bottom and left
+func boxSides(value string) [4]string {
+	parts := strings.Fields(value)
+	switch len(parts) {
+	case 1:
+		return [4]string{parts[0], parts[0], parts[0], parts[0]}
+	case 2:
+		return [4]string{parts[0], parts[1], parts[0], parts[1]}
+	case 3:
+		return [4]string{parts[0], parts[1], parts[2], parts[1]}
+	case 4:
+		return [4]string{parts[0], parts[1], parts[2], parts[3]}
+	}
+	return [4]string{"0", "0", "0", "0"}
+}
@@ -0,0 +1,397 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "github.com/gen2brain/webp"
+)
+
+type alignment int
+
+const (
+	alignLeft alignment = iota
+	alignCenter
+	alignRight
+	alignJustify
+)
+
+// style is an element's computed style, as far as the renderer cares
+type style struct {
+	bold, italic bool
+	hidden       bool
+	pre          bool
+	size         float64 // Font size in pixels
+	align        alignment
+	left, right  float64 // Indent from the content edges, in pixels
+	indent       float64 // First line indent, in pixels
+
+	marginTop, marginBottom float64 // Not inherited
+}
+
+// run is a stretch of text in one font
+type run struct {
+	text         string
+	bold, italic bool
+	size         float64
+}
+
+type blockKind int
+
+const (
+	textBlock blockKind = iota
+	imageBlock
+	ruleBlock
+	spaceBlock
+)
+
+// block is one item in the flow of a chapter: a paragraph, an image, a horizontal rule or
+// vertical space between them
+type block struct {
+	kind        blockKind
+	runs        []run
+	align       alignment
+	left, right float64
+	indent      float64
+	image       image.Image
+	space       float64
+}
+
+// userAgentStyles are the defaults every browser applies, trimmed to what matters here
+var userAgentStyles = map[string]map[string]string{
+	"h1":         {"font-size": "1.6em", "font-weight": "bold", "margin": "0.67em 0"},
+	"h2":         {"font-size": "1.4em", "font-weight": "bold", "margin": "0.83em 0"},
+	"h3":         {"font-size": "1.2em", "font-weight": "bold", "margin": "1em 0"},
+	"h4":         {"font-weight": "bold", "margin": "1.33em 0"},
+	"h5":         {"font-size": "0.9em", "font-weight": "bold", "margin": "1.67em 0"},
+	"h6":         {"font-size": "0.8em", "font-weight": "bold", "margin": "2.33em 0"},
+	"p":          {"margin": "1em 0"},
+	"blockquote": {"margin": "1em 2.5em"},
+	"figure":     {"margin": "1em 2.5em"},
+	"ul":         {"margin": "1em 0 1em 1.5em"},
+	"ol":         {"margin": "1em 0 1em 1.5em"},
+	"dd":         {"margin-left": "2.5em"},
+	"pre":        {"margin": "1em 0"},
+	"b":          {"font-weight": "bold"},
+	"strong":     {"font-weight": "bold"},
+	"th":         {"font-weight": "bold"},
+	"dt":         {"font-weight": "bold"},
+	"i":          {"font-style": "italic"},
+	"em":         {"font-style": "italic"},
+	"cite":       {"font-style": "italic"},
+	"var":        {"font-style": "italic"},
+	"dfn":        {"font-style": "italic"},
+	"small":      {"font-size": "smaller"},
+	"sub":        {"font-size": "smaller"},
+	"sup":        {"font-size": "smaller"},
+	"center":     {"text-align": "center"},
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "blockquote": true, "pre": true, "center": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"figure": true, "figcaption": true, "section": true, "article": true, "aside": true,
+	"header": true, "footer": true, "nav": true, "main": true, "address": true,
+	"table": true, "tr": true, "td": true, "th": true, "caption": true,
+}
+
+// skippedElements have no visible content
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true, "noscript": true,
+}
+
+// layout turns a document into blocks
+type layout struct {
+	width    float64 // Content width, for percentages
+	cssPixel float64
+	sheet    stylesheet
+	load     func(src string) ([]byte, error)
+
+	blocks  []block
+	current *block
+}
+
+// walk lays out a node's children
+func (l *layout) walk(n *html.Node, st style) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			if !st.hidden {
+				l.text(child.Data, st)
+			}
+		case html.ElementNode:
+			l.element(child, st)
+		}
+	}
+}
+
+func (l *layout) element(n *html.Node, parent style) {
+	tag := strings.ToLower(n.Data)
+	if skippedElements[tag] {
+		return
+	}
+
+	declarations := l.declarations(n, tag)
+	st := parent.inherit()
+	l.apply(&st, declarations, parent.size)
+	if st.hidden {
+		return
+	}
+
+	switch tag {
+	case "img", "image":
+		src := attribute(n, "src")
+		if src == "" {
+			src = attribute(n, "href") // SVG <image>, with or without the xlink prefix
+		}
+		l.image(src, attribute(n, "alt"), st)
+		return
+	case "br":
+		l.text("\n", st)
+		return
+	case "hr":
+		l.flush()
+		l.blocks = append(l.blocks, block{kind: ruleBlock, left: st.left, right: st.right})
+		return
+	}
+
+	if !blockElements[tag] && declarations["display"] != "block" {
+		l.walk(n, st)
+		return
+	}
+
+	l.flush()
+	l.space(st.marginTop)
+	if tag == "li" {
+		l.text(listMarker(n), st)
+	}
+	l.walk(n, st)
+	l.flush()
+	l.space(st.marginBottom)
+}
+
+// declarations gathers an element's CSS in cascade order: browser defaults, then the
+// stylesheets, then its style attribute
+func (l *layout) declarations(n *html.Node, tag string) map[string]string {
+	declarations := make(map[string]string)
+	for property, value := range userAgentStyles[tag] {
+		declarations[property] = value
+	}
+	for _, matched := range l.sheet.match(tag, strings.Fields(attribute(n, "class"))) {
+		for property, value := range matched {
+			declarations[property] = value
+		}
+	}
+	for property, value := range parseDeclarations(attribute(n, "style")) {
+		declarations[property] = value
+	}
+	return declarations
+}
+
+// inherit returns the style a child element starts from
+func (st style) inherit() style {
+	st.marginTop, st.marginBottom = 0, 0
+	return st
+}
+
+// apply sets the declarations on a style. The font size comes first, since em lengths
+// in the same rule are relative to it.
+func (l *layout) apply(st *style, declarations map[string]string, parentSize float64) {
+	if value, ok := declarations["font-size"]; ok {
+		if scale, ok := fontSizeKeywords[value]; ok {
+			st.size = parentSize * scale
+		} else if size, ok := length(value, parentSize, parentSize*100, l.cssPixel); ok && size > 0 {
+			// A percentage font size is of the parent's font, not the content width
+			st.size = size
+		}
+	}
+
+	resolve := func(value string) (float64, bool) {
+		return length(value, st.size, l.width, l.cssPixel)
+	}
+
+	if margin, ok := declarations["margin"]; ok {
+		sides := boxSides(margin)
+		for i, property := range []string{"margin-top", "margin-right", "margin-bottom", "margin-left"} {
+			if _, set := declarations[property]; !set {
+				declarations[property] = sides[i]
+			}
+		}
+	}
+
+	for property, value := range declarations {
+		switch property {
+		case "font-weight":
+			weight, err := strconv.Atoi(value)
+			st.bold = value == "bold" || value == "bolder" || (err == nil && weight >= 600)
+		case "font-style":
+			st.italic = value == "italic" || value == "oblique"
+		case "text-align":
+			switch value {
+			case "center":
+				st.align = alignCenter
+			case "right", "end":
+				st.align = alignRight
+			case "justify":
+				st.align = alignJustify
+			default:
+				st.align = alignLeft
+			}
+		case "text-indent":
+			if px, ok := resolve(value); ok {
+				st.indent = px
+			}
+		case "margin-top":
+			if px, ok := resolve(value); ok {
+				st.marginTop = px
+			}
+		case "margin-bottom":
+			if px, ok := resolve(value); ok {
+				st.marginBottom = px
+			}
+		case "margin-left", "padding-left":
+			if px, ok := resolve(value); ok {
+				st.left += px
+			}
+		case "margin-right", "padding-right":
+			if px, ok := resolve(value); ok {
+				st.right += px
+			}
+		case "display":
+			st.hidden = st.hidden || value == "none"
+		case "visibility":
+			st.hidden = st.hidden || value == "hidden"
+		case "color":
+			// The invisible text layer over scanned pages
+			st.hidden = st.hidden || value == "transparent"
+		case "white-space":
+			st.pre = strings.HasPrefix(value, "pre")
+		}
+	}
+}
+
+// text adds text to the current paragraph, collapsing white space the way HTML does
+func (l *layout) text(text string, st style) {
+	if l.current == nil {
+		l.current = &block{kind: textBlock, align: st.align, left: st.left, right: st.right, indent: st.indent}
+	}
+
+	if !st.pre && text != "\n" {
+		leading := isSpace(text[0]) && !l.endsWithSpace()
+		trailing := isSpace(text[len(text)-1])
+		collapsed := strings.Join(strings.Fields(text), " ")
+		if collapsed == "" {
+			if !leading {
+				return
+			}
+			collapsed = " "
+		} else {
+			if leading {
+				collapsed = " " + collapsed
+			}
+			if trailing {
+				collapsed += " "
+			}
+		}
+		text = collapsed
+	}
+
+	l.current.runs = append(l.current.runs, run{text: text, bold: st.bold, italic: st.italic, size: st.size})
+}
+
+func (l *layout) endsWithSpace() bool {
+	runs := l.current.runs
+	if len(runs) == 0 {
+		return true // Nothing to separate from
+	}
+	last := runs[len(runs)-1].text
+	return last == "\n" || strings.HasSuffix(last, " ")
+}
+
+// image adds an image, or its alt text if it can't be shown
+func (l *layout) image(src, alt string, st style) {
+	if src != "" && l.load != nil {
+		if data, err := l.load(src); err == nil {
+			if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+				l.flush()
+				l.blocks = append(l.blocks, block{kind: imageBlock, image: img, align: st.align, left: st.left, right: st.right})
+				return
+			}
+		}
+	}
+
+	if alt != "" {
+		st.italic = true
+		l.text("["+alt+"]", st)
+	}
+}
+
+// space adds vertical space. Adjacent margins collapse into the larger of the two.
+func (l *layout) space(px float64) {
+	if px <= 0 {
+		return
+	}
+	if n := len(l.blocks); n > 0 && l.blocks[n-1].kind == spaceBlock {
+		l.blocks[n-1].space = max(l.blocks[n-1].space, px)
+		return
+	}
+	l.blocks = append(l.blocks, block{kind: spaceBlock, space: px})
+}
+
+// flush ends the current paragraph
+func (l *layout) flush() {
+	if l.current == nil {
+		return
+	}
+	current := l.current
+	l.current = nil
+
+	// Trailing spaces and line breaks take no room
+	for len(current.runs) > 0 {
+		last := &current.runs[len(current.runs)-1]
+		last.text = strings.TrimRight(last.text, " ")
+		if last.text != "" && last.text != "\n" {
+			break
+		}
+		current.runs = current.runs[:len(current.runs)-1]
+	}
+	if len(current.runs) > 0 {
+		l.blocks = append(l.blocks, *current)
+	}
+}
+
+// listMarker is the bullet or number in front of a list item
+func listMarker(n *html.Node) string {
+	if n.Parent == nil || strings.ToLower(n.Parent.Data) != "ol" {
+		return "• "
+	}
+	number := 1
+	for sibling := n.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode && strings.ToLower(sibling.Data) == "li" {
+			number++
+		}
+	}
+	return strconv.Itoa(number) + ". "
+}
+
+func attribute(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if strings.ToLower(attr.Key) == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,466 @@
+// Package render lays out EPUB content documents the way a reader would, at the reader's
+// resolution, so what a conversion looks like can be checked without copying it to a
+// device. It knows HTML and the handful of CSS properties publify's own output relies on
+// (alignment, weight, style, size, margins and hiding), not the whole of CSS, and sets
+// everything in the Go fonts it embeds.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/alde/publify/pkg/reader"
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/net/html"
+)
+
+// lineHeight is the CSS "normal" line height most readers default to
+const lineHeight = 1.3
+
+// Page margins, as a fraction of the screen
+const (
+	horizontalMargin = 0.05
+	verticalMargin   = 0.04
+)
+
+// Options describes the screen pages are rendered for
+type Options struct {
+	Width    int  // Screen width in pixels
+	Height   int  // Screen height in pixels
+	DPI      int  // Screen resolution, which sets the size of text
+	FontSize int  // Base font size in points
+	Color    bool // Keep colour; otherwise pages come out greyscale, as on e-ink
+}
+
+// OptionsFor returns the options that match a reader profile
+func OptionsFor(profile reader.Profile) Options {
+	caps := profile.Capabilities
+	return Options{
+		Width:    caps.ScreenWidth,
+		Height:   caps.ScreenHeight,
+		DPI:      caps.DPI,
+		FontSize: caps.DefaultFontSize,
+		Color:    caps.SupportsColor,
+	}
+}
+
+type faceKey struct {
+	bold, italic bool
+	size         float64
+}
+
+// Renderer renders content documents into page images
+type Renderer struct {
+	opts  Options
+	fonts [4]*opentype.Font // Indexed by fontIndex
+	faces map[faceKey]font.Face
+}
+
+// New creates a renderer for a screen
+func New(opts Options) (*Renderer, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("invalid screen size: %dx%d", opts.Width, opts.Height)
+	}
+	if opts.DPI <= 0 {
+		opts.DPI = 96
+	}
+	if opts.FontSize <= 0 {
+		opts.FontSize = 12
+	}
+
+	r := &Renderer{opts: opts, faces: make(map[faceKey]font.Face)}
+	for i, ttf := range [][]byte{goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF} {
+		f, err := opentype.Parse(ttf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load font: %w", err)
+		}
+		r.fonts[i] = f
+	}
+	return r, nil
+}
+
+// RenderChapter lays out a content document and returns its pages. load reads the images
+// and stylesheets it refers to, by their path relative to the document.
+func (r *Renderer) RenderChapter(document []byte, load func(src string) ([]byte, error)) ([]image.Image, error) {
+	root, err := html.Parse(bytes.NewReader(document))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content document: %w", err)
+	}
+
+	marginX := math.Round(float64(r.opts.Width) * horizontalMargin)
+	l := &layout{
+		width:    float64(r.opts.Width) - 2*marginX,
+		cssPixel: float64(r.opts.DPI) / 96,
+		sheet:    parseStylesheet(stylesheets(root, load)),
+		load:     load,
+	}
+
+	body := findElement(root, "body")
+	if body == nil {
+		body = root
+	}
+	base := style{size: float64(r.opts.FontSize) * float64(r.opts.DPI) / 72}
+	l.apply(&base, l.declarations(body, "body"), base.size)
+	l.walk(body, base)
+	l.flush()
+
+	return r.paginate(l.blocks), nil
+}
+
+// stylesheets collects the CSS a document uses, linked and inline, in document order
+func stylesheets(root *html.Node, load func(string) ([]byte, error)) string {
+	var css strings.Builder
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch strings.ToLower(n.Data) {
+			case "link":
+				href := attribute(n, "href")
+				if strings.Contains(strings.ToLower(attribute(n, "rel")), "stylesheet") && href != "" && load != nil {
+					if data, err := load(href); err == nil {
+						css.Write(data)
+						css.WriteString("\n")
+					}
+				}
+			case "style":
+				for child := n.FirstChild; child != nil; child = child.NextSibling {
+					css.WriteString(child.Data)
+				}
+				css.WriteString("\n")
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(root)
+	return css.String()
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && strings.ToLower(n.Data) == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// page is the page being filled
+type page struct {
+	img    *image.RGBA
+	y      float64
+	top    float64
+	bottom float64
+	empty  bool
+}
+
+// paginate draws the blocks onto as many pages as they need
+func (r *Renderer) paginate(blocks []block) []image.Image {
+	width, height := float64(r.opts.Width), float64(r.opts.Height)
+	marginX := math.Round(width * horizontalMargin)
+	marginY := math.Round(height * verticalMargin)
+
+	var pages []image.Image
+	var current *page
+	newPage := func() {
+		if current != nil {
+			pages = append(pages, r.finish(current.img))
+		}
+		img := image.NewRGBA(image.Rect(0, 0, r.opts.Width, r.opts.Height))
+		draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+		current = &page{img: img, y: marginY, top: marginY, bottom: height - marginY, empty: true}
+	}
+	newPage()
+
+	for _, b := range blocks {
+		left := marginX + b.left
+		right := width - marginX - b.right
+		if right-left < width/4 {
+			// Indents that leave no room are ignored rather than letting text pile up
+			left, right = marginX, width-marginX
+		}
+
+		switch b.kind {
+		case spaceBlock:
+			// Space at the top of a page is dropped, as readers do
+			if !current.empty {
+				current.y += b.space
+			}
+
+		case ruleBlock:
+			if current.y+2 > current.bottom {
+				newPage()
+			}
+			draw.Draw(current.img, image.Rect(int(left), int(current.y), int(right), int(current.y)+1), image.Black, image.Point{}, draw.Src)
+			current.y += 2
+			current.empty = false
+
+		case imageBlock:
+			img := fitImage(b.image, right-left, current.bottom-current.top)
+			h := float64(img.Bounds().Dy())
+			if current.y+h > current.bottom && !current.empty {
+				newPage()
+			}
+			// Block images are centered on most readers unless pushed right
+			x := left + (right-left-float64(img.Bounds().Dx()))/2
+			if b.align == alignRight {
+				x = right - float64(img.Bounds().Dx())
+			}
+			draw.Draw(current.img, image.Rect(int(x), int(current.y), int(x)+img.Bounds().Dx(), int(current.y+h)), img, img.Bounds().Min, draw.Over)
+			current.y += h
+			current.empty = false
+
+		case textBlock:
+			lines := r.breakLines(b.runs, right-left, b.indent)
+			for i, line := range lines {
+				if current.y+line.height > current.bottom && !current.empty {
+					newPage()
+				}
+				x := left
+				if i == 0 {
+					x += b.indent
+				}
+				r.drawLine(current.img, line, x, current.y, right-x, b.align, i == len(lines)-1)
+				current.y += line.height
+				current.empty = false
+			}
+		}
+	}
+
+	pages = append(pages, r.finish(current.img))
+	return pages
+}
+
+// finish converts a page to greyscale for readers without colour
+func (r *Renderer) finish(img *image.RGBA) image.Image {
+	if r.opts.Color {
+		return img
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+// fitImage scales an image down to fit the space, never up: readers show small images at
+// their own size
+func fitImage(img image.Image, maxWidth, maxHeight float64) image.Image {
+	w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+	scale := math.Min(1, math.Min(maxWidth/w, maxHeight/h))
+	if scale >= 1 {
+		return img
+	}
+	return imaging.Resize(img, max(1, int(w*scale)), max(1, int(h*scale)), imaging.Lanczos)
+}
+
+func alignOffset(align alignment, available, used float64) float64 {
+	switch align {
+	case alignCenter:
+		return (available - used) / 2
+	case alignRight:
+		return available - used
+	}
+	return 0
+}
+
+func fontIndex(bold, italic bool) int {
+	i := 0
+	if bold {
+		i |= 1
+	}
+	if italic {
+		i |= 2
+	}
+	return i
+}
+
+// face returns the font face for a run, cached since building one isn't cheap
+func (r *Renderer) face(bold, italic bool, size float64) font.Face {
+	key := faceKey{bold: bold, italic: italic, size: math.Round(size*4) / 4}
+	if face, ok := r.faces[key]; ok {
+		return face
+	}
+
+	// At 72 DPI a point is a pixel, so the size can be given in pixels
+	face, err := opentype.NewFace(r.fonts[fontIndex(bold, italic)], &opentype.FaceOptions{
+		Size:    key.size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		face = nil
+	}
+	r.faces[key] = face
+	return face
+}
+
+// word is an unbreakable piece of a line, possibly in several fonts ("<b>un</b>breakable")
+type word struct {
+	pieces []wordPiece
+	width  float64
+	space  float64 // Width of the space after it, 0 at the end of a line
+}
+
+type wordPiece struct {
+	text  string
+	face  font.Face
+	width float64
+}
+
+type line struct {
+	words      []word
+	width      float64 // Without the trailing space
+	height     float64 // Line box, with leading
+	fontHeight float64 // Tallest font on the line, without leading
+	ascent     float64
+	newline    bool // Ended by a <br>, so it isn't justified
+}
+
+// breakLines splits a paragraph into lines that fit the width
+func (r *Renderer) breakLines(runs []run, width, indent float64) []line {
+	words, breaks := r.words(runs)
+
+	var lines []line
+	current := line{}
+	available := width - indent
+	for i, w := range words {
+		if len(current.words) > 0 && current.width+current.words[len(current.words)-1].space+w.width > available {
+			lines = append(lines, current)
+			current = line{}
+			available = width
+		}
+		if len(current.words) > 0 {
+			current.width += current.words[len(current.words)-1].space
+		}
+		current.words = append(current.words, w)
+		current.width += w.width
+
+		if breaks[i] {
+			current.newline = true
+			lines = append(lines, current)
+			current = line{}
+			available = width
+		}
+	}
+	if len(current.words) > 0 {
+		lines = append(lines, current)
+	}
+
+	// Line heights from the largest font on each line
+	for i := range lines {
+		for _, w := range lines[i].words {
+			for _, piece := range w.pieces {
+				if piece.face == nil {
+					continue
+				}
+				metrics := piece.face.Metrics()
+				fontHeight := float64(metrics.Height.Ceil())
+				lines[i].fontHeight = max(lines[i].fontHeight, fontHeight)
+				lines[i].height = max(lines[i].height, fontHeight*lineHeight)
+				lines[i].ascent = max(lines[i].ascent, float64(metrics.Ascent.Ceil()))
+			}
+		}
+	}
+	return lines
+}
+
+// words splits runs into words, returning which of them end in a forced line break
+func (r *Renderer) words(runs []run) ([]word, map[int]bool) {
+	var words []word
+	breaks := make(map[int]bool)
+	open := false // Whether the last word can still be continued by the next run
+
+	for _, rn := range runs {
+		face := r.face(rn.bold, rn.italic, rn.size)
+		if face == nil {
+			continue
+		}
+		if rn.text == "\n" {
+			if len(words) == 0 || breaks[len(words)-1] {
+				// An empty line still takes the height of its font
+				words = append(words, word{pieces: []wordPiece{{face: face}}})
+			}
+			breaks[len(words)-1] = true
+			open = false
+			continue
+		}
+
+		spaceWidth := float64(font.MeasureString(face, " ")) / 64
+		parts := strings.Split(rn.text, " ")
+		for i, part := range parts {
+			if part != "" {
+				piece := wordPiece{text: part, face: face, width: float64(font.MeasureString(face, part)) / 64}
+				if open && len(words) > 0 {
+					last := &words[len(words)-1]
+					last.pieces = append(last.pieces, piece)
+					last.width += piece.width
+				} else {
+					words = append(words, word{pieces: []wordPiece{piece}, width: piece.width})
+				}
+				open = true
+			}
+			if i < len(parts)-1 {
+				// A space: the next part starts a new word
+				if len(words) > 0 && open {
+					words[len(words)-1].space = spaceWidth
+				}
+				open = false
+			}
+		}
+	}
+	return words, breaks
+}
+
+// drawLine draws a line of text with its top at y
+func (r *Renderer) drawLine(img *image.RGBA, l line, x, y, available float64, align alignment, last bool) {
+	gap := 0.0
+	if align == alignJustify && !last && !l.newline && len(l.words) > 1 {
+		gap = (available - l.width) / float64(len(l.words)-1)
+	} else {
+		x += alignOffset(align, available, l.width)
+	}
+
+	// Leading is split evenly above and below the text
+	baseline := y + (l.height-l.fontHeight)/2 + l.ascent
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(color.Black)}
+	for i, w := range l.words {
+		drawer.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(baseline * 64)}
+		for _, piece := range w.pieces {
+			drawer.Face = piece.face
+			drawer.DrawString(piece.text)
+		}
+		x += w.width
+		if i < len(l.words)-1 {
+			x += w.space + gap
+		}
+	}
+}
+
+// PageFileName is the file name for a rendered page, so a directory of them sorts in
+// reading order
+func PageFileName(chapter, page int) string {
+	return fmt.Sprintf("chapter%03d-page%03d.png", chapter, page)
+}
+
+// Resolve returns the path of a resource referenced from a document, relative to the
+// same root as the document's own path
+func Resolve(documentPath, src string) string {
+	src, _, _ = strings.Cut(src, "#")
+	return path.Join(path.Dir(documentPath), src)
+}
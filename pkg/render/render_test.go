@@ -0,0 +1,149 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func testRenderer(t *testing.T, colour bool) *Renderer {
+	t.Helper()
+	r, err := New(Options{Width: 600, Height: 800, DPI: 150, FontSize: 12, Color: colour})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return r
+}
+
+func document(body string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Test</title></head><body>` + body + `</body></html>`)
+}
+
+// inked counts the pixels that aren't white
+func inked(img image.Image) int {
+	count := 0
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 200 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestRenderChapterPaginates(t *testing.T) {
+	paragraph := "<p>" + strings.Repeat("Some honest words about the weather. ", 40) + "</p>"
+	pages, err := testRenderer(t, false).RenderChapter(document(strings.Repeat(paragraph, 10)), nil)
+	if err != nil {
+		t.Fatalf("RenderChapter failed: %v", err)
+	}
+
+	if len(pages) < 2 {
+		t.Fatalf("Expected long text to need several pages, got %d", len(pages))
+	}
+	for i, page := range pages {
+		if page.Bounds().Dx() != 600 || page.Bounds().Dy() != 800 {
+			t.Errorf("Page %d is %v, expected 600x800", i+1, page.Bounds())
+		}
+		if inked(page) == 0 {
+			t.Errorf("Page %d is blank", i+1)
+		}
+	}
+}
+
+func TestRenderChapterGreyscale(t *testing.T) {
+	body := `<p style="color: red">Red text</p>`
+
+	pages, err := testRenderer(t, false).RenderChapter(document(body), nil)
+	if err != nil {
+		t.Fatalf("RenderChapter failed: %v", err)
+	}
+	if _, ok := pages[0].(*image.Gray); !ok {
+		t.Errorf("Expected a greyscale page for an e-ink screen, got %T", pages[0])
+	}
+
+	pages, err = testRenderer(t, true).RenderChapter(document(body), nil)
+	if err != nil {
+		t.Fatalf("RenderChapter failed: %v", err)
+	}
+	if _, ok := pages[0].(*image.Gray); ok {
+		t.Error("Expected a colour page for a colour screen")
+	}
+}
+
+func TestRenderChapterSkipsHiddenText(t *testing.T) {
+	r := testRenderer(t, false)
+
+	hidden := `<style>.page-text { color: transparent; }</style>
+<div class="page-text">` + strings.Repeat("Invisible OCR text layer. ", 50) + `</div>
+<p style="display: none">Not shown either</p>`
+	pages, err := r.RenderChapter(document(hidden), nil)
+	if err != nil {
+		t.Fatalf("RenderChapter failed: %v", err)
+	}
+	for i, page := range pages {
+		if n := inked(page); n > 0 {
+			t.Errorf("Page %d has %d inked pixels, expected hidden text to be skipped", i+1, n)
+		}
+	}
+}
+
+func TestStylesheetMatch(t *testing.T) {
+	sheet := parseStylesheet(`
+/* Comments are ignored */
+p.center { text-align: center }
+p { text-align: justify; text-indent: 1em }
+.bold { font-weight: bold }
+#id { color: red }
+a:hover { color: blue }
+@media print { p { display: none } }
+`)
+
+	merged := make(map[string]string)
+	for _, declarations := range sheet.match("p", []string{"center", "bold"}) {
+		for property, value := range declarations {
+			merged[property] = value
+		}
+	}
+
+	expected := map[string]string{"text-align": "center", "text-indent": "1em", "font-weight": "bold"}
+	for property, value := range expected {
+		if merged[property] != value {
+			t.Errorf("%s = %q, expected %q", property, merged[property], value)
+		}
+	}
+	if _, ok := merged["color"]; ok {
+		t.Error("Expected id and pseudo-class selectors to be skipped")
+	}
+	if merged["display"] == "none" {
+		t.Error("Expected @media rules to be skipped")
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		ok       bool
+	}{
+		{"0", 0, true},
+		{"2em", 40, true},
+		{"1.5rem", 30, true},
+		{"10%", 50, true},
+		{"3px", 6, true},
+		{"12pt", 32, true},
+		{"large", 0, false},
+		{"xem", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := length(tt.value, 20, 500, 2)
+		if ok != tt.ok || got != tt.expected {
+			t.Errorf("length(%q) = %v, %v; expected %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
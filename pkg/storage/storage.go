@@ -0,0 +1,95 @@
+// Package storage keeps what publify saves between runs, such as conversion checkpoints,
+// behind one small interface. The CLI keeps them in directories; a server can point them
+// at a shared volume, or at a backend of its own.
+//
+// A store holds values under slash-separated keys ("checkpoints/book/page0012.json").
+// Values are replaced whole, so a reader never sees half of one.
+package storage
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key with no value
+var ErrNotFound = errors.New("not found")
+
+// Store keeps values by key. Implementations must be safe to use from several goroutines.
+type Store interface {
+	// Get returns the value stored under key, or ErrNotFound
+	Get(key string) ([]byte, error)
+
+	// Put stores a value under key, replacing any there
+	Put(key string, value []byte) error
+
+	// Delete removes the value under key; deleting a missing key isn't an error
+	Delete(key string) error
+
+	// List returns the keys starting with prefix, sorted
+	List(prefix string) ([]string, error)
+}
+
+// DeleteAll removes every value whose key starts with prefix
+func DeleteAll(store Store, prefix string) error {
+	keys, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sub is the part of a store under a prefix, for keeping one user's keys apart from
+// another's in a shared store. The keys it's given and lists are relative to the prefix.
+func Sub(store Store, prefix string) Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return store
+	}
+	return &subStore{store: store, prefix: prefix + "/"}
+}
+
+type subStore struct {
+	store  Store
+	prefix string
+}
+
+func (s *subStore) Get(key string) ([]byte, error) {
+	return s.store.Get(s.prefix + key)
+}
+
+func (s *subStore) Put(key string, value []byte) error {
+	return s.store.Put(s.prefix+key, value)
+}
+
+func (s *subStore) Delete(key string) error {
+	return s.store.Delete(s.prefix + key)
+}
+
+func (s *subStore) List(prefix string) ([]string, error) {
+	keys, err := s.store.List(s.prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.prefix)
+	}
+	return keys, nil
+}
+
+// validKey rejects keys that would reach outside a store on the filesystem
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	store := NewDir(root)
+
+	if _, err := store.Get("missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing key, got %v", err)
+	}
+	if keys, err := store.List(""); err != nil || len(keys) != 0 {
+		t.Errorf("Expected an empty list before anything is stored, got %v (%v)", keys, err)
+	}
+
+	for key, value := range map[string]string{
+		"checkpoints/mort/page0002.json": "two",
+		"checkpoints/mort/page0001.json": "one",
+		"checkpoints/eric/page0001.json": "eric",
+	} {
+		if err := store.Put(key, []byte(value)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+	if err := store.Put("checkpoints/mort/page0001.json", []byte("one again")); err != nil {
+		t.Fatalf("Put failed replacing a value: %v", err)
+	}
+
+	if value, err := store.Get("checkpoints/mort/page0001.json"); err != nil || string(value) != "one again" {
+		t.Errorf("Get = %q, %v, expected the replaced value", value, err)
+	}
+	keys, err := store.List("checkpoints/mort/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if expected := []string{"checkpoints/mort/page0001.json", "checkpoints/mort/page0002.json"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("List = %v, expected %v", keys, expected)
+	}
+
+	for _, key := range []string{"../outside", "/etc/passwd", "a//b", "a/./b", ""} {
+		if err := store.Put(key, nil); err == nil {
+			t.Errorf("Expected Put(%q) to be refused", key)
+		}
+	}
+
+	// Deleting everything leaves nothing behind
+	if err := DeleteAll(store, ""); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+	if err := store.Delete("checkpoints/mort/page0001.json"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("Expected the store's directory removed once empty, got %v", err)
+	}
+}
+
+func TestSub(t *testing.T) {
+	shared := NewDir(t.TempDir())
+	mort := Sub(shared, "checkpoints/mort/")
+	eric := Sub(shared, "checkpoints/eric")
+
+	if err := mort.Put("page0001.json", []byte("mort")); err != nil {
+		t.Fatal(err)
+	}
+	if err := eric.Put("page0001.json", []byte("eric")); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := shared.Get("checkpoints/mort/page0001.json"); err != nil || string(value) != "mort" {
+		t.Errorf("Expected the value under the prefix in the shared store, got %q (%v)", value, err)
+	}
+	if keys, err := mort.List(""); err != nil || !reflect.DeepEqual(keys, []string{"page0001.json"}) {
+		t.Errorf("List = %v (%v), expected keys relative to the prefix", keys, err)
+	}
+
+	if err := DeleteAll(mort, ""); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := eric.Get("page0001.json"); err != nil || string(value) != "eric" {
+		t.Errorf("Expected another prefix's values kept, got %q (%v)", value, err)
+	}
+}
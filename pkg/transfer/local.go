@@ -0,0 +1,125 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LocalShare is a book offered for download on the local network, at an address with a
+// random token in it so only whoever is shown the link finds it
+type LocalShare struct {
+	URL string
+
+	name       string
+	data       []byte
+	server     *http.Server
+	listener   net.Listener
+	once       sync.Once
+	downloaded chan struct{}
+}
+
+// ShareLocally starts serving a file on host:port (port 0 picks a free one) until it has
+// been downloaded once or Wait gives up
+func ShareLocally(host string, port int, name string, data []byte) (*LocalShare, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to make a token: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", host, err)
+	}
+
+	share := &LocalShare{
+		name:       name,
+		data:       data,
+		listener:   listener,
+		downloaded: make(chan struct{}),
+	}
+	filePath := "/" + base64.RawURLEncoding.EncodeToString(token) + "/" + url.PathEscape(name)
+	share.URL = "http://" + listener.Addr().String() + filePath
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(filePath, share.serve)
+	share.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go share.server.Serve(listener)
+	return share, nil
+}
+
+// serve sends the book, and counts it downloaded once all of it went out
+func (s *LocalShare) serve(w http.ResponseWriter, r *http.Request) {
+	contentType := mime.TypeByExtension(path.Ext(s.name))
+	if path.Ext(s.name) == ".epub" {
+		contentType = "application/epub+zip"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": s.name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(s.data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := bytes.NewReader(s.data).WriteTo(w); err == nil {
+		s.once.Do(func() { close(s.downloaded) })
+	}
+}
+
+// Wait blocks until the book has been downloaded or ctx ends, then stops serving. It
+// returns ctx's error if nobody downloaded it.
+func (s *LocalShare) Wait(ctx context.Context) error {
+	var err error
+	select {
+	case <-s.downloaded:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Let the download that finished close its connection before shutting down
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if closeErr := s.server.Shutdown(shutdown); closeErr != nil && !errors.Is(closeErr, http.ErrServerClosed) {
+		s.server.Close()
+	}
+	return err
+}
+
+// LANAddress finds this machine's address on the local network: the first private IPv4
+// address of an interface that's up
+func LANAddress() (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.To4() != nil && ipNet.IP.IsPrivate() {
+				return ipNet.IP.String(), nil
+			}
+		}
+	}
+	return "", errors.New("no local network address found; is this machine on Wi-Fi or Ethernet?")
+}
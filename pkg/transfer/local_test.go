@@ -0,0 +1,59 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareLocally(t *testing.T) {
+	book := []byte("PK... the book")
+	share, err := ShareLocally("127.0.0.1", 0, "Mort.epub", book)
+	if err != nil {
+		t.Fatalf("ShareLocally failed: %v", err)
+	}
+
+	// Only the link with the token finds the book
+	guess := share.URL[:strings.LastIndex(share.URL, "/")-4] + "/Mort.epub"
+	if response, err := http.Get(guess); err != nil || response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a guessed link not to find the book, got %v, %v", response, err)
+	}
+
+	response, err := http.Get(share.URL)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(data) != string(book) || response.Header.Get("Content-Type") != "application/epub+zip" {
+		t.Errorf("Unexpected download: %q as %s", data, response.Header.Get("Content-Type"))
+	}
+	if disposition := response.Header.Get("Content-Disposition"); !strings.Contains(disposition, `filename=Mort.epub`) {
+		t.Errorf("Expected the book's name in the download, got %s", disposition)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := share.Wait(ctx); err != nil {
+		t.Errorf("Expected Wait to return once downloaded, got %v", err)
+	}
+	if _, err := http.Get(share.URL); err == nil {
+		t.Error("Expected the server stopped after the download")
+	}
+}
+
+func TestShareLocallyTimesOut(t *testing.T) {
+	share, err := ShareLocally("127.0.0.1", 0, "Mort.epub", []byte("book"))
+	if err != nil {
+		t.Fatalf("ShareLocally failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := share.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline when nobody downloads the book, got %v", err)
+	}
+}
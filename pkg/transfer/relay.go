@@ -0,0 +1,136 @@
+// Package transfer gets converted books onto readers and tablets without cables: served
+// straight from this machine on the local network, or through a relay.
+//
+// A relay is a small web service that holds a book until it's downloaded once; Relay is
+// one. The book is encrypted before it leaves the machine, with AES-256-GCM under a fresh
+// key, and the key only travels in the download link's fragment, which browsers don't send,
+// so what the relay is sent and holds is encrypted. That isn't
+// end-to-end encryption, though: the relay serves the page that decrypts the book, and a
+// relay could serve one that sends it the key. Whoever runs the relay can read the books
+// sent through it, so only use one whose operator you trust. The protocol:
+//
+//	POST <relay>/v1/files
+//	Content-Type: application/octet-stream
+//	Publify-Expires-In: <seconds>
+//	Publify-Max-Downloads: 1
+//
+//	<12-byte nonce><ciphertext and tag>
+//
+// answered with 201 Created and {"url": "...", "expires": "<RFC 3339>"}. The relay serves
+// the file at url once, with a page that decrypts it with the key and name in the
+// fragment (#key=<base64url>&name=<file name>) and saves it. Relay serves that page at
+// url and the file at url/file.
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExpiry is how long a relay keeps a book nobody has downloaded
+const DefaultExpiry = 24 * time.Hour
+
+// Upload is a book waiting on a relay
+type Upload struct {
+	Link    string // One-time download link, with the key in its fragment
+	Expires time.Time
+}
+
+// relayResponse is what a relay answers an upload with
+type relayResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Seal encrypts a file with a fresh key, returning the nonce followed by the ciphertext,
+// and the key
+func Seal(data []byte) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("failed to make a key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to make a nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, data, nil), key, nil
+}
+
+// SendToRelay encrypts a file and uploads it to a relay, which keeps it for one download
+// or until expiry, whichever comes first
+func SendToRelay(ctx context.Context, client *http.Client, relay, name string, data []byte, expiry time.Duration) (*Upload, error) {
+	if !strings.HasPrefix(relay, "https://") {
+		return nil, fmt.Errorf("books are only sent to a relay over https, not to %s", relay)
+	}
+	if expiry <= 0 {
+		expiry = DefaultExpiry
+	}
+
+	sealed, key, err := Seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", name, err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(relay, "/")+"/v1/files", bytes.NewReader(sealed))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("Publify-Expires-In", strconv.Itoa(int(expiry.Seconds())))
+	request.Header.Set("Publify-Max-Downloads", "1")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload to the relay: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+	case http.StatusRequestEntityTooLarge:
+		return nil, fmt.Errorf("the relay doesn't take files as large as %s (%d bytes)", name, len(sealed))
+	default:
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("the relay refused the upload: %s %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	var answer relayResponse
+	if err := json.NewDecoder(io.LimitReader(response.Body, 64<<10)).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("failed to read the relay's answer: %w", err)
+	}
+	if !strings.HasPrefix(answer.URL, "https://") {
+		return nil, fmt.Errorf("the relay gave no https download link (%q)", answer.URL)
+	}
+
+	fragment := url.Values{}
+	fragment.Set("key", base64.RawURLEncoding.EncodeToString(key))
+	fragment.Set("name", name)
+	upload := &Upload{
+		Link:    answer.URL + "#" + fragment.Encode(),
+		Expires: answer.Expires,
+	}
+	if upload.Expires.IsZero() {
+		upload.Expires = time.Now().Add(expiry)
+	}
+	return upload, nil
+}
@@ -0,0 +1,126 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeal(t *testing.T) {
+	book := []byte("PK... not really an EPUB")
+	sealed, key, err := Seal(book)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, book) {
+		t.Error("Expected the book encrypted")
+	}
+
+	opened, err := open(sealed, key)
+	if err != nil || !bytes.Equal(opened, book) {
+		t.Errorf("Expected the sealed book to open with its key, got %q, %v", opened, err)
+	}
+
+	_, other, _ := Seal(book)
+	if _, err := open(sealed, other); err == nil {
+		t.Error("Expected another key not to open the book")
+	}
+}
+
+func TestSendToRelay(t *testing.T) {
+	book := []byte("PK... the book")
+	var received []byte
+	var headers http.Header
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/files" {
+			http.NotFound(w, r)
+			return
+		}
+		received, _ = io.ReadAll(r.Body)
+		headers = r.Header
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"url":     "https://relay.example.com/d/x7Kq",
+			"expires": "2026-10-17T12:00:00Z",
+		})
+	}))
+	defer server.Close()
+
+	upload, err := SendToRelay(context.Background(), server.Client(), server.URL+"/", "Mort.epub", book, time.Hour)
+	if err != nil {
+		t.Fatalf("SendToRelay failed: %v", err)
+	}
+	if headers.Get("Publify-Expires-In") != "3600" || headers.Get("Publify-Max-Downloads") != "1" {
+		t.Errorf("Unexpected upload headers: %v", headers)
+	}
+	if !upload.Expires.Equal(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the relay's expiry, got %v", upload.Expires)
+	}
+
+	link, fragment, _ := strings.Cut(upload.Link, "#")
+	if link != "https://relay.example.com/d/x7Kq" {
+		t.Errorf("Unexpected link %s", upload.Link)
+	}
+	values, err := url.ParseQuery(fragment)
+	if err != nil || values.Get("name") != "Mort.epub" {
+		t.Fatalf("Expected the name in the fragment, got %q", fragment)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(values.Get("key"))
+	if err != nil {
+		t.Fatalf("Invalid key in the fragment: %v", err)
+	}
+	if opened, err := open(received, key); err != nil || !bytes.Equal(opened, book) {
+		t.Errorf("Expected the relay to get the book sealed with the link's key, got %v", err)
+	}
+}
+
+func TestSendToRelayErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/full/v1/files":
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case "/plain/v1/files":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"url": "http://relay.example.com/d/x7Kq"}`))
+		default:
+			http.Error(w, "relay closed", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	for name, test := range map[string]struct{ relay, message string }{
+		"plain http": {"http://relay.example.com", "only sent to a relay over https"},
+		"too large":  {server.URL + "/full", "doesn't take files as large"},
+		"http link":  {server.URL + "/plain", "no https download link"},
+		"refused":    {server.URL + "/closed", "relay closed"},
+	} {
+		_, err := SendToRelay(context.Background(), server.Client(), test.relay, "book.epub", []byte("book"), 0)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an error about %q, got %v", name, test.message, err)
+		}
+	}
+}
+
+// open decrypts a sealed file, as the relay's download page does
+func open(sealed, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,251 @@
+package transfer
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Relay limits, unless RelayOptions gives others
+const (
+	DefaultRelayMaxSize   = 200 << 20 // Largest file taken
+	DefaultRelayMaxStored = 2 << 30   // All the files waiting, together
+	DefaultRelayMaxExpiry = 7 * 24 * time.Hour
+)
+
+// RelayOptions configures a relay
+type RelayOptions struct {
+	// URL is where the relay is reached, for the links it gives out ("" = https:// and the
+	// host the upload came to)
+	URL string
+
+	MaxSize   int64         // Largest file taken (0 = DefaultRelayMaxSize)
+	MaxStored int64         // All the files waiting, together (0 = DefaultRelayMaxStored)
+	MaxExpiry time.Duration // Longest a file is kept (0 = DefaultRelayMaxExpiry)
+}
+
+// Relay is the web service SendToRelay uploads to, as described in the package
+// documentation. It keeps files in memory, so they're lost if it restarts, and serves
+// each one once: the link it gives out opens a page that fetches the file from
+// <link>/file and decrypts it in the browser.
+type Relay struct {
+	options RelayOptions
+	mux     *http.ServeMux
+	now     func() time.Time
+
+	mu     sync.Mutex
+	files  map[string]*relayFile
+	stored int64
+}
+
+// relayFile is a sealed file waiting for its download
+type relayFile struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewRelay creates a relay, to serve with an http.Server behind https
+func NewRelay(opts RelayOptions) *Relay {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultRelayMaxSize
+	}
+	if opts.MaxStored <= 0 {
+		opts.MaxStored = DefaultRelayMaxStored
+	}
+	if opts.MaxExpiry <= 0 {
+		opts.MaxExpiry = DefaultRelayMaxExpiry
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+
+	relay := &Relay{
+		options: opts,
+		mux:     http.NewServeMux(),
+		now:     time.Now,
+		files:   make(map[string]*relayFile),
+	}
+	relay.mux.HandleFunc("POST /v1/files", relay.upload)
+	relay.mux.HandleFunc("GET /d/{id}", relay.page)
+	relay.mux.HandleFunc("GET /d/{id}/file", relay.download)
+	return relay
+}
+
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// upload takes a sealed file and answers with its download link
+func (r *Relay) upload(w http.ResponseWriter, req *http.Request) {
+	expiry := DefaultExpiry
+	if value := req.Header.Get("Publify-Expires-In"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "Publify-Expires-In must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+		expiry = time.Duration(seconds) * time.Second
+	}
+	expiry = min(expiry, r.options.MaxExpiry)
+	if downloads := req.Header.Get("Publify-Max-Downloads"); downloads != "" && downloads != "1" {
+		http.Error(w, "files are kept for a single download", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, r.options.MaxSize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("files are limited to %d bytes", r.options.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, "failed to read the file", http.StatusBadRequest)
+		return
+	}
+
+	id, err := newRelayID()
+	if err != nil {
+		http.Error(w, "failed to make a link", http.StatusInternalServerError)
+		return
+	}
+	expires := r.now().Add(expiry).UTC().Truncate(time.Second)
+
+	r.mu.Lock()
+	r.removeExpired()
+	if r.stored+int64(len(data)) > r.options.MaxStored {
+		r.mu.Unlock()
+		http.Error(w, "the relay is full, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	r.files[id] = &relayFile{data: data, expires: expires}
+	r.stored += int64(len(data))
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(relayResponse{URL: r.baseURL(req) + "/d/" + id, Expires: expires})
+}
+
+// page serves the page that fetches a file and decrypts it with the key in the fragment
+func (r *Relay) page(w http.ResponseWriter, req *http.Request) {
+	if r.waiting(req.PathValue("id")) == nil {
+		http.Error(w, "This book has already been downloaded, or its link has expired.", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; connect-src 'self'")
+	w.Write([]byte(relayPage))
+}
+
+// download serves a sealed file, and forgets it once all of it went out
+func (r *Relay) download(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	file := r.waiting(id)
+	if file == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.data)))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(file.data); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	if r.files[id] == file {
+		delete(r.files, id)
+		r.stored -= int64(len(file.data))
+	}
+	r.mu.Unlock()
+}
+
+// waiting returns the file under id, or nil if there's none or it has expired
+func (r *Relay) waiting(id string) *relayFile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeExpired()
+	return r.files[id]
+}
+
+// removeExpired forgets the files nobody downloaded in time; r.mu must be held
+func (r *Relay) removeExpired() {
+	now := r.now()
+	for id, file := range r.files {
+		if !now.Before(file.expires) {
+			delete(r.files, id)
+			r.stored -= int64(len(file.data))
+		}
+	}
+}
+
+// baseURL is where the relay's links point
+func (r *Relay) baseURL(req *http.Request) string {
+	if r.options.URL != "" {
+		return r.options.URL
+	}
+	return "https://" + req.Host
+}
+
+// newRelayID makes the unguessable part of a download link
+func newRelayID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// relayPage fetches the sealed file next to it and decrypts it with the key in the link's
+// fragment, which stays in the browser. It's the relay that serves this page, though, so
+// a relay that meant to could serve one that sends the key back.
+const relayPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Download a book</title>
+</head>
+<body>
+<p id="status">Decrypting the book…</p>
+<script>
+(async function () {
+  var status = document.getElementById("status");
+  try {
+    var params = new URLSearchParams(location.hash.slice(1));
+    var key = params.get("key"), name = params.get("name") || "book.epub";
+    if (!key) {
+      throw new Error("the link has no key. Open all of it, with the part after the #");
+    }
+    var raw = Uint8Array.from(atob(key.replace(/-/g, "+").replace(/_/g, "/")), function (c) { return c.charCodeAt(0); });
+    var response = await fetch(location.pathname + "/file");
+    if (!response.ok) {
+      throw new Error(response.status === 404 ? "it has already been downloaded, or its link has expired" : response.statusText);
+    }
+    var sealed = new Uint8Array(await response.arrayBuffer());
+    var cryptoKey = await crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["decrypt"]);
+    var book = await crypto.subtle.decrypt({name: "AES-GCM", iv: sealed.slice(0, 12)}, cryptoKey, sealed.slice(12));
+    var link = document.createElement("a");
+    link.href = URL.createObjectURL(new Blob([book], {type: "application/epub+zip"}));
+    link.download = name;
+    link.textContent = "Save " + name;
+    status.textContent = "";
+    status.appendChild(link);
+    link.click();
+  } catch (e) {
+    status.textContent = "Couldn't get the book: " + e.message;
+  }
+})();
+</script>
+</body>
+</html>
+`
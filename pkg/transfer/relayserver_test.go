@@ -0,0 +1,132 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayRoundTrip(t *testing.T) {
+	server := httptest.NewTLSServer(NewRelay(RelayOptions{}))
+	defer server.Close()
+	client := server.Client()
+
+	book := []byte("PK... the book")
+	upload, err := SendToRelay(context.Background(), client, server.URL, "Mort.epub", book, time.Hour)
+	if err != nil {
+		t.Fatalf("SendToRelay failed: %v", err)
+	}
+	link, fragment, _ := strings.Cut(upload.Link, "#")
+	if !strings.HasPrefix(link, server.URL+"/d/") {
+		t.Fatalf("Expected a link on the relay, got %s", upload.Link)
+	}
+	if until := time.Until(upload.Expires); until <= 0 || until > time.Hour {
+		t.Errorf("Expected the book kept for an hour, until %v", upload.Expires)
+	}
+
+	// The page the link opens, then the file it fetches and decrypts
+	if status, page := get(t, client, link); status != http.StatusOK || !strings.Contains(string(page), "crypto.subtle.decrypt") {
+		t.Errorf("Expected the decrypting page, got %d %q", status, page)
+	}
+	status, sealed := get(t, client, link+"/file")
+	if status != http.StatusOK {
+		t.Fatalf("Expected the file, got %d", status)
+	}
+	if bytes.Contains(sealed, book) {
+		t.Error("Expected the relay to hold the book encrypted")
+	}
+	values, _ := url.ParseQuery(fragment)
+	key, _ := base64.RawURLEncoding.DecodeString(values.Get("key"))
+	if opened, err := open(sealed, key); err != nil || !bytes.Equal(opened, book) {
+		t.Errorf("Expected the file to open with the link's key, got %q, %v", opened, err)
+	}
+
+	// Downloaded once, and gone
+	if status, _ := get(t, client, link+"/file"); status != http.StatusNotFound {
+		t.Errorf("Expected a second download refused, got %d", status)
+	}
+	if status, _ := get(t, client, link); status != http.StatusNotFound {
+		t.Errorf("Expected the page gone after the download, got %d", status)
+	}
+}
+
+func TestRelayExpiry(t *testing.T) {
+	relay := NewRelay(RelayOptions{URL: "https://relay.example.org/", MaxExpiry: time.Hour})
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	relay.now = func() time.Time { return now }
+	server := httptest.NewTLSServer(relay)
+	defer server.Close()
+
+	upload, err := SendToRelay(context.Background(), server.Client(), server.URL, "book.epub", []byte("book"), 48*time.Hour)
+	if err != nil {
+		t.Fatalf("SendToRelay failed: %v", err)
+	}
+	if !upload.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the expiry held to the relay's longest, got %v", upload.Expires)
+	}
+	link, _, _ := strings.Cut(upload.Link, "#")
+	if !strings.HasPrefix(link, "https://relay.example.org/d/") {
+		t.Fatalf("Expected a link on the relay's URL, got %s", upload.Link)
+	}
+
+	now = now.Add(time.Hour)
+	path := strings.TrimPrefix(link, "https://relay.example.org")
+	if status, _ := get(t, server.Client(), server.URL+path+"/file"); status != http.StatusNotFound {
+		t.Errorf("Expected an expired file gone, got %d", status)
+	}
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	if relay.stored != 0 {
+		t.Errorf("Expected nothing left stored, got %d bytes", relay.stored)
+	}
+}
+
+func TestRelayLimits(t *testing.T) {
+	// Sealing adds 28 bytes, a nonce and a tag
+	server := httptest.NewTLSServer(NewRelay(RelayOptions{MaxSize: 64, MaxStored: 100}))
+	defer server.Close()
+	client := server.Client()
+
+	if _, err := SendToRelay(context.Background(), client, server.URL, "big.epub", make([]byte, 100), 0); err == nil || !strings.Contains(err.Error(), "doesn't take files as large") {
+		t.Errorf("Expected a file over the limit refused, got %v", err)
+	}
+	if _, err := SendToRelay(context.Background(), client, server.URL, "one.epub", make([]byte, 36), 0); err != nil {
+		t.Fatalf("SendToRelay failed: %v", err)
+	}
+	if _, err := SendToRelay(context.Background(), client, server.URL, "two.epub", make([]byte, 36), 0); err == nil || !strings.Contains(err.Error(), "relay is full") {
+		t.Errorf("Expected a full relay to refuse, got %v", err)
+	}
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/files", strings.NewReader("book"))
+	request.Header.Set("Publify-Max-Downloads", "3")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected more than one download refused, got %s", response.Status)
+	}
+}
+
+// get fetches a URL and returns its status and body
+func get(t *testing.T, client *http.Client, link string) (int, []byte) {
+	t.Helper()
+	response, err := client.Get(link)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", link, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Reading %s failed: %v", link, err)
+	}
+	return response.StatusCode, body
+}